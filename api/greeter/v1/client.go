@@ -0,0 +1,202 @@
+package greeterv1
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GreeterServiceClient calls the GreeterService at URL.
+type GreeterServiceClient struct {
+	// URL is the base URL of the server, such as http://localhost:9090.
+	URL string
+	// Header is sent with every call, for credentials such as X-API-Key.
+	Header http.Header
+	// HTTPClient makes the calls. If nil, a client speaking HTTP/2 is used,
+	// without TLS for http URLs.
+	HTTPClient *http.Client
+}
+
+// NewGreeterServiceClient returns a client of the GreeterService at url.
+func NewGreeterServiceClient(url string) *GreeterServiceClient {
+	return &GreeterServiceClient{URL: url}
+}
+
+var defaultHTTPClient = func() *http.Client {
+	var p http.Protocols
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: &p}}
+}()
+
+func (c *GreeterServiceClient) Greet(ctx context.Context, req *GreetRequest) (*GreetResponse, error) {
+	resp := new(GreetResponse)
+	if err := c.unary(ctx, "Greet", req.Marshal(), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *GreeterServiceClient) GreetBatch(ctx context.Context, req *GreetBatchRequest) (*GreetBatchResponse, error) {
+	resp := new(GreetBatchResponse)
+	if err := c.unary(ctx, "GreetBatch", req.Marshal(), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GreetStream starts the call, whose responses Recv returns in turn.
+func (c *GreeterServiceClient) GreetStream(ctx context.Context, req *GreetBatchRequest) (*GreetStreamClient, error) {
+	resp, err := c.call(ctx, "GreetStream", req.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	return &GreetStreamClient{resp: resp}, nil
+}
+
+// GreetStreamClient receives the responses of a GreetStream call.
+type GreetStreamClient struct {
+	resp *http.Response
+	err  error
+}
+
+// Recv returns the next response, or io.EOF once the call has ended
+// with OK and the status it failed with otherwise.
+func (s *GreetStreamClient) Recv() (*GreetResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	msg, err := readFrame(s.resp.Body)
+	if err == io.EOF {
+		if err = trailerStatus(s.resp); err == nil {
+			err = io.EOF
+		}
+	}
+	if err != nil {
+		s.err = err
+		s.resp.Body.Close()
+		return nil, err
+	}
+	resp := new(GreetResponse)
+	if err := resp.Unmarshal(msg); err != nil {
+		s.err = &Status{Internal, err.Error()}
+		s.resp.Body.Close()
+		return nil, s.err
+	}
+	return resp, nil
+}
+
+// Close ends the call, if it has not ended.
+func (s *GreetStreamClient) Close() error {
+	return s.resp.Body.Close()
+}
+
+// unary makes the call to method with req, decoding its one response
+// into resp.
+func (c *GreeterServiceClient) unary(ctx context.Context, method string, req []byte, resp interface{ Unmarshal([]byte) error }) error {
+	r, err := c.call(ctx, method, req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	msg, err := readFrame(r.Body)
+	if err == io.EOF {
+		if err = trailerStatus(r); err == nil {
+			err = &Status{Internal, "no response message"}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// The status follows the message.
+	if _, err := io.Copy(io.Discard, r.Body); err != nil {
+		return statusOf(err)
+	}
+	if err := trailerStatus(r); err != nil {
+		return err
+	}
+	if err := resp.Unmarshal(msg); err != nil {
+		return &Status{Internal, err.Error()}
+	}
+	return nil
+}
+
+// call starts a call to method with req, returning the response once its
+// headers have come, unless they end the call with an error.
+func (c *GreeterServiceClient) call(ctx context.Context, method string, req []byte) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.URL, "/")+ServicePath+method, bytes.NewReader(appendFrame(nil, req)))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("Te", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set("Grpc-Timeout", formatTimeout(time.Until(deadline)))
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = defaultHTTPClient
+	}
+	resp, err := hc.Do(r)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &Status{httpCode(resp.StatusCode), "HTTP status " + resp.Status}
+	}
+	// A call that fails before any response has its status in the headers.
+	if resp.Header.Get("Grpc-Status") != "" {
+		resp.Body.Close()
+		if err := headerStatus(resp.Header); err != nil {
+			return nil, err
+		}
+		return nil, &Status{Internal, "no response message"}
+	}
+	return resp, nil
+}
+
+// trailerStatus returns the status resp ended with, once its body has
+// been read, as an error unless it is OK.
+func trailerStatus(resp *http.Response) error {
+	if resp.Trailer.Get("Grpc-Status") == "" {
+		return &Status{Internal, "call ended without a status"}
+	}
+	return headerStatus(resp.Trailer)
+}
+
+func headerStatus(h http.Header) error {
+	code, err := strconv.ParseUint(h.Get("Grpc-Status"), 10, 32)
+	if err != nil {
+		return &Status{Internal, "invalid grpc-status " + strconv.Quote(h.Get("Grpc-Status"))}
+	}
+	if code == uint64(OK) {
+		return nil
+	}
+	return &Status{Code(code), decodeGRPCMessage(h.Get("Grpc-Message"))}
+}
+
+// httpCode returns the code of a call answered with an HTTP status other
+// than 200, as gRPC maps them.
+func httpCode(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return Internal
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return Unavailable
+	}
+	return Unknown
+}
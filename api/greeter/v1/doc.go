@@ -0,0 +1,10 @@
+// Package greeterv1 holds greeter.proto, the GreeterService definition,
+// and its Go bindings: the messages, a server handler and a client. Its
+// messages are those of api/message/v1. Like those, the bindings are
+// written by hand rather than generated, so that the module needs neither
+// a protobuf nor a gRPC runtime; a change to greeter.proto needs the same
+// change here.
+//
+// The service speaks the gRPC protocol over HTTP/2 without compression,
+// which any gRPC client or server can talk to.
+package greeterv1
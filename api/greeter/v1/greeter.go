@@ -0,0 +1,219 @@
+package greeterv1
+
+import (
+	messagev1 "github.com/fanda-blazek/syntax-highlighting-test/api/message/v1"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/protowire"
+)
+
+// GreetRequest is the GreetRequest message.
+type GreetRequest struct {
+	Name  string
+	Lang  string
+	Style string
+}
+
+// Marshal returns r in the protobuf encoding.
+func (r *GreetRequest) Marshal() []byte {
+	b := protowire.AppendString(nil, 1, r.Name)
+	b = protowire.AppendString(b, 2, r.Lang)
+	return protowire.AppendString(b, 3, r.Style)
+}
+
+// Unmarshal decodes data, a GreetRequest in the protobuf encoding, into r,
+// ignoring fields it does not know.
+func (r *GreetRequest) Unmarshal(data []byte) error {
+	*r = GreetRequest{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		switch num {
+		case 1:
+			v, err = d.Bytes(typ)
+			r.Name = string(v)
+		case 2:
+			v, err = d.Bytes(typ)
+			r.Lang = string(v)
+		case 3:
+			v, err = d.Bytes(typ)
+			r.Style = string(v)
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// GreetResponse is the GreetResponse message.
+type GreetResponse struct {
+	Message *messagev1.Message
+}
+
+// Marshal returns r in the protobuf encoding.
+func (r *GreetResponse) Marshal() []byte {
+	if r.Message == nil {
+		return nil
+	}
+	return protowire.AppendBytes(nil, 1, r.Message.Marshal())
+}
+
+// Unmarshal decodes data, a GreetResponse in the protobuf encoding, into r.
+func (r *GreetResponse) Unmarshal(data []byte) error {
+	*r = GreetResponse{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		switch num {
+		case 1:
+			if v, err = d.Bytes(typ); err == nil {
+				r.Message = new(messagev1.Message)
+				err = r.Message.Unmarshal(v)
+			}
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// GreetBatchRequest is the GreetBatchRequest message.
+type GreetBatchRequest struct {
+	Names []string
+	Lang  string
+	Style string
+}
+
+// Marshal returns r in the protobuf encoding.
+func (r *GreetBatchRequest) Marshal() []byte {
+	var b []byte
+	for _, name := range r.Names {
+		b = protowire.AppendBytes(b, 1, []byte(name))
+	}
+	b = protowire.AppendString(b, 2, r.Lang)
+	return protowire.AppendString(b, 3, r.Style)
+}
+
+// Unmarshal decodes data, a GreetBatchRequest in the protobuf encoding,
+// into r.
+func (r *GreetBatchRequest) Unmarshal(data []byte) error {
+	*r = GreetBatchRequest{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		switch num {
+		case 1:
+			if v, err = d.Bytes(typ); err == nil {
+				r.Names = append(r.Names, string(v))
+			}
+		case 2:
+			v, err = d.Bytes(typ)
+			r.Lang = string(v)
+		case 3:
+			v, err = d.Bytes(typ)
+			r.Style = string(v)
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// GreetBatchResponse is the GreetBatchResponse message.
+type GreetBatchResponse struct {
+	Items []*GreetBatchItem
+}
+
+// GreetBatchItem is the GreetBatchResponse.Item message. Its result is
+// Message if set, and Error otherwise.
+type GreetBatchItem struct {
+	Message *messagev1.Message
+	Error   string
+}
+
+// Marshal returns r in the protobuf encoding.
+func (r *GreetBatchResponse) Marshal() []byte {
+	var b []byte
+	for _, item := range r.Items {
+		b = protowire.AppendBytes(b, 1, item.appendTo(nil))
+	}
+	return b
+}
+
+func (item *GreetBatchItem) appendTo(b []byte) []byte {
+	// A member of a oneof is encoded even when empty, so that it is told
+	// apart from none.
+	if item.Message != nil {
+		return protowire.AppendBytes(b, 1, item.Message.Marshal())
+	}
+	return protowire.AppendBytes(b, 2, []byte(item.Error))
+}
+
+// Unmarshal decodes data, a GreetBatchResponse in the protobuf encoding,
+// into r.
+func (r *GreetBatchResponse) Unmarshal(data []byte) error {
+	*r = GreetBatchResponse{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		switch num {
+		case 1:
+			if v, err = d.Bytes(typ); err == nil {
+				item := new(GreetBatchItem)
+				r.Items = append(r.Items, item)
+				err = item.unmarshal(v)
+			}
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (item *GreetBatchItem) unmarshal(data []byte) error {
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		switch num {
+		case 1:
+			if v, err = d.Bytes(typ); err == nil {
+				item.Message, item.Error = new(messagev1.Message), ""
+				err = item.Message.Unmarshal(v)
+			}
+		case 2:
+			v, err = d.Bytes(typ)
+			item.Message, item.Error = nil, string(v)
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package greeterv1
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServicePath is the path every GreeterService method is under, followed
+// by the method name.
+const ServicePath = "/greeter.v1.GreeterService/"
+
+// MaxMessageSize bounds the messages a handler or client reads, as gRPC's
+// default does.
+const MaxMessageSize = 4 << 20
+
+// A Code is a gRPC status code.
+type Code uint32
+
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = [...]string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded",
+	"NotFound", "AlreadyExists", "PermissionDenied", "ResourceExhausted",
+	"FailedPrecondition", "Aborted", "OutOfRange", "Unimplemented",
+	"Internal", "Unavailable", "DataLoss", "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// Status is the error a call ends with, as its grpc-status and
+// grpc-message trailers carry it. Handlers return one to choose the code
+// a call fails with; other errors are Unknown.
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: %s: %s", s.Code, s.Message)
+}
+
+// statusOf returns err as a Status.
+func statusOf(err error) *Status {
+	var st *Status
+	switch {
+	case errors.As(err, &st):
+		return st
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Status{DeadlineExceeded, err.Error()}
+	case errors.Is(err, context.Canceled):
+		return &Status{Canceled, err.Error()}
+	}
+	return &Status{Unknown, err.Error()}
+}
+
+// GreeterServiceServer is the GreeterService. GreetStream calls send with
+// each response in turn, and stops when it fails.
+type GreeterServiceServer interface {
+	Greet(ctx context.Context, req *GreetRequest) (*GreetResponse, error)
+	GreetBatch(ctx context.Context, req *GreetBatchRequest) (*GreetBatchResponse, error)
+	GreetStream(ctx context.Context, req *GreetBatchRequest, send func(*GreetResponse) error) error
+}
+
+// IsGRPC reports whether r is a gRPC call, by its content type.
+func IsGRPC(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+proto") || strings.HasPrefix(ct, "application/grpc;")
+}
+
+// NewGreeterServiceHandler returns a handler of the calls to srv, the
+// requests under ServicePath. They must come over HTTP/2, for the
+// trailers the status is sent in.
+func NewGreeterServiceHandler(srv GreeterServiceServer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "gRPC calls must be POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !IsGRPC(r) {
+			http.Error(w, "gRPC calls must be application/grpc", http.StatusUnsupportedMediaType)
+			return
+		}
+		if r.ProtoMajor != 2 {
+			http.Error(w, "gRPC calls must be HTTP/2", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		ctx := r.Context()
+		if v := r.Header.Get("Grpc-Timeout"); v != "" {
+			d, err := parseTimeout(v)
+			if err != nil {
+				WriteError(w, &Status{InvalidArgument, err.Error()})
+				return
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		st := &stream{w: w}
+		st.finish(serve(ctx, srv, strings.TrimPrefix(r.URL.Path, ServicePath), r.Body, st))
+	})
+}
+
+// serve calls method of srv with the request in body.
+func serve(ctx context.Context, srv GreeterServiceServer, method string, body io.Reader, st *stream) error {
+	switch method {
+	case "Greet":
+		var req GreetRequest
+		if err := readRequest(body, &req); err != nil {
+			return err
+		}
+		resp, err := srv.Greet(ctx, &req)
+		if err != nil {
+			return err
+		}
+		return st.send(resp.Marshal())
+	case "GreetBatch":
+		var req GreetBatchRequest
+		if err := readRequest(body, &req); err != nil {
+			return err
+		}
+		resp, err := srv.GreetBatch(ctx, &req)
+		if err != nil {
+			return err
+		}
+		return st.send(resp.Marshal())
+	case "GreetStream":
+		var req GreetBatchRequest
+		if err := readRequest(body, &req); err != nil {
+			return err
+		}
+		return srv.GreetStream(ctx, &req, func(resp *GreetResponse) error {
+			return st.send(resp.Marshal())
+		})
+	}
+	return &Status{Unimplemented, "unknown method " + method}
+}
+
+// stream writes the response of a call: its messages, then its status.
+type stream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+// send writes the message msg, flushing it to the client.
+func (st *stream) send(msg []byte) error {
+	if !st.started {
+		st.w.Header().Set("Content-Type", "application/grpc")
+		st.w.WriteHeader(http.StatusOK)
+		st.started = true
+	}
+	if _, err := st.w.Write(appendFrame(nil, msg)); err != nil {
+		return err
+	}
+	if f, ok := st.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// finish ends the call with err, or OK for nil: in trailers after the
+// messages, or in the headers of a response that has none.
+func (st *stream) finish(err error) {
+	s := &Status{Code: OK}
+	if err != nil {
+		s = statusOf(err)
+	}
+	h, prefix := st.w.Header(), http.TrailerPrefix
+	if !st.started {
+		h.Set("Content-Type", "application/grpc")
+		prefix = ""
+	}
+	h.Set(prefix+"Grpc-Status", strconv.FormatUint(uint64(s.Code), 10))
+	if s.Message != "" {
+		h.Set(prefix+"Grpc-Message", encodeGRPCMessage(s.Message))
+	}
+	if !st.started {
+		st.w.WriteHeader(http.StatusOK)
+	}
+}
+
+// WriteError ends a call that has sent nothing yet with err, so that
+// middleware in front of a handler can refuse calls as the handler would.
+func WriteError(w http.ResponseWriter, err error) {
+	(&stream{w: w}).finish(err)
+}
+
+// appendFrame appends msg in the framing of gRPC: uncompressed, after its
+// length.
+func appendFrame(b, msg []byte) []byte {
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// readFrame reads the next message from r, returning io.EOF if there are
+// no more.
+func readFrame(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, &Status{Internal, "truncated message"}
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, &Status{Unimplemented, "compressed messages are not supported"}
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > MaxMessageSize {
+		return nil, &Status{ResourceExhausted, fmt.Sprintf("message of %d bytes is over the limit of %d", n, MaxMessageSize)}
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &Status{Internal, "truncated message"}
+	}
+	return msg, nil
+}
+
+// readRequest reads the one request message of a call from body into req.
+func readRequest(body io.Reader, req interface{ Unmarshal([]byte) error }) error {
+	msg, err := readFrame(body)
+	if err == io.EOF {
+		return &Status{InvalidArgument, "missing request message"}
+	}
+	if err != nil {
+		return err
+	}
+	if err := req.Unmarshal(msg); err != nil {
+		return &Status{InvalidArgument, err.Error()}
+	}
+	return nil
+}
+
+// timeoutUnits are the units of grpc-timeout.
+var timeoutUnits = map[byte]time.Duration{
+	'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+	'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+}
+
+// parseTimeout parses v, a grpc-timeout value such as 100m.
+func parseTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	unit, ok := timeoutUnits[v[len(v)-1]]
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// formatTimeout formats d as a grpc-timeout value, which has at most
+// eight digits.
+func formatTimeout(d time.Duration) string {
+	d = max(d, 0)
+	for _, u := range []struct {
+		unit string
+		d    time.Duration
+	}{{"n", time.Nanosecond}, {"u", time.Microsecond}, {"m", time.Millisecond}, {"S", time.Second}, {"M", time.Minute}} {
+		if n := d / u.d; n < 1e8 {
+			return strconv.FormatInt(int64(n), 10) + u.unit
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour), 10) + "H"
+}
+
+// encodeGRPCMessage percent-encodes s for grpc-message, which is
+// printable ASCII.
+func encodeGRPCMessage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// decodeGRPCMessage undoes encodeGRPCMessage, keeping malformed escapes
+// as they are.
+func decodeGRPCMessage(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(n))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,74 @@
+package messagev1
+
+import (
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// FromMessage returns m as a Message. The zero CreatedAt is left unset.
+func FromMessage(m message.Message) *Message {
+	pm := &Message{
+		ID:       m.ID,
+		Sender:   m.Sender,
+		Severity: Severity(m.Severity),
+		Tags:     m.Tags,
+		Text:     m.Text,
+	}
+	if !m.CreatedAt.IsZero() {
+		pm.CreatedAt = NewTimestamp(m.CreatedAt)
+	}
+	return pm
+}
+
+// ToMessage returns m as a message.Message, with CreatedAt in UTC. A
+// severity this release does not know is kept, for Validate to reject.
+func (m *Message) ToMessage() message.Message {
+	return message.Message{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt.AsTime(),
+		Sender:    m.Sender,
+		Severity:  message.Severity(m.Severity),
+		Tags:      m.Tags,
+		Text:      m.Text,
+	}
+}
+
+// MarshalMessage returns m in the protobuf encoding of Message.
+func MarshalMessage(m message.Message) []byte {
+	return FromMessage(m).Marshal()
+}
+
+// UnmarshalMessage decodes data, a Message in the protobuf encoding.
+func UnmarshalMessage(data []byte) (message.Message, error) {
+	var pm Message
+	if err := pm.Unmarshal(data); err != nil {
+		return message.Message{}, err
+	}
+	return pm.ToMessage(), nil
+}
+
+// FromTokens returns toks, produced by the lexer named lang, as a
+// TokenStream.
+func FromTokens(lang string, toks []lexer.Token) *TokenStream {
+	s := &TokenStream{Lang: lang, Tokens: make([]*Token, len(toks))}
+	for i, t := range toks {
+		s.Tokens[i] = &Token{
+			Kind: TokenKind(t.Kind),
+			Text: t.Text,
+			Pos:  &Position{Offset: int64(t.Pos.Offset), Line: int32(t.Pos.Line), Column: int32(t.Pos.Column)},
+		}
+	}
+	return s
+}
+
+// ToTokens returns the tokens of s as the lexer's.
+func (s *TokenStream) ToTokens() []lexer.Token {
+	toks := make([]lexer.Token, len(s.Tokens))
+	for i, t := range s.Tokens {
+		toks[i] = lexer.Token{Kind: lexer.Kind(t.Kind), Text: t.Text}
+		if t.Pos != nil {
+			toks[i].Pos = lexer.Pos{Offset: int(t.Pos.Offset), Line: int(t.Pos.Line), Column: int(t.Pos.Column)}
+		}
+	}
+	return toks
+}
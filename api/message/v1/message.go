@@ -0,0 +1,297 @@
+// Package messagev1 holds message.proto, the wire schema of messages and
+// token streams, and its Go bindings. The bindings are written by hand
+// against the proto3 encoding rather than generated, so that the module
+// needs no protobuf runtime; a change to message.proto needs the same
+// change here. Messages encoded with them decode with any protobuf
+// implementation, and the other way around.
+//
+// FromMessage, FromTokens and their inverses convert between the bindings
+// and the greeter's own types.
+package messagev1
+
+import (
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/protowire"
+)
+
+// Severity is the Severity enum.
+type Severity int32
+
+const (
+	SeverityInfo    Severity = 0
+	SeverityNotice  Severity = 1
+	SeverityWarning Severity = 2
+	SeverityError   Severity = 3
+)
+
+// Timestamp is google.protobuf.Timestamp.
+type Timestamp struct {
+	Seconds int64
+	Nanos   int32
+}
+
+// NewTimestamp returns t as a Timestamp.
+func NewTimestamp(t time.Time) *Timestamp {
+	return &Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+}
+
+// AsTime returns t as a time.Time in UTC, or the zero time for nil.
+func (t *Timestamp) AsTime() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Unix(t.Seconds, int64(t.Nanos)).UTC()
+}
+
+func (t *Timestamp) appendTo(b []byte) []byte {
+	b = protowire.AppendVarint(b, 1, t.Seconds)
+	return protowire.AppendVarint(b, 2, int64(t.Nanos))
+}
+
+func (t *Timestamp) unmarshal(data []byte) error {
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v int64
+		switch num {
+		case 1:
+			v, err = d.Varint(typ)
+			t.Seconds = v
+		case 2:
+			v, err = d.Varint(typ)
+			t.Nanos = int32(v)
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Message is the Message message.
+type Message struct {
+	ID        string
+	CreatedAt *Timestamp
+	Sender    string
+	Severity  Severity
+	Tags      []string
+	Text      string
+}
+
+// Marshal returns m in the protobuf encoding.
+func (m *Message) Marshal() []byte {
+	return m.appendTo(nil)
+}
+
+func (m *Message) appendTo(b []byte) []byte {
+	b = protowire.AppendString(b, 1, m.ID)
+	if m.CreatedAt != nil {
+		b = protowire.AppendBytes(b, 2, m.CreatedAt.appendTo(nil))
+	}
+	b = protowire.AppendString(b, 3, m.Sender)
+	b = protowire.AppendVarint(b, 4, int64(m.Severity))
+	for _, tag := range m.Tags {
+		b = protowire.AppendBytes(b, 5, []byte(tag))
+	}
+	return protowire.AppendString(b, 6, m.Text)
+}
+
+// Unmarshal decodes data, a Message in the protobuf encoding, into m,
+// ignoring fields it does not know.
+func (m *Message) Unmarshal(data []byte) error {
+	*m = Message{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		var n int64
+		switch num {
+		case 1:
+			v, err = d.Bytes(typ)
+			m.ID = string(v)
+		case 2:
+			if v, err = d.Bytes(typ); err == nil {
+				m.CreatedAt = new(Timestamp)
+				err = m.CreatedAt.unmarshal(v)
+			}
+		case 3:
+			v, err = d.Bytes(typ)
+			m.Sender = string(v)
+		case 4:
+			n, err = d.Varint(typ)
+			m.Severity = Severity(n)
+		case 5:
+			if v, err = d.Bytes(typ); err == nil {
+				m.Tags = append(m.Tags, string(v))
+			}
+		case 6:
+			v, err = d.Bytes(typ)
+			m.Text = string(v)
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// TokenKind is the TokenKind enum.
+type TokenKind int32
+
+const (
+	TokenKindEOF      TokenKind = 0
+	TokenKindIllegal  TokenKind = 1
+	TokenKindKeyword  TokenKind = 2
+	TokenKindIdent    TokenKind = 3
+	TokenKindNumber   TokenKind = 4
+	TokenKindString   TokenKind = 5
+	TokenKindChar     TokenKind = 6
+	TokenKindComment  TokenKind = 7
+	TokenKindOperator TokenKind = 8
+)
+
+// Position is the Position message.
+type Position struct {
+	Offset int64
+	Line   int32
+	Column int32
+}
+
+func (p *Position) appendTo(b []byte) []byte {
+	b = protowire.AppendVarint(b, 1, p.Offset)
+	b = protowire.AppendVarint(b, 2, int64(p.Line))
+	return protowire.AppendVarint(b, 3, int64(p.Column))
+}
+
+func (p *Position) unmarshal(data []byte) error {
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v int64
+		switch num {
+		case 1:
+			v, err = d.Varint(typ)
+			p.Offset = v
+		case 2:
+			v, err = d.Varint(typ)
+			p.Line = int32(v)
+		case 3:
+			v, err = d.Varint(typ)
+			p.Column = int32(v)
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Token is the Token message.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  *Position
+}
+
+// Marshal returns t in the protobuf encoding.
+func (t *Token) Marshal() []byte {
+	return t.appendTo(nil)
+}
+
+func (t *Token) appendTo(b []byte) []byte {
+	b = protowire.AppendVarint(b, 1, int64(t.Kind))
+	b = protowire.AppendString(b, 2, t.Text)
+	if t.Pos != nil {
+		b = protowire.AppendBytes(b, 3, t.Pos.appendTo(nil))
+	}
+	return b
+}
+
+// Unmarshal decodes data, a Token in the protobuf encoding, into t.
+func (t *Token) Unmarshal(data []byte) error {
+	*t = Token{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		var n int64
+		switch num {
+		case 1:
+			n, err = d.Varint(typ)
+			t.Kind = TokenKind(n)
+		case 2:
+			v, err = d.Bytes(typ)
+			t.Text = string(v)
+		case 3:
+			if v, err = d.Bytes(typ); err == nil {
+				t.Pos = new(Position)
+				err = t.Pos.unmarshal(v)
+			}
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// TokenStream is the TokenStream message.
+type TokenStream struct {
+	Lang   string
+	Tokens []*Token
+}
+
+// Marshal returns s in the protobuf encoding.
+func (s *TokenStream) Marshal() []byte {
+	b := protowire.AppendString(nil, 1, s.Lang)
+	for _, t := range s.Tokens {
+		b = protowire.AppendBytes(b, 2, t.appendTo(nil))
+	}
+	return b
+}
+
+// Unmarshal decodes data, a TokenStream in the protobuf encoding, into s.
+func (s *TokenStream) Unmarshal(data []byte) error {
+	*s = TokenStream{}
+	d := protowire.NewDecoder(data)
+	for {
+		num, typ, ok, err := d.Next()
+		if !ok || err != nil {
+			return err
+		}
+		var v []byte
+		switch num {
+		case 1:
+			v, err = d.Bytes(typ)
+			s.Lang = string(v)
+		case 2:
+			if v, err = d.Bytes(typ); err == nil {
+				t := new(Token)
+				s.Tokens = append(s.Tokens, t)
+				err = t.Unmarshal(v)
+			}
+		default:
+			err = d.Skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Command greeter-lexer-ini is a lexer plugin for INI files, and an example
+// of writing one. Installed on the PATH, it lets greeter highlight them:
+//
+//	go install ./cmd/greeter-lexer-ini
+//	greeter highlight --lexer ini config.ini
+//
+// It speaks the protocol of lexers.Serve on its standard input and output.
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("greeter-lexer-ini: ")
+	if err := lexers.Serve(os.Stdin, os.Stdout, "ini", []string{".ini", ".cfg"}, tokenize); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// tokenize splits INI source into comments, [section] headers, keys, the =
+// or : after them, and values.
+func tokenize(src []byte) []lexer.Token {
+	var toks []lexer.Token
+	add := func(kind lexer.Kind, start, end, line, lineStart int) {
+		if end > start {
+			toks = append(toks, lexer.Token{Kind: kind, Text: string(src[start:end]), Pos: lexer.Pos{Offset: start, Line: line, Column: start - lineStart + 1}})
+		}
+	}
+	off := 0
+	for line := 1; off < len(src); line++ {
+		end := len(src)
+		if i := bytes.IndexByte(src[off:], '\n'); i >= 0 {
+			end = off + i
+		}
+		start := off + len(src[off:end]) - len(bytes.TrimLeft(src[off:end], " \t"))
+		stop := start + len(bytes.TrimRight(src[start:end], " \t\r"))
+		switch {
+		case start == stop:
+		case src[start] == ';' || src[start] == '#':
+			add(lexer.Comment, start, stop, line, off)
+		case src[start] == '[':
+			rb := bytes.IndexByte(src[start:stop], ']')
+			if rb < 0 {
+				add(lexer.Illegal, start, stop, line, off)
+				break
+			}
+			rb += start
+			add(lexer.Operator, start, start+1, line, off)
+			add(lexer.Keyword, start+1, rb, line, off)
+			add(lexer.Operator, rb, rb+1, line, off)
+			// Only a comment may follow the header.
+			rest := rb + 1 + len(src[rb+1:stop]) - len(bytes.TrimLeft(src[rb+1:stop], " \t"))
+			if rest < stop && (src[rest] == ';' || src[rest] == '#') {
+				add(lexer.Comment, rest, stop, line, off)
+			} else {
+				add(lexer.Illegal, rest, stop, line, off)
+			}
+		default:
+			sep := bytes.IndexAny(src[start:stop], "=:")
+			if sep < 0 {
+				add(lexer.Ident, start, stop, line, off)
+				break
+			}
+			sep += start
+			add(lexer.Ident, start, start+len(bytes.TrimRight(src[start:sep], " \t")), line, off)
+			add(lexer.Operator, sep, sep+1, line, off)
+			value := sep + 1 + len(src[sep+1:stop]) - len(bytes.TrimLeft(src[sep+1:stop], " \t"))
+			kind := lexer.String
+			if isNumber(src[value:stop]) {
+				kind = lexer.Number
+			}
+			add(kind, value, stop, line, off)
+		}
+		off = end + 1
+	}
+	return toks
+}
+
+func isNumber(b []byte) bool {
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if (c < '0' || c > '9') && c != '.' {
+			return false
+		}
+	}
+	return true
+}
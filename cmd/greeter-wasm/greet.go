@@ -0,0 +1,51 @@
+//go:build js && wasm && !nogreet
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+func init() {
+	exports["greet"] = greet
+}
+
+// greet greets the name args[0] and returns the message as JSON. args[1],
+// if an object, may set the greeting's style, lang and template, as the
+// greet command's flags do.
+func greet(args []js.Value) (string, error) {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return "", errors.New("greet: want a name")
+	}
+	var opts js.Value
+	if len(args) > 1 {
+		opts = args[1]
+	}
+	ctx := context.Background()
+	if lang := stringOption(opts, "lang"); lang != "" {
+		ctx = greeting.ContextWithLocale(ctx, lang)
+	}
+	tmpl := stringOption(opts, "template")
+	var g greeting.Greeter = greeting.TemplateGreeter{Template: tmpl}
+	if tmpl == "" {
+		style := stringOption(opts, "style")
+		if style == "" {
+			style = "default"
+		}
+		var err error
+		if g, err = greeting.Lookup(style); err != nil {
+			return "", err
+		}
+	}
+	m, err := greeting.Chain(g, greeting.Validating).Greet(ctx, args[0].String())
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(m)
+	return string(data), err
+}
@@ -0,0 +1,43 @@
+// greeter.js loads greeter.wasm, the browser build of cmd/greeter-wasm,
+// and wraps the functions it exports. It needs the Go class of
+// wasm_exec.js, from the Go distribution, loaded first:
+//
+//	<script src="wasm_exec.js"></script>
+//	<script type="module">
+//	  import { loadGreeter } from "./greeter.js";
+//	  const greeter = await loadGreeter("greeter.wasm");
+//	  greeter.greet("Ann", { style: "formal", lang: "fr" }).text;
+//	  greeter.highlight("package main", "go", "dark");
+//	</script>
+//
+// Errors are thrown as Error objects.
+
+export async function loadGreeter(url = "greeter.wasm") {
+  const go = new Go();
+  const { instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  // The module sets greeterWasm before it waits for calls, which is when
+  // run gives control back.
+  go.run(instance);
+  const api = globalThis.greeterWasm;
+  delete globalThis.greeterWasm;
+
+  const call = (name, ...args) => {
+    if (!api[name]) {
+      throw new Error(`greeter: ${name} is not in this build`);
+    }
+    const { value, error } = api[name](...args);
+    if (error !== undefined) {
+      throw new Error(error);
+    }
+    return value;
+  };
+
+  return {
+    // greet greets name and returns the message, with its text, id and
+    // tags. opts may set the style, lang and template of the greeting.
+    greet: (name, opts = {}) => JSON.parse(call("greet", name, opts)),
+    // highlight returns code, in lang, as HTML styled inline with the
+    // bundled theme.
+    highlight: (code, lang = "go", theme = "light") => call("highlight", code, lang, theme),
+  };
+}
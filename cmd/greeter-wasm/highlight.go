@@ -0,0 +1,38 @@
+//go:build js && wasm && !nohighlight
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall/js"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/htmlrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+func init() {
+	exports["highlight"] = highlight
+}
+
+// highlight highlights the source args[0] in the language args[1], go by
+// default and the only one built in, with the bundled theme args[2], light
+// by default. It returns the HTML with each token styled inline, so that
+// pages need no stylesheet.
+func highlight(args []js.Value) (string, error) {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return "", errors.New("highlight: want source code")
+	}
+	if lang := stringArg(args, 1, "go"); lang != "go" {
+		return "", fmt.Errorf("no lexer for %q: the browser build highlights go only", lang)
+	}
+	name := stringArg(args, 2, "light")
+	th, ok := theme.Builtin(name)
+	if !ok {
+		return "", fmt.Errorf("unknown theme %q: want %s", name, strings.Join(theme.Names(), ", "))
+	}
+	var b strings.Builder
+	err := htmlrender.Render(&b, []byte(args[0].String()), htmlrender.Options{Theme: th, InlineStyles: true})
+	return b.String(), err
+}
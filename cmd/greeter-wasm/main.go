@@ -0,0 +1,58 @@
+//go:build js && wasm
+
+// Command greeter-wasm is the greeting and highlighting of greeter built
+// for browsers, as WebAssembly. It sets a greeterWasm global of the
+// functions it exports, which greeter.js wraps:
+//
+//	GOOS=js GOARCH=wasm go build -trimpath -ldflags="-s -w" -o greeter.wasm ./cmd/greeter-wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// Builds that need only one of them are smaller without the other: the
+// nohighlight tag leaves highlight out, and nogreet greet.
+//
+// Each function returns an object with the result as value, or with an
+// error message as error, which greeter.js throws.
+package main
+
+import "syscall/js"
+
+// exports are the functions of greeterWasm, added by the files of the
+// parts the build includes.
+var exports = make(map[string]func(args []js.Value) (string, error))
+
+func main() {
+	api := js.Global().Get("Object").New()
+	for name, f := range exports {
+		api.Set(name, js.FuncOf(func(this js.Value, args []js.Value) any {
+			v, err := f(args)
+			if err != nil {
+				return map[string]any{"error": err.Error()}
+			}
+			return map[string]any{"value": v}
+		}))
+	}
+	js.Global().Set("greeterWasm", api)
+	// The functions are called for as long as the page is open.
+	select {}
+}
+
+// stringArg returns the i-th of args if it is a string, or def if it is
+// missing, undefined or null.
+func stringArg(args []js.Value, i int, def string) string {
+	if i >= len(args) || args[i].IsUndefined() || args[i].IsNull() {
+		return def
+	}
+	return args[i].String()
+}
+
+// stringOption returns the key property of opts if it is set, or "".
+func stringOption(opts js.Value, key string) string {
+	if opts.Type() != js.TypeObject {
+		return ""
+	}
+	v := opts.Get(key)
+	if v.IsUndefined() || v.IsNull() {
+		return ""
+	}
+	return v.String()
+}
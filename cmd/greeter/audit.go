@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/audit"
+)
+
+var auditCommand = &command{
+	name: "audit",
+	args: "list | verify",
+	summary: "Show who created, modified, delivered and deleted messages, from the audit log commands and serve keep in $GREETER_AUDIT_LOG, " +
+		"or check that the log was not changed after it was written.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		file := fs.String("log", "", "read the audit log in `file` (default $GREETER_AUDIT_LOG)")
+		id := fs.String("message", "", "only entries for the message with `id`")
+		actor := fs.String("actor", "", "only entries by `actor`, an API key or JWT subject, anonymous, schedule:ID or user:NAME")
+		action := fs.String("action", "", "only entries for `action`: create, modify, deliver or delete")
+		request := fs.String("request", "", "only entries for the HTTP request with X-Request-ID `id`")
+		since := fs.String("since", "", "only entries made after `time`, as RFC 3339, a date or a duration ago such as 24h")
+		until := fs.String("until", "", "only entries made before `time`, like --since")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "list" && args[0] != "verify" {
+				return usagef("expected list or verify")
+			}
+			if err := c.parseTrailingFlags(fs, args[1:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if *file == "" {
+				*file = os.Getenv("GREETER_AUDIT_LOG")
+			}
+			if *file == "" {
+				return usagef("--log or $GREETER_AUDIT_LOG is required")
+			}
+			if _, err := os.Stat(*file); err != nil {
+				return err
+			}
+			log, err := audit.Open(*file)
+			if err != nil {
+				return err
+			}
+			if args[0] == "verify" {
+				n, err := log.Verify()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(c.stdout, "%d entries verified\n", n)
+				return nil
+			}
+			f := audit.Filter{MessageID: *id, Actor: *actor, Action: audit.Action(*action), RequestID: *request}
+			switch f.Action {
+			case "", audit.Create, audit.Modify, audit.Deliver, audit.Delete:
+			default:
+				return usagef("unknown action %q: want create, modify, deliver or delete", *action)
+			}
+			now := time.Now()
+			if f.Since, err = parseTimeFlag(*since, now); err != nil {
+				return usagef("--since: %v", err)
+			}
+			if f.Until, err = parseTimeFlag(*until, now); err != nil {
+				return usagef("--until: %v", err)
+			}
+			entries, err := log.Entries(f)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				fmt.Fprintf(c.stdout, "%d  %s  %-7s  %s  %s", e.Seq, e.Time.Format(time.RFC3339), e.Action, e.MessageID, e.Actor)
+				if e.RequestID != "" {
+					fmt.Fprintf(c.stdout, "  request %s", e.RequestID)
+				}
+				if e.Target != "" {
+					fmt.Fprintf(c.stdout, "  %s  %d attempts", e.Target, e.Attempts)
+				}
+				fmt.Fprintln(c.stdout)
+				if e.Error != "" {
+					fmt.Fprintf(c.stdout, "    %s\n", e.Error)
+				}
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/detect"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/htmlrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/imagerender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/latexrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/perf"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/termrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+var benchCommand = &command{
+	name:    "bench",
+	args:    "[file ...]",
+	summary: "Benchmark tokenizing and rendering the highlighting corpus, the test.* files by default, and formatting greetings, record the results as JSON, and compare them with a baseline.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		out := fs.String("out", "bench.json", "write the results to `file`, or nowhere if empty")
+		baseline := fs.String("baseline", "", "compare with the results in `file`, failing on regressions")
+		maxSlowdown := fs.Float64("max-slowdown", 10, "with --baseline, the `percent` more ns/op allowed before a benchmark has regressed")
+		maxAllocs := fs.Float64("max-alloc-increase", 1, "with --baseline, the `percent` more allocs/op allowed before a benchmark has regressed")
+		benchtime := fs.Duration("benchtime", time.Second, "run each benchmark for at least `duration`")
+		run := fs.String("run", "", "run only the benchmarks whose names match `regexp`")
+		return func(ctx context.Context, args []string) error {
+			files, err := c.parseTrailingArgs(fs, args)
+			if err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if *benchtime <= 0 {
+				return usagef("--benchtime must be positive")
+			}
+			filter, err := regexp.Compile(*run)
+			if err != nil {
+				return usagef("--run: %v", err)
+			}
+			if len(files) == 0 {
+				if files, err = filepath.Glob("test.*"); err != nil {
+					return err
+				}
+				if len(files) == 0 {
+					return usagef("no test.* files here: name the corpus files")
+				}
+			}
+			var base perf.Results
+			if *baseline != "" {
+				if base, err = perf.Load(*baseline); err != nil {
+					return err
+				}
+			}
+			all, err := corpusBenchmarks(files)
+			if err != nil {
+				return err
+			}
+			all = append(all, greetingBenchmarks()...)
+			var benchmarks []perf.Benchmark
+			for _, b := range all {
+				if filter.MatchString(b.Name) {
+					benchmarks = append(benchmarks, b)
+				}
+			}
+			if len(benchmarks) == 0 {
+				return usagef("no benchmarks match %q", *run)
+			}
+			results := perf.Run(benchmarks, *benchtime)
+			if *out != "" {
+				if err := perf.Save(*out, results); err != nil {
+					return err
+				}
+			}
+			comparisons := perf.Compare(base, results, perf.Thresholds{Time: *maxSlowdown / 100, Allocs: *maxAllocs / 100})
+			regressed := 0
+			for _, cmp := range comparisons {
+				r := cmp.Current
+				fmt.Fprintf(c.stdout, "%-16s %12d ns/op %8d allocs/op %10d B/op", r.Name, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp)
+				if r.MBPerSec > 0 {
+					fmt.Fprintf(c.stdout, " %8.2f MB/s", r.MBPerSec)
+				}
+				switch {
+				case *baseline == "":
+				case cmp.Baseline == nil:
+					fmt.Fprint(c.stdout, "  (new)")
+				default:
+					fmt.Fprintf(c.stdout, "  %+.1f%% time, %+.1f%% allocs", 100*cmp.TimeChange, 100*cmp.AllocsChange)
+					if len(cmp.Regressed) > 0 {
+						regressed++
+						fmt.Fprintf(c.stdout, "  REGRESSED: %s", strings.Join(cmp.Regressed, ", "))
+					}
+				}
+				fmt.Fprintln(c.stdout)
+			}
+			if regressed > 0 {
+				return fmt.Errorf("%d of %d benchmarks regressed against %s", regressed, len(comparisons), *baseline)
+			}
+			return nil
+		}
+	},
+}
+
+// corpusBenchmarks reads the files named and returns a benchmark of each
+// stage of highlighting over all of them.
+func corpusBenchmarks(files []string) ([]perf.Benchmark, error) {
+	type file struct {
+		name string
+		src  []byte
+		toks []lexer.Token
+	}
+	var corpus []file
+	var highlighters []*lexer.Highlighter
+	var size int64
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, file{name, src, lexer.Tokenize(src)})
+		highlighters = append(highlighters, lexer.NewHighlighter(src))
+		size += int64(len(src))
+	}
+	each := func(f func(file)) func() {
+		return func() {
+			for _, file := range corpus {
+				f(file)
+			}
+		}
+	}
+	render := func(r func(io.Writer, []byte, []lexer.Token) error) func() {
+		return each(func(f file) { r(io.Discard, f.src, f.toks) })
+	}
+	return []perf.Benchmark{
+		{Name: "tokenize", Bytes: size, Run: each(func(f file) { lexer.Tokenize(f.src) })},
+		{Name: "tokenize-reader", Bytes: size, Run: each(func(f file) {
+			lexer.TokenizeReader(bytes.NewReader(f.src), func(lexer.Token) error { return nil })
+		})},
+		{Name: "edit", Run: func() {
+			// Type a character in the middle of each file and delete it.
+			for _, h := range highlighters {
+				mid := len(h.Source()) / 2
+				h.Edit(mid, 0, []byte("x"))
+				h.Edit(mid, 1, nil)
+			}
+		}},
+		{Name: "detect", Run: each(func(f file) { detect.Language(f.name, f.src) })},
+		{Name: "render-ansi", Bytes: size, Run: render(func(w io.Writer, src []byte, toks []lexer.Token) error {
+			return termrender.RenderTokens(w, src, toks, termrender.Options{Depth: termrender.DepthTrueColor, Theme: theme.Dark})
+		})},
+		{Name: "render-html", Bytes: size, Run: render(func(w io.Writer, src []byte, toks []lexer.Token) error {
+			return htmlrender.RenderTokens(w, src, toks, htmlrender.Options{})
+		})},
+		{Name: "render-latex", Bytes: size, Run: render(func(w io.Writer, src []byte, toks []lexer.Token) error {
+			return latexrender.RenderTokens(w, src, toks, latexrender.Options{})
+		})},
+		{Name: "render-svg", Bytes: size, Run: render(func(w io.Writer, src []byte, toks []lexer.Token) error {
+			return imagerender.RenderSVGTokens(w, src, toks, imagerender.Options{})
+		})},
+	}, nil
+}
+
+// greetingBenchmarks returns benchmarks of formatting a greeting, as the
+// server does for every request. append-greeting reuses its buffer, and so
+// should not allocate at all.
+func greetingBenchmarks() []perf.Benchmark {
+	buf := make([]byte, 0, 64)
+	return []perf.Benchmark{
+		{Name: "greet", Run: func() { greeting.Greet("World") }},
+		{Name: "append-greeting", Run: func() { buf = greeting.AppendGreeting(buf[:0], "World") }},
+	}
+}
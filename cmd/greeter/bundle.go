@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+// bundlePoll is how often serve --bundle-dir checks its directory for
+// changed translations.
+const bundlePoll = time.Second
+
+// bundleWatcher keeps the default translations those of a bundle
+// directory, of <locale>.json files over the bundled ones, reloading them
+// when the files change. A bundle that does not load or validate is
+// rejected, leaving the last good one in place.
+type bundleWatcher struct {
+	dir string
+
+	mu    sync.Mutex
+	stamp string
+}
+
+// load loads the bundle if its files changed since the last load, or
+// regardless if force is set, and reports whether it did.
+func (w *bundleWatcher) load(force bool) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stamp, err := bundleStamp(w.dir)
+	if err != nil {
+		return false, err
+	}
+	if stamp == w.stamp && !force {
+		return false, nil
+	}
+	// A bundle caught half written is rejected, and loaded once its files
+	// change again.
+	w.stamp = stamp
+	l, err := greeting.LoadBundle(os.DirFS(w.dir), ".")
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", w.dir, err)
+	}
+	greeting.SetDefaultLocalizer(l)
+	return true, nil
+}
+
+// run reloads the bundle whenever it changes, checking every interval
+// until ctx is done, and calls reloaded after each reload.
+func (w *bundleWatcher) run(ctx context.Context, interval time.Duration, reloaded func()) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		ok, err := w.load(false)
+		if err != nil {
+			slog.Error("translation bundle rejected", "error", err)
+			continue
+		}
+		if ok {
+			slog.Info("translation bundle reloaded", "dir", w.dir)
+			reloaded()
+		}
+	}
+}
+
+// bundleStamp fingerprints the translation files in dir by their names,
+// sizes and modification times.
+func bundleStamp(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", e.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/clipboard"
+)
+
+// copyToClipboard puts data on the clipboard as mediaType, for --copy.
+func copyToClipboard(ctx context.Context, data []byte, mediaType string) error {
+	how, err := clipboard.Copy(ctx, data, mediaType)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "copied to clipboard", "bytes", len(data), "via", how)
+	return nil
+}
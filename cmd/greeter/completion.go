@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/samples"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+var completionShells = []string{"bash", "fish", "zsh"}
+
+var completionCommand = &command{
+	name:    "completion",
+	args:    "<bash|zsh|fish>",
+	summary: "Print a shell completion script.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return usagef("expected exactly one shell")
+			}
+			switch args[0] {
+			case "bash":
+				return writeBashCompletion(c.stdout)
+			case "zsh":
+				return writeZshCompletion(c.stdout)
+			case "fish":
+				return writeFishCompletion(c.stdout)
+			}
+			return usagef("unsupported shell %q: want bash, zsh or fish", args[0])
+		}
+	},
+}
+
+// flagValues lists the values a flag accepts, for flags with a fixed or
+// discoverable set.
+func flagValues(name string) []string {
+	switch name {
+	case "lang":
+		return greeting.Locales()
+	case "style":
+		return greeting.Greeters()
+	case "color":
+		return []string{"auto", "always", "never"}
+	case "output":
+		return []string{"text", "json", "jsonl", "yaml"}
+	case "log-format":
+		return []string{"text", "json"}
+	case "severity":
+		return []string{"info", "notice", "warning", "error"}
+	case "order":
+		return []string{"newest", "oldest"}
+	case "language":
+		return samples.Languages()
+	case "theme":
+		return theme.Names()
+	case "color-depth":
+		return []string{"auto", "16", "256", "truecolor"}
+	case "engine-a", "engine-b":
+		return []string{"builtin", "textmate:"}
+	case "catch-up":
+		return []string{"skip", "once", "all"}
+	case "action":
+		return []string{"create", "modify", "deliver", "delete"}
+	}
+	return nil
+}
+
+// argValues lists the values a command takes as positional arguments.
+func argValues(cmd *command) []string {
+	switch cmd.name {
+	case "help", "profile":
+		return commandNames()
+	case "completion":
+		return completionShells
+	case "messages":
+		return []string{"list"}
+	case "samples":
+		return []string{"generate", "unicode"}
+	case "goldens":
+		return []string{"update", "verify"}
+	case "corpus":
+		return []string{"add", "list", "validate"}
+	case "dead-letters":
+		return []string{"list", "requeue", "rm"}
+	case "schedule":
+		return []string{"list", "rm", "run"}
+	case "keys":
+		return []string{"generate", "rotate"}
+	case "docs":
+		return []string{"man", "markdown"}
+	case "audit":
+		return []string{"list", "verify"}
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+type flagInfo struct {
+	name, usage string
+	// kind names the flag's value, as in its usage, and def is its
+	// default, empty for the zero value.
+	kind, def string
+	isBool    bool
+	isFile    bool
+	values    []string
+}
+
+func commandFlags(cmd *command) []flagInfo {
+	fs, _ := (&cli{stderr: io.Discard}).newFlagSet(cmd)
+	var flags []flagInfo
+	fs.VisitAll(func(f *flag.Flag) {
+		kind, usage := flag.UnquoteUsage(f)
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		def := f.DefValue
+		if def == "false" || def == "0" || def == "0s" {
+			def = ""
+		}
+		flags = append(flags, flagInfo{
+			name:   f.Name,
+			usage:  usage,
+			kind:   kind,
+			def:    def,
+			isBool: ok && b.IsBoolFlag(),
+			isFile: kind == "file" || kind == "dir",
+			values: flagValues(f.Name),
+		})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+func writeBashCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# bash completion for greeter\n_greeter() {\n")
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(&b, "    if [ \"$COMP_CWORD\" -eq 1 ]; then\n        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        return\n    fi\n", strings.Join(commandNames(), " "))
+	b.WriteString("    case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "    %s)\n        case \"$prev\" in\n", cmd.name)
+		var words []string
+		for _, f := range commandFlags(cmd) {
+			words = append(words, "--"+f.name)
+			switch {
+			case f.isFile:
+				fmt.Fprintf(&b, "        -%[1]s|--%[1]s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.name)
+			case len(f.values) > 0:
+				fmt.Fprintf(&b, "        -%[1]s|--%[1]s) COMPREPLY=($(compgen -W %[2]q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.values, " "))
+			case !f.isBool:
+				fmt.Fprintf(&b, "        -%[1]s|--%[1]s) return ;;\n", f.name)
+			}
+		}
+		b.WriteString("        esac\n")
+		words = append(words, argValues(cmd)...)
+		fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(words, " "))
+	}
+	b.WriteString("    esac\n}\ncomplete -F _greeter greeter\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("#compdef greeter\n\n_greeter() {\n    local -a commands\n    commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        %s\n", zshQuote(cmd.name+":"+strings.ReplaceAll(cmd.summary, ":", `\:`)))
+	}
+	b.WriteString("    )\n    if (( CURRENT == 2 )); then\n        _describe 'command' commands\n        return\n    fi\n")
+	b.WriteString("    case $words[2] in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "    %s)\n        _arguments \\\n", cmd.name)
+		for _, f := range commandFlags(cmd) {
+			spec := "--" + f.name + "[" + zshEscape(f.usage) + "]"
+			switch {
+			case f.isBool:
+			case f.isFile:
+				spec += ":" + f.name + ":_files"
+			case len(f.values) > 0:
+				spec += ":" + f.name + ":(" + strings.Join(f.values, " ") + ")"
+			default:
+				spec += ":" + f.name + ": "
+			}
+			fmt.Fprintf(&b, "            %s \\\n", zshQuote(spec))
+		}
+		if values := argValues(cmd); len(values) > 0 {
+			fmt.Fprintf(&b, "            %s\n        ;;\n", zshQuote("*:argument:("+strings.Join(values, " ")+")"))
+		} else {
+			b.WriteString("            '*:argument: '\n        ;;\n")
+		}
+	}
+	b.WriteString("    esac\n}\n\ncompdef _greeter greeter\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for greeter\ncomplete -c greeter -f\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c greeter -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	for _, cmd := range commands {
+		cond := fishQuote("__fish_seen_subcommand_from " + cmd.name)
+		for _, f := range commandFlags(cmd) {
+			line := fmt.Sprintf("complete -c greeter -n %s -l %s -d %s", cond, f.name, fishQuote(f.usage))
+			switch {
+			case f.isBool:
+			case f.isFile:
+				line += " -r -F"
+			case len(f.values) > 0:
+				line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+			default:
+				line += " -x"
+			}
+			b.WriteString(line + "\n")
+		}
+		if values := argValues(cmd); len(values) > 0 {
+			fmt.Fprintf(&b, "complete -c greeter -n %s -a %s\n", cond, fishQuote(strings.Join(values, " ")))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configKeys maps config file keys, which are also the GREETER_<KEY>
+// environment variables, to the flags they provide defaults for.
+var configKeys = map[string]string{
+	"language":       "lang",
+	"style":          "style",
+	"output":         "output",
+	"color":          "color",
+	"log_format":     "log-format",
+	"webhook_secret": "webhook-secret",
+	"smtp":           "smtp",
+	"mail_from":      "mail-from",
+	"api_keys":       "api-keys",
+	"jwks_url":       "jwks-url",
+	"authz":          "authz",
+	"db":             "db",
+	"cache":          "cache",
+	"color_depth":    "color-depth",
+	"theme":          "theme",
+	"tenant":         "tenant",
+	"tenants":        "tenants",
+	"a11y":           "a11y",
+}
+
+func configPath() string {
+	if path := os.Getenv("GREETER_CONFIG"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "greeter", "config.yaml")
+}
+
+// loadConfig merges the config file and GREETER_* environment variables,
+// the latter taking precedence.
+func loadConfig() (map[string]string, error) {
+	values := make(map[string]string)
+	if path := configPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err := parseConfig(data, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for key := range configKeys {
+		if value, ok := os.LookupEnv("GREETER_" + strings.ToUpper(key)); ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// parseConfig reads the flat "key: value" subset of YAML the config file
+// uses.
+func parseConfig(data []byte, values map[string]string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected key: value", n)
+		}
+		key = strings.TrimSpace(key)
+		if _, known := configKeys[key]; !known {
+			return fmt.Errorf("line %d: unknown key %q", n, key)
+		}
+		value, err := configValue(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		values[key] = value
+	}
+	return scanner.Err()
+}
+
+func configValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		quoted, err := strconv.QuotedPrefix(raw)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Unquote(quoted)
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(strings.ReplaceAll(raw[1:], "''", "  "), "'")
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		return strings.ReplaceAll(raw[1:end+1], "''", "'"), nil
+	}
+	value, _, _ := strings.Cut(raw, " #")
+	return strings.TrimSpace(value), nil
+}
+
+// applyConfig fills in the flags fs defines but that were not given on the
+// command line, so the precedence is flags, environment, file, defaults.
+func applyConfig(fs *flag.FlagSet, values map[string]string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for key, value := range values {
+		name := configKeys[key]
+		if explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/corpus"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/detect"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+)
+
+var corpusCommand = &command{
+	name:    "corpus",
+	args:    "add|list|validate [file ...]",
+	summary: "Keep the manifest of the highlighting corpus: add files to it with their language, tags and token counts, list them, or check the corpus against it.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		manifest := fs.String("manifest", corpus.DefaultManifest, "keep the manifest in `file`; entries are relative to its directory")
+		language := fs.String("language", "", "with add, the files' `language` (default detected); with list, list only this language")
+		tags := fs.String("tag", "", "with add, tag the files with these comma-separated `tags`; with list, list only files with all of them")
+		knownBad := fs.Bool("known-bad", false, "with add, mark the files as known to highlight wrongly")
+		note := fs.String("note", "", "with add, note `text` on the files, such as why they are known bad")
+		replace := fs.Bool("replace", false, "with add, replace entries already in the manifest")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "add" && args[0] != "list" && args[0] != "validate" {
+				return usagef("expected add, list or validate")
+			}
+			files, err := c.parseTrailingArgs(fs, args[1:])
+			if err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			m, err := corpus.Load(*manifest)
+			if err != nil {
+				return err
+			}
+			dir := filepath.Dir(*manifest)
+			var tagList []string
+			if *tags != "" {
+				tagList = strings.Split(*tags, ",")
+			}
+			switch args[0] {
+			case "add":
+				if len(files) == 0 {
+					return usagef("name the files to add")
+				}
+				tokenize := corpusTokenizer()
+				defer tokenize.close()
+				for _, name := range files {
+					e, err := corpusEntry(dir, name, *language, tokenize.tokenize)
+					if err != nil {
+						return err
+					}
+					e.Tags, e.KnownBad, e.Note = tagList, *knownBad, *note
+					if err := m.Add(e, *replace); err != nil {
+						return usagef("%v", err)
+					}
+					fmt.Fprintf(c.stdout, "%s: %s, %d tokens\n", e.Path, e.Language, e.Tokens)
+				}
+				return m.Save(*manifest)
+			case "list":
+				if len(files) > 0 {
+					return usagef("list takes no files")
+				}
+				for _, e := range m.Select(*language, tagList) {
+					fmt.Fprintf(c.stdout, "%-20s %-12s %6d  %s", e.Path, e.Language, e.Tokens, strings.Join(e.Tags, ","))
+					if e.KnownBad {
+						fmt.Fprint(c.stdout, "  (known bad)")
+					}
+					if e.Note != "" {
+						fmt.Fprintf(c.stdout, "  %s", e.Note)
+					}
+					fmt.Fprintln(c.stdout)
+				}
+				return nil
+			}
+			if len(files) > 0 {
+				return usagef("validate takes no files")
+			}
+			tokenize := corpusTokenizer()
+			defer tokenize.close()
+			problems, err := corpus.Validate(m, dir, tokenize.tokenize, "test.*")
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, p := range problems {
+				if p.Expected {
+					fmt.Fprintf(c.stdout, "%s: %v (known bad)\n", p.Path, p.Err)
+					continue
+				}
+				failed++
+				fmt.Fprintf(c.stdout, "%s: %v\n", p.Path, p.Err)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d problems with the corpus in %s", failed, *manifest)
+			}
+			return nil
+		}
+	},
+}
+
+// corpusEntry returns the manifest entry for the file name, relative to
+// dir, with its language detected if language is empty and its token count
+// if there is a lexer for it.
+func corpusEntry(dir, name, language string, tokenize corpus.Tokenizer) (corpus.Entry, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return corpus.Entry{}, err
+	}
+	rel, err := filepath.Rel(dir, name)
+	if err != nil {
+		return corpus.Entry{}, err
+	}
+	if language == "" {
+		language = detect.Language(name, src).Name
+	}
+	e := corpus.Entry{Path: filepath.ToSlash(rel), Language: language}
+	toks, ok, err := tokenize(language, src)
+	if err != nil {
+		return corpus.Entry{}, fmt.Errorf("%s: %w", name, err)
+	}
+	if ok {
+		e.Tokens = len(toks)
+	}
+	return e, nil
+}
+
+// lexerCache opens the lexer for each language once, for the tokenizer of
+// the corpus.
+type lexerCache struct {
+	lexers map[string]lexers.Lexer
+	stops  []func()
+}
+
+func corpusTokenizer() *lexerCache {
+	return &lexerCache{lexers: make(map[string]lexers.Lexer)}
+}
+
+func (lc *lexerCache) tokenize(language string, src []byte) ([]lexer.Token, bool, error) {
+	l, ok := lc.lexers[language]
+	if !ok {
+		var stop func()
+		var err error
+		if l, stop, err = openLexer(language); err == nil {
+			lc.stops = append(lc.stops, stop)
+		}
+		// A language with no lexer is remembered as nil, so that the PATH
+		// is not searched again.
+		lc.lexers[language] = l
+	}
+	if l == nil {
+		return nil, false, nil
+	}
+	toks, err := l.Tokenize(src)
+	return toks, true, err
+}
+
+func (lc *lexerCache) close() {
+	for _, stop := range lc.stops {
+		stop()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/corpus"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/coverage"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/detect"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/textmate"
+)
+
+var coverageCommand = &command{
+	name:    "coverage",
+	args:    "[file ...]",
+	summary: "Report, per language, how many tokens of each kind the highlighting corpus, the test.* files by default, exercises, and which kinds it never reaches.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		format := fs.String("format", "text", "report format: text, json or html")
+		out := fs.String("out", "", "write the report to `file` instead of standard output")
+		manifest := fs.String("manifest", corpus.DefaultManifest, "take the languages of the files it lists from the corpus manifest in `file`")
+		grammars := fs.String("grammars", "", "tokenize files with the .tmLanguage.json grammars in `dir`, by their fileTypes, before the lexers")
+		return func(ctx context.Context, args []string) error {
+			files, err := c.parseTrailingArgs(fs, args)
+			if err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			var write func(*coverage.Report, io.Writer) error
+			switch *format {
+			case "text":
+				write = (*coverage.Report).WriteText
+			case "json":
+				write = (*coverage.Report).WriteJSON
+			case "html":
+				write = (*coverage.Report).WriteHTML
+			default:
+				return usagef("--format must be text, json or html")
+			}
+			if len(files) == 0 {
+				if files, err = filepath.Glob("test.*"); err != nil {
+					return err
+				}
+				if len(files) == 0 {
+					return usagef("no test.* files here: name the corpus files")
+				}
+			}
+			m, err := corpus.Load(*manifest)
+			if err != nil {
+				return err
+			}
+			byType := map[string]*textmate.Grammar{}
+			if *grammars != "" {
+				if byType, err = loadGrammars(*grammars); err != nil {
+					return err
+				}
+			}
+			tokenize := corpusTokenizer()
+			defer tokenize.close()
+			var cov coverage.Collector
+			for _, name := range files {
+				src, err := os.ReadFile(name)
+				if err != nil {
+					return err
+				}
+				language := detect.Language(name, src).Name
+				if rel, err := filepath.Rel(filepath.Dir(*manifest), name); err == nil {
+					if e := m.Lookup(filepath.ToSlash(rel)); e != nil {
+						language = e.Language
+					}
+				}
+				if g, ok := byType[strings.TrimPrefix(filepath.Ext(name), ".")]; ok {
+					cov.Add(language, name, g.Tokenize(src, nil))
+					continue
+				}
+				toks, ok, err := tokenize.tokenize(language, src)
+				switch {
+				case err != nil:
+					return err
+				case ok:
+					cov.Add(language, name, toks)
+				default:
+					cov.AddUntokenized(language, name)
+				}
+			}
+			var w io.Writer = c.stdout
+			if *out != "" {
+				f, err := os.Create(*out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			return write(cov.Report(), w)
+		}
+	},
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/audit"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/email"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/webhook"
+)
+
+var deadLettersCommand = &command{
+	name:    "dead-letters",
+	args:    "list | requeue [<id>...] | rm <id>...",
+	summary: "Inspect the messages webhooks, publishing and mail gave up on, kept in a --dead-letters file, and requeue them to where they were going or remove them.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		file := fs.String("dead-letters", "", "the dead-letter `file` of serve or greet --dead-letters")
+		hookSecret := fs.String("webhook-secret", "", "sign requeued webhook requests with HMAC-SHA256 using `secret`")
+		relay := fs.String("smtp", "", "SMTP relay for requeued mail, as smtp[s]://[user:password@]host[:port]")
+		mailFrom := fs.String("mail-from", "", "sender `address` for requeued mail")
+		timeout := fs.Duration("timeout", time.Minute, "give up on requeueing a message after `duration`, retries included")
+		dryRun := fs.Bool("dry-run", false, "with requeue or rm, deliver and remove nothing, but show how the dead letters would change as a diff")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "list" && args[0] != "requeue" && args[0] != "rm" {
+				return usagef("expected list, requeue or rm")
+			}
+			// IDs come first, then flags.
+			rest := args[1:]
+			var ids []string
+			for len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+				ids, rest = append(ids, rest[0]), rest[1:]
+			}
+			if err := c.parseTrailingFlags(fs, rest); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if *file == "" {
+				return usagef("--dead-letters is required")
+			}
+			switch {
+			case args[0] == "list" && len(ids) > 0:
+				return usagef("list takes no IDs")
+			case args[0] == "rm" && len(ids) == 0:
+				return usagef("rm takes the IDs of the dead letters to remove")
+			}
+			dl, err := delivery.OpenDeadLetters(*file)
+			if err != nil {
+				return err
+			}
+			if *dryRun && args[0] != "list" {
+				return c.previewDeadLetters(dl, *file, args[0], ids)
+			}
+			switch args[0] {
+			case "rm":
+				return dl.Remove(ids...)
+			case "requeue":
+				r := &requeuer{c: c, hookSecret: *hookSecret, relay: *relay, mailFrom: *mailFrom, timeout: *timeout}
+				return r.requeue(ctx, dl, ids)
+			}
+			failures, err := dl.List()
+			if err != nil {
+				return err
+			}
+			writeFailures(c.stdout, failures)
+			return nil
+		}
+	},
+}
+
+// writeFailures lists fs to w, as dead-letters list does.
+func writeFailures(w io.Writer, fs []delivery.Failure) {
+	for _, f := range fs {
+		fmt.Fprintf(w, "%s  %s  %s  %d attempts  %s\n", f.ID, f.FailedAt.Format(time.RFC3339), f.Target, f.Attempts, f.Message.ID)
+		fmt.Fprintf(w, "    %s\n", f.Error)
+	}
+}
+
+// pickFailures returns the failures of fs with the given IDs, or all of
+// them if there are none.
+func pickFailures(fs []delivery.Failure, ids []string) ([]delivery.Failure, error) {
+	if len(ids) == 0 {
+		return fs, nil
+	}
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	var picked []delivery.Failure
+	for _, f := range fs {
+		if want[f.ID] {
+			picked = append(picked, f)
+			delete(want, f.ID)
+		}
+	}
+	for id := range want {
+		return nil, fmt.Errorf("%w: %s", delivery.ErrUnknownFailure, id)
+	}
+	return picked, nil
+}
+
+// previewDeadLetters shows, as a diff of their listing, how action, rm or
+// requeue, would change the dead letters in the file name if every
+// requeued message were delivered.
+func (c *cli) previewDeadLetters(dl *delivery.DeadLetters, name, action string, ids []string) error {
+	fs, err := dl.List()
+	if err != nil {
+		return err
+	}
+	picked, err := pickFailures(fs, ids)
+	if err != nil {
+		return err
+	}
+	gone := make(map[string]bool, len(picked))
+	for _, f := range picked {
+		gone[f.ID] = true
+	}
+	var kept []delivery.Failure
+	for _, f := range fs {
+		if !gone[f.ID] {
+			kept = append(kept, f)
+		}
+	}
+	var before, after bytes.Buffer
+	writeFailures(&before, fs)
+	writeFailures(&after, kept)
+	if _, err := c.printDiff(name, name+" after "+action, before.Bytes(), after.Bytes()); err != nil {
+		return err
+	}
+	verb := "remove"
+	if action == "requeue" {
+		verb = "requeue"
+	}
+	fmt.Fprintf(c.stderr, "would %s %d of %d dead letters\n", verb, len(picked), len(fs))
+	return nil
+}
+
+// requeuer delivers dead letters again, to their targets.
+type requeuer struct {
+	c          *cli
+	hookSecret string
+	relay      string
+	mailFrom   string
+	timeout    time.Duration
+
+	mailer     *email.Sender
+	publishers map[string]delivery.Publisher
+	audit      *audit.Log
+}
+
+// requeue delivers the dead letters with the given IDs, or all of them,
+// removing those delivered. It reports how many were and returns an error
+// if any were not.
+func (r *requeuer) requeue(ctx context.Context, dl *delivery.DeadLetters, ids []string) error {
+	fs, err := dl.List()
+	if err != nil {
+		return err
+	}
+	if fs, err = pickFailures(fs, ids); err != nil {
+		return err
+	}
+	if r.audit, err = openAuditLog(); err != nil {
+		return err
+	}
+	r.publishers = make(map[string]delivery.Publisher)
+	defer func() {
+		for _, p := range r.publishers {
+			p.Close()
+		}
+	}()
+	var done []string
+	var failed int
+	for _, f := range fs {
+		if err := r.deliver(ctx, f); err != nil {
+			fmt.Fprintf(r.c.stderr, "greeter: %s: %v\n", f.ID, err)
+			failed++
+			continue
+		}
+		done = append(done, f.ID)
+	}
+	if len(done) > 0 {
+		if err := dl.Remove(done...); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(r.c.stdout, "requeued %d of %d\n", len(done), len(fs))
+	if failed > 0 {
+		return fmt.Errorf("%d dead letters could not be delivered and were kept", failed)
+	}
+	return nil
+}
+
+func (r *requeuer) deliver(ctx context.Context, f delivery.Failure) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	scheme, rest, _ := strings.Cut(f.Target, ":")
+	switch scheme {
+	case "webhook":
+		h, err := webhook.ParseHook(rest)
+		if err != nil {
+			return err
+		}
+		h.Secret = r.hookSecret
+		client := webhook.NewClient()
+		if r.audit != nil {
+			client.Audit = r.audit
+		}
+		return client.Deliver(ctx, &h, f.Message)
+	case "mailto":
+		if r.mailer == nil {
+			if r.relay == "" || r.mailFrom == "" {
+				return usagef("requeueing mail needs --smtp and --mail-from")
+			}
+			m, err := email.ParseRelay(r.relay)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			m.From = r.mailFrom
+			if r.audit != nil {
+				m.Audit = r.audit
+			}
+			r.mailer = m
+		}
+		return r.mailer.Send(ctx, f.Message, strings.Split(rest, ","))
+	}
+	p, ok := r.publishers[f.Target]
+	if !ok {
+		var err error
+		if p, err = openPublisher(f.Target); err != nil {
+			return err
+		}
+		r.publishers[f.Target] = p
+	}
+	attempts, err := delivery.DefaultRetry.Do(ctx, func(ctx context.Context) error {
+		return p.Publish(ctx, f.Message)
+	})
+	if r.audit != nil {
+		r.audit.RecordDelivery(ctx, f.Message, f.Target, attempts, err)
+	}
+	return err
+}
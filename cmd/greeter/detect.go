@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/detect"
+)
+
+var detectCommand = &command{
+	name:    "detect",
+	args:    "[file ...]",
+	summary: "Guess the language of files, or standard input, from their names and content.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				args = []string{"-"}
+			}
+			for _, name := range args {
+				var src []byte
+				var err error
+				if name == "-" {
+					src, err = io.ReadAll(c.stdin)
+				} else {
+					src, err = os.ReadFile(name)
+				}
+				if err != nil {
+					return err
+				}
+				filename := name
+				if name == "-" {
+					filename = ""
+				}
+				l := detect.Language(filename, src)
+				if l.By == "" {
+					fmt.Fprintf(c.stdout, "%s: %s\n", name, l.Name)
+					continue
+				}
+				fmt.Fprintf(c.stdout, "%s: %s (by %s, %.0f%% confident)\n", name, l.Name, l.By, 100*l.Confidence)
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var docsCommand = &command{
+	name:    "docs",
+	args:    "man | markdown",
+	summary: "Write reference documentation for greeter and each of its commands, as man pages or Markdown, generated from the commands and flags themselves.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		out := fs.String("out", ".", "write the pages to `dir`, creating it if needed")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return usagef("expected man or markdown")
+			}
+			if err := c.parseTrailingFlags(fs, args[1:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			var write func(w io.Writer, cmd *command) error
+			var ext string
+			switch args[0] {
+			case "man":
+				date, err := docsDate()
+				if err != nil {
+					return err
+				}
+				write = func(w io.Writer, cmd *command) error { return writeManPage(w, cmd, date) }
+				ext = ".1"
+			case "markdown":
+				write, ext = writeMarkdownPage, ".md"
+			default:
+				return usagef("unknown format %q: want man or markdown", args[0])
+			}
+			if err := os.MkdirAll(*out, 0o755); err != nil {
+				return err
+			}
+			// The nil command stands for greeter itself.
+			for _, cmd := range append([]*command{nil}, commands...) {
+				var b strings.Builder
+				if err := write(&b, cmd); err != nil {
+					return err
+				}
+				name := filepath.Join(*out, pageName(cmd)+ext)
+				if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	},
+}
+
+// docsDate returns the date man pages are stamped with: that of
+// $SOURCE_DATE_EPOCH, for reproducible builds, or today.
+func docsDate() (string, error) {
+	t := time.Now()
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("SOURCE_DATE_EPOCH: %w", err)
+		}
+		t = time.Unix(sec, 0)
+	}
+	return t.UTC().Format("2006-01-02"), nil
+}
+
+// pageName names the page of cmd, or of greeter for nil.
+func pageName(cmd *command) string {
+	if cmd == nil {
+		return "greeter"
+	}
+	return "greeter-" + cmd.name
+}
+
+// globalFlags returns the flags every command takes.
+func globalFlags() []flagInfo {
+	return commandFlags(&command{setup: func(*cli, *flag.FlagSet) func(context.Context, []string) error { return nil }})
+}
+
+// ownFlags returns the flags of cmd that are not global.
+func ownFlags(cmd *command) []flagInfo {
+	global := globalFlags()
+	return slices.DeleteFunc(commandFlags(cmd), func(f flagInfo) bool {
+		return slices.ContainsFunc(global, func(g flagInfo) bool { return g.name == f.name })
+	})
+}
+
+// firstSentence returns the summary up to its first full stop, for the
+// one-line descriptions of NAME sections and command lists.
+func firstSentence(summary string) string {
+	if i := strings.Index(summary, ". "); i >= 0 {
+		return summary[:i]
+	}
+	return strings.TrimSuffix(summary, ".")
+}
+
+// configEnv returns the GREETER_<KEY> variables of configKeys, sorted,
+// with the flags they set.
+func configEnv() [][2]string {
+	var env [][2]string
+	for key, flagName := range configKeys {
+		env = append(env, [2]string{"GREETER_" + strings.ToUpper(key), flagName})
+	}
+	slices.SortFunc(env, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+	return env
+}
+
+func writeManPage(w io.Writer, cmd *command, date string) error {
+	var b strings.Builder
+	title := strings.ToUpper(pageName(cmd))
+	fmt.Fprintf(&b, ".TH %s 1 %q %q \"Greeter Manual\"\n", title, date, "greeter "+buildVersion())
+	b.WriteString(".SH NAME\n")
+	if cmd == nil {
+		b.WriteString("greeter \\- greet people and highlight source code\n")
+		b.WriteString(".SH SYNOPSIS\n.B greeter\n\\fIcommand\\fR [\\fIflags\\fR] [\\fIargs\\fR]\n")
+		b.WriteString(".SH DESCRIPTION\nEach command has a page of its own, such as\n.BR greeter\\-greet (1),\nand\n.B greeter help\n\\fIcommand\\fR\nprints its usage.\n")
+		b.WriteString(".SH COMMANDS\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(&b, ".TP\n.B %s\n%s.\nSee\n.BR %s (1).\n", roffEscape(cmd.name), roffEscape(firstSentence(cmd.summary)), roffEscape(pageName(cmd)))
+		}
+		b.WriteString(".SH \"GLOBAL OPTIONS\"\nThese flags follow the command name, as in\n.BR \"greeter greet \\-\\-output json\" .\n")
+		writeManFlags(&b, globalFlags())
+		b.WriteString(".SH ENVIRONMENT\n")
+		for _, kv := range configEnv() {
+			fmt.Fprintf(&b, ".TP\n.B %s\nThe default of \\fB\\-\\-%s\\fR.\n", roffEscape(kv[0]), roffEscape(kv[1]))
+		}
+		b.WriteString(".TP\n.B GREETER_CONFIG\nThe config file, in place of the default.\n")
+		b.WriteString(".SH FILES\n.TP\n.I $XDG_CONFIG_HOME/greeter/config.yaml\n")
+		b.WriteString("The config file, of \"key: value\" lines whose keys are those of the GREETER_ variables, lower-cased and without the prefix. The environment takes precedence over it, and flags over both.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(pageName(cmd)), roffEscape(firstSentence(cmd.summary)))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B greeter %s\n[\\fIflags\\fR]", roffEscape(cmd.name))
+	if cmd.args != "" {
+		b.WriteString(" " + roffEscape(cmd.args))
+	}
+	fmt.Fprintf(&b, "\n.SH DESCRIPTION\n%s\n", roffEscape(cmd.summary))
+	if flags := ownFlags(cmd); len(flags) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		writeManFlags(&b, flags)
+	}
+	b.WriteString(".PP\nThe global options of\n.BR greeter (1)\napply too.\n")
+	b.WriteString(".SH \"SEE ALSO\"\n.BR greeter (1)\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeManFlags(b *strings.Builder, flags []flagInfo) {
+	for _, f := range flags {
+		fmt.Fprintf(b, ".TP\n\\fB\\-\\-%s\\fR", roffEscape(f.name))
+		if !f.isBool {
+			fmt.Fprintf(b, " \\fI%s\\fR", roffEscape(f.kind))
+		}
+		b.WriteString("\n" + roffEscape(f.usage))
+		if f.def != "" && !f.isBool {
+			fmt.Fprintf(b, " (default \\fB%s\\fR)", roffEscape(f.def))
+		}
+		b.WriteString(".\n")
+	}
+}
+
+// roffEscape escapes s for the text of a man page: backslashes and hyphens,
+// and a line starting with a control character.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func writeMarkdownPage(w io.Writer, cmd *command) error {
+	var b strings.Builder
+	if cmd == nil {
+		b.WriteString("# greeter\n\nGreet people and highlight source code.\n\n```\ngreeter <command> [flags] [args]\n```\n\n## Commands\n\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(&b, "- [`%s`](%s.md): %s.\n", cmd.name, pageName(cmd), mdEscape(firstSentence(cmd.summary)))
+		}
+		b.WriteString("\n## Global flags\n\nThese flags follow the command name, as in `greeter greet --output json`.\n\n")
+		writeMarkdownFlags(&b, globalFlags())
+		b.WriteString("\n## Environment\n\n")
+		for _, kv := range configEnv() {
+			fmt.Fprintf(&b, "- `%s`: the default of `--%s`.\n", kv[0], kv[1])
+		}
+		b.WriteString("- `GREETER_CONFIG`: the config file, in place of the default.\n")
+		b.WriteString("\n## Files\n\n`$XDG_CONFIG_HOME/greeter/config.yaml` is the config file, of `key: value` lines whose keys are those of the `GREETER_` variables, lower-cased and without the prefix. The environment takes precedence over it, and flags over both.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	fmt.Fprintf(&b, "# greeter %s\n\n%s\n\n```\ngreeter %s [flags]", cmd.name, mdEscape(cmd.summary), cmd.name)
+	if cmd.args != "" {
+		b.WriteString(" " + cmd.args)
+	}
+	b.WriteString("\n```\n")
+	if flags := ownFlags(cmd); len(flags) > 0 {
+		b.WriteString("\n## Flags\n\n")
+		writeMarkdownFlags(&b, flags)
+	}
+	b.WriteString("\nThe [global flags](greeter.md#global-flags) apply too.\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownFlags(b *strings.Builder, flags []flagInfo) {
+	for _, f := range flags {
+		fmt.Fprintf(b, "- `--%s", f.name)
+		if !f.isBool {
+			b.WriteString(" " + f.kind)
+		}
+		b.WriteString("`: " + mdEscape(f.usage))
+		if f.def != "" && !f.isBool {
+			fmt.Fprintf(b, " (default `%s`)", f.def)
+		}
+		b.WriteString(".\n")
+	}
+}
+
+// mdEscape escapes the characters of s that Markdown would take for
+// emphasis, code, links or HTML.
+func mdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "<", `\<`, "[", `\[`).Replace(s)
+}
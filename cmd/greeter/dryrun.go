@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/goldentest"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// printDiff writes a unified diff from before, called aName, to after,
+// called bName, to stdout, in color if --color asks for it. It reports
+// whether they differ.
+func (c *cli) printDiff(aName, bName string, before, after []byte) (bool, error) {
+	d := goldentest.Diff(aName, bName, before, after)
+	if d == "" {
+		return false, nil
+	}
+	color, err := c.colorEnabled()
+	if err != nil {
+		return true, err
+	}
+	if !color {
+		_, err := io.WriteString(c.stdout, d)
+		return true, err
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(d, "\n") {
+		var sgr string
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			sgr = "1"
+		case strings.HasPrefix(line, "@@"):
+			sgr = "36"
+		case line[0] == '-':
+			sgr = "31"
+		case line[0] == '+':
+			sgr = "32"
+		default:
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString("\x1b[" + sgr + "m" + strings.TrimSuffix(line, "\n") + "\x1b[0m\n")
+	}
+	_, err = io.WriteString(c.stdout, b.String())
+	return true, err
+}
+
+// messageLines returns m as YAML, one field a line, for printDiff, or nil
+// for the zero Message, which stands for no message. CreatedAt is written
+// in UTC, since the stores keep the instant but not the zone.
+func messageLines(m message.Message) []byte {
+	if m.ID == "" {
+		return nil
+	}
+	m.CreatedAt = m.CreatedAt.UTC()
+	data, err := message.MarshalMessage(m, message.FormatYAML)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// writeKeyring writes a keyring of keys, the first primary, returning its
+// path.
+func writeKeyring(t *testing.T, dir, name string, keys ...seal.Key) string {
+	t.Helper()
+	var lines []string
+	for _, k := range keys {
+		lines = append(lines, k.String())
+	}
+	return writeFile(t, dir, name, strings.Join(lines, "\n")+"\n")
+}
+
+func TestKeysRotateDryRun(t *testing.T) {
+	dir := t.TempDir()
+	old, err := seal.GenerateKey("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := seal.GenerateKey("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := filepath.Join(dir, "greetings.db")
+	t.Setenv("GREETER_DB_KEYS", writeKeyring(t, dir, "old.keys", old))
+	st, err := openStore(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, text := range []string{"Hello, Ada!", "Hello, Alan!"} {
+		m := message.NewMessage(text)
+		if err := st.Save(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ID)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GREETER_DB_KEYS", writeKeyring(t, dir, "new.keys", primary, old))
+
+	before, err := os.ReadFile(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr := runCLI(t, "", "keys", "rotate", "--db", db, "--dry-run")
+	if code != 0 {
+		t.Fatalf("keys rotate --dry-run exited %d: %s", code, stderr)
+	}
+	for _, id := range ids {
+		if !strings.Contains(stdout, id+"\told\n") {
+			t.Errorf("keys rotate --dry-run output does not list %s as sealed with old:\n%s", id, stdout)
+		}
+	}
+	if !strings.Contains(stdout, "would encrypt 2 messages") {
+		t.Errorf("keys rotate --dry-run output = %q, want the count it would encrypt", stdout)
+	}
+	if after, err := os.ReadFile(db); err != nil || !bytes.Equal(after, before) {
+		t.Fatalf("keys rotate --dry-run changed the database (err %v)", err)
+	}
+
+	if code, stdout, stderr := runCLI(t, "", "keys", "rotate", "--db", db); code != 0 || !strings.Contains(stdout, "encrypted 2 messages") {
+		t.Fatalf("keys rotate exited %d with %q: %s", code, stdout, stderr)
+	}
+	if after, err := os.ReadFile(db); err != nil || bytes.Equal(after, before) {
+		t.Fatalf("keys rotate left the database as it was (err %v)", err)
+	}
+	if code, stdout, _ := runCLI(t, "", "keys", "rotate", "--db", db, "--dry-run"); code != 0 || !strings.Contains(stdout, "would encrypt 0 messages") {
+		t.Errorf("keys rotate --dry-run after rotating exited %d with %q, want nothing to encrypt", code, stdout)
+	}
+}
+
+func TestMigrateDryRunWithoutSchema(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "greetings.db")
+	code, _, stderr := runCLI(t, "", "migrate", "--db", db, "--to", "0", "--dry-run")
+	if code != 1 || !strings.Contains(stderr, "no versioned schema") {
+		t.Errorf("migrate --dry-run of a bolt store exited %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(db); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("migrate --dry-run created the database (err %v)", err)
+	}
+}
+
+func TestGreetMailDryRun(t *testing.T) {
+	deadLetters := filepath.Join(t.TempDir(), "dead.jsonl")
+	// Nothing listens on the relay, so mailing would only dead-letter.
+	code, stdout, stderr := runCLI(t, "", "greet",
+		"--mail-to", "ada@example.com, alan@example.com",
+		"--smtp", "smtp://127.0.0.1:1",
+		"--mail-from", "greeter@example.com",
+		"--dead-letters", deadLetters,
+		"--dry-run", "Ada")
+	if code != 0 {
+		t.Fatalf("greet --dry-run exited %d: %s", code, stderr)
+	}
+	for _, want := range []string{"would mail ", " to ada@example.com, alan@example.com:\n", "\tFrom: <greeter@example.com>\n", "\tSubject: "} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("greet --dry-run output does not contain %q:\n%s", want, stdout)
+		}
+	}
+	if _, err := os.Stat(deadLetters); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("greet --dry-run wrote the dead letters file (err %v)", err)
+	}
+
+	if code, _, stderr := runCLI(t, "", "greet", "--dry-run", "Ada"); code != 2 {
+		t.Errorf("greet --dry-run without --mail-to exited %d, want 2: %s", code, stderr)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexfuzz"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/samples"
+)
+
+var fuzzCommand = &command{
+	name:    "fuzz",
+	args:    "[file ...]",
+	summary: "Mutate corpus files, the test.* files and generated samples by default, into pathological cases and check that the lexers survive them.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		iterations := fs.Int("iterations", 1000, "try `n` mutated inputs with each lexer")
+		seed := fs.Uint64("seed", 1, "repeat a run with the same `seed`")
+		grammar := fs.String("grammar", "", "also check the TextMate grammar in `file`, a .tmLanguage.json")
+		scopes := fs.String("scopes", "", "with --grammar, map scopes to token kinds with the \"scope kind\" lines in `file`")
+		out := fs.String("out", "", "write each failing input to `dir`, to reproduce it with greeter highlight")
+		return func(ctx context.Context, args []string) error {
+			if *iterations < 1 {
+				return usagef("--iterations must be positive")
+			}
+			tokenizers := []lexfuzz.Tokenizer{{Name: "builtin", Tokenize: lexer.Tokenize, Covers: true, SkipsBOM: true}}
+			if *grammar != "" {
+				tokenize, err := loadGrammar(*grammar, *scopes)
+				if err != nil {
+					return err
+				}
+				tokenizers = append(tokenizers, lexfuzz.Tokenizer{Name: "textmate", Tokenize: tokenize})
+			} else if *scopes != "" {
+				return usagef("--scopes needs --grammar")
+			}
+			corpus, err := fuzzCorpus(args)
+			if err != nil {
+				return err
+			}
+			failures := lexfuzz.Run(tokenizers, lexfuzz.Options{Corpus: corpus, Iterations: *iterations, Seed: *seed})
+			for i, f := range failures {
+				fmt.Fprintf(c.stdout, "%s: %s: %v\n", f.Tokenizer, strings.Join(f.Mutations, "+"), f.Err)
+				if *out == "" {
+					continue
+				}
+				if err := os.MkdirAll(*out, 0o755); err != nil {
+					return err
+				}
+				name := filepath.Join(*out, fmt.Sprintf("%s-%d.txt", f.Tokenizer, i+1))
+				if err := os.WriteFile(name, f.Input, 0o644); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.stdout, "\tinput written to %s\n", name)
+			}
+			if len(failures) > 0 {
+				return fmt.Errorf("%d failing inputs with seed %d", len(failures), *seed)
+			}
+			fmt.Fprintf(c.stdout, "no failures in %d inputs to each of %d lexers\n", *iterations, len(tokenizers))
+			return nil
+		}
+	},
+}
+
+// fuzzCorpus reads the files to mutate, or else the test.* files, the
+// generated samples and the Unicode edge cases.
+func fuzzCorpus(files []string) ([][]byte, error) {
+	var corpus [][]byte
+	if len(files) == 0 {
+		var err error
+		if files, err = filepath.Glob("test.*"); err != nil {
+			return nil, err
+		}
+		ss, err := samples.Generate(samples.Options{})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			corpus = append(corpus, []byte(s.Source))
+		}
+		for _, uc := range samples.UnicodeCases() {
+			corpus = append(corpus, []byte(uc.Source))
+		}
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, src)
+	}
+	return corpus, nil
+}
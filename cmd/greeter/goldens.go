@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/detect"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/goldentest"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/textmate"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/textmate/grammars"
+)
+
+var goldensCommand = &command{
+	name:    "goldens",
+	args:    "update|verify [file ...]",
+	summary: "Render the highlighting corpus, the test.* files by default, to HTML and ANSI, and write or check its golden files.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		dir := fs.String("dir", "goldens", "keep the golden files under `dir`")
+		grammarDir := fs.String("grammars", "", "tokenize files with the .tmLanguage.json grammars in `dir`, by their fileTypes, before the built-in lexers and grammars")
+		workers := fs.Int("workers", 0, "render `n` files at once (default one per core)")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "update" && args[0] != "verify" {
+				return usagef("expected update or verify")
+			}
+			files, err := c.parseTrailingArgs(fs, args[1:])
+			if err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if len(files) == 0 {
+				if files, err = filepath.Glob("test.*"); err != nil {
+					return err
+				}
+				if len(files) == 0 {
+					return usagef("no test.* files here: name the corpus files")
+				}
+			}
+			byType := map[string]*textmate.Grammar{}
+			if *grammarDir != "" {
+				if byType, err = loadGrammars(*grammarDir); err != nil {
+					return err
+				}
+			}
+			bundled, err := grammars.ByFileType()
+			if err != nil {
+				return err
+			}
+			plugins := corpusTokenizer()
+			defer plugins.close()
+			var pluginsMu sync.Mutex
+			h := &goldentest.Harness{
+				Dir:     *dir,
+				Formats: goldentest.Formats,
+				Workers: *workers,
+				// The built-in lexers and grammars come before lexer plugins,
+				// so that goldens do not depend on the plugins installed.
+				Tokenize: func(name string, src []byte) ([]lexer.Token, bool, error) {
+					ext := strings.TrimPrefix(filepath.Ext(name), ".")
+					if g, ok := byType[ext]; ok {
+						return g.Tokenize(src, nil), true, nil
+					}
+					language := detect.Language(name, src).Name
+					if l, err := lexers.Lookup(language); err == nil {
+						toks, err := l.Tokenize(src)
+						return toks, true, err
+					}
+					if g, ok := bundled[ext]; ok {
+						return g.Tokenize(src, nil), true, nil
+					}
+					pluginsMu.Lock()
+					defer pluginsMu.Unlock()
+					return plugins.tokenize(language, src)
+				},
+			}
+			var results []goldentest.Result
+			if args[0] == "update" {
+				results, err = h.Update(files)
+			} else {
+				results, err = h.Verify(files)
+			}
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, r := range results {
+				switch r.Status {
+				case goldentest.Match, goldentest.Unchanged:
+				case goldentest.Skipped:
+					failed++
+					fmt.Fprintf(c.stdout, "cannot check %s: no lexer or grammar for it\n", r.File)
+				case goldentest.Mismatch:
+					failed++
+					fmt.Fprint(c.stdout, r.Diff)
+				case goldentest.Missing:
+					failed++
+					fmt.Fprintf(c.stdout, "missing %s\n", r.Golden)
+				default:
+					fmt.Fprintf(c.stdout, "%s %s\n", r.Status, r.Golden)
+				}
+			}
+			if failed > 0 && args[0] == "update" {
+				return fmt.Errorf("%d of %d files have no goldens: give their grammars with --grammars or install lexer plugins for them", failed, len(files))
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d goldens do not match or cannot be checked: run greeter goldens update if the changes are intended", failed, len(results))
+			}
+			return nil
+		}
+	},
+}
+
+// loadGrammars loads the .tmLanguage.json grammars in dir and returns them
+// by the file types they are for.
+func loadGrammars(dir string) (map[string]*textmate.Grammar, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.tmLanguage.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: no .tmLanguage.json grammars", dir)
+	}
+	byType := map[string]*textmate.Grammar{}
+	for _, name := range names {
+		g, err := textmate.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range g.Skipped {
+			slog.Warn("grammar rule skipped", "grammar", g.ScopeName, "rule", s)
+		}
+		for _, t := range g.FileTypes {
+			byType[strings.TrimPrefix(t, ".")] = g
+		}
+	}
+	return byType, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGoldens checks the highlighting corpus at the root of the module
+// against its goldens, as greeter goldens verify does there.
+func TestGoldens(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "test.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test.* files at the root of the module")
+	}
+	args := append([]string{"goldens", "verify", "--dir", filepath.Join("..", "..", "goldens")}, files...)
+	if code, stdout, stderr := runCLI(t, "", args...); code != 0 {
+		t.Errorf("goldens verify exited %d: %s\n%s", code, stderr, stdout)
+	}
+}
+
+func TestGoldensCannotCheck(t *testing.T) {
+	dir := t.TempDir()
+	name := writeFile(t, dir, "notes.unknown", "no lexer for this\n")
+	for _, verb := range []string{"verify", "update"} {
+		code, stdout, _ := runCLI(t, "", "goldens", verb, "--dir", filepath.Join(dir, "goldens"), name)
+		if code != 1 || !strings.Contains(stdout, "cannot check "+name) {
+			t.Errorf("goldens %s of a file with no lexer exited %d with %q, want it to fail", verb, code, stdout)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/email"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/script"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/render"
+)
+
+// mailTimeout bounds mailing a greeting, retries included.
+const mailTimeout = 2 * time.Minute
+
+var greetCommand = &command{
+	name:    "greet",
+	args:    "[<name>... | -]",
+	summary: "Greet one or more people. Use - or --from to read names from stdin or a file.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		lang := fs.String("lang", "", "greeting language, e.g. fr or cs-CZ (default from LANG)")
+		style := fs.String("style", "default", "greeting style: "+strings.Join(greeting.Greeters(), ", "))
+		format := fs.String("format", "", "greeting template with {name} placeholders and {date now}, {number n} or {currency EUR n}, e.g. 'Hi {name}!'")
+		count := fs.Int("count", 1, "number of times to print each greeting")
+		seed := fs.Uint64("seed", 0, "with --style variants, pick the phrasings with a source seeded with `n`, the same on every run; 0 picks at random")
+		from := fs.String("from", "", "read names from `file`, one per line or as CSV (- for stdin)")
+		var mailTo []string
+		fs.Func("mail-to", "also email each greeting to `addresses`, comma-separated; repeatable", func(v string) error {
+			for _, addr := range strings.Split(v, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					mailTo = append(mailTo, addr)
+				}
+			}
+			return nil
+		})
+		relay := fs.String("smtp", "", "SMTP relay for --mail-to, as smtp[s]://[user:password@]host[:port]")
+		mailFrom := fs.String("mail-from", "", "sender `address` for --mail-to")
+		deadLetters := fs.String("dead-letters", "", "keep the greetings --mail-to gives up on in `file`, for greeter dead-letters to requeue, and go on")
+		subject := fs.String("mail-subject", "", "subject `template` for --mail-to, e.g. 'A greeting from {{.Sender}}' (default the greeting)")
+		scriptFile := fs.String("script", "", "change or drop each greeting with the script in `file` before it is printed or mailed")
+		scriptTimeout := fs.Duration("script-timeout", 100*time.Millisecond, "stop --script after `duration` on a greeting")
+		dryRun := fs.Bool("dry-run", false, "with --mail-to, print the mail each greeting would be sent as, and send, log and dead-letter nothing")
+		copyOut := fs.Bool("copy", false, "also copy the greetings, without color, to the clipboard, or with OSC 52 over SSH")
+		return func(ctx context.Context, args []string) error {
+			names, err := c.readNames(args, *from)
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				return usagef("at least one name is required")
+			}
+			if *count < 0 {
+				return usagef("--count must not be negative")
+			}
+			t, err := c.currentTenant()
+			if err != nil {
+				return err
+			}
+			ctx = tenantGreeting(ctx, t, fs, style, lang, format)
+			g, err := lookupGreeter(*style)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			if v, ok := g.(*greeting.VariantGreeter); ok && *seed != 0 {
+				g = &greeting.VariantGreeter{Localizer: v.Localizer, Variants: v.Variants, Source: greeting.SeededSource(*seed)}
+			}
+			p, err := c.printer()
+			if err != nil {
+				return err
+			}
+			// With --copy, greetings are printed again, without color, for
+			// the clipboard.
+			var clip bytes.Buffer
+			var clipPrinter *render.Printer
+			if *copyOut {
+				if clipPrinter, err = c.printerTo(&clip, false); err != nil {
+					return err
+				}
+			}
+			var mailer *email.Sender
+			if len(mailTo) > 0 {
+				if *relay == "" || *mailFrom == "" {
+					return usagef("--mail-to needs --smtp and --mail-from")
+				}
+				if mailer, err = email.ParseRelay(*relay); err != nil {
+					return usagef("%v", err)
+				}
+				mailer.From, mailer.Subject, mailer.Locale = *mailFrom, *subject, *lang
+			} else if *deadLetters != "" {
+				return usagef("--dead-letters needs --mail-to")
+			} else if *dryRun {
+				return usagef("--dry-run needs --mail-to")
+			}
+			if mailer != nil && !*dryRun {
+				log, err := openAuditLog()
+				if err != nil {
+					return err
+				}
+				if log != nil {
+					mailer.Audit = log
+				}
+				if *deadLetters != "" {
+					if mailer.DeadLetters, err = delivery.OpenDeadLetters(*deadLetters); err != nil {
+						return err
+					}
+				}
+			}
+			sc, err := loadScript(*scriptFile, *scriptTimeout)
+			if err != nil {
+				return err
+			}
+			if *lang != "" {
+				ctx = greeting.ContextWithLocale(ctx, *lang)
+			}
+			if *format != "" {
+				g = greeting.GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+					text, err := greeting.GreetTemplate(ctx, *format, name, nil)
+					return message.NewMessage(text), err
+				})
+			}
+			g = produce(g)
+			for _, name := range names {
+				m, err := g.Greet(ctx, name)
+				if err != nil {
+					return err
+				}
+				m = tenantMessage(t, m)
+				if sc != nil {
+					var keep bool
+					if m, keep, err = sc.Run(ctx, m); err != nil {
+						return err
+					}
+					if !keep {
+						slog.InfoContext(ctx, "greeting dropped by script", "id", m.ID)
+						continue
+					}
+				}
+				for i := 0; i < *count; i++ {
+					if err := p.Print(m, name); err != nil {
+						return err
+					}
+					if clipPrinter != nil {
+						if err := clipPrinter.Print(m, name); err != nil {
+							return err
+						}
+					}
+				}
+				if mailer != nil && *dryRun {
+					data, err := mailer.Compose(m)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(c.stdout, "would mail %s to %s:\n", m.ID, strings.Join(mailTo, ", "))
+					for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+						fmt.Fprintf(c.stdout, "\t%s\n", line)
+					}
+				} else if mailer != nil {
+					sendCtx, cancel := context.WithTimeout(ctx, mailTimeout)
+					err := mailer.Send(sendCtx, m, mailTo)
+					cancel()
+					if errors.Is(err, delivery.ErrDeadLettered) {
+						slog.WarnContext(ctx, "greeting dead-lettered", "id", m.ID, "error", err)
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					slog.InfoContext(ctx, "greeting mailed", "id", m.ID, "recipients", len(mailTo))
+				}
+			}
+			if *copyOut {
+				return copyToClipboard(ctx, bytes.TrimSuffix(clip.Bytes(), []byte("\n")), "text/plain")
+			}
+			return nil
+		}
+	},
+}
+
+// loadScript loads the message script in the file name, stopping each run
+// after timeout, or returns nil if name is empty.
+func loadScript(name string, timeout time.Duration) (*script.Script, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if timeout <= 0 {
+		return nil, usagef("--script-timeout must be positive")
+	}
+	sc, err := script.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	sc.Limits.Timeout = timeout
+	return sc, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"flag"
+)
+
+var helpCommand = &command{
+	name:    "help",
+	args:    "[command]",
+	summary: "Show help for greeter or one of its commands.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				c.usage()
+				return nil
+			}
+			cmd := lookupCommand(args[0])
+			if cmd == nil {
+				return usagef("unknown command %q", args[0])
+			}
+			sub, _ := c.newFlagSet(cmd)
+			sub.Usage()
+			return nil
+		}
+	},
+}
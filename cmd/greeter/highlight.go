@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/chromacompat"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/htmlrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/imagerender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/latexrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/linerange"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/termrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/textmate"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+var highlightCommand = &command{
+	name:    "highlight",
+	args:    "[file ...]",
+	summary: "Highlight Go source, or another language with a TextMate --grammar, from files or standard input, in the terminal, as HTML or LaTeX, or as an SVG or PNG image.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		format := fs.String("format", "ansi", "output format: ansi, html, latex, svg, png, or chroma-json for the tokens as Chroma's json formatter writes them")
+		depth := fs.String("color-depth", "auto", "terminal colors: auto, 16, 256 or truecolor")
+		standalone := fs.Bool("standalone", false, "with --format latex, write a whole document, with the preamble the listings need")
+		inline := fs.Bool("inline-styles", false, "with --format html, style each token inline instead of by class")
+		themeName := fs.String("theme", "", "color tokens with a bundled `theme` ("+strings.Join(theme.Names(), ", ")+") or a theme file, or a Chroma style's .xml (default dark for ansi, light otherwise)")
+		fontFamily := fs.String("font-family", imagerender.DefaultFontFamily, "with --format svg, the CSS font `families` to use, which should be monospace")
+		fontSize := fs.Int("font-size", imagerender.DefaultFontSize, "with --format svg or png, the font size in `pixels`")
+		numbers := fs.Bool("line-numbers", false, "with --format ansi, html or latex, number the lines")
+		startLine := fs.Int("start-line", 1, "number the first line `n`, as for a snippet of a larger file")
+		hlLines := fs.String("hl-lines", "", "with --format ansi or html, highlight the lines with these `numbers`, such as 3-5,9, counted from --start-line")
+		padding := fs.Int("padding", imagerender.DefaultPadding, "with --format svg or png, the space around the code in `pixels`")
+		grammar := fs.String("grammar", "", "tokenize with the TextMate grammar in `file`, a .tmLanguage.json, instead of as Go")
+		lexerName := fs.String("lexer", "", "tokenize with the lexer for `language`, built in or a "+lexers.PluginPrefix+"language plugin on the PATH, instead of as Go")
+		scopes := fs.String("scopes", "", "with --grammar, map scopes to token kinds with the \"scope kind\" lines in `file`")
+		copyOut := fs.Bool("copy", false, "also copy the output to the clipboard, as HTML with --format html, or with OSC 52 over SSH")
+		return func(ctx context.Context, args []string) error {
+			th, err := loadTheme(*themeName)
+			if err != nil {
+				return err
+			}
+			tokenize := func(src []byte) ([]lexer.Token, error) { return lexer.Tokenize(src), nil }
+			// Go source is rendered as it is read, if the format can be,
+			// rather than all at once.
+			goLexer := true
+			switch {
+			case *grammar != "" && *lexerName != "":
+				return usagef("--grammar and --lexer are exclusive")
+			case *grammar != "":
+				g, err := loadGrammar(*grammar, *scopes)
+				if err != nil {
+					return err
+				}
+				tokenize = func(src []byte) ([]lexer.Token, error) { return g(src), nil }
+				goLexer = false
+			case *scopes != "":
+				return usagef("--scopes needs --grammar")
+			case *lexerName != "":
+				l, closeLexer, err := openLexer(*lexerName)
+				if err != nil {
+					return err
+				}
+				defer closeLexer()
+				tokenize = l.Tokenize
+				goLexer = false
+			}
+			if *startLine < 1 {
+				return usagef("--start-line must be at least 1")
+			}
+			highlighted, err := linerange.Parse(*hlLines)
+			if err != nil {
+				return usagef("--hl-lines: %v", err)
+			}
+			var render func(io.Writer, []byte, []lexer.Token) error
+			// stream, if not nil, renders Go source from a reader, with
+			// line numbers as wide as lines of it need.
+			var stream func(w io.Writer, r io.Reader, lines int) error
+			// header and footer are written before and after every file.
+			var header, footer string
+			switch *format {
+			case "ansi":
+				opts := termrender.Options{
+					Depth:          termrender.DetectDepth(os.Getenv),
+					Theme:          th,
+					LineNumbers:    *numbers,
+					StartLine:      *startLine,
+					HighlightLines: highlighted,
+				}
+				if f, ok := c.stdout.(*os.File); ok {
+					opts.Depth = detectTerminal(f).Depth
+				}
+				if *depth != "auto" {
+					d, err := termrender.ParseDepth(*depth)
+					if err != nil {
+						return usagef("%v", err)
+					}
+					opts.Depth = d
+				}
+				color, err := c.colorEnabled()
+				if err != nil {
+					return err
+				}
+				if !color {
+					// Without color, only line numbers are left to show.
+					opts.Theme = &theme.Theme{}
+				}
+				render = func(w io.Writer, src []byte, toks []lexer.Token) error {
+					if !color && !opts.LineNumbers {
+						_, err := w.Write(src)
+						return err
+					}
+					return termrender.RenderTokens(w, src, toks, opts)
+				}
+				stream = func(w io.Writer, r io.Reader, lines int) error {
+					if !color && !opts.LineNumbers {
+						_, err := io.Copy(w, r)
+						return err
+					}
+					opts := opts
+					opts.Lines = lines
+					return termrender.RenderReader(w, r, opts)
+				}
+			case "html":
+				opts := htmlrender.Options{
+					Theme:          th,
+					InlineStyles:   *inline,
+					LineNumbers:    *numbers,
+					StartLine:      *startLine,
+					HighlightLines: highlighted,
+				}
+				render = func(w io.Writer, src []byte, toks []lexer.Token) error {
+					return htmlrender.RenderTokens(w, src, toks, opts)
+				}
+				stream = func(w io.Writer, r io.Reader, lines int) error {
+					opts := opts
+					opts.Lines = lines
+					return htmlrender.RenderReader(w, r, opts)
+				}
+			case "latex":
+				opts := latexrender.Options{Theme: th, LineNumbers: *numbers, StartLine: *startLine}
+				if *standalone {
+					header = "\\documentclass{article}\n" + latexrender.Preamble(opts) + "\\begin{document}\n"
+					footer = "\\end{document}\n"
+				}
+				render = func(w io.Writer, src []byte, toks []lexer.Token) error {
+					return latexrender.RenderTokens(w, src, toks, opts)
+				}
+			case "chroma-json":
+				render = chromacompat.WriteJSON
+			case "svg", "png":
+				if len(args) > 1 {
+					return usagef("--format %s takes one file", *format)
+				}
+				if *fontSize < 1 || *padding < 0 {
+					return usagef("--font-size must be positive and --padding not negative")
+				}
+				opts := imagerender.Options{Theme: th, FontFamily: *fontFamily, FontSize: *fontSize, Padding: *padding}
+				if opts.Padding == 0 {
+					// imagerender takes zero for its default.
+					opts.Padding = -1
+				}
+				image := imagerender.RenderSVGTokens
+				if *format == "png" {
+					image = imagerender.RenderPNGTokens
+				}
+				render = func(w io.Writer, src []byte, toks []lexer.Token) error {
+					return image(w, src, toks, opts)
+				}
+			default:
+				return usagef("unknown format %q: want ansi, html, latex, svg, png or chroma-json", *format)
+			}
+			if *standalone && *format != "latex" {
+				return usagef("--standalone needs --format latex")
+			}
+			if len(args) == 0 {
+				args = []string{"-"}
+			}
+			out := c.stdout
+			var clip bytes.Buffer
+			if *copyOut {
+				out = io.MultiWriter(c.stdout, &clip)
+			}
+			if _, err := io.WriteString(out, header); err != nil {
+				return err
+			}
+			for _, name := range args {
+				// Standard input cannot be read twice to count its lines
+				// first, so it is read whole when they are numbered.
+				if stream != nil && goLexer && (name != "-" || !*numbers) {
+					if err := streamFile(c, out, name, *numbers, stream); err != nil {
+						return err
+					}
+					continue
+				}
+				var src []byte
+				if name == "-" {
+					src, err = io.ReadAll(c.stdin)
+				} else {
+					src, err = os.ReadFile(name)
+				}
+				if err != nil {
+					return err
+				}
+				toks, err := tokenize(src)
+				if err != nil {
+					return err
+				}
+				if err := render(out, src, toks); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(out, footer); err != nil {
+				return err
+			}
+			if *copyOut {
+				return copyToClipboard(ctx, clip.Bytes(), clipboardTypes[*format])
+			}
+			return nil
+		}
+	},
+}
+
+// clipboardTypes are the media types --copy puts formats on the clipboard
+// as, if not text.
+var clipboardTypes = map[string]string{
+	"html": "text/html",
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+}
+
+// streamFile renders the file name, or standard input for -, with stream
+// to w, counting its lines first if numbered.
+func streamFile(c *cli, w io.Writer, name string, numbered bool, stream func(io.Writer, io.Reader, int) error) error {
+	if name == "-" {
+		return stream(w, c.stdin, 0)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	lines := 0
+	if numbered {
+		if lines, err = countLines(f); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return stream(w, f, lines)
+}
+
+// countLines returns the number of lines read from r, counting a last line
+// without a newline.
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 32<<10)
+	lines, last := 0, byte('\n')
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte("\n"))
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// loadGrammar returns a tokenizer for the TextMate grammar in the file
+// name, mapping scopes with the file scopes, or DefaultScopes if it is "".
+func loadGrammar(name, scopes string) (func([]byte) []lexer.Token, error) {
+	g, err := textmate.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range g.Skipped {
+		slog.Warn("grammar rule skipped", "grammar", g.ScopeName, "rule", s)
+	}
+	var m textmate.ScopeMap
+	if scopes != "" {
+		if m, err = textmate.LoadScopes(scopes); err != nil {
+			return nil, err
+		}
+	}
+	return func(src []byte) []lexer.Token { return g.Tokenize(src, m) }, nil
+}
+
+// loadTheme returns the bundled theme called name, or else the theme or
+// Chroma style in the file name, or nil for no name.
+func loadTheme(name string) (*theme.Theme, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if t, ok := theme.Builtin(name); ok {
+		return t, nil
+	}
+	switch filepath.Ext(name) {
+	case "":
+		return nil, usagef("unknown theme %q: want one of %s, a .json or .yaml file, or a Chroma style's .xml", name, strings.Join(theme.Names(), ", "))
+	case ".xml":
+		return chromacompat.LoadStyle(name)
+	}
+	return theme.Load(name)
+}
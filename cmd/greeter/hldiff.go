@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/chromacompat"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/hldiff"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+var hldiffCommand = &command{
+	name:    "hldiff",
+	args:    "file ...",
+	summary: "Tokenize files with two highlighting engines and report where their token boundaries and kinds disagree.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		engineA := fs.String("engine-a", "builtin", "first `engine`: builtin, the Go lexer; lexer:name, a lexer greeter lexers lists; textmate:file for a .tmLanguage.json grammar; chroma, running Chroma; or chroma:file, the tokens chroma --formatter json wrote for the one file given")
+		engineB := fs.String("engine-b", "builtin", "second `engine`, as for --engine-a")
+		scopes := fs.String("scopes", "", "map the scopes of textmate engines to token kinds with the \"scope kind\" lines in `file`")
+		chroma := fs.String("chroma", "chroma", "run `command` for chroma engines")
+		lines := fs.Int("context", 1, "show `n` lines of source around each disagreement")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return usagef("expected at least one file")
+			}
+			if *lines < 0 {
+				return usagef("--context must not be negative")
+			}
+			opts := engineOptions{scopes: *scopes, chroma: *chroma, files: len(args)}
+			a, stopA, err := loadEngine(ctx, *engineA, opts)
+			if err != nil {
+				return err
+			}
+			defer stopA()
+			b, stopB, err := loadEngine(ctx, *engineB, opts)
+			if err != nil {
+				return err
+			}
+			defer stopB()
+			total := 0
+			for _, name := range args {
+				var src []byte
+				if name == "-" {
+					src, err = io.ReadAll(c.stdin)
+				} else {
+					src, err = os.ReadFile(name)
+				}
+				if err != nil {
+					return err
+				}
+				ta, err := a(name, src)
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", name, *engineA, err)
+				}
+				tb, err := b(name, src)
+				if err != nil {
+					return fmt.Errorf("%s: %s: %w", name, *engineB, err)
+				}
+				diffs := hldiff.Compare(ta, tb)
+				for _, d := range diffs {
+					printDifference(c.stdout, name, src, d, *lines)
+				}
+				total += len(diffs)
+			}
+			if total > 0 {
+				return fmt.Errorf("%d disagreements between %s and %s", total, *engineA, *engineB)
+			}
+			return nil
+		}
+	},
+}
+
+// An engine tokenizes src, read from the file name, or from stdin for -.
+type engine func(name string, src []byte) ([]lexer.Token, error)
+
+// engineOptions are the hldiff flags engines are loaded with; files is the
+// number of files to compare.
+type engineOptions struct {
+	scopes string
+	chroma string
+	files  int
+}
+
+// loadEngine returns the engine of an --engine-a or --engine-b value, and
+// a function stopping it.
+func loadEngine(ctx context.Context, spec string, opts engineOptions) (engine, func(), error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch {
+	case spec == "builtin":
+		return func(_ string, src []byte) ([]lexer.Token, error) { return lexer.Tokenize(src), nil }, func() {}, nil
+	case kind == "lexer" && arg != "":
+		l, stop, err := openLexer(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(_ string, src []byte) ([]lexer.Token, error) { return l.Tokenize(src) }, stop, nil
+	case kind == "textmate" && arg != "":
+		tokenize, err := loadGrammar(arg, opts.scopes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(_ string, src []byte) ([]lexer.Token, error) { return tokenize(src), nil }, func() {}, nil
+	case spec == "chroma":
+		return func(name string, src []byte) ([]lexer.Token, error) {
+			return runChroma(ctx, opts.chroma, name, src)
+		}, func() {}, nil
+	case kind == "chroma" && arg != "":
+		if opts.files != 1 {
+			return nil, nil, usagef("engine %s holds the tokens of one file, not %d", spec, opts.files)
+		}
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(_ string, src []byte) ([]lexer.Token, error) {
+			return chromaTokens(src, bytes.NewReader(data))
+		}, func() {}, nil
+	}
+	return nil, nil, usagef("unknown engine %q: want builtin, lexer:name, textmate:file, chroma or chroma:file", spec)
+}
+
+// runChroma tokenizes src with the Chroma command, which picks its lexer by
+// the file name.
+func runChroma(ctx context.Context, command, name string, src []byte) ([]lexer.Token, error) {
+	args := []string{"--formatter", "json"}
+	if name != "-" {
+		args = append(args, "--filename", filepath.Base(name))
+	}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdin = bytes.NewReader(src)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return chromaTokens(src, bytes.NewReader(out))
+}
+
+// chromaTokens reads Chroma's JSON tokens of src from r. Chroma's lexers
+// may add a newline to source that does not end with one, which is cut off
+// the token that ends with it.
+func chromaTokens(src []byte, r io.Reader) ([]lexer.Token, error) {
+	csrc, toks, err := chromacompat.ReadJSON(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(csrc) == len(src)+1 && csrc[len(src)] == '\n' {
+		csrc = csrc[:len(src)]
+		if n := len(toks); n > 0 && toks[n-1].End() > len(src) {
+			if last := &toks[n-1]; len(last.Text) > 1 {
+				last.Text = last.Text[:len(last.Text)-1]
+			} else {
+				toks = toks[:n-1]
+			}
+		}
+	}
+	if !bytes.Equal(csrc, src) {
+		return nil, errors.New("the Chroma tokens are of other source")
+	}
+	return toks, nil
+}
+
+// printDifference writes d, found in the file name, as a file:line:col
+// heading with each engine's tokens, then the source lines around it with
+// the disagreeing text underlined.
+func printDifference(w io.Writer, name string, src []byte, d hldiff.Difference, context int) {
+	line, col := lineCol(src, d.Start)
+	fmt.Fprintf(w, "%s:%d:%d: %s\n", name, line, col, d.Kind)
+	fmt.Fprintf(w, "\ta: %s\n", hldiff.Describe(d.A))
+	fmt.Fprintf(w, "\tb: %s\n", hldiff.Describe(d.B))
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	endLine, _ := lineCol(src, d.End-1)
+	first, last := max(1, line-context), min(len(lines), endLine+context)
+	offset := 0
+	for n := 1; n < first; n++ {
+		offset += len(lines[n-1])
+	}
+	for n := first; n <= last; n++ {
+		text := strings.TrimRight(string(lines[n-1]), "\r\n")
+		fmt.Fprintf(w, "%6d | %s\n", n, text)
+		// Underline the part of the line inside d, keeping tabs so that
+		// the marks line up.
+		start, end := max(d.Start-offset, 0), min(d.End-offset, len(text))
+		if start < end {
+			var marks strings.Builder
+			for _, r := range text[:start] {
+				if r == '\t' {
+					marks.WriteByte('\t')
+				} else {
+					marks.WriteByte(' ')
+				}
+			}
+			marks.WriteString(strings.Repeat("^", utf8.RuneCountInString(text[start:end])))
+			fmt.Fprintf(w, "%6s | %s\n", "", marks.String())
+		}
+		offset += len(lines[n-1])
+	}
+}
+
+// lineCol returns the line and byte column, both from 1, of offset in src.
+func lineCol(src []byte, offset int) (line, col int) {
+	before := src[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	return line, offset - (bytes.LastIndexByte(before, '\n') + 1) + 1
+}
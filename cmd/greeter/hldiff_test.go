@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/chromacompat"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+const hldiffSource = "package main\n\nfunc main() { println(\"hi\", 42) }\n"
+
+// chromaJSON returns the tokens of src as Chroma's json formatter writes
+// them, with the kind of the token with text changed to kind, if any.
+func chromaJSON(t *testing.T, src, text string, kind lexer.Kind) string {
+	t.Helper()
+	toks := lexer.Tokenize([]byte(src))
+	for i := range toks {
+		if toks[i].Text == text {
+			toks[i].Kind = kind
+		}
+	}
+	var b bytes.Buffer
+	if err := chromacompat.WriteJSON(&b, []byte(src), toks); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestHldiffEngines(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "main.go", hldiffSource)
+	same := writeFile(t, dir, "same.json", chromaJSON(t, hldiffSource, "", 0))
+	differs := writeFile(t, dir, "differs.json", chromaJSON(t, hldiffSource, "42", lexer.Ident))
+	// Chroma's lexers end source with a newline if it has none.
+	unterminated := writeFile(t, dir, "unterminated.go", "x := 1 // one")
+	newline := writeFile(t, dir, "newline.json", chromaJSON(t, "x := 1 // one\n", "", 0))
+	other := writeFile(t, dir, "other.json", chromaJSON(t, "package other\n", "", 0))
+	fakeChroma := writeFile(t, dir, "chroma", "#!/bin/sh\n[ \"$1 $2 $3 $4\" = \"--formatter json --filename main.go\" ] || exit 3\ncat >/dev/null\ncat "+same+"\n")
+	if err := os.Chmod(fakeChroma, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		out  string
+	}{
+		{"builtin", []string{"--engine-b", "builtin", file}, 0, ""},
+		{"lexer", []string{"--engine-b", "lexer:go", file}, 0, ""},
+		{"chroma tokens", []string{"--engine-a", "chroma:" + same, file}, 0, ""},
+		{"chroma tokens disagreeing", []string{"--engine-a", "chroma:" + differs, file}, 1, "a: ident"},
+		{"chroma tokens with a newline", []string{"--engine-a", "chroma:" + newline, unterminated}, 0, ""},
+		{"chroma tokens of other source", []string{"--engine-a", "chroma:" + other, file}, 1, ""},
+		{"chroma tokens of two files", []string{"--engine-a", "chroma:" + same, file, file}, 2, ""},
+		{"chroma", []string{"--engine-a", "chroma", "--chroma", fakeChroma, file}, 0, ""},
+		{"unknown lexer", []string{"--engine-a", "lexer:cobol", file}, 1, ""},
+		{"unknown engine", []string{"--engine-a", "pygments", file}, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, stderr := runCLI(t, "", append([]string{"hldiff"}, tt.args...)...)
+			if code != tt.code {
+				t.Fatalf("exit code %d, want %d\n%s%s", code, tt.code, out, stderr)
+			}
+			if !strings.Contains(out, tt.out) {
+				t.Errorf("output\n%s\nwant it to contain %q", out, tt.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/encrypted"
+)
+
+var keysCommand = &command{
+	name: "keys",
+	args: "generate | rotate",
+	summary: "Generate a key for a keyring file, as serve --sign-keys and $GREETER_DB_KEYS take, or rotate the keys of a --db database: " +
+		"put the new key first in $GREETER_DB_KEYS, run keys rotate to encrypt every message with it, then remove the old keys.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		id := fs.String("id", "", "name the generated key `id` (default the date and time)")
+		db := fs.String("db", "", "rotate the keys of `database`, as [bolt:]file or sqlite:file")
+		dryRun := fs.Bool("dry-run", false, "with rotate, list the messages it would encrypt with the first key, and change nothing")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "generate" && args[0] != "rotate" {
+				return usagef("expected generate or rotate")
+			}
+			if err := c.parseTrailingFlags(fs, args[1:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if args[0] == "generate" {
+				keyID := *id
+				if keyID == "" {
+					keyID = time.Now().UTC().Format("20060102T150405")
+				}
+				k, err := seal.GenerateKey(keyID)
+				if err != nil {
+					return err
+				}
+				if _, err := seal.NewKeyring(k); err != nil {
+					return usagef("%v", err)
+				}
+				fmt.Fprintln(c.stdout, k)
+				return nil
+			}
+			if *db == "" {
+				return usagef("--db is required")
+			}
+			if os.Getenv("GREETER_DB_KEYS") == "" {
+				return usagef("set GREETER_DB_KEYS to the keyring file, the new key first")
+			}
+			if err := c.wholeDatabase("keys rotate"); err != nil {
+				return err
+			}
+			open := openStore
+			if *dryRun {
+				open = openStoreCopy
+			}
+			st, err := open(ctx, *db)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			keys, _ := store.As[*encrypted.Store](st)
+			if *dryRun {
+				ms, err := keys.Unrotated(ctx)
+				if err != nil {
+					return err
+				}
+				for _, m := range ms {
+					key, ok := seal.SealedWith(m)
+					if !ok {
+						key = "no key"
+					}
+					fmt.Fprintf(c.stdout, "%s\t%s\n", m.ID, key)
+				}
+				fmt.Fprintf(c.stdout, "would encrypt %d messages with the first key\n", len(ms))
+				return nil
+			}
+			n, err := keys.Rotate(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stdout, "encrypted %d messages with the first key\n", n)
+			return nil
+		}
+	},
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+)
+
+var lexersCommand = &command{
+	name:    "lexers",
+	summary: "List the built-in lexers, the " + lexers.PluginPrefix + "* lexer plugins on the PATH and the lexers of greeter plugins.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if err := c.parseTrailingFlags(fs, args); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			for _, name := range lexers.Names() {
+				fmt.Fprintf(c.stdout, "%-12s built in\n", name)
+			}
+			for _, p := range pluginLexers() {
+				fmt.Fprintf(c.stdout, "%-12s %s\n", p.Name, p.Path)
+			}
+			for _, p := range startedPlugins() {
+				for _, l := range p.Manifest().Lexers {
+					fmt.Fprintf(c.stdout, "%-12s %s\n", l.Name, p.Path())
+				}
+			}
+			return nil
+		}
+	},
+}
+
+// pluginLexers returns the lexer plugins on the PATH.
+func pluginLexers() []lexers.Found {
+	return lexers.Discover(filepath.SplitList(os.Getenv("PATH")))
+}
+
+// openLexer returns the lexer for the language name, built in, a plugin on
+// the PATH or else one provided by a plugin in the plugins directories, and
+// a function stopping it.
+func openLexer(name string) (lexers.Lexer, func(), error) {
+	if l, err := lexers.Lookup(name); err == nil {
+		return l, func() {}, nil
+	}
+	for _, p := range pluginLexers() {
+		if p.Name == name {
+			l, err := lexers.Start(p.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+			return l, func() { l.Close() }, nil
+		}
+	}
+	for _, p := range startedPlugins() {
+		for _, l := range p.Manifest().Lexers {
+			if l.Name == name {
+				return p.Lexer(name), func() {}, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no lexer for %q: run greeter lexers for those there are", name)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor is a minimal readline: cursor movement, history and tab
+// completion on a terminal in raw mode. Without a terminal it just reads
+// lines.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool
+	quiet    bool // no prompt, for input that is not typed by a person
+	history  []string
+	complete func(line string) []string
+}
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if !e.terminal {
+		if !e.quiet {
+			fmt.Fprint(e.out, prompt)
+		}
+		line, err := e.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if err == nil {
+			e.remember(line)
+		}
+		return line, err
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		e.terminal = false
+		return e.readLine(prompt)
+	}
+	defer restore()
+	line, err := e.edit(prompt)
+	fmt.Fprint(e.out, "\r\n")
+	if err == nil {
+		e.remember(line)
+	}
+	return line, err
+}
+
+func (e *lineEditor) remember(line string) {
+	if line = strings.TrimSpace(line); line != "" {
+		if n := len(e.history); n == 0 || e.history[n-1] != line {
+			e.history = append(e.history, line)
+		}
+	}
+}
+
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	hist := len(e.history)
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(buf), err
+		}
+		switch r {
+		case '\r', '\n':
+			return string(buf), nil
+		case 3: // Ctrl-C
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				return "", io.EOF
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 21: // Ctrl-U
+			buf, pos = buf[pos:], 0
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case '\t':
+			buf, pos = e.completeLine(prompt, buf)
+		case 27:
+			buf, pos, hist = e.escape(buf, pos, hist)
+		default:
+			if r >= ' ' && r != utf8.RuneError {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// escape handles the CSI sequences for the arrow, Home, End and Delete keys.
+func (e *lineEditor) escape(buf []rune, pos, hist int) ([]rune, int, int) {
+	if b, err := e.in.ReadByte(); err != nil || b != '[' {
+		return buf, pos, hist
+	}
+	b, err := e.in.ReadByte()
+	if err != nil {
+		return buf, pos, hist
+	}
+	switch b {
+	case 'A', 'B':
+		if b == 'A' && hist > 0 {
+			hist--
+		} else if b == 'B' && hist < len(e.history) {
+			hist++
+		}
+		buf = nil
+		if hist < len(e.history) {
+			buf = []rune(e.history[hist])
+		}
+		pos = len(buf)
+	case 'C':
+		pos = min(pos+1, len(buf))
+	case 'D':
+		pos = max(pos-1, 0)
+	case 'H':
+		pos = 0
+	case 'F':
+		pos = len(buf)
+	case '3':
+		if next, _ := e.in.ReadByte(); next == '~' && pos < len(buf) {
+			buf = append(buf[:pos], buf[pos+1:]...)
+		}
+	}
+	return buf, pos, hist
+}
+
+// completeLine extends buf to the longest common prefix of the candidates,
+// listing them when that does not add anything.
+func (e *lineEditor) completeLine(prompt string, buf []rune) ([]rune, int) {
+	if e.complete == nil {
+		return buf, len(buf)
+	}
+	line := string(buf)
+	matches := e.complete(line)
+	if len(matches) == 0 {
+		return buf, len(buf)
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	for !utf8.ValidString(common) {
+		common = common[:len(common)-1]
+	}
+	if len(common) > len(line) {
+		buf = []rune(common)
+	} else if len(matches) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(matches, "  "))
+	}
+	return buf, len(buf)
+}
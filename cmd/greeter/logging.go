@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+)
+
+// newLogger returns the logger selected by the --verbose, --quiet and
+// --log-format flags. Only warnings and errors are logged by default.
+func (c *cli) newLogger(w io.Writer) (*slog.Logger, error) {
+	if c.verbose && c.quiet {
+		return nil, usagef("--verbose and --quiet are mutually exclusive")
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelWarn}
+	switch {
+	case c.verbose:
+		opts.Level = slog.LevelDebug
+	case c.quiet:
+		opts.Level = slog.LevelError
+	}
+	switch c.logFormat {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, usagef("invalid log format %q: want text or json", c.logFormat)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/render"
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// setup registers the command's flags on fs and returns the function
+	// that runs it with the remaining arguments.
+	setup func(c *cli, fs *flag.FlagSet) func(ctx context.Context, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{greetCommand, messageCommand, replCommand, tuiCommand, watchCommand, serveCommand, exportCommand, importCommand, messagesCommand, migrateCommand, samplesCommand, highlightCommand, markdownCommand, lexersCommand, goldensCommand, corpusCommand, hldiffCommand, detectCommand, fuzzCommand, benchCommand, coverageCommand, profileCommand, pluginsCommand, scheduleCommand, deadLettersCommand, keysCommand, auditCommand, completionCommand, docsCommand, versionCommand, helpCommand}
+}
+
+type cli struct {
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	color     string
+	output    string
+	verbose   bool
+	quiet     bool
+	logFormat string
+	// a11y asks for output for screen readers; see render.Renderer.
+	a11y bool
+	// tenantName and tenantsFile are the --tenant to work for and the
+	// --tenants file of the tenants' settings.
+	tenantName  string
+	tenantsFile string
+}
+
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitCode is returned by commands that have reported their error
+// themselves, to exit with the code without a message.
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (c *cli) printer() (*render.Printer, error) {
+	color, err := c.colorEnabled()
+	if err != nil {
+		return nil, err
+	}
+	return c.printerTo(c.stdout, color)
+}
+
+// printerTo returns a printer of the --output format writing to w, in
+// color if color is set.
+func (c *cli) printerTo(w io.Writer, color bool) (*render.Printer, error) {
+	output, err := render.ParseOutput(c.output)
+	if err != nil {
+		return nil, &usageError{msg: err.Error()}
+	}
+	r := render.NewRenderer(color)
+	r.Accessible = c.a11y
+	p := render.NewPrinter(w, r)
+	p.SetOutput(output)
+	return p, nil
+}
+
+// colorEnabled reports whether --color asks for colored output on stdout,
+// which --a11y rules out, as does a terminal without ANSI escapes unless
+// --color is always.
+func (c *cli) colorEnabled() (bool, error) {
+	mode, err := render.ParseColorMode(c.color)
+	if err != nil {
+		return false, &usageError{msg: err.Error()}
+	}
+	if c.a11y {
+		return false, nil
+	}
+	if f, ok := c.stdout.(*os.File); ok {
+		// Forcing color is taken to mean the terminal shows it, but
+		// detecting it still readies Windows consoles to.
+		ansi := detectTerminal(f).ANSI
+		return mode.Enabled(f) && (ansi || mode == render.ColorAlways), nil
+	}
+	return mode == render.ColorAlways, nil
+}
+
+// readNames expands "-" in args to names read from stdin and appends the
+// names read from the file named by from, if any.
+func (c *cli) readNames(args []string, from string) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		if arg != "-" {
+			names = append(names, arg)
+			continue
+		}
+		read, err := c.readNamesFile("-")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, read...)
+	}
+	if from != "" {
+		read, err := c.readNamesFile(from)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, read...)
+	}
+	return names, nil
+}
+
+func (c *cli) readNamesFile(name string) ([]string, error) {
+	if name == "-" {
+		names, err := greeting.ReadNames(c.stdin)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %w", err)
+		}
+		return names, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := greeting.ReadNames(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return names, nil
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// parseTrailingFlags parses the flags and arguments that follow a
+// subcommand, as in "messages list --limit 5". It prints help itself and
+// returns flag.ErrHelp for it; other errors are usage errors, reported once
+// by run.
+func (c *cli) parseTrailingFlags(fs *flag.FlagSet, args []string) error {
+	rest, err := c.parseTrailingArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected arguments: %v", rest)
+	}
+	return nil
+}
+
+// parseTrailingArgs is like parseTrailingFlags, but returns the arguments
+// after the flags instead of rejecting them.
+func (c *cli) parseTrailingArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	usage := fs.Usage
+	fs.Usage = func() {}
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.SetOutput(c.stderr)
+	fs.Usage = usage
+	if errors.Is(err, flag.ErrHelp) {
+		fs.Usage()
+		return nil, err
+	}
+	if err != nil {
+		return nil, usagef("%v", err)
+	}
+	return fs.Args(), nil
+}
+
+func (c *cli) newFlagSet(cmd *command) (*flag.FlagSet, func(context.Context, []string) error) {
+	fs := flag.NewFlagSet("greeter "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.color, "color", "auto", "colorize output: auto, always or never")
+	fs.StringVar(&c.output, "output", "text", "output format: text, json, jsonl or yaml")
+	fs.BoolVar(&c.verbose, "verbose", false, "log greeting events and timing")
+	fs.BoolVar(&c.quiet, "quiet", false, "log errors only")
+	fs.StringVar(&c.logFormat, "log-format", "text", "log format: text or json")
+	fs.BoolVar(&c.a11y, "a11y", false, "write for screen readers: no color or emoji, plain characters and severities in words")
+	fs.StringVar(&c.tenantName, "tenant", "", "work with the messages and settings of tenant `name`")
+	fs.StringVar(&c.tenantsFile, "tenants", "", "read the tenants' settings from the \"tenant setting value\" lines in `file`")
+	run := cmd.setup(c, fs)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: greeter %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs, run
+}
+
+func (c *cli) usage() {
+	fmt.Fprintf(c.stderr, "usage: greeter <command> [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(c.stderr, "\nRun 'greeter help <command>' for details.\n")
+}
+
+func (c *cli) run(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		c.usage()
+		return 2
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(c.stderr, "greeter: unknown command %q\n\n", args[0])
+		c.usage()
+		return 2
+	}
+	fs, run := c.newFlagSet(cmd)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	config, err := loadConfig()
+	if err == nil {
+		err = applyConfig(fs, config)
+	}
+	if err != nil {
+		fmt.Fprintf(c.stderr, "greeter: %v\n", err)
+		return 2
+	}
+	logger, err := c.newLogger(c.stderr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "greeter %s: %v\n", cmd.name, err)
+		fs.Usage()
+		return 2
+	}
+	slog.SetDefault(logger)
+	tracer, err := trace.NewTracerFromEnv("greeter", c.stderr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "greeter: %v\n", err)
+		return 2
+	}
+	if tracer != nil {
+		trace.SetTracer(tracer)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tracer.Shutdown(shutdownCtx)
+		}()
+	}
+	defer stopPlugins()
+	start := time.Now()
+	ctx, span := trace.Start(ctx, "greeter "+cmd.name)
+	err = run(ctx, fs.Args())
+	span.RecordError(err)
+	span.End()
+	logger.Debug("command finished", "command", cmd.name, "duration", time.Since(start), "ok", err == nil)
+	if err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			return int(code)
+		}
+		var uerr *usageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintf(c.stderr, "greeter %s: %v\n", cmd.name, err)
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(c.stderr, "greeter %s: %v\n", cmd.name, strings.TrimSpace(err.Error()))
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	code := c.run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
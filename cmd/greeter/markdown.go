@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/detect"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/htmlrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/mdcode"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/textmate"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+var markdownCommand = &command{
+	name:    "markdown",
+	args:    "[file|dir ...]",
+	summary: "Highlight the fenced code blocks of Markdown files, or standard input, replacing them with HTML.",
+	setup: func(c *cli, flags *flag.FlagSet) func(context.Context, []string) error {
+		out := flags.String("out", "", "write each document under `dir`, by its path below the directory named, instead of to standard output")
+		grammars := flags.String("grammars", "", "highlight languages other than Go with the .tmLanguage.json grammars in `dir`, by their fileTypes and scope names")
+		inline := flags.Bool("inline-styles", false, "style each token inline instead of with a stylesheet at the top of the document")
+		themeName := flags.String("theme", "", "color tokens with a bundled `theme` ("+strings.Join(theme.Names(), ", ")+") or a theme file (default light)")
+		guess := flags.Bool("detect", false, "highlight blocks without a language tag in the language their content looks like")
+		return func(ctx context.Context, args []string) error {
+			th, err := loadTheme(*themeName)
+			if err != nil {
+				return err
+			}
+			byLang := map[string]*textmate.Grammar{}
+			if *grammars != "" {
+				if byLang, err = loadGrammars(*grammars); err != nil {
+					return err
+				}
+				for _, g := range byLang {
+					// source.python is also python.
+					byLang[g.ScopeName[strings.LastIndex(g.ScopeName, ".")+1:]] = g
+				}
+			}
+			tokenizer := func(lang string) func([]byte) []lexer.Token {
+				lang = strings.ToLower(lang)
+				if g, ok := byLang[lang]; ok {
+					return func(src []byte) []lexer.Token { return g.Tokenize(src, nil) }
+				}
+				if lang == "go" || lang == "golang" {
+					return lexer.Tokenize
+				}
+				return nil
+			}
+			opts := htmlrender.Options{Theme: th, InlineStyles: *inline}
+			process := func(src []byte) []byte {
+				highlighted := 0
+				doc, _ := mdcode.Process(src, func(b mdcode.Block) (string, bool) {
+					lang := b.Lang
+					if lang == "" && *guess {
+						lang = detect.Language("", b.Code).Name
+					}
+					tokenize := tokenizer(lang)
+					if tokenize == nil {
+						return "", false
+					}
+					var html strings.Builder
+					if err := htmlrender.RenderTokens(&html, b.Code, tokenize(b.Code), opts); err != nil {
+						return "", false
+					}
+					highlighted++
+					return html.String(), true
+				})
+				if highlighted > 0 && !opts.InlineStyles {
+					doc = append([]byte("<style>\n"+htmlrender.Stylesheet(opts)+"</style>\n\n"), doc...)
+				}
+				return doc
+			}
+
+			if len(args) == 0 {
+				if *out != "" {
+					return usagef("--out needs files or directories to read")
+				}
+				src, err := io.ReadAll(c.stdin)
+				if err != nil {
+					return err
+				}
+				_, err = c.stdout.Write(process(src))
+				return err
+			}
+			// docs holds each source with its output name below --out.
+			var docs [][2]string
+			for _, arg := range args {
+				info, err := os.Stat(arg)
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() {
+					docs = append(docs, [2]string{arg, filepath.Base(arg)})
+					continue
+				}
+				err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+					if err != nil || d.IsDir() {
+						return err
+					}
+					if ext := strings.ToLower(filepath.Ext(path)); ext == ".md" || ext == ".markdown" {
+						rel, err := filepath.Rel(arg, path)
+						if err != nil {
+							return err
+						}
+						docs = append(docs, [2]string{path, rel})
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if *out == "" && (len(docs) != 1 || len(args) != 1 || docs[0][0] != args[0]) {
+				return usagef("more than one document needs --out")
+			}
+			for _, doc := range docs {
+				src, err := os.ReadFile(doc[0])
+				if err != nil {
+					return err
+				}
+				result := process(src)
+				if *out == "" {
+					_, err := c.stdout.Write(result)
+					return err
+				}
+				name := filepath.Join(*out, doc[1])
+				if same, err := samePath(name, doc[0]); err != nil {
+					return err
+				} else if same {
+					return fmt.Errorf("%s: will not overwrite the source with its output", doc[0])
+				}
+				if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+					return err
+				}
+				if err := os.WriteFile(name, result, 0o644); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.stdout, "wrote %s\n", name)
+			}
+			return nil
+		}
+	},
+}
+
+// samePath reports whether the paths a and b name the same existing file.
+func samePath(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ai, bi), nil
+}
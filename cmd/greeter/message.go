@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+var messageCommand = &command{
+	name:    "message",
+	args:    "<text>...",
+	summary: "Print a message. The text may use templates and :emoji: shortcodes.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		severity := fs.String("severity", "info", "message severity: info, notice, warning or error")
+		sender := fs.String("sender", "", "message sender (default greeter)")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return usagef("message text is required")
+			}
+			m := message.NewMessage(strings.Join(args, " "))
+			if err := m.Severity.UnmarshalText([]byte(*severity)); err != nil {
+				return usagef("%v", err)
+			}
+			if *sender != "" {
+				m.Sender = *sender
+			}
+			t, err := c.currentTenant()
+			if err != nil {
+				return err
+			}
+			m = tenantMessage(t, m)
+			text, err := m.Render(nil)
+			if err != nil {
+				return err
+			}
+			m.Text = text
+			if err := m.Validate(); err != nil {
+				slog.InfoContext(ctx, "message rejected", "error", err)
+				return err
+			}
+			slog.InfoContext(ctx, "message", "id", m.ID, "severity", m.Severity, "sender", m.Sender)
+			p, err := c.printer()
+			if err != nil {
+				return err
+			}
+			return p.Print(m)
+		}
+	},
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+)
+
+var messagesCommand = &command{
+	name:    "messages",
+	args:    "list",
+	summary: "Search the messages in a --db database by sender, time, severity and text.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		db := fs.String("db", "", "read messages from `database`, as [bolt:]file or sqlite:file")
+		sender := fs.String("sender", "", "only messages from `sender`")
+		since := fs.String("since", "", "only messages created after `time`, as RFC 3339, a date or a duration ago such as 24h")
+		until := fs.String("until", "", "only messages created before `time`, like --since")
+		text := fs.String("text", "", "only messages whose text contains every word of `words`, ignoring case")
+		severity := fs.String("severity", "info", "only messages at least this severe: info, notice, warning or error")
+		order := fs.String("order", "newest", "sort order: newest or oldest first")
+		limit := fs.Int("limit", 20, "show at most `n` messages per page, 0 for all")
+		page := fs.Int("page", 1, "show page `n` of the results")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "list" {
+				return usagef("expected list")
+			}
+			if err := c.parseTrailingFlags(fs, args[1:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if *db == "" {
+				return usagef("--db is required")
+			}
+			q := store.Query{Sender: *sender, Text: *text}
+			now := time.Now()
+			var err error
+			if q.Since, err = parseTimeFlag(*since, now); err != nil {
+				return usagef("--since: %v", err)
+			}
+			if q.Until, err = parseTimeFlag(*until, now); err != nil {
+				return usagef("--until: %v", err)
+			}
+			if err := q.MinSeverity.UnmarshalText([]byte(*severity)); err != nil {
+				return usagef("%v", err)
+			}
+			switch *order {
+			case "newest":
+				q.Order = store.NewestFirst
+			case "oldest":
+				q.Order = store.OldestFirst
+			default:
+				return usagef("unknown order %q: want newest or oldest", *order)
+			}
+			if *limit < 0 || *page < 1 {
+				return usagef("--limit must not be negative and --page must be positive")
+			}
+			q.Limit, q.Offset = *limit, (*page-1)**limit
+			st, err := c.openTenantStore(ctx, *db)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			ms, err := st.Find(ctx, q)
+			if err != nil {
+				return err
+			}
+			p, err := c.printer()
+			if err != nil {
+				return err
+			}
+			for _, m := range ms {
+				if err := p.Print(m); err != nil {
+					return err
+				}
+			}
+			if *limit > 0 && len(ms) == *limit {
+				fmt.Fprintf(c.stderr, "more messages may follow: --page %d\n", *page+1)
+			}
+			return nil
+		}
+	},
+}
+
+// parseTimeFlag parses an RFC 3339 time, a 2006-01-02 date in local time,
+// or a duration before now. An empty value is the zero time.
+func parseTimeFlag(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC 3339, a date or a duration", v)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/bolt"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/sqlite"
+)
+
+var migrateCommand = &command{
+	name:    "migrate",
+	summary: "Show or change the schema version of a --db database, which greeter upgrades whenever it opens one.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		db := fs.String("db", "", "migrate `database`, as sqlite:file")
+		to := fs.Int("to", -1, "migrate to schema `version`, downgrading if it is older (default latest)")
+		dryRun := fs.Bool("dry-run", false, "change nothing, but show the migrations that would be applied or reverted")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return usagef("unexpected arguments: %s", strings.Join(args, " "))
+			}
+			if *db == "" {
+				return usagef("--db is required")
+			}
+			if err := c.wholeDatabase("migrate"); err != nil {
+				return err
+			}
+			if *dryRun {
+				return c.planMigration(ctx, *db, *to)
+			}
+			st, err := openStore(ctx, *db)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			m, ok := store.As[store.Migrator](st)
+			if !ok {
+				return fmt.Errorf("%s has no versioned schema", *db)
+			}
+			if *to >= 0 {
+				if err := m.MigrateTo(ctx, *to); err != nil {
+					return err
+				}
+			}
+			version, err := m.SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stdout, "schema version %d of %d\n", version, m.LatestVersion())
+			return nil
+		}
+	},
+}
+
+// planMigration prints the steps migrate would take the database target
+// through to version, or to the latest version if it is negative, as
+// opening it would.
+func (c *cli) planMigration(ctx context.Context, target string, version int) error {
+	scheme, _ := splitTarget(target)
+	if target == bolt.Memory || scheme != "sqlite" {
+		return fmt.Errorf("%s has no versioned schema", target)
+	}
+	copied, remove, err := copyDatabase(target)
+	if err != nil {
+		return err
+	}
+	defer remove()
+	_, path := splitTarget(copied)
+	current, steps, err := sqlite.Plan(ctx, path, version)
+	if err != nil {
+		if !sqliteLinked {
+			return fmt.Errorf("database %q: this greeter was built without -tags sqlite: %w", target, err)
+		}
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Fprintf(c.stdout, "schema version %d, nothing to migrate\n", current)
+		return nil
+	}
+	to := current
+	for _, step := range steps {
+		verb := "apply"
+		to = step.Version
+		if step.Down {
+			verb = "revert"
+			to--
+		}
+		fmt.Fprintf(c.stdout, "would %s migration %d (%s):\n", verb, step.Version, step.Name)
+		for _, line := range strings.Split(strings.TrimSpace(step.SQL), "\n") {
+			fmt.Fprintf(c.stdout, "\t%s\n", line)
+		}
+	}
+	fmt.Fprintf(c.stdout, "would migrate schema version %d to %d\n", current, to)
+	return nil
+}
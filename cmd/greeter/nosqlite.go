@@ -0,0 +1,6 @@
+//go:build !sqlite
+
+package main
+
+// sqliteLinked is whether a SQLite driver is linked in, for error messages.
+const sqliteLinked = false
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/plugin"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+var pluginsCommand = &command{
+	name:    "plugins",
+	summary: "List the plugins in the plugins directories, $GREETER_PLUGINS or greeter/plugins in the config directory, and the greeters, publishers and lexers they provide.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if err := c.parseTrailingFlags(fs, args); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			for _, p := range startedPlugins() {
+				m := p.Manifest()
+				fmt.Fprintf(c.stdout, "%s (%s)\n", m.Name, p.Path())
+				if len(m.Greeters) > 0 {
+					fmt.Fprintf(c.stdout, "  greeters:   %s\n", strings.Join(m.Greeters, ", "))
+				}
+				if len(m.Publishers) > 0 {
+					fmt.Fprintf(c.stdout, "  publishers: %s\n", strings.Join(m.Publishers, ", "))
+				}
+				for _, l := range m.Lexers {
+					fmt.Fprintf(c.stdout, "  lexer:      %s %s\n", l.Name, strings.Join(l.Extensions, " "))
+				}
+			}
+			return nil
+		}
+	},
+}
+
+// pluginDirs returns the plugins directories: those listed in
+// GREETER_PLUGINS, or else greeter/plugins beside the config file.
+func pluginDirs() []string {
+	if dirs := os.Getenv("GREETER_PLUGINS"); dirs != "" {
+		return filepath.SplitList(dirs)
+	}
+	if path := configPath(); path != "" {
+		return []string{filepath.Join(filepath.Dir(path), "plugins")}
+	}
+	return nil
+}
+
+// The plugins are started the first time one is needed, and stopped by
+// stopPlugins when the command is done.
+var (
+	pluginsOnce sync.Once
+	plugins     []*plugin.Plugin
+)
+
+// startedPlugins returns the plugins in the plugins directories, starting
+// them if this is the first call. Plugins that fail to start are logged
+// and left out.
+func startedPlugins() []*plugin.Plugin {
+	pluginsOnce.Do(func() {
+		for _, path := range plugin.Discover(pluginDirs()) {
+			p, err := plugin.Start(path)
+			if err != nil {
+				slog.Warn("plugin failed to start", "path", path, "error", err)
+				continue
+			}
+			plugins = append(plugins, p)
+		}
+	})
+	return plugins
+}
+
+func stopPlugins() {
+	for _, p := range plugins {
+		p.Close()
+	}
+}
+
+// registerPluginGreeters registers the greeters the plugins provide, but
+// for those whose names are taken.
+func registerPluginGreeters() {
+	for _, p := range startedPlugins() {
+		for _, name := range p.Manifest().Greeters {
+			if !slices.Contains(greeting.Greeters(), name) {
+				greeting.Register(name, p.Greeter(name))
+			}
+		}
+	}
+}
+
+// lookupGreeter returns the greeter called style, built in or else
+// provided by a plugin.
+func lookupGreeter(style string) (greeting.Greeter, error) {
+	if g, err := greeting.Lookup(style); err == nil {
+		return g, nil
+	}
+	registerPluginGreeters()
+	return greeting.Lookup(style)
+}
+
+// produce returns g wrapped in greeting.DefaultChain, which every command
+// greets through, so that names are validated and greetings logged alike.
+func produce(g greeting.Greeter) greeting.Greeter {
+	return greeting.Chain(g, greeting.DefaultChain()...)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+)
+
+var profileCommand = &command{
+	name:    "profile",
+	args:    "<command> [flags] [args]",
+	summary: "Run another command, such as a batch greeting or an import, and write CPU and heap profiles of it for go tool pprof.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		cpu := fs.String("cpu", "cpu.pprof", "write the CPU profile to `file`, or none if empty")
+		heap := fs.String("heap", "heap.pprof", "write the heap profile, taken when the command finishes, to `file`, or none if empty")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return usagef("name the command to profile")
+			}
+			if args[0] == "profile" {
+				return usagef("profile cannot profile itself")
+			}
+			if *cpu == "" && *heap == "" {
+				return usagef("--cpu and --heap are both empty: nothing to profile")
+			}
+			if *cpu != "" {
+				f, err := os.Create(*cpu)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				if err := runtimepprof.StartCPUProfile(f); err != nil {
+					return err
+				}
+			}
+			code := c.run(ctx, args)
+			if *cpu != "" {
+				runtimepprof.StopCPUProfile()
+				fmt.Fprintf(c.stderr, "wrote CPU profile to %s\n", *cpu)
+			}
+			if *heap != "" {
+				if err := writeHeapProfile(*heap); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.stderr, "wrote heap profile to %s\n", *heap)
+			}
+			if code != 0 {
+				return exitCode(code)
+			}
+			return nil
+		}
+	},
+}
+
+// writeHeapProfile writes a profile of the memory in use to the file name.
+func writeHeapProfile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Collect garbage first, so that the profile is of what is still in
+	// use.
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// pprofHandler serves the runtime profiles under /debug/pprof/, as
+// net/http/pprof does on http.DefaultServeMux.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/kafka"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/nats"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/tts"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+)
+
+// openPublisher opens the publisher for target, one of
+//
+//	nats://[user:password@]host[:port]/subject[?jetstream=true][&encoding=proto]
+//	tls://... (NATS over TLS)
+//	kafka://broker[:port][,broker...]/topic[?encoding=proto]
+//	file:path (one JSON record per line)
+//	plugin:name (a publisher provided by a plugin)
+//	tts:local[?program=espeak-ng][&voice=cs:Zuzana,...] (speak with a local program)
+//	tts:google[?voice=cs:cs-CZ-Wavenet-A,...][&player=aplay] (speak with Google Cloud
+//	Text-to-Speech, the API key in $GREETER_TTS_KEY)
+//
+// Brokers are sent JSON records unless encoding=proto asks for the
+// protobuf encoding of api/message/v1.
+func openPublisher(target string) (delivery.Publisher, error) {
+	scheme, rest, ok := strings.Cut(target, ":")
+	if !ok {
+		return nil, fmt.Errorf("publish target %q: want nats://, tls://, kafka://, file:, plugin: or tts:", target)
+	}
+	switch scheme {
+	case "nats", "tls":
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		subject := strings.TrimPrefix(u.Path, "/")
+		p, err := nats.New((&url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host}).String(), subject)
+		if err != nil {
+			return nil, err
+		}
+		p.JetStream = u.Query().Get("jetstream") == "true"
+		if p.Encoding, err = delivery.ParseEncoding(u.Query().Get("encoding")); err != nil {
+			return nil, fmt.Errorf("publish target %q: %w", target, err)
+		}
+		return p, nil
+	case "kafka":
+		rest, rawQuery, _ := strings.Cut(rest, "?")
+		q, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("publish target %q: %w", target, err)
+		}
+		enc, err := delivery.ParseEncoding(q.Get("encoding"))
+		if err != nil {
+			return nil, fmt.Errorf("publish target %q: %w", target, err)
+		}
+		brokers, topic, _ := strings.Cut(strings.TrimPrefix(rest, "//"), "/")
+		p, err := kafka.NewProducer(kafka.ParseBrokers(brokers), topic)
+		if err != nil {
+			return nil, err
+		}
+		p.Encoding = enc
+		return p, nil
+	case "file":
+		return delivery.OpenFile(strings.TrimPrefix(rest, "//"))
+	case "plugin":
+		for _, p := range startedPlugins() {
+			if slices.Contains(p.Manifest().Publishers, rest) {
+				return p.Publisher(rest), nil
+			}
+		}
+		return nil, fmt.Errorf("publish target %q: no plugin provides publisher %q: run greeter plugins for those there are", target, rest)
+	case "tts":
+		return openSpeaker(target, rest)
+	}
+	return nil, fmt.Errorf("publish target %q: unknown scheme %q", target, scheme)
+}
+
+// newPublisher opens target for asynchronous publishing, retrying failed
+// publishes retries times. Messages that still fail are sent to the dlq
+// target and recorded in dl, where those are set.
+func newPublisher(target, dlq string, retries int, dl *delivery.DeadLetters) (*delivery.Async, error) {
+	p, err := openPublisher(target)
+	if err != nil {
+		return nil, err
+	}
+	r := &delivery.Retrying{Publisher: p, Retry: delivery.Retries(retries), DeadLetters: dl, Target: target}
+	if log, err := openAuditLog(); err != nil {
+		p.Close()
+		return nil, err
+	} else if log != nil {
+		r.Audit = log
+	}
+	if dlq != "" {
+		if r.DLQ, err = openPublisher(dlq); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return delivery.NewAsync(r), nil
+}
+
+func closePublisher(ctx context.Context, a *delivery.Async, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	return a.Close(ctx)
+}
+
+// openSpeaker opens the tts: publish target, with rest the part after the
+// scheme.
+func openSpeaker(target, rest string) (delivery.Publisher, error) {
+	provider, rawQuery, _ := strings.Cut(rest, "?")
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("publish target %q: %w", target, err)
+	}
+	var p *tts.Publisher
+	switch provider {
+	case "local":
+		local := &tts.Local{Program: q.Get("program")}
+		if local.Program == "" {
+			if local, err = tts.FindLocal(); err != nil {
+				return nil, err
+			}
+		}
+		p = tts.New(local)
+	case "google":
+		cloud := &tts.Cloud{APIKey: os.Getenv("GREETER_TTS_KEY")}
+		if cloud.APIKey == "" {
+			return nil, fmt.Errorf("publish target %q: set GREETER_TTS_KEY to the API key", target)
+		}
+		if player := q.Get("player"); player != "" {
+			cloud.Player = strings.Fields(player)
+		}
+		p = tts.New(cloud)
+	default:
+		return nil, fmt.Errorf("publish target %q: unknown speech provider %q: want local or google", target, provider)
+	}
+	if voices := q.Get("voice"); voices != "" {
+		p.Voices = make(map[string]string)
+		for _, v := range strings.Split(voices, ",") {
+			tag, voice, ok := strings.Cut(v, ":")
+			if !ok {
+				return nil, fmt.Errorf("publish target %q: voice %q: want locale:voice", target, v)
+			}
+			p.Voices[locale.Normalize(tag)] = voice
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/render"
+)
+
+var replCommands = []string{":help", ":history", ":lang", ":quit", ":style"}
+
+var replCommand = &command{
+	name:    "repl",
+	summary: "Greet names typed interactively. Type :help for session commands.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		lang := fs.String("lang", "", "initial greeting language (default from LANG)")
+		style := fs.String("style", "default", "initial greeting style")
+		from := fs.String("from", "", "preload known recipients for completion from `file`")
+		return func(ctx context.Context, args []string) error {
+			p, err := c.printer()
+			if err != nil {
+				return err
+			}
+			s := &replSession{cli: c, printer: p, locale: *lang, style: *style}
+			if _, err := lookupGreeter(s.style); err != nil {
+				return usagef("%v", err)
+			}
+			if *from != "" {
+				if s.known, err = c.readNamesFile(*from); err != nil {
+					return err
+				}
+			}
+			s.editor = &lineEditor{in: bufio.NewReader(c.stdin), out: c.stdout, complete: s.complete, quiet: true}
+			if f, ok := c.stdin.(*os.File); ok {
+				if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+					s.editor.quiet = false
+				}
+				// Editing lines redraws them with ANSI escape sequences.
+				out, ok := c.stdout.(*os.File)
+				if isTerminalFd(int(f.Fd())) && ok && detectTerminal(out).ANSI {
+					s.editor.fd, s.editor.terminal = int(f.Fd()), true
+				}
+			}
+			return s.run(ctx)
+		}
+	},
+}
+
+type replSession struct {
+	cli     *cli
+	printer *render.Printer
+	editor  *lineEditor
+	locale  string
+	style   string
+	known   []string
+}
+
+func (s *replSession) run(ctx context.Context) error {
+	for {
+		line, err := s.editor.readLine("greeter> ")
+		switch {
+		case errors.Is(err, errInterrupted):
+			continue
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			quit, err := s.command(line)
+			if err != nil {
+				fmt.Fprintln(s.cli.stderr, err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+		if err := s.greet(ctx, line); err != nil {
+			fmt.Fprintln(s.cli.stderr, err)
+		}
+	}
+}
+
+func (s *replSession) greet(ctx context.Context, name string) error {
+	g, err := lookupGreeter(s.style)
+	if err != nil {
+		return err
+	}
+	if s.locale != "" {
+		ctx = greeting.ContextWithLocale(ctx, s.locale)
+	}
+	m, err := produce(g).Greet(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(s.known, name) {
+		s.known = append(s.known, name)
+	}
+	return s.printer.Print(m, name)
+}
+
+func (s *replSession) command(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	arg := strings.Join(fields[1:], " ")
+	switch fields[0] {
+	case ":quit", ":q":
+		return true, nil
+	case ":lang":
+		if arg == "" {
+			fmt.Fprintf(s.cli.stdout, "language: %s\n", s.currentLocale())
+			return false, nil
+		}
+		s.locale = arg
+	case ":style":
+		if arg == "" {
+			fmt.Fprintf(s.cli.stdout, "style: %s\n", s.style)
+			return false, nil
+		}
+		if _, err := lookupGreeter(arg); err != nil {
+			return false, err
+		}
+		s.style = arg
+	case ":history":
+		for i, entry := range s.editor.history {
+			fmt.Fprintf(s.cli.stdout, "%4d  %s\n", i+1, entry)
+		}
+	case ":help":
+		fmt.Fprint(s.cli.stdout, `Type a name to greet it. Commands:
+  :lang [locale]  show or change the greeting language
+  :style [name]   show or change the greeting style
+  :history        list previous input
+  :quit           leave the session
+`)
+	default:
+		return false, fmt.Errorf("unknown command %s, try :help", fields[0])
+	}
+	return false, nil
+}
+
+func (s *replSession) currentLocale() string {
+	if s.locale != "" {
+		return s.locale
+	}
+	return greeting.DetectLocale()
+}
+
+func (s *replSession) complete(line string) []string {
+	candidates := s.known
+	prefix := ""
+	switch {
+	case strings.HasPrefix(line, ":lang "):
+		prefix, candidates = ":lang ", greeting.Locales()
+	case strings.HasPrefix(line, ":style "):
+		prefix, candidates = ":style ", greeting.Greeters()
+	case strings.HasPrefix(line, ":"):
+		candidates = replCommands
+	}
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(prefix+c, line) {
+			matches = append(matches, prefix+c)
+		}
+	}
+	return matches
+}
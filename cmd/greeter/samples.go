@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/samples"
+)
+
+var samplesCommand = &command{
+	name:    "samples",
+	args:    "generate|unicode",
+	summary: "Generate code samples for testing syntax highlighting, one directory per language, or the Unicode edge cases, with the tokens each should split into.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		out := fs.String("out", "samples", "write the samples under `dir`, replacing any already there")
+		langs := fs.String("language", "", "generate only these comma-separated `languages` (default all: "+strings.Join(samples.Languages(), ", ")+")")
+		depth := fs.Int("depth", samples.DefaultDepth, "nest the nesting samples `n` levels deep")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 || args[0] != "generate" && args[0] != "unicode" {
+				return usagef("expected generate or unicode")
+			}
+			if err := c.parseTrailingFlags(fs, args[1:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if args[0] == "unicode" {
+				return writeUnicodeCases(c, filepath.Join(*out, "unicode"))
+			}
+			if *depth < 1 || *depth > 100 {
+				return usagef("--depth must be between 1 and 100")
+			}
+			opts := samples.Options{Depth: *depth}
+			if *langs != "" {
+				opts.Languages = strings.Split(*langs, ",")
+			}
+			ss, err := samples.Generate(opts)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			for _, s := range ss {
+				name := filepath.Join(*out, filepath.FromSlash(s.Path()))
+				if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+					return err
+				}
+				if err := os.WriteFile(name, []byte(s.Source), 0o644); err != nil {
+					return err
+				}
+				fmt.Fprintln(c.stdout, name)
+			}
+			return nil
+		}
+	},
+}
+
+// writeUnicodeCases writes each of samples.UnicodeCases to dir, with its
+// expected tokens beside it in a .tokens file.
+func writeUnicodeCases(c *cli, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, uc := range samples.UnicodeCases() {
+		name := filepath.Join(dir, uc.FileName())
+		if err := os.WriteFile(name, []byte(uc.Source), 0o644); err != nil {
+			return err
+		}
+		if err := os.WriteFile(strings.TrimSuffix(name, ".go")+".tokens", []byte(uc.ExpectedTokens()), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, name)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/schedule"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+var scheduleCommand = &command{
+	name:    "schedule",
+	args:    "<cron> | list | rm <id> | run",
+	summary: "Greet the names in a --to file on a cron schedule, such as \"0 9 * * MON-FRI\", kept in the --db database; list or rm schedules, or run them. serve --db runs them too.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		db := fs.String("db", "", "keep schedules in `database`, as [bolt:]file or sqlite:file; a bolt file must not be in use by serve or schedule run")
+		to := fs.String("to", "", "greet the names in `file`, one per line or as CSV, read for each run")
+		style := fs.String("style", "default", "greeting style: "+strings.Join(greeting.Greeters(), ", "))
+		lang := fs.String("lang", "", "greeting language, e.g. fr or cs-CZ (default from LANG where the schedule runs)")
+		tz := fs.String("tz", "", "read the cron expression in time `zone`, such as Europe/Prague (default local)")
+		catchUp := fs.String("catch-up", schedule.CatchUpOnce, "what to do about runs missed while no scheduler ran: skip, once or all")
+		dryRun := fs.Bool("dry-run", false, "when adding or removing a schedule, save nothing, but show how the schedules would change as a diff")
+		return func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				return usagef("expected a cron expression, list, rm or run")
+			}
+			// What follows the cron expression, subcommand or ID is flags.
+			rest := args[1:]
+			var id string
+			if args[0] == "rm" {
+				if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+					return usagef("rm takes a schedule ID")
+				}
+				id, rest = rest[0], rest[1:]
+			}
+			if err := c.parseTrailingFlags(fs, rest); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			if *db == "" {
+				return usagef("--db is required")
+			}
+			var sched store.Schedule
+			if args[0] != "list" && args[0] != "rm" && args[0] != "run" {
+				if *to == "" {
+					return usagef("--to is required")
+				}
+				if _, err := lookupGreeter(*style); err != nil {
+					return usagef("%v", err)
+				}
+				path, err := filepath.Abs(*to)
+				if err != nil {
+					return err
+				}
+				sched = store.Schedule{
+					ID:        newScheduleID(),
+					Cron:      args[0],
+					TimeZone:  *tz,
+					To:        path,
+					Style:     *style,
+					Locale:    *lang,
+					CatchUp:   *catchUp,
+					CreatedAt: time.Now(),
+				}
+				if err := schedule.Validate(sched); err != nil {
+					return usagef("%v", err)
+				}
+			}
+			if err := c.wholeDatabase("schedule"); err != nil {
+				return err
+			}
+			st, err := openStore(ctx, *db)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			ss, ok := store.As[store.ScheduleStore](st)
+			if !ok {
+				return fmt.Errorf("%s: the store does not keep schedules", *db)
+			}
+			if *dryRun && args[0] != "list" && args[0] != "run" {
+				return c.previewSchedules(ctx, ss, *db, sched, id)
+			}
+			switch args[0] {
+			case "list":
+				scheds, err := ss.Schedules(ctx)
+				if err != nil {
+					return err
+				}
+				writeSchedules(c.stdout, scheds, time.Now())
+				return nil
+			case "rm":
+				return ss.DeleteSchedule(ctx, id)
+			case "run":
+				p, err := c.printer()
+				if err != nil {
+					return err
+				}
+				sc := &schedule.Scheduler{Store: ss, Fire: func(ctx context.Context, s store.Schedule, at time.Time) error {
+					ms, err := c.fireSchedule(ctx, s, at)
+					for _, m := range ms {
+						if err := st.Save(ctx, m); err != nil {
+							slog.Error("saving message failed", "id", m.ID, "error", err)
+						}
+						if err := p.Print(m); err != nil {
+							return err
+						}
+					}
+					return err
+				}}
+				fmt.Fprintln(c.stderr, "running schedules")
+				return sc.Run(ctx)
+			}
+			if err := ss.SaveSchedule(ctx, sched); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stdout, "%s  next %s\n", sched.ID, schedule.NextRun(sched, sched.CreatedAt).Format(time.RFC3339))
+			return nil
+		}
+	},
+}
+
+// writeSchedules lists scheds to w, as schedule list does, with their
+// next runs after now.
+func writeSchedules(w io.Writer, scheds []store.Schedule, now time.Time) {
+	for _, s := range scheds {
+		fmt.Fprintf(w, "%s  %-16s  next %s  %s  --style %s", s.ID, s.Cron, schedule.NextRun(s, now).Format(time.RFC3339), s.To, s.Style)
+		if s.TimeZone != "" {
+			fmt.Fprintf(w, " --tz %s", s.TimeZone)
+		}
+		if s.Locale != "" {
+			fmt.Fprintf(w, " --lang %s", s.Locale)
+		}
+		fmt.Fprintf(w, " --catch-up %s\n", s.CatchUp)
+	}
+}
+
+// previewSchedules shows, as a diff of their listing, how the schedules in
+// ss, kept in db, would change by adding add, if it has an ID, or else by
+// removing the schedule with the ID rm.
+func (c *cli) previewSchedules(ctx context.Context, ss store.ScheduleStore, db string, add store.Schedule, rm string) error {
+	scheds, err := ss.Schedules(ctx)
+	if err != nil {
+		return err
+	}
+	after := slices.DeleteFunc(slices.Clone(scheds), func(s store.Schedule) bool { return s.ID == rm })
+	if add.ID != "" {
+		after = append(after, add)
+	} else if len(after) == len(scheds) {
+		return fmt.Errorf("%w: schedule %s", store.ErrNotFound, rm)
+	}
+	now := time.Now()
+	var a, b bytes.Buffer
+	writeSchedules(&a, scheds, now)
+	writeSchedules(&b, after, now)
+	_, err = c.printDiff(db, db+" after schedule", a.Bytes(), b.Bytes())
+	return err
+}
+
+func newScheduleID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// fireSchedule greets the names in s's file for its run at at, returning
+// the greetings made before any error.
+func (c *cli) fireSchedule(ctx context.Context, s store.Schedule, at time.Time) ([]message.Message, error) {
+	g, err := lookupGreeter(s.Style)
+	if err != nil {
+		return nil, err
+	}
+	g = produce(g)
+	names, err := c.readNamesFile(s.To)
+	if err != nil {
+		return nil, err
+	}
+	if s.Locale != "" {
+		ctx = greeting.ContextWithLocale(ctx, s.Locale)
+	}
+	var ms []message.Message
+	for _, name := range names {
+		m, err := g.Greet(ctx, name)
+		if err != nil {
+			return ms, err
+		}
+		m.Tags = append(m.Tags, schedule.Tag+s.ID)
+		slog.InfoContext(ctx, "scheduled greeting", "schedule", s.ID, "at", at, "name", name, "id", m.ID)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/audit"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/cache"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/webhook"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/history"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/schedule"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/server"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	summary: "Serve GET /greet, GET/POST /messages and /graphql, /metrics, health probes and a /ws live feed over HTTP, and the GreeterService over gRPC.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		addr := fs.String("addr", ":8080", "listen `address`")
+		grpcAddr := fs.String("grpc", "", "also serve the gRPC GreeterService on `address`, over HTTP/2, without TLS unless --tls-cert is given")
+		style := fs.String("style", "default", "default greeting style: "+strings.Join(greeting.Greeters(), ", "))
+		keep := fs.Int("history", 1000, "number of messages kept for GET /messages")
+		db := fs.String("db", "", "also keep messages in `database`, as [bolt:]file, sqlite:file or :memory:, restoring the history from it on start and running its schedules")
+		playground := fs.Bool("graphql-playground", false, "serve the GraphiQL playground at /graphql")
+		certFile := fs.String("tls-cert", "", "serve HTTPS with the certificate in `file`")
+		keyFile := fs.String("tls-key", "", "private key `file` for --tls-cert")
+		clientCA := fs.String("tls-client-ca", "", "require client certificates signed by the CAs in `file`")
+		pprofAddr := fs.String("pprof", "", "serve runtime profiles at /debug/pprof/ on `address`, such as localhost:6060, without authentication")
+		shutdownTimeout := fs.Duration("shutdown-timeout", 10*time.Second, "how long to let in-flight requests finish on shutdown")
+		var hooks []webhook.Hook
+		fs.Func("webhook", "post each message to `url`, written as [json=|slack=|discord=]URL; repeatable", func(v string) error {
+			h, err := webhook.ParseHook(v)
+			hooks = append(hooks, h)
+			return err
+		})
+		hookSecret := fs.String("webhook-secret", "", "sign webhook requests with HMAC-SHA256 using `secret`")
+		hookTemplate := fs.String("webhook-template", "", "render webhook payloads with the text/template in `file`")
+		scriptFile := fs.String("script", "", "change or drop each message with the script in `file` before it goes to --webhook and --publish")
+		scriptTimeout := fs.Duration("script-timeout", 100*time.Millisecond, "stop --script after `duration` on a message")
+		publish := fs.String("publish", "", "publish each message to `target`: nats://host/subject, kafka://brokers/topic, file:path, plugin:name or tts:local")
+		dlq := fs.String("publish-dlq", "", "send messages that cannot be published to `target`, like --publish")
+		retries := fs.Int("publish-retries", 3, "retries, with backoff, before a message is given up on or goes to --publish-dlq")
+		signKeys := fs.String("sign-keys", "", "sign every message with the first key of the keyring in `file`, as greeter keys generate writes them, in a sig: tag")
+		deadLetters := fs.String("dead-letters", "", "keep the messages --webhook and --publish give up on in `file`, for greeter dead-letters to requeue")
+		cacheTarget := fs.String("cache", "", "cache rendered greetings in `target`: memory, or redis://host[:port][/db] falling back to memory")
+		cacheTTL := fs.Duration("cache-ttl", 5*time.Minute, "how long cached greetings are kept")
+		bundleDir := fs.String("bundle-dir", "", "greet with the translations of the <locale>.json files in `dir` over the bundled ones, reloaded when they change or on SIGHUP")
+		recent := fs.Int("greeting-cache-size", 1000, "keep up to `n` recent greetings in memory, in front of --cache, or none if 0; SIGHUP drops them")
+		apiKeys := fs.String("api-keys", "", "require an X-API-Key from the \"key subject\" lines in `file`")
+		jwksURL := fs.String("jwks-url", "", "accept bearer JWTs signed by the keys at `url`")
+		jwtIssuer := fs.String("jwt-issuer", "", "require JWTs issued by `issuer`")
+		jwtAudience := fs.String("jwt-audience", "", "require JWTs intended for `audience`")
+		authz := fs.String("authz", "", "authorize callers with the \"subject action [name...]\" rules in `file`")
+		var limit, clientLimit server.Limit
+		fs.TextVar(&limit, "rate-limit", server.Limit{}, "limit all requests to `rate`, as N/s, N/m or N/h with an optional :burst")
+		fs.TextVar(&clientLimit, "client-rate-limit", server.Limit{}, "limit each authenticated caller, or IP address without --api-keys or --jwks-url, to `rate`, like --rate-limit")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return usagef("unexpected arguments: %s", strings.Join(args, " "))
+			}
+			// Requests may ask for any style, so the plugins' are registered
+			// up front.
+			registerPluginGreeters()
+			if _, err := greeting.Lookup(*style); err != nil {
+				return usagef("%v", err)
+			}
+			if *keep < 1 {
+				return usagef("--history must be positive")
+			}
+			if *recent < 0 {
+				return usagef("--greeting-cache-size must not be negative")
+			}
+			if *retries < 0 {
+				return usagef("--publish-retries must not be negative")
+			}
+			if (*certFile == "") != (*keyFile == "") {
+				return usagef("--tls-cert and --tls-key must be given together")
+			}
+			if *clientCA != "" && *certFile == "" {
+				return usagef("--tls-client-ca needs --tls-cert and --tls-key")
+			}
+			if (*jwtIssuer != "" || *jwtAudience != "") && *jwksURL == "" {
+				return usagef("--jwt-issuer and --jwt-audience need --jwks-url")
+			}
+			if *authz != "" && *apiKeys == "" && *jwksURL == "" {
+				return usagef("--authz needs --api-keys or --jwks-url")
+			}
+			var bundle *bundleWatcher
+			if *bundleDir != "" {
+				bundle = &bundleWatcher{dir: *bundleDir}
+				if _, err := bundle.load(true); err != nil {
+					return err
+				}
+			}
+			h := history.New(*keep)
+			s := server.New(h)
+			log, err := openAuditLog()
+			if err != nil {
+				return err
+			}
+			s.Audit = log
+			if *db != "" {
+				// The server logs the messages it records itself, with
+				// the caller and request they were recorded for.
+				st, err := openStoreAudited(ctx, *db, nil)
+				if err != nil {
+					return err
+				}
+				defer st.Close()
+				ms, err := st.List(ctx, *keep)
+				if err != nil {
+					return err
+				}
+				for _, m := range ms {
+					h.Record(m)
+				}
+				s.Store = st
+			}
+			if *signKeys != "" {
+				keys, err := seal.LoadKeyring(*signKeys)
+				if err != nil {
+					return err
+				}
+				s.Keys = keys
+			}
+			s.Style = *style
+			if s.Tenants, err = c.tenants(); err != nil {
+				return err
+			}
+			if c.tenantName != "" {
+				if err := tenant.Validate(c.tenantName); err != nil {
+					return usagef("--tenant: %v", err)
+				}
+				if s.Tenants != nil {
+					if _, err := s.Tenants.Lookup(c.tenantName); err != nil {
+						return usagef("--tenant: %v", err)
+					}
+				}
+			}
+			s.Tenant = c.tenantName
+			s.Version = buildVersion()
+			s.GraphQLPlayground = *playground
+			s.RateLimit, s.ClientRateLimit = limit, clientLimit
+			s.GreetingCacheSize = *recent
+			if *recent == 0 {
+				s.GreetingCacheSize = -1
+			}
+			if *cacheTarget != "" {
+				c, closeCache, err := newCache(*cacheTarget)
+				if err != nil {
+					return usagef("%v", err)
+				}
+				defer closeCache()
+				s.Cache, s.CacheTTL = c, *cacheTTL
+			}
+			authn, err := newAuthenticator(*apiKeys, *jwksURL, *jwtIssuer, *jwtAudience)
+			if err != nil {
+				return err
+			}
+			s.Auth = authn
+			// Without --tenants no one is a member of the --tenant tenant, so
+			// that every authenticated request for it would be refused.
+			if authn != nil && s.Tenant != "" && s.Tenants == nil {
+				return usagef("--tenant with --api-keys or --jwks-url needs --tenants, to list the tenant's subjects")
+			}
+			if *authz != "" {
+				if s.Policy, err = auth.LoadPolicy(*authz); err != nil {
+					return err
+				}
+			}
+			var dl *delivery.DeadLetters
+			if *deadLetters != "" {
+				if dl, err = delivery.OpenDeadLetters(*deadLetters); err != nil {
+					return err
+				}
+			}
+			var sinks []func(message.Message)
+			if len(hooks) > 0 {
+				d, err := newDispatcher(hooks, *hookSecret, *hookTemplate, dl)
+				if err != nil {
+					return err
+				}
+				sinks = append(sinks, d.Send)
+				defer func() {
+					ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+					defer cancel()
+					if err := d.Close(ctx); err != nil {
+						fmt.Fprintln(c.stderr, "greeter: undelivered webhooks abandoned")
+					}
+				}()
+			}
+			if *publish != "" {
+				p, err := newPublisher(*publish, *dlq, *retries, dl)
+				if err != nil {
+					return usagef("%v", err)
+				}
+				sinks = append(sinks, p.Send)
+				defer func() {
+					if err := closePublisher(ctx, p, *shutdownTimeout); err != nil {
+						fmt.Fprintf(c.stderr, "greeter: closing publisher: %v\n", err)
+					}
+				}()
+			} else if *dlq != "" {
+				return usagef("--publish-dlq needs --publish")
+			}
+			if *scriptFile != "" && len(sinks) == 0 {
+				return usagef("--script needs --webhook or --publish")
+			}
+			sc, err := loadScript(*scriptFile, *scriptTimeout)
+			if err != nil {
+				return err
+			}
+			if len(sinks) > 0 {
+				s.OnRecord = func(m message.Message) {
+					if sc != nil {
+						var keep bool
+						var err error
+						if m, keep, err = sc.Run(context.Background(), m); err != nil {
+							// Deliver nothing rather than what the script
+							// meant to change, such as text to redact.
+							slog.Error("message script failed", "id", m.ID, "error", err)
+							return
+						}
+						if !keep {
+							return
+						}
+					}
+					for _, send := range sinks {
+						send(m)
+					}
+				}
+			}
+			tlsConfig, err := serverTLSConfig(*certFile, *keyFile, *clientCA)
+			if err != nil {
+				return err
+			}
+			ln, err := net.Listen("tcp", *addr)
+			if err != nil {
+				return err
+			}
+			if *pprofAddr != "" {
+				// Profiles are served apart from the API, so that they are
+				// not exposed wherever the API is.
+				pln, err := net.Listen("tcp", *pprofAddr)
+				if err != nil {
+					return err
+				}
+				psrv := &http.Server{Handler: pprofHandler()}
+				go psrv.Serve(pln)
+				defer psrv.Close()
+				fmt.Fprintf(c.stderr, "serving profiles on %s\n", pln.Addr())
+			}
+			srv := &http.Server{Handler: s, ConnState: s.ConnState, TLSConfig: tlsConfig}
+			srv.RegisterOnShutdown(s.Close)
+			errc := make(chan error, 2)
+			serveOn := func(srv *http.Server, ln net.Listener) {
+				if tlsConfig != nil {
+					errc <- srv.ServeTLS(ln, "", "")
+				} else {
+					errc <- srv.Serve(ln)
+				}
+			}
+			go serveOn(srv, ln)
+			fmt.Fprintf(c.stderr, "listening on %s\n", ln.Addr())
+			var grpcSrv *http.Server
+			if *grpcAddr != "" {
+				gln, err := net.Listen("tcp", *grpcAddr)
+				if err != nil {
+					return err
+				}
+				// gRPC needs HTTP/2, which clients speak without TLS from
+				// the start.
+				var protocols http.Protocols
+				protocols.SetHTTP2(true)
+				protocols.SetUnencryptedHTTP2(tlsConfig == nil)
+				grpcSrv = &http.Server{Handler: s, ConnState: s.ConnState, TLSConfig: tlsConfig, Protocols: &protocols}
+				go serveOn(grpcSrv, gln)
+				fmt.Fprintf(c.stderr, "serving gRPC on %s\n", gln.Addr())
+			}
+			if ss, ok := store.As[store.ScheduleStore](s.Store); ok {
+				sc := &schedule.Scheduler{Store: ss, Fire: func(ctx context.Context, sched store.Schedule, at time.Time) error {
+					ms, err := c.fireSchedule(ctx, sched, at)
+					actx := audit.ContextWithActor(ctx, "schedule:"+sched.ID)
+					for _, m := range ms {
+						s.RecordContext(actx, m)
+					}
+					return err
+				}}
+				// The scheduler is stopped before the store is closed.
+				schedCtx, stopSchedules := context.WithCancel(ctx)
+				done := make(chan struct{})
+				defer func() {
+					stopSchedules()
+					<-done
+				}()
+				go func() {
+					defer close(done)
+					if err := sc.Run(schedCtx); err != nil {
+						slog.Error("running schedules failed", "error", err)
+					}
+				}()
+			}
+			if bundle != nil {
+				go bundle.run(ctx, bundlePoll, s.InvalidateGreetings)
+			}
+			// SIGHUP drops the cached greetings, for after translations or
+			// templates they were rendered with change, reloading
+			// --bundle-dir first.
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+		wait:
+			for {
+				select {
+				case err := <-errc:
+					return err
+				case <-hup:
+					if bundle != nil {
+						if _, err := bundle.load(true); err != nil {
+							slog.Error("translation bundle rejected", "error", err)
+						}
+					}
+					s.InvalidateGreetings()
+					fmt.Fprintln(c.stderr, "dropped cached greetings")
+				case <-ctx.Done():
+					break wait
+				}
+			}
+			// Stop accepting connections and let in-flight requests finish,
+			// cutting off whatever is left after the timeout.
+			fmt.Fprintln(c.stderr, "shutting down")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancel()
+			if grpcSrv != nil {
+				if err := grpcSrv.Shutdown(shutdownCtx); err != nil {
+					grpcSrv.Close()
+				}
+			}
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				srv.Close()
+				return fmt.Errorf("shutdown: %w", err)
+			}
+			return nil
+		}
+	},
+}
+
+// newDispatcher starts delivering to hooks, signed with secret and
+// rendered with the template in templateFile if set, keeping the messages
+// it gives up on in dl if set.
+func newDispatcher(hooks []webhook.Hook, secret, templateFile string, dl *delivery.DeadLetters) (*webhook.Dispatcher, error) {
+	var tmpl string
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = string(data)
+	}
+	for i := range hooks {
+		hooks[i].Secret = secret
+		if tmpl != "" {
+			err := hooks[i].SetTemplate(tmpl)
+			if err == nil {
+				_, err = hooks[i].Payload(message.NewMessage(""))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", templateFile, err)
+			}
+		}
+	}
+	client := webhook.NewClient()
+	client.DeadLetters = dl
+	log, err := openAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	if log != nil {
+		client.Audit = log
+	}
+	return webhook.NewDispatcher(client, hooks...), nil
+}
+
+const memoryCacheSize = 10000
+
+// newCache returns the greeting cache for target and a function that
+// releases it.
+func newCache(target string) (cache.Cache, func() error, error) {
+	mem := cache.NewMemory(memoryCacheSize)
+	if target == "memory" {
+		return mem, func() error { return nil }, nil
+	}
+	if !strings.HasPrefix(target, "redis://") && !strings.HasPrefix(target, "rediss://") {
+		return nil, nil, fmt.Errorf("cache target %q: want memory, redis:// or rediss://", target)
+	}
+	r, err := cache.NewRedis(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cache.Fallback(r, mem), r.Close, nil
+}
+
+// newAuthenticator returns the authenticators configured by the API key
+// file and JWKS URL, or nil if neither is set.
+func newAuthenticator(keysFile, jwksURL, issuer, audience string) (auth.Authenticator, error) {
+	var auths []auth.Authenticator
+	if keysFile != "" {
+		keys, err := auth.LoadAPIKeys(keysFile)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, keys)
+	}
+	if jwksURL != "" {
+		jwt := auth.NewJWT(jwksURL)
+		jwt.Issuer, jwt.Audience = issuer, audience
+		auths = append(auths, jwt)
+	}
+	switch len(auths) {
+	case 0:
+		return nil, nil
+	case 1:
+		return auths[0], nil
+	}
+	return auth.Chain(auths...), nil
+}
+
+// serverTLSConfig loads the server certificate and, if clientCA is set,
+// the CAs client certificates must be signed by. It returns nil without a
+// certificate.
+func serverTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", clientCA)
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
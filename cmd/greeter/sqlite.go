@@ -0,0 +1,11 @@
+//go:build sqlite
+
+package main
+
+// Building with -tags sqlite links in a pure-Go SQLite driver for --db
+// sqlite:file. The module does not require it, so that the default build
+// needs nothing outside the standard library: go get modernc.org/sqlite
+// first.
+import _ "modernc.org/sqlite"
+
+const sqliteLinked = true
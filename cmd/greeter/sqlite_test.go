@@ -0,0 +1,60 @@
+//go:build sqlite
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+)
+
+func TestMigrateDryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "greetings.sqlite")
+	db := "sqlite:" + path
+	st, err := openPlainStore(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := store.As[store.Migrator](st)
+	if err := m.MigrateTo(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"would apply migration 2 (create schedules):\n", "\tCREATE TABLE schedules (\n", "would migrate schema version 1 to 2\n"}},
+		{[]string{"--to", "0"}, []string{"would revert migration 1 (create messages):\n", "\tDROP TABLE messages\n", "would migrate schema version 1 to 0\n"}},
+		{[]string{"--to", "1"}, []string{"schema version 1, nothing to migrate\n"}},
+	} {
+		args := append([]string{"migrate", "--db", db, "--dry-run"}, tt.args...)
+		code, stdout, stderr := runCLI(t, "", args...)
+		if code != 0 {
+			t.Fatalf("%s exited %d: %s", strings.Join(args, " "), code, stderr)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("%s output does not contain %q:\n%s", strings.Join(args, " "), want, stdout)
+			}
+		}
+	}
+	if after, err := os.ReadFile(path); err != nil || !bytes.Equal(after, before) {
+		t.Errorf("migrate --dry-run changed the database (err %v)", err)
+	}
+	if _, err := os.Stat(path + "-wal"); err == nil {
+		t.Error("migrate --dry-run left a write-ahead log")
+	}
+}
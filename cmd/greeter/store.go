@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/audit"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/bolt"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/encrypted"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/sqlite"
+)
+
+// compactGarbage is the share of a bolt file taken up by deleted messages
+// above which it is compacted when opened.
+const compactGarbage = 0.5
+
+// openStore opens the message store for target, one of
+//
+//	bolt:path (or a bare path)
+//	sqlite:path, in a greeter built with -tags sqlite
+//	:memory:, a private bolt store removed once closed
+//
+// With $GREETER_DB_KEYS set to a keyring file, the text of the messages
+// in it is encrypted, and with $GREETER_AUDIT_LOG set to a file, the
+// messages saved to and deleted from it are logged there.
+func openStore(ctx context.Context, target string) (store.MessageStore, error) {
+	log, err := openAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	return openStoreAudited(ctx, target, log)
+}
+
+// openStoreAudited opens the store as openStore does, logging changes in
+// log if it is not nil.
+func openStoreAudited(ctx context.Context, target string, log *audit.Log) (store.MessageStore, error) {
+	st, err := openPlainStore(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	// Changes are logged beneath the encryption, which reseals messages
+	// on rotation as modifications.
+	if log != nil {
+		st = audit.NewStore(st, log)
+	}
+	if name := os.Getenv("GREETER_DB_KEYS"); name != "" {
+		keys, err := seal.LoadKeyring(name)
+		if err != nil {
+			st.Close()
+			return nil, fmt.Errorf("GREETER_DB_KEYS: %w", err)
+		}
+		return encrypted.New(st, keys), nil
+	}
+	return st, nil
+}
+
+var sharedAudit struct {
+	once sync.Once
+	log  *audit.Log
+	err  error
+}
+
+// openAuditLog opens the audit log named by $GREETER_AUDIT_LOG, once per
+// process, so that its entries are chained in order, or returns nil if it
+// is not set. Entries made outside requests are made by the user running
+// the greeter.
+func openAuditLog() (*audit.Log, error) {
+	sharedAudit.once.Do(func() {
+		name := os.Getenv("GREETER_AUDIT_LOG")
+		if name == "" {
+			return
+		}
+		log, err := audit.Open(name)
+		if err != nil {
+			sharedAudit.err = fmt.Errorf("GREETER_AUDIT_LOG: %w", err)
+			return
+		}
+		log.Actor = "user:unknown"
+		if u, err := user.Current(); err == nil {
+			log.Actor = "user:" + u.Username
+		}
+		sharedAudit.log = log
+	})
+	return sharedAudit.log, sharedAudit.err
+}
+
+func openPlainStore(ctx context.Context, target string) (store.MessageStore, error) {
+	if target == bolt.Memory {
+		return bolt.Open(bolt.Memory)
+	}
+	scheme, path := splitTarget(target)
+	switch scheme {
+	case "sqlite":
+		s, err := sqlite.Open(path)
+		if err != nil && !sqliteLinked {
+			return nil, fmt.Errorf("database %q: this greeter was built without -tags sqlite: %w", target, err)
+		}
+		return s, err
+	case "bolt":
+		s, err := bolt.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		if s.Garbage() > compactGarbage {
+			if err := s.Compact(ctx); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("database %q: unknown scheme %q", target, scheme)
+}
+
+// splitTarget splits a store target other than :memory: into its scheme
+// and path, bolt for a bare path.
+func splitTarget(target string) (scheme, path string) {
+	scheme, path, ok := strings.Cut(target, ":")
+	if !ok {
+		return "bolt", target
+	}
+	return scheme, path
+}
+
+// copyDatabase copies the database files of target, a SQLite database's
+// write-ahead log included, to a temporary directory for dry runs, and
+// returns the target for the copy and a function to remove it. Opening a
+// store can change it, by compacting or upgrading it, so a dry run opens
+// the copy instead. A missing database is copied as a missing one.
+func copyDatabase(target string) (string, func(), error) {
+	if target == bolt.Memory {
+		return target, func() {}, nil
+	}
+	scheme, path := splitTarget(target)
+	dir, err := os.MkdirTemp("", "greeter-dry-run-*")
+	if err != nil {
+		return "", nil, err
+	}
+	remove := func() { os.RemoveAll(dir) }
+	dst := filepath.Join(dir, filepath.Base(path))
+	suffixes := []string{""}
+	if scheme == "sqlite" {
+		suffixes = append(suffixes, "-wal")
+	}
+	for _, suffix := range suffixes {
+		if err := copyFile(dst+suffix, path+suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			remove()
+			return "", nil, err
+		}
+	}
+	return scheme + ":" + dst, remove, nil
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// openStoreCopy opens a copy of the store for target, as openStore does
+// but without the audit log, for a dry run that must leave target as it
+// is. The copy is removed when the store is closed.
+func openStoreCopy(ctx context.Context, target string) (store.MessageStore, error) {
+	copied, remove, err := copyDatabase(target)
+	if err != nil {
+		return nil, err
+	}
+	st, err := openStoreAudited(ctx, copied, nil)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	return &copiedStore{MessageStore: st, remove: remove}, nil
+}
+
+// copiedStore is a store opened by openStoreCopy.
+type copiedStore struct {
+	store.MessageStore
+	remove func()
+}
+
+func (s *copiedStore) Unwrap() store.MessageStore { return s.MessageStore }
+
+func (s *copiedStore) Close() error {
+	err := s.MessageStore.Close()
+	s.remove()
+	return err
+}
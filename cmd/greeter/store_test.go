@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/bolt"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+func TestOpenPlainStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, target := range []string{
+		filepath.Join(dir, "bare.db"),
+		"bolt:" + filepath.Join(dir, "bolt.db"),
+		":memory:",
+		"bolt::memory:",
+	} {
+		st, err := openPlainStore(ctx, target)
+		if err != nil {
+			t.Errorf("%s: %v", target, err)
+			continue
+		}
+		if _, ok := st.(*bolt.Store); !ok {
+			t.Errorf("%s opened a %T, want a bolt store", target, st)
+		}
+		if err := st.Save(ctx, message.NewMessage("hi")); err != nil {
+			t.Errorf("%s: Save: %v", target, err)
+		}
+		st.Close()
+	}
+	if _, err := openPlainStore(ctx, "postgres:x"); err == nil {
+		t.Error("postgres:x opened")
+	}
+	if _, err := openPlainStore(ctx, "sqlite:"+filepath.Join(dir, "x.db")); err == nil && !sqliteLinked {
+		t.Error("sqlite: opened without a driver")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// tenants returns the registry of the --tenants file, or nil without one.
+func (c *cli) tenants() (*tenant.Registry, error) {
+	if c.tenantsFile == "" {
+		return nil, nil
+	}
+	return tenant.Load(c.tenantsFile)
+}
+
+// currentTenant returns the --tenant tenant, with its settings from the
+// --tenants file if there is one, or nil without --tenant.
+func (c *cli) currentTenant() (*tenant.Tenant, error) {
+	if c.tenantName == "" {
+		return nil, nil
+	}
+	if err := tenant.Validate(c.tenantName); err != nil {
+		return nil, usagef("--tenant: %v", err)
+	}
+	reg, err := c.tenants()
+	if err != nil || reg == nil {
+		return &tenant.Tenant{Name: c.tenantName}, err
+	}
+	t, err := reg.Lookup(c.tenantName)
+	if err != nil {
+		return nil, usagef("--tenant: %v", err)
+	}
+	return t, nil
+}
+
+// openTenantStore opens the store for target as openStore does, or the
+// part of it that belongs to the --tenant tenant.
+func (c *cli) openTenantStore(ctx context.Context, target string) (store.MessageStore, error) {
+	if c.tenantName != "" {
+		if err := tenant.Validate(c.tenantName); err != nil {
+			return nil, usagef("--tenant: %v", err)
+		}
+	}
+	st, err := openStore(ctx, target)
+	if err != nil || c.tenantName == "" {
+		return st, err
+	}
+	return tenant.NewStore(st, c.tenantName), nil
+}
+
+// wholeDatabase returns a usage error for commands that work on a whole
+// database, such as its schema or schedules, if --tenant is set.
+func (c *cli) wholeDatabase(cmd string) error {
+	if c.tenantName != "" {
+		return usagef("%s works on the whole database and takes no --tenant", cmd)
+	}
+	return nil
+}
+
+// tenantGreeting applies the settings of the tenant t, if not nil, to the
+// greeting flags neither the command line nor the config gave: its style,
+// language and template. It returns ctx with the tenant's translations.
+func tenantGreeting(ctx context.Context, t *tenant.Tenant, fs *flag.FlagSet, style, lang, format *string) context.Context {
+	if t == nil {
+		return ctx
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["style"] && !given["format"] && t.Template != "" {
+		*format = t.Template
+	} else if !given["style"] && t.Style != "" {
+		*style = t.Style
+	}
+	if !given["lang"] && t.Locale != "" {
+		*lang = t.Locale
+	}
+	if t.Localizer != nil {
+		ctx = greeting.ContextWithLocalizer(ctx, t.Localizer)
+	}
+	return ctx
+}
+
+// tenantMessage returns m as a message of t, or as it is if t is nil.
+func tenantMessage(t *tenant.Tenant, m message.Message) message.Message {
+	if t == nil {
+		return m
+	}
+	return tenant.Set(m, t.Name)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/termrender"
+)
+
+// termCaps are what the terminal output goes to can show.
+type termCaps struct {
+	// ANSI is whether it interprets ANSI escape sequences, for colors and
+	// cursor movement. Output to no terminal, or to one TERM calls dumb,
+	// has none, and so is written plain.
+	ANSI bool
+	// Depth is how many colors it shows.
+	Depth termrender.Depth
+}
+
+// detectTerminal returns the capabilities of the terminal f writes to,
+// turning on the processing of ANSI escape sequences on Windows consoles.
+func detectTerminal(f *os.File) termCaps {
+	caps := termCaps{Depth: termrender.DetectDepth(os.Getenv)}
+	caps.ANSI = os.Getenv("TERM") != "dumb" && ansiTerminal(f)
+	// Windows consoles set no TERM, and show true color once they
+	// interpret escape sequences at all.
+	if caps.ANSI && runtime.GOOS == "windows" && os.Getenv("TERM") == "" {
+		caps.Depth = termrender.DepthTrueColor
+	}
+	return caps
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// ansiTerminal reports whether f is a terminal, all of which interpret
+// ANSI escape sequences outside Windows.
+func ansiTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !windows
+
+package main
+
+import "errors"
+
+func isTerminalFd(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func terminalSize(fd int) (width, height int, err error) {
+	return 0, 0, errors.New("terminal size is not supported on this platform")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Console modes, from wincon.h.
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+func isTerminalFd(fd int) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+// ansiTerminal reports whether f is a console that interprets ANSI escape
+// sequences, turning their processing on first. Consoles older than
+// Windows 10 cannot, and show the sequences as they are.
+func ansiTerminal(f *os.File) bool {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if syscall.GetConsoleMode(h, &mode) != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	return setConsoleMode(h, mode|enableVirtualTerminalProcessing) == nil
+}
+
+// makeRaw switches the console to raw input mode, with keys such as the
+// arrows read as the escape sequences terminals send for them.
+func makeRaw(fd int) (restore func(), err error) {
+	h := syscall.Handle(fd)
+	var old uint32
+	if err := syscall.GetConsoleMode(h, &old); err != nil {
+		return nil, err
+	}
+	raw := old&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if err := setConsoleMode(h, raw); err != nil {
+		return nil, err
+	}
+	return func() { setConsoleMode(h, old) }, nil
+}
+
+func setConsoleMode(h syscall.Handle, mode uint32) error {
+	if r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode)); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// consoleScreenBufferInfo is CONSOLE_SCREEN_BUFFER_INFO, of which only the
+// window matters here.
+type consoleScreenBufferInfo struct {
+	size, cursor             [2]int16
+	attributes               uint16
+	left, top, right, bottom int16
+	maxWindow                [2]int16
+}
+
+func terminalSize(fd int) (width, height int, err error) {
+	var info consoleScreenBufferInfo
+	if r, _, err := procGetConsoleScreenBufferInfo.Call(uintptr(fd), uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0, 0, err
+	}
+	return int(info.right-info.left) + 1, int(info.bottom-info.top) + 1, nil
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/compress"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/pipeline"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// messageFields are the CSV columns of a message, in their default order.
+var messageFields = []string{"id", "created_at", "sender", "severity", "tags", "text"}
+
+var exportCommand = &command{
+	name:    "export",
+	summary: "Write the messages in a --db database as JSON lines or CSV, gzipped with --compress or an --out file ending in .gz.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		db := fs.String("db", "", "read messages from `database`, as [bolt:]file or sqlite:file")
+		format := fs.String("format", "jsonl", "output format: jsonl or csv")
+		out := fs.String("out", "", "write to `file` instead of standard output")
+		compression := fs.String("compress", "auto", "compress the output: none, gzip, or auto for gzip if --out ends in .gz")
+		columns := columnFlag(fs, "write the CSV column `field=header`, in the order given; repeatable (default all fields)")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return usagef("unexpected arguments: %s", strings.Join(args, " "))
+			}
+			if *db == "" {
+				return usagef("--db is required")
+			}
+			if *format != "jsonl" && *format != "csv" {
+				return usagef("unknown format %q: want jsonl or csv", *format)
+			}
+			algo := compress.ForName(*out)
+			if *compression != "auto" {
+				var err error
+				if algo, err = compress.Parse(*compression); err != nil {
+					return usagef("--compress: %v", err)
+				}
+			} else if algo == compress.Zstd {
+				return usagef("--out: %v", compress.ErrZstd)
+			}
+			st, err := c.openTenantStore(ctx, *db)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			ms, err := st.List(ctx, -1)
+			if err != nil {
+				return err
+			}
+			w := c.stdout
+			if *out != "" {
+				f, err := os.Create(*out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			bw := bufio.NewWriter(w)
+			zw, err := compress.NewWriter(bw, algo)
+			if err != nil {
+				return err
+			}
+			if *format == "csv" {
+				err = exportCSV(zw, ms, columns.orDefault())
+			} else {
+				err = exportJSONL(zw, ms)
+			}
+			if err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+	},
+}
+
+var importCommand = &command{
+	name:    "import",
+	args:    "<file>",
+	summary: "Read messages from a JSON lines or CSV file, or - for standard input, into a --db database. Gzipped files are decompressed as they are read.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		db := fs.String("db", "", "write messages to `database`, as [bolt:]file or sqlite:file")
+		format := fs.String("format", "", "input format: jsonl or csv (default from the file extension)")
+		dryRun := fs.Bool("dry-run", false, "save no messages, but show what importing would change in the --db database as a diff, or with no --db only check them")
+		workers := fs.Int("workers", 0, "decode and check messages on `n` goroutines (default one per core)")
+		columns := columnFlag(fs, "map a CSV column to a message field, written as `field=header`; repeatable (default columns named after fields)")
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return usagef("expected exactly one file")
+			}
+			if *db == "" && !*dryRun {
+				return usagef("--db is required without --dry-run")
+			}
+			name := args[0]
+			if *format == "" {
+				*format = strings.TrimPrefix(filepath.Ext(compress.TrimExt(name)), ".")
+			}
+			if *format != "jsonl" && *format != "csv" {
+				return usagef("unknown format %q: want jsonl or csv, or name the --format", *format)
+			}
+			var r io.Reader = c.stdin
+			if name != "-" {
+				f, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			zr, err := compress.NewReader(r)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			defer zr.Close()
+			r = zr
+			save := func(int, message.Message) error { return nil }
+			var added, changed int
+			if *db != "" {
+				st, err := c.openTenantStore(ctx, *db)
+				if err != nil {
+					return err
+				}
+				defer st.Close()
+				save = func(_ int, m message.Message) error { return st.Save(ctx, m) }
+				if *dryRun {
+					save = func(line int, m message.Message) error {
+						old, err := st.Get(ctx, m.ID)
+						if errors.Is(err, store.ErrNotFound) {
+							old, err = message.Message{}, nil
+						}
+						if err != nil {
+							return err
+						}
+						// The store would make the message the tenant's.
+						if c.tenantName != "" {
+							m = tenant.Set(m, c.tenantName)
+						}
+						differs, err := c.printDiff(*db+" "+m.ID, fmt.Sprintf("%s:%d", name, line), messageLines(old), messageLines(m))
+						switch {
+						case !differs:
+						case old.ID == "":
+							added++
+						default:
+							changed++
+						}
+						return err
+					}
+				}
+			}
+			// Messages are decoded and checked on the workers, and saved
+			// in the order they were read.
+			type decoded struct {
+				line int
+				m    message.Message
+			}
+			var n int
+			p := pipeline.Start(ctx, pipeline.Options{Workers: *workers, Ordered: true},
+				func(ctx context.Context, in importedLine) (decoded, error) {
+					m, err := in.decode()
+					if err == nil {
+						err = m.Validate()
+					}
+					return decoded{in.line, m}, err
+				},
+				func(r pipeline.Result[decoded]) error {
+					err := r.Err
+					if err == nil {
+						err = save(r.Value.line, r.Value.m)
+					}
+					if err != nil {
+						return fmt.Errorf("%s:%d: %w", name, r.Value.line, err)
+					}
+					n++
+					return nil
+				})
+			each := func(line int, decode func() (message.Message, error)) error {
+				return p.Submit(importedLine{line, decode})
+			}
+			if *format == "csv" {
+				err = importCSV(r, columns, each)
+			} else {
+				err = importJSONL(r, each)
+			}
+			if werr := p.Wait(); werr != nil {
+				err = werr
+			}
+			switch {
+			case *dryRun && *db != "":
+				fmt.Fprintf(c.stderr, "would import %d messages: %d new, %d changed\n", n, added, changed)
+			case *dryRun:
+				fmt.Fprintf(c.stderr, "checked %d messages\n", n)
+			default:
+				fmt.Fprintf(c.stderr, "imported %d messages\n", n)
+			}
+			return err
+		}
+	},
+}
+
+// importedLine is a line of an imported file, with a function decoding its
+// message.
+type importedLine struct {
+	line   int
+	decode func() (message.Message, error)
+}
+
+// columns maps message fields to CSV headers, in column order.
+type columns []struct{ field, header string }
+
+func columnFlag(fs *flag.FlagSet, usage string) *columns {
+	var cols columns
+	fs.Func("column", usage, func(v string) error {
+		field, header, ok := strings.Cut(v, "=")
+		if !ok {
+			header = field
+		}
+		if !isMessageField(field) || header == "" {
+			return fmt.Errorf("want field=header with a field of %s", strings.Join(messageFields, ", "))
+		}
+		cols = append(cols, struct{ field, header string }{field, header})
+		return nil
+	})
+	return &cols
+}
+
+func (cols *columns) orDefault() columns {
+	if len(*cols) > 0 {
+		return *cols
+	}
+	var all columns
+	for _, f := range messageFields {
+		all = append(all, struct{ field, header string }{f, f})
+	}
+	return all
+}
+
+func isMessageField(field string) bool {
+	for _, f := range messageFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func exportJSONL(w io.Writer, ms []message.Message) error {
+	enc := json.NewEncoder(w)
+	for _, m := range ms {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportCSV(w io.Writer, ms []message.Message, cols columns) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(cols))
+	for i, col := range cols {
+		record[i] = col.header
+	}
+	cw.Write(record)
+	for _, m := range ms {
+		for i, col := range cols {
+			record[i] = fieldValue(m, col.field)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func fieldValue(m message.Message, field string) string {
+	switch field {
+	case "id":
+		return m.ID
+	case "created_at":
+		return m.CreatedAt.Format(time.RFC3339Nano)
+	case "sender":
+		return m.Sender
+	case "severity":
+		return m.Severity.String()
+	case "tags":
+		return strings.Join(m.Tags, ",")
+	}
+	return m.Text
+}
+
+func setField(m *message.Message, field, value string) error {
+	switch field {
+	case "id":
+		m.ID = value
+	case "created_at":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("created_at: %w", err)
+		}
+		m.CreatedAt = t
+	case "sender":
+		m.Sender = value
+	case "severity":
+		return m.Severity.UnmarshalText([]byte(value))
+	case "tags":
+		m.Tags = nil
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+	case "text":
+		m.Text = value
+	}
+	return nil
+}
+
+// withDefaults fills in the ID, creation time and sender an imported
+// message left out, as POST /messages does.
+func withDefaults(m message.Message) message.Message {
+	defaults := message.NewMessage(m.Text)
+	if m.ID == "" {
+		m.ID = defaults.ID
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = defaults.CreatedAt
+	}
+	if m.Sender == "" {
+		m.Sender = defaults.Sender
+	}
+	return m
+}
+
+// importCSV calls each with every row of r, read one at a time, and a
+// function decoding its message, stopping at the first error each returns.
+// The first row names the columns.
+func importCSV(r io.Reader, cols *columns, each func(line int, decode func() (message.Message, error)) error) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	fieldOf := make(map[string]string)
+	for _, f := range messageFields {
+		fieldOf[f] = f
+	}
+	for _, col := range *cols {
+		fieldOf[col.header] = col.field
+	}
+	fields := make([]string, len(header))
+	hasText := false
+	for i, h := range header {
+		fields[i] = fieldOf[strings.TrimSpace(h)]
+		hasText = hasText || fields[i] == "text"
+	}
+	if !hasText {
+		return errors.New("CSV has no text column; map one with --column text=HEADER")
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return err
+		}
+		// The record is reused by the next Read.
+		record = slices.Clone(record)
+		decode := func() (message.Message, error) {
+			var m message.Message
+			for i, value := range record {
+				if fields[i] != "" {
+					if err := setField(&m, fields[i], value); err != nil {
+						return m, err
+					}
+				}
+			}
+			return withDefaults(m), nil
+		}
+		if err := each(line, decode); err != nil {
+			return err
+		}
+	}
+}
+
+// importJSONL calls each with every line of r and a function decoding its
+// message, stopping at the first error each returns. Blank lines are
+// skipped.
+func importJSONL(r io.Reader, each func(line int, decode func() (message.Message, error)) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		data = bytes.Clone(data)
+		decode := func() (message.Message, error) {
+			m, err := message.UnmarshalMessage(data, message.FormatJSON, true)
+			return withDefaults(m), err
+		}
+		if err := each(line, decode); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
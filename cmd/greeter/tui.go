@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/history"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/render"
+)
+
+// rateWindow is how far back the messages/sec figure looks.
+const rateWindow = 10 * time.Second
+
+var tuiCommand = &command{
+	name:    "tui",
+	summary: "Full-screen dashboard: a message feed, name input, language selector and live stats.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		lang := fs.String("lang", "", "initially selected language (default from LANG)")
+		style := fs.String("style", "default", "greeting style: "+strings.Join(greeting.Greeters(), ", "))
+		return func(ctx context.Context, args []string) error {
+			in, inOK := c.stdin.(*os.File)
+			out, outOK := c.stdout.(*os.File)
+			if !inOK || !outOK || !isTerminalFd(int(in.Fd())) || !isTerminalFd(int(out.Fd())) {
+				return errors.New("tui needs a terminal on stdin and stdout; use repl instead")
+			}
+			if !detectTerminal(out).ANSI {
+				return errors.New("tui needs a terminal that interprets ANSI escape sequences; use repl instead")
+			}
+			g, err := lookupGreeter(*style)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			mode, err := render.ParseColorMode(c.color)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			r := render.NewRenderer(mode.Enabled(out) && !c.a11y)
+			r.Accessible = c.a11y
+			t := &tui{
+				in:         in,
+				out:        out,
+				greeter:    produce(g),
+				style:      *style,
+				renderer:   r,
+				accessible: c.a11y,
+				locales:    greeting.Locales(),
+				feed:       history.New(1000),
+			}
+			t.selectLocale(*lang)
+			return t.run(ctx)
+		}
+	},
+}
+
+type tui struct {
+	in       *os.File
+	out      *os.File
+	greeter  greeting.Greeter
+	style    string
+	renderer *render.Renderer
+	// accessible draws without styles, marking the selected language in
+	// brackets, for screen readers.
+	accessible bool
+	locales    []string
+	locale     int
+	input      []rune
+	feed       *history.Store
+	total      int
+	sent       []time.Time
+	status     string
+}
+
+// selectLocale selects the bundled language matching locale, falling back
+// to English.
+func (t *tui) selectLocale(locale string) {
+	if locale == "" {
+		locale = greeting.DetectLocale()
+	}
+	i := slices.IndexFunc(t.locales, func(l string) bool {
+		return l == locale || strings.HasPrefix(locale, l+"-")
+	})
+	if i < 0 {
+		i = max(slices.Index(t.locales, "en"), 0)
+	}
+	t.locale = i
+}
+
+func (t *tui) run(ctx context.Context) error {
+	restore, err := makeRaw(int(t.in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Fprint(t.out, "\x1b[?1049h")
+	defer fmt.Fprint(t.out, "\x1b[?1049l")
+
+	keys := make(chan rune)
+	go func() {
+		r := bufio.NewReader(t.in)
+		for {
+			key, _, err := r.ReadRune()
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- key
+		}
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		t.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case key, ok := <-keys:
+			if !ok || t.key(ctx, key, keys) {
+				return nil
+			}
+		}
+	}
+}
+
+// key handles one key press and reports whether the user asked to quit.
+func (t *tui) key(ctx context.Context, key rune, keys <-chan rune) (quit bool) {
+	switch key {
+	case 3, 4: // Ctrl-C, Ctrl-D
+		return true
+	case '\r', '\n':
+		t.submit(ctx)
+	case 127, 8: // Backspace
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case 21: // Ctrl-U
+		t.input = t.input[:0]
+	case '\t':
+		t.cycleLocale(1)
+	case 27:
+		return t.escape(keys)
+	default:
+		if unicode.IsPrint(key) {
+			t.input = append(t.input, key)
+		}
+	}
+	return false
+}
+
+// escape handles the rest of an escape sequence. A lone Esc quits.
+func (t *tui) escape(keys <-chan rune) (quit bool) {
+	next := func() rune {
+		select {
+		case r := <-keys:
+			return r
+		case <-time.After(25 * time.Millisecond):
+			return 0
+		}
+	}
+	switch next() {
+	case 0:
+		return true
+	case '[':
+		switch next() {
+		case 'C': // Right
+			t.cycleLocale(1)
+		case 'D', 'Z': // Left, Shift-Tab
+			t.cycleLocale(-1)
+		}
+	}
+	return false
+}
+
+func (t *tui) cycleLocale(step int) {
+	t.locale = (t.locale + step + len(t.locales)) % len(t.locales)
+}
+
+func (t *tui) submit(ctx context.Context) {
+	name := strings.TrimSpace(string(t.input))
+	t.input = t.input[:0]
+	if name == "" {
+		return
+	}
+	ctx = greeting.ContextWithLocale(ctx, t.locales[t.locale])
+	m, err := t.greeter.Greet(ctx, name)
+	if err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.status = ""
+	t.feed.Record(m)
+	t.total++
+	t.sent = append(t.sent, m.CreatedAt)
+}
+
+// rate returns the messages per second over the last rateWindow.
+func (t *tui) rate() float64 {
+	cutoff := time.Now().Add(-rateWindow)
+	i := 0
+	for i < len(t.sent) && t.sent[i].Before(cutoff) {
+		i++
+	}
+	t.sent = t.sent[i:]
+	return float64(len(t.sent)) / rateWindow.Seconds()
+}
+
+// draw repaints the whole screen: the language selector on the first row,
+// the feed filling the middle, then the stats and input rows.
+func (t *tui) draw() {
+	width, height, err := terminalSize(int(t.out.Fd()))
+	if err != nil || width < 20 || height < 4 {
+		width, height = 80, 24
+	}
+	w := bufio.NewWriter(t.out)
+	row := func(n int, text string) {
+		fmt.Fprintf(w, "\x1b[%d;1H%s\x1b[0m\x1b[K", n, text)
+	}
+	// sgr is the escape setting the graphic rendition params, or nothing
+	// when drawing accessibly.
+	sgr := func(params string) string {
+		if t.accessible {
+			return ""
+		}
+		return "\x1b[" + params + "m"
+	}
+
+	var header strings.Builder
+	header.WriteString(sgr("1") + "greeter" + sgr("0") + " ")
+	if t.accessible {
+		header.WriteString(" language:")
+	}
+	for i, l := range t.locales {
+		switch {
+		case i == t.locale && t.accessible:
+			fmt.Fprintf(&header, "[%s]", l)
+		case i == t.locale:
+			fmt.Fprintf(&header, "\x1b[7m %s \x1b[0m", l)
+		default:
+			fmt.Fprintf(&header, " %s ", l)
+		}
+	}
+	if t.accessible {
+		header.WriteString("  tab or arrows change the language, escape quits")
+	} else {
+		header.WriteString("  \x1b[2mtab/arrows: language, esc: quit")
+	}
+	row(1, header.String())
+
+	feedRows := height - 3
+	messages := t.feed.Last(feedRows)
+	for i := 0; i < feedRows; i++ {
+		text := ""
+		if j := i - (feedRows - len(messages)); j >= 0 {
+			m := messages[j]
+			m.Text = t.truncate(m.Text, width)
+			text = t.renderer.Render(m)
+		}
+		row(2+i, text)
+	}
+
+	stats := fmt.Sprintf("%d messages  %.1f/s  style %s", t.total, t.rate(), t.style)
+	if t.accessible {
+		stats = fmt.Sprintf("%d messages, %.1f a second, style %s", t.total, t.rate(), t.style)
+	}
+	stats = t.truncate(stats, width)
+	if rest := width - len([]rune(stats)) - 2; t.status != "" && rest > 0 {
+		status := t.status
+		if t.accessible {
+			status = "Error: " + status
+		}
+		stats += "  " + sgr("31") + t.truncate(status, rest)
+	}
+	row(height-1, sgr("2")+stats)
+	row(height, "> "+t.truncate(string(t.input), width-3))
+	w.Flush()
+}
+
+// truncate cuts s to width runes, ending it with an ellipsis if cut,
+// written as three dots when drawing accessibly.
+func (t *tui) truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	ellipsis := []rune("…")
+	if t.accessible {
+		ellipsis = []rune("...")
+	}
+	if width < len(ellipsis) {
+		return ""
+	}
+	return string(runes[:width-len(ellipsis)]) + string(ellipsis)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+var watchCommand = &command{
+	name:    "watch",
+	args:    "<file>",
+	summary: "Greet every name appended to a file, like tail -f.",
+	setup: func(c *cli, fs *flag.FlagSet) func(context.Context, []string) error {
+		lang := fs.String("lang", "", "greeting language, e.g. fr or cs-CZ (default from LANG)")
+		style := fs.String("style", "default", "greeting style: "+strings.Join(greeting.Greeters(), ", "))
+		interval := fs.Duration("interval", 500*time.Millisecond, "how often to check the file for new lines")
+		debounce := fs.Duration("debounce", 200*time.Millisecond, "wait until the file has been unchanged this long before reading")
+		state := fs.String("state", "", "remember the read offset in `file` and resume from it on restart")
+		fromStart := fs.Bool("from-start", false, "greet the names already in the file instead of only new ones")
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return usagef("exactly one file is required")
+			}
+			if *interval <= 0 {
+				return usagef("--interval must be positive")
+			}
+			g, err := lookupGreeter(*style)
+			if err != nil {
+				return usagef("%v", err)
+			}
+			g = produce(g)
+			p, err := c.printer()
+			if err != nil {
+				return err
+			}
+			if *lang != "" {
+				ctx = greeting.ContextWithLocale(ctx, *lang)
+			}
+			w := &watcher{path: args[0], state: *state}
+			if err := w.start(*fromStart); err != nil {
+				return err
+			}
+			return w.run(ctx, *interval, *debounce, func(name string) {
+				m, err := g.Greet(ctx, name)
+				if err == nil {
+					err = p.Print(m, name)
+				}
+				if err != nil {
+					fmt.Fprintln(c.stderr, err)
+				}
+			})
+		}
+	},
+}
+
+// watcher follows a spool file by polling its size. offset is the position
+// just past the last complete line handed out, so a partially written line
+// is read again once its newline arrives.
+type watcher struct {
+	path   string
+	state  string
+	offset int64
+}
+
+// start sets the initial offset: the one saved in the state file if there
+// is one, otherwise the start or the current end of the file.
+func (w *watcher) start(fromStart bool) error {
+	if w.state != "" {
+		b, err := os.ReadFile(w.state)
+		switch {
+		case err == nil:
+			offset, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+			if err != nil || offset < 0 {
+				return fmt.Errorf("%s: invalid offset %q", w.state, strings.TrimSpace(string(b)))
+			}
+			w.offset = offset
+			return nil
+		case !errors.Is(err, os.ErrNotExist):
+			return err
+		}
+	}
+	if fromStart {
+		return nil
+	}
+	info, err := os.Stat(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.offset = info.Size()
+	return nil
+}
+
+func (w *watcher) run(ctx context.Context, interval, debounce time.Duration, greet func(name string)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastSize, readSize := int64(-1), int64(-1)
+	var changed time.Time
+	for {
+		info, err := os.Stat(w.path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return err
+		case info.Size() != lastSize:
+			lastSize, changed = info.Size(), time.Now()
+		case lastSize != w.offset && lastSize != readSize && time.Since(changed) >= debounce:
+			if lastSize < w.offset {
+				slog.InfoContext(ctx, "file truncated, reading from the start", "path", w.path)
+				w.offset = 0
+			}
+			names, err := w.read()
+			if err != nil {
+				return err
+			}
+			readSize = lastSize
+			for _, name := range names {
+				greet(name)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// read returns the names on the complete lines after the offset and moves
+// the offset past them.
+func (w *watcher) read() ([]string, error) {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(w.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	end := bytes.LastIndexByte(b, '\n')
+	if end < 0 {
+		return nil, nil
+	}
+	names, err := greeting.ReadNames(bytes.NewReader(b[:end+1]))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", w.path, err)
+	}
+	w.offset += int64(end + 1)
+	return names, w.save()
+}
+
+func (w *watcher) save() error {
+	if w.state == "" {
+		return nil
+	}
+	tmp := w.state + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(w.offset, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.state)
+}
@@ -0,0 +1,3 @@
+module github.com/fanda-blazek/syntax-highlighting-test
+
+go 1.24
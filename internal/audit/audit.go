@@ -0,0 +1,269 @@
+// Package audit keeps an append-only log of who created, modified,
+// delivered and deleted each message, and when, for running the greeter
+// where every change to messages must be accounted for.
+//
+// The log is a file of JSON entries, one per line, each holding the hash
+// of the one before it, so that an entry changed or removed after it was
+// written breaks the chain from there on, which Verify reports. Nothing
+// here rewrites the file; making it append-only for the operating system
+// too, as with chattr +a, is up to whoever runs the greeter.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Action is what was done to a message.
+type Action string
+
+const (
+	Create  Action = "create"
+	Modify  Action = "modify"
+	Deliver Action = "deliver"
+	Delete  Action = "delete"
+)
+
+// Entry is one operation on a message.
+type Entry struct {
+	// Seq numbers the entries of a log from 1.
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action Action    `json:"action"`
+	// MessageID is the message acted on.
+	MessageID string `json:"message_id"`
+	// RequestID is the ID of the HTTP request that asked for the action,
+	// if one did.
+	RequestID string `json:"request_id,omitempty"`
+	// Target, Attempts and Error describe a delivery: where it went, as a
+	// dead letter names it, after how many attempts, and why it failed if
+	// it did.
+	Target   string `json:"target,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Prev is the hash of the entry before, empty for the first, and Hash
+	// that of this entry with Prev but without Hash.
+	Prev string `json:"prev,omitempty"`
+	Hash string `json:"hash"`
+}
+
+// ErrTampered is returned by Verify for a log whose hash chain is broken.
+var ErrTampered = errors.New("audit: log was changed after it was written")
+
+// Log is an audit log in a file. Processes may append to the same file one
+// after another, but two appending at the same moment may fork the chain,
+// so that Verify reports the log as tampered with; give each concurrent
+// process its own log.
+type Log struct {
+	// Actor is who entries are made by when their context holds neither an
+	// identity nor a request, such as the user running a command.
+	Actor string
+
+	path string
+	mu   sync.Mutex
+	// size, seq and hash describe the file as it was after the last
+	// entry this Log read or wrote, so that the chain continues from
+	// there without reading the file again unless it grew.
+	size int64
+	seq  int64
+	hash string
+}
+
+// Open opens the audit log in the file path, creating it if it does not
+// exist. Entries name targets that may hold credentials, so the file is
+// readable by its owner only.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{path: path, size: -1}, f.Close()
+}
+
+// Path returns the file name of the log.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append fills in e's sequence number, hashes and, if they are unset, its
+// time, actor and request ID from ctx, and appends it to the log.
+func (l *Log) Append(ctx context.Context, e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	if e.Actor == "" {
+		e.Actor = l.actor(ctx)
+	}
+	if e.RequestID == "" {
+		e.RequestID = RequestID(ctx)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() != l.size {
+		if err := l.readTail(f); err != nil {
+			return err
+		}
+	}
+	e.Seq, e.Prev = l.seq+1, l.hash
+	e.Hash = hash(e)
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if _, err := f.Write(b); err != nil {
+		l.size = -1
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		l.size = -1
+		return err
+	}
+	l.size, l.seq, l.hash = fi.Size()+int64(len(b)), e.Seq, e.Hash
+	return nil
+}
+
+// readTail reads the sequence number and hash of the last entry in f.
+func (l *Log) readTail(f *os.File) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	l.size, l.seq, l.hash = int64(len(data)), 0, ""
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(bytes.TrimSpace(last)) == 0 {
+		return nil
+	}
+	var e Entry
+	if err := json.Unmarshal(last, &e); err != nil {
+		return fmt.Errorf("%s:%d: %w", l.path, len(lines), err)
+	}
+	l.seq, l.hash = e.Seq, e.Hash
+	return nil
+}
+
+// RecordDelivery appends a delivery of m to target, made in attempts
+// attempts and failed with err if it is not nil. It logs rather than
+// returns a failure to append, as deliveries do not wait on the log.
+func (l *Log) RecordDelivery(ctx context.Context, m message.Message, target string, attempts int, err error) {
+	e := Entry{Action: Deliver, MessageID: m.ID, Target: target, Attempts: attempts}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := l.Append(ctx, e); err != nil {
+		slog.Error("audit log failed", "id", m.ID, "action", Deliver, "error", err)
+	}
+}
+
+// hash returns the hash of e, without its Hash.
+func hash(e Entry) string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Filter selects entries. Zero fields match every entry.
+type Filter struct {
+	MessageID string
+	Actor     string
+	Action    Action
+	RequestID string
+	// Since and Until bound the entries' times, Since inclusive.
+	Since, Until time.Time
+}
+
+// Match reports whether e passes f.
+func (f Filter) Match(e Entry) bool {
+	return (f.MessageID == "" || e.MessageID == f.MessageID) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.RequestID == "" || e.RequestID == f.RequestID) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Entries returns the entries f matches, oldest first.
+func (l *Log) Entries(f Filter) ([]Entry, error) {
+	var es []Entry
+	err := l.scan(func(e Entry) error {
+		if f.Match(e) {
+			es = append(es, e)
+		}
+		return nil
+	})
+	return es, err
+}
+
+// Verify checks the hash chain of the whole log and returns how many
+// entries follow from the ones before. Its error wraps ErrTampered and
+// names the first entry that does not.
+func (l *Log) Verify() (int, error) {
+	var n int
+	var prev string
+	err := l.scan(func(e Entry) error {
+		switch {
+		case e.Seq != int64(n+1):
+			return fmt.Errorf("%w: entry %d is numbered %d", ErrTampered, n+1, e.Seq)
+		case e.Prev != prev:
+			return fmt.Errorf("%w: entry %d does not follow entry %d", ErrTampered, n+1, n)
+		case e.Hash != hash(e):
+			return fmt.Errorf("%w: entry %d does not match its hash", ErrTampered, n+1)
+		}
+		n++
+		prev = e.Hash
+		return nil
+	})
+	return n, err
+}
+
+func (l *Log) scan(fn func(Entry) error) error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s:%d: %w", l.path, line, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+)
+
+// Anonymous is the actor of requests without an identity.
+const Anonymous = "anonymous"
+
+type (
+	requestIDKey struct{}
+	actorKey     struct{}
+)
+
+// ContextWithRequestID returns ctx for the HTTP request with the given ID,
+// for the entries made on its behalf.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ContextWithActor returns ctx for work done by actor, such as a schedule,
+// where there is no request with an identity.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actor returns who ctx acts for: the subject of its identity, the actor
+// of ContextWithActor, Anonymous for a request without an identity, or
+// l.Actor.
+func (l *Log) actor(ctx context.Context) string {
+	if id := auth.IdentityFromContext(ctx); id != nil {
+		return id.Subject
+	}
+	if a, ok := ctx.Value(actorKey{}).(string); ok {
+		return a
+	}
+	if RequestID(ctx) != "" {
+		return Anonymous
+	}
+	return l.Actor
+}
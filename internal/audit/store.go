@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Store logs the messages saved to and deleted from an underlying store.
+// A save is logged as a creation, or as a modification if the store held a
+// message with the same ID, which takes a Get before each Save.
+type Store struct {
+	s   store.MessageStore
+	log *Log
+}
+
+var (
+	_ store.MessageStore = (*Store)(nil)
+	_ store.Wrapper      = (*Store)(nil)
+)
+
+// NewStore returns a store keeping messages in s and logging changes to
+// them in log.
+func NewStore(s store.MessageStore, log *Log) *Store {
+	return &Store{s: s, log: log}
+}
+
+// Unwrap returns the underlying store.
+func (s *Store) Unwrap() store.MessageStore {
+	return s.s
+}
+
+func (s *Store) Save(ctx context.Context, m message.Message) error {
+	action := Modify
+	if _, err := s.s.Get(ctx, m.ID); errors.Is(err, store.ErrNotFound) {
+		action = Create
+	} else if err != nil {
+		return err
+	}
+	if err := s.s.Save(ctx, m); err != nil {
+		return err
+	}
+	s.append(ctx, action, m.ID)
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (message.Message, error) {
+	return s.s.Get(ctx, id)
+}
+
+func (s *Store) List(ctx context.Context, limit int) ([]message.Message, error) {
+	return s.s.List(ctx, limit)
+}
+
+func (s *Store) Find(ctx context.Context, q store.Query) ([]message.Message, error) {
+	return s.s.Find(ctx, q)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := s.s.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.append(ctx, Delete, id)
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.s.Close()
+}
+
+// append logs action on the message id. The change is made by then, so a
+// failure to log it is logged rather than returned, which would have the
+// caller take it for a failed change.
+func (s *Store) append(ctx context.Context, action Action, id string) {
+	if err := s.log.Append(ctx, Entry{Action: action, MessageID: id}); err != nil {
+		slog.Error("audit log failed", "id", id, "action", action, "error", err)
+	}
+}
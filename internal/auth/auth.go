@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials means the request carried no credentials that the
+	// authenticator understands.
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalid       = errors.New("invalid credentials")
+)
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Subject string
+	// Claims holds the JWT claims for tokens, and is nil for API keys.
+	Claims map[string]any
+}
+
+// Authenticator establishes who sent a request. It returns an error
+// wrapping ErrNoCredentials if the request has none it understands, and
+// one wrapping ErrInvalid if they are wrong.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type identityKey struct{}
+
+func ContextWithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the request the context
+// belongs to, or nil if it is anonymous.
+func IdentityFromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Chain tries each authenticator in turn, using the first that finds
+// credentials it understands.
+func Chain(auths ...Authenticator) Authenticator {
+	return chain(auths)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return id, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// APIKeys authenticates requests by the X-API-Key header against a static
+// set of keys.
+type APIKeys struct {
+	// subjects maps the SHA-256 of each key to its subject, so that a
+	// lookup does not compare the key itself byte by byte.
+	subjects map[[sha256.Size]byte]string
+}
+
+// LoadAPIKeys reads keys from a file with one "key subject" pair per line.
+// A key may be given as sha256:<hex digest> to keep it out of the file.
+// Blank lines and lines starting with # are ignored.
+func LoadAPIKeys(name string) (*APIKeys, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	k := &APIKeys{subjects: make(map[[sha256.Size]byte]string)}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a key and a subject", name, n)
+		}
+		var sum [sha256.Size]byte
+		if digest, ok := strings.CutPrefix(fields[0], "sha256:"); ok {
+			b, err := hex.DecodeString(digest)
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("%s:%d: invalid SHA-256 digest", name, n)
+			}
+			copy(sum[:], b)
+		} else {
+			sum = sha256.Sum256([]byte(fields[0]))
+		}
+		k.subjects[sum] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *APIKeys) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	subject, ok := k.subjects[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalid)
+	}
+	return &Identity{Subject: subject}, nil
+}
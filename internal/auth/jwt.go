@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwksTTL        = time.Hour
+	jwksMinRefresh = time.Minute
+	clockSkew      = time.Minute
+)
+
+// JWT authenticates requests by a bearer token signed with one of the keys
+// published at a JWKS URL. RS256/384/512, ES256/384/512 and EdDSA tokens
+// are accepted; the key set is fetched on first use, hourly, and when a
+// token names a key it does not have.
+type JWT struct {
+	JWKSURL string
+	// Issuer and Audience, if set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// SubjectClaim names the claim used as the identity's subject, sub by
+	// default.
+	SubjectClaim string
+	HTTPClient   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func NewJWT(jwksURL string) *JWT {
+	return &JWT{JWKSURL: jwksURL, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (j *JWT) Authenticate(r *http.Request) (*Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || strings.Count(token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	claims, err := j.Verify(r.Context(), strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	claim := j.SubjectClaim
+	if claim == "" {
+		claim = "sub"
+	}
+	subject, _ := claims[claim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no %s claim", ErrInvalid, claim)
+	}
+	return &Identity{Subject: subject, Claims: claims}, nil
+}
+
+// Verify checks the signature and the time, issuer and audience claims
+// of token and returns its claims.
+func (j *JWT) Verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("token signature: %w", err)
+	}
+	key, err := j.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("token claims: %w", err)
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("token expired or has no exp claim")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if j.Issuer != "" && claims["iss"] != j.Issuer {
+		return nil, errors.New("token issuer does not match")
+	}
+	if j.Audience != "" && !hasAudience(claims["aud"], j.Audience) {
+		return nil, errors.New("token audience does not match")
+	}
+	return claims, nil
+}
+
+func decodeSegment(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func hasAudience(aud any, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []any:
+		return slices.Contains(aud, any(want))
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	hashFor := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+	invalid := errors.New("invalid token signature")
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		h, ok := hashFor[strings.TrimPrefix(alg, "RS")]
+		if !ok || !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %q does not match an RSA key", alg)
+		}
+		if rsa.VerifyPKCS1v15(k, h, digest(h, signed), sig) != nil {
+			return invalid
+		}
+	case *ecdsa.PublicKey:
+		curves := map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+		if curves[alg] != k.Curve.Params().Name {
+			return fmt.Errorf("algorithm %q does not match a %s key", alg, k.Curve.Params().Name)
+		}
+		h := hashFor[alg[2:]]
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return invalid
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest(h, signed), r, s) {
+			return invalid
+		}
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			return fmt.Errorf("algorithm %q does not match an Ed25519 key", alg)
+		}
+		if !ed25519.Verify(k, signed, sig) {
+			return invalid
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	var w hash.Hash
+	switch h {
+	case crypto.SHA384:
+		w = sha512.New384()
+	case crypto.SHA512:
+		w = sha512.New()
+	default:
+		w = sha256.New()
+	}
+	w.Write(data)
+	return w.Sum(nil)
+}
+
+// key returns the key named kid, fetching the key set if it is stale or
+// lacks the key.
+func (j *JWT) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetched) > jwksTTL
+	if (!ok && time.Since(j.fetched) > jwksMinRefresh) || stale {
+		keys, err := j.fetch(ctx)
+		if err != nil {
+			if ok {
+				return key, nil // keep using the cached key
+			}
+			return nil, fmt.Errorf("fetching JWKS: %w", err)
+		}
+		j.keys, j.fetched = keys, time.Now()
+		key, ok = keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWT) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := j.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	b64 := func(s string) []byte {
+		b, _ := base64.RawURLEncoding.DecodeString(s)
+		return b
+	}
+	switch k.Kty {
+	case "RSA":
+		n, e := new(big.Int).SetBytes(b64(k.N)), new(big.Int).SetBytes(b64(k.E))
+		if n.Sign() == 0 || !e.IsInt64() || e.Int64() < 3 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(b64(k.X)), Y: new(big.Int).SetBytes(b64(k.Y))}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	case "OKP":
+		if x := b64(k.X); k.Crv == "Ed25519" && len(x) == ed25519.PublicKeySize {
+			return ed25519.PublicKey(x), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
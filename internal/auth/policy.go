@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Actions that a Policy authorizes.
+const (
+	ActionGreet = "greet" // greet a name
+	ActionPost  = "post"  // record a message
+	ActionRead  = "read"  // list or stream messages
+)
+
+type rule struct {
+	subject string
+	action  string
+	names   []string
+}
+
+// Policy decides what each identity may do. It denies anything no rule
+// allows.
+type Policy struct {
+	rules []rule
+}
+
+// LoadPolicy reads rules from a file with one "subject action [name...]"
+// rule per line. Subjects and names are glob patterns, in which * matches
+// any run of characters, slashes included, ? any one character and \
+// escapes the character after it; so * alone matches anyone. The action is
+// greet, post, read or *. Names only apply to greet
+// and restrict whom the subject may greet; without them it may greet
+// anyone. Blank lines and lines starting with # are ignored.
+func LoadPolicy(name string) (*Policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p := &Policy{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: want a subject and an action", name, n)
+		}
+		r := rule{subject: fields[0], action: fields[1], names: fields[2:]}
+		switch r.action {
+		case ActionGreet, ActionPost, ActionRead, "*":
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown action %q", name, n, r.action)
+		}
+		for _, pattern := range append([]string{r.subject}, r.names...) {
+			if strings.HasSuffix(strings.ReplaceAll(pattern, `\\`, ""), `\`) {
+				return nil, fmt.Errorf("%s:%d: invalid pattern %q", name, n, pattern)
+			}
+		}
+		p.rules = append(p.rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Allow reports whether id may perform action, on name for greet. A nil
+// Policy allows everything and a nil id is never allowed.
+func (p *Policy) Allow(id *Identity, action, name string) bool {
+	if p == nil {
+		return true
+	}
+	if id == nil {
+		return false
+	}
+	for _, r := range p.rules {
+		if r.action != "*" && r.action != action || !match(r.subject, id.Subject) {
+			continue
+		}
+		if action != ActionGreet || len(r.names) == 0 {
+			return true
+		}
+		for _, pattern := range r.names {
+			if match(pattern, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match reports whether s matches the glob pattern, a rune at a time. On
+// a mismatch after a *, it retries with the * spanning one rune more.
+func match(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	var pi, si int
+	star, resume := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, resume = pi, si
+			pi++
+			continue
+		case pi < len(p) && p[pi] == '?':
+			pi++
+			si++
+			continue
+		case pi < len(p):
+			c := p[pi]
+			next := pi + 1
+			if c == '\\' && next < len(p) {
+				c = p[next]
+				next++
+			}
+			if c == str[si] {
+				pi = next
+				si++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		resume++
+		pi, si = star+1, resume
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
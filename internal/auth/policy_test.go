@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "anyone", true},
+		{"*", "team/alice", true},
+		{"team/*", "team/alice", true},
+		{"team/*", "team/a/b", true},
+		{"team/*", "other/alice", false},
+		{"*@example.com", "alice@example.com", true},
+		{"*@example.com", "alice@example.org", false},
+		{"A?a", "Ada", true},
+		{"A?a", "Aða", true},
+		{"A?a", "Adda", false},
+		{"*a*a*", "banana", true},
+		{"*x*", "banana", false},
+		{"Zoë", "Zoë", true},
+		{`\*`, "*", true},
+		{`\*`, "anyone", false},
+		{`a\?`, "a?", true},
+		{`a\?`, "ab", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyAllow(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "policy")
+	rules := "alice greet Ada Gr*\nops/* read\nbob *\n"
+	if err := os.WriteFile(name, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		subject, action, name string
+		want                  bool
+	}{
+		{"alice", ActionGreet, "Ada", true},
+		{"alice", ActionGreet, "Grace", true},
+		{"alice", ActionGreet, "Eve", false},
+		{"alice", ActionRead, "", false},
+		{"ops/eu/carol", ActionRead, "", true},
+		{"ops/eu/carol", ActionPost, "", false},
+		{"bob", ActionPost, "", true},
+		{"bob", ActionGreet, "Eve", true},
+		{"mallory", ActionGreet, "Ada", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allow(&Identity{Subject: tt.subject}, tt.action, tt.name); got != tt.want {
+			t.Errorf("Allow(%q, %q, %q) = %v, want %v", tt.subject, tt.action, tt.name, got, tt.want)
+		}
+	}
+	if p.Allow(nil, ActionGreet, "Ada") {
+		t.Error("Allow(nil) = true, want false")
+	}
+}
+
+func TestLoadPolicyInvalidPattern(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "policy")
+	if err := os.WriteFile(name, []byte("alice greet Ada\\\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicy(name); err == nil {
+		t.Error("LoadPolicy accepted a pattern ending in a lone backslash")
+	}
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores byte values under string keys for a limited time.
+type Cache interface {
+	// Get returns the value stored under key, and false if there is none
+	// or it has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Memory is an in-process Cache that evicts the least recently used entry
+// once it is full.
+type Memory struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // of *memoryEntry, most recently used first
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory returns a Memory cache of up to size entries.
+func NewMemory(size int) *Memory {
+	return &Memory{size: max(size, 1), entries: make(map[string]*list.Element)}
+}
+
+func (c *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if time.Now().After(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.lru.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (c *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// Fallback returns a Cache that uses primary, and secondary while primary
+// fails, so that an unreachable Redis degrades to an in-process cache.
+func Fallback(primary, secondary Cache) Cache {
+	return &fallback{primary: primary, secondary: secondary}
+}
+
+type fallback struct {
+	primary, secondary Cache
+	lastWarning        atomic.Int64
+}
+
+func (f *fallback) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok, err := f.primary.Get(ctx, key)
+	if err != nil {
+		// Warn once a minute rather than on every request.
+		now := time.Now().Unix()
+		if last := f.lastWarning.Load(); now-last >= 60 && f.lastWarning.CompareAndSwap(last, now) {
+			slog.Warn("cache unavailable, using fallback", "error", err)
+		}
+		return f.secondary.Get(ctx, key)
+	}
+	return value, ok, nil
+}
+
+func (f *fallback) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := f.primary.Set(ctx, key, value, ttl); err != nil {
+		return f.secondary.Set(ctx, key, value, ttl)
+	}
+	return nil
+}
+
+// Loader fills a Cache on misses. Concurrent misses for a key share one
+// load, and TTLs vary by up to a tenth so that entries cached together do
+// not all expire together; both keep a burst of requests from stampeding
+// whatever the cache protects.
+type Loader struct {
+	Cache Cache
+	TTL   time.Duration
+
+	mu     sync.Mutex
+	flight map[string]*call
+}
+
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// Result says how Load found a value.
+type Result string
+
+const (
+	Hit    Result = "hit"
+	Miss   Result = "miss"
+	Shared Result = "shared" // waited for another caller's load
+	Error  Result = "error"  // the cache failed and the value was loaded
+)
+
+// Load returns the value cached under key, or calls load and caches its
+// result. Errors from load are returned but not cached.
+func (l *Loader) Load(ctx context.Context, key string, load func(context.Context) ([]byte, error)) ([]byte, Result, error) {
+	value, ok, err := l.Cache.Get(ctx, key)
+	if err == nil && ok {
+		return value, Hit, nil
+	}
+	result := Miss
+	if err != nil {
+		result = Error
+	}
+	l.mu.Lock()
+	if c, ok := l.flight[key]; ok {
+		l.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.value, Shared, c.err
+		case <-ctx.Done():
+			return nil, Shared, ctx.Err()
+		}
+	}
+	if l.flight == nil {
+		l.flight = make(map[string]*call)
+	}
+	c := &call{done: make(chan struct{})}
+	l.flight[key] = c
+	l.mu.Unlock()
+
+	c.value, c.err = load(ctx)
+	if c.err == nil {
+		ttl := l.TTL - time.Duration(rand.Int64N(int64(l.TTL)/10+1))
+		if err := l.Cache.Set(ctx, key, c.value, ttl); err != nil {
+			result = Error
+		}
+	}
+	l.mu.Lock()
+	delete(l.flight, key)
+	l.mu.Unlock()
+	close(c.done)
+	return c.value, result, c.err
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// LRU is an in-process map of up to a fixed number of values, which evicts
+// the least recently used once it is full. Unlike Memory, it holds values
+// of any type without encoding them, and they never expire: Purge empties
+// it when what they were made from changes. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	lru     list.List // of *lruEntry[K, V], most recently used first
+
+	hits, misses atomic.Uint64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewLRU returns an LRU of up to size values.
+func NewLRU[K comparable, V any](size int) *LRU[K, V] {
+	return &LRU[K, V]{size: max(size, 1), entries: make(map[K]*list.Element)}
+}
+
+// Get returns the value for key, and false if there is none.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	c.hits.Add(1)
+	c.lru.MoveToFront(el)
+	return el.Value.(*lruEntry[K, V]).value, true
+}
+
+// Add sets the value for key, evicting the least recently used value if
+// the LRU is full.
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruEntry[K, V]).value = value
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&lruEntry[K, V]{key, value})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Purge removes every value.
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.lru.Init()
+}
+
+// Len returns the number of values held.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns how many calls of Get have found a value and how many
+// have not.
+func (c *LRU[K, V]) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
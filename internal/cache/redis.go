@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxIdleConns = 4
+	dialTimeout  = 5 * time.Second
+	maxBulkSize  = 64 << 20
+)
+
+// Redis is a Cache in a Redis server, speaking just enough of the RESP
+// protocol for GET and SET with an expiry. Keys are stored with Prefix
+// prepended.
+type Redis struct {
+	Prefix string
+
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+// RedisError is an error reply from the server.
+type RedisError string
+
+func (e RedisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+// NewRedis returns a cache for the server at rawURL, written as
+// redis://[[user]:password@]host[:port][/db], or rediss:// for TLS.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	r := &Redis{Prefix: "greeter:"}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		r.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("redis: unsupported URL scheme %q", u.Scheme)
+	}
+	r.addr = u.Host
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", r.Prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %v", reply)
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := max(ttl.Milliseconds(), 1)
+	_, err := r.do(ctx, "SET", r.Prefix+key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// Close closes the idle connections.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.idle {
+		c.nc.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+// do sends one command and reads its reply, reusing an idle connection if
+// there is one. Connections that fail are discarded, and a command that
+// failed on a reused connection, which the server may have closed, is
+// retried once on a new one.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c, reused, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(ctx, args)
+	var rerr RedisError
+	if err != nil && !errors.As(err, &rerr) {
+		c.nc.Close()
+		if reused && ctx.Err() == nil {
+			return r.do(ctx, args...)
+		}
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	r.mu.Lock()
+	if len(r.idle) < maxIdleConns {
+		r.idle = append(r.idle, c)
+		c = nil
+	}
+	r.mu.Unlock()
+	if c != nil {
+		c.nc.Close()
+	}
+	return reply, err
+}
+
+func (r *Redis) conn(ctx context.Context) (c *redisConn, reused bool, err error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, true, nil
+	}
+	r.mu.Unlock()
+	c, err = r.dial(ctx)
+	return c, false, err
+}
+
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if r.tls != nil {
+		tc := tls.Client(nc, r.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		nc = tc
+	}
+	c := &redisConn{nc: nc, r: bufio.NewReader(nc)}
+	var setup [][]string
+	if r.password != "" {
+		if r.username != "" {
+			setup = append(setup, []string{"AUTH", r.username, r.password})
+		} else {
+			setup = append(setup, []string{"AUTH", r.password})
+		}
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.roundTrip(ctx, args); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: %s: %w", args[0], err)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	c.nc.SetDeadline(deadline)
+	var b []byte
+	b = fmt.Appendf(b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		b = fmt.Appendf(b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.nc.Write(b); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads a RESP2 reply: a simple string, error, integer, bulk
+// string or array. Nil bulk strings and arrays are returned as nil.
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > maxBulkSize {
+			return nil, fmt.Errorf("invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
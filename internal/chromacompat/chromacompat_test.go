@@ -0,0 +1,234 @@
+package chromacompat
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// TestTypes checks that Types is a closed table: no name twice, and the
+// types each token type inherits its style from listed too, so that a type
+// added without its category is caught. Meta types, such as
+// LineNumbersTable, style the output and make no tokens.
+func TestTypes(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, typ := range Types {
+		if seen[typ.Name] {
+			t.Errorf("%s is listed twice", typ.Name)
+		}
+		seen[typ.Name] = true
+	}
+	for _, typ := range Types {
+		if p := parent(typ.Name); typ.Kind != skip && p != "" && !seen[p] {
+			t.Errorf("%s inherits from %s, which is not listed", typ.Name, p)
+		}
+	}
+	for _, c := range categories {
+		if !seen[c] {
+			t.Errorf("category %s is not listed", c)
+		}
+	}
+}
+
+// TestKinds checks that every kind is reached from some Chroma type and
+// written as one that maps back to it.
+func TestKinds(t *testing.T) {
+	reached := make(map[lexer.Kind]bool)
+	for _, typ := range Types {
+		reached[typ.Kind] = true
+	}
+	for k := lexer.Illegal; k <= lexer.Operator; k++ {
+		if !reached[k] {
+			t.Errorf("no Chroma type maps to %v", k)
+		}
+		name := TypeName(k)
+		if got, ok := Kind(name); !ok || got != k {
+			t.Errorf("Kind(TypeName(%v)) = Kind(%q) = %v, %v, want %v", k, name, got, ok, k)
+		}
+	}
+	if got := TypeName(lexer.EOF); got != "Text" {
+		t.Errorf("TypeName(EOF) = %q, want Text", got)
+	}
+}
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		name string
+		kind lexer.Kind
+		ok   bool
+	}{
+		{"Keyword", lexer.Keyword, true},
+		{"KeywordType", lexer.Keyword, true},
+		{"NameFunction", lexer.Ident, true},
+		{"LiteralStringDouble", lexer.String, true},
+		{"LiteralStringChar", lexer.Char, true},
+		{"LiteralNumberHex", lexer.Number, true},
+		{"Punctuation", lexer.Operator, true},
+		{"CommentSingle", lexer.Comment, true},
+		{"Error", lexer.Illegal, true},
+		{"GenericError", lexer.Illegal, true},
+		// Types newer than Types take the kind of their category.
+		{"LiteralStringFoo", lexer.String, true},
+		{"LiteralNumberFoo", lexer.Number, true},
+		{"NameFoo", lexer.Ident, true},
+		{"Text", 0, false},
+		{"TextWhitespace", 0, false},
+		{"GenericHeading", 0, false},
+		{"Background", 0, false},
+		{"Unknown", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if k, ok := Kind(tt.name); k != tt.kind || ok != tt.ok {
+			t.Errorf("Kind(%q) = %v, %v, want %v, %v", tt.name, k, ok, tt.kind, tt.ok)
+		}
+	}
+}
+
+func TestParent(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"LiteralStringDouble", "LiteralString"},
+		{"LiteralString", "Literal"},
+		{"Literal", ""},
+		{"Keyword", ""},
+	}
+	for _, tt := range tests {
+		if got := parent(tt.name); got != tt.want {
+			t.Errorf("parent(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+const goSource = "package main\n\n// main greets.\nfunc main() {\n\tprintln(\"hi\", 'x', 0x1f) @\n}\n"
+
+func TestChromaRoundTrip(t *testing.T) {
+	src := []byte(goSource)
+	toks := lexer.Tokenize(src)
+	var b bytes.Buffer
+	if err := WriteJSON(&b, src, toks); err != nil {
+		t.Fatal(err)
+	}
+	gotSrc, gotToks, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotSrc) != goSource {
+		t.Errorf("source %q, want %q", gotSrc, goSource)
+	}
+	if !reflect.DeepEqual(gotToks, toks) {
+		t.Errorf("tokens\n%v\nwant\n%v", gotToks, toks)
+	}
+}
+
+func TestFromChroma(t *testing.T) {
+	src, toks, err := FromChroma([]Token{
+		{"KeywordDeclaration", "var"},
+		{"TextWhitespace", " "},
+		{"NameOther", "s"},
+		{"Text", " "},
+		{"Punctuation", "="},
+		{"Text", " "},
+		{"LiteralStringDouble", `"a`},
+		{"LiteralStringEscape", `\n`},
+		{"LiteralStringDouble", `"`},
+		{"Comment", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `var s = "a\n"`; string(src) != want {
+		t.Errorf("source %q, want %q", src, want)
+	}
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.Kind.String()+" "+tok.Text)
+	}
+	want := []string{"keyword var", "ident s", "operator =", `string "a`, `string \n`, `string "`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens %q, want %q", got, want)
+	}
+}
+
+func TestToChromaOutOfOrder(t *testing.T) {
+	src := []byte("a b")
+	toks := lexer.Tokenize(src)
+	toks[0], toks[1] = toks[1], toks[0]
+	if _, err := ToChroma(src, toks); err == nil {
+		t.Error("ToChroma of out-of-order tokens succeeded")
+	}
+}
+
+func TestStyleRoundTrip(t *testing.T) {
+	for _, name := range theme.Names() {
+		t.Run(name, func(t *testing.T) {
+			want, _ := theme.Builtin(name)
+			data, err := StyleXML(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseStyle(data)
+			if err != nil {
+				t.Fatalf("ParseStyle: %v\n%s", err, data)
+			}
+			if got.Name != want.Name || got.Background != want.Background || got.Foreground != want.Foreground ||
+				got.LineNumbers != want.LineNumbers || got.Highlight != want.Highlight {
+				t.Errorf("theme %+v, want %+v", got, want)
+			}
+			for k := lexer.Illegal; k <= lexer.Operator; k++ {
+				if got.Style(k) != want.Style(k) {
+					t.Errorf("%v styled %+v, want %+v", k, got.Style(k), want.Style(k))
+				}
+			}
+		})
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	th, err := ParseStyle([]byte(`<style name="test">
+  <entry type="Background" style="bg:#FFF #111"/>
+  <entry type="Literal" style="italic"/>
+  <entry type="LiteralString" style="#0a0"/>
+  <entry type="LiteralStringChar" style="bold"/>
+  <entry type="Keyword" style="bold #00f"/>
+  <entry type="KeywordType" style="noinherit #f00"/>
+  <entry type="Comment" style="noinherit underline #888"/>
+  <entry type="LineHighlight" style="bg:#eee"/>
+</style>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Background != "#ffffff" || th.Foreground != "#111111" || th.Highlight != "#eeeeee" {
+		t.Errorf("background %q, foreground %q, highlight %q", th.Background, th.Foreground, th.Highlight)
+	}
+	tests := []struct {
+		kind lexer.Kind
+		want theme.Style
+	}{
+		{lexer.String, theme.Style{Color: "#00aa00", Italic: true}},
+		{lexer.Char, theme.Style{Color: "#00aa00", Italic: true, Bold: true}},
+		{lexer.Keyword, theme.Style{Color: "#0000ff", Bold: true}},
+		{lexer.Comment, theme.Style{Color: "#888888", Underline: true}},
+		{lexer.Ident, theme.Style{}},
+	}
+	for _, tt := range tests {
+		if got := th.Style(tt.kind); got != tt.want {
+			t.Errorf("%v styled %+v, want %+v", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestParseStyleErrors(t *testing.T) {
+	for _, style := range []string{
+		`<style><entry type="Keyword" style="#12"/></style>`,
+		`<style><entry type="Keyword" style="blink"/></style>`,
+		`<style><entry type="Literal" style="bg:red"/></style>`,
+		`<style>`,
+	} {
+		if _, err := ParseStyle([]byte(style)); err == nil {
+			t.Errorf("ParseStyle(%s) succeeded", style)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package chromacompat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+)
+
+// Token is a Chroma token, as chroma.Token marshals to JSON.
+type Token struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// FromChroma returns the source of a Chroma token stream, the values of its
+// tokens one after another, and its tokens, with those that make none, such
+// as Text, left out. Tokens are not merged: a string Chroma splits at an
+// escape stays several tokens.
+func FromChroma(ts []Token) ([]byte, []lexer.Token, error) {
+	var src []byte
+	var spans []lexers.Span
+	for _, t := range ts {
+		if k, ok := Kind(t.Type); ok && t.Value != "" {
+			spans = append(spans, lexers.Span{Kind: k.String(), Offset: len(src), Length: len(t.Value)})
+		}
+		src = append(src, t.Value...)
+	}
+	toks, err := lexers.Tokens(src, spans)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, toks, nil
+}
+
+// ToChroma returns toks, which must be in order and lie within src, as a
+// Chroma token stream covering all of src, with Text tokens for the source
+// between them.
+func ToChroma(src []byte, toks []lexer.Token) ([]Token, error) {
+	var ts []Token
+	off := 0
+	for _, t := range toks {
+		if t.Pos.Offset < off || t.End() > len(src) {
+			return nil, fmt.Errorf("chromacompat: token %s at offset %d is out of order or past the source", t.Kind, t.Pos.Offset)
+		}
+		if t.Pos.Offset > off {
+			ts = append(ts, Token{"Text", string(src[off:t.Pos.Offset])})
+		}
+		ts = append(ts, Token{TypeName(t.Kind), t.Text})
+		off = t.End()
+	}
+	if off < len(src) {
+		ts = append(ts, Token{"Text", string(src[off:])})
+	}
+	return ts, nil
+}
+
+// ReadJSON reads the output of Chroma's json formatter, such as
+// chroma --formatter json writes, and returns it as FromChroma does.
+func ReadJSON(r io.Reader) ([]byte, []lexer.Token, error) {
+	var ts []Token
+	if err := json.NewDecoder(r).Decode(&ts); err != nil {
+		return nil, nil, fmt.Errorf("chromacompat: reading Chroma tokens: %w", err)
+	}
+	return FromChroma(ts)
+}
+
+// WriteJSON writes toks as ToChroma returns them, laid out as Chroma's json
+// formatter writes them.
+func WriteJSON(w io.Writer, src []byte, toks []lexer.Token) error {
+	ts, err := ToChroma(src, toks)
+	if err != nil {
+		return err
+	}
+	out := []byte("[\n")
+	for i, t := range ts {
+		if i > 0 {
+			out = append(out, ",\n"...)
+		}
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		out = append(append(out, "  "...), data...)
+	}
+	out = append(out, "\n]\n"...)
+	_, err = w.Write(out)
+	return err
+}
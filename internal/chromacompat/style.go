@@ -0,0 +1,189 @@
+package chromacompat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// styleFile is a Chroma style as its XML files hold it.
+type styleFile struct {
+	XMLName xml.Name `xml:"style"`
+	Name    string   `xml:"name,attr"`
+	Entries []entry  `xml:"entry"`
+}
+
+type entry struct {
+	Type  string `xml:"type,attr"`
+	Style string `xml:"style,attr"`
+}
+
+// LoadStyle reads a Chroma style from an XML file, as ParseStyle does.
+func LoadStyle(path string) (*theme.Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ParseStyle(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// ParseStyle returns the Chroma style in data, XML such as
+//
+//	<style name="github">
+//	  <entry type="Background" style="bg:#ffffff #24292f"/>
+//	  <entry type="Keyword" style="bold #cf222e"/>
+//	</style>
+//
+// as a theme. Each kind takes the style of its type in TypeName, which
+// inherits from the types above it, as LiteralStringChar does from
+// LiteralString and Literal, unless it says noinherit. The Background,
+// LineNumbers and LineHighlight entries give the theme's colors, lines and
+// highlighted lines; Text gives the foreground if Background does not.
+func ParseStyle(data []byte) (*theme.Theme, error) {
+	var f styleFile
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("reading Chroma style: %w", err)
+	}
+	entries := make(map[string]string, len(f.Entries))
+	for _, e := range f.Entries {
+		entries[e.Type] = e.Style
+	}
+	resolve := func(name string) (theme.Style, error) {
+		var chain []string
+		for n := name; n != ""; n = parent(n) {
+			chain = append(chain, n)
+		}
+		var st theme.Style
+		for i := len(chain) - 1; i >= 0; i-- {
+			var err error
+			if st, err = apply(st, entries[chain[i]]); err != nil {
+				return theme.Style{}, fmt.Errorf("entry %s: %w", chain[i], err)
+			}
+		}
+		return st, nil
+	}
+
+	t := &theme.Theme{Name: f.Name, Styles: make(map[lexer.Kind]theme.Style)}
+	bg, err := resolve("Background")
+	if err != nil {
+		return nil, err
+	}
+	text, err := resolve("Text")
+	if err != nil {
+		return nil, err
+	}
+	t.Background, t.Foreground = bg.Background, bg.Color
+	if t.Foreground == "" {
+		t.Foreground = text.Color
+	}
+	if t.LineNumbers, err = resolve("LineNumbers"); err != nil {
+		return nil, err
+	}
+	hl, err := resolve("LineHighlight")
+	if err != nil {
+		return nil, err
+	}
+	t.Highlight = hl.Background
+	for k := lexer.Illegal; k <= lexer.Operator; k++ {
+		st, err := resolve(TypeName(k))
+		if err != nil {
+			return nil, err
+		}
+		if st != (theme.Style{}) {
+			t.Styles[k] = st
+		}
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// apply returns st changed by the words of a Chroma style entry.
+func apply(st theme.Style, s string) (theme.Style, error) {
+	for _, word := range strings.Fields(s) {
+		switch {
+		case word == "noinherit":
+			st = theme.Style{}
+		case word == "inherit":
+		case word == "bold" || word == "nobold":
+			st.Bold = word == "bold"
+		case word == "italic" || word == "noitalic":
+			st.Italic = word == "italic"
+		case word == "underline" || word == "nounderline":
+			st.Underline = word == "underline"
+		case strings.HasPrefix(word, "bg:"):
+			c, err := color(word[3:])
+			if err != nil {
+				return st, err
+			}
+			st.Background = c
+		case strings.HasPrefix(word, "border:"):
+			// Themes have no borders.
+		case strings.HasPrefix(word, "#"):
+			c, err := color(word)
+			if err != nil {
+				return st, err
+			}
+			st.Color = c
+		default:
+			return st, fmt.Errorf("unknown style %q", word)
+		}
+	}
+	return st, nil
+}
+
+// color returns a Chroma color, #rgb or #rrggbb, as #rrggbb, or "" for
+// none.
+func color(c string) (string, error) {
+	switch {
+	case c == "":
+		return "", nil
+	case len(c) == 4 && c[0] == '#':
+		return strings.ToLower(string([]byte{'#', c[1], c[1], c[2], c[2], c[3], c[3]})), nil
+	case len(c) == 7 && c[0] == '#':
+		return strings.ToLower(c), nil
+	}
+	return "", fmt.Errorf("invalid color %q: want #rgb or #rrggbb", c)
+}
+
+// StyleXML returns t as a Chroma style, for Chroma to highlight with.
+func StyleXML(t *theme.Theme) ([]byte, error) {
+	f := styleFile{Name: t.Name}
+	add := func(typ string, words ...string) {
+		s := strings.Join(strings.Fields(strings.Join(words, " ")), " ")
+		if s != "" {
+			f.Entries = append(f.Entries, entry{typ, s})
+		}
+	}
+	bg := ""
+	if t.Background != "" {
+		bg = "bg:" + t.Background
+	}
+	add("Background", bg, t.Foreground)
+	add("LineNumbers", t.LineNumbers.String())
+	if t.Highlight != "" {
+		add("LineHighlight", "bg:"+t.Highlight)
+	}
+	for k := lexer.Illegal; k <= lexer.Operator; k++ {
+		// Each type is written out in full, so that it does not inherit
+		// from another kind's, as LiteralStringChar would from
+		// LiteralString.
+		add(TypeName(k), "noinherit", t.Style(k).String())
+	}
+	// Chroma styles punctuation apart from operators.
+	add("Punctuation", "noinherit", t.Style(lexer.Operator).String())
+	data, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,143 @@
+// Package chromacompat converts between this module's tokens and themes and
+// those of Chroma, github.com/alecthomas/chroma, by the names of Chroma's
+// token types: its JSON token streams and its XML styles. It needs no
+// dependency on Chroma, whose token types marshal to these names.
+package chromacompat
+
+import (
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// skip marks token types with no kind: text and whitespace between tokens,
+// and types that style the output rather than code.
+const skip lexer.Kind = -1
+
+// Types maps every Chroma token type to the kind of token it becomes, or to
+// skip. Chroma's own order is kept, by category.
+var Types = []struct {
+	Name string
+	Kind lexer.Kind
+}{
+	// Meta types, which style the output.
+	{"Background", skip}, {"PreWrapper", skip}, {"Line", skip}, {"LineNumbersTable", skip},
+	{"LineNumbers", skip}, {"LineHighlight", skip}, {"LineTableTD", skip}, {"LineTable", skip},
+	{"LineLink", skip}, {"CodeLine", skip}, {"Error", lexer.Illegal}, {"Other", skip},
+	{"None", skip}, {"Ignore", skip}, {"EOFType", skip},
+
+	{"Keyword", lexer.Keyword}, {"KeywordConstant", lexer.Keyword}, {"KeywordDeclaration", lexer.Keyword},
+	{"KeywordNamespace", lexer.Keyword}, {"KeywordPseudo", lexer.Keyword}, {"KeywordReserved", lexer.Keyword},
+	{"KeywordType", lexer.Keyword},
+
+	{"Name", lexer.Ident}, {"NameAttribute", lexer.Ident}, {"NameBuiltin", lexer.Ident},
+	{"NameBuiltinPseudo", lexer.Ident}, {"NameClass", lexer.Ident}, {"NameConstant", lexer.Ident},
+	{"NameDecorator", lexer.Ident}, {"NameEntity", lexer.Ident}, {"NameException", lexer.Ident},
+	{"NameFunction", lexer.Ident}, {"NameFunctionMagic", lexer.Ident}, {"NameKeyword", lexer.Ident},
+	{"NameLabel", lexer.Ident}, {"NameNamespace", lexer.Ident}, {"NameOperator", lexer.Ident},
+	{"NameOther", lexer.Ident}, {"NamePseudo", lexer.Ident}, {"NameProperty", lexer.Ident},
+	{"NameTag", lexer.Ident}, {"NameVariable", lexer.Ident}, {"NameVariableAnonymous", lexer.Ident},
+	{"NameVariableClass", lexer.Ident}, {"NameVariableGlobal", lexer.Ident},
+	{"NameVariableInstance", lexer.Ident}, {"NameVariableMagic", lexer.Ident},
+
+	{"Literal", lexer.String}, {"LiteralDate", lexer.String}, {"LiteralOther", lexer.String},
+
+	{"LiteralString", lexer.String}, {"LiteralStringAffix", lexer.String}, {"LiteralStringAtom", lexer.String},
+	{"LiteralStringBacktick", lexer.String}, {"LiteralStringBoolean", lexer.String},
+	{"LiteralStringChar", lexer.Char}, {"LiteralStringDelimiter", lexer.String},
+	{"LiteralStringDoc", lexer.String}, {"LiteralStringDouble", lexer.String},
+	{"LiteralStringEscape", lexer.String}, {"LiteralStringHeredoc", lexer.String},
+	{"LiteralStringInterpol", lexer.String}, {"LiteralStringName", lexer.String},
+	{"LiteralStringOther", lexer.String}, {"LiteralStringRegex", lexer.String},
+	{"LiteralStringSingle", lexer.String}, {"LiteralStringSymbol", lexer.String},
+
+	{"LiteralNumber", lexer.Number}, {"LiteralNumberBin", lexer.Number}, {"LiteralNumberFloat", lexer.Number},
+	{"LiteralNumberHex", lexer.Number}, {"LiteralNumberInteger", lexer.Number},
+	{"LiteralNumberIntegerLong", lexer.Number}, {"LiteralNumberOct", lexer.Number},
+	{"LiteralNumberByte", lexer.Number},
+
+	{"Operator", lexer.Operator}, {"OperatorWord", lexer.Operator},
+	{"Punctuation", lexer.Operator},
+
+	{"Comment", lexer.Comment}, {"CommentHashbang", lexer.Comment}, {"CommentMultiline", lexer.Comment},
+	{"CommentSingle", lexer.Comment}, {"CommentSpecial", lexer.Comment}, {"CommentPreproc", lexer.Comment},
+	{"CommentPreprocFile", lexer.Comment},
+
+	// Generic types mark up prose and diffs, not code.
+	{"Generic", skip}, {"GenericDeleted", skip}, {"GenericEmph", skip}, {"GenericError", lexer.Illegal},
+	{"GenericHeading", skip}, {"GenericInserted", skip}, {"GenericOutput", skip}, {"GenericPrompt", skip},
+	{"GenericStrong", skip}, {"GenericSubheading", skip}, {"GenericTraceback", skip},
+	{"GenericUnderline", skip},
+
+	{"Text", skip}, {"TextWhitespace", skip}, {"TextSymbol", lexer.Operator}, {"TextPunctuation", lexer.Operator},
+}
+
+// kinds indexes Types by name.
+var kinds = func() map[string]lexer.Kind {
+	m := make(map[string]lexer.Kind, len(Types))
+	for _, t := range Types {
+		m[t.Name] = t.Kind
+	}
+	return m
+}()
+
+// categories are the prefixes of Chroma's type names, longest first, for
+// types newer than Types.
+var categories = []string{"LiteralString", "LiteralNumber", "Keyword", "Name", "Literal", "Operator", "Punctuation", "Comment", "Generic", "Text"}
+
+// Kind returns the kind of tokens of the Chroma type name, or false for
+// types that make no token, such as Text and Whitespace. Types missing from
+// Types take the kind of their category, as LiteralStringFoo does that of
+// LiteralString.
+func Kind(name string) (lexer.Kind, bool) {
+	k, ok := kinds[name]
+	if !ok {
+		for _, c := range categories {
+			if strings.HasPrefix(name, c) {
+				k, ok = kinds[c]
+				break
+			}
+		}
+	}
+	if !ok || k == skip {
+		return 0, false
+	}
+	return k, true
+}
+
+// TypeName returns the Chroma type of tokens of kind k, the most general
+// one that maps back to k: Keyword, Name, LiteralNumber, LiteralString,
+// LiteralStringChar, Comment, Operator or Error, or Text for anything else.
+func TypeName(k lexer.Kind) string {
+	switch k {
+	case lexer.Keyword:
+		return "Keyword"
+	case lexer.Ident:
+		return "Name"
+	case lexer.Number:
+		return "LiteralNumber"
+	case lexer.String:
+		return "LiteralString"
+	case lexer.Char:
+		return "LiteralStringChar"
+	case lexer.Comment:
+		return "Comment"
+	case lexer.Operator:
+		return "Operator"
+	case lexer.Illegal:
+		return "Error"
+	}
+	return "Text"
+}
+
+// parent returns the Chroma type name inherits its style from: the name
+// without its last word, as LiteralString for LiteralStringDouble, or ""
+// for a category.
+func parent(name string) string {
+	for i := len(name) - 1; i > 0; i-- {
+		if 'A' <= name[i] && name[i] <= 'Z' {
+			return name[:i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,277 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Client calls the greeter HTTP API served by greeter serve.
+type Client struct {
+	// HTTPClient makes the requests. Its timeout bounds each attempt but
+	// not streams.
+	HTTPClient *http.Client
+	// APIKey, if set, is sent as X-API-Key, which the server's per-client
+	// rate limit counts by.
+	APIKey string
+	// Retries is how many times a request is retried after a network
+	// error, 429, 502, 503 or 504. Only POST requests refused with 429
+	// are retried, since the others may have been processed.
+	Retries int
+	// Backoff is the delay before the first retry, doubling after each.
+	// A Retry-After header takes precedence.
+	Backoff time.Duration
+	// Keys, if set, verifies the signature of every message received, for
+	// servers signing with serve --sign-keys: a message without a valid
+	// signature is an error.
+	Keys *seal.Keyring
+
+	base *url.URL
+}
+
+const maxBackoff = 30 * time.Second
+
+// New returns a client for the server at baseURL, such as
+// http://localhost:8080, retrying twice and timing out after 10s.
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("client: invalid base URL %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Retries:    2,
+		Backoff:    200 * time.Millisecond,
+		base:       u,
+	}, nil
+}
+
+// Error is an error response from the server. For 422 responses Err is
+// the *message.ValidationError the server reported, so that
+// errors.Is(err, greeting.ErrEmptyName) works as it does in-process.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the server's Retry-After, for 429 and 503 responses.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// sentinels are the validation failures a server may report, matched by
+// prefix against the reasons in its error response.
+var sentinels = []error{
+	message.ErrEmpty,
+	message.ErrTooLong,
+	message.ErrInvalidUTF8,
+	message.ErrControlChar,
+	message.ErrBannedWord,
+	message.ErrConfusable,
+}
+
+type reason struct {
+	sentinel error
+	text     string
+}
+
+func (r *reason) Error() string { return r.text }
+func (r *reason) Unwrap() error { return r.sentinel }
+
+func decodeError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body struct {
+		Error   string   `json:"error"`
+		Field   string   `json:"field"`
+		Reasons []string `json:"reasons"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		e.Message = body.Error
+	} else {
+		e.Message = strings.TrimSpace(string(data))
+	}
+	if body.Field != "" && len(body.Reasons) > 0 {
+		errs := make([]error, len(body.Reasons))
+		for i, text := range body.Reasons {
+			errs[i] = errors.New(text)
+			for _, s := range sentinels {
+				if strings.HasPrefix(text, s.Error()) {
+					errs[i] = &reason{s, text}
+					break
+				}
+			}
+		}
+		e.Err = &message.ValidationError{Field: body.Field, Err: errors.Join(errs...)}
+	}
+	return e
+}
+
+func retryAfter(v string) time.Duration {
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// do sends a request to path and decodes a successful JSON response into
+// out, retrying as described on Client.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.APIKey != "" {
+			req.Header.Set("X-API-Key", c.APIKey)
+		}
+		wait, err := c.attempt(req, out)
+		if err == nil || wait < 0 || attempt >= c.Retries {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff
+			backoff = min(2*backoff, maxBackoff)
+		}
+		select {
+		case <-time.After(min(wait, maxBackoff)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// attempt makes one request. On failure it returns how long to wait
+// before retrying: negative if the request should not be retried, zero for
+// the usual backoff.
+func (c *Client) attempt(req *http.Request, out any) (time.Duration, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if req.Context().Err() != nil || req.Method != http.MethodGet {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := decodeError(resp)
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+		case req.Method == http.MethodGet && (resp.StatusCode == http.StatusBadGateway ||
+			resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout):
+		default:
+			return -1, err
+		}
+		return err.(*Error).RetryAfter, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return -1, fmt.Errorf("client: decoding response: %w", err)
+	}
+	return 0, nil
+}
+
+// GreetOption customizes a greeting.
+type GreetOption func(url.Values)
+
+// Lang asks for a greeting in the given language, such as fr or cs-CZ.
+func Lang(lang string) GreetOption {
+	return func(q url.Values) { q.Set("lang", lang) }
+}
+
+// Style selects a registered greeter, such as formal.
+func Style(style string) GreetOption {
+	return func(q url.Values) { q.Set("style", style) }
+}
+
+// Greet asks the server to greet name, recording the greeting.
+func (c *Client) Greet(ctx context.Context, name string, opts ...GreetOption) (message.Message, error) {
+	q := url.Values{"name": {name}}
+	for _, opt := range opts {
+		opt(q)
+	}
+	var m message.Message
+	if err := c.do(ctx, http.MethodGet, "/greet", q, nil, &m); err != nil {
+		return m, err
+	}
+	return m, c.verify(m)
+}
+
+// ListMessages returns the most recent limit messages, oldest first, or
+// every message the server keeps if limit is negative.
+func (c *Client) ListMessages(ctx context.Context, limit int) ([]message.Message, error) {
+	q := url.Values{}
+	if limit >= 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var ms []message.Message
+	if err := c.do(ctx, http.MethodGet, "/messages", q, nil, &ms); err != nil {
+		return nil, err
+	}
+	for _, m := range ms {
+		if err := c.verify(m); err != nil {
+			return nil, err
+		}
+	}
+	return ms, nil
+}
+
+// PostMessage records m on the server, which fills in the ID, creation
+// time and sender if m has none, and returns the recorded message.
+func (c *Client) PostMessage(ctx context.Context, m message.Message) (message.Message, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	var out message.Message
+	if err := c.do(ctx, http.MethodPost, "/messages", nil, body, &out); err != nil {
+		return out, err
+	}
+	return out, c.verify(out)
+}
+
+// verify checks the signature of m if c has Keys.
+func (c *Client) verify(m message.Message) error {
+	if c.Keys == nil {
+		return nil
+	}
+	if err := c.Keys.Verify(m); err != nil {
+		return fmt.Errorf("client: message %s: %w", m.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+
+	maxFrameSize = 1 << 20
+)
+
+// Filter restricts a stream to greetings in one language and to messages
+// at least as severe as MinSeverity. The zero Filter streams everything.
+type Filter struct {
+	Lang        string
+	MinSeverity message.Severity
+}
+
+// Stream is a live feed of the messages the server records, read from its
+// /ws endpoint.
+type Stream struct {
+	conn net.Conn
+	r    *bufio.Reader
+	wmu  sync.Mutex
+	stop func() bool
+	c    *Client
+}
+
+// ErrStreamClosed is returned by Next once the server has ended the
+// stream, for example because it is shutting down.
+var ErrStreamClosed = errors.New("client: stream closed by server")
+
+// StreamMessages opens a feed of the messages matching f. Cancelling ctx
+// closes the stream.
+func (c *Client) StreamMessages(ctx context.Context, f Filter) (*Stream, error) {
+	u := *c.base
+	u.Path += "/ws"
+	q := url.Values{}
+	if f.Lang != "" {
+		q.Set("lang", f.Lang)
+	}
+	if f.MinSeverity != message.SeverityInfo {
+		q.Set("severity", f.MinSeverity.String())
+	}
+	u.RawQuery = q.Encode()
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"http": "80", "https": "443"}[u.Scheme])
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	s := &Stream{conn: conn, r: bufio.NewReader(conn), c: c}
+	s.stop = context.AfterFunc(ctx, func() { conn.Close() })
+	if err := s.handshake(&u, c.APIKey); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Stream) handshake(u *url.URL, apiKey string) error {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	if err := req.Write(s.conn); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(s.r, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		return decodeError(resp)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return errors.New("client: invalid WebSocket handshake")
+	}
+	return nil
+}
+
+// Next returns the next message, waiting for one to arrive. It answers the
+// server's pings while it waits.
+func (s *Stream) Next() (message.Message, error) {
+	for {
+		op, payload, err := s.readFrame()
+		if err != nil {
+			return message.Message{}, err
+		}
+		switch op {
+		case opText:
+			var m message.Message
+			if err := json.Unmarshal(payload, &m); err != nil {
+				return m, fmt.Errorf("client: decoding message: %w", err)
+			}
+			return m, s.c.verify(m)
+		case opPing:
+			if err := s.write(opPong, payload); err != nil {
+				return message.Message{}, err
+			}
+		case opClose:
+			s.write(opClose, payload)
+			return message.Message{}, ErrStreamClosed
+		}
+	}
+}
+
+func (s *Stream) readFrame() (op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(s.r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	if head[0]&0x80 == 0 {
+		return 0, nil, errors.New("client: fragmented WebSocket frames are not supported")
+	}
+	op = head[0] & 0x0F
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(s.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(s.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxFrameSize {
+		return 0, nil, fmt.Errorf("client: WebSocket frame of %d bytes is too large", n)
+	}
+	payload = make([]byte, n)
+	_, err = io.ReadFull(s.r, payload)
+	return op, payload, err
+}
+
+// write sends a masked frame, as clients must.
+func (s *Stream) write(op byte, payload []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	frame := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+// Close ends the stream.
+func (s *Stream) Close() error {
+	s.stop()
+	s.write(opClose, binary.BigEndian.AppendUint16(nil, 1000))
+	return s.conn.Close()
+}
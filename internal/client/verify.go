@@ -0,0 +1,43 @@
+package client
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery/webhook"
+)
+
+// ErrBadWebhookSignature is returned by VerifyWebhook for a request not
+// signed with the secret, or signed too long ago.
+var ErrBadWebhookSignature = errors.New("client: webhook signature does not match")
+
+// maxWebhookBody bounds the webhook bodies VerifyWebhook reads.
+const maxWebhookBody = 1 << 20
+
+// VerifyWebhook reads the body of a webhook request from serve --webhook,
+// and returns it if it is signed with secret, as serve --webhook-secret,
+// at most maxAge ago, or any time ago if maxAge is zero.
+func VerifyWebhook(r *http.Request, secret string, maxAge time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		return nil, err
+	}
+	secs, err := strconv.ParseInt(r.Header.Get("X-Greeter-Timestamp"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no valid X-Greeter-Timestamp", ErrBadWebhookSignature)
+	}
+	ts := time.Unix(secs, 0)
+	if maxAge > 0 && time.Since(ts).Abs() > maxAge {
+		return nil, fmt.Errorf("%w: timestamp %s is stale", ErrBadWebhookSignature, ts.Format(time.RFC3339))
+	}
+	want := webhook.Sign(secret, ts, body)
+	if !hmac.Equal([]byte(r.Header.Get("X-Greeter-Signature")), []byte(want)) {
+		return nil, ErrBadWebhookSignature
+	}
+	return body, nil
+}
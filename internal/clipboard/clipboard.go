@@ -0,0 +1,119 @@
+// Package clipboard puts text on the system clipboard: with the platform's
+// clipboard program where there is one, and otherwise, as over SSH, where
+// the clipboard that matters is the one of the machine the terminal runs
+// on, with an OSC 52 escape sequence asking the terminal to.
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// MaxOSC52 is the most data Copy sends in an OSC 52 sequence, which many
+// terminals cap at 100000 bytes of base64.
+const MaxOSC52 = 74994
+
+// A program is a clipboard program, with the arguments to copy with,
+// given the media type, and whether it needs an environment variable set.
+type program struct {
+	name string
+	args func(mediaType string) []string
+	env  string
+}
+
+func plain(string) []string { return nil }
+
+// typed appends flag and the media type t to args unless t is plain text,
+// which the programs offer by default, under the names applications ask
+// for it by.
+func typed(args []string, flag, t string) []string {
+	if t == "text/plain" {
+		return args
+	}
+	return append(args, flag, t)
+}
+
+var programs = []program{
+	{name: "wl-copy", env: "WAYLAND_DISPLAY", args: func(t string) []string { return typed(nil, "--type", t) }},
+	{name: "xclip", env: "DISPLAY", args: func(t string) []string { return typed([]string{"-selection", "clipboard"}, "-t", t) }},
+	{name: "xsel", env: "DISPLAY", args: func(string) []string { return []string{"--clipboard", "--input"} }},
+	// Under WSL, clip.exe reaches the Windows clipboard.
+	{name: "clip.exe", args: plain},
+}
+
+// Copy puts data on the clipboard as mediaType, such as text/html, where
+// the clipboard program can say so, or as text otherwise, and returns how:
+// the program's name, or "OSC 52".
+func Copy(ctx context.Context, data []byte, mediaType string) (string, error) {
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+		if p, ok := native(); ok {
+			cmd := exec.CommandContext(ctx, p.name, p.args(mediaType)...)
+			cmd.Stdin = bytes.NewReader(data)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return "", fmt.Errorf("clipboard: %s: %w: %s", p.name, err, bytes.TrimSpace(out))
+			}
+			return p.name, nil
+		}
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return "", fmt.Errorf("clipboard: OSC 52, the only way left, copies only text, not %s", mediaType)
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return "", errors.New("clipboard: no terminal to send OSC 52 to")
+	}
+	defer tty.Close()
+	if err := OSC52(tty, data, os.Getenv("TMUX") != ""); err != nil {
+		return "", err
+	}
+	return "OSC 52", nil
+}
+
+// native returns the clipboard program of this machine.
+func native() (program, bool) {
+	switch runtime.GOOS {
+	case "darwin":
+		return program{name: "pbcopy", args: plain}, true
+	case "windows":
+		return program{name: "clip", args: plain}, true
+	}
+	for _, p := range programs {
+		if p.env != "" && os.Getenv(p.env) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(p.name); err == nil {
+			return p, true
+		}
+	}
+	return program{}, false
+}
+
+// OSC52 writes the OSC 52 sequence setting the clipboard to data to w, a
+// terminal, wrapped for tmux to pass on if tmux is set. Terminals only
+// take text this way, and not all of them take it at all.
+func OSC52(w io.Writer, data []byte, tmux bool) error {
+	if len(data) > MaxOSC52 {
+		return fmt.Errorf("clipboard: %d bytes are too many for OSC 52, which takes %d", len(data), MaxOSC52)
+	}
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString(data) + "\a"
+	if tmux {
+		// tmux passes on a DCS sequence, with its escapes doubled, to the
+		// terminal it runs in.
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	if _, err := io.WriteString(w, seq); err != nil {
+		return fmt.Errorf("clipboard: %w", err)
+	}
+	return nil
+}
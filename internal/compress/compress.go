@@ -0,0 +1,135 @@
+// Package compress compresses message blobs and export files with gzip,
+// and recognizes compressed input by its magic bytes, so that reading it
+// needs no flag.
+//
+// zstd is recognized but not supported: the Go standard library has no
+// implementation of it.
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Algorithm is a compression algorithm, or none.
+type Algorithm string
+
+const (
+	None Algorithm = "none"
+	Gzip Algorithm = "gzip"
+	Zstd Algorithm = "zstd"
+)
+
+// ErrZstd is returned for zstd, which this build cannot compress or
+// decompress.
+var ErrZstd = errors.New("compress: zstd is not supported, as the Go standard library has no zstd; use gzip")
+
+// MinBlob is the size below which Blob leaves data as it is: gzip's
+// header and trailer outweigh what it saves on short greetings.
+const MinBlob = 512
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Parse parses an algorithm name: none, gzip or zstd. zstd is parsed, to
+// report ErrZstd for it.
+func Parse(s string) (Algorithm, error) {
+	switch a := Algorithm(strings.ToLower(s)); a {
+	case None, Gzip:
+		return a, nil
+	case Zstd:
+		return a, ErrZstd
+	}
+	return "", fmt.Errorf("compress: unknown algorithm %q: want none or gzip", s)
+}
+
+// ForName returns the algorithm a file name's extension calls for: gzip
+// for .gz, zstd for .zst and none otherwise.
+func ForName(name string) Algorithm {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return Gzip
+	case strings.HasSuffix(name, ".zst"):
+		return Zstd
+	}
+	return None
+}
+
+// TrimExt returns name without the extension of its compression, as in
+// messages.jsonl for messages.jsonl.gz.
+func TrimExt(name string) string {
+	for _, ext := range []string{".gz", ".zst"} {
+		if s, ok := strings.CutSuffix(name, ext); ok {
+			return s
+		}
+	}
+	return name
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// NewWriter returns a writer compressing to w with a. Closing it flushes
+// the compressed stream but does not close w.
+func NewWriter(w io.Writer, a Algorithm) (io.WriteCloser, error) {
+	switch a {
+	case None, "":
+		return nopCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return nil, ErrZstd
+	}
+	return nil, fmt.Errorf("compress: unknown algorithm %q", a)
+}
+
+// NewReader returns a reader decompressing r as it is read, if r starts
+// with the magic bytes of gzip, or reading r as it is otherwise.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(head, zstdMagic):
+		return nil, ErrZstd
+	}
+	return io.NopCloser(br), nil
+}
+
+// Blob returns data gzipped and true if data is at least MinBlob bytes and
+// gzip makes it smaller, or data and false otherwise.
+func Blob(data []byte) ([]byte, bool) {
+	if len(data) < MinBlob {
+		return data, false
+	}
+	var b bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&b, gzip.BestSpeed)
+	zw.Write(data)
+	zw.Close()
+	if b.Len() >= len(data) {
+		return data, false
+	}
+	return b.Bytes(), true
+}
+
+// Unblob returns the data a Blob call compressed.
+func Unblob(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("compress: %w", err)
+	}
+	return out, nil
+}
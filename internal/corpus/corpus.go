@@ -0,0 +1,188 @@
+// Package corpus keeps a manifest of the snippets highlighters are tested
+// on: the language of each, tags for what it exercises, the number of tokens
+// it should split into, and whether it is known to highlight badly.
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// DefaultManifest is the usual name of a manifest file.
+const DefaultManifest = "corpus.json"
+
+// Entry is a snippet in the manifest.
+type Entry struct {
+	// Path is the snippet's file, relative to the manifest's directory,
+	// with slashes.
+	Path     string   `json:"path"`
+	Language string   `json:"language"`
+	Tags     []string `json:"tags,omitempty"`
+	// Tokens is how many tokens the snippet should split into, or zero if
+	// that is not known.
+	Tokens int `json:"tokens,omitempty"`
+	// KnownBad marks snippets that are known to highlight wrongly, with
+	// the reason in Note, so that their failures are expected.
+	KnownBad bool   `json:"known_bad,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// HasTag reports whether e is tagged tag.
+func (e Entry) HasTag(tag string) bool {
+	return slices.Contains(e.Tags, tag)
+}
+
+// Manifest is the entries of a corpus, kept sorted by path.
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Load reads the manifest in the file name. A missing file is an empty
+// manifest, so that a corpus can start with its first Add.
+func Load(name string) (*Manifest, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &m, nil
+}
+
+// Save writes m to the file name as indented JSON.
+func (m *Manifest) Save(name string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+// Lookup returns the entry for path, or nil.
+func (m *Manifest) Lookup(path string) *Entry {
+	i, ok := slices.BinarySearchFunc(m.Entries, path, func(e Entry, p string) int { return strings.Compare(e.Path, p) })
+	if !ok {
+		return nil
+	}
+	return &m.Entries[i]
+}
+
+// Add adds e, or replaces the entry for its path if replace is set. Tags
+// are sorted and deduplicated.
+func (m *Manifest) Add(e Entry, replace bool) error {
+	if e.Path == "" || e.Language == "" {
+		return errors.New("corpus: an entry needs a path and a language")
+	}
+	if strings.HasPrefix(e.Path, "/") || e.Path == ".." || strings.HasPrefix(e.Path, "../") {
+		return fmt.Errorf("corpus: entry %s is outside the corpus", e.Path)
+	}
+	slices.Sort(e.Tags)
+	e.Tags = slices.Compact(e.Tags)
+	i, found := slices.BinarySearchFunc(m.Entries, e.Path, func(e Entry, p string) int { return strings.Compare(e.Path, p) })
+	switch {
+	case found && !replace:
+		return fmt.Errorf("corpus: %s is already in the manifest", e.Path)
+	case found:
+		m.Entries[i] = e
+	default:
+		m.Entries = slices.Insert(m.Entries, i, e)
+	}
+	return nil
+}
+
+// Select returns the entries of the language, if it is not empty, that
+// have every one of tags.
+func (m *Manifest) Select(language string, tags []string) []Entry {
+	var out []Entry
+	for _, e := range m.Entries {
+		if language != "" && e.Language != language {
+			continue
+		}
+		if !slices.ContainsFunc(tags, func(t string) bool { return !e.HasTag(t) }) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Problem is something wrong with a corpus.
+type Problem struct {
+	// Path is the entry or file the problem is with.
+	Path string
+	Err  error
+	// Expected is set for the problems of known-bad entries.
+	Expected bool
+}
+
+// A Tokenizer returns the tokens of src in language, or false if it has no
+// lexer for the language.
+type Tokenizer func(language string, src []byte) ([]lexer.Token, bool, error)
+
+// Validate checks the corpus in dir against m: each entry's file must
+// exist, and split into the expected number of tokens if tokenize has a
+// lexer for it. The problems of known-bad entries are Expected, and a
+// known-bad entry with none is itself a problem, as it may be fixed. Files
+// in dir matching any of unlisted, such as test.*, that are not in the
+// manifest are problems too.
+func Validate(m *Manifest, dir string, tokenize Tokenizer, unlisted ...string) ([]Problem, error) {
+	var problems []Problem
+	for _, e := range m.Entries {
+		err := check(e, dir, tokenize)
+		switch {
+		case err != nil:
+			problems = append(problems, Problem{Path: e.Path, Err: err, Expected: e.KnownBad})
+		case e.KnownBad:
+			problems = append(problems, Problem{Path: e.Path, Err: errors.New("marked known bad, but it checks out: is it fixed?")})
+		}
+	}
+	for _, pattern := range unlisted {
+		names, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			rel, err := filepath.Rel(dir, name)
+			if err != nil {
+				return nil, err
+			}
+			if rel = filepath.ToSlash(rel); m.Lookup(rel) == nil {
+				problems = append(problems, Problem{Path: rel, Err: errors.New("not in the manifest")})
+			}
+		}
+	}
+	return problems, nil
+}
+
+func check(e Entry, dir string, tokenize Tokenizer) error {
+	src, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.Path)))
+	if err != nil {
+		return err
+	}
+	if e.Tokens == 0 || tokenize == nil {
+		return nil
+	}
+	toks, ok, err := tokenize(e.Language, src)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return nil
+	case len(toks) != e.Tokens:
+		return fmt.Errorf("%d tokens, want %d", len(toks), e.Tokens)
+	}
+	return nil
+}
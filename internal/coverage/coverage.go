@@ -0,0 +1,214 @@
+// Package coverage counts the kinds of token a highlighting corpus exercises
+// in each language, to show which kinds its tests never reach.
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Kinds are the kinds of token coverage is reported for, every kind but EOF.
+var Kinds = func() []lexer.Kind {
+	var ks []lexer.Kind
+	for k := lexer.Illegal; k <= lexer.Operator; k++ {
+		ks = append(ks, k)
+	}
+	return ks
+}()
+
+// Language is the coverage of a language's files.
+type Language struct {
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+	// Untokenized lists the files there was no lexer for, which count
+	// towards no kind.
+	Untokenized []string `json:"untokenized,omitempty"`
+	Tokens      int      `json:"tokens"`
+	// Counts holds the number of tokens of each of Kinds, by name, zero
+	// included.
+	Counts map[string]int `json:"counts"`
+	// Missing lists the kinds no token was of, in the order of Kinds.
+	Missing []string `json:"missing"`
+}
+
+// Report is the coverage of a corpus, by language in order of name, and
+// over all of them.
+type Report struct {
+	Languages []*Language `json:"languages"`
+	Total     *Language   `json:"total"`
+}
+
+// Collector builds a Report from the files of a corpus.
+type Collector struct {
+	langs map[string]*Language
+}
+
+// Add counts the tokens of file, in language.
+func (c *Collector) Add(language, file string, toks []lexer.Token) {
+	l := c.language(language)
+	l.Files = append(l.Files, file)
+	for _, t := range toks {
+		if _, ok := l.Counts[t.Kind.String()]; ok {
+			l.Counts[t.Kind.String()]++
+			l.Tokens++
+		}
+	}
+}
+
+// AddUntokenized records file, in language, as having no lexer.
+func (c *Collector) AddUntokenized(language, file string) {
+	l := c.language(language)
+	l.Files = append(l.Files, file)
+	l.Untokenized = append(l.Untokenized, file)
+}
+
+func (c *Collector) language(name string) *Language {
+	if c.langs == nil {
+		c.langs = make(map[string]*Language)
+	}
+	l := c.langs[name]
+	if l == nil {
+		l = newLanguage(name)
+		c.langs[name] = l
+	}
+	return l
+}
+
+func newLanguage(name string) *Language {
+	l := &Language{Name: name, Counts: make(map[string]int, len(Kinds))}
+	for _, k := range Kinds {
+		l.Counts[k.String()] = 0
+	}
+	return l
+}
+
+// Report returns the coverage counted so far.
+func (c *Collector) Report() *Report {
+	r := &Report{Total: newLanguage("total")}
+	for _, l := range c.langs {
+		r.Languages = append(r.Languages, l)
+		r.Total.Files = append(r.Total.Files, l.Files...)
+		r.Total.Untokenized = append(r.Total.Untokenized, l.Untokenized...)
+		r.Total.Tokens += l.Tokens
+		for name, n := range l.Counts {
+			r.Total.Counts[name] += n
+		}
+	}
+	slices.SortFunc(r.Languages, func(a, b *Language) int { return strings.Compare(a.Name, b.Name) })
+	slices.Sort(r.Total.Files)
+	slices.Sort(r.Total.Untokenized)
+	for _, l := range r.all() {
+		l.Missing = []string{}
+		for _, k := range Kinds {
+			if l.Counts[k.String()] == 0 {
+				l.Missing = append(l.Missing, k.String())
+			}
+		}
+	}
+	return r
+}
+
+// all returns the languages of r and then its total.
+func (r *Report) all() []*Language {
+	return append(slices.Clip(r.Languages), r.Total)
+}
+
+// WriteText writes r as a table, a row per language and a column per kind,
+// with the kinds each language is missing after it.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-12s %6s %7s", "language", "files", "tokens")
+	for _, k := range Kinds {
+		fmt.Fprintf(&b, " %8s", k)
+	}
+	b.WriteString("\n")
+	for _, l := range r.all() {
+		fmt.Fprintf(&b, "%-12s %6d %7d", l.Name, len(l.Files), l.Tokens)
+		for _, k := range Kinds {
+			fmt.Fprintf(&b, " %8d", l.Counts[k.String()])
+		}
+		b.WriteString("\n")
+	}
+	for _, l := range r.Languages {
+		switch {
+		case len(l.Untokenized) == len(l.Files):
+			fmt.Fprintf(&b, "%s: no lexer, so nothing is covered\n", l.Name)
+		case len(l.Missing) > 0:
+			fmt.Fprintf(&b, "%s: no %s tokens\n", l.Name, strings.Join(l.Missing, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes r as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Token coverage</title>
+<style>
+table { border-collapse: collapse; font-family: sans-serif; }
+th, td { padding: 0.25em 0.75em; border: 1px solid #ccc; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+td.missing { background: #fdd; color: #900; }
+tr.total td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Token coverage</h1>
+<table>
+<tr><th>language</th><th>files</th><th>tokens</th>{{range .Kinds}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr{{if .Total}} class="total"{{end}}><td>{{.Name}}</td><td>{{.Files}}</td><td>{{.Tokens}}</td>{{range .Counts}}<td{{if eq . 0}} class="missing"{{end}}>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- range .Rows}}{{if .Untokenized}}
+<p>{{.Name}}: no lexer for {{.Untokenized}}.</p>
+{{- end}}{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes r as an HTML page, with the kinds each language is
+// missing marked.
+func (r *Report) WriteHTML(w io.Writer) error {
+	type row struct {
+		Name        string
+		Files       int
+		Tokens      int
+		Counts      []int
+		Untokenized string
+		Total       bool
+	}
+	data := struct {
+		Kinds []lexer.Kind
+		Rows  []row
+	}{Kinds: Kinds}
+	for _, l := range r.all() {
+		rw := row{Name: l.Name, Files: len(l.Files), Tokens: l.Tokens, Total: l == r.Total}
+		for _, k := range Kinds {
+			rw.Counts = append(rw.Counts, l.Counts[k.String()])
+		}
+		if !rw.Total {
+			rw.Untokenized = strings.Join(l.Untokenized, ", ")
+		}
+		data.Rows = append(data.Rows, rw)
+	}
+	return page.Execute(w, data)
+}
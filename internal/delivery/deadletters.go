@@ -0,0 +1,150 @@
+package delivery
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Failure is a message that could not be delivered.
+type Failure struct {
+	ID string `json:"id"`
+	// Target is where the message was going, as the command line names
+	// it: a publish target, webhook:URL or mailto:addresses.
+	Target   string          `json:"target"`
+	Message  message.Message `json:"message"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+// ErrUnknownFailure is returned by Remove for an ID not in the file.
+var ErrUnknownFailure = errors.New("no such dead letter")
+
+// DeadLetters is a dead-letter store: a file of the failures delivery gave
+// up on, one JSON record per line, for a person to inspect and requeue.
+//
+// Processes appending to the file may run while another lists it, but not
+// while another removes failures from it, which rewrites the file.
+type DeadLetters struct {
+	path string
+	mu   sync.Mutex
+}
+
+// OpenDeadLetters opens the dead-letter store in the file path, creating
+// it if it does not exist. The file may hold credentials in targets, so it
+// is readable by its owner only.
+func OpenDeadLetters(path string) (*DeadLetters, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetters{path: path}, f.Close()
+}
+
+// Add appends f, filling in its ID and failure time if they are unset.
+func (d *DeadLetters) Add(f Failure) error {
+	if f.ID == "" {
+		var b [6]byte
+		rand.Read(b[:])
+		f.ID = hex.EncodeToString(b[:])
+	}
+	if f.FailedAt.IsZero() {
+		f.FailedAt = time.Now()
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	file, err := os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(b, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	return errors.Join(err, file.Close())
+}
+
+// List returns the failures, oldest first.
+func (d *DeadLetters) List() ([]Failure, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.list()
+}
+
+func (d *DeadLetters) list() ([]Failure, error) {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var fs []Failure
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var f Failure
+		if err := json.Unmarshal(sc.Bytes(), &f); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", d.path, line, err)
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// Remove removes the failures with the given IDs, rewriting the file.
+func (d *DeadLetters) Remove(ids ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fs, err := d.list()
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	var buf bytes.Buffer
+	for _, f := range fs {
+		if drop[f.ID] {
+			delete(drop, f.ID)
+			continue
+		}
+		b, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+	}
+	for id := range drop {
+		return fmt.Errorf("%w: %s", ErrUnknownFailure, id)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(buf.Bytes())
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err = errors.Join(err, tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path)
+}
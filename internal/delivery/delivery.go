@@ -0,0 +1,230 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	messagev1 "github.com/fanda-blazek/syntax-highlighting-test/api/message/v1"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Publisher publishes messages to a broker or other downstream system.
+// Publish returns once the system has confirmed it holds the message.
+type Publisher interface {
+	Publish(ctx context.Context, m message.Message) error
+	Close() error
+}
+
+// Encode serializes m as a record: compact JSON, as GET /messages returns
+// it.
+func Encode(m message.Message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Encoding is how a broker publisher serializes the messages it publishes.
+type Encoding string
+
+const (
+	// EncodingJSON is the default, records as Encode writes them.
+	EncodingJSON Encoding = "json"
+	// EncodingProto is the protobuf encoding of greeter.message.v1.Message,
+	// in api/message/v1/message.proto.
+	EncodingProto Encoding = "proto"
+)
+
+// ParseEncoding returns the encoding named s, with the empty string for
+// EncodingJSON.
+func ParseEncoding(s string) (Encoding, error) {
+	switch Encoding(s) {
+	case "", EncodingJSON:
+		return EncodingJSON, nil
+	case EncodingProto:
+		return EncodingProto, nil
+	}
+	return "", fmt.Errorf("unknown encoding %q: want json or proto", s)
+}
+
+// Encode serializes m in encoding e.
+func (e Encoding) Encode(m message.Message) ([]byte, error) {
+	if e == EncodingProto {
+		return messagev1.MarshalMessage(m), nil
+	}
+	return Encode(m)
+}
+
+// ContentType is the media type of the records e encodes.
+func (e Encoding) ContentType() string {
+	if e == EncodingProto {
+		return "application/x-protobuf; messageType=greeter.message.v1.Message"
+	}
+	return "application/json"
+}
+
+// Recorder records the outcome of each delivery of a message to a target,
+// named as a dead letter names it: delivered in attempts attempts if err is
+// nil, or given up on with err. audit.Log is one.
+type Recorder interface {
+	RecordDelivery(ctx context.Context, m message.Message, target string, attempts int, err error)
+}
+
+// ErrDeadLettered wraps the publishing error of a message that went to the
+// dead-letter queue or store instead.
+var ErrDeadLettered = errors.New("message dead-lettered")
+
+// Retrying retries failed publishes as Retry has it, and then hands the
+// message to DLQ, if set, and records it in DeadLetters, if set.
+type Retrying struct {
+	Publisher
+	Retry Retry
+	DLQ   Publisher
+	// DeadLetters keeps the messages that could not be published, with
+	// Target, the publish target, to requeue them to.
+	DeadLetters *DeadLetters
+	Target      string
+	// Audit, if set, records every publish to Target.
+	Audit Recorder
+}
+
+func (r *Retrying) Publish(ctx context.Context, m message.Message) error {
+	attempts, err := r.Retry.Do(ctx, func(ctx context.Context) error {
+		return r.Publisher.Publish(ctx, m)
+	})
+	if r.Audit != nil {
+		r.Audit.RecordDelivery(ctx, m, r.Target, attempts, err)
+	}
+	if err == nil {
+		return nil
+	}
+	if r.DLQ == nil && r.DeadLetters == nil {
+		return err
+	}
+	var dlqErr error
+	if r.DLQ != nil {
+		if e := r.DLQ.Publish(context.WithoutCancel(ctx), m); e != nil {
+			dlqErr = fmt.Errorf("dead-letter queue: %w", e)
+		}
+	}
+	if r.DeadLetters != nil {
+		if e := r.DeadLetters.Add(Failure{Target: r.Target, Message: m, Error: err.Error(), Attempts: attempts}); e != nil {
+			dlqErr = errors.Join(dlqErr, fmt.Errorf("dead-letter store: %w", e))
+		}
+	}
+	if dlqErr != nil {
+		return errors.Join(err, dlqErr)
+	}
+	slog.Warn("message dead-lettered", "id", m.ID, "attempts", attempts, "error", err)
+	return fmt.Errorf("%w: %w", ErrDeadLettered, err)
+}
+
+func (r *Retrying) Close() error {
+	if r.DLQ == nil {
+		return r.Publisher.Close()
+	}
+	return errors.Join(r.Publisher.Close(), r.DLQ.Close())
+}
+
+// File is a Publisher appending records to a file, one per line. It suits
+// dead-letter queues that a person or a script replays later.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func OpenFile(name string) (*File, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f}, nil
+}
+
+func (f *File) Publish(ctx context.Context, m message.Message) error {
+	b, err := Encode(m)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return f.f.Sync()
+}
+
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+const (
+	// queueSize is how many messages an Async publisher holds before it
+	// drops new ones.
+	queueSize      = 1024
+	publishTimeout = 30 * time.Second
+)
+
+// Async publishes messages in the background, in order, so that producers
+// of messages never wait for the broker.
+type Async struct {
+	p     Publisher
+	queue chan message.Message
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewAsync(p Publisher) *Async {
+	a := &Async{p: p, queue: make(chan message.Message, queueSize), done: make(chan struct{})}
+	go a.loop()
+	return a
+}
+
+// Send queues m without blocking. It drops m if the queue is full or a is
+// closed.
+func (a *Async) Send(m message.Message) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.queue <- m:
+	default:
+		slog.Warn("publish queue full, dropping message", "id", m.ID)
+	}
+}
+
+func (a *Async) loop() {
+	defer close(a.done)
+	for m := range a.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		err := a.p.Publish(ctx, m)
+		cancel()
+		if err != nil && !errors.Is(err, ErrDeadLettered) {
+			slog.Warn("publishing message failed", "id", m.ID, "error", err)
+		}
+	}
+}
+
+// Close waits for the queued messages to be published, up to ctx's
+// deadline, and closes the underlying publisher.
+func (a *Async) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return errors.Join(ctx.Err(), a.p.Close())
+	}
+	return a.p.Close()
+}
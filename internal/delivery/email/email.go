@@ -0,0 +1,312 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/render"
+)
+
+// DefaultBatchSize is how many recipients share one SMTP transaction when
+// Sender.BatchSize is unset.
+const DefaultBatchSize = 50
+
+// Sender mails messages through an SMTP relay as multipart/alternative
+// mail with a plain-text and an HTML part. Recipients are sent as Bcc, so
+// they do not see each other.
+type Sender struct {
+	// Addr is the relay's host:port.
+	Addr string
+	// ImplicitTLS connects with TLS from the start, as on port 465.
+	// Otherwise STARTTLS is used if the relay offers it.
+	ImplicitTLS bool
+	// Auth, if set, authenticates with the relay. net/smtp only sends
+	// PLAIN credentials over TLS or to localhost.
+	Auth smtp.Auth
+	From string
+	// Subject is a text/template executed with the message. It defaults
+	// to the message text. The date, number and currency functions of
+	// locale.Locale.Funcs format for Locale, or the environment's locale
+	// if it is empty.
+	Subject string
+	Locale  string
+	// BatchSize caps the recipients of one SMTP transaction.
+	BatchSize int
+	// Retry is how a failed transaction is retried. Relays' 5xx answers
+	// are not retried.
+	Retry delivery.Retry
+	// DeadLetters, if set, keeps the mail Send gave up on, with the
+	// recipients it did not reach.
+	DeadLetters *delivery.DeadLetters
+	// Audit, if set, records the delivery of every batch.
+	Audit delivery.Recorder
+
+	subject *template.Template
+}
+
+// ParseRelay parses a relay URL, smtp://[user:password@]host[:port] or
+// smtps://... for implicit TLS, into the Sender fields it sets. The port
+// defaults to 587 for smtp and 465 for smtps.
+func ParseRelay(s string) (*Sender, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid relay URL: %w", err)
+	}
+	sender := &Sender{Retry: delivery.DefaultRetry}
+	port := "587"
+	switch u.Scheme {
+	case "smtp":
+	case "smtps":
+		sender.ImplicitTLS = true
+		port = "465"
+	default:
+		return nil, errors.New("email: relay URL must start with smtp:// or smtps://")
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("email: relay URL has no host")
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	sender.Addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		sender.Auth = smtp.PlainAuth("", u.User.Username(), password, u.Hostname())
+	}
+	return sender, nil
+}
+
+func (s *Sender) renderSubject(m message.Message) (string, error) {
+	if s.Subject == "" {
+		return subjectLine(m.Text), nil
+	}
+	if s.subject == nil {
+		tag := s.Locale
+		if tag == "" {
+			tag = locale.Detect()
+		}
+		t, err := template.New("subject").Funcs(locale.For(tag).Funcs()).Option("missingkey=error").Parse(s.Subject)
+		if err != nil {
+			return "", fmt.Errorf("email: subject: %w", err)
+		}
+		s.subject = t
+	}
+	var b strings.Builder
+	if err := s.subject.Execute(&b, m); err != nil {
+		return "", fmt.Errorf("email: subject: %w", err)
+	}
+	return subjectLine(b.String()), nil
+}
+
+// subjectLine keeps the first line of s, without markup, as a header can
+// hold only one.
+func subjectLine(s string) string {
+	s = render.MarkdownANSI(s, false)
+	s, _, _ = strings.Cut(s, "\n")
+	return strings.TrimSpace(s)
+}
+
+// Compose returns the mail for m, without recipient headers.
+func (s *Sender) Compose(m message.Message) ([]byte, error) {
+	subject, err := s.renderSubject(m)
+	if err != nil {
+		return nil, err
+	}
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return nil, fmt.Errorf("email: from: %w", err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	parts := []struct{ contentType, text string }{
+		{"text/plain; charset=utf-8", render.MarkdownANSI(m.Text, false) + "\r\n"},
+		{"text/html; charset=utf-8", "<!doctype html>\r\n<html><body>\r\n" + render.MarkdownHTML(m.Text) + "</body></html>\r\n"},
+	}
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(p.text))
+		qp.Close()
+	}
+	mw.Close()
+
+	var b bytes.Buffer
+	header := func(key, value string) { b.WriteString(key + ": " + value + "\r\n") }
+	header("From", from.String())
+	header("To", "undisclosed-recipients:;")
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", m.CreatedAt.Format(time.RFC1123Z))
+	header("Message-ID", "<"+messageID(m.ID)+"@"+domain(from.Address)+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", `multipart/alternative; boundary="`+mw.Boundary()+`"`)
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+// messageID returns id as the local part of a Message-ID header: as it is
+// if it is made of letters, digits and -._ only, as the IDs messages are
+// given are, and otherwise as a hash of it, so that no ID can end the
+// header or add another.
+func messageID(id string) string {
+	if id != "" && strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-._") == "" {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+func domain(address string) string {
+	if _, d, ok := strings.Cut(address, "@"); ok {
+		return d
+	}
+	return "greeter"
+}
+
+// Send mails m to recipients, in batches of at most BatchSize per SMTP
+// transaction, each retried as Retry has it. It stops at the first batch
+// that fails; recipients in the batches before it have been sent the mail,
+// and those from it on are dead-lettered if DeadLetters is set.
+func (s *Sender) Send(ctx context.Context, m message.Message, recipients []string) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	for _, r := range recipients {
+		if _, err := mail.ParseAddress(r); err != nil {
+			return fmt.Errorf("email: recipient %q: %w", r, err)
+		}
+	}
+	data, err := s.Compose(m)
+	if err != nil {
+		return err
+	}
+	size := s.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	for i := 0; i < len(recipients); i += size {
+		batch := recipients[i:min(i+size, len(recipients))]
+		attempts, err := s.Retry.Do(ctx, func(ctx context.Context) error {
+			err := s.send(ctx, data, batch)
+			var tpErr *textproto.Error
+			if errors.As(err, &tpErr) && tpErr.Code >= 500 {
+				return delivery.Permanent(err)
+			}
+			return err
+		})
+		if s.Audit != nil {
+			s.Audit.RecordDelivery(ctx, m, Target(batch), attempts, err)
+		}
+		if err != nil {
+			return s.deadLetter(m, recipients[i:], attempts, err)
+		}
+	}
+	return nil
+}
+
+// deadLetter records that m could not be mailed to recipients, if
+// DeadLetters is set, and returns err, the reason.
+func (s *Sender) deadLetter(m message.Message, recipients []string, attempts int, err error) error {
+	if s.DeadLetters == nil {
+		return err
+	}
+	f := delivery.Failure{Target: Target(recipients), Message: m, Error: err.Error(), Attempts: attempts}
+	if dlErr := s.DeadLetters.Add(f); dlErr != nil {
+		return errors.Join(err, fmt.Errorf("dead-letter store: %w", dlErr))
+	}
+	return fmt.Errorf("%w: %w", delivery.ErrDeadLettered, err)
+}
+
+// Target returns recipients as a dead-letter target, mailto: and the
+// addresses, comma-separated. The relay is not part of it.
+func Target(recipients []string) string {
+	return "mailto:" + strings.Join(recipients, ",")
+}
+
+// send runs one SMTP transaction delivering data to recipients.
+func (s *Sender) send(ctx context.Context, data []byte, recipients []string) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("email: relay address: %w", err)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if s.ImplicitTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	// net/smtp has no context support, so bound the whole exchange by the
+	// context's deadline and close the connection if it is cancelled.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("email: %w", err)
+	}
+	defer c.Close()
+	if !s.ImplicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("email: %w", err)
+			}
+		}
+	}
+	if s.Auth != nil {
+		if err := c.Auth(s.Auth); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	from, _ := mail.ParseAddress(s.From)
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	for _, r := range recipients {
+		addr, _ := mail.ParseAddress(r)
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("email: recipient %s: %w", addr.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+func TestComposeMessageID(t *testing.T) {
+	s := &Sender{From: "Greeter <greeter@example.com>"}
+	tests := []struct {
+		id, want string
+	}{
+		{"0123456789abcdef", "<0123456789abcdef@example.com>"},
+		{"msg-1_a.b", "<msg-1_a.b@example.com>"},
+		{"a\r\nBcc: x@y", ""},
+		{"a>b", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		m := message.NewMessage("hi")
+		m.ID = tt.id
+		data, err := s.Compose(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("ID %q: %v", tt.id, err)
+		}
+		if len(msg.Header) != 7 || msg.Header.Get("Bcc") != "" {
+			t.Errorf("ID %q: headers %v, want the 7 Compose writes", tt.id, msg.Header)
+		}
+		got := msg.Header.Get("Message-ID")
+		switch {
+		case tt.want != "" && got != tt.want:
+			t.Errorf("ID %q: Message-ID %q, want %q", tt.id, got, tt.want)
+		case tt.want == "" && (strings.ContainsAny(got, "\r\n ") || strings.Count(got, "@") != 1 || strings.Count(got, ">") != 1):
+			t.Errorf("ID %q: Message-ID %q, want a hash of it", tt.id, got)
+		}
+	}
+}
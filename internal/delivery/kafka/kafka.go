@@ -0,0 +1,217 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+const clientID = "greeter"
+
+// Producer publishes records to a Kafka topic, keyed by message ID. It
+// waits for all in-sync replicas to acknowledge each record.
+//
+// Partitions are chosen by an FNV-1a hash of the key, which differs from
+// the murmur2 partitioner of the Java client.
+type Producer struct {
+	Topic string
+	// Timeout is how long the leader waits for the replicas.
+	Timeout time.Duration
+	// Encoding is how record values are serialized, JSON if unset. The
+	// content-type header of each record names it.
+	Encoding delivery.Encoding
+
+	bootstrap []string
+
+	mu          sync.Mutex
+	md          *metadata
+	conns       map[string]*conn
+	correlation int32
+}
+
+// NewProducer returns a producer for topic that finds the cluster through
+// the bootstrap brokers, given as host:port.
+func NewProducer(bootstrap []string, topic string) (*Producer, error) {
+	if len(bootstrap) == 0 {
+		return nil, errors.New("kafka: no bootstrap brokers")
+	}
+	if topic == "" {
+		return nil, errors.New("kafka: no topic")
+	}
+	for i, addr := range bootstrap {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			bootstrap[i] = net.JoinHostPort(addr, "9092")
+		}
+	}
+	return &Producer{Topic: topic, Timeout: 10 * time.Second, bootstrap: bootstrap, conns: make(map[string]*conn)}, nil
+}
+
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+// roundTrip sends one request to addr and returns the response body
+// after the correlation ID. It is called with p.mu held.
+func (p *Producer) roundTrip(ctx context.Context, addr string, api, version int16, body []byte) ([]byte, error) {
+	c := p.conns[addr]
+	if c == nil {
+		var d net.Dialer
+		nc, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: %w", err)
+		}
+		c = &conn{nc: nc, r: bufio.NewReader(nc)}
+		p.conns[addr] = c
+	}
+	resp, err := p.exchange(ctx, c, api, version, body)
+	if err != nil {
+		c.nc.Close()
+		delete(p.conns, addr)
+		return nil, fmt.Errorf("kafka: %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func (p *Producer) exchange(ctx context.Context, c *conn, api, version int16, body []byte) ([]byte, error) {
+	deadline, _ := ctx.Deadline()
+	c.nc.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { c.nc.SetDeadline(time.Now()) })
+	defer stop()
+	p.correlation++
+	var req encoder
+	req.int32(0) // size, filled in below
+	req.int16(api)
+	req.int16(version)
+	req.int32(p.correlation)
+	req.string(clientID)
+	req.b = append(req.b, body...)
+	binary.BigEndian.PutUint32(req.b, uint32(len(req.b)-4))
+	if _, err := c.nc.Write(req.b); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > 64<<20 {
+		return nil, fmt.Errorf("invalid response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	if id := int32(binary.BigEndian.Uint32(resp)); id != p.correlation {
+		return nil, fmt.Errorf("response for request %d, want %d", id, p.correlation)
+	}
+	return resp[4:], nil
+}
+
+// refresh looks up the topic's partition leaders from any broker that
+// answers.
+func (p *Producer) refresh(ctx context.Context) error {
+	addrs := slices.Clone(p.bootstrap)
+	if p.md != nil {
+		for _, addr := range p.md.brokers {
+			addrs = append(addrs, addr)
+		}
+	}
+	var e encoder
+	encodeMetadataRequest(&e, p.Topic)
+	var errs []error
+	for _, addr := range addrs {
+		resp, err := p.roundTrip(ctx, addr, apiMetadata, 4, e.b)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		md, err := decodeMetadataResponse(&decoder{b: resp}, p.Topic)
+		if err != nil {
+			return err
+		}
+		if md.topicErr != 0 {
+			return fmt.Errorf("%w: %s", md.topicErr, p.Topic)
+		}
+		if len(md.partitions) == 0 {
+			return fmt.Errorf("kafka: topic %s has no partitions", p.Topic)
+		}
+		p.md = md
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// Publish produces m to its partition and waits for the acknowledgement.
+func (p *Producer) Publish(ctx context.Context, m message.Message) error {
+	value, err := p.Encoding.Encode(m)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.md == nil {
+		if err := p.refresh(ctx); err != nil {
+			return err
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(m.ID))
+	part := p.md.partitions[h.Sum32()%uint32(len(p.md.partitions))]
+	leader, ok := p.md.brokers[part.leader]
+	if !ok || part.err != 0 {
+		p.md = nil
+		return fmt.Errorf("kafka: partition %d of %s has no leader", part.id, p.Topic)
+	}
+	batch := encodeRecordBatch([]byte(m.ID), value, []header{{"content-type", []byte(p.Encoding.ContentType())}}, m.CreatedAt)
+	var e encoder
+	encodeProduceRequest(&e, -1, p.Timeout, p.Topic, part.id, batch)
+	resp, err := p.roundTrip(ctx, leader, apiProduce, 3, e.b)
+	if err != nil {
+		return err
+	}
+	code, _, err := decodeProduceResponse(&decoder{b: resp})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		if code.staleMetadata() {
+			p.md = nil
+		}
+		return code
+	}
+	return nil
+}
+
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, c := range p.conns {
+		c.nc.Close()
+		delete(p.conns, addr)
+	}
+	return nil
+}
+
+// ParseBrokers splits a comma-separated broker list.
+func ParseBrokers(s string) []string {
+	var brokers []string
+	for _, b := range strings.Split(s, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
@@ -0,0 +1,275 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// The subset of the Kafka wire protocol a producer needs: Metadata v4 and
+// Produce v3 with v2 record batches, all in the non-flexible encodings.
+const (
+	apiProduce  = 0
+	apiMetadata = 3
+)
+
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int8(v int8)   { e.b = append(e.b, byte(v)) }
+func (e *encoder) int16(v int16) { e.b = binary.BigEndian.AppendUint16(e.b, uint16(v)) }
+func (e *encoder) int32(v int32) { e.b = binary.BigEndian.AppendUint32(e.b, uint32(v)) }
+func (e *encoder) int64(v int64) { e.b = binary.BigEndian.AppendUint64(e.b, uint64(v)) }
+func (e *encoder) varint(v int64) {
+	e.b = binary.AppendVarint(e.b, v)
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) varbytes(b []byte) {
+	e.varint(int64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+var errShort = errors.New("kafka: short response")
+
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = errShort
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) int8() int8 {
+	if v := d.take(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if v := d.take(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if v := d.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if v := d.take(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+// string reads a string, returning "" for a null one.
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// array reads an array length, treating a null array as empty.
+func (d *decoder) array() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	}
+	if n > len(d.b) {
+		d.err = errShort
+		return 0
+	}
+	return n
+}
+
+type partitionMeta struct {
+	id     int32
+	leader int32
+	err    Error
+}
+
+type metadata struct {
+	brokers    map[int32]string
+	partitions []partitionMeta
+	topicErr   Error
+}
+
+func encodeMetadataRequest(e *encoder, topic string) {
+	e.int32(1)
+	e.string(topic)
+	e.int8(0) // allow_auto_topic_creation
+}
+
+func decodeMetadataResponse(d *decoder, topic string) (*metadata, error) {
+	d.int32() // throttle_time_ms
+	md := &metadata{brokers: make(map[int32]string), topicErr: errUnknownTopicOrPartition}
+	for n := d.array(); n > 0; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		md.brokers[id] = fmt.Sprintf("%s:%d", host, port)
+	}
+	d.string() // cluster_id
+	d.int32()  // controller_id
+	for n := d.array(); n > 0; n-- {
+		code := Error(d.int16())
+		name := d.string()
+		d.int8() // is_internal
+		var partitions []partitionMeta
+		for p := d.array(); p > 0; p-- {
+			pm := partitionMeta{err: Error(d.int16()), id: d.int32(), leader: d.int32()}
+			for r := d.array(); r > 0; r-- {
+				d.int32() // replica_nodes
+			}
+			for r := d.array(); r > 0; r-- {
+				d.int32() // isr_nodes
+			}
+			partitions = append(partitions, pm)
+		}
+		if name == topic {
+			md.topicErr, md.partitions = code, partitions
+		}
+	}
+	return md, d.err
+}
+
+type header struct {
+	key   string
+	value []byte
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeRecordBatch encodes one record as a v2 record batch.
+func encodeRecordBatch(key, value []byte, headers []header, ts time.Time) []byte {
+	var rec encoder
+	rec.int8(0)   // attributes
+	rec.varint(0) // timestamp_delta
+	rec.varint(0) // offset_delta
+	rec.varbytes(key)
+	rec.varbytes(value)
+	rec.varint(int64(len(headers)))
+	for _, h := range headers {
+		rec.varbytes([]byte(h.key))
+		rec.varbytes(h.value)
+	}
+
+	// The CRC covers everything from the attributes to the end.
+	var body encoder
+	body.int16(0) // attributes: no compression, not transactional
+	body.int32(0) // last_offset_delta
+	body.int64(ts.UnixMilli())
+	body.int64(ts.UnixMilli())
+	body.int64(-1) // producer_id
+	body.int16(-1) // producer_epoch
+	body.int32(-1) // base_sequence
+	body.int32(1)  // records
+	body.varint(int64(len(rec.b)))
+	body.b = append(body.b, rec.b...)
+
+	var batch encoder
+	batch.int64(0)                              // base_offset
+	batch.int32(int32(4 + 1 + 4 + len(body.b))) // batch_length, from the leader epoch on
+	batch.int32(-1)                             // partition_leader_epoch
+	batch.int8(2)                               // magic
+	batch.int32(int32(crc32.Checksum(body.b, crc32c)))
+	batch.b = append(batch.b, body.b...)
+	return batch.b
+}
+
+func encodeProduceRequest(e *encoder, acks int16, timeout time.Duration, topic string, partition int32, records []byte) {
+	e.int16(-1) // transactional_id: null
+	e.int16(acks)
+	e.int32(int32(timeout.Milliseconds()))
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.bytes(records)
+}
+
+func decodeProduceResponse(d *decoder) (code Error, offset int64, err error) {
+	code = errUnknownTopicOrPartition
+	for n := d.array(); n > 0; n-- {
+		d.string() // name
+		for p := d.array(); p > 0; p-- {
+			d.int32() // index
+			code = Error(d.int16())
+			offset = d.int64()
+			d.int64() // log_append_time_ms
+		}
+	}
+	d.int32() // throttle_time_ms
+	return code, offset, d.err
+}
+
+// Error is an error code returned by a broker.
+type Error int16
+
+const (
+	errUnknownTopicOrPartition Error = 3
+	errLeaderNotAvailable      Error = 5
+	errNotLeaderOrFollower     Error = 6
+	errRequestTimedOut         Error = 7
+	errMessageTooLarge         Error = 10
+	errNotEnoughReplicas       Error = 19
+	errNotEnoughReplicasAfter  Error = 20
+	errTopicAuthorization      Error = 29
+	errInvalidRecord           Error = 87
+)
+
+var errorNames = map[Error]string{
+	errUnknownTopicOrPartition: "unknown topic or partition",
+	errLeaderNotAvailable:      "leader not available",
+	errNotLeaderOrFollower:     "not leader or follower",
+	errRequestTimedOut:         "request timed out",
+	errMessageTooLarge:         "message too large",
+	errNotEnoughReplicas:       "not enough replicas",
+	errNotEnoughReplicasAfter:  "not enough replicas after append",
+	errTopicAuthorization:      "topic authorization failed",
+	errInvalidRecord:           "invalid record",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return "kafka: " + name
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// staleMetadata reports whether e means the producer should look up the
+// partition leaders again.
+func (e Error) staleMetadata() bool {
+	return e == errUnknownTopicOrPartition || e == errLeaderNotAvailable || e == errNotLeaderOrFollower
+}
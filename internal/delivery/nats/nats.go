@@ -0,0 +1,297 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Publisher publishes records to a NATS subject over the NATS client
+// protocol. The connection is opened on first use and again after an
+// error.
+type Publisher struct {
+	// Subject is the subject messages are published to.
+	Subject string
+	// JetStream waits for the stream bound to Subject to acknowledge each
+	// message. Otherwise a PING round trip confirms the server received it,
+	// which does not mean any subscriber did.
+	JetStream bool
+	// Encoding is how messages are serialized, JSON if unset.
+	Encoding delivery.Encoding
+
+	addr       string
+	tls        bool
+	user, pass string
+	token      string
+
+	mu         sync.Mutex
+	conn       net.Conn
+	r          *bufio.Reader
+	maxPayload int
+	inbox      string
+	seq        int
+}
+
+// New returns a publisher to the server at rawURL,
+// nats://[user:password@|token@]host[:port], or tls://... to require TLS.
+// The port defaults to 4222.
+func New(rawURL, subject string) (*Publisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, errors.New("nats: URL must start with nats:// or tls://")
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("nats: URL has no host")
+	}
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return nil, fmt.Errorf("nats: invalid subject %q", subject)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "4222"
+	}
+	p := &Publisher{Subject: subject, addr: net.JoinHostPort(u.Hostname(), port), tls: u.Scheme == "tls"}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			p.user, p.pass = u.User.Username(), pass
+		} else {
+			p.token = u.User.Username()
+		}
+	}
+	return p, nil
+}
+
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	Headers     bool `json:"headers"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// connect dials the server and completes the handshake. It is called with
+// p.mu held.
+func (p *Publisher) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	var info serverInfo
+	if op, arg, _ := strings.Cut(strings.TrimSpace(line), " "); op != "INFO" || json.Unmarshal([]byte(arg), &info) != nil {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	if p.tls || info.TLSRequired {
+		host, _, _ := net.SplitHostPort(p.addr)
+		tc := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn, r = tc, bufio.NewReader(tc)
+	}
+	connect, _ := json.Marshal(map[string]any{
+		"verbose":       false,
+		"pedantic":      false,
+		"name":          "greeter",
+		"lang":          "go",
+		"protocol":      1,
+		"headers":       info.Headers,
+		"no_responders": info.Headers,
+		"user":          p.user,
+		"pass":          p.pass,
+		"auth_token":    p.token,
+	})
+	var inbox [8]byte
+	rand.Read(inbox[:])
+	p.inbox = "_INBOX." + hex.EncodeToString(inbox[:])
+	cmd := "CONNECT " + string(connect) + "\r\n"
+	if p.JetStream {
+		cmd += "SUB " + p.inbox + ".* 1\r\n"
+	}
+	if _, err := io.WriteString(conn, cmd+"PING\r\n"); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.r, p.maxPayload = conn, r, info.MaxPayload
+	if _, err := p.wait(""); err != nil {
+		p.reset()
+		return err
+	}
+	return nil
+}
+
+func (p *Publisher) reset() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn, p.r = nil, nil
+}
+
+// Publish publishes m to the subject and waits for the confirmation.
+func (p *Publisher) Publish(ctx context.Context, m message.Message) error {
+	data, err := p.Encoding.Encode(m)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+	}
+	if p.maxPayload > 0 && len(data) > p.maxPayload {
+		return fmt.Errorf("nats: message of %d bytes exceeds the server's limit of %d", len(data), p.maxPayload)
+	}
+	deadline, _ := ctx.Deadline()
+	p.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { p.conn.SetDeadline(time.Now()) })
+	defer stop()
+	cmd := "PUB " + p.Subject + " "
+	reply := ""
+	if p.JetStream {
+		p.seq++
+		reply = p.inbox + "." + strconv.Itoa(p.seq)
+		cmd += reply + " "
+	}
+	cmd += strconv.Itoa(len(data)) + "\r\n" + string(data) + "\r\n"
+	if !p.JetStream {
+		cmd += "PING\r\n"
+	}
+	if _, err := io.WriteString(p.conn, cmd); err != nil {
+		p.reset()
+		return fmt.Errorf("nats: %w", err)
+	}
+	ack, err := p.wait(reply)
+	if err != nil {
+		var perr *PublishError
+		if !errors.As(err, &perr) {
+			p.reset()
+		}
+		return fmt.Errorf("nats: %w", err)
+	}
+	if p.JetStream {
+		return parseAck(ack)
+	}
+	return nil
+}
+
+// PublishError is a JetStream publish acknowledgement reporting failure,
+// or a server without responders for the subject.
+type PublishError struct {
+	Code        int
+	Description string
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("publish rejected: %d %s", e.Code, e.Description)
+}
+
+func parseAck(data []byte) error {
+	var ack struct {
+		Stream string `json:"stream"`
+		Error  *struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &ack); err != nil {
+		return fmt.Errorf("nats: invalid acknowledgement: %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("nats: %w", &PublishError{ack.Error.Code, ack.Error.Description})
+	}
+	return nil
+}
+
+// wait reads server operations until the message sent to reply arrives,
+// or a PONG if reply is empty.
+func (p *Publisher) wait(reply string) ([]byte, error) {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		op, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch strings.ToUpper(op) {
+		case "PING":
+			if _, err := io.WriteString(p.conn, "PONG\r\n"); err != nil {
+				return nil, err
+			}
+		case "PONG":
+			if reply == "" {
+				return nil, nil
+			}
+		case "+OK", "INFO":
+		case "-ERR":
+			return nil, fmt.Errorf("server error: %s", strings.Trim(args, "' "))
+		case "MSG", "HMSG":
+			fields := strings.Fields(args)
+			if len(fields) < 3 || op == "HMSG" && len(fields) < 4 {
+				return nil, fmt.Errorf("invalid %s %q", op, args)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid %s %q", op, args)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(p.r, payload); err != nil {
+				return nil, err
+			}
+			payload = payload[:size]
+			if fields[0] != reply {
+				continue
+			}
+			if op == "HMSG" {
+				hdrSize, _ := strconv.Atoi(fields[len(fields)-2])
+				if hdrSize < 0 || hdrSize > size {
+					return nil, fmt.Errorf("invalid HMSG %q", args)
+				}
+				// NATS/1.0 503 is a publish with no stream to receive it.
+				status, _, _ := strings.Cut(string(payload[:hdrSize]), "\r\n")
+				if code := strings.Fields(status); len(code) > 1 && code[1] != "200" {
+					n, _ := strconv.Atoi(code[1])
+					return nil, &PublishError{n, strings.Join(code[2:], " ")}
+				}
+				payload = payload[hdrSize:]
+			}
+			return payload, nil
+		default:
+			return nil, fmt.Errorf("unexpected operation %q", op)
+		}
+	}
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
@@ -0,0 +1,111 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Retry is how failed deliveries are retried: with exponential backoff and
+// jitter, up to a number of attempts.
+type Retry struct {
+	// Attempts is the most attempts made, the first included. Less than
+	// one means one.
+	Attempts int
+	// Backoff is the delay before the second attempt, doubling after each
+	// up to MaxBackoff, if that is set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay, from 0 to 1, that is random,
+	// so that deliveries failing together are not all retried together.
+	Jitter float64
+}
+
+// DefaultRetry makes 4 attempts, the first retry after about a second.
+var DefaultRetry = Retry{Attempts: 4, Backoff: time.Second, MaxBackoff: time.Minute, Jitter: 0.2}
+
+// Retries returns a Retry like DefaultRetry making n retries after the
+// first attempt.
+func Retries(n int) Retry {
+	r := DefaultRetry
+	r.Attempts = n + 1
+	return r
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying will not fix, such as a
+// rejected request.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter marks err as a failure to retry after delay rather than the
+// usual backoff, as a server may ask.
+func RetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err, delay}
+}
+
+// Do calls fn until it succeeds, fails permanently, the attempts run out
+// or ctx is done. It returns the attempts made and fn's last error.
+func (r Retry) Do(ctx context.Context, fn func(context.Context) error) (int, error) {
+	backoff := r.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || IsPermanent(err) || attempt >= r.Attempts {
+			return attempt, err
+		}
+		wait := r.jitter(backoff)
+		var ra *retryAfterError
+		if errors.As(err, &ra) && ra.delay > 0 {
+			wait = ra.delay
+			if r.MaxBackoff > 0 {
+				wait = min(wait, r.MaxBackoff)
+			}
+		}
+		if backoff *= 2; r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// jitter returns d with the Jitter fraction of it random.
+func (r Retry) jitter(d time.Duration) time.Duration {
+	j := min(max(r.Jitter, 0), 1)
+	if j == 0 || d <= 0 {
+		return d
+	}
+	fixed := time.Duration(float64(d) * (1 - j))
+	return fixed + rand.N(d-fixed+1)
+}
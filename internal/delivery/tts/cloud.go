@@ -0,0 +1,173 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultCloudURL is the synthesize endpoint of Google Cloud
+// Text-to-Speech.
+const DefaultCloudURL = "https://texttospeech.googleapis.com/v1/text:synthesize"
+
+// players are the audio players FindPlayer looks for, in order, with the
+// arguments they take before the file.
+var players = [][]string{
+	{"afplay"},
+	{"paplay"},
+	{"aplay", "-q"},
+	{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"},
+}
+
+// regions are the regions voices are asked for in when a locale has only
+// a language, as the API wants both.
+var regions = map[string]string{
+	"cs": "CZ",
+	"de": "DE",
+	"en": "US",
+	"es": "ES",
+	"fr": "FR",
+}
+
+// Cloud is a Provider synthesizing speech with the Google Cloud
+// Text-to-Speech API and playing it on this machine.
+type Cloud struct {
+	// APIKey authenticates with the API.
+	APIKey string
+	// URL is the synthesize endpoint, DefaultCloudURL if empty.
+	URL string
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Player is the command playing a WAV file, given as its last
+	// argument. FindPlayer's if empty.
+	Player []string
+}
+
+// FindPlayer returns the first of afplay, paplay, aplay and ffplay on the
+// PATH, with its arguments.
+func FindPlayer() ([]string, error) {
+	for _, p := range players {
+		if path, err := exec.LookPath(p[0]); err == nil {
+			return append([]string{path}, p[1:]...), nil
+		}
+	}
+	return nil, errors.New("tts: no audio player: install pulseaudio-utils, alsa-utils or ffmpeg")
+}
+
+type synthesizeRequest struct {
+	Input struct {
+		Text string `json:"text"`
+	} `json:"input"`
+	Voice struct {
+		LanguageCode string `json:"languageCode"`
+		Name         string `json:"name,omitempty"`
+	} `json:"voice"`
+	AudioConfig struct {
+		AudioEncoding string `json:"audioEncoding"`
+	} `json:"audioConfig"`
+}
+
+// Speak synthesizes u, in its voice or else the API's default for its
+// locale, and plays it.
+func (c *Cloud) Speak(ctx context.Context, u Utterance) error {
+	audio, err := c.synthesize(ctx, u)
+	if err != nil {
+		return err
+	}
+	player := c.Player
+	if len(player) == 0 {
+		if player, err = FindPlayer(); err != nil {
+			return err
+		}
+	}
+	f, err := os.CreateTemp("", "greeter-tts-*.wav")
+	if err != nil {
+		return fmt.Errorf("tts: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(audio)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("tts: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, player[0], append(player[1:], f.Name())...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("tts: %s: %w: %s", filepath.Base(player[0]), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// synthesize returns u spoken, as a WAV file.
+func (c *Cloud) synthesize(ctx context.Context, u Utterance) ([]byte, error) {
+	var req synthesizeRequest
+	req.Input.Text = u.Text
+	req.Voice.LanguageCode = languageCode(u.Locale)
+	req.Voice.Name = u.Voice
+	req.AudioConfig.AudioEncoding = "LINEAR16"
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.URL
+	if endpoint == "" {
+		endpoint = DefaultCloudURL
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"?key="+url.QueryEscape(c.APIKey), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("tts: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		// The error includes the URL, and so the key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("tts: %w", err)
+	}
+	defer resp.Body.Close()
+	var res struct {
+		AudioContent []byte `json:"audioContent"`
+		Error        struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&res); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("tts: decoding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if res.Error.Message != "" {
+			return nil, fmt.Errorf("tts: %s: %s", resp.Status, res.Error.Message)
+		}
+		return nil, fmt.Errorf("tts: %s", resp.Status)
+	}
+	return res.AudioContent, nil
+}
+
+// languageCode returns a normalized locale, such as cs-cz or cs, as the
+// BCP 47 tag the API takes, cs-CZ.
+func languageCode(tag string) string {
+	lang, region, ok := strings.Cut(tag, "-")
+	if !ok {
+		if region, ok = regions[lang]; !ok {
+			return lang
+		}
+	}
+	return lang + "-" + strings.ToUpper(region)
+}
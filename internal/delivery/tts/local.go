@@ -0,0 +1,74 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// programs are the speech programs FindLocal looks for, in order.
+var programs = []string{"say", "espeak-ng", "espeak"}
+
+// sayVoices are voices macOS's say has for the locales greeter has
+// translations for.
+var sayVoices = map[string]string{
+	"cs": "Zuzana",
+	"de": "Anna",
+	"en": "Samantha",
+	"es": "Monica",
+	"fr": "Thomas",
+}
+
+// Local is a Provider running a speech program on this machine: say, as
+// on macOS, or espeak-ng, espeak or another program taking espeak's
+// options. The text is written to its standard input.
+type Local struct {
+	// Program is the name or path of the program.
+	Program string
+}
+
+// FindLocal returns a Local for the first of say, espeak-ng and espeak on
+// the PATH.
+func FindLocal() (*Local, error) {
+	for _, name := range programs {
+		if path, err := exec.LookPath(name); err == nil {
+			return &Local{Program: path}, nil
+		}
+	}
+	return nil, errors.New("tts: no speech program: install espeak-ng, or use say on macOS")
+}
+
+// Speak runs the program for u. Without a voice, say gets its voice for
+// u's language, and espeak the language itself, which espeak names its
+// voices by.
+func (l *Local) Speak(ctx context.Context, u Utterance) error {
+	name := strings.TrimSuffix(filepath.Base(l.Program), ".exe")
+	voice := u.Voice
+	var args []string
+	if name == "say" {
+		if voice == "" {
+			voice = sayVoices[language(u.Locale)]
+		}
+	} else {
+		args = append(args, "--stdin")
+		if voice == "" {
+			voice = language(u.Locale)
+		}
+	}
+	if voice != "" {
+		args = append(args, "-v", voice)
+	}
+	cmd := exec.CommandContext(ctx, l.Program, args...)
+	cmd.Stdin = strings.NewReader(u.Text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if out = bytes.TrimSpace(out); len(out) > 0 {
+			return fmt.Errorf("tts: %s: %w: %s", name, err, out)
+		}
+		return fmt.Errorf("tts: %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package tts speaks messages aloud, for kiosks that greet people as they
+// arrive. A Publisher hands each message's text to a Provider, a local
+// speech program or a cloud speech API, in the voice configured for the
+// message's locale.
+package tts
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/render"
+)
+
+// Utterance is text to speak.
+type Utterance struct {
+	Text string
+	// Locale is the language of the text, normalized, such as cs-cz.
+	Locale string
+	// Voice is the provider's name for the voice to speak in, or empty for
+	// the provider's choice for Locale.
+	Voice string
+}
+
+// Provider speaks utterances. Speak returns once the text has been
+// spoken.
+type Provider interface {
+	Speak(ctx context.Context, u Utterance) error
+}
+
+// Publisher is a delivery.Publisher speaking each message with Provider.
+// Messages are spoken one at a time, so that they do not talk over each
+// other.
+type Publisher struct {
+	Provider Provider
+	// Voices maps locales, such as cs or en-gb, to the voices to speak
+	// in. A message's locale is looked up, then its language.
+	Voices map[string]string
+	// Locale is the locale of messages that a greeter did not record one
+	// on, the environment's if empty.
+	Locale string
+
+	mu sync.Mutex
+}
+
+// New returns a Publisher speaking with provider.
+func New(provider Provider) *Publisher {
+	return &Publisher{Provider: provider}
+}
+
+func (p *Publisher) Publish(ctx context.Context, m message.Message) error {
+	text := strings.TrimSpace(render.MarkdownANSI(m.Text, false))
+	if text == "" {
+		return nil
+	}
+	tag, ok := greeting.MessageLocale(m)
+	if !ok {
+		if tag = p.Locale; tag == "" {
+			tag = locale.Detect()
+		}
+	}
+	tag = locale.Normalize(tag)
+	u := Utterance{Text: text, Locale: tag, Voice: p.voice(tag)}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Provider.Speak(ctx, u)
+}
+
+// voice returns the voice Voices give for tag or its language.
+func (p *Publisher) voice(tag string) string {
+	if v, ok := p.Voices[tag]; ok {
+		return v
+	}
+	return p.Voices[language(tag)]
+}
+
+func (p *Publisher) Close() error {
+	return nil
+}
+
+// language returns the language of a normalized locale, such as cs for
+// cs-cz.
+func language(tag string) string {
+	lang, _, _ := strings.Cut(tag, "-")
+	return lang
+}
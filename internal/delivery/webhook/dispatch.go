@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// queueSize is how many messages may wait for delivery before new ones
+// are dropped.
+const queueSize = 256
+
+// Dispatcher delivers messages to its hooks in the background, in the
+// order they were sent.
+type Dispatcher struct {
+	client *Client
+	hooks  []*Hook
+	queue  chan message.Message
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewDispatcher starts delivering to hooks with c, or NewClient() if c is
+// nil. Close it to stop.
+func NewDispatcher(c *Client, hooks ...Hook) *Dispatcher {
+	if c == nil {
+		c = NewClient()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		client: c,
+		queue:  make(chan message.Message, queueSize),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	for _, h := range hooks {
+		d.hooks = append(d.hooks, &h)
+	}
+	go d.loop(ctx)
+	return d
+}
+
+// Send queues m for delivery without blocking. It drops m if the queue is
+// full or d is closed.
+func (d *Dispatcher) Send(m message.Message) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- m:
+	default:
+		slog.Warn("webhook queue full, dropping message", "id", m.ID)
+	}
+}
+
+func (d *Dispatcher) loop(ctx context.Context) {
+	defer close(d.done)
+	for m := range d.queue {
+		var wg sync.WaitGroup
+		for _, h := range d.hooks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := d.client.Deliver(ctx, h, m); err != nil {
+					if errors.Is(err, delivery.ErrDeadLettered) {
+						slog.Warn("webhook delivery dead-lettered", "id", m.ID, "url", redact(h.URL), "error", err)
+						return
+					}
+					slog.Warn("webhook delivery failed", "id", m.ID, "error", err)
+					return
+				}
+				slog.Debug("webhook delivered", "id", m.ID, "url", redact(h.URL))
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// Close stops accepting messages and waits for the queued ones to be
+// delivered, abandoning them when ctx is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+	defer d.cancel()
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,267 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Format selects the payload a hook receives.
+type Format string
+
+const (
+	// FormatJSON posts the message itself, as GET /messages returns it.
+	FormatJSON    Format = "json"
+	FormatSlack   Format = "slack"
+	FormatDiscord Format = "discord"
+)
+
+var builtinTemplates = map[Format]string{
+	FormatSlack:   `{"text": {{json .Text}}}`,
+	FormatDiscord: `{"content": {{json .Text}}, "username": {{json .Sender}}}`,
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Hook is a webhook endpoint.
+type Hook struct {
+	URL    string
+	Format Format
+	// Template overrides the payload with a text/template executed with
+	// the message. Quote strings with the json function: {{json .Text}}.
+	// The date, number and currency functions of locale.Locale.Funcs
+	// format for Locale, or the environment's locale if it is empty.
+	Template string
+	Locale   string
+	// Secret, if set, signs each request; see Sign.
+	Secret string
+
+	compiled bool
+	tmpl     *template.Template
+}
+
+// ParseHook parses a hook written as URL or FORMAT=URL, where FORMAT is
+// json (the default), slack or discord.
+func ParseHook(s string) (Hook, error) {
+	h := Hook{URL: s, Format: FormatJSON}
+	if format, rest, ok := strings.Cut(s, "="); ok && !strings.Contains(format, ":") {
+		h.Format, h.URL = Format(format), rest
+	}
+	return h, h.compile()
+}
+
+// SetTemplate makes text, a template as for Template, the payload of h,
+// reporting the error if it does not parse.
+func (h *Hook) SetTemplate(text string) error {
+	h.Template, h.tmpl, h.compiled = text, nil, false
+	return h.compile()
+}
+
+// compile checks h and parses its payload template.
+func (h *Hook) compile() error {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook: invalid URL %q", redact(h.URL))
+	}
+	text := h.Template
+	switch h.Format {
+	case "", FormatJSON:
+	case FormatSlack, FormatDiscord:
+		if text == "" {
+			text = builtinTemplates[h.Format]
+		}
+	default:
+		return fmt.Errorf("webhook: unknown format %q: want json, slack or discord", h.Format)
+	}
+	if text != "" {
+		tag := h.Locale
+		if tag == "" {
+			tag = locale.Detect()
+		}
+		if h.tmpl, err = template.New("payload").Funcs(funcs).Funcs(locale.For(tag).Funcs()).Option("missingkey=error").Parse(text); err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+	}
+	h.compiled = true
+	return nil
+}
+
+// Payload returns the request body h receives for m.
+func (h *Hook) Payload(m message.Message) ([]byte, error) {
+	if !h.compiled {
+		if err := h.compile(); err != nil {
+			return nil, err
+		}
+	}
+	if h.tmpl == nil {
+		return json.Marshal(m)
+	}
+	var b bytes.Buffer
+	if err := h.tmpl.Execute(&b, m); err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// Sign returns the X-Greeter-Signature value for body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256, keyed with secret, of the Unix
+// timestamp, a dot and the body. Receivers should recompute it from the
+// X-Greeter-Timestamp header and reject stale timestamps.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// StatusError is returned when a hook answers with a status other than 2xx.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Client posts messages to hooks, retrying network errors, 429 and 5xx
+// answers.
+type Client struct {
+	HTTPClient *http.Client
+	// Retry is how failed deliveries are retried. A Retry-After header
+	// from the hook takes precedence over its backoff.
+	Retry delivery.Retry
+	// DeadLetters, if set, keeps the messages a delivery gave up on.
+	DeadLetters *delivery.DeadLetters
+	// Audit, if set, records every delivery.
+	Audit delivery.Recorder
+}
+
+const maxBackoff = time.Minute
+
+// NewClient returns a client retrying as delivery.DefaultRetry.
+func NewClient() *Client {
+	return &Client{HTTPClient: &http.Client{Timeout: 10 * time.Second}, Retry: delivery.DefaultRetry}
+}
+
+// Deliver posts m to h, retrying until it succeeds, fails permanently, the
+// attempts run out or ctx is done, and then dead-letters m if it failed.
+func (c *Client) Deliver(ctx context.Context, h *Hook, m message.Message) error {
+	body, err := h.Payload(m)
+	if err != nil {
+		return err
+	}
+	attempts, err := c.Retry.Do(ctx, func(ctx context.Context) error {
+		return c.post(ctx, h, m.ID, body)
+	})
+	if c.Audit != nil {
+		c.Audit.RecordDelivery(ctx, m, h.Target(), attempts, err)
+	}
+	if err == nil || c.DeadLetters == nil {
+		return err
+	}
+	f := delivery.Failure{Target: h.Target(), Message: m, Error: err.Error(), Attempts: attempts}
+	if dlErr := c.DeadLetters.Add(f); dlErr != nil {
+		return errors.Join(err, fmt.Errorf("dead-letter store: %w", dlErr))
+	}
+	return fmt.Errorf("%w: %w", delivery.ErrDeadLettered, err)
+}
+
+// Target returns h as a dead-letter target: webhook: and h as ParseHook
+// takes it. The secret and template are not part of it.
+func (h *Hook) Target() string {
+	if h.Format == "" || h.Format == FormatJSON {
+		return "webhook:" + h.URL
+	}
+	return "webhook:" + string(h.Format) + "=" + h.URL
+}
+
+// post makes one delivery attempt. Failures not worth retrying are marked
+// delivery.Permanent, and those the hook asked to be retried later
+// delivery.RetryAfter.
+func (c *Client) post(ctx context.Context, h *Hook, id string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return delivery.Permanent(err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "greeter-webhook")
+	req.Header.Set("X-Greeter-Delivery", id)
+	req.Header.Set("X-Greeter-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	if h.Secret != "" {
+		req.Header.Set("X-Greeter-Signature", Sign(h.Secret, now, body))
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Errors from the client carry the URL, which may hold a token.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = redact(h.URL)
+		}
+		if ctx.Err() != nil {
+			return delivery.Permanent(err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = &StatusError{URL: redact(h.URL), StatusCode: resp.StatusCode}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return delivery.Permanent(err)
+	}
+	return delivery.RetryAfter(err, min(retryAfter(resp.Header.Get("Retry-After"), now), maxBackoff))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date. It
+// returns zero if the header is missing or invalid.
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// redact drops the path and query from a hook URL for errors and logs:
+// Slack and Discord URLs embed their credentials.
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host + "/..."
+}
@@ -0,0 +1,185 @@
+package detect
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+)
+
+// sniffLimit bounds how much of a file the content heuristics read.
+const sniffLimit = 16 << 10
+
+// A clue is a pattern suggesting a language, worth weight for each match,
+// up to three.
+type clue struct {
+	re     *regexp.Regexp
+	weight int
+}
+
+// languageClues hold the clues to each language. They are lightweight:
+// enough to tell the corpus languages apart, not to parse them.
+var languageClues = map[string][]clue{
+	// JSON is scored by jsonScore instead, and TSX as both TypeScript and
+	// JSX.
+	"json": nil,
+	"tsx":  nil,
+	"go": {
+		{regexp.MustCompile(`(?m)^package \w+\s*$`), 5},
+		{regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`), 3},
+		{regexp.MustCompile(`(?m)^import \(\s*$`), 3},
+		{regexp.MustCompile(`\w+ := `), 1},
+	},
+	"python": {
+		{regexp.MustCompile(`(?m)^\s*def \w+\(.*\)( -> [^:]+)?:\s*$`), 3},
+		{regexp.MustCompile(`(?m)^from [\w.]+ import `), 3},
+		{regexp.MustCompile(`(?m)^import \w+(\.\w+)*\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*(elif|except)\b.*:\s*$`), 3},
+		{regexp.MustCompile(`\bself\.\w+`), 1},
+	},
+	"ruby": {
+		{regexp.MustCompile(`(?m)^\s*def \w+[?!]?(\(.*\))?\s*$`), 2},
+		{regexp.MustCompile(`(?m)^\s*end\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*(require|require_relative) ['"]`), 3},
+		{regexp.MustCompile(`\battr_(accessor|reader|writer)\b`), 3},
+		{regexp.MustCompile(`(?m)^\s*puts\b`), 2},
+		{regexp.MustCompile(`\.each do \|`), 3},
+	},
+	"rust": {
+		{regexp.MustCompile(`(?m)^\s*(pub )?fn \w+(<[^>]*>)?\(`), 3},
+		{regexp.MustCompile(`\blet mut\b`), 3},
+		{regexp.MustCompile(`(?m)^use \w+(::\w+)+`), 3},
+		{regexp.MustCompile(`(?m)^\s*impl\b`), 3},
+		{regexp.MustCompile(`\w+!\(`), 1},
+	},
+	"javascript": {
+		{regexp.MustCompile(`(?m)^\s*function\s*\w*\(`), 2},
+		{regexp.MustCompile(`(?m)^\s*(const|let|var) \w+ = `), 1},
+		{regexp.MustCompile(`=> `), 1},
+		{regexp.MustCompile(`\bconsole\.log\(`), 2},
+		{regexp.MustCompile(`\brequire\(['"]`), 2},
+		{regexp.MustCompile(`module\.exports`), 3},
+	},
+	"typescript": {
+		{regexp.MustCompile(`(?m)^\s*(export )?(interface|type) \w+(<[^>]*>)? (=|\{)`), 4},
+		{regexp.MustCompile(`\w+\??: (string|number|boolean|any|unknown|void)\b`), 2},
+		{regexp.MustCompile(`(?m)^\s*(private|public|readonly) \w+`), 2},
+	},
+	"jsx": {
+		{regexp.MustCompile(`return \(\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*<[A-Za-z][\w.]*( [\w-]+=|>|/>)`), 1},
+		{regexp.MustCompile(`from ['"]react['"]`), 4},
+		{regexp.MustCompile(`className=`), 3},
+	},
+	"php": {
+		{regexp.MustCompile(`<\?php\b`), 10},
+		{regexp.MustCompile(`\$\w+ = `), 1},
+		{regexp.MustCompile(`(?m)^\s*echo\b`), 1},
+	},
+	"html": {
+		{regexp.MustCompile(`(?i)<!doctype html`), 10},
+		{regexp.MustCompile(`(?i)<(html|head|body)[ >]`), 4},
+		{regexp.MustCompile(`(?i)</(div|span|p|a|li)>`), 1},
+	},
+	"css": {
+		{regexp.MustCompile(`(?m)^[.#]?[\w-]+(\s*[,>+~]?\s*[.#:]?[\w-]+)*\s*\{\s*$`), 2},
+		{regexp.MustCompile(`(?m)^\s+[a-z-]+:\s*[^;]+;\s*$`), 1},
+		{regexp.MustCompile(`@media\b`), 3},
+	},
+	"yaml": {
+		{regexp.MustCompile(`(?m)^---\s*$`), 3},
+		{regexp.MustCompile(`(?m)^[\w-]+:( .*)?$`), 1},
+		{regexp.MustCompile(`(?m)^\s*- [\w"']`), 1},
+	},
+	"sql": {
+		{regexp.MustCompile(`(?im)^\s*(select|insert into|update|delete from|create table|alter table)\b`), 3},
+		{regexp.MustCompile(`(?i)\b(from|where|join|group by|order by)\b`), 1},
+	},
+	"shell": {
+		{regexp.MustCompile(`(?m)^\s*(if|while) \[{1,2} `), 3},
+		{regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`), 3},
+		{regexp.MustCompile(`(?m)^\s*(echo|export|local) `), 1},
+		{regexp.MustCompile(`\$\{?\w+\}?`), 1},
+	},
+	"c": {
+		{regexp.MustCompile(`(?m)^#include <\w+\.h>`), 3},
+		{regexp.MustCompile(`(?m)^(static )?(int|void|char) \*?\w+\(`), 2},
+		{regexp.MustCompile(`\b(printf|malloc|sizeof)\(`), 1},
+	},
+	"cpp": {
+		{regexp.MustCompile(`(?m)^#include <\w+>`), 3},
+		{regexp.MustCompile(`\bstd::`), 3},
+		{regexp.MustCompile(`(?m)^\s*(class|namespace|template)\b`), 3},
+	},
+	"objective-c": {
+		{regexp.MustCompile(`(?m)^@(interface|implementation|end)\b`), 5},
+		{regexp.MustCompile(`(?m)^#import `), 3},
+	},
+	"matlab": {
+		{regexp.MustCompile(`(?m)^\s*function .*=`), 3},
+		{regexp.MustCompile(`(?m)^\s*%`), 1},
+		{regexp.MustCompile(`(?m)^\s*end\s*$`), 1},
+	},
+}
+
+// bestScore returns the name among candidates, or every language with
+// clues if candidates is nil, whose clues content matches best, with a
+// confidence, or "" if none match.
+func bestScore(content []byte, candidates []string) (string, float64) {
+	if len(content) > sniffLimit {
+		content = content[:sniffLimit]
+	}
+	if candidates == nil {
+		for name := range languageClues {
+			candidates = append(candidates, name)
+		}
+		// The first of equal scores wins, so fix the order.
+		slices.Sort(candidates)
+	}
+	best, bestScore, second := "", 0, 0
+	for _, name := range candidates {
+		n := score(content, name)
+		if n > bestScore {
+			best, bestScore, second = name, n, bestScore
+		} else if n > second {
+			second = n
+		}
+	}
+	if bestScore == 0 {
+		return "", 0
+	}
+	// Confidence grows with the score and with its lead over the runner-up.
+	strength := min(1, float64(bestScore)/10)
+	lead := float64(bestScore-second) / float64(bestScore)
+	return best, maxContentScore * strength * (0.5 + 0.5*lead)
+}
+
+func score(content []byte, name string) int {
+	switch name {
+	case "json":
+		return jsonScore(content)
+	case "tsx":
+		ts, jsx := score(content, "typescript"), score(content, "jsx")
+		if ts == 0 || jsx == 0 {
+			return 0
+		}
+		return ts + jsx
+	}
+	total := 0
+	for _, c := range languageClues[name] {
+		total += c.weight * len(c.re.FindAllIndex(content, 3))
+	}
+	return total
+}
+
+// jsonScore scores content that is a JSON object or array.
+func jsonScore(content []byte) int {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || trimmed[0] != '{' && trimmed[0] != '[' {
+		return 0
+	}
+	if len(content) < sniffLimit && !json.Valid(trimmed) {
+		return 0
+	}
+	return 10
+}
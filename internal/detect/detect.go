@@ -0,0 +1,187 @@
+// Package detect guesses the language of a source file from its name and
+// content.
+package detect
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Unknown is the name of the language of files nothing recognizes.
+const Unknown = "text"
+
+// Lang is a guess at the language of a file.
+type Lang struct {
+	// Name is the language, such as go or python, named as in the samples
+	// package, or Unknown.
+	Name string
+	// Confidence is how sure the guess is, from 0 for Unknown to 1.
+	Confidence float64
+	// By is what decided the guess: override, filename, extension,
+	// shebang or content, or "" for Unknown.
+	By string
+}
+
+// Confidence of each way of deciding. Content never scores as high as a
+// name, which its author chose.
+const (
+	filenameConfidence  = 0.95
+	extensionConfidence = 0.9
+	shebangConfidence   = 0.85
+	// Ambiguous extensions, such as .h, are decided by content among the
+	// languages they may be.
+	ambiguousConfidence = 0.6
+	maxContentScore     = 0.8
+)
+
+// An Override decides the language of a file before Language looks at it,
+// or returns false to leave it to Language.
+type Override func(filename string, content []byte) (Lang, bool)
+
+var (
+	overridesMu sync.RWMutex
+	overrides   []Override
+)
+
+// RegisterOverride adds an override, consulted before those registered
+// earlier. Lang.By is set to override if it is empty.
+func RegisterOverride(o Override) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	overrides = append(overrides, o)
+}
+
+// filenames are the languages of whole file names, lowercased.
+var filenames = map[string]string{
+	"makefile":      "make",
+	"gnumakefile":   "make",
+	"dockerfile":    "dockerfile",
+	"gemfile":       "ruby",
+	"rakefile":      "ruby",
+	".bashrc":       "shell",
+	".bash_profile": "shell",
+	".profile":      "shell",
+	".zshrc":        "shell",
+	"go.mod":        "gomod",
+}
+
+// extensions are the languages of file extensions, lowercased. Those with
+// several list each candidate, the most common first.
+var extensions = map[string][]string{
+	".go":   {"go"},
+	".json": {"json"},
+	".yaml": {"yaml"},
+	".yml":  {"yaml"},
+	".sql":  {"sql"},
+	".sh":   {"shell"},
+	".bash": {"shell"},
+	".zsh":  {"shell"},
+	".py":   {"python"},
+	".pyw":  {"python"},
+	".rb":   {"ruby"},
+	".rs":   {"rust"},
+	".js":   {"javascript"},
+	".mjs":  {"javascript"},
+	".cjs":  {"javascript"},
+	".jsx":  {"jsx"},
+	".ts":   {"typescript"},
+	".mts":  {"typescript"},
+	".cts":  {"typescript"},
+	".tsx":  {"tsx"},
+	".php":  {"php"},
+	".html": {"html"},
+	".htm":  {"html"},
+	".css":  {"css"},
+	".md":   {"markdown"},
+	".c":    {"c"},
+	".h":    {"c", "cpp"},
+	".cc":   {"cpp"},
+	".cpp":  {"cpp"},
+	".hpp":  {"cpp"},
+	".java": {"java"},
+	".toml": {"toml"},
+	".xml":  {"xml"},
+	".pl":   {"perl"},
+	".m":    {"objective-c", "matlab"},
+}
+
+// interpreters are the languages of shebang interpreters, without version
+// suffixes.
+var interpreters = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"dash":    "shell",
+	"zsh":     "shell",
+	"ksh":     "shell",
+	"python":  "python",
+	"ruby":    "ruby",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"php":     "php",
+	"perl":    "perl",
+}
+
+// Language guesses the language of the file called filename, which may
+// be a path or "", with content. Overrides go first, then the file name,
+// its extension, a #! line, and last what the content looks like.
+func Language(filename string, content []byte) Lang {
+	overridesMu.RLock()
+	for i := len(overrides) - 1; i >= 0; i-- {
+		if l, ok := overrides[i](filename, content); ok {
+			overridesMu.RUnlock()
+			if l.By == "" {
+				l.By = "override"
+			}
+			return l
+		}
+	}
+	overridesMu.RUnlock()
+	base := strings.ToLower(filepath.Base(filename))
+	if name, ok := filenames[base]; ok {
+		return Lang{Name: name, Confidence: filenameConfidence, By: "filename"}
+	}
+	candidates := extensions[filepath.Ext(base)]
+	switch len(candidates) {
+	case 0:
+	case 1:
+		return Lang{Name: candidates[0], Confidence: extensionConfidence, By: "extension"}
+	default:
+		if name, _ := bestScore(content, candidates); name != "" {
+			return Lang{Name: name, Confidence: ambiguousConfidence, By: "extension"}
+		}
+		return Lang{Name: candidates[0], Confidence: ambiguousConfidence / 2, By: "extension"}
+	}
+	if name, ok := shebang(content); ok {
+		return Lang{Name: name, Confidence: shebangConfidence, By: "shebang"}
+	}
+	if name, confidence := bestScore(content, nil); name != "" {
+		return Lang{Name: name, Confidence: confidence, By: "content"}
+	}
+	return Lang{Name: Unknown}
+}
+
+var versionSuffix = regexp.MustCompile(`[0-9.]+$`)
+
+// shebang returns the language of the interpreter on content's #! line,
+// looking past env and its options.
+func shebang(content []byte) (string, bool) {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return "", false
+	}
+	line, _, _ := bytes.Cut(content[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	for len(fields) > 0 {
+		prog := filepath.Base(fields[0])
+		fields = fields[1:]
+		if prog == "env" || strings.HasPrefix(prog, "-") || strings.Contains(prog, "=") {
+			continue
+		}
+		name, ok := interpreters[versionSuffix.ReplaceAllString(prog, "")]
+		return name, ok
+	}
+	return "", false
+}
@@ -0,0 +1,96 @@
+package emoji
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:embed shortcodes.json
+var bundledShortcodes []byte
+
+var (
+	shortcodesMu sync.RWMutex
+	shortcodes   = make(map[string]string)
+)
+
+func init() {
+	if err := json.Unmarshal(bundledShortcodes, &shortcodes); err != nil {
+		panic(fmt.Sprintf("emoji: bundled shortcodes: %v", err))
+	}
+}
+
+// Register adds or replaces a shortcode, given without colons.
+func Register(code, emoji string) {
+	shortcodesMu.Lock()
+	defer shortcodesMu.Unlock()
+	shortcodes[code] = emoji
+}
+
+// Expand replaces :shortcode: sequences with their emoji. Unknown
+// shortcodes are left as they are.
+func Expand(s string) string {
+	shortcodesMu.RLock()
+	defer shortcodesMu.RUnlock()
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, ':')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], ':')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		code := s[start+1 : end]
+		if emoji, ok := shortcodes[code]; ok {
+			b.WriteString(s[:start])
+			b.WriteString(emoji)
+			s = s[end+1:]
+			continue
+		}
+		b.WriteString(s[:end])
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// Describe replaces the emoji of registered shortcodes in s with their
+// shortcodes in words, in parentheses, so that "Hi 👋" reads "Hi (wave)",
+// for screen readers, which announce some emoji poorly and others not at
+// all. The longest emoji is matched first.
+func Describe(s string) string {
+	shortcodesMu.RLock()
+	defer shortcodesMu.RUnlock()
+	names := make(map[string]string, len(shortcodes))
+	longest := 0
+	for code, emoji := range shortcodes {
+		// Of shortcodes for the same emoji, the shortest names it
+		// best, then the first in order.
+		if name, ok := names[emoji]; !ok || len(code) < len(name) || len(code) == len(name) && code < name {
+			names[emoji] = code
+		}
+		longest = max(longest, len(emoji))
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		matched := false
+		for n := min(longest, len(s)-i); n > 0; n-- {
+			if code, ok := names[s[i:i+n]]; ok {
+				b.WriteString("(" + strings.ReplaceAll(code, "_", " ") + ")")
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String()
+}
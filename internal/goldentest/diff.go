@@ -0,0 +1,177 @@
+package goldentest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// Diff returns a unified diff of the lines of a, called aName, and b,
+// called bName, or "" if they are equal. Control characters other than tabs
+// are shown escaped, so that ANSI output reads as text.
+func Diff(aName, bName string, a, b []byte) string {
+	as, bs := splitLines(string(a)), splitLines(string(b))
+	ops := diffLines(as, bs)
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Grow the hunk while changes are within 2*contextLines lines of each
+		// other.
+		start := max(0, i-contextLines)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*contextLines {
+				break
+			}
+		}
+		end = min(len(ops), end+contextLines)
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		hunk := ops[start:end]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunk, true), hunkRange(hunk, false))
+		for _, op := range hunk {
+			out.WriteByte(op.kind)
+			out.WriteString(visible(op.line))
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// op is one line of a diff: ' ' in both, '-' only in a, '+' only in b.
+// ai and bi are its line numbers in a and b, counted from 1, where it has
+// one.
+type op struct {
+	kind   byte
+	line   string
+	ai, bi int
+}
+
+// diffLines returns the edit from a to b that keeps their longest common
+// subsequence of lines.
+func diffLines(a, b []string) []op {
+	// Trim the common prefix and suffix, which for goldens is usually most
+	// of both, to keep the table small.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	// lcs[i][j] is the length of the longest common subsequence of ma[i:]
+	// and mb[j:].
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []op
+	for i := 0; i < pre; i++ {
+		ops = append(ops, op{' ', a[i], i + 1, i + 1})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, op{' ', ma[i], pre + i + 1, pre + j + 1})
+			i++
+			j++
+		case j == len(mb) || i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', ma[i], pre + i + 1, 0})
+			i++
+		default:
+			ops = append(ops, op{'+', mb[j], 0, pre + j + 1})
+			j++
+		}
+	}
+	for k := 0; k < suf; k++ {
+		ai, bi := len(a)-suf+k, len(b)-suf+k
+		ops = append(ops, op{' ', a[ai], ai + 1, bi + 1})
+	}
+	return ops
+}
+
+// hunkRange returns the "start,count" of hunk's lines in a, or b if not
+// inA.
+func hunkRange(hunk []op, inA bool) string {
+	first, count := 0, 0
+	for _, op := range hunk {
+		n := op.bi
+		if inA {
+			n = op.ai
+		}
+		if n == 0 {
+			continue
+		}
+		if count == 0 {
+			first = n
+		}
+		count++
+	}
+	// Hunks have context lines, so a hunk has no lines on one side only if
+	// that side is empty, and then starts at line 0.
+	return fmt.Sprintf("%d,%d", first, count)
+}
+
+// splitLines splits s into lines without their newlines. A last line
+// without a newline is marked, as diff does.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, l := range lines {
+		if trimmed, ok := strings.CutSuffix(l, "\n"); ok {
+			lines[i] = trimmed
+		} else {
+			lines[i] = l + " (no newline at end)"
+		}
+	}
+	return lines
+}
+
+// visible escapes the control characters in line other than tabs.
+func visible(line string) string {
+	if !strings.ContainsFunc(line, isControl) {
+		return line
+	}
+	var b strings.Builder
+	for _, r := range line {
+		switch {
+		case r == 0x1b:
+			b.WriteString(`\e`)
+		case isControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 && r != '\t' || r == 0x7f
+}
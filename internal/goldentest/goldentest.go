@@ -0,0 +1,188 @@
+package goldentest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/htmlrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/pipeline"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/termrender"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// Format is one kind of rendered output kept as goldens.
+type Format struct {
+	// Name is also the extension of the golden files.
+	Name   string
+	Render func(w io.Writer, src []byte, toks []lexer.Token) error
+}
+
+// Formats render HTML with the light theme and ANSI in truecolor with the
+// dark theme. Their options are fixed so that goldens do not depend on the
+// terminal or configuration they were made with.
+var Formats = []Format{
+	{Name: "html", Render: func(w io.Writer, src []byte, toks []lexer.Token) error {
+		return htmlrender.RenderTokens(w, src, toks, htmlrender.Options{Theme: theme.Light})
+	}},
+	{Name: "ansi", Render: func(w io.Writer, src []byte, toks []lexer.Token) error {
+		return termrender.RenderTokens(w, src, toks, termrender.Options{Depth: termrender.DepthTrueColor, Theme: theme.Dark})
+	}},
+}
+
+// Harness renders corpus files and compares them with their goldens.
+type Harness struct {
+	// Dir holds the goldens, at the path of each corpus file with the
+	// format's extension added, such as Dir/test.go.html.
+	Dir     string
+	Formats []Format
+	// Tokenize returns the tokens of a corpus file, and false if it cannot
+	// tokenize files like it. It is called concurrently.
+	Tokenize func(name string, src []byte) ([]lexer.Token, bool, error)
+	// Workers is how many files are rendered at once, or one per core if
+	// zero or less.
+	Workers int
+}
+
+// Status is the outcome for one golden.
+type Status string
+
+const (
+	Match     Status = "ok"
+	Mismatch  Status = "differs"
+	Missing   Status = "missing"
+	Updated   Status = "updated"
+	Unchanged Status = "unchanged"
+	// Skipped files have no tokenizer, so they have no goldens and
+	// cannot be checked.
+	Skipped Status = "skipped"
+)
+
+// Result is the outcome for one corpus file in one format, or for the
+// file in every format if it was skipped.
+type Result struct {
+	File   string
+	Golden string
+	Status Status
+	// Diff is a unified diff from the golden to the output, for a
+	// Mismatch.
+	Diff string
+}
+
+// Verify renders each file in every format and compares the output with
+// its golden.
+func (h *Harness) Verify(files []string) ([]Result, error) {
+	return h.each(files, func(r *Result, got []byte) error {
+		want, err := os.ReadFile(r.Golden)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.Status = Missing
+		case err != nil:
+			return err
+		case bytes.Equal(want, got):
+			r.Status = Match
+		default:
+			r.Status = Mismatch
+			r.Diff = Diff(r.Golden, r.File, want, got)
+		}
+		return nil
+	})
+}
+
+// Update renders each file in every format and writes the output to its
+// golden, if it changed.
+func (h *Harness) Update(files []string) ([]Result, error) {
+	return h.each(files, func(r *Result, got []byte) error {
+		if want, err := os.ReadFile(r.Golden); err == nil && bytes.Equal(want, got) {
+			r.Status = Unchanged
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(r.Golden), 0o755); err != nil {
+			return err
+		}
+		r.Status = Updated
+		return os.WriteFile(r.Golden, got, 0o644)
+	})
+}
+
+// rendered is a corpus file rendered in every format, or nil outputs if it
+// was skipped.
+type rendered struct {
+	file string
+	outs [][]byte
+}
+
+// each renders the files on h.Workers goroutines and checks the outputs
+// against their goldens one at a time, in order.
+func (h *Harness) each(files []string, check func(r *Result, got []byte) error) ([]Result, error) {
+	var results []Result
+	p := pipeline.Start(context.Background(), pipeline.Options{Workers: h.Workers, Ordered: true},
+		func(_ context.Context, file string) (rendered, error) {
+			return h.render(file)
+		},
+		func(pr pipeline.Result[rendered]) error {
+			if pr.Err != nil {
+				return pr.Err
+			}
+			file := pr.Value.file
+			if pr.Value.outs == nil {
+				results = append(results, Result{File: file, Status: Skipped})
+				return nil
+			}
+			for i, f := range h.Formats {
+				r := Result{File: file, Golden: h.golden(file, f)}
+				if err := check(&r, pr.Value.outs[i]); err != nil {
+					return err
+				}
+				results = append(results, r)
+			}
+			return nil
+		})
+	for _, file := range files {
+		if err := p.Submit(file); err != nil {
+			break
+		}
+	}
+	return results, p.Wait()
+}
+
+// render renders file in every format.
+func (h *Harness) render(file string) (rendered, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return rendered{}, err
+	}
+	toks, ok, err := h.Tokenize(file, src)
+	if err != nil {
+		return rendered{}, fmt.Errorf("%s: %w", file, err)
+	}
+	if !ok {
+		return rendered{file: file}, nil
+	}
+	outs := make([][]byte, len(h.Formats))
+	for i, f := range h.Formats {
+		var out bytes.Buffer
+		if err := f.Render(&out, src, toks); err != nil {
+			return rendered{}, err
+		}
+		outs[i] = out.Bytes()
+	}
+	return rendered{file: file, outs: outs}, nil
+}
+
+// golden returns the path of file's golden in format f. Files outside the
+// current directory keep only their base name.
+func (h *Harness) golden(file string, f Format) string {
+	name := filepath.Clean(file)
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		name = filepath.Base(name)
+	}
+	return filepath.Join(h.Dir, name+"."+f.Name)
+}
@@ -0,0 +1,319 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrSkip is returned by a subscription resolver for an event the
+// subscriber did not ask for. Execute then returns nil.
+var ErrSkip = errors.New("graphql: skip event")
+
+// Object is a value of an object type. Resolve returns the value of one of
+// its fields: nil, a scalar or []string, an *Object or a []*Object. Field
+// names and arguments have already been checked against the schema.
+type Object struct {
+	Type    string
+	Resolve func(ctx context.Context, field string, args map[string]any) (any, error)
+}
+
+// Request is the body of a GraphQL request over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type Response struct {
+	Data   *Map     `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorResponse returns a response for a request that failed before
+// execution started.
+func ErrorResponse(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// Map is a JSON object that keeps its keys in selection order.
+type Map struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *Map) set(key string, value any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *Map) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Execute runs op against root, which must be a value of the schema's root
+// type for the operation. Query operations can also select __schema and
+// __type. The response is nil if a resolver returned ErrSkip.
+func (s *Schema) Execute(ctx context.Context, op *Operation, root *Object, variables map[string]any) *Response {
+	e := &executor{ctx: ctx, schema: s, doc: op.doc, vars: make(map[string]any)}
+	for _, def := range op.variables {
+		if v, ok := variables[def.name]; ok {
+			e.vars[def.name] = v
+		} else if def.hasDefault {
+			e.vars[def.name] = def.defaultVal
+		}
+	}
+	e.introspect = op.Type == "query"
+	data := e.selectionSet(root, op.selection, nil)
+	if e.skipped {
+		return nil
+	}
+	return &Response{Data: data, Errors: e.errs}
+}
+
+type executor struct {
+	ctx        context.Context
+	schema     *Schema
+	doc        *Document
+	vars       map[string]any
+	errs       []*Error
+	introspect bool
+	skipped    bool
+}
+
+func (e *executor) fail(path []any, err error) {
+	if errors.Is(err, ErrSkip) {
+		e.skipped = true
+		return
+	}
+	e.errs = append(e.errs, &Error{Message: err.Error(), Path: path})
+}
+
+func (e *executor) selectionSet(obj *Object, sels []selection, path []any) *Map {
+	out := &Map{values: make(map[string]any)}
+	keys, fields := e.collect(obj.Type, sels, make(map[string]bool))
+	for _, key := range keys {
+		fieldPath := append(path[:len(path):len(path)], key)
+		out.set(key, e.field(obj, fields[key], fieldPath))
+	}
+	return out
+}
+
+// collect flattens fragments into the fields selected on an object of type
+// typ, grouped by response key in selection order.
+func (e *executor) collect(typ string, sels []selection, visited map[string]bool) ([]string, map[string][]*selection) {
+	var keys []string
+	fields := make(map[string][]*selection)
+	var walk func(sels []selection)
+	walk = func(sels []selection) {
+		for i := range sels {
+			sel := &sels[i]
+			if !e.included(sel.directives) {
+				continue
+			}
+			switch {
+			case sel.spread != "":
+				frag := e.doc.fragments[sel.spread]
+				if frag == nil {
+					e.fail(nil, fmt.Errorf("unknown fragment %q", sel.spread))
+					continue
+				}
+				if visited[sel.spread] || frag.typeCond != typ {
+					continue
+				}
+				visited[sel.spread] = true
+				walk(frag.selection)
+			case sel.inline:
+				if sel.typeCond == "" || sel.typeCond == typ {
+					walk(sel.selection)
+				}
+			default:
+				key := sel.key()
+				if _, ok := fields[key]; !ok {
+					keys = append(keys, key)
+				}
+				fields[key] = append(fields[key], sel)
+			}
+		}
+	}
+	walk(sels)
+	return keys, fields
+}
+
+func (e *executor) included(ds []directive) bool {
+	for _, d := range ds {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		var cond bool
+		for _, arg := range d.args {
+			if arg.name == "if" {
+				cond, _ = e.resolve(arg.value).(bool)
+			}
+		}
+		if cond == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve substitutes variables in a literal value.
+func (e *executor) resolve(v any) any {
+	switch v := v.(type) {
+	case variableRef:
+		return e.vars[string(v)]
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = e.resolve(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = e.resolve(item)
+		}
+		return out
+	case enumLiteral:
+		return string(v)
+	}
+	return v
+}
+
+func (e *executor) field(obj *Object, sels []*selection, path []any) any {
+	sel := sels[0]
+	if sel.name == "__typename" {
+		return obj.Type
+	}
+	var subsel []selection
+	for _, s := range sels {
+		subsel = append(subsel, s.selection...)
+	}
+	if e.introspect && (sel.name == "__schema" || sel.name == "__type") && obj.Type == e.schema.Query.Name {
+		args, _ := e.arguments(nil, sel.args)
+		v, err := e.schema.introspection(sel.name, args)
+		if err != nil {
+			e.fail(path, err)
+			return nil
+		}
+		return e.complete(v, sel.name, subsel, path)
+	}
+	var def *Field
+	if t := e.schema.lookup(obj.Type); t != nil {
+		if def = t.field(sel.name); def == nil {
+			e.fail(path, unknownField(obj.Type, sel.name))
+			return nil
+		}
+	}
+	args, err := e.arguments(def, sel.args)
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+	v, err := obj.Resolve(e.ctx, sel.name, args)
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+	if v == nil && def != nil && def.Type.Kind == KindNonNull {
+		e.fail(path, fmt.Errorf("%s.%s is non-null but resolved to null", obj.Type, sel.name))
+	}
+	return e.complete(v, sel.name, subsel, path)
+}
+
+// arguments resolves variables in args and coerces them to the argument
+// types of def. Without a definition, as for introspection fields, the
+// values are passed through.
+func (e *executor) arguments(def *Field, args []argument) (map[string]any, error) {
+	out := make(map[string]any, len(args))
+	for _, arg := range args {
+		out[arg.name] = e.resolve(arg.value)
+	}
+	if def == nil {
+		return out, nil
+	}
+	for name := range out {
+		if fieldArg(def, name) == nil {
+			return nil, fmt.Errorf("unknown argument %q on field %q", name, def.Name)
+		}
+	}
+	for _, a := range def.Args {
+		v, err := e.schema.coerce(a.Type, out[a.Name])
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", a.Name, err)
+		}
+		if v == nil {
+			delete(out, a.Name)
+		} else {
+			out[a.Name] = v
+		}
+	}
+	return out, nil
+}
+
+func fieldArg(f *Field, name string) *InputValue {
+	for _, a := range f.Args {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func (e *executor) complete(v any, name string, subsel []selection, path []any) any {
+	switch v := v.(type) {
+	case *Object:
+		if v == nil {
+			return nil
+		}
+		if len(subsel) == 0 {
+			e.fail(path, fmt.Errorf("field %q of type %s must have a selection of subfields", name, v.Type))
+			return nil
+		}
+		return e.selectionSet(v, subsel, path)
+	case []*Object:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = e.complete(item, name, subsel, append(path[:len(path):len(path)], i))
+		}
+		return out
+	}
+	if len(subsel) > 0 && v != nil {
+		e.fail(path, fmt.Errorf("field %q is a scalar and cannot have a selection", name))
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,766 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The parser covers executable documents: operations with variables,
+// fields with aliases, arguments and directives, fragment definitions,
+// fragment spreads and inline fragments.
+
+// Limits on the operations Parse accepts, so that one request cannot make
+// the server do unbounded work. Fragments count as the fields they spread.
+const (
+	// MaxDepth bounds how deeply fields nest: { greet { text } } is 2
+	// deep, and the introspection query of GraphiQL 13.
+	MaxDepth = 15
+	// MaxRootFields bounds the fields an operation selects at its root,
+	// each alias of a field counting as one of its own.
+	MaxRootFields = 20
+)
+
+type Document struct {
+	operations []*Operation
+	fragments  map[string]*fragment
+}
+
+type Operation struct {
+	// Type is "query", "mutation" or "subscription".
+	Type      string
+	Name      string
+	variables []variableDef
+	selection []selection
+	doc       *Document
+}
+
+type variableDef struct {
+	name       string
+	defaultVal any
+	hasDefault bool
+}
+
+type fragment struct {
+	typeCond  string
+	selection []selection
+}
+
+// A selection is a field, a fragment spread (spread is set) or an inline
+// fragment (inline is set).
+type selection struct {
+	alias, name string
+	args        []argument
+	directives  []directive
+	selection   []selection
+
+	spread   string
+	inline   bool
+	typeCond string
+}
+
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argument struct {
+	name  string
+	value any
+}
+
+type directive struct {
+	name string
+	args []argument
+}
+
+// Literal values other than strings, numbers, booleans, null, lists and
+// objects.
+type (
+	enumLiteral string
+	variableRef string
+)
+
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+func Parse(query string) (*Document, error) {
+	p := &parser{src: strings.TrimPrefix(query, "\uFEFF")}
+	p.next()
+	doc := &Document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokEOF {
+		if err := p.definition(doc); err != nil {
+			return nil, err
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if len(doc.operations) == 0 {
+		return nil, &SyntaxError{Offset: 0, Msg: "document has no operation"}
+	}
+	l := &limiter{doc: doc, depths: make(map[string]int), counts: make(map[string]int), visiting: make(map[string]bool)}
+	for _, op := range doc.operations {
+		op.doc = doc
+		if err := l.check(op); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// limiter checks operations against MaxDepth and MaxRootFields, working
+// out the depth and root fields of each fragment once.
+type limiter struct {
+	doc      *Document
+	depths   map[string]int
+	counts   map[string]int
+	visiting map[string]bool
+}
+
+func (l *limiter) check(op *Operation) error {
+	name := op.Name
+	if name == "" {
+		name = "the " + op.Type
+	}
+	depth, err := l.depth(op.selection)
+	if err != nil {
+		return err
+	}
+	if depth > MaxDepth {
+		return fmt.Errorf("%s nests fields %d deep, over the limit of %d", name, depth, MaxDepth)
+	}
+	n, err := l.rootFields(op.selection)
+	if err != nil {
+		return err
+	}
+	if n > MaxRootFields {
+		return fmt.Errorf("%s selects %d root fields, over the limit of %d", name, n, MaxRootFields)
+	}
+	return nil
+}
+
+// depth returns how deeply the fields of sels nest.
+func (l *limiter) depth(sels []selection) (int, error) {
+	depth := 0
+	for i := range sels {
+		sel := &sels[i]
+		var d int
+		var err error
+		switch {
+		case sel.spread != "":
+			d, err = l.fragment(sel.spread, l.depths, l.depth)
+		case sel.inline:
+			d, err = l.depth(sel.selection)
+		default:
+			d, err = l.depth(sel.selection)
+			d++
+		}
+		if err != nil {
+			return 0, err
+		}
+		depth = max(depth, d)
+	}
+	return depth, nil
+}
+
+// rootFields counts the fields of sels, not those nested in them.
+func (l *limiter) rootFields(sels []selection) (int, error) {
+	n := 0
+	for i := range sels {
+		sel := &sels[i]
+		switch {
+		case sel.spread != "":
+			c, err := l.fragment(sel.spread, l.counts, l.rootFields)
+			if err != nil {
+				return 0, err
+			}
+			n += c
+		case sel.inline:
+			c, err := l.rootFields(sel.selection)
+			if err != nil {
+				return 0, err
+			}
+			n += c
+		default:
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fragment returns measure of the fragment name, recorded in memo. An
+// unknown fragment measures 0, for the executor to report.
+func (l *limiter) fragment(name string, memo map[string]int, measure func([]selection) (int, error)) (int, error) {
+	if v, ok := memo[name]; ok {
+		return v, nil
+	}
+	frag := l.doc.fragments[name]
+	if frag == nil {
+		return 0, nil
+	}
+	if l.visiting[name] {
+		return 0, fmt.Errorf("fragment %q spreads itself", name)
+	}
+	l.visiting[name] = true
+	v, err := measure(frag.selection)
+	delete(l.visiting, name)
+	if err != nil {
+		return 0, err
+	}
+	memo[name] = v
+	return v, nil
+}
+
+// Operation returns the operation called name, or the only operation if
+// name is empty.
+func (d *Document) Operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("document has %d operations; operationName is required", len(d.operations))
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string // decoded string literal
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+	err error
+	// nesting counts the selection sets being parsed, which are given up
+	// on well before a deep document's recursion could exhaust the stack.
+	nesting int
+}
+
+func (p *parser) fail(format string, args ...any) error {
+	if p.err == nil {
+		p.err = &SyntaxError{Offset: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+	}
+	return p.err
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		p.tok = token{kind: tokEOF, pos: p.pos}
+		return
+	}
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case ' ', '\t', '\n', '\r', ',':
+			p.pos++
+			continue
+		case '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokPunct, text: "...", pos: start}
+	case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokPunct, text: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, text: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.number(start)
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		p.blockString(start)
+	case c == '"':
+		p.string(start)
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.tok = token{kind: tokEOF, pos: start}
+		p.fail("unexpected character %q", r)
+	}
+}
+
+func (p *parser) number(start int) {
+	kind := tokInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok = token{kind: kind, text: p.src[start:p.pos], pos: start}
+}
+
+func (p *parser) string(start int) {
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		if p.pos < len(p.src) && (p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+			break
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		p.tok = token{kind: tokEOF, pos: start}
+		p.fail("unterminated string")
+		return
+	}
+	p.pos++
+	text := p.src[start:p.pos]
+	// GraphQL string escapes are a subset of JSON's.
+	var value string
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		p.tok = token{kind: tokEOF, pos: start}
+		p.fail("invalid string %s", text)
+		return
+	}
+	p.tok = token{kind: tokString, text: text, value: value, pos: start}
+}
+
+func (p *parser) blockString(start int) {
+	p.pos += 3
+	end := strings.Index(p.src[p.pos:], `"""`)
+	for end > 0 && p.src[p.pos+end-1] == '\\' {
+		next := strings.Index(p.src[p.pos+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		p.tok = token{kind: tokEOF, pos: start}
+		p.fail("unterminated block string")
+		return
+	}
+	raw := strings.ReplaceAll(p.src[p.pos:p.pos+end], `\"""`, `"""`)
+	p.pos += end + 3
+	p.tok = token{kind: tokString, text: p.src[start:p.pos], value: dedentBlockString(raw), pos: start}
+}
+
+// dedentBlockString removes the common indentation and the leading and
+// trailing blank lines of a block string.
+func dedentBlockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+func (p *parser) is(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.is(punct) {
+		return p.fail("expected %q, found %s", punct, p.describe())
+	}
+	p.next()
+	return p.err
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.fail("expected a name, found %s", p.describe())
+	}
+	name := p.tok.text
+	p.next()
+	return name, p.err
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+func (p *parser) definition(doc *Document) error {
+	if p.is("{") {
+		sel, err := p.selectionSet()
+		if err != nil {
+			return err
+		}
+		doc.operations = append(doc.operations, &Operation{Type: "query", selection: sel})
+		return nil
+	}
+	if p.tok.kind != tokName {
+		return p.fail("expected a definition, found %s", p.describe())
+	}
+	switch p.tok.text {
+	case "query", "mutation", "subscription":
+		return p.operation(doc)
+	case "fragment":
+		p.next()
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if p.tok.kind != tokName || p.tok.text != "on" {
+			return p.fail("expected \"on\", found %s", p.describe())
+		}
+		p.next()
+		typeCond, err := p.name()
+		if err != nil {
+			return err
+		}
+		if _, err := p.directives(); err != nil {
+			return err
+		}
+		sel, err := p.selectionSet()
+		if err != nil {
+			return err
+		}
+		if _, dup := doc.fragments[name]; dup {
+			return p.fail("fragment %q is defined twice", name)
+		}
+		doc.fragments[name] = &fragment{typeCond: typeCond, selection: sel}
+		return nil
+	}
+	return p.fail("unexpected %s", p.describe())
+}
+
+func (p *parser) operation(doc *Document) error {
+	op := &Operation{Type: p.tok.text}
+	p.next()
+	if p.tok.kind == tokName {
+		op.Name = p.tok.text
+		p.next()
+	}
+	if p.is("(") {
+		p.next()
+		for !p.is(")") {
+			if err := p.expect("$"); err != nil {
+				return err
+			}
+			name, err := p.name()
+			if err != nil {
+				return err
+			}
+			if err := p.expect(":"); err != nil {
+				return err
+			}
+			if err := p.typeRef(); err != nil {
+				return err
+			}
+			def := variableDef{name: name}
+			if p.is("=") {
+				p.next()
+				if def.defaultVal, err = p.value(true); err != nil {
+					return err
+				}
+				def.hasDefault = true
+			}
+			if _, err := p.directives(); err != nil {
+				return err
+			}
+			op.variables = append(op.variables, def)
+		}
+		p.next()
+	}
+	if _, err := p.directives(); err != nil {
+		return err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return err
+	}
+	op.selection = sel
+	doc.operations = append(doc.operations, op)
+	return nil
+}
+
+// typeRef skips a variable type. Variables are coerced against the
+// argument they are used in instead.
+func (p *parser) typeRef() error {
+	if p.is("[") {
+		p.next()
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.is("!") {
+		p.next()
+	}
+	return p.err
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	// Inline fragments nest selection sets without nesting fields, hence
+	// the allowance over MaxDepth.
+	if p.nesting++; p.nesting > 2*MaxDepth {
+		return nil, p.fail("selection sets nest over %d deep", 2*MaxDepth)
+	}
+	defer func() { p.nesting-- }()
+	var sels []selection
+	for !p.is("}") {
+		if p.tok.kind == tokEOF {
+			return nil, p.fail("unterminated selection set")
+		}
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	p.next()
+	if len(sels) == 0 {
+		return nil, p.fail("empty selection set")
+	}
+	return sels, p.err
+}
+
+func (p *parser) selection() (selection, error) {
+	var s selection
+	var err error
+	if p.is("...") {
+		p.next()
+		switch {
+		case p.tok.kind == tokName && p.tok.text != "on":
+			s.spread = p.tok.text
+			p.next()
+			s.directives, err = p.directives()
+			return s, err
+		case p.tok.kind == tokName:
+			p.next()
+			if s.typeCond, err = p.name(); err != nil {
+				return s, err
+			}
+		}
+		s.inline = true
+		if s.directives, err = p.directives(); err != nil {
+			return s, err
+		}
+		s.selection, err = p.selectionSet()
+		return s, err
+	}
+	if s.name, err = p.name(); err != nil {
+		return s, err
+	}
+	if p.is(":") {
+		p.next()
+		s.alias = s.name
+		if s.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if s.args, err = p.arguments(false); err != nil {
+		return s, err
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return s, err
+	}
+	if p.is("{") {
+		s.selection, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments(constant bool) ([]argument, error) {
+	if !p.is("(") {
+		return nil, nil
+	}
+	p.next()
+	var args []argument
+	for !p.is(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argument{name: name, value: v})
+	}
+	p.next()
+	return args, p.err
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var ds []directive
+	for p.is("@") {
+		p.next()
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, directive{name: name, args: args})
+	}
+	return ds, p.err
+}
+
+func (p *parser) value(constant bool) (any, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, p.fail("invalid integer %s", tok.text)
+		}
+		return n, p.err
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.fail("invalid number %s", tok.text)
+		}
+		return f, p.err
+	case tokString:
+		p.next()
+		return tok.value, p.err
+	case tokName:
+		p.next()
+		switch tok.text {
+		case "true":
+			return true, p.err
+		case "false":
+			return false, p.err
+		case "null":
+			return nil, p.err
+		}
+		return enumLiteral(tok.text), p.err
+	}
+	switch {
+	case p.is("$") && !constant:
+		p.next()
+		name, err := p.name()
+		return variableRef(name), err
+	case p.is("["):
+		p.next()
+		list := []any{}
+		for !p.is("]") {
+			if p.tok.kind == tokEOF {
+				return nil, p.fail("unterminated list")
+			}
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.next()
+		return list, p.err
+	case p.is("{"):
+		p.next()
+		obj := map[string]any{}
+		for !p.is("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		p.next()
+		return obj, p.err
+	}
+	return nil, p.fail("expected a value, found %s", p.describe())
+}
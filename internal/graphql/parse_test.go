@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+// nested returns a query with fields nested depth deep.
+func nested(depth int) string {
+	return strings.Repeat("{ a ", depth) + strings.Repeat("}", depth)
+}
+
+// aliased returns a query selecting the field greet n times, by alias.
+func aliased(n int) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i := range n {
+		b.WriteString(" g")
+		b.WriteString(string(rune('a'+i%26)) + string(rune('a'+i/26)))
+		b.WriteString(`: greet(name: "Ada") { text }`)
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name, query string
+		err         string
+	}{
+		{"depth at the limit", nested(MaxDepth), ""},
+		{"too deep", nested(MaxDepth + 1), "nests fields 16 deep"},
+		{"too deep by fragments", `{ ...A } fragment A on Query { a { ...B } } fragment B on Query ` + nested(MaxDepth), "nests fields 16 deep"},
+		{"too deep by inline fragments", "{ ... on Query " + nested(MaxDepth+1) + " }", "nests fields 16 deep"},
+		{"nesting too deep to parse", strings.Repeat("{ ... ", 2*MaxDepth+1) + strings.Repeat("}", 2*MaxDepth+1), "nest over 30 deep"},
+		{"root fields at the limit", aliased(MaxRootFields), ""},
+		{"too many aliases", aliased(MaxRootFields + 1), "selects 21 root fields"},
+		{"too many root fields by fragments", `{ ...A ...A ...A } fragment A on Query ` + aliased(MaxRootFields/3+1), "selects 21 root fields"},
+		{"fragment cycle", `{ ...A } fragment A on Query { a { ...B } } fragment B on Query { ...A }`, `fragment "A" spreads itself`},
+		{"named operation", "query Q " + nested(MaxDepth+1), "Q nests fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("Parse: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("Parse succeeded, want an error containing %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("Parse: %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
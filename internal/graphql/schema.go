@@ -0,0 +1,400 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+type Kind string
+
+const (
+	KindScalar      Kind = "SCALAR"
+	KindObject      Kind = "OBJECT"
+	KindEnum        Kind = "ENUM"
+	KindInputObject Kind = "INPUT_OBJECT"
+	KindList        Kind = "LIST"
+	KindNonNull     Kind = "NON_NULL"
+)
+
+// Type describes a named type, or a list or non-null wrapper around OfType.
+type Type struct {
+	Kind        Kind
+	Name        string
+	Description string
+	Fields      []*Field      // objects
+	InputFields []*InputValue // input objects
+	EnumValues  []string      // enums
+	OfType      *Type         // lists and non-null types
+}
+
+type Field struct {
+	Name        string
+	Description string
+	Args        []*InputValue
+	Type        *Type
+}
+
+type InputValue struct {
+	Name        string
+	Description string
+	Type        *Type
+}
+
+var (
+	String  = &Type{Kind: KindScalar, Name: "String"}
+	Int     = &Type{Kind: KindScalar, Name: "Int"}
+	Float   = &Type{Kind: KindScalar, Name: "Float"}
+	Boolean = &Type{Kind: KindScalar, Name: "Boolean"}
+	ID      = &Type{Kind: KindScalar, Name: "ID"}
+)
+
+func NonNull(t *Type) *Type { return &Type{Kind: KindNonNull, OfType: t} }
+func ListOf(t *Type) *Type  { return &Type{Kind: KindList, OfType: t} }
+
+func (t *Type) field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// String formats t the way it is written in a schema, e.g. [String!]!.
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindNonNull:
+		return t.OfType.String() + "!"
+	case KindList:
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// Schema lists the named types of an API and its root types. The built-in
+// scalars are added automatically.
+type Schema struct {
+	Query        *Type
+	Mutation     *Type
+	Subscription *Type
+	Types        []*Type
+}
+
+func (s *Schema) lookup(name string) *Type {
+	for _, t := range s.types() {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Schema) types() []*Type {
+	types := []*Type{String, Int, Float, Boolean, ID}
+	for _, t := range []*Type{s.Query, s.Mutation, s.Subscription} {
+		if t != nil {
+			types = append(types, t)
+		}
+	}
+	return append(types, s.Types...)
+}
+
+// coerce converts an argument value, already resolved from variables, to
+// the Go type resolvers receive for t: string, int, float64, bool,
+// []any or map[string]any.
+func (s *Schema) coerce(t *Type, v any) (any, error) {
+	if t.Kind == KindNonNull {
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", t.OfType)
+		}
+		return s.coerce(t.OfType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t.Kind {
+	case KindList:
+		list, ok := v.([]any)
+		if !ok {
+			list = []any{v}
+		}
+		out := make([]any, len(list))
+		for i, item := range list {
+			var err error
+			if out[i], err = s.coerce(t.OfType, item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case KindInputObject:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an input object %s", t.Name)
+		}
+		out := make(map[string]any, len(obj))
+		for name := range obj {
+			if inputField(t, name) == nil {
+				return nil, fmt.Errorf("unknown field %q in %s", name, t.Name)
+			}
+		}
+		for _, f := range t.InputFields {
+			value, err := s.coerce(f.Type, obj[f.Name])
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name, f.Name, err)
+			}
+			if value != nil {
+				out[f.Name] = value
+			}
+		}
+		return out, nil
+	case KindEnum:
+		var name string
+		switch v := v.(type) {
+		case enumLiteral:
+			name = string(v)
+		case string:
+			name = v
+		}
+		for _, e := range t.EnumValues {
+			if e == name {
+				return name, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid %s value %v", t.Name, v)
+	}
+	switch t {
+	case String, ID:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case int64:
+			if t == ID {
+				return strconv.FormatInt(v, 10), nil
+			}
+		}
+	case Int:
+		switch v := v.(type) {
+		case int64:
+			if v == int64(int32(v)) {
+				return int(v), nil
+			}
+		case float64:
+			if v == float64(int32(v)) {
+				return int(v), nil
+			}
+		}
+	case Float:
+		switch v := v.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %v", t.Name, v)
+}
+
+func inputField(t *Type, name string) *InputValue {
+	for _, f := range t.InputFields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// introspection resolves __schema and __type.
+func (s *Schema) introspection(field string, args map[string]any) (any, error) {
+	switch field {
+	case "__schema":
+		return s.schemaObject(), nil
+	case "__type":
+		name, _ := args["name"].(string)
+		if t := s.lookup(name); t != nil {
+			return typeObject(t), nil
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+var directives = []struct {
+	name, description string
+}{
+	{"include", "Includes the field or fragment only when the if argument is true."},
+	{"skip", "Skips the field or fragment when the if argument is true."},
+}
+
+func (s *Schema) schemaObject() *Object {
+	return &Object{Type: "__Schema", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "types":
+			var list []*Object
+			for _, t := range s.types() {
+				list = append(list, typeObject(t))
+			}
+			return list, nil
+		case "queryType":
+			return typeObject(s.Query), nil
+		case "mutationType":
+			if s.Mutation == nil {
+				return nil, nil
+			}
+			return typeObject(s.Mutation), nil
+		case "subscriptionType":
+			if s.Subscription == nil {
+				return nil, nil
+			}
+			return typeObject(s.Subscription), nil
+		case "directives":
+			var list []*Object
+			for _, d := range directives {
+				list = append(list, directiveObject(d.name, d.description))
+			}
+			return list, nil
+		case "description":
+			return nil, nil
+		}
+		return nil, unknownField("__Schema", field)
+	}}
+}
+
+func typeObject(t *Type) *Object {
+	return &Object{Type: "__Type", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "kind":
+			return string(t.Kind), nil
+		case "name":
+			return optional(t.Name), nil
+		case "description":
+			return optional(t.Description), nil
+		case "fields":
+			if t.Kind != KindObject {
+				return nil, nil
+			}
+			list := []*Object{}
+			for _, f := range t.Fields {
+				list = append(list, fieldObject(f))
+			}
+			return list, nil
+		case "interfaces":
+			if t.Kind != KindObject {
+				return nil, nil
+			}
+			return []*Object{}, nil
+		case "possibleTypes", "specifiedByURL", "specifiedByUrl", "isOneOf":
+			return nil, nil
+		case "enumValues":
+			if t.Kind != KindEnum {
+				return nil, nil
+			}
+			list := []*Object{}
+			for _, v := range t.EnumValues {
+				list = append(list, enumValueObject(v))
+			}
+			return list, nil
+		case "inputFields":
+			if t.Kind != KindInputObject {
+				return nil, nil
+			}
+			return inputValueObjects(t.InputFields), nil
+		case "ofType":
+			if t.OfType == nil {
+				return nil, nil
+			}
+			return typeObject(t.OfType), nil
+		}
+		return nil, unknownField("__Type", field)
+	}}
+}
+
+func fieldObject(f *Field) *Object {
+	return &Object{Type: "__Field", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "name":
+			return f.Name, nil
+		case "description":
+			return optional(f.Description), nil
+		case "args":
+			return inputValueObjects(f.Args), nil
+		case "type":
+			return typeObject(f.Type), nil
+		case "isDeprecated":
+			return false, nil
+		case "deprecationReason":
+			return nil, nil
+		}
+		return nil, unknownField("__Field", field)
+	}}
+}
+
+func inputValueObjects(values []*InputValue) []*Object {
+	list := []*Object{}
+	for _, v := range values {
+		list = append(list, &Object{Type: "__InputValue", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+			switch field {
+			case "name":
+				return v.Name, nil
+			case "description":
+				return optional(v.Description), nil
+			case "type":
+				return typeObject(v.Type), nil
+			case "defaultValue", "deprecationReason":
+				return nil, nil
+			case "isDeprecated":
+				return false, nil
+			}
+			return nil, unknownField("__InputValue", field)
+		}})
+	}
+	return list
+}
+
+func enumValueObject(name string) *Object {
+	return &Object{Type: "__EnumValue", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "name":
+			return name, nil
+		case "description", "deprecationReason":
+			return nil, nil
+		case "isDeprecated":
+			return false, nil
+		}
+		return nil, unknownField("__EnumValue", field)
+	}}
+}
+
+func directiveObject(name, description string) *Object {
+	ifArg := []*InputValue{{Name: "if", Type: NonNull(Boolean)}}
+	return &Object{Type: "__Directive", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "name":
+			return name, nil
+		case "description":
+			return description, nil
+		case "locations":
+			return []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}, nil
+		case "args":
+			return inputValueObjects(ifArg), nil
+		case "isRepeatable":
+			return false, nil
+		}
+		return nil, unknownField("__Directive", field)
+	}}
+}
+
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func unknownField(typ, field string) error {
+	return fmt.Errorf("cannot query field %q on type %q", field, typ)
+}
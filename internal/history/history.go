@@ -0,0 +1,71 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Store keeps the most recent messages in a fixed-size ring buffer.
+type Store struct {
+	mu    sync.RWMutex
+	buf   []message.Message
+	next  int
+	count int
+}
+
+func New(capacity int) *Store {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Store{buf: make([]message.Message, capacity)}
+}
+
+func (h *Store) Record(m message.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf[h.next] = m
+	h.next = (h.next + 1) % len(h.buf)
+	if h.count < len(h.buf) {
+		h.count++
+	}
+}
+
+func (h *Store) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.count
+}
+
+// Last returns up to n of the newest messages, oldest first.
+func (h *Store) Last(n int) []message.Message {
+	if n <= 0 {
+		return nil
+	}
+	all := h.Filter(nil)
+	if n < len(all) {
+		all = all[len(all)-n:]
+	}
+	return all
+}
+
+func (h *Store) Since(t time.Time) []message.Message {
+	return h.Filter(func(m message.Message) bool { return !m.CreatedAt.Before(t) })
+}
+
+// Filter returns the messages matching keep, oldest first. A nil keep
+// matches everything.
+func (h *Store) Filter(keep func(message.Message) bool) []message.Message {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var out []message.Message
+	start := (h.next - h.count + len(h.buf)) % len(h.buf)
+	for i := 0; i < h.count; i++ {
+		m := h.buf[(start+i)%len(h.buf)]
+		if keep == nil || keep(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
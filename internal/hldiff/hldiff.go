@@ -0,0 +1,119 @@
+// Package hldiff compares how two highlighters tokenize the same source.
+package hldiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Kind is the kind of a disagreement.
+type Kind string
+
+const (
+	// Boundary disagreements split the same text into different tokens.
+	Boundary Kind = "boundary"
+	// Type disagreements split text into the same tokens, of different
+	// kinds.
+	Type Kind = "type"
+	// OnlyA and OnlyB disagreements are tokens that one engine found where
+	// the other found none, leaving the text unhighlighted.
+	OnlyA Kind = "only-a"
+	OnlyB Kind = "only-b"
+)
+
+// Difference is a run of source that two engines tokenized differently.
+type Difference struct {
+	Kind Kind
+	// Start and End are the offsets of the run in the source.
+	Start, End int
+	// A and B are the tokens each engine has in the run.
+	A, B []lexer.Token
+}
+
+// Compare returns where the tokens a and b of the same source disagree, in
+// source order. Tokens are grouped into runs whose tokens overlap, and a
+// run agrees if it is one token in both with the same text and kind.
+func Compare(a, b []lexer.Token) []Difference {
+	a, b = sorted(a), sorted(b)
+	var diffs []Difference
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		// Start a run at the earlier token and grow it while a token of
+		// either engine starts inside it.
+		var d Difference
+		if j == len(b) || i < len(a) && a[i].Pos.Offset <= b[j].Pos.Offset {
+			d.Start, d.End = a[i].Pos.Offset, a[i].End()
+		} else {
+			d.Start, d.End = b[j].Pos.Offset, b[j].End()
+		}
+		for {
+			if i < len(a) && a[i].Pos.Offset < d.End {
+				d.A = append(d.A, a[i])
+				d.End = max(d.End, a[i].End())
+				i++
+			} else if j < len(b) && b[j].Pos.Offset < d.End {
+				d.B = append(d.B, b[j])
+				d.End = max(d.End, b[j].End())
+				j++
+			} else {
+				break
+			}
+		}
+		if d.Kind = classify(d.A, d.B); d.Kind != "" {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+// classify returns the kind of disagreement between the tokens of a run,
+// or "" if they agree.
+func classify(a, b []lexer.Token) Kind {
+	switch {
+	case len(b) == 0:
+		return OnlyA
+	case len(a) == 0:
+		return OnlyB
+	case len(a) != len(b):
+		return Boundary
+	}
+	kind := Kind("")
+	for i := range a {
+		if a[i].Pos.Offset != b[i].Pos.Offset || a[i].Text != b[i].Text {
+			return Boundary
+		}
+		if a[i].Kind != b[i].Kind {
+			kind = Type
+		}
+	}
+	return kind
+}
+
+// sorted returns toks in source order without empty tokens, which take up
+// no text to disagree about.
+func sorted(toks []lexer.Token) []lexer.Token {
+	out := make([]lexer.Token, 0, len(toks))
+	for _, t := range toks {
+		if t.Kind != lexer.EOF && t.Text != "" {
+			out = append(out, t)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Pos.Offset < out[j].Pos.Offset })
+	return out
+}
+
+// Describe returns the tokens of one engine in a run, as in
+// keyword "func" ident "main", or "none".
+func Describe(toks []lexer.Token) string {
+	if len(toks) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(toks))
+	for i, t := range toks {
+		parts[i] = fmt.Sprintf("%s %q", t.Kind, t.Text)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,331 @@
+package htmlrender
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/linerange"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// Class returns the CSS class of tokens of kind k, such as "tok-keyword".
+// Class names never change, so that reference output stays comparable.
+func Class(k lexer.Kind) string {
+	return "tok-" + k.String()
+}
+
+// Options control the HTML produced.
+type Options struct {
+	// Theme styles the tokens, in Stylesheet or inline. It defaults to
+	// theme.Light.
+	Theme *theme.Theme
+	// InlineStyles adds each token's style as a style attribute, for HTML
+	// that must look right without a stylesheet.
+	InlineStyles bool
+	// LineNumbers numbers the lines from StartLine, or 1 if it is zero.
+	LineNumbers bool
+	StartLine   int
+	// HighlightLines marks the lines with these numbers, counted from
+	// StartLine, with the class hl.
+	HighlightLines linerange.Set
+	// Lines is the number of lines in the source, which line numbers are
+	// padded to the width of. RenderTokens counts them if it is zero.
+	Lines int
+}
+
+func (o Options) theme() *theme.Theme {
+	if o.Theme != nil {
+		return o.Theme
+	}
+	return theme.Light
+}
+
+// Render tokenizes src and writes it as HTML, as RenderTokens does.
+func Render(w io.Writer, src []byte, opts Options) error {
+	return RenderTokens(w, src, lexer.Tokenize(src), opts)
+}
+
+// RenderReader tokenizes the source read from r and writes it as HTML, as
+// RenderTokens does, but a token at a time as it is read, so that sources
+// too large to hold in memory can be rendered. Line numbers are only as
+// wide as opts.Lines makes them.
+func RenderReader(w io.Writer, r io.Reader, opts Options) error {
+	hw := NewWriter(w, opts)
+	if err := lexer.TokenizeReaderText(r, hw.Text, hw.Token); err != nil {
+		return err
+	}
+	return hw.Close()
+}
+
+// RenderTokens writes src as a <pre> element with a <span> for each of
+// toks, which must be in order and lie within src. Source between tokens,
+// such as whitespace, is written without a span, so the text content of the
+// output is exactly src, and the line numbers if LineNumbers is set.
+//
+// All text is escaped, so the output never contains markup from src: <, >,
+// &, ' and " only appear as entities, and invalid UTF-8 is replaced with
+// U+FFFD.
+func RenderTokens(w io.Writer, src []byte, toks []lexer.Token, opts Options) error {
+	if opts.Lines == 0 {
+		opts.Lines = bytes.Count(src, []byte("\n"))
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			opts.Lines++
+		}
+	}
+	hw := NewWriter(w, opts)
+	off := 0
+	for _, t := range toks {
+		if t.Pos.Offset < off || t.End() > len(src) {
+			return outOfOrder(t)
+		}
+		if err := hw.Text(string(src[off:t.Pos.Offset])); err != nil {
+			return err
+		}
+		if err := hw.Token(t); err != nil {
+			return err
+		}
+		off = t.End()
+	}
+	if err := hw.Text(string(src[off:])); err != nil {
+		return err
+	}
+	return hw.Close()
+}
+
+func outOfOrder(t lexer.Token) error {
+	return fmt.Errorf("htmlrender: token %s at offset %d is out of order or past the source", t.Kind, t.Pos.Offset)
+}
+
+// bufferSize is how much output a Writer holds before writing it on.
+const bufferSize = 32 << 10
+
+// A Writer writes source as HTML, as RenderTokens does, a piece at a time:
+// the source between tokens with Text, and each token with Token. Output is
+// written on to the underlying writer in blocks as it builds up, so that a
+// slow writer holds back the source too.
+type Writer struct {
+	w    *errWriter
+	b    *bufio.Writer
+	opts Options
+	th   *theme.Theme
+	// lines is whether each line is in a <span class="line">, for
+	// LineNumbers or HighlightLines; n is the number of the current line,
+	// width that of line numbers, and open whether the line's span is
+	// open.
+	lines bool
+	n     int
+	width int
+	open  bool
+}
+
+// NewWriter returns a Writer writing to w, which has written the start of
+// the <pre> element. Close writes the end.
+func NewWriter(w io.Writer, opts Options) *Writer {
+	ew := &errWriter{w: w}
+	hw := &Writer{
+		w:     ew,
+		b:     bufio.NewWriterSize(ew, bufferSize),
+		opts:  opts,
+		th:    opts.theme(),
+		lines: opts.LineNumbers || len(opts.HighlightLines) > 0,
+		n:     opts.StartLine,
+	}
+	if hw.n == 0 {
+		hw.n = 1
+	}
+	hw.width = len(strconv.Itoa(hw.n + max(opts.Lines, 1) - 1))
+	hw.b.WriteString(`<pre class="highlight"`)
+	if decl := blockDeclarations(hw.th); decl != "" && opts.InlineStyles {
+		fmt.Fprintf(hw.b, ` style="%s"`, escape(decl))
+	}
+	hw.b.WriteString("><code>")
+	return hw
+}
+
+// Text writes s, source that is not part of any token, and returns the
+// error, if any, from writing on to the underlying writer.
+func (hw *Writer) Text(s string) error {
+	if hw.lines {
+		hw.text(s, "", "")
+	} else {
+		hw.b.WriteString(escape(s))
+	}
+	return hw.w.err
+}
+
+// Token writes the span of t, which must follow the source written so far,
+// and returns the error, if any, from writing on to the underlying writer.
+func (hw *Writer) Token(t lexer.Token) error {
+	class, decl := Class(t.Kind), declarations(hw.th.Style(t.Kind))
+	if hw.lines {
+		hw.text(t.Text, class, decl)
+	} else {
+		hw.span(t.Text, class, decl)
+	}
+	return hw.w.err
+}
+
+// Close writes the end of the <pre> element and flushes the output.
+func (hw *Writer) Close() error {
+	if hw.open {
+		hw.b.WriteString("</span>")
+	}
+	hw.b.WriteString("</code></pre>\n")
+	return hw.b.Flush()
+}
+
+// span writes s in a span of class and style decl.
+func (hw *Writer) span(s, class, decl string) {
+	fmt.Fprintf(hw.b, `<span class="%s"`, class)
+	if decl != "" && hw.opts.InlineStyles {
+		fmt.Fprintf(hw.b, ` style="%s"`, escape(decl))
+	}
+	hw.b.WriteString(">" + escape(s) + "</span>")
+}
+
+// text writes s with each line in a <span class="line">, starting with its
+// number in a <span class="ln"> for LineNumbers, and in a span of class and
+// style decl unless class is "". Tokens spanning lines are split into a
+// span on each.
+func (hw *Writer) text(s, class, decl string) {
+	for i, part := range strings.Split(s, "\n") {
+		if i > 0 {
+			if !hw.open {
+				hw.openLine()
+			}
+			hw.b.WriteString("\n</span>")
+			hw.open = false
+			hw.n++
+		}
+		if part == "" {
+			continue
+		}
+		if !hw.open {
+			hw.openLine()
+		}
+		if class == "" {
+			hw.b.WriteString(escape(part))
+			continue
+		}
+		hw.span(part, class, decl)
+	}
+}
+
+func (hw *Writer) openLine() {
+	hw.b.WriteString(`<span class="line`)
+	if hw.opts.HighlightLines.Contains(hw.n) {
+		hw.b.WriteString(` hl"`)
+		if decl := highlightDeclarations(hw.th); decl != "" && hw.opts.InlineStyles {
+			fmt.Fprintf(hw.b, ` style="%s"`, escape(decl))
+		}
+	} else {
+		hw.b.WriteByte('"')
+	}
+	hw.b.WriteByte('>')
+	if hw.opts.LineNumbers {
+		hw.b.WriteString(`<span class="ln"`)
+		if decl := lineNumberDeclarations(hw.th); decl != "" && hw.opts.InlineStyles {
+			fmt.Fprintf(hw.b, ` style="%s"`, escape(decl))
+		}
+		fmt.Fprintf(hw.b, ">%*d </span>", hw.width, hw.n)
+	}
+	hw.open = true
+}
+
+// errWriter keeps the first error from writing to w, which a bufio.Writer
+// would otherwise only return from a later write.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	ew.err = err
+	return n, err
+}
+
+// Stylesheet returns CSS giving the highlight block and each token class
+// their style from the theme of opts.
+func Stylesheet(opts Options) string {
+	th := opts.theme()
+	var b strings.Builder
+	if decl := blockDeclarations(th); decl != "" {
+		fmt.Fprintf(&b, ".highlight { %s }\n", decl)
+	}
+	kinds := make([]lexer.Kind, 0, len(th.Styles))
+	for k := range th.Styles {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	for _, k := range kinds {
+		if decl := declarations(th.Style(k)); decl != "" {
+			fmt.Fprintf(&b, ".highlight .%s { %s }\n", Class(k), decl)
+		}
+	}
+	// Line numbers are left out when code is selected and copied.
+	fmt.Fprintf(&b, ".highlight .ln { %s }\n", strings.Join(append([]string{"user-select: none"}, lineNumberDeclarations(th)), "; "))
+	if decl := highlightDeclarations(th); decl != "" {
+		fmt.Fprintf(&b, ".highlight .hl { %s }\n", decl)
+	}
+	return b.String()
+}
+
+func lineNumberDeclarations(th *theme.Theme) string {
+	return declarations(th.LineNumbers)
+}
+
+// highlightDeclarations returns the declarations of highlighted lines,
+// which are blocks so that their background spans the whole width.
+func highlightDeclarations(th *theme.Theme) string {
+	if th.Highlight == "" {
+		return ""
+	}
+	return "display: block; background-color: " + th.Highlight
+}
+
+func blockDeclarations(th *theme.Theme) string {
+	var decls []string
+	if th.Background != "" {
+		decls = append(decls, "background-color: "+th.Background)
+	}
+	if th.Foreground != "" {
+		decls = append(decls, "color: "+th.Foreground)
+	}
+	return strings.Join(decls, "; ")
+}
+
+// declarations returns the CSS declarations for st.
+func declarations(st theme.Style) string {
+	var decls []string
+	if st.Color != "" {
+		decls = append(decls, "color: "+st.Color)
+	}
+	if st.Background != "" {
+		decls = append(decls, "background-color: "+st.Background)
+	}
+	if st.Bold {
+		decls = append(decls, "font-weight: bold")
+	}
+	if st.Italic {
+		decls = append(decls, "font-style: italic")
+	}
+	if st.Underline {
+		decls = append(decls, "text-decoration: underline")
+	}
+	return strings.Join(decls, "; ")
+}
+
+func escape(s string) string {
+	return html.EscapeString(strings.ToValidUTF8(s, "\uFFFD"))
+}
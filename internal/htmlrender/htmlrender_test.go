@@ -0,0 +1,130 @@
+package htmlrender
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/linerange"
+)
+
+var (
+	lineNumber = regexp.MustCompile(`<span class="ln"[^>]*>[^<]*</span>`)
+	tag        = regexp.MustCompile(`<[^>]*>`)
+)
+
+// textContent returns the text a browser shows for the <pre> element out,
+// less line numbers.
+func textContent(out string) string {
+	out = strings.TrimSuffix(out, "\n")
+	return html.UnescapeString(tag.ReplaceAllString(lineNumber.ReplaceAllString(out, ""), ""))
+}
+
+func TestRenderEscapes(t *testing.T) {
+	sources := []struct {
+		name, src string
+	}{
+		{"markup in a string", `s := "<script>alert('x')</script>"`},
+		{"markup in a comment", "// </code></pre><img src=x onerror=alert(1)>\nx := 1"},
+		{"entities", `a && b || c < d > e // &amp; &#39;`},
+		{"quotes", "r := '\"'\nq := `'`"},
+		{"illegal characters", "x := 1 @ <b> # &"},
+		{"unterminated", "s := \"<i>\n"},
+	}
+	options := []struct {
+		name string
+		opts Options
+	}{
+		{"plain", Options{}},
+		{"inline styles", Options{InlineStyles: true}},
+		{"lines", Options{LineNumbers: true, HighlightLines: linerange.Set{{First: 1, Last: 1}}, InlineStyles: true}},
+	}
+	for _, s := range sources {
+		for _, o := range options {
+			t.Run(s.name+"/"+o.name, func(t *testing.T) {
+				var b bytes.Buffer
+				if err := Render(&b, []byte(s.src), o.opts); err != nil {
+					t.Fatal(err)
+				}
+				out := b.String()
+				// Every < and > is of a tag this package writes, and no
+				// tag has a quote the source could have closed.
+				for _, m := range tag.FindAllString(out, -1) {
+					if !strings.HasPrefix(m, "<span") && !strings.HasPrefix(m, "</span") &&
+						m != "<pre class=\"highlight\">" && !strings.HasPrefix(m, "<pre class=\"highlight\" style=") &&
+						m != "<code>" && m != "</code>" && m != "</pre>" {
+						t.Errorf("output has the tag %s", m)
+					}
+				}
+				if rest := tag.ReplaceAllString(out, ""); strings.ContainsAny(rest, `<>"'`) {
+					t.Errorf("output has unescaped text: %s", rest)
+				}
+				if got := textContent(out); got != s.src {
+					t.Errorf("text content %q, want the source %q", got, s.src)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderInvalidUTF8(t *testing.T) {
+	var b bytes.Buffer
+	if err := Render(&b, []byte("s := \"a\xffb\" // \xc0<"), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := textContent(b.String()), "s := \"a\uFFFDb\" // \uFFFD<"; got != want {
+		t.Errorf("text content %q, want %q", got, want)
+	}
+}
+
+func TestClass(t *testing.T) {
+	// Class names are part of the output that reference HTML and
+	// stylesheets depend on, so they must not change.
+	tests := []struct {
+		kind lexer.Kind
+		want string
+	}{
+		{lexer.EOF, "tok-eof"},
+		{lexer.Illegal, "tok-illegal"},
+		{lexer.Keyword, "tok-keyword"},
+		{lexer.Ident, "tok-ident"},
+		{lexer.Number, "tok-number"},
+		{lexer.String, "tok-string"},
+		{lexer.Char, "tok-char"},
+		{lexer.Comment, "tok-comment"},
+		{lexer.Operator, "tok-operator"},
+	}
+	for _, tt := range tests {
+		if got := Class(tt.kind); got != tt.want {
+			t.Errorf("Class(%v) = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTokensOutOfOrder(t *testing.T) {
+	src := []byte("a b")
+	toks := lexer.Tokenize(src)
+	toks[0], toks[1] = toks[1], toks[0]
+	if err := RenderTokens(new(bytes.Buffer), src, toks, Options{}); err == nil {
+		t.Error("RenderTokens of out-of-order tokens succeeded")
+	}
+}
+
+func TestRenderReader(t *testing.T) {
+	src := "package main\n\n// <hi>\nfunc main() { println(\"&\") }\n"
+	for _, opts := range []Options{{}, {LineNumbers: true, Lines: 4}} {
+		var want, got bytes.Buffer
+		if err := Render(&want, []byte(src), opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := RenderReader(&got, strings.NewReader(src), opts); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("RenderReader wrote\n%s\nRender\n%s", &got, &want)
+		}
+	}
+}
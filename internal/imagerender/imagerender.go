@@ -0,0 +1,138 @@
+// Package imagerender renders highlighted source as SVG and PNG images, for
+// documentation and for comparing highlighter output pixel by pixel.
+package imagerender
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// Options control the image produced. Zero values take the defaults.
+type Options struct {
+	// Theme colors the image. It defaults to theme.Light.
+	Theme *theme.Theme
+	// FontFamily and FontSize, in pixels, set the SVG's font, which should
+	// be monospace as text is laid out on a grid. PNG images always use a
+	// built-in bitmap font, scaled to about FontSize.
+	FontFamily string
+	FontSize   int
+	// Padding is the space around the code, in pixels. A negative padding
+	// means none.
+	Padding int
+	// TabWidth is the number of columns a tab advances to the next
+	// multiple of.
+	TabWidth int
+}
+
+const (
+	DefaultFontFamily = "ui-monospace, SFMono-Regular, Menlo, Consolas, monospace"
+	DefaultFontSize   = 14
+	DefaultPadding    = 16
+	DefaultTabWidth   = 4
+)
+
+func (o Options) withDefaults() Options {
+	if o.Theme == nil {
+		o.Theme = theme.Light
+	}
+	if o.FontFamily == "" {
+		o.FontFamily = DefaultFontFamily
+	}
+	if o.FontSize <= 0 {
+		o.FontSize = DefaultFontSize
+	}
+	if o.Padding < 0 {
+		o.Padding = 0
+	} else if o.Padding == 0 {
+		o.Padding = DefaultPadding
+	}
+	if o.TabWidth <= 0 {
+		o.TabWidth = DefaultTabWidth
+	}
+	return o
+}
+
+// colors returns the background and foreground of th, white and black
+// where it leaves them to the renderer.
+func colors(th *theme.Theme) (bg, fg string) {
+	bg, fg = th.Background, th.Foreground
+	if bg == "" {
+		bg = "#ffffff"
+	}
+	if fg == "" {
+		fg = "#000000"
+	}
+	return bg, fg
+}
+
+// run is text in one style, on one line, with tabs expanded.
+type run struct {
+	text  string
+	style theme.Style
+	// col is the column the run starts in, from 0.
+	col int
+}
+
+// layout splits src into lines of runs styled by toks, which must be in
+// order and lie within src, and returns them with the width of the widest
+// line in columns. Text between tokens has the zero style. Each rune takes
+// one column, and invalid UTF-8 and control characters other than tabs
+// are shown as U+FFFD.
+func layout(src []byte, toks []lexer.Token, th *theme.Theme, tabWidth int) (lines [][]run, width int, err error) {
+	lines = [][]run{nil}
+	col := 0
+	add := func(text string, st theme.Style) {
+		var b strings.Builder
+		start := col
+		flush := func() {
+			if b.Len() > 0 {
+				last := &lines[len(lines)-1]
+				*last = append(*last, run{text: b.String(), style: st, col: start})
+				b.Reset()
+			}
+		}
+		for len(text) > 0 {
+			r, size := utf8.DecodeRuneInString(text)
+			text = text[size:]
+			switch {
+			case r == '\n':
+				flush()
+				width = max(width, col)
+				lines = append(lines, nil)
+				col, start = 0, 0
+			case r == '\r' && strings.HasPrefix(text, "\n"):
+			case r == '\t':
+				n := tabWidth - col%tabWidth
+				b.WriteString(strings.Repeat(" ", n))
+				col += n
+			case r < 0x20 || r == 0x7f || r == utf8.RuneError && size == 1:
+				b.WriteRune(utf8.RuneError)
+				col++
+			default:
+				b.WriteRune(r)
+				col++
+			}
+		}
+		flush()
+		width = max(width, col)
+	}
+	off := 0
+	for _, t := range toks {
+		if t.Pos.Offset < off || t.End() > len(src) {
+			return nil, 0, fmt.Errorf("imagerender: token %s at offset %d is out of order or past the source", t.Kind, t.Pos.Offset)
+		}
+		add(string(src[off:t.Pos.Offset]), theme.Style{})
+		add(t.Text, th.Style(t.Kind))
+		off = t.End()
+	}
+	add(string(src[off:]), theme.Style{})
+	// A final newline ends the last line rather than starting another.
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, width, nil
+}
@@ -0,0 +1,90 @@
+package imagerender
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// RenderPNG tokenizes src and writes it as a PNG image, as RenderPNGTokens
+// does.
+func RenderPNG(w io.Writer, src []byte, opts Options) error {
+	return RenderPNGTokens(w, src, lexer.Tokenize(src), opts)
+}
+
+// RenderPNGTokens writes src, styled by toks, as a PNG image drawn with a
+// built-in 5x8 bitmap font, scaled by a whole number so that lines are
+// about 1.5 times FontSize apart. The same input and options always give
+// the same pixels, so images can be compared exactly. Bold text is drawn
+// twice, a pixel apart; italic text is drawn upright.
+func RenderPNGTokens(w io.Writer, src []byte, toks []lexer.Token, opts Options) error {
+	img, err := Rasterize(src, toks, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Rasterize draws src, styled by toks, as RenderPNGTokens does, and returns
+// the image.
+func Rasterize(src []byte, toks []lexer.Token, opts Options) (*image.RGBA, error) {
+	opts = opts.withDefaults()
+	lines, cols, err := layout(src, toks, opts.Theme, opts.TabWidth)
+	if err != nil {
+		return nil, err
+	}
+	scale := max(1, (3*opts.FontSize+cellHeight)/(2*cellHeight))
+	cw, ch := cellWidth*scale, cellHeight*scale
+	pad := opts.Padding
+	img := image.NewRGBA(image.Rect(0, 0, 2*pad+cols*cw, 2*pad+len(lines)*ch))
+	bg, fg := colors(opts.Theme)
+	draw.Draw(img, img.Bounds(), image.NewUniform(rgba(bg)), image.Point{}, draw.Src)
+	fill := func(x, y, w, h int, c color.RGBA) {
+		draw.Draw(img, image.Rect(x, y, x+w, y+h), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	for i, line := range lines {
+		top := pad + i*ch
+		for _, r := range line {
+			ink := rgba(fg)
+			if r.style.Color != "" {
+				ink = rgba(r.style.Color)
+			}
+			x := pad + r.col*cw
+			for _, c := range r.text {
+				if r.style.Background != "" {
+					fill(x, top, cw, ch, rgba(r.style.Background))
+				}
+				g := glyph(c)
+				for gx, bits := range g {
+					for gy := 0; gy < 8; gy++ {
+						if bits&(1<<gy) == 0 {
+							continue
+						}
+						px, py := x+gx*scale, top+(glyphTop+gy)*scale
+						fill(px, py, scale, scale, ink)
+						if r.style.Bold {
+							fill(px+1, py, scale, scale, ink)
+						}
+					}
+				}
+				if r.style.Underline {
+					fill(x, top+(cellHeight-1)*scale, cw, scale, ink)
+				}
+				x += cw
+			}
+		}
+	}
+	return img, nil
+}
+
+// rgba returns a #rrggbb color, which the theme has validated, as an
+// opaque color.
+func rgba(c string) color.RGBA {
+	r, g, b, _ := theme.RGB(c)
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
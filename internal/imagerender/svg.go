@@ -0,0 +1,84 @@
+package imagerender
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// RenderSVG tokenizes src and writes it as an SVG image, as
+// RenderSVGTokens does.
+func RenderSVG(w io.Writer, src []byte, opts Options) error {
+	return RenderSVGTokens(w, src, lexer.Tokenize(src), opts)
+}
+
+// RenderSVGTokens writes src, styled by toks, as an SVG image. Every run of
+// text is placed at its column, taking the advance of a monospace font as
+// 0.6 of the font size, so the layout does not depend on the font the
+// viewer has.
+func RenderSVGTokens(w io.Writer, src []byte, toks []lexer.Token, opts Options) error {
+	opts = opts.withDefaults()
+	lines, cols, err := layout(src, toks, opts.Theme, opts.TabWidth)
+	if err != nil {
+		return err
+	}
+	size := float64(opts.FontSize)
+	advance, lineHeight := 0.6*size, 1.5*size
+	pad := float64(opts.Padding)
+	bg, fg := colors(opts.Theme)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s">`+"\n",
+		num(2*pad+float64(cols)*advance), num(2*pad+float64(len(lines))*lineHeight))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", bg)
+	for i, line := range lines {
+		top := pad + float64(i)*lineHeight
+		for _, r := range line {
+			if r.style.Background != "" {
+				fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+					num(pad+float64(r.col)*advance), num(top), num(float64(len([]rune(r.text)))*advance), num(lineHeight), r.style.Background)
+			}
+		}
+	}
+	fmt.Fprintf(&b, `<g font-family="%s" font-size="%d" fill="%s" xml:space="preserve">`+"\n", escape(opts.FontFamily), opts.FontSize, fg)
+	for i, line := range lines {
+		// Center the text in its line, taking the baseline as 0.8 of the
+		// font size down from the top of the text.
+		baseline := pad + float64(i)*lineHeight + (lineHeight-size)/2 + 0.8*size
+		for _, r := range line {
+			if strings.TrimSpace(r.text) == "" && r.style.Background == "" && !r.style.Underline {
+				continue
+			}
+			fmt.Fprintf(&b, `<text x="%s" y="%s"`, num(pad+float64(r.col)*advance), num(baseline))
+			if r.style.Color != "" {
+				fmt.Fprintf(&b, ` fill="%s"`, r.style.Color)
+			}
+			if r.style.Bold {
+				b.WriteString(` font-weight="bold"`)
+			}
+			if r.style.Italic {
+				b.WriteString(` font-style="italic"`)
+			}
+			if r.style.Underline {
+				b.WriteString(` text-decoration="underline"`)
+			}
+			b.WriteString(">" + escape(r.text) + "</text>\n")
+		}
+	}
+	b.WriteString("</g>\n</svg>\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// num formats v with at most two decimals, as SVG coordinates.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func escape(s string) string {
+	return html.EscapeString(s)
+}
@@ -0,0 +1,160 @@
+// Package latexrender writes highlighted code as LaTeX, in the Verbatim
+// environment of fancyvrb that minted also typesets with.
+package latexrender
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// Options control the LaTeX produced.
+type Options struct {
+	// Theme colors the tokens, in Preamble. It defaults to theme.Light.
+	Theme *theme.Theme
+	// LineNumbers numbers the lines from StartLine, or 1 if it is zero.
+	LineNumbers bool
+	StartLine   int
+}
+
+func (o Options) theme() *theme.Theme {
+	if o.Theme != nil {
+		return o.Theme
+	}
+	return theme.Light
+}
+
+// Macro returns the name of the macro typesetting tokens of kind k, such
+// as "HL@keyword", defined by Preamble. Tokens are written \HL{keyword}{...},
+// which uses it.
+func Macro(k lexer.Kind) string {
+	return "HL@" + k.String()
+}
+
+// Render tokenizes src and writes it as LaTeX, as RenderTokens does.
+func Render(w io.Writer, src []byte, opts Options) error {
+	return RenderTokens(w, src, lexer.Tokenize(src), opts)
+}
+
+// RenderTokens writes src as a Verbatim environment with each of toks, which
+// must be in order and lie within src, in a \HL command. Tokens spanning
+// lines are split into a command on each, as fancyvrb reads a line at a
+// time. The document needs the definitions of Preamble.
+//
+// Backslashes and braces, the only characters special in the environment,
+// are written as the commands \HLZbs, \HLZob and \HLZcb, CRLF line endings
+// as LF, and invalid UTF-8 and control characters other than tabs as
+// U+FFFD.
+func RenderTokens(w io.Writer, src []byte, toks []lexer.Token, opts Options) error {
+	var b strings.Builder
+	b.WriteString(`\begin{Verbatim}[commandchars=\\\{\}`)
+	if opts.LineNumbers {
+		fmt.Fprintf(&b, ",numbers=left,firstnumber=%d", max(opts.StartLine, 1))
+	}
+	b.WriteString("]\n")
+	off := 0
+	for _, t := range toks {
+		if t.Pos.Offset < off || t.End() > len(src) {
+			return fmt.Errorf("latexrender: token %s at offset %d is out of order or past the source", t.Kind, t.Pos.Offset)
+		}
+		b.WriteString(escape(string(src[off:t.Pos.Offset])))
+		for i, line := range strings.Split(t.Text, "\n") {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			if line = escape(strings.TrimSuffix(line, "\r")); line != "" {
+				fmt.Fprintf(&b, `\HL{%s}{%s}`, t.Kind, line)
+			}
+		}
+		off = t.End()
+	}
+	b.WriteString(escape(string(src[off:])))
+	// \end{Verbatim} must start a line.
+	if body := b.String(); !strings.HasSuffix(body, "\n") {
+		b.WriteByte('\n')
+	}
+	b.WriteString("\\end{Verbatim}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escape returns s as Verbatim text with commandchars \, { and }.
+func escape(s string) string {
+	s = strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\r\n", "\n")
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\HLZbs{}`)
+		case r == '{':
+			b.WriteString(`\HLZob{}`)
+		case r == '}':
+			b.WriteString(`\HLZcb{}`)
+		case r != '\n' && r != '\t' && unicode.IsControl(r):
+			b.WriteRune('\uFFFD')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Preamble returns the LaTeX that documents using RenderTokens need before
+// \begin{document}: the fancyvrb and xcolor packages, and a macro giving
+// each token kind its style from the theme of opts. Token backgrounds are
+// set with \colorbox; the theme's background is left to the document,
+// which Verbatim has no option for.
+func Preamble(opts Options) string {
+	th := opts.theme()
+	var b strings.Builder
+	b.WriteString("\\usepackage{fancyvrb}\n\\usepackage{xcolor}\n\\makeatletter\n")
+	b.WriteString("\\def\\HLZbs{\\char`\\\\}\n\\def\\HLZob{\\char`\\{}\n\\def\\HLZcb{\\char`\\}}\n")
+	// The text of kinds without a macro is set as it is.
+	b.WriteString("\\def\\HL#1#2{\\ifcsname HL@#1\\endcsname\\csname HL@#1\\endcsname{#2}\\else#2\\fi}\n")
+	kinds := make([]lexer.Kind, 0, len(th.Styles))
+	for k := range th.Styles {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	for _, k := range kinds {
+		if def := definition(th.Style(k)); def != "#1" {
+			fmt.Fprintf(&b, "\\@namedef{%s}#1{%s}\n", Macro(k), def)
+		}
+	}
+	if th.Foreground != "" {
+		fmt.Fprintf(&b, "\\fvset{formatcom=\\color[HTML]{%s}}\n", hex(th.Foreground))
+	}
+	b.WriteString("\\makeatother\n")
+	return b.String()
+}
+
+// definition returns the body of a macro setting its argument, #1, in st.
+func definition(st theme.Style) string {
+	def := "#1"
+	if st.Bold {
+		def = `\textbf{` + def + "}"
+	}
+	if st.Italic {
+		def = `\textit{` + def + "}"
+	}
+	if st.Underline {
+		def = `\underline{` + def + "}"
+	}
+	if st.Color != "" {
+		def = `\textcolor[HTML]{` + hex(st.Color) + "}{" + def + "}"
+	}
+	if st.Background != "" {
+		def = `\colorbox[HTML]{` + hex(st.Background) + "}{" + def + "}"
+	}
+	return def
+}
+
+// hex returns a #rrggbb color as the xcolor HTML model writes it.
+func hex(color string) string {
+	return strings.ToUpper(strings.TrimPrefix(color, "#"))
+}
@@ -0,0 +1,132 @@
+package lexer
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// lookahead bounds how far past its end the scan of a token reads: a whole
+// rune after an identifier, or two bytes after an operator or number.
+const lookahead = 4
+
+// Highlighter keeps the tokens of a source that is edited, as in an editor,
+// and re-tokenizes only what each edit affects.
+type Highlighter struct {
+	src  string
+	toks []Token
+}
+
+// NewHighlighter returns a Highlighter for src.
+func NewHighlighter(src []byte) *Highlighter {
+	s := string(src)
+	l := newLexer(s)
+	var toks []Token
+	for t := l.Next(); t.Kind != EOF; t = l.Next() {
+		toks = append(toks, t)
+	}
+	return &Highlighter{src: s, toks: toks}
+}
+
+// Source returns the source as edited so far.
+func (h *Highlighter) Source() string { return h.src }
+
+// Tokens returns the tokens of the source, as Tokenize would. The slice is
+// only valid until the next Edit.
+func (h *Highlighter) Tokens() []Token { return h.toks }
+
+// Change is how an Edit changed the tokens: Removed tokens from index First
+// were replaced by Inserted tokens from the same index. The tokens after
+// them keep their kind and text, moved by the edit.
+type Change struct {
+	First, Removed, Inserted int
+	// Start and End are the offsets, in the edited source, of the text
+	// whose tokens changed, for an editor to repaint.
+	Start, End int
+}
+
+// Edit replaces the deletedLen bytes at offset with inserted and updates
+// the tokens. Scanning a token depends only on the source from its start,
+// so re-tokenizing starts at the last token the edit cannot reach, or at a
+// raw string or comment left open that the edit may close, and stops at
+// the first new token that starts where an old one did after the edit.
+func (h *Highlighter) Edit(offset, deletedLen int, inserted []byte) (Change, error) {
+	if offset < 0 || deletedLen < 0 || offset+deletedLen > len(h.src) {
+		return Change{}, fmt.Errorf("edit of %d bytes at offset %d is outside the source, of %d bytes", deletedLen, offset, len(h.src))
+	}
+	src := h.src[:offset] + string(inserted) + h.src[offset+deletedLen:]
+	newEnd := offset + len(inserted)
+	delta := len(inserted) - deletedLen
+
+	first := sort.Search(len(h.toks), func(i int) bool { return h.toks[i].End()+lookahead > offset })
+	// A quote or */ made by the edit may close a raw string or comment
+	// left open anywhere before it.
+	around := src[max(offset-1, 0):min(newEnd+1, len(src))]
+	raw, comment := strings.Contains(around, "`"), strings.Contains(around, "*/")
+	if raw || comment {
+		for i, t := range h.toks[:first] {
+			if unclosed(t) && (raw && t.Text[0] == '`' || comment && t.Text[0] == '/') {
+				first = i
+				break
+			}
+		}
+	}
+	var l *Lexer
+	if first == 0 {
+		// Start over, so that a byte order mark is handled as by New.
+		l = newLexer(src)
+	} else {
+		prev := h.toks[first-1]
+		l = &Lexer{src: src, off: prev.End(), line: prev.Pos.Line, lineStart: prev.Pos.Offset - prev.Pos.Column + 1}
+		if n := strings.Count(prev.Text, "\n"); n > 0 {
+			l.line += n
+			l.lineStart = prev.Pos.Offset + strings.LastIndexByte(prev.Text, '\n') + 1
+		}
+	}
+
+	var fresh []Token
+	next := first
+	for {
+		t := l.Next()
+		if t.Kind == EOF {
+			next = len(h.toks)
+			break
+		}
+		if t.Pos.Offset >= newEnd {
+			old := t.Pos.Offset - delta
+			for next < len(h.toks) && h.toks[next].Pos.Offset < old {
+				next++
+			}
+			if next < len(h.toks) && h.toks[next].Pos.Offset == old {
+				h.shift(next, delta, t.Pos)
+				break
+			}
+		}
+		fresh = append(fresh, t)
+	}
+
+	c := Change{First: first, Removed: next - first, Inserted: len(fresh), Start: offset, End: newEnd}
+	if len(fresh) > 0 {
+		c.Start = min(c.Start, fresh[0].Pos.Offset)
+		c.End = max(c.End, fresh[len(fresh)-1].End())
+	}
+	h.src = src
+	h.toks = slices.Replace(h.toks, first, next, fresh...)
+	return c, nil
+}
+
+// shift moves the tokens from index i by delta bytes, token i to pos. Those
+// on its line move by as many columns as it does, and the rest keep theirs.
+func (h *Highlighter) shift(i, delta int, pos Pos) {
+	ref := h.toks[i].Pos
+	lines, columns := pos.Line-ref.Line, pos.Column-ref.Column
+	for j := i; j < len(h.toks); j++ {
+		p := &h.toks[j].Pos
+		if p.Line == ref.Line {
+			p.Column += columns
+		}
+		p.Offset += delta
+		p.Line += lines
+	}
+}
@@ -0,0 +1,316 @@
+package lexer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind is the kind of a Token.
+type Kind int
+
+const (
+	EOF Kind = iota
+	// Illegal is the error token: an unexpected character or an
+	// unterminated literal or comment. Lexing recovers after it, at the
+	// next character or the end of the line, so that source mid-edit
+	// still tokenizes as it will once the error is fixed.
+	Illegal
+	Keyword
+	Ident
+	Number
+	// String is an interpreted or raw string literal, and Char a rune
+	// literal.
+	String
+	Char
+	Comment
+	// Operator covers operators and punctuation, from + to { and ....
+	Operator
+)
+
+var kindNames = [...]string{"eof", "illegal", "keyword", "ident", "number", "string", "char", "comment", "operator"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// ParseKind returns the Kind named s, as Kind.String writes it.
+func ParseKind(s string) (Kind, bool) {
+	for k, name := range kindNames {
+		if name == s {
+			return Kind(k), true
+		}
+	}
+	return 0, false
+}
+
+// Pos is a position in the source. Line and Column start at 1, and Column
+// counts bytes, as go/token does.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Token is a token of Go source. Text is exactly the source it spans, so
+// the whitespace between tokens is whatever lies between their offsets.
+type Token struct {
+	Kind Kind
+	Text string
+	Pos  Pos
+}
+
+// End is the offset just past the token.
+func (t Token) End() int { return t.Pos.Offset + len(t.Text) }
+
+var keywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// operators holds every operator and punctuation token, longest first so
+// that the first prefix found is the longest match.
+var operators = []string{
+	"<<=", ">>=", "&^=", "...",
+	"&&", "||", "<-", "++", "--", "==", "!=", "<=", ">=", ":=",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", ">>", "&^",
+	"+", "-", "*", "/", "%", "&", "|", "^", "<", ">", "=", "!", "~",
+	"(", ")", "[", "]", "{", "}", ",", ";", ".", ":",
+}
+
+// Lexer splits Go source into tokens, skipping whitespace.
+type Lexer struct {
+	src       string
+	off       int
+	line      int
+	lineStart int
+	// noCommentEnd and noRawEnd are offsets from which src holds no */ or
+	// no backquote, once a search for one has failed, or zero.
+	noCommentEnd int
+	noRawEnd     int
+}
+
+// New returns a Lexer for src. A leading byte order mark is skipped.
+func New(src []byte) *Lexer {
+	return newLexer(string(src))
+}
+
+func newLexer(src string) *Lexer {
+	l := &Lexer{src: src, line: 1}
+	if strings.HasPrefix(l.src, "\uFEFF") {
+		l.off = len("\uFEFF")
+		l.lineStart = l.off
+	}
+	return l
+}
+
+// Tokenize returns every token of src, not including the final EOF.
+func Tokenize(src []byte) []Token {
+	l := New(src)
+	var toks []Token
+	for {
+		t := l.Next()
+		if t.Kind == EOF {
+			return toks
+		}
+		toks = append(toks, t)
+	}
+}
+
+// Next returns the next token, or an EOF token at the end of the source,
+// and keeps returning EOF after that.
+func (l *Lexer) Next() Token {
+	l.skipSpace()
+	start := l.off
+	pos := Pos{Offset: start, Line: l.line, Column: start - l.lineStart + 1}
+	if start >= len(l.src) {
+		return Token{Kind: EOF, Pos: pos}
+	}
+	kind := l.scan()
+	text := l.src[start:l.off]
+	// Raw strings and general comments may span lines.
+	if n := strings.Count(text, "\n"); n > 0 {
+		l.line += n
+		l.lineStart = start + strings.LastIndexByte(text, '\n') + 1
+	}
+	return Token{Kind: kind, Text: text, Pos: pos}
+}
+
+func (l *Lexer) skipSpace() {
+	for l.off < len(l.src) {
+		switch l.src[l.off] {
+		case '\n':
+			l.line++
+			l.lineStart = l.off + 1
+		case ' ', '\t', '\r':
+		default:
+			return
+		}
+		l.off++
+	}
+}
+
+// scan consumes one token starting at l.off and returns its kind.
+func (l *Lexer) scan() Kind {
+	rest := l.src[l.off:]
+	r, size := utf8.DecodeRuneInString(rest)
+	switch {
+	case isLetter(r):
+		n := strings.IndexFunc(rest, func(r rune) bool { return !isLetter(r) && !isDigit(r) })
+		if n < 0 {
+			n = len(rest)
+		}
+		l.off += n
+		if keywords[rest[:n]] {
+			return Keyword
+		}
+		return Ident
+	case isDecimal(rest[0]) || r == '.' && len(rest) > 1 && isDecimal(rest[1]):
+		l.scanNumber()
+		return Number
+	case r == '"':
+		return l.scanQuoted('"', String)
+	case r == '\'':
+		return l.scanQuoted('\'', Char)
+	case r == '`':
+		if l.noRawEnd == 0 || l.off+1 < l.noRawEnd {
+			if n := strings.IndexByte(rest[1:], '`'); n >= 0 {
+				l.off += n + 2
+				return String
+			}
+			l.noRawEnd = l.off + 1
+		}
+		return l.recoverLine()
+	case strings.HasPrefix(rest, "//"):
+		if n := strings.IndexByte(rest, '\n'); n >= 0 {
+			l.off += n
+		} else {
+			l.off = len(l.src)
+		}
+		// A CR before the newline belongs to the line ending, not the
+		// comment.
+		if l.src[l.off-1] == '\r' {
+			l.off--
+		}
+		return Comment
+	case strings.HasPrefix(rest, "/*"):
+		if l.noCommentEnd == 0 || l.off+2 < l.noCommentEnd {
+			if n := strings.Index(rest[2:], "*/"); n >= 0 {
+				l.off += n + 4
+				return Comment
+			}
+			l.noCommentEnd = l.off + 2
+		}
+		return l.recoverLine()
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			l.off += len(op)
+			return Operator
+		}
+	}
+	l.off += size
+	return Illegal
+}
+
+// recoverLine consumes the rest of the line of a raw string or general
+// comment that is never closed, as an Illegal token. Lexing resumes on the
+// next line rather than taking the rest of the source for the literal, as
+// the source is most likely mid-edit, its closing quote not yet typed.
+func (l *Lexer) recoverLine() Kind {
+	n := strings.IndexByte(l.src[l.off:], '\n')
+	if n < 0 {
+		l.off = len(l.src)
+		return Illegal
+	}
+	l.off += n
+	if l.src[l.off-1] == '\r' {
+		l.off--
+	}
+	return Illegal
+}
+
+// unclosed reports whether t is a raw string or general comment that was
+// never closed, and so would change if a closing quote or */ were added
+// anywhere after it.
+func unclosed(t Token) bool {
+	return t.Kind == Illegal && (strings.HasPrefix(t.Text, "`") || strings.HasPrefix(t.Text, "/*"))
+}
+
+// scanQuoted consumes an interpreted string or rune literal, which may not
+// span lines.
+func (l *Lexer) scanQuoted(quote byte, kind Kind) Kind {
+	for i := l.off + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			if i+1 < len(l.src) && l.src[i+1] != '\n' {
+				i++
+			}
+		case quote:
+			l.off = i + 1
+			return kind
+		case '\n':
+			l.off = i
+			return Illegal
+		}
+	}
+	l.off = len(l.src)
+	return Illegal
+}
+
+// scanNumber consumes an integer, floating-point or imaginary literal in
+// any base. It accepts some malformed numbers, such as 0b2, as a single
+// token rather than splitting them.
+func (l *Lexer) scanNumber() {
+	exponent := "eE"
+	if len(l.src) > l.off+1 && l.src[l.off] == '0' {
+		switch l.src[l.off+1] {
+		case 'x', 'X':
+			exponent = "pP"
+			l.off += 2
+		case 'b', 'B', 'o', 'O':
+			l.off += 2
+		}
+	}
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case strings.IndexByte(exponent, c) >= 0:
+			l.off++
+			if l.off < len(l.src) && (l.src[l.off] == '+' || l.src[l.off] == '-') {
+				l.off++
+			}
+		case isHex(c) || c == '_' || c == '.':
+			// A second dot starts an operator, as in 1..
+			if c == '.' && strings.HasPrefix(l.src[l.off:], "..") {
+				return
+			}
+			l.off++
+		default:
+			if c == 'i' {
+				l.off++
+			}
+			return
+		}
+	}
+}
+
+func isLetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_' || r >= utf8.RuneSelf && unicode.IsLetter(r)
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9' || r >= utf8.RuneSelf && unicode.IsDigit(r)
+}
+
+func isDecimal(c byte) bool { return '0' <= c && c <= '9' }
+
+func isHex(c byte) bool {
+	return isDecimal(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,115 @@
+package lexer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// chunkSize is how much TokenizeReader reads at a time.
+const chunkSize = 64 << 10
+
+// MaxTokenSize bounds the tokens TokenizeReader reads, and so the memory it
+// uses: a comment or literal longer than this, or one left unterminated
+// further than this from the end, is an error.
+const MaxTokenSize = 16 << 20
+
+// ErrTokenTooLong is returned by TokenizeReader for a token longer than
+// MaxTokenSize.
+var ErrTokenTooLong = errors.New("lexer: token too long")
+
+// TokenizeReader tokenizes the source read from r as Tokenize does, calling
+// emit with each token in turn, but reads it in chunks, so that files too
+// large to hold in memory can be tokenized. A token is only emitted once
+// enough source follows it to be sure where it ends. The Text of each token
+// shares memory with the chunk it was read in, so that keeping a token
+// keeps the chunk. An error from r or emit stops the tokenizing and is
+// returned.
+func TokenizeReader(r io.Reader, emit func(Token) error) error {
+	var buf []byte
+	// base is the offset in the source of buf[0]; line and lineStart are
+	// where the lexer is, as offsets in the source too.
+	base, line, lineStart := 0, 1, 0
+	eof := false
+	for {
+		// Read a chunk more after the source kept from the last one.
+		want := len(buf) + chunkSize
+		if cap(buf) < want {
+			buf = append(make([]byte, 0, max(want, 2*cap(buf))), buf...)
+		}
+		for len(buf) < want && !eof {
+			n, err := r.Read(buf[len(buf):want])
+			buf = buf[:len(buf)+n]
+			if errors.Is(err, io.EOF) {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		window := string(buf)
+		l := &Lexer{src: window, line: line, lineStart: lineStart - base}
+		if base == 0 && strings.HasPrefix(window, "\uFEFF") {
+			l.off = len("\uFEFF")
+			l.lineStart = l.off
+		}
+		var saved Lexer
+		for {
+			l.skipSpace()
+			saved = *l
+			t := l.Next()
+			if t.Kind == EOF && eof {
+				return nil
+			}
+			// Scanning reads a little past the end of a token, and a token
+			// reaching the end of the chunk may go on in the next, as may
+			// a raw string or comment left open in this one.
+			if !eof && (t.Kind == EOF || t.End()+lookahead > len(window) || unclosed(t)) {
+				break
+			}
+			t.Pos.Offset += base
+			if err := emit(t); err != nil {
+				return err
+			}
+		}
+
+		// Keep the source from the token that could not be finished.
+		line, lineStart = saved.line, saved.lineStart+base
+		base += saved.off
+		buf = buf[:copy(buf, buf[saved.off:])]
+		if len(buf) >= MaxTokenSize {
+			return fmt.Errorf("%w: more than %d bytes at offset %d", ErrTokenTooLong, MaxTokenSize, base)
+		}
+	}
+}
+
+// TokenizeReaderText tokenizes the source read from r as TokenizeReader
+// does, calling text with the source before, between and after the tokens,
+// such as whitespace, and emit with each token, in order, so that together
+// they are the whole source. Only the source not yet passed on is kept, so
+// that the source can be rendered as it is read. An error from r, text or
+// emit stops the tokenizing and is returned.
+func TokenizeReaderText(r io.Reader, text func(string) error, emit func(Token) error) error {
+	var pending bytes.Buffer
+	// off is the offset in the source of the start of pending.
+	off := 0
+	err := TokenizeReader(io.TeeReader(r, &pending), func(t Token) error {
+		if gap := t.Pos.Offset - off; gap > 0 {
+			if err := text(string(pending.Next(gap))); err != nil {
+				return err
+			}
+		}
+		pending.Next(len(t.Text))
+		off = t.End()
+		return emit(t)
+	})
+	if err != nil {
+		return err
+	}
+	if pending.Len() > 0 {
+		return text(pending.String())
+	}
+	return nil
+}
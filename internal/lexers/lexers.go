@@ -0,0 +1,83 @@
+// Package lexers is a registry of lexers for the languages greeter
+// highlights, so that new languages can be added without changing this
+// module: in Go, with Register, or as a separate program that speaks the
+// protocol of Serve, found on the PATH by Discover.
+package lexers
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Lexer tokenizes source in one language.
+type Lexer interface {
+	// Name is the language, named as the detect package names it, such as
+	// go or python.
+	Name() string
+	// Tokenize returns the tokens of src in order, each with Text exactly
+	// the source it spans.
+	Tokenize(src []byte) ([]lexer.Token, error)
+}
+
+// Func returns a Lexer called name that tokenizes with f.
+func Func(name string, f func(src []byte) []lexer.Token) Lexer {
+	return funcLexer{name, f}
+}
+
+type funcLexer struct {
+	name string
+	f    func([]byte) []lexer.Token
+}
+
+func (l funcLexer) Name() string { return l.name }
+
+func (l funcLexer) Tokenize(src []byte) ([]lexer.Token, error) { return l.f(src), nil }
+
+var (
+	lexersMu sync.RWMutex
+	lexers   = make(map[string]Lexer)
+)
+
+func init() {
+	Register(Func("go", lexer.Tokenize))
+}
+
+// Register makes l available by its name. It panics if the name is already
+// taken or l is nil.
+func Register(l Lexer) {
+	lexersMu.Lock()
+	defer lexersMu.Unlock()
+	if l == nil {
+		panic("lexers: Register lexer is nil")
+	}
+	if _, dup := lexers[l.Name()]; dup {
+		panic("lexers: Register called twice for " + l.Name())
+	}
+	lexers[l.Name()] = l
+}
+
+// Lookup returns the lexer registered for the language name.
+func Lookup(name string) (Lexer, error) {
+	lexersMu.RLock()
+	defer lexersMu.RUnlock()
+	l, ok := lexers[name]
+	if !ok {
+		return nil, fmt.Errorf("no lexer for %q", name)
+	}
+	return l, nil
+}
+
+// Names returns the languages with a registered lexer, sorted.
+func Names() []string {
+	lexersMu.RLock()
+	defer lexersMu.RUnlock()
+	names := make([]string, 0, len(lexers))
+	for name := range lexers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,235 @@
+package lexers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// A plugin is a program that tokenizes over its standard input and output,
+// one JSON object per line each way. The host sends a Request and reads a
+// Response: first a describe request, answered with the plugin's name, then
+// a tokenize request for each source. The source is base64, as JSON writes
+// []byte, so that it need not be valid UTF-8.
+//
+//	{"method":"describe"}
+//	{"name":"ini","extensions":[".ini"]}
+//	{"method":"tokenize","source":"Zm9vPTEK"}
+//	{"tokens":[{"kind":"ident","offset":0,"length":3},...]}
+
+// PluginPrefix starts the names of plugin programs, followed by the name of
+// their language, as in greeter-lexer-ini.
+const PluginPrefix = "greeter-lexer-"
+
+// Request is a message from the host to a plugin.
+type Request struct {
+	// Method is describe or tokenize.
+	Method string `json:"method"`
+	Source []byte `json:"source,omitempty"`
+}
+
+// Response is a plugin's answer to a Request. Error is set, and nothing
+// else, if the request failed.
+type Response struct {
+	Name       string   `json:"name,omitempty"`
+	Extensions []string `json:"extensions,omitempty"`
+	Tokens     []Span   `json:"tokens,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Span is a token as a plugin reports it: its kind, named as
+// lexer.Kind.String writes it, and the bytes of the source it spans.
+type Span struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// Serve answers the requests on r on w, as a plugin for the language name,
+// with the extensions given, tokenizing with tokenize, until r ends.
+func Serve(r io.Reader, w io.Writer, name string, extensions []string, tokenize func(src []byte) []lexer.Token) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var req Request
+		if err := dec.Decode(&req); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var resp Response
+		switch req.Method {
+		case "describe":
+			resp = Response{Name: name, Extensions: extensions}
+		case "tokenize":
+			for _, t := range tokenize(req.Source) {
+				resp.Tokens = append(resp.Tokens, Span{Kind: t.Kind.String(), Offset: t.Pos.Offset, Length: len(t.Text)})
+			}
+		default:
+			resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Plugin is a lexer running as a separate program. Requests are sent one at
+// a time, so a Plugin may be used by several goroutines.
+type Plugin struct {
+	name       string
+	extensions []string
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// Start runs the plugin program path with args and asks for its name.
+func Start(path string, args ...string) (*Plugin, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Plugin{cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin), dec: json.NewDecoder(bufio.NewReader(stdout))}
+	resp, err := p.call(Request{Method: "describe"})
+	if err == nil && resp.Name == "" {
+		err = errors.New("it described itself without a name")
+	}
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("lexer plugin %s: %w", path, err)
+	}
+	p.name, p.extensions = resp.Name, resp.Extensions
+	return p, nil
+}
+
+// Name returns the language the plugin described itself as for.
+func (p *Plugin) Name() string { return p.name }
+
+// Extensions returns the file extensions the plugin described itself as
+// for, such as .ini.
+func (p *Plugin) Extensions() []string { return p.extensions }
+
+// Tokenize sends src to the plugin and returns the tokens it reports, after
+// checking that they are in order and within src.
+func (p *Plugin) Tokenize(src []byte) ([]lexer.Token, error) {
+	resp, err := p.call(Request{Method: "tokenize", Source: src})
+	if err != nil {
+		return nil, fmt.Errorf("lexer plugin %s: %w", p.name, err)
+	}
+	toks, err := Tokens(src, resp.Tokens)
+	if err != nil {
+		return nil, fmt.Errorf("lexer plugin %s: %w", p.name, err)
+	}
+	return toks, nil
+}
+
+func (p *Plugin) call(req Request) (Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.enc.Encode(req); err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	if err := p.dec.Decode(&resp); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("it exited")
+		}
+		return Response{}, err
+	}
+	if resp.Error != "" {
+		return Response{}, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// Close ends the plugin's input, which should make it exit, and waits for
+// it.
+func (p *Plugin) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// Tokens returns spans of src as tokens, with their text and positions.
+// It reports spans that are empty, out of order, past the end of src or of
+// an unknown kind.
+func Tokens(src []byte, spans []Span) ([]lexer.Token, error) {
+	toks := make([]lexer.Token, 0, len(spans))
+	off, line, lineStart := 0, 1, 0
+	for i, s := range spans {
+		kind, ok := lexer.ParseKind(s.Kind)
+		switch {
+		case !ok || kind == lexer.EOF:
+			return nil, fmt.Errorf("token %d has unknown kind %q", i, s.Kind)
+		case s.Length <= 0 || s.Offset < off || s.Offset+s.Length > len(src):
+			return nil, fmt.Errorf("token %d, of %d bytes at offset %d, is empty, out of order or past the source", i, s.Length, s.Offset)
+		}
+		for ; off < s.Offset; off++ {
+			if src[off] == '\n' {
+				line++
+				lineStart = off + 1
+			}
+		}
+		text := string(src[s.Offset : s.Offset+s.Length])
+		toks = append(toks, lexer.Token{Kind: kind, Text: text, Pos: lexer.Pos{Offset: s.Offset, Line: line, Column: s.Offset - lineStart + 1}})
+		if n := strings.Count(text, "\n"); n > 0 {
+			line += n
+			lineStart = s.Offset + strings.LastIndexByte(text, '\n') + 1
+		}
+		off = s.Offset + s.Length
+	}
+	return toks, nil
+}
+
+// Found is a plugin program found by Discover, which has not been started.
+type Found struct {
+	// Name is the language, from the program's name.
+	Name string
+	Path string
+}
+
+// Discover returns the plugin programs in dirs, such as the directories of
+// the PATH, with the first of each name winning, sorted by name.
+func Discover(dirs []string) []Found {
+	var found []Found
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		if dir == "" {
+			dir = "."
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, PluginPrefix+"*"))
+		for _, path := range matches {
+			name, exe := strings.CutSuffix(strings.TrimPrefix(filepath.Base(path), PluginPrefix), ".exe")
+			// Windows marks no file executable, but names programs .exe.
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() || !exe && info.Mode().Perm()&0o111 == 0 || seen[name] {
+				continue
+			}
+			seen[name] = true
+			found = append(found, Found{Name: name, Path: path})
+		}
+	}
+	slices.SortFunc(found, func(a, b Found) int { return strings.Compare(a.Name, b.Name) })
+	return found
+}
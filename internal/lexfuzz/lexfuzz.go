@@ -0,0 +1,271 @@
+// Package lexfuzz mutates source into pathological cases, such as
+// unterminated strings and invalid UTF-8, and checks that tokenizers
+// survive them. Its tests also run Check under Go's fuzzer, as in
+// go test -fuzz FuzzTokenize ./internal/lexfuzz.
+package lexfuzz
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// A Tokenizer under test.
+type Tokenizer struct {
+	Name     string
+	Tokenize func(src []byte) []lexer.Token
+	// Covers is whether every byte outside the tokens must be whitespace,
+	// as for the built-in lexer. Engines that leave unscoped text between
+	// tokens, such as TextMate grammars, do not cover their input.
+	Covers bool
+	// SkipsBOM is whether a byte order mark at the start is left out of
+	// positions, so that columns on the first line count from after it.
+	SkipsBOM bool
+}
+
+// Options control a run.
+type Options struct {
+	// Corpus holds the snippets to mutate.
+	Corpus [][]byte
+	// Iterations is the number of mutated inputs to try with each tokenizer.
+	Iterations int
+	// Seed makes the run repeatable; the same seed gives the same inputs.
+	Seed uint64
+	// Timeout bounds one call of Tokenize, so that a tokenizer that loops
+	// is reported rather than hanging the run. Zero means five seconds.
+	Timeout time.Duration
+}
+
+// Failure is an input a tokenizer failed on.
+type Failure struct {
+	Tokenizer string
+	// Mutations are the names of the mutations that made Input.
+	Mutations []string
+	Input     []byte
+	Err       error
+}
+
+// Run feeds each tokenizer opts.Iterations mutated inputs and returns the
+// failures, at most one for each tokenizer and set of mutations.
+func Run(tokenizers []Tokenizer, opts Options) []Failure {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if len(opts.Corpus) == 0 {
+		opts.Corpus = [][]byte{nil}
+	}
+	var failures []Failure
+	for _, t := range tokenizers {
+		r := rand.New(rand.NewPCG(opts.Seed, 0))
+		seen := map[string]bool{}
+		for i := 0; i < opts.Iterations; i++ {
+			src := opts.Corpus[r.IntN(len(opts.Corpus))]
+			input, mutations := Mutate(r, src)
+			key := strings.Join(mutations, ",")
+			if seen[key] {
+				continue
+			}
+			if err := Check(t, input, opts.Timeout); err != nil {
+				seen[key] = true
+				failures = append(failures, Failure{Tokenizer: t.Name, Mutations: mutations, Input: input, Err: err})
+			}
+		}
+	}
+	return failures
+}
+
+// Check tokenizes src with t and reports a panic, a call taking longer
+// than timeout, or tokens that are empty, out of order, past the end of
+// src, do not match the source at their offsets, or have positions that
+// disagree with their offsets. If t.Covers, it also reports source
+// outside the tokens that is not whitespace.
+func Check(t Tokenizer, src []byte, timeout time.Duration) error {
+	type result struct {
+		toks []lexer.Token
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- result{err: fmt.Errorf("panic: %v", v)}
+			}
+		}()
+		done <- result{toks: t.Tokenize(bytes.Clone(src))}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(timeout):
+		// The goroutine is left running; there is no stopping it.
+		return fmt.Errorf("no result after %v", timeout)
+	}
+	if res.err != nil {
+		return res.err
+	}
+	return checkTokens(t, res.toks, src)
+}
+
+func checkTokens(t Tokenizer, toks []lexer.Token, src []byte) error {
+	// line and lineStart track the position of scanned, so that positions
+	// are checked in one pass.
+	off, line, lineStart, scanned := 0, 1, 0, 0
+	if t.SkipsBOM && bytes.HasPrefix(src, []byte("\uFEFF")) {
+		off = len("\uFEFF")
+		lineStart, scanned = off, off
+	}
+	for i, tok := range toks {
+		start, end := tok.Pos.Offset, tok.End()
+		switch {
+		case tok.Text == "":
+			return fmt.Errorf("token %d (%s) at offset %d is empty", i, tok.Kind, start)
+		case start < off:
+			return fmt.Errorf("token %d (%s) at offset %d overlaps the one before, which ends at %d", i, tok.Kind, start, off)
+		case end > len(src):
+			return fmt.Errorf("token %d (%s) at offset %d ends past the source, at %d of %d", i, tok.Kind, start, end, len(src))
+		case tok.Text != string(src[start:end]):
+			return fmt.Errorf("token %d (%s) at offset %d has text %q, but the source has %q", i, tok.Kind, start, tok.Text, src[start:end])
+		}
+		if t.Covers {
+			if gap := bytes.Trim(src[off:start], " \t\r\n"); len(gap) > 0 {
+				return fmt.Errorf("source at offset %d, %q, is in no token", off, gap)
+			}
+		}
+		for ; scanned < start; scanned++ {
+			if src[scanned] == '\n' {
+				line++
+				lineStart = scanned + 1
+			}
+		}
+		if want := (lexer.Pos{Offset: start, Line: line, Column: start - lineStart + 1}); tok.Pos != want {
+			return fmt.Errorf("token %d (%s) at offset %d is at %d:%d, not %d:%d", i, tok.Kind, start, tok.Pos.Line, tok.Pos.Column, want.Line, want.Column)
+		}
+		off = end
+	}
+	if t.Covers {
+		if gap := bytes.Trim(src[off:], " \t\r\n"); len(gap) > 0 {
+			return fmt.Errorf("source at offset %d, %q, is in no token", off, gap)
+		}
+	}
+	return nil
+}
+
+// Mutate applies one to three mutations, chosen with r, to a copy of src
+// and returns it with their names.
+func Mutate(r *rand.Rand, src []byte) ([]byte, []string) {
+	out := bytes.Clone(src)
+	n := 1 + r.IntN(3)
+	names := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		m := Mutations[r.IntN(len(Mutations))]
+		out = m.Apply(r, out)
+		names = append(names, m.Name)
+	}
+	return out, names
+}
+
+// Mutation is one way of making source pathological.
+type Mutation struct {
+	Name  string
+	Apply func(r *rand.Rand, src []byte) []byte
+}
+
+// Mutations are the mutations Mutate chooses from.
+var Mutations = []Mutation{
+	{"unterminated-string", insertOne(`"`, `'`, "`", `"\`, `'\`)},
+	{"unterminated-comment", insertOne("/*", "/* /*", "<!--", `"""`)},
+	{"nested-comments", insertOne("/* /* */ */", "/* // */", "// /*\n*/", "/*/", "*/")},
+	{"invalid-utf8", insertOne("\xff", "\xc3", "\xe2\x82", "\xed\xa0\x80", "\xc0\xaf", "\xf8\x88\x80\x80\x80")},
+	{"bom", insertOne("\uFEFF")},
+	{"control-chars", insertOne("\x00", "\x1b[31m", "\r", "\f", "\v", "\u2028", "\u202E")},
+	{"long-line", longLine},
+	{"deep-nesting", deepNesting},
+	{"crlf", func(r *rand.Rand, src []byte) []byte {
+		return bytes.ReplaceAll(src, []byte("\n"), []byte("\r\n"))
+	}},
+	{"truncate", func(r *rand.Rand, src []byte) []byte {
+		if len(src) == 0 {
+			return src
+		}
+		return src[:r.IntN(len(src))]
+	}},
+	{"duplicate", func(r *rand.Rand, src []byte) []byte {
+		i, j := span(r, src)
+		return insertAt(src, j, src[i:j])
+	}},
+	{"flip-byte", func(r *rand.Rand, src []byte) []byte {
+		if len(src) == 0 {
+			return src
+		}
+		out := bytes.Clone(src)
+		out[r.IntN(len(out))] ^= 1 << r.IntN(8)
+		return out
+	}},
+}
+
+// insertOne returns a mutation inserting one of pieces at random.
+func insertOne(pieces ...string) func(r *rand.Rand, src []byte) []byte {
+	return func(r *rand.Rand, src []byte) []byte {
+		return insertAt(src, r.IntN(len(src)+1), []byte(pieces[r.IntN(len(pieces))]))
+	}
+}
+
+// longLine inserts a line of up to a megabyte, repeating a piece of src or
+// a single character.
+func longLine(r *rand.Rand, src []byte) []byte {
+	piece := []byte("x")
+	if i, j := span(r, src); j > i {
+		piece = bytes.ReplaceAll(src[i:j], []byte("\n"), nil)
+	}
+	if len(piece) == 0 {
+		piece = []byte("+")
+	}
+	n := (1 + r.IntN(1<<20)) / len(piece)
+	return insertAt(src, r.IntN(len(src)+1), bytes.Repeat(piece, max(n, 1)))
+}
+
+// deepNesting inserts thousands of nested brackets, balanced or not.
+func deepNesting(r *rand.Rand, src []byte) []byte {
+	pairs := []string{"()", "[]", "{}", `""`, "/**/", "<>"}
+	p := pairs[r.IntN(len(pairs))]
+	half := len(p) / 2
+	depth := 1 + r.IntN(10000)
+	nested := strings.Repeat(p[:half], depth)
+	if r.IntN(2) == 0 {
+		nested += strings.Repeat(p[half:], depth)
+	}
+	return insertAt(src, r.IntN(len(src)+1), []byte(nested))
+}
+
+// span returns a random range of src, ending within a few hundred bytes
+// of its start and splitting no UTF-8 sequence.
+func span(r *rand.Rand, src []byte) (int, int) {
+	if len(src) == 0 {
+		return 0, 0
+	}
+	i := runeStart(src, r.IntN(len(src)))
+	return i, runeStart(src, min(len(src), i+1+r.IntN(256)))
+}
+
+// runeStart returns i, or the start of the UTF-8 sequence it is inside.
+func runeStart(src []byte, i int) int {
+	for i > 0 && i < len(src) && !utf8.RuneStart(src[i]) {
+		i--
+	}
+	return i
+}
+
+// insertAt returns src with piece inserted at i, splitting no UTF-8
+// sequence so that encoding errors are only made on purpose.
+func insertAt(src []byte, i int, piece []byte) []byte {
+	i = runeStart(src, i)
+	out := make([]byte, 0, len(src)+len(piece))
+	out = append(out, src[:i]...)
+	out = append(out, piece...)
+	return append(out, src[i:]...)
+}
@@ -0,0 +1,91 @@
+package lexfuzz
+
+import (
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/samples"
+)
+
+// timeout bounds one tokenization, far above what any input takes.
+const timeout = 10 * time.Second
+
+var builtin = Tokenizer{Name: "builtin", Tokenize: lexer.Tokenize, Covers: true, SkipsBOM: true}
+
+// corpus returns what greeter fuzz mutates by default: the test.* files at
+// the root of the module, the generated samples and the Unicode edge
+// cases, with one mutation of each, as Run would try.
+func corpus(f *testing.F) [][]byte {
+	f.Helper()
+	files, err := filepath.Glob(filepath.Join("..", "..", "test.*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	var srcs [][]byte
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		srcs = append(srcs, src)
+	}
+	ss, err := samples.Generate(samples.Options{})
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, s := range ss {
+		srcs = append(srcs, []byte(s.Source))
+	}
+	for _, uc := range samples.UnicodeCases() {
+		srcs = append(srcs, []byte(uc.Source))
+	}
+	r := rand.New(rand.NewPCG(1, 0))
+	for _, src := range srcs[:len(srcs):len(srcs)] {
+		mutated, _ := Mutate(r, src)
+		srcs = append(srcs, mutated)
+	}
+	return srcs
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, src := range corpus(f) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(builtin, src, timeout); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// FuzzHighlighterEdit checks that the tokens after an edit are sound and
+// those Tokenize gives the edited source. The offset and length are taken
+// modulo the source, so that every input makes an edit.
+func FuzzHighlighterEdit(f *testing.F) {
+	for i, src := range corpus(f) {
+		// Edits that open or close a string or comment reach furthest.
+		inserted := []string{"`", "*/", "/*", `"`, "\n", ""}[i%6]
+		f.Add(src, uint(len(src)/2), uint(i%3), []byte(inserted))
+	}
+	f.Fuzz(func(t *testing.T, src []byte, offset, deleted uint, inserted []byte) {
+		h := lexer.NewHighlighter(src)
+		off := int(offset % uint(len(src)+1))
+		n := int(deleted % uint(len(src)-off+1))
+		if _, err := h.Edit(off, n, inserted); err != nil {
+			t.Fatal(err)
+		}
+		edited := []byte(h.Source())
+		incremental := Tokenizer{Name: "highlighter", Tokenize: func([]byte) []lexer.Token { return h.Tokens() }, Covers: true, SkipsBOM: true}
+		if err := Check(incremental, edited, timeout); err != nil {
+			t.Fatalf("after replacing %d bytes at %d with %q: %v", n, off, inserted, err)
+		}
+		if want := lexer.Tokenize(edited); !slices.Equal(h.Tokens(), want) {
+			t.Fatalf("after replacing %d bytes at %d with %q, the tokens are\n%v\nnot, as Tokenize gives,\n%v", n, off, inserted, h.Tokens(), want)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Package linerange parses sets of line numbers such as 3-5,9.
+package linerange
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range is the lines First to Last, inclusive.
+type Range struct {
+	First, Last int
+}
+
+// Set is a set of lines, as a list of ranges that may overlap.
+type Set []Range
+
+// Parse parses comma-separated line numbers and ranges of them, as in
+// "3-5,9". Lines count from 1; the empty string is the empty set.
+func Parse(s string) (Set, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var set Set
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		a, err := parseLine(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line range %q: %w", part, err)
+		}
+		b := a
+		if isRange {
+			if b, err = parseLine(last); err != nil {
+				return nil, fmt.Errorf("invalid line range %q: %w", part, err)
+			}
+			if b < a {
+				return nil, fmt.Errorf("invalid line range %q: it ends before it starts", part)
+			}
+		}
+		set = append(set, Range{a, b})
+	}
+	return set, nil
+}
+
+func parseLine(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 0, errors.New("want line numbers from 1")
+	}
+	return n, nil
+}
+
+// Contains reports whether line n is in s.
+func (s Set) Contains(n int) bool {
+	for _, r := range s {
+		if r.First <= n && n <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns s as Parse reads it.
+func (s Set) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = strconv.Itoa(r.First)
+		if r.Last != r.First {
+			parts[i] += "-" + strconv.Itoa(r.Last)
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,92 @@
+package locale
+
+// data is what CLDR has for formatting in a locale.
+type data struct {
+	// dates are the date patterns for each Style, in CLDR's syntax: d, M
+	// and y for the day, month and year, MMM and MMMM for the month's
+	// short and full name, and quoted literals.
+	dates [3]string
+	// months and shortMonths are the names of the months as dates write
+	// them, which in some languages is not how they are named alone:
+	// 5. ledna, not 5. leden.
+	months, shortMonths [12]string
+	decimal, group      string
+	// minGrouping is the digits above the first group a number needs to
+	// be grouped: with 2, 1000 is not, but 10 000 is.
+	minGrouping int
+	// currency is the pattern for money, with # for the number and ¤ for
+	// the currency's symbol.
+	currency string
+	// symbols are the currency symbols that are not the ISO code.
+	symbols map[string]string
+}
+
+// currencyDigits are the fraction digits of the currencies that do not
+// have 2.
+var currencyDigits = map[string]int{
+	"JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+var cldr = map[string]*data{
+	"en": {
+		dates:       [3]string{"MMMM d, y", "MMM d, y", "M/d/yy"},
+		months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		decimal:     ".",
+		group:       ",",
+		minGrouping: 1,
+		currency:    "¤#",
+		symbols:     map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥"},
+	},
+	"en-gb": {
+		dates:       [3]string{"d MMMM y", "d MMM y", "dd/MM/y"},
+		months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sept", "Oct", "Nov", "Dec"},
+		decimal:     ".",
+		group:       ",",
+		minGrouping: 1,
+		currency:    "¤#",
+		symbols:     map[string]string{"USD": "US$", "EUR": "€", "GBP": "£", "JPY": "JP¥"},
+	},
+	"cs": {
+		dates:       [3]string{"d. MMMM y", "d. M. y", "dd.MM.yy"},
+		months:      [12]string{"ledna", "února", "března", "dubna", "května", "června", "července", "srpna", "září", "října", "listopadu", "prosince"},
+		shortMonths: [12]string{"led", "úno", "bře", "dub", "kvě", "čvn", "čvc", "srp", "zář", "říj", "lis", "pro"},
+		decimal:     ",",
+		group:       "\u00a0",
+		minGrouping: 1,
+		currency:    "#\u00a0¤",
+		symbols:     map[string]string{"CZK": "Kč", "USD": "US$", "EUR": "€", "GBP": "£", "JPY": "JP¥"},
+	},
+	"de": {
+		dates:       [3]string{"d. MMMM y", "dd.MM.y", "dd.MM.yy"},
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		decimal:     ",",
+		group:       ".",
+		minGrouping: 1,
+		currency:    "#\u00a0¤",
+		symbols:     map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥"},
+	},
+	"es": {
+		dates:       [3]string{"d 'de' MMMM 'de' y", "d MMM y", "d/M/yy"},
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		decimal:     ",",
+		group:       ".",
+		minGrouping: 2,
+		currency:    "#\u00a0¤",
+		symbols:     map[string]string{"USD": "US$", "EUR": "€"},
+	},
+	"fr": {
+		dates:       [3]string{"d MMMM y", "d MMM y", "dd/MM/y"},
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		decimal:     ",",
+		group:       "\u202f",
+		minGrouping: 1,
+		currency:    "#\u00a0¤",
+		symbols:     map[string]string{"USD": "$US", "EUR": "€", "GBP": "£GB"},
+	},
+}
@@ -0,0 +1,139 @@
+// Package mdcode finds the fenced code blocks of Markdown documents and
+// replaces them with highlighted HTML.
+package mdcode
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Block is a fenced code block.
+type Block struct {
+	// Lang is the first word of the info string, such as go in ```go, or
+	// "" for none. Info is the whole info string.
+	Lang string
+	Info string
+	// Code is the content, without the fences and the fence's indentation.
+	Code []byte
+	// Start and End are the offsets of the block in the document, from the
+	// opening fence to the end of the closing fence's line, and Line is
+	// the line of the opening fence, from 1.
+	Start, End int
+	Line       int
+}
+
+// Blocks returns the fenced code blocks of the Markdown document src, in
+// order. Fences are runs of three or more backticks or tildes, indented by
+// at most three spaces, as in CommonMark; a block whose fence is never
+// closed runs to the end of the document. Fences nested in block quotes or
+// indented in list items are not recognized.
+func Blocks(src []byte) []Block {
+	var blocks []Block
+	var open *Block
+	var fence string
+	indent := 0
+	var code bytes.Buffer
+	off := 0
+	for n := 1; off < len(src); n++ {
+		end := len(src)
+		if i := bytes.IndexByte(src[off:], '\n'); i >= 0 {
+			end = off + i + 1
+		}
+		line := string(src[off:end])
+		switch {
+		case open == nil:
+			if f, ind, info, ok := openingFence(line); ok {
+				lang, _, _ := strings.Cut(info, " ")
+				open = &Block{Lang: lang, Info: info, Start: off, Line: n}
+				fence, indent = f, ind
+				code.Reset()
+			}
+		case closesFence(line, fence):
+			open.Code = bytes.Clone(code.Bytes())
+			open.End = end
+			blocks = append(blocks, *open)
+			open = nil
+		default:
+			code.WriteString(dedent(line, indent))
+		}
+		off = end
+	}
+	if open != nil {
+		open.Code = bytes.Clone(code.Bytes())
+		open.End = len(src)
+		blocks = append(blocks, *open)
+	}
+	return blocks
+}
+
+// openingFence reports whether line opens a fenced block, and returns the
+// fence, its indentation and the trimmed info string.
+func openingFence(line string) (fence string, indent int, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent = len(line) - len(trimmed)
+	if indent > 3 || len(trimmed) < 3 || trimmed[0] != '`' && trimmed[0] != '~' {
+		return "", 0, "", false
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return "", 0, "", false
+	}
+	fence = trimmed[:n]
+	info = strings.TrimSpace(trimmed[n:])
+	// The info string of a backtick fence may not hold backticks, so that
+	// inline code such as ```a``` is not taken for a fence.
+	if fence[0] == '`' && strings.Contains(info, "`") {
+		return "", 0, "", false
+	}
+	return fence, indent, info, true
+}
+
+// closesFence reports whether line closes a block opened by fence: a run of
+// the same character at least as long, with nothing after it.
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	rest := strings.TrimLeft(trimmed, fence[:1])
+	return len(trimmed)-len(rest) >= len(fence) && strings.TrimSpace(rest) == ""
+}
+
+// dedent removes up to indent spaces from the start of line.
+func dedent(line string, indent int) string {
+	for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+		line = line[1:]
+	}
+	return line
+}
+
+// A Highlighter returns the code of a block as HTML, or false to leave the
+// block as it is.
+type Highlighter func(b Block) (html string, ok bool)
+
+// Process returns src with each fenced code block that highlight accepts
+// replaced by its HTML, followed by a blank line so that the Markdown
+// after it starts a new block. It also returns the blocks found.
+func Process(src []byte, highlight Highlighter) ([]byte, []Block) {
+	blocks := Blocks(src)
+	var out bytes.Buffer
+	off := 0
+	for _, b := range blocks {
+		html, ok := highlight(b)
+		if !ok {
+			continue
+		}
+		out.Write(src[off:b.Start])
+		out.WriteString(html)
+		if !strings.HasSuffix(html, "\n") {
+			out.WriteByte('\n')
+		}
+		// A blank line ends the HTML block.
+		if b.End < len(src) && !bytes.HasPrefix(src[b.End:], []byte("\n")) {
+			out.WriteByte('\n')
+		}
+		off = b.End
+	}
+	out.Write(src[off:])
+	return out.Bytes(), blocks
+}
@@ -0,0 +1,115 @@
+package normalize
+
+import (
+	"strings"
+	"unicode"
+)
+
+type CaseMode int
+
+const (
+	CaseKeep CaseMode = iota
+	CaseLower
+	CaseFold
+)
+
+type Normalizer struct {
+	Case CaseMode
+}
+
+var Default = &Normalizer{}
+
+// Normalize composes combining marks (NFC for Latin, Greek and Cyrillic),
+// drops invisible format characters such as U+200B, collapses runs of
+// whitespace and applies the configured case mode.
+func (n *Normalizer) Normalize(s string) string {
+	if n.isNormal(s) {
+		return s
+	}
+	s = strings.Join(strings.Fields(compose(s)), " ")
+	switch n.Case {
+	case CaseLower:
+		s = strings.ToLower(s)
+	case CaseFold:
+		s = strings.Map(foldRune, s)
+	}
+	return s
+}
+
+// Append appends Normalize(s) to dst and returns the extended buffer,
+// without allocating when s is already normal, as most names are.
+func (n *Normalizer) Append(dst []byte, s string) []byte {
+	if n.isNormal(s) {
+		return append(dst, s...)
+	}
+	return append(dst, n.Normalize(s)...)
+}
+
+// isNormal reports whether s is printable ASCII, with single spaces only
+// between words, and in n's case, so that Normalize would return it as it
+// is.
+func (n *Normalizer) isNormal(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ':
+			if i == 0 || i == len(s)-1 || s[i-1] == ' ' {
+				return false
+			}
+		case c < '!' || c > '~':
+			return false
+		case 'A' <= c && c <= 'Z' && n.Case != CaseKeep:
+			return false
+		}
+	}
+	return true
+}
+
+func compose(s string) string {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		if last := len(out) - 1; last >= 0 && unicode.Is(unicode.Mn, r) {
+			if c, ok := compositions[[2]rune{out[last], r}]; ok {
+				out[last] = c
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// foldRune goes through upper case first so that variants such as "ſ" and
+// the Kelvin sign fold to the same lower-case letter as their plain forms.
+func foldRune(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+var confusableScripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Greek", unicode.Greek},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Armenian", unicode.Armenian},
+	{"Cherokee", unicode.Cherokee},
+}
+
+// Scripts lists the scripts among the commonly confused ones (Latin, Greek,
+// Cyrillic, ...) that letters in s belong to.
+func Scripts(s string) []string {
+	var found []string
+	for _, script := range confusableScripts {
+		for _, r := range s {
+			if unicode.Is(script.table, r) {
+				found = append(found, script.name)
+				break
+			}
+		}
+	}
+	return found
+}
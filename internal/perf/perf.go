@@ -0,0 +1,164 @@
+// Package perf times highlighting benchmarks, records the results as JSON,
+// and compares them against a baseline to find regressions.
+package perf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Benchmark is an operation to time, such as tokenizing the corpus. Bytes is
+// how much source one call of Run processes, for MB/s, or zero.
+type Benchmark struct {
+	Name  string
+	Bytes int64
+	Run   func()
+}
+
+// Result is the measurement of a Benchmark, per call of Run.
+type Result struct {
+	Name        string  `json:"name"`
+	N           int     `json:"n"`
+	NsPerOp     int64   `json:"ns_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	MBPerSec    float64 `json:"mb_per_s,omitempty"`
+}
+
+// Results are the results of a run, with what they were measured on.
+type Results struct {
+	Time       time.Time `json:"time"`
+	GoVersion  string    `json:"go_version"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	Benchmarks []Result  `json:"benchmarks"`
+}
+
+// Run times each benchmark, calling its Run more times until the calls take
+// at least benchtime, as go test -bench does.
+func Run(benchmarks []Benchmark, benchtime time.Duration) Results {
+	rs := Results{Time: time.Now().UTC(), GoVersion: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+	for _, b := range benchmarks {
+		rs.Benchmarks = append(rs.Benchmarks, measure(b, benchtime))
+	}
+	return rs
+}
+
+func measure(b Benchmark, benchtime time.Duration) Result {
+	// Warm up, so that the first call does not pay for lazy setup.
+	b.Run()
+	n := 1
+	for {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			b.Run()
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if elapsed >= benchtime || n >= 1e9 {
+			r := Result{
+				Name:        b.Name,
+				N:           n,
+				NsPerOp:     elapsed.Nanoseconds() / int64(n),
+				AllocsPerOp: int64(after.Mallocs-before.Mallocs) / int64(n),
+				BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / int64(n),
+			}
+			if b.Bytes > 0 && elapsed > 0 {
+				r.MBPerSec = float64(b.Bytes) * float64(n) / 1e6 / elapsed.Seconds()
+			}
+			return r
+		}
+		// Aim past benchtime, as testing does, growing at most 100-fold.
+		next := n * 100
+		if elapsed > 0 {
+			next = int(1.2 * float64(n) * float64(benchtime) / float64(elapsed))
+		}
+		n = max(min(next, 100*n), n+1)
+	}
+}
+
+// Load reads results written by Save.
+func Load(name string) (Results, error) {
+	var rs Results
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return rs, err
+	}
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return rs, fmt.Errorf("%s: %w", name, err)
+	}
+	return rs, nil
+}
+
+// Save writes rs to the file name as indented JSON.
+func Save(name string, rs Results) error {
+	data, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+// Thresholds are how much worse than the baseline a result may be before
+// it is a regression, as fractions: 0.1 allows 10% more.
+type Thresholds struct {
+	Time   float64
+	Allocs float64
+}
+
+// Comparison is a result beside the baseline's result of the same name.
+type Comparison struct {
+	Name string
+	// Baseline is nil for a benchmark new since the baseline.
+	Baseline *Result
+	Current  Result
+	// TimeChange and AllocsChange are the changes from the baseline as
+	// fractions, so 0.25 is 25% slower.
+	TimeChange, AllocsChange float64
+	// Regressed lists the metrics past their threshold: ns/op and
+	// allocs/op.
+	Regressed []string
+}
+
+// Compare compares each of current's results with baseline's.
+func Compare(baseline, current Results, t Thresholds) []Comparison {
+	base := map[string]Result{}
+	for _, r := range baseline.Benchmarks {
+		base[r.Name] = r
+	}
+	var cs []Comparison
+	for _, r := range current.Benchmarks {
+		c := Comparison{Name: r.Name, Current: r}
+		if b, ok := base[r.Name]; ok {
+			c.Baseline = &b
+			c.TimeChange = change(b.NsPerOp, r.NsPerOp)
+			c.AllocsChange = change(b.AllocsPerOp, r.AllocsPerOp)
+			if c.TimeChange > t.Time {
+				c.Regressed = append(c.Regressed, "ns/op")
+			}
+			if c.AllocsChange > t.Allocs {
+				c.Regressed = append(c.Regressed, "allocs/op")
+			}
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// change returns the change from old to cur as a fraction of old. From
+// zero, any increase is a doubling.
+func change(old, cur int64) float64 {
+	if old == 0 {
+		if cur == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(cur-old) / float64(old)
+}
@@ -0,0 +1,217 @@
+// Package pipeline works through many inputs on a pool of goroutines, so
+// that large batches can use more than one core.
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Options configure a Pool.
+type Options struct {
+	// Workers is how many inputs are worked on at once, or GOMAXPROCS if
+	// zero or less.
+	Workers int
+	// Ordered has results emitted in the order their inputs were
+	// submitted, rather than as they finish.
+	Ordered bool
+	// Timeout, if positive, bounds the work on each input: its context is
+	// cancelled once the timeout passes, and its result is then the
+	// context's error, even if the work carries on regardless.
+	Timeout time.Duration
+}
+
+// Result is the outcome of the work on the input submitted Index-th,
+// counting from 0.
+type Result[O any] struct {
+	Index int
+	Value O
+	Err   error
+}
+
+// Pool works on the inputs submitted to it on a fixed number of goroutines
+// and emits their results one at a time.
+type Pool[I, O any] struct {
+	opts   Options
+	do     func(context.Context, I) (O, error)
+	emit   func(Result[O]) error
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	jobs    chan job[I]
+	results chan Result[O]
+	// slots bounds the inputs submitted but not yet emitted, so that an
+	// Ordered pool waiting on a slow input does not pile up every result
+	// after it.
+	slots     chan struct{}
+	workers   sync.WaitGroup
+	emitted   chan struct{}
+	submitted int
+	// err is the error emit stopped the pool with, set before emitted is
+	// closed.
+	err error
+}
+
+type job[I any] struct {
+	index int
+	in    I
+}
+
+// Start starts a pool that calls do with each input submitted, on
+// opts.Workers goroutines, and emit with each result, on one goroutine of
+// its own, so that emit need not be safe for concurrent use. An error from
+// emit stops the pool: the context of the work under way is cancelled, the
+// inputs not yet started get the error as their result, and no more
+// results are emitted. Cancelling ctx stops the pool too.
+func Start[I, O any](ctx context.Context, opts Options, do func(context.Context, I) (O, error), emit func(Result[O]) error) *Pool[I, O] {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	p := &Pool[I, O]{
+		opts:    opts,
+		do:      do,
+		emit:    emit,
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    make(chan job[I]),
+		results: make(chan Result[O], opts.Workers),
+		slots:   make(chan struct{}, 2*opts.Workers),
+		emitted: make(chan struct{}),
+	}
+	p.workers.Add(opts.Workers)
+	for range opts.Workers {
+		go p.work()
+	}
+	go p.collect()
+	return p
+}
+
+// Submit hands in to the pool, waiting while it is busy. Once the pool has
+// stopped, it returns why instead. Submit must not be called concurrently,
+// nor after Wait.
+func (p *Pool[I, O]) Submit(in I) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.ctx.Done():
+		return context.Cause(p.ctx)
+	}
+	if p.ctx.Err() != nil {
+		<-p.slots
+		return context.Cause(p.ctx)
+	}
+	p.jobs <- job[I]{p.submitted, in}
+	p.submitted++
+	return nil
+}
+
+// Wait waits until every input submitted has been worked on and its result
+// emitted, and returns the error that stopped the pool, if any. It must be
+// called exactly once.
+func (p *Pool[I, O]) Wait() error {
+	close(p.jobs)
+	p.workers.Wait()
+	close(p.results)
+	<-p.emitted
+	defer p.cancel(nil)
+	if p.err != nil {
+		return p.err
+	}
+	return context.Cause(p.ctx)
+}
+
+func (p *Pool[I, O]) work() {
+	defer p.workers.Done()
+	for j := range p.jobs {
+		r := Result[O]{Index: j.index}
+		if p.ctx.Err() != nil {
+			r.Err = context.Cause(p.ctx)
+		} else {
+			r.Value, r.Err = p.call(j.in)
+		}
+		p.results <- r
+	}
+}
+
+// call calls do with in, giving up on it after opts.Timeout.
+func (p *Pool[I, O]) call(in I) (O, error) {
+	if p.opts.Timeout <= 0 {
+		return p.do(p.ctx, in)
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, p.opts.Timeout)
+	defer cancel()
+	type outcome struct {
+		value O
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		v, err := p.do(ctx, in)
+		done <- outcome{v, err}
+	}()
+	select {
+	case o := <-done:
+		return o.value, o.err
+	case <-ctx.Done():
+		var zero O
+		return zero, ctx.Err()
+	}
+}
+
+// collect emits the results as they come, or in order if opts.Ordered,
+// holding back those that finish early.
+func (p *Pool[I, O]) collect() {
+	defer close(p.emitted)
+	early := make(map[int]Result[O])
+	next := 0
+	for r := range p.results {
+		if !p.opts.Ordered {
+			p.emitOne(r)
+			continue
+		}
+		early[r.Index] = r
+		for {
+			r, ok := early[next]
+			if !ok {
+				break
+			}
+			delete(early, next)
+			next++
+			p.emitOne(r)
+		}
+	}
+}
+
+func (p *Pool[I, O]) emitOne(r Result[O]) {
+	if p.err == nil {
+		if err := p.emit(r); err != nil {
+			p.err = err
+			p.cancel(err)
+		}
+	}
+	<-p.slots
+}
+
+// Run calls do with each of inputs, on up to workers goroutines at once, or
+// GOMAXPROCS if workers is zero or less, and returns the results in the
+// order of inputs. Cancelling ctx stops the inputs not yet started, whose
+// results then have its error.
+func Run[I, O any](ctx context.Context, inputs []I, workers int, do func(context.Context, I) (O, error)) []Result[O] {
+	results := make([]Result[O], len(inputs))
+	p := Start(ctx, Options{Workers: workers}, do, func(r Result[O]) error {
+		results[r.Index] = r
+		return nil
+	})
+	for i, in := range inputs {
+		if err := p.Submit(in); err != nil {
+			for j := i; j < len(inputs); j++ {
+				results[j] = Result[O]{Index: j, Err: err}
+			}
+			break
+		}
+	}
+	p.Wait()
+	return results
+}
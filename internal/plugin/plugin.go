@@ -0,0 +1,351 @@
+// Package plugin runs extensions to greeter as separate programs, so that
+// greeters, delivery backends and lexers can be added without rebuilding
+// it. A plugin talks JSON-RPC 2.0 over its standard input and output, one
+// message per line. The host first calls describe, which the plugin answers
+// with its Manifest, and then the methods for what it provides:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"describe"}
+//	{"jsonrpc":"2.0","id":1,"result":{"name":"pirate","greeters":["pirate"]}}
+//	{"jsonrpc":"2.0","id":2,"method":"greet","params":{"greeter":"pirate","name":"Ann"}}
+//	{"jsonrpc":"2.0","id":2,"result":{"text":"Ahoy, Ann!"}}
+//
+// greet is answered with a message, as GET /messages writes them, of which
+// only the text is required; publish is sent a message and answered with
+// null; and tokenize is sent source, as base64, and answered with tokens,
+// as lexer plugins report them. Requests may be sent before earlier ones
+// are answered, so responses are matched to them by ID. A method failing
+// is answered with an error object. Serve implements the plugin side.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/delivery"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexers"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Manifest is what a plugin describes itself as providing.
+type Manifest struct {
+	Name       string     `json:"name"`
+	Greeters   []string   `json:"greeters,omitempty"`
+	Publishers []string   `json:"publishers,omitempty"`
+	Lexers     []LexerDoc `json:"lexers,omitempty"`
+}
+
+// LexerDoc describes a lexer a plugin provides.
+type LexerDoc struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// The parameters of greet, publish and tokenize, and the result of
+// tokenize.
+type (
+	GreetParams struct {
+		Greeter string `json:"greeter"`
+		Name    string `json:"name"`
+		Locale  string `json:"locale,omitempty"`
+	}
+	PublishParams struct {
+		Publisher string          `json:"publisher"`
+		Message   json.RawMessage `json:"message"`
+	}
+	TokenizeParams struct {
+		Lexer  string `json:"lexer"`
+		Source []byte `json:"source"`
+	}
+	TokenizeResult struct {
+		Tokens []lexers.Span `json:"tokens"`
+	}
+)
+
+// Request and Response are JSON-RPC 2.0 messages.
+type (
+	Request struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id,omitempty"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params,omitempty"`
+	}
+	Response struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result,omitempty"`
+		Error   *Error          `json:"error,omitempty"`
+	}
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// The JSON-RPC error codes plugins use.
+const (
+	CodeParse          = -32700
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	// CodeFailed is for a method that was called correctly but failed.
+	CodeFailed = -32000
+)
+
+// describeTimeout bounds how long a plugin may take to describe itself.
+const describeTimeout = 10 * time.Second
+
+// Plugin is a running plugin program. It may be used by several
+// goroutines at once.
+type Plugin struct {
+	path     string
+	manifest Manifest
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	// exited is closed, with err set, once the plugin's output ends.
+	exited chan struct{}
+	err    error
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan Response
+}
+
+// Start runs the plugin program path and asks it to describe itself.
+func Start(path string) (*Plugin, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Plugin{
+		path:    path,
+		cmd:     cmd,
+		stdin:   stdin,
+		enc:     json.NewEncoder(stdin),
+		exited:  make(chan struct{}),
+		pending: make(map[int64]chan Response),
+	}
+	go p.read(stdout)
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+	err = p.Call(ctx, "describe", nil, &p.manifest)
+	if err == nil && p.manifest.Name == "" {
+		err = errors.New("it described itself without a name")
+	}
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Manifest returns what the plugin described itself as providing.
+func (p *Plugin) Manifest() Manifest { return p.manifest }
+
+// Path returns the plugin's program.
+func (p *Plugin) Path() string { return p.path }
+
+// read hands each response the plugin writes to the call waiting for it,
+// until the output ends.
+func (p *Plugin) read(r io.Reader) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("it exited")
+			}
+			p.err = err
+			close(p.exited)
+			return
+		}
+		var id int64
+		if json.Unmarshal(resp.ID, &id) != nil {
+			continue
+		}
+		p.mu.Lock()
+		c := p.pending[id]
+		delete(p.pending, id)
+		p.mu.Unlock()
+		if c != nil {
+			c <- resp
+		}
+	}
+}
+
+// Call calls method with params, and decodes its result into result
+// unless it is nil.
+func (p *Plugin) Call(ctx context.Context, method string, params, result any) error {
+	req := Request{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+	c := make(chan Response, 1)
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = c
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+	}()
+	req.ID = json.RawMessage(fmt.Sprint(id))
+	p.writeMu.Lock()
+	err := p.enc.Encode(req)
+	p.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case resp := <-c:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-p.exited:
+		return p.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close ends the plugin's input, which should make it exit, and waits for
+// it.
+func (p *Plugin) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// Greeter returns the greeter called name that the plugin provides.
+func (p *Plugin) Greeter(name string) greeting.Greeter {
+	return greeting.GreeterFunc(func(ctx context.Context, who string) (message.Message, error) {
+		params := GreetParams{Greeter: name, Name: who}
+		params.Locale, _ = greeting.LocaleFromContext(ctx)
+		var got message.Message
+		if err := p.Call(ctx, "greet", params, &got); err != nil {
+			return message.Message{}, fmt.Errorf("plugin %s: greeter %s: %w", p.manifest.Name, name, err)
+		}
+		// Plugins need only write the text.
+		m := message.NewMessage(got.Text, got.Tags...)
+		m.Severity = got.Severity
+		if got.Sender != "" {
+			m.Sender = got.Sender
+		}
+		if err := m.Validate(); err != nil {
+			return message.Message{}, fmt.Errorf("plugin %s: greeter %s: %w", p.manifest.Name, name, err)
+		}
+		return m, nil
+	})
+}
+
+// Publisher returns the publisher called name that the plugin provides.
+// Closing it does not stop the plugin.
+func (p *Plugin) Publisher(name string) delivery.Publisher {
+	return publisher{p, name}
+}
+
+type publisher struct {
+	p    *Plugin
+	name string
+}
+
+func (pub publisher) Publish(ctx context.Context, m message.Message) error {
+	data, err := delivery.Encode(m)
+	if err != nil {
+		return err
+	}
+	if err := pub.p.Call(ctx, "publish", PublishParams{Publisher: pub.name, Message: data}, nil); err != nil {
+		return fmt.Errorf("plugin %s: publisher %s: %w", pub.p.manifest.Name, pub.name, err)
+	}
+	return nil
+}
+
+func (publisher) Close() error { return nil }
+
+// Lexer returns the lexer for the language name that the plugin provides.
+func (p *Plugin) Lexer(name string) lexers.Lexer {
+	return pluginLexer{p, name}
+}
+
+type pluginLexer struct {
+	p    *Plugin
+	name string
+}
+
+func (l pluginLexer) Name() string { return l.name }
+
+func (l pluginLexer) Tokenize(src []byte) ([]lexer.Token, error) {
+	var res TokenizeResult
+	if err := l.p.Call(context.Background(), "tokenize", TokenizeParams{Lexer: l.name, Source: src}, &res); err != nil {
+		return nil, fmt.Errorf("plugin %s: lexer %s: %w", l.p.manifest.Name, l.name, err)
+	}
+	toks, err := lexers.Tokens(src, res.Tokens)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: lexer %s: %w", l.p.manifest.Name, l.name, err)
+	}
+	return toks, nil
+}
+
+// Discover returns the programs in dirs, the plugins directories, with
+// the first of each name winning, sorted by name. They have not been
+// started.
+func Discover(dirs []string) []string {
+	var found []string
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			name, exe := strings.CutSuffix(e.Name(), ".exe")
+			// Windows marks no file executable, but names programs .exe.
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() || !exe && info.Mode().Perm()&0o111 == 0 || seen[name] {
+				continue
+			}
+			seen[name] = true
+			found = append(found, path)
+		}
+	}
+	slices.SortFunc(found, func(a, b string) int { return strings.Compare(filepath.Base(a), filepath.Base(b)) })
+	return found
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrMethodNotFound is returned by a Handler for a method it does not
+// know, to answer with CodeMethodNotFound.
+var ErrMethodNotFound = errors.New("method not found")
+
+// A Handler answers a call of method with params, or with an error:
+// ErrMethodNotFound, an *Error to send as is, or any other error for
+// CodeFailed.
+type Handler func(ctx context.Context, method string, params json.RawMessage) (any, error)
+
+// Serve answers the calls on r on w, as a plugin described by m, with
+// handle, until r ends. describe is answered with m. Each call is handled
+// on a goroutine of its own, so handle must be safe for concurrent use.
+func Serve(r io.Reader, w io.Writer, m Manifest, handle Handler) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dec := json.NewDecoder(r)
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	reply := func(resp Response) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(resp)
+	}
+	for {
+		var req Request
+		if err := dec.Decode(&req); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			reply(Response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeParse, Message: err.Error()}})
+			return err
+		}
+		if req.Method == "describe" {
+			data, _ := json.Marshal(m)
+			if err := reply(Response{JSONRPC: "2.0", ID: req.ID, Result: data}); err != nil {
+				return err
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := Response{JSONRPC: "2.0", ID: req.ID}
+			result, err := handle(ctx, req.Method, req.Params)
+			if err == nil {
+				resp.Result, err = json.Marshal(result)
+			}
+			if err != nil {
+				var rpcErr *Error
+				switch {
+				case errors.As(err, &rpcErr):
+					resp.Error = rpcErr
+				case errors.Is(err, ErrMethodNotFound):
+					resp.Error = &Error{Code: CodeMethodNotFound, Message: "unknown method " + req.Method}
+				default:
+					resp.Error = &Error{Code: CodeFailed, Message: err.Error()}
+				}
+			}
+			// A notification, without an ID, is not answered.
+			if len(req.ID) > 0 {
+				reply(resp)
+			}
+		}()
+	}
+}
@@ -0,0 +1,137 @@
+// Package protowire reads and writes the protobuf wire format, for the
+// hand-written bindings under api/.
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Wire types of the protobuf encoding.
+const (
+	Varint = 0
+	I64    = 1
+	Bytes  = 2
+	I32    = 5
+)
+
+var errTruncated = errors.New("protowire: truncated field")
+
+// AppendTag appends the tag of field num, of wire type typ.
+func AppendTag(b []byte, num, typ int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(typ))
+}
+
+// AppendVarint appends field num unless v is zero, which proto3 leaves
+// out. Negative values take ten bytes, as int32 and int64 fields do.
+func AppendVarint(b []byte, num int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(AppendTag(b, num, Varint), uint64(v))
+}
+
+// AppendString appends field num unless s is empty.
+func AppendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return AppendBytes(b, num, []byte(s))
+}
+
+// AppendBytes appends field num, even if v is empty, as repeated and
+// embedded fields need.
+func AppendBytes(b []byte, num int, v []byte) []byte {
+	b = binary.AppendUvarint(AppendTag(b, num, Bytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// Decoder reads the fields of an encoded message in turn.
+type Decoder struct {
+	data []byte
+}
+
+// NewDecoder returns a decoder of the message data.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data}
+}
+
+// Next returns the number and wire type of the next field, with ok false
+// at the end of the message.
+func (d *Decoder) Next() (num, typ int, ok bool, err error) {
+	if len(d.data) == 0 {
+		return 0, 0, false, nil
+	}
+	tag, err := d.uvarint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	num, typ = int(tag>>3), int(tag&7)
+	if num <= 0 || tag>>3 > math.MaxInt32 {
+		return 0, 0, false, fmt.Errorf("protowire: invalid field number %d", tag>>3)
+	}
+	return num, typ, true, nil
+}
+
+func (d *Decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+// Varint reads a varint field of wire type typ.
+func (d *Decoder) Varint(typ int) (int64, error) {
+	if typ != Varint {
+		return 0, fmt.Errorf("protowire: wire type %d, want a varint", typ)
+	}
+	v, err := d.uvarint()
+	return int64(v), err
+}
+
+// Bytes reads a length-delimited field of wire type typ. The result shares
+// memory with the message.
+func (d *Decoder) Bytes(typ int) ([]byte, error) {
+	if typ != Bytes {
+		return nil, fmt.Errorf("protowire: wire type %d, want length-delimited", typ)
+	}
+	l, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(d.data)) {
+		return nil, errTruncated
+	}
+	v := d.data[:l]
+	d.data = d.data[l:]
+	return v, nil
+}
+
+// Skip skips a field of wire type typ, so that fields added to the schema
+// after this code was written are ignored.
+func (d *Decoder) Skip(typ int) error {
+	var n uint64
+	switch typ {
+	case Varint:
+		_, err := d.uvarint()
+		return err
+	case Bytes:
+		_, err := d.Bytes(typ)
+		return err
+	case I64:
+		n = 8
+	case I32:
+		n = 4
+	default:
+		return fmt.Errorf("protowire: unsupported wire type %d", typ)
+	}
+	if n > uint64(len(d.data)) {
+		return errTruncated
+	}
+	d.data = d.data[n:]
+	return nil
+}
@@ -0,0 +1,125 @@
+package samples
+
+import "fmt"
+
+func jsonStrings(int) string {
+	return `{
+  "empty": "",
+  "escapes": "quote \" backslash \\ slash \/ \b\f\n\r\t",
+  "unicode": "\u017elu\u0165ou\u010dk\u00fd k\u016f\u0148 \ud83d\ude42",
+  "literal": "žluťoučký kůň 🙂",
+  "looks like a comment": "// no /* comments */ in JSON",
+  "not a number": "123",
+  "keyword-like": ["true", "null", "false"],
+  "": "an empty key"
+}
+`
+}
+
+func jsonNumbers(int) string {
+	return `{
+  "integers": [0, -0, 7, -42, 9007199254740993],
+  "fractions": [0.5, -3.14159, 100.0],
+  "exponents": [1e3, 1E+3, 6.022e23, 1.5e-10, -2E-2],
+  "literals": [true, false, null]
+}
+`
+}
+
+func jsonNesting(depth int) string {
+	w := &writer{indent: "  "}
+	w.line(0, "{")
+	for i := 1; i <= depth; i++ {
+		w.line(2*i-1, "%q: [", levelName(i))
+		w.line(2*i, "%d,", i)
+		w.line(2*i, "{")
+	}
+	w.line(2*depth+1, `"empty": [{}, [], [[]], {"a": {}}]`)
+	for i := depth; i >= 1; i-- {
+		w.line(2*i, "}")
+		w.line(2*i-1, "]")
+	}
+	w.line(0, "}")
+	return w.String()
+}
+
+func yamlStrings(int) string {
+	return `plain: unquoted text, with a:colon
+single: 'it''s quoted, # not a comment'
+double: "tab\t, newline\n, unicode é \U0001F642"
+multi word key: value
+"quoted key": value
+numeric string: "123"
+boolean string: 'yes'
+literal: |
+  kept
+    indentation
+  and newlines
+folded: >
+  folded into
+  one line
+stripped: |-
+  no trailing newline
+kept: |+
+  trailing newlines kept
+
+anchor: &greeting hello
+alias: *greeting
+tagged: !!str 2024-01-01
+`
+}
+
+func yamlComments(int) string {
+	return `# A full-line comment
+key: value # a trailing comment
+hash: a#b # the first hash is part of the value
+quoted: "# not a comment"
+list:
+  # comment inside a sequence
+  - one # after an item
+  - two
+block: |
+  # inside a block scalar this is text
+  not a comment
+#no space still a comment
+---
+# a second document
+`
+}
+
+func yamlNumbers(int) string {
+	return `integer: 42
+negative: -17
+octal: 0o14
+hex: 0xFF
+float: 3.14159
+exponent: 6.022e+23
+infinity: .inf
+negative infinity: -.Inf
+not a number: .NaN
+version: 1.2.3
+leading zero: 007
+null value: ~
+booleans: [true, false]
+`
+}
+
+func yamlNesting(depth int) string {
+	w := &writer{indent: "  "}
+	for i := 1; i <= depth; i++ {
+		w.line(2*(i-1), "%s:", levelName(i))
+		w.line(2*(i-1)+1, "- %d", i)
+		w.line(2*(i-1)+1, "-")
+	}
+	w.line(2*depth, "leaf: true")
+	flow := "leaf"
+	for i := depth; i >= 1; i-- {
+		if i%2 == 0 {
+			flow = fmt.Sprintf("[%d, %s]", i, flow)
+		} else {
+			flow = fmt.Sprintf("{%s: %s}", levelName(i), flow)
+		}
+	}
+	w.line(0, "flow: %s", flow)
+	return w.String()
+}
@@ -0,0 +1,138 @@
+package samples
+
+import "fmt"
+
+func goStrings(int) string {
+	return `package samples
+
+import "fmt"
+
+const (
+	Empty       = ""
+	Escapes     = "tab\there, newline\n, quote \", backslash \\"
+	Codes       = "\x41\101é\U0001F600"
+	NotComments = "// not a comment /* nor this */"
+	Unicode     = "žluťoučký kůň 日本語 🙂"
+)
+
+var runes = []rune{'a', '\'', '\\', '\n', '\x7f', 'ዤ', 'é', '🙂'}
+
+` + "var raw = `raw \"quotes\" stay, \\n is two characters,\nand lines\n  keep their indentation`\n" + `
+func Format(name string) string {
+	return fmt.Sprintf("%q said %s"+"\n", name, ` + "`" + `\d+` + "`" + `)
+}
+`
+}
+
+func goComments(int) string {
+	return `//go:build !ignore
+
+// Package samples has comments in every position.
+package samples
+
+/*
+Block comments may span lines,
+/* but do not nest, so this line is still the same comment.
+*/
+
+import (
+	"fmt" // trailing comment
+)
+
+// Doc is a doc comment with a [link] and code:
+//
+//	x := "not a string here"
+func Doc( /* inline */ a int /* between */, b int) int {
+	return a /* in an expression */ + b // after code
+}
+
+//nolint:all
+func quoted() {
+	fmt.Println("/* a string */", ` + "`// a raw string`" + `) ///// many slashes
+	/**/ /***/
+}
+`
+}
+
+func goNumbers(int) string {
+	return `package samples
+
+const (
+	Zero        = 0
+	Decimal     = 1234567890
+	Underscores = 1_000_000
+	Hex         = 0xDEAD_beef
+	Octal       = 0o755
+	OldOctal    = 0755
+	Binary      = 0b1010_1010
+	Float       = 3.14159
+	NoFraction  = 1.
+	NoInteger   = .5
+	Exponent    = 6.022e23
+	Negative    = -1e-9
+	HexFloat    = 0x1.8p-3
+	Imaginary   = 2i
+	Complex     = 1 + 0.5i
+	HexImag     = 0x10i
+)
+
+var (
+	char    = 'x' - 'a'
+	shifted = 1 << 10 >> 2
+	ranged  = []int{1: 10, 2: 20}[1:2:2]
+	indexed = "0123456789abcdef"[0x0a]
+)
+`
+}
+
+func goNesting(depth int) string {
+	w := &writer{indent: "\t"}
+	w.line(0, "package samples")
+	w.line(0, "")
+	w.line(0, "var Tree = map[string]any{")
+	for i := 1; i <= depth; i++ {
+		w.line(2*i-1, "%q: []any{", levelName(i))
+		w.line(2*i, "%d,", i)
+		w.line(2*i, "map[string]any{")
+	}
+	w.line(2*depth+1, "%q: struct{ X, Y int }{X: 1, Y: 2},", "leaf")
+	for i := depth; i >= 1; i-- {
+		w.line(2*i, "},")
+		w.line(2*i-1, "},")
+	}
+	w.line(0, "}")
+	w.line(0, "")
+	w.line(0, "func Closures() int {")
+	for i := 1; i <= depth; i++ {
+		w.line(i, "return func() int {")
+	}
+	w.line(depth+1, "return 0")
+	for i := depth; i >= 1; i-- {
+		w.line(i, "}() + %d", i)
+	}
+	w.line(0, "}")
+	w.line(0, "")
+	w.line(0, "func Loops(n int) (count int) {")
+	for i := 1; i <= depth; i++ {
+		v := fmt.Sprintf("i%d", i)
+		w.line(i, "for %s := 0; %s < n; %s++ {", v, v, v)
+		w.line(i+1, "if %s%%2 == 0 {", v)
+		w.line(i+2, "continue")
+		w.line(i+1, "}")
+	}
+	sum := "1"
+	for i := depth; i >= 1; i-- {
+		sum = fmt.Sprintf("(i%d + %s)", i, sum)
+	}
+	w.line(depth+1, "count += %s", sum)
+	for i := depth; i >= 1; i-- {
+		w.line(i, "}")
+	}
+	w.line(1, "return count")
+	w.line(0, "}")
+	return w.String()
+}
+
+func levelName(i int) string {
+	return fmt.Sprintf("level%d", i)
+}
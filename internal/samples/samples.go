@@ -0,0 +1,119 @@
+package samples
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Feature is the aspect of a language a sample exercises.
+type Feature string
+
+const (
+	Strings  Feature = "strings"
+	Comments Feature = "comments"
+	Numbers  Feature = "numbers"
+	Nesting  Feature = "nesting"
+)
+
+// Features lists every feature, in the order samples are generated.
+var Features = []Feature{Strings, Comments, Numbers, Nesting}
+
+// Sample is one generated snippet.
+type Sample struct {
+	Language string
+	Feature  Feature
+	Source   string
+}
+
+// Path is where the sample belongs in a tree of samples, such as
+// go/strings.go.
+func (s Sample) Path() string {
+	return path.Join(s.Language, string(s.Feature)+languages[s.Language].ext)
+}
+
+// Options control Generate. The zero value generates every language at
+// DefaultDepth.
+type Options struct {
+	// Languages limits the samples to these languages.
+	Languages []string
+	// Depth is how deeply the nesting samples nest.
+	Depth int
+}
+
+const DefaultDepth = 4
+
+type language struct {
+	ext string
+	// features generate a sample's source at a nesting depth. A language
+	// without a feature, such as JSON without comments, leaves it out.
+	features map[Feature]func(depth int) string
+}
+
+var languages = map[string]language{
+	"go": {ext: ".go", features: map[Feature]func(int) string{
+		Strings: goStrings, Comments: goComments, Numbers: goNumbers, Nesting: goNesting,
+	}},
+	"json": {ext: ".json", features: map[Feature]func(int) string{
+		Strings: jsonStrings, Numbers: jsonNumbers, Nesting: jsonNesting,
+	}},
+	"yaml": {ext: ".yaml", features: map[Feature]func(int) string{
+		Strings: yamlStrings, Comments: yamlComments, Numbers: yamlNumbers, Nesting: yamlNesting,
+	}},
+	"sql": {ext: ".sql", features: map[Feature]func(int) string{
+		Strings: sqlStrings, Comments: sqlComments, Numbers: sqlNumbers, Nesting: sqlNesting,
+	}},
+	"shell": {ext: ".sh", features: map[Feature]func(int) string{
+		Strings: shellStrings, Comments: shellComments, Numbers: shellNumbers, Nesting: shellNesting,
+	}},
+}
+
+// Languages returns the names of the languages samples are generated in,
+// sorted.
+func Languages() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Generate returns the samples selected by opts, by language and then
+// feature. The output depends only on opts.
+func Generate(opts Options) ([]Sample, error) {
+	names := opts.Languages
+	if len(names) == 0 {
+		names = Languages()
+	}
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+	var out []Sample
+	for _, name := range names {
+		lang, ok := languages[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q: want one of %s", name, strings.Join(Languages(), ", "))
+		}
+		for _, f := range Features {
+			if gen, ok := lang.features[f]; ok {
+				out = append(out, Sample{Language: name, Feature: f, Source: gen(depth)})
+			}
+		}
+	}
+	return out, nil
+}
+
+// writer builds indented source a line at a time.
+type writer struct {
+	strings.Builder
+	indent string
+}
+
+func (w *writer) line(depth int, format string, args ...any) {
+	w.WriteString(strings.Repeat(w.indent, depth))
+	fmt.Fprintf(w, format, args...)
+	w.WriteByte('\n')
+}
@@ -0,0 +1,156 @@
+package samples
+
+import (
+	"fmt"
+	"strings"
+)
+
+func sqlStrings(int) string {
+	return `SELECT
+  '' AS empty,
+  'it''s doubled' AS quote,
+  'spans
+two lines' AS multiline,
+  '-- not a comment' AS dashes,
+  '/* nor this */' AS slashes,
+  'žluťoučký kůň' AS unicode,
+  "Quoted Identifier" AS "Alias With Spaces",
+  'O''Brien' || ' & ' || 'Sons' AS joined
+FROM "messages"
+WHERE sender LIKE '%\_%' ESCAPE '\';
+`
+}
+
+func sqlComments(int) string {
+	return `-- A line comment
+/* A block comment
+   spanning lines */
+SELECT id, -- trailing comment
+       text /* inline */ AS body
+FROM messages
+WHERE text <> '-- in a string'
+  AND sender <> '/* also a string */' --- more dashes
+-- a final comment without a newline`
+}
+
+func sqlNumbers(int) string {
+	return `SELECT
+  0,
+  42,
+  -17,
+  3.14159,
+  .5,
+  1.,
+  6.022e23,
+  1.5E-10,
+  +2,
+  severity * 2 - 1 AS adjusted,
+  CAST('12' AS INTEGER) + 0.0 AS converted
+FROM messages
+LIMIT 10 OFFSET 20;
+`
+}
+
+func sqlNesting(depth int) string {
+	w := &writer{indent: "  "}
+	w.line(0, "WITH recent AS (")
+	w.line(1, "SELECT * FROM messages WHERE created_at > '2024-01-01'")
+	w.line(0, ")")
+	w.line(0, "SELECT t1.id")
+	w.line(0, "FROM (")
+	for i := 1; i < depth; i++ {
+		w.line(i, "SELECT t%d.id FROM (", i+1)
+	}
+	w.line(depth, "SELECT id FROM recent WHERE severity IN (SELECT DISTINCT severity FROM recent)")
+	for i := depth - 1; i >= 1; i-- {
+		w.line(i, ") AS t%d", i+1)
+	}
+	w.line(0, ") AS t1")
+	w.line(0, "WHERE %s;", strings.Repeat("(", depth)+"t1.id IS NOT NULL"+strings.Repeat(")", depth))
+	return w.String()
+}
+
+func shellStrings(int) string {
+	return `#!/bin/sh
+name='single $quotes stay literal'
+greeting="Hello, $USER and ${name}!"
+nested="today is $(date +%A), home is \"$HOME\""
+escaped="backslash \\ dollar \$ backtick \` + "`" + ` quote \""
+ansi=$'tab\there\nnewline'
+concatenated='it'"'"'s'
+empty="" other=''
+` + "old=`echo backticks`" + `
+
+cat <<EOF
+Expanded $name in a here-document
+EOF
+
+cat <<'EOF'
+Literal $name in a quoted here-document
+EOF
+
+cat <<-EOF
+	Leading tabs are stripped
+	EOF
+`
+}
+
+func shellComments(int) string {
+	return `#!/usr/bin/env bash
+# A full-line comment
+echo hello # a trailing comment
+echo a#b   # the first hash is part of the word
+echo '# not a comment' "# nor this"
+length=${#HOME} # the hash in a parameter expansion is not a comment
+: <<'COMMENT'
+A here-document to : is a common block comment.
+COMMENT
+	# an indented comment
+`
+}
+
+func shellNumbers(int) string {
+	return `#!/usr/bin/env bash
+count=42
+negative=-7
+sum=$((count + 1))
+product=$(( (count * 3) / 2 % 5 ))
+hex=$((0xff))
+octal=$((017))
+based=$((16#ff + 8#17 + 2#1010))
+(( count++ ))
+if [ "$count" -gt 10 ] && [ "$count" -le 100 ]; then
+  echo "$sum $product $hex $octal $based $negative"
+fi
+exit 0
+`
+}
+
+func shellNesting(depth int) string {
+	w := &writer{indent: "  "}
+	w.line(0, "#!/bin/sh")
+	w.line(0, "nest() {")
+	for i := 1; i <= depth; i++ {
+		w.line(i, "if [ \"$1\" -ge %d ]; then", i)
+	}
+	w.line(depth+1, "echo deep")
+	for i := depth; i >= 1; i-- {
+		w.line(i, "fi")
+	}
+	w.line(0, "}")
+	w.line(0, "nest %d", depth)
+	sub := "echo leaf"
+	for i := depth; i >= 1; i-- {
+		sub = fmt.Sprintf("echo \"%d $(%s)\"", i, sub)
+	}
+	w.line(0, "%s", sub)
+	w.line(0, "for a in 1 2; do")
+	w.line(1, "for b in x y; do")
+	w.line(2, "case \"$a$b\" in")
+	w.line(3, "1x) echo first ;;")
+	w.line(3, "*) (cd / && { echo \"$a$b\"; }) ;;")
+	w.line(2, "esac")
+	w.line(1, "done")
+	w.line(0, "done")
+	return w.String()
+}
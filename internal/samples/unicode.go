@@ -0,0 +1,104 @@
+package samples
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Token is a token a Case expects, by kind and text.
+type Token struct {
+	Kind lexer.Kind
+	Text string
+}
+
+// Case is a Go snippet with text that highlighters and renderers get wrong,
+// such as bidi overrides and emoji sequences, and the tokens it should
+// split into.
+type Case struct {
+	// Name is the context and the text in it, such as string/rtl-override.
+	Name   string
+	Source string
+	Tokens []Token
+}
+
+// FileName is the name of the case's source file, such as
+// string-rtl-override.go.
+func (c Case) FileName() string {
+	return strings.ReplaceAll(c.Name, "/", "-") + ".go"
+}
+
+// ExpectedTokens formats the tokens of c one per line, as their kind, a tab
+// and their text quoted in ASCII, so that invisible characters show.
+func (c Case) ExpectedTokens() string {
+	var b strings.Builder
+	for _, t := range c.Tokens {
+		b.WriteString(t.Kind.String() + "\t" + strconv.QuoteToASCII(t.Text) + "\n")
+	}
+	return b.String()
+}
+
+// payloads are the awkward texts, written with escapes so that they can be
+// seen here. Each is valid in strings and comments.
+var payloads = []struct{ name, text string }{
+	{"rtl-override", "\u202Eevil\u202C"},
+	{"rtl-isolate", "\u2067\u05E9\u05DC\u05D5\u05DD\u2069 x"},
+	{"trojan-source", "\u202E } \u2066if admin\u2069 \u2066 begin"},
+	{"zwj-emoji", "\U0001F468\u200D\U0001F469\u200D\U0001F467"},
+	{"zero-width", "a\u200Bb\u200Cc\u2060d"},
+	{"combining-marks", "e\u0301\u0302 Z\u0351\u0352\u0353"},
+	{"astral", "\U0001D465\U0001D466 \U00020000"},
+	{"emoji", "\U0001F642"},
+	{"flag", "\U0001F1E8\U0001F1FF"},
+	{"variation-selector", "\u2764\uFE0F \u2764\uFE0E"},
+	{"skin-tone", "\U0001F44D\U0001F3FD"},
+	{"bom", "\uFEFF"},
+	{"line-separator", "a\u2028b\u2029c"},
+}
+
+// identifiers are texts where an identifier may be, with the tokens Go
+// splits them into: letters and digits of any script make identifiers, and
+// anything else, such as combining marks and format characters, is
+// illegal, as the Go specification has it.
+var identifiers = []struct {
+	name, text string
+	tokens     []Token
+}{
+	{"astral", "\U0001D465\U0001D466", []Token{{lexer.Ident, "\U0001D465\U0001D466"}}},
+	{"cjk", "\u65E5\u672C\u8A9E", []Token{{lexer.Ident, "\u65E5\u672C\u8A9E"}}},
+	{"fullwidth-digit", "x\uFF11", []Token{{lexer.Ident, "x\uFF11"}}},
+	{"combining-marks", "e\u0301", []Token{{lexer.Ident, "e"}, {lexer.Illegal, "\u0301"}}},
+	{"zwj", "a\u200Db", []Token{{lexer.Ident, "a"}, {lexer.Illegal, "\u200D"}, {lexer.Ident, "b"}}},
+	{"rtl-override", "a\u202Eb", []Token{{lexer.Ident, "a"}, {lexer.Illegal, "\u202E"}, {lexer.Ident, "b"}}},
+	{"emoji", "\U0001F642", []Token{{lexer.Illegal, "\U0001F642"}}},
+}
+
+// UnicodeCases returns the edge cases: each payload in a string, a raw
+// string, a line comment and a block comment, those of one code point in
+// a rune literal too, and each of identifiers after var. The output never
+// changes.
+func UnicodeCases() []Case {
+	var cases []Case
+	for _, p := range payloads {
+		quoted := `"` + p.text + `"`
+		raw := "`" + p.text + "`"
+		cases = append(cases,
+			Case{"string/" + p.name, "s := " + quoted + "\n", []Token{{lexer.Ident, "s"}, {lexer.Operator, ":="}, {lexer.String, quoted}}},
+			Case{"raw-string/" + p.name, "s := " + raw + "\n", []Token{{lexer.Ident, "s"}, {lexer.Operator, ":="}, {lexer.String, raw}}},
+			Case{"line-comment/" + p.name, "x // " + p.text + "\ny\n", []Token{{lexer.Ident, "x"}, {lexer.Comment, "// " + p.text}, {lexer.Ident, "y"}}},
+			Case{"block-comment/" + p.name, "x /* " + p.text + " */ y\n", []Token{{lexer.Ident, "x"}, {lexer.Comment, "/* " + p.text + " */"}, {lexer.Ident, "y"}}},
+		)
+		if utf8.RuneCountInString(p.text) == 1 {
+			char := "'" + p.text + "'"
+			cases = append(cases, Case{"rune/" + p.name, "r := " + char + "\n", []Token{{lexer.Ident, "r"}, {lexer.Operator, ":="}, {lexer.Char, char}}})
+		}
+	}
+	for _, id := range identifiers {
+		tokens := append([]Token{{lexer.Keyword, "var"}}, id.tokens...)
+		tokens = append(tokens, Token{lexer.Operator, "="}, Token{lexer.Number, "1"})
+		cases = append(cases, Case{"identifier/" + id.name, "var " + id.text + " = 1\n", tokens})
+	}
+	return cases
+}
@@ -0,0 +1,183 @@
+// Package schedule sends greetings on cron schedules. A Scheduler reads
+// the schedules from a store.ScheduleStore, fires each at the times its
+// cron expression matches, and records the last run in the store, so that
+// the runs missed while no scheduler ran are caught up with on start
+// according to the schedule's catch-up policy.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+)
+
+// Catch-up policies, for the runs a schedule missed.
+const (
+	// CatchUpSkip skips them.
+	CatchUpSkip = "skip"
+	// CatchUpOnce fires the latest of them, once. It is the default.
+	CatchUpOnce = "once"
+	// CatchUpAll fires each of them, up to Scheduler.MaxCatchUp.
+	CatchUpAll = "all"
+)
+
+// Tag prefixes the tag marking the messages fired for a schedule, followed
+// by its ID.
+const Tag = "schedule:"
+
+// Validate reports whether s has a valid cron expression, time zone and
+// catch-up policy.
+func Validate(s store.Schedule) error {
+	if _, err := ParseCron(s.Cron); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return fmt.Errorf("time zone %q: %w", s.TimeZone, err)
+	}
+	switch s.CatchUp {
+	case "", CatchUpSkip, CatchUpOnce, CatchUpAll:
+		return nil
+	}
+	return fmt.Errorf("unknown catch-up policy %q: want skip, once or all", s.CatchUp)
+}
+
+// NextRun returns the first time after t that s runs at, or the zero time
+// if s is invalid or never runs again.
+func NextRun(s store.Schedule, t time.Time) time.Time {
+	c, loc, err := parse(s)
+	if err != nil {
+		return time.Time{}
+	}
+	return c.Next(t.In(loc))
+}
+
+func parse(s store.Schedule) (*Cron, *time.Location, error) {
+	c, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, loc, nil
+}
+
+// Scheduler fires the schedules in Store.
+type Scheduler struct {
+	Store store.ScheduleStore
+	// Fire sends the greeting of s for the run at at. A run whose Fire
+	// fails is logged and not retried.
+	Fire func(ctx context.Context, s store.Schedule, at time.Time) error
+	// Grace is how late a run may be fired and still count as on time
+	// rather than missed, a minute if zero.
+	Grace time.Duration
+	// Reload is how often the schedules are read again from Store, to
+	// pick up those added or removed by other processes, a minute if zero.
+	Reload time.Duration
+	// MaxCatchUp is the most missed runs CatchUpAll fires for a schedule,
+	// the latest ones, 100 if zero.
+	MaxCatchUp int
+}
+
+// Run fires the schedules until ctx is done. It returns an error only if
+// the schedules cannot be read at the start.
+func (s *Scheduler) Run(ctx context.Context) error {
+	scheds, err := s.Store.Schedules(ctx)
+	if err != nil {
+		return err
+	}
+	reload := s.Reload
+	if reload <= 0 {
+		reload = time.Minute
+	}
+	lastLoad := time.Now()
+	for {
+		now := time.Now()
+		if now.Sub(lastLoad) >= reload {
+			if fresh, err := s.Store.Schedules(ctx); err != nil {
+				slog.Error("reading schedules failed", "error", err)
+			} else {
+				scheds = fresh
+			}
+			lastLoad = now
+		}
+		wake := lastLoad.Add(reload)
+		for i := range scheds {
+			next := s.runDue(ctx, &scheds[i], now)
+			if !next.IsZero() && next.Before(wake) {
+				wake = next
+			}
+		}
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// runDue fires the runs of sched due by now, as its catch-up policy has
+// it, saves its last run and returns its next one.
+func (s *Scheduler) runDue(ctx context.Context, sched *store.Schedule, now time.Time) time.Time {
+	c, loc, err := parse(*sched)
+	if err != nil {
+		// Validated when added, so only a store edited by hand gets here.
+		slog.Error("invalid schedule", "id", sched.ID, "error", err)
+		return time.Time{}
+	}
+	max := s.MaxCatchUp
+	if max <= 0 {
+		max = 100
+	}
+	from := sched.LastRun
+	if from.IsZero() {
+		from = sched.CreatedAt
+	}
+	var due []time.Time
+	n := 0
+	t := c.Next(from.In(loc))
+	for ; !t.IsZero() && !t.After(now); t = c.Next(t) {
+		n++
+		if due = append(due, t); len(due) > max {
+			due = due[1:]
+		}
+	}
+	if len(due) == 0 {
+		return t
+	}
+	last := due[len(due)-1]
+	grace := s.Grace
+	if grace <= 0 {
+		grace = time.Minute
+	}
+	var fire []time.Time
+	switch sched.CatchUp {
+	case CatchUpSkip:
+		if now.Sub(last) <= grace {
+			fire = due[len(due)-1:]
+		}
+	case CatchUpAll:
+		fire = due
+	default:
+		fire = due[len(due)-1:]
+	}
+	if skipped := n - len(fire); skipped > 0 {
+		slog.Info("missed schedule runs skipped", "id", sched.ID, "runs", skipped)
+	}
+	for _, at := range fire {
+		if err := s.Fire(ctx, *sched, at); err != nil {
+			slog.Error("schedule run failed", "id", sched.ID, "at", at, "error", err)
+		}
+	}
+	sched.LastRun = last
+	if err := s.Store.SaveSchedule(ctx, *sched); err != nil {
+		slog.Error("saving schedule failed", "id", sched.ID, "error", err)
+	}
+	return t
+}
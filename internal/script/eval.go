@@ -0,0 +1,621 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/emoji"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/transform"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Values are strings, ints, bools and []strings.
+
+func typeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int:
+		return "integer"
+	case bool:
+		return "boolean"
+	}
+	return "list"
+}
+
+// aType returns the type of v with an article, such as "an integer".
+func aType(v any) string {
+	if _, ok := v.(int); ok {
+		return "an integer"
+	}
+	return "a " + typeName(v)
+}
+
+// run is the state of a run of a script.
+type run struct {
+	ctx     context.Context
+	name    string
+	maxSize int
+	m       *message.Message
+	locals  []any
+}
+
+func (r *run) errorf(pos lexer.Pos, format string, args ...any) error {
+	return &Error{Name: r.name, Line: pos.Line, Column: pos.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// check returns an error if the run has gone on too long, or built v too
+// big.
+func (r *run) check(pos lexer.Pos, v any) error {
+	if r.ctx.Err() != nil {
+		if errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+			return &Error{Name: r.name, Line: pos.Line, Column: pos.Column, Msg: "run took too long", err: ErrTimeout}
+		}
+		return r.ctx.Err()
+	}
+	switch v := v.(type) {
+	case string:
+		if len(v) > r.maxSize {
+			return r.errorf(pos, "string of %d bytes is over the limit of %d", len(v), r.maxSize)
+		}
+	case []string:
+		if len(v) > r.maxSize {
+			return r.errorf(pos, "list of %d elements is over the limit of %d", len(v), r.maxSize)
+		}
+	}
+	return nil
+}
+
+// errDrop and errReturn stop a run, dropping the message or not.
+var (
+	errDrop   = errors.New("drop")
+	errReturn = errors.New("return")
+)
+
+type stmt interface {
+	exec(r *run) error
+}
+
+func execAll(r *run, body []stmt) error {
+	for _, s := range body {
+		if err := s.exec(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type dropStmt struct{}
+
+func (dropStmt) exec(*run) error { return errDrop }
+
+type returnStmt struct{}
+
+func (returnStmt) exec(*run) error { return errReturn }
+
+type setVar struct {
+	slot int
+	x    expr
+}
+
+func (s *setVar) exec(r *run) error {
+	v, err := s.x.eval(r)
+	if err != nil {
+		return err
+	}
+	r.locals[s.slot] = v
+	return nil
+}
+
+type setField struct {
+	name  string
+	field field
+	x     expr
+	pos   lexer.Pos
+}
+
+func (s *setField) exec(r *run) error {
+	v, err := s.x.eval(r)
+	if err != nil {
+		return err
+	}
+	if err := s.field.set(r.m, v); err != nil {
+		return r.errorf(s.pos, "cannot set %s: %v", s.name, err)
+	}
+	return nil
+}
+
+type ifStmt struct {
+	cond      expr
+	then, els []stmt
+	pos       lexer.Pos
+}
+
+func (s *ifStmt) exec(r *run) error {
+	v, err := s.cond.eval(r)
+	if err != nil {
+		return err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return r.errorf(s.pos, "if needs a boolean, not %s", aType(v))
+	}
+	if b {
+		return execAll(r, s.then)
+	}
+	return execAll(r, s.els)
+}
+
+type expr interface {
+	eval(r *run) (any, error)
+}
+
+type literal struct{ v any }
+
+func (l literal) eval(*run) (any, error) { return l.v, nil }
+
+type getVar int
+
+func (g getVar) eval(r *run) (any, error) { return r.locals[g], nil }
+
+// fieldRef reads a field of the message.
+type fieldRef struct{ field field }
+
+func (f fieldRef) eval(r *run) (any, error) { return f.field.get(r.m), nil }
+
+type field struct {
+	get func(*message.Message) any
+	// set is nil for fields that may only be read.
+	set func(*message.Message, any) error
+}
+
+var fields = map[string]field{
+	"text": {
+		get: func(m *message.Message) any { return m.Text },
+		set: func(m *message.Message, v any) error { return setString(&m.Text, v) },
+	},
+	"sender": {
+		get: func(m *message.Message) any { return m.Sender },
+		set: func(m *message.Message, v any) error { return setString(&m.Sender, v) },
+	},
+	"severity": {
+		get: func(m *message.Message) any { return int(m.Severity) },
+		set: func(m *message.Message, v any) error {
+			n, ok := v.(int)
+			if !ok {
+				return fmt.Errorf("want info, notice, warning or error, not %s", aType(v))
+			}
+			if n < int(message.SeverityInfo) || n > int(message.SeverityError) {
+				return fmt.Errorf("no severity %d", n)
+			}
+			m.Severity = message.Severity(n)
+			return nil
+		},
+	},
+	"tags": {
+		get: func(m *message.Message) any { return m.Tags },
+		set: func(m *message.Message, v any) error {
+			l, ok := v.([]string)
+			if !ok {
+				return fmt.Errorf("want a list, not %s", aType(v))
+			}
+			m.Tags = l
+			return nil
+		},
+	},
+	"id":      {get: func(m *message.Message) any { return m.ID }},
+	"created": {get: func(m *message.Message) any { return int(m.CreatedAt.Unix()) }},
+}
+
+func setString(dst *string, v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("want a string, not %s", aType(v))
+	}
+	*dst = s
+	return nil
+}
+
+// constants are the names that are neither fields nor variables.
+var constants = map[string]any{
+	"true":    true,
+	"false":   false,
+	"info":    int(message.SeverityInfo),
+	"notice":  int(message.SeverityNotice),
+	"warning": int(message.SeverityWarning),
+	"error":   int(message.SeverityError),
+}
+
+// lookupName returns the field or constant called name.
+func lookupName(name string) (expr, bool) {
+	if f, ok := fields[name]; ok {
+		return fieldRef{f}, true
+	}
+	if v, ok := constants[name]; ok {
+		return literal{v}, true
+	}
+	return nil, false
+}
+
+type list struct {
+	elems []expr
+	pos   lexer.Pos
+}
+
+func (l *list) eval(r *run) (any, error) {
+	out := make([]string, 0, len(l.elems))
+	for _, x := range l.elems {
+		v, err := x.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, r.errorf(l.pos, "lists hold strings, not %s", aType(v))
+		}
+		out = append(out, s)
+	}
+	return out, r.check(l.pos, out)
+}
+
+type unary struct {
+	op  string
+	x   expr
+	pos lexer.Pos
+}
+
+func (u *unary) eval(r *run) (any, error) {
+	v, err := u.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case bool:
+		if u.op == "!" {
+			return !v, nil
+		}
+	case int:
+		if u.op == "-" {
+			return -v, nil
+		}
+	}
+	return nil, r.errorf(u.pos, "operator %s is not defined on %s", u.op, aType(v))
+}
+
+type binary struct {
+	op   string
+	x, y expr
+	pos  lexer.Pos
+}
+
+func (b *binary) eval(r *run) (any, error) {
+	x, err := b.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	// && and || only evaluate their right side if they need it.
+	if b.op == "&&" || b.op == "||" {
+		xb, ok := x.(bool)
+		if !ok {
+			return nil, r.errorf(b.pos, "operator %s needs booleans, not %s", b.op, aType(x))
+		}
+		if xb == (b.op == "||") {
+			return xb, nil
+		}
+		y, err := b.y.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		yb, ok := y.(bool)
+		if !ok {
+			return nil, r.errorf(b.pos, "operator %s needs booleans, not %s", b.op, aType(y))
+		}
+		return yb, nil
+	}
+	y, err := b.y.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if typeName(x) != typeName(y) {
+		return nil, r.errorf(b.pos, "mismatched types %s and %s for %s", typeName(x), typeName(y), b.op)
+	}
+	switch b.op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	}
+	var v any
+	switch x := x.(type) {
+	case int:
+		v, err = intOp(b.op, x, y.(int))
+	case string:
+		v, err = stringOp(b.op, x, y.(string))
+	case []string:
+		if b.op == "+" {
+			v = append(slices.Clip(x), y.([]string)...)
+		}
+	}
+	if err != nil {
+		return nil, r.errorf(b.pos, "%v", err)
+	}
+	if v == nil {
+		return nil, r.errorf(b.pos, "operator %s is not defined on %s", b.op, aType(x))
+	}
+	return v, r.check(b.pos, v)
+}
+
+func equal(x, y any) bool {
+	if l, ok := x.([]string); ok {
+		return slices.Equal(l, y.([]string))
+	}
+	return x == y
+}
+
+func intOp(op string, x, y int) (any, error) {
+	switch op {
+	case "<":
+		return x < y, nil
+	case "<=":
+		return x <= y, nil
+	case ">":
+		return x > y, nil
+	case ">=":
+		return x >= y, nil
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+	return nil, nil
+}
+
+func stringOp(op string, x, y string) (any, error) {
+	switch op {
+	case "<":
+		return x < y, nil
+	case "<=":
+		return x <= y, nil
+	case ">":
+		return x > y, nil
+	case ">=":
+		return x >= y, nil
+	case "+":
+		return x + y, nil
+	}
+	return nil, nil
+}
+
+type call struct {
+	name string
+	fn   *builtin
+	args []expr
+	pos  lexer.Pos
+}
+
+func (c *call) eval(r *run) (any, error) {
+	args := make([]any, len(c.args))
+	for i, x := range c.args {
+		v, err := x.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if err := r.check(c.pos, nil); err != nil {
+		return nil, err
+	}
+	v, err := c.fn.call(args)
+	if err != nil {
+		return nil, r.errorf(c.pos, "%s: %v", c.name, err)
+	}
+	return v, r.check(c.pos, v)
+}
+
+// compiledRE is a regexp argument written as a literal, compiled when the
+// script was parsed.
+type compiledRE struct{ re *regexp.Regexp }
+
+func (c compiledRE) eval(*run) (any, error) { return c.re, nil }
+
+// builtin is a function scripts may call, with args arguments, or at
+// least that many if variadic. re, if not -1, is the index of an argument
+// that is a regexp.
+type builtin struct {
+	args     int
+	variadic bool
+	re       int
+	call     func(args []any) (any, error)
+}
+
+func (b *builtin) arity() string {
+	switch {
+	case b.variadic:
+		return fmt.Sprintf("at least %d arguments", b.args)
+	case b.args == 1:
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", b.args)
+}
+
+var builtins map[string]*builtin
+
+func init() {
+	builtins = map[string]*builtin{
+		"len": {args: 1, re: -1, call: func(args []any) (any, error) {
+			switch v := args[0].(type) {
+			case string:
+				return len(v), nil
+			case []string:
+				return len(v), nil
+			}
+			return nil, fmt.Errorf("want a string or list, not %s", aType(args[0]))
+		}},
+		"upper":     stringFunc(strings.ToUpper),
+		"lower":     stringFunc(strings.ToLower),
+		"title":     stringFunc(transform.TitleCase),
+		"trim":      stringFunc(strings.TrimSpace),
+		"emoji":     stringFunc(emoji.Expand),
+		"contains":  predicate(strings.Contains),
+		"hasPrefix": predicate(strings.HasPrefix),
+		"hasSuffix": predicate(strings.HasSuffix),
+		"trunc": {args: 2, re: -1, call: func(args []any) (any, error) {
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := args[1].(int)
+			if !ok {
+				return nil, fmt.Errorf("argument 2: want an integer, not %s", aType(args[1]))
+			}
+			if n < 0 || utf8.RuneCountInString(s) <= n {
+				return s, nil
+			}
+			return string([]rune(s)[:n]), nil
+		}},
+		"replace": {args: 3, re: -1, call: func(args []any) (any, error) {
+			ss, err := stringArgs(args)
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(ss[0], ss[1], ss[2]), nil
+		}},
+		"matches": {args: 2, re: 1, call: func(args []any) (any, error) {
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexpArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return re.MatchString(s), nil
+		}},
+		"replaceRE": {args: 3, re: 1, call: func(args []any) (any, error) {
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexpArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			repl, err := stringArg(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			return re.ReplaceAllString(s, repl), nil
+		}},
+		"redact": {args: 1, variadic: true, re: -1, call: func(args []any) (any, error) {
+			ss, err := stringArgs(args)
+			if err != nil {
+				return nil, err
+			}
+			return transform.Redact(ss[1:]...)(ss[0]), nil
+		}},
+		"has": {args: 2, re: -1, call: func(args []any) (any, error) {
+			l, ok := args[0].([]string)
+			if !ok {
+				return nil, fmt.Errorf("argument 1: want a list, not %s", aType(args[0]))
+			}
+			s, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Contains(l, s), nil
+		}},
+		"without": {args: 1, variadic: true, re: -1, call: func(args []any) (any, error) {
+			l, ok := args[0].([]string)
+			if !ok {
+				return nil, fmt.Errorf("argument 1: want a list, not %s", aType(args[0]))
+			}
+			ss, err := stringArgs(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			out := []string{}
+			for _, s := range l {
+				if !slices.Contains(ss, s) {
+					out = append(out, s)
+				}
+			}
+			return out, nil
+		}},
+		"str": {args: 1, re: -1, call: func(args []any) (any, error) {
+			n, ok := args[0].(int)
+			if !ok {
+				return nil, fmt.Errorf("want an integer, not %s", aType(args[0]))
+			}
+			return strconv.Itoa(n), nil
+		}},
+	}
+}
+
+func stringFunc(f func(string) string) *builtin {
+	return &builtin{args: 1, re: -1, call: func(args []any) (any, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}}
+}
+
+func predicate(f func(s, t string) bool) *builtin {
+	return &builtin{args: 2, re: -1, call: func(args []any) (any, error) {
+		ss, err := stringArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return f(ss[0], ss[1]), nil
+	}}
+}
+
+func stringArg(args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d: want a string, not %s", i+1, aType(args[i]))
+	}
+	return s, nil
+}
+
+func stringArgs(args []any) ([]string, error) {
+	ss := make([]string, len(args))
+	for i := range args {
+		var err error
+		if ss[i], err = stringArg(args, i); err != nil {
+			return nil, err
+		}
+	}
+	return ss, nil
+}
+
+// regexpArg returns the regexp argument i, compiling it unless it was a
+// literal.
+func regexpArg(args []any, i int) (*regexp.Regexp, error) {
+	if re, ok := args[i].(*regexp.Regexp); ok {
+		return re, nil
+	}
+	s, err := stringArg(args, i)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(s)
+}
@@ -0,0 +1,328 @@
+package script
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Parse parses the script src, naming it name in errors.
+func Parse(name, src string) (*Script, error) {
+	p := &parser{name: name, vars: make(map[string]int)}
+	for _, t := range lexer.Tokenize([]byte(src)) {
+		if t.Kind != lexer.Comment {
+			p.toks = append(p.toks, t)
+		}
+	}
+	end := lexer.Pos{Offset: len(src), Line: strings.Count(src, "\n") + 1}
+	end.Column = len(src) - strings.LastIndexByte(src, '\n')
+	p.toks = append(p.toks, lexer.Token{Kind: lexer.EOF, Pos: end})
+	body, err := p.block(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{name: name, body: body, locals: len(p.vars)}, nil
+}
+
+// parser parses the tokens of a script, which always end with EOF.
+type parser struct {
+	name string
+	toks []lexer.Token
+	// prevLine is the line the last token taken ends on, to tell where
+	// statements end.
+	prevLine int
+	// vars numbers the variables declared so far.
+	vars map[string]int
+}
+
+func (p *parser) peek() lexer.Token { return p.toks[0] }
+
+func (p *parser) next() lexer.Token {
+	t := p.toks[0]
+	if t.Kind != lexer.EOF {
+		p.toks = p.toks[1:]
+		p.prevLine = t.Pos.Line + strings.Count(t.Text, "\n")
+	}
+	return t
+}
+
+// is reports whether the next token is the operator or keyword text.
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.Kind == lexer.Operator || t.Kind == lexer.Keyword) && t.Text == text
+}
+
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf(p.peek(), "expected %s, found %s", text, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) errorf(t lexer.Token, format string, args ...any) error {
+	return &Error{Name: p.name, Line: t.Pos.Line, Column: t.Pos.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func describe(t lexer.Token) string {
+	if t.Kind == lexer.EOF {
+		return "end of script"
+	}
+	return strconv.Quote(t.Text)
+}
+
+// block parses statements up to the end of the script, or up to and
+// including a closing brace if braced.
+func (p *parser) block(braced bool) ([]stmt, error) {
+	var body []stmt
+	for {
+		for p.accept(";") {
+		}
+		if braced && p.accept("}") {
+			return body, nil
+		}
+		if p.peek().Kind == lexer.EOF {
+			if braced {
+				return nil, p.errorf(p.peek(), "expected }, found end of script")
+			}
+			return body, nil
+		}
+		s, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, s)
+		// A statement ends with the line, a semicolon or the block.
+		if t := p.peek(); t.Pos.Line == p.prevLine && t.Kind != lexer.EOF && !p.is(";") && !p.is("}") {
+			return nil, p.errorf(t, "expected the end of the statement, found %s", describe(t))
+		}
+	}
+}
+
+func (p *parser) stmt() (stmt, error) {
+	t := p.next()
+	switch {
+	case t.Kind == lexer.Keyword && t.Text == "if":
+		return p.ifStmt(t)
+	case t.Kind == lexer.Keyword && t.Text == "return":
+		return returnStmt{}, nil
+	case t.Kind == lexer.Ident && t.Text == "drop":
+		return dropStmt{}, nil
+	case t.Kind != lexer.Ident:
+		return nil, p.errorf(t, "expected a statement, found %s", describe(t))
+	}
+	switch op := p.next(); {
+	case op.Kind == lexer.Operator && op.Text == ":=":
+		if _, ok := p.vars[t.Text]; ok {
+			return nil, p.errorf(t, "%s is already declared", t.Text)
+		}
+		if _, ok := lookupName(t.Text); ok {
+			return nil, p.errorf(t, "%s is a field or constant, and cannot be declared", t.Text)
+		}
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		// The variable is declared after its value, which cannot use it.
+		p.vars[t.Text] = len(p.vars)
+		return &setVar{slot: p.vars[t.Text], x: x}, nil
+	case op.Kind == lexer.Operator && op.Text == "=":
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if slot, ok := p.vars[t.Text]; ok {
+			return &setVar{slot: slot, x: x}, nil
+		}
+		f, ok := fields[t.Text]
+		switch {
+		case !ok:
+			return nil, p.errorf(t, "undefined: %s", t.Text)
+		case f.set == nil:
+			return nil, p.errorf(t, "cannot set %s", t.Text)
+		}
+		return &setField{name: t.Text, field: f, x: x, pos: t.Pos}, nil
+	default:
+		return nil, p.errorf(op, "expected = or := after %s, found %s", t.Text, describe(op))
+	}
+}
+
+func (p *parser) ifStmt(kw lexer.Token) (stmt, error) {
+	cond, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	s := &ifStmt{cond: cond, pos: kw.Pos}
+	if s.then, err = p.block(true); err != nil {
+		return nil, err
+	}
+	if !p.accept("else") {
+		return s, nil
+	}
+	if p.is("if") {
+		elseIf, err := p.ifStmt(p.next())
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{elseIf}
+		return s, nil
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	s.els, err = p.block(true)
+	return s, err
+}
+
+// binaryOps lists the binary operators, loosest binding first.
+var binaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.binary(0)
+}
+
+func (p *parser) binary(level int) (expr, error) {
+	if level == len(binaryOps) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.Kind != lexer.Operator || !slices.Contains(binaryOps[level], t.Text) {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op: t.Text, x: x, y: y, pos: t.Pos}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if t := p.peek(); t.Kind == lexer.Operator && (t.Text == "!" || t.Text == "-") {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: t.Text, x: x, pos: t.Pos}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.Kind {
+	case lexer.String:
+		s, err := strconv.Unquote(t.Text)
+		if err != nil {
+			return nil, p.errorf(t, "invalid string %s", t.Text)
+		}
+		return literal{s}, nil
+	case lexer.Number:
+		n, err := strconv.ParseInt(t.Text, 0, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid integer %s", t.Text)
+		}
+		return literal{int(n)}, nil
+	case lexer.Ident:
+		if p.is("(") {
+			return p.call(t)
+		}
+		if slot, ok := p.vars[t.Text]; ok {
+			return getVar(slot), nil
+		}
+		if x, ok := lookupName(t.Text); ok {
+			return x, nil
+		}
+		return nil, p.errorf(t, "undefined: %s", t.Text)
+	case lexer.Operator:
+		switch t.Text {
+		case "(":
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			var l list
+			for !p.accept("]") {
+				x, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				l.elems = append(l.elems, x)
+				if !p.is("]") {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			l.pos = t.Pos
+			return &l, nil
+		}
+	}
+	return nil, p.errorf(t, "expected an expression, found %s", describe(t))
+}
+
+func (p *parser) call(name lexer.Token) (expr, error) {
+	p.next()
+	fn, ok := builtins[name.Text]
+	if !ok {
+		return nil, p.errorf(name, "undefined function %s", name.Text)
+	}
+	c := &call{name: name.Text, fn: fn, pos: name.Pos}
+	for !p.accept(")") {
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, x)
+		if !p.is(")") {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if n := len(c.args); n < fn.args || n > fn.args && !fn.variadic {
+		return nil, p.errorf(name, "%s takes %s, not %d", name.Text, fn.arity(), n)
+	}
+	// Regexps written as literals are compiled, and checked, once.
+	if fn.re >= 0 {
+		if lit, ok := c.args[fn.re].(literal); ok {
+			if s, ok := lit.v.(string); ok {
+				re, err := regexp.Compile(s)
+				if err != nil {
+					return nil, p.errorf(name, "%s: %v", name.Text, err)
+				}
+				c.args[fn.re] = compiledRE{re}
+			}
+		}
+	}
+	return c, nil
+}
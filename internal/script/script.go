@@ -0,0 +1,141 @@
+// Package script runs small scripts that change messages before they are
+// delivered, for rules the built-in transforms cannot express. Scripts are
+// written in a little language with Go's syntax:
+//
+//	// Page the ops team about warnings, loudly, and drop test messages.
+//	if severity >= warning && has(tags, "ops") {
+//		text = upper(text)
+//		tags = tags + ["paged"]
+//	} else if contains(lower(text), "test") {
+//		drop
+//	}
+//	sender = "greeter-bot"
+//
+// A script is a sequence of statements, one to a line or separated by
+// semicolons:
+//
+//	field = expr       set a field of the message
+//	name := expr       declare a variable, which = then sets
+//	if expr { ... } else if expr { ... } else { ... }
+//	drop               stop, and deliver nothing
+//	return             stop, and deliver the message as it is
+//
+// Values are strings, integers, booleans and lists of strings, and
+// conversions are never implicit. The message's fields are text, sender and
+// severity, which is one of the integers info, notice, warning and error,
+// and tags, a list, all of which may be set; and id and created, its
+// creation time in Unix seconds, which may only be read.
+//
+// Expressions are literals, such as "hi", `raw`, 42, true and ["a", "b"];
+// fields and variables; calls of the functions below; and Go's operators:
+// || and &&, == and != between values of the same type, < <= > >= between
+// strings or integers, + adding integers or joining strings or lists,
+// - * / % between integers, and unary ! and -. The functions are:
+//
+//	len(s)                   the bytes of a string or elements of a list
+//	upper(s), lower(s), title(s), trim(s), emoji(s)
+//	trunc(s, n)              s cut to n characters
+//	replace(s, old, new)     s with every old replaced by new
+//	replaceRE(s, re, repl)   s with every match of the regexp re replaced
+//	contains(s, sub), hasPrefix(s, prefix), hasSuffix(s, suffix)
+//	matches(s, re)           whether the regexp re matches s
+//	redact(s, words...)      s with the whole words masked
+//	has(list, s)             whether the list holds s
+//	without(list, s...)      the list without s
+//	str(n)                   an integer in decimal
+//
+// Scripts are sandboxed: they can read and change only the message they
+// are run on, have no loops, and are stopped when they run longer or build
+// bigger values than their Limits allow.
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Limits bound a run of a script.
+type Limits struct {
+	// Timeout bounds the time of each run, 100ms if zero.
+	Timeout time.Duration
+	// MaxSize bounds the strings, in bytes, and lists, in elements, a
+	// run may make, 1 MiB if zero.
+	MaxSize int
+}
+
+const (
+	defaultTimeout = 100 * time.Millisecond
+	defaultMaxSize = 1 << 20
+)
+
+// Script is a parsed script. It is safe for concurrent use.
+type Script struct {
+	Limits Limits
+	name   string
+	body   []stmt
+	locals int
+}
+
+// Error is an error in a script, from parsing or running it, at a line
+// and column of its source.
+type Error struct {
+	Name         string
+	Line, Column int
+	Msg          string
+	err          error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Name, e.Line, e.Column, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// ErrTimeout is wrapped by the Error of a run that takes longer than its
+// Limits allow.
+var ErrTimeout = errors.New("script: run took too long")
+
+// Load parses the script in the file name.
+func Load(name string) (*Script, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, string(src))
+}
+
+// Run runs s on m, returning the message it leaves, and false if the
+// script dropped it. The message must be valid when the script is done.
+func (s *Script) Run(ctx context.Context, m message.Message) (message.Message, bool, error) {
+	timeout := s.Limits.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	r := &run{ctx: ctx, name: s.name, maxSize: s.Limits.MaxSize, locals: make([]any, s.locals)}
+	if r.maxSize <= 0 {
+		r.maxSize = defaultMaxSize
+	}
+	// The script gets its own tags, so that the caller's are not changed.
+	m.Tags = append([]string(nil), m.Tags...)
+	r.m = &m
+	switch err := execAll(r, s.body); err {
+	case nil, errReturn:
+	case errDrop:
+		return m, false, nil
+	default:
+		return m, false, err
+	}
+	if err := m.Validate(); err != nil {
+		return m, false, fmt.Errorf("%s: script left an invalid message: %w", s.name, err)
+	}
+	return m, true, nil
+}
@@ -0,0 +1,87 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// sealedPrefix starts the text of a sealed message, followed by the key
+// ID, a colon and the base64 nonce and ciphertext.
+const sealedPrefix = "sealed:v1:"
+
+// ErrCorrupt is returned by Open for sealed text that does not decrypt.
+var ErrCorrupt = errors.New("seal: sealed text is corrupt or was tampered with")
+
+// SealedWith returns the ID of the key m's text is sealed with, or false
+// if it is not sealed.
+func SealedWith(m message.Message) (string, bool) {
+	rest, ok := strings.CutPrefix(m.Text, sealedPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, _ := strings.Cut(rest, ":")
+	return id, true
+}
+
+// Seal returns m with its text encrypted by the primary key. The rest of
+// m is left in the clear, for stores to search by, but the ciphertext is
+// bound to m's ID: it does not decrypt as the text of another message.
+// Text that is already sealed, or only looks it, is sealed again, so that
+// Open gives it back as it was.
+func (k *Keyring) Seal(m message.Message) (message.Message, error) {
+	key := &k.keys[0]
+	aead, err := key.aead()
+	if err != nil {
+		return m, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(m.Text)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return m, err
+	}
+	out := aead.Seal(nonce, nonce, []byte(m.Text), []byte(m.ID))
+	m.Text = sealedPrefix + key.ID + ":" + base64.StdEncoding.EncodeToString(out)
+	return m, nil
+}
+
+// Open returns m with its text decrypted, or m as it is if its text is not
+// sealed.
+func (k *Keyring) Open(m message.Message) (message.Message, error) {
+	rest, ok := strings.CutPrefix(m.Text, sealedPrefix)
+	if !ok {
+		return m, nil
+	}
+	id, data, _ := strings.Cut(rest, ":")
+	key, err := k.key(id)
+	if err != nil {
+		return m, fmt.Errorf("message %s: %w", m.ID, err)
+	}
+	aead, err := key.aead()
+	if err != nil {
+		return m, err
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(b) < aead.NonceSize() {
+		return m, fmt.Errorf("message %s: %w", m.ID, ErrCorrupt)
+	}
+	text, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(m.ID))
+	if err != nil {
+		return m, fmt.Errorf("message %s: %w", m.ID, ErrCorrupt)
+	}
+	m.Text = string(text)
+	return m, nil
+}
+
+func (k *Key) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.subkey("seal"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
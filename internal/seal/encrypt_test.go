@@ -0,0 +1,74 @@
+package seal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+func newKeyring(t *testing.T, ids ...string) *Keyring {
+	t.Helper()
+	var keys []Key
+	for _, id := range ids {
+		k, err := GenerateKey(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	k, err := NewKeyring(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// TestSealRoundTrip checks that every text comes back from Open as it was
+// sealed, text that looks sealed included, which is sealed like any other.
+func TestSealRoundTrip(t *testing.T) {
+	k := newKeyring(t, "k1")
+	other, err := k.Seal(message.Message{ID: "other", Text: "Hello, Ada!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{
+		"Hello, Ada!",
+		"",
+		sealedPrefix,
+		sealedPrefix + "k1:bm90IHJlYWxseQ==",
+		sealedPrefix + "nope:",
+		other.Text,
+	} {
+		m := message.Message{ID: "m1", Text: text}
+		sealed, err := k.Seal(m)
+		if err != nil {
+			t.Fatalf("Seal(%q): %v", text, err)
+		}
+		if id, ok := SealedWith(sealed); !ok || id != "k1" {
+			t.Errorf("Seal(%q) = %q, not sealed with k1", text, sealed.Text)
+		}
+		if text != "" && strings.Contains(strings.TrimPrefix(sealed.Text, sealedPrefix+"k1:"), text) {
+			t.Errorf("Seal(%q) = %q, which has the text in the clear", text, sealed.Text)
+		}
+		opened, err := k.Open(sealed)
+		if err != nil {
+			t.Fatalf("Open(Seal(%q)): %v", text, err)
+		}
+		if opened.Text != text {
+			t.Errorf("Open(Seal(%q)) = %q", text, opened.Text)
+		}
+	}
+}
+
+func TestOpenOtherMessage(t *testing.T) {
+	k := newKeyring(t, "k1")
+	sealed, err := k.Seal(message.Message{ID: "m1", Text: "Hello, Ada!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed.ID = "m2"
+	if _, err := k.Open(sealed); err == nil {
+		t.Error("Open decrypted the text of m1 as that of m2")
+	}
+}
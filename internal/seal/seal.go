@@ -0,0 +1,128 @@
+// Package seal signs and encrypts messages with the keys of a Keyring, for
+// the integrity and confidentiality of greetings sent and stored.
+//
+// Signatures are HMAC-SHA256, carried in a SignatureTag tag, so that they
+// travel with a message wherever its tags do. Encryption is AES-256-GCM
+// of the text; the Go standard library has neither age nor NaCl, and GCM
+// gives the same guarantees. Both name the key they used, so that keys can
+// be rotated: the first key of a keyring signs and encrypts, and the
+// others only verify and decrypt, until they are retired.
+package seal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of a key, in bytes.
+const KeySize = 32
+
+// Key is a named secret key.
+type Key struct {
+	ID     string
+	Secret [KeySize]byte
+}
+
+// Keyring holds keys, the first of which is the primary one.
+type Keyring struct {
+	keys []Key
+}
+
+// ErrUnknownKey is returned for a signature or ciphertext made with a key
+// a keyring does not hold.
+var ErrUnknownKey = errors.New("seal: unknown key")
+
+// NewKeyring returns a keyring of keys, the first the primary one.
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("seal: no keys")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.ID == "" || strings.ContainsAny(k.ID, ": \t") {
+			return nil, fmt.Errorf("seal: invalid key ID %q", k.ID)
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("seal: duplicate key ID %q", k.ID)
+		}
+		seen[k.ID] = true
+	}
+	return &Keyring{keys: keys}, nil
+}
+
+// LoadKeyring reads a keyring from a file with one "id key" pair per line,
+// the key in base64, as GenerateKey writes them. The first key is the
+// primary one. Blank lines and lines starting with # are ignored.
+func LoadKeyring(name string) (*Keyring, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	k, err := ReadKeyring(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return k, nil
+}
+
+// ReadKeyring reads a keyring written as LoadKeyring reads it.
+func ReadKeyring(r io.Reader) (*Keyring, error) {
+	var keys []Key
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want a key ID and a key", n)
+		}
+		secret, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(secret) != KeySize {
+			return nil, fmt.Errorf("line %d: want a %d-byte key in base64", n, KeySize)
+		}
+		k := Key{ID: fields[0]}
+		copy(k.Secret[:], secret)
+		keys = append(keys, k)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewKeyring(keys...)
+}
+
+// GenerateKey returns a random key named id.
+func GenerateKey(id string) (Key, error) {
+	k := Key{ID: id}
+	if _, err := rand.Read(k.Secret[:]); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// String returns k as a line of a keyring file.
+func (k Key) String() string {
+	return k.ID + " " + base64.StdEncoding.EncodeToString(k.Secret[:])
+}
+
+// Primary returns the ID of the key that signs and encrypts.
+func (k *Keyring) Primary() string {
+	return k.keys[0].ID
+}
+
+func (k *Keyring) key(id string) (*Key, error) {
+	for i := range k.keys {
+		if k.keys[i].ID == id {
+			return &k.keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+}
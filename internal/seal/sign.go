@@ -0,0 +1,96 @@
+package seal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// SignatureTag prefixes the tag holding a message's signature, followed
+// by the key ID, a colon and the hex HMAC-SHA256.
+const SignatureTag = "sig:"
+
+var (
+	// ErrUnsigned is returned by Verify for a message without a
+	// signature.
+	ErrUnsigned = errors.New("seal: message is not signed")
+	// ErrBadSignature is returned by Verify for a message whose signature
+	// does not match it.
+	ErrBadSignature = errors.New("seal: signature does not match")
+)
+
+// subkey derives the key for one use of k's secret, so that the same
+// secret is never used by two algorithms.
+func (k *Key) subkey(use string) []byte {
+	mac := hmac.New(sha256.New, k.Secret[:])
+	mac.Write([]byte("greeter " + use))
+	return mac.Sum(nil)
+}
+
+// Sign returns m with its signature by the primary key, replacing any
+// signature it had. The signature covers every field of m and the tags
+// but itself, so m must not change after it is signed.
+func (k *Keyring) Sign(m message.Message) message.Message {
+	m.Tags = unsigned(m.Tags)
+	key := &k.keys[0]
+	m.Tags = append(m.Tags, SignatureTag+key.ID+":"+hex.EncodeToString(signature(key, m)))
+	return m
+}
+
+// Verify reports whether m carries a valid signature by a key of k.
+func (k *Keyring) Verify(m message.Message) error {
+	var sig string
+	for _, tag := range m.Tags {
+		if s, ok := strings.CutPrefix(tag, SignatureTag); ok {
+			sig = s
+		}
+	}
+	if sig == "" {
+		return ErrUnsigned
+	}
+	id, sum, ok := strings.Cut(sig, ":")
+	got, err := hex.DecodeString(sum)
+	if !ok || err != nil {
+		return fmt.Errorf("%w: malformed signature tag", ErrBadSignature)
+	}
+	key, err := k.key(id)
+	if err != nil {
+		return err
+	}
+	m.Tags = unsigned(m.Tags)
+	if !hmac.Equal(got, signature(key, m)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// signature returns the HMAC of m, which has no signature tag.
+func signature(key *Key, m message.Message) []byte {
+	// JSON of the fields in a fixed order, with the time in UTC, where
+	// stores may give it in another location.
+	tags := m.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	data, _ := json.Marshal([]any{m.ID, m.CreatedAt.UTC().UnixNano(), m.Sender, int(m.Severity), tags, m.Text})
+	mac := hmac.New(sha256.New, key.subkey("sign"))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// unsigned returns tags without signature tags, copied if there were any.
+func unsigned(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, SignatureTag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/normalize"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+)
+
+var errForbidden = errors.New("forbidden")
+
+// protect wraps an API handler so that, when the server has an
+// authenticator, requests must authenticate and, for a non-empty action,
+// be allowed it by the policy, and so that requests are for the tenant
+// resolveTenant finds. Handlers whose action depends on the
+// request check it themselves with allowed. Authentication comes before
+// the rate limits so that clients are limited by identity.
+func (s *Server) protect(action string, h http.HandlerFunc) http.HandlerFunc {
+	h = s.limit(h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Auth != nil {
+			id, err := s.Auth.Authenticate(r)
+			if err != nil {
+				if errors.Is(err, auth.ErrNoCredentials) {
+					err = errors.New("authentication required")
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="greeter"`)
+				writeError(w, r, http.StatusUnauthorized, err)
+				return
+			}
+			r = r.WithContext(auth.ContextWithIdentity(r.Context(), id))
+			if action != "" && !s.allowed(r.Context(), action, "") {
+				writeError(w, r, http.StatusForbidden, errForbidden)
+				return
+			}
+		}
+		t, status, err := s.resolveTenant(r)
+		if err != nil {
+			writeError(w, r, status, err)
+			return
+		}
+		if t != nil {
+			r = r.WithContext(tenant.ContextWithTenant(r.Context(), t))
+		}
+		h(w, r)
+	}
+}
+
+// greetName returns name normalized, as the greeters will greet it. Names
+// are authorized in this form, and greeted in it, so that a spelling that
+// differs only in invisible characters, spacing or combining marks cannot
+// get past a policy that denies the name.
+func greetName(name string) string {
+	return normalize.Default.Normalize(name)
+}
+
+// allowed reports whether the caller whose identity ctx holds may perform
+// action, on name for greetings, which greetName should have normalized.
+func (s *Server) allowed(ctx context.Context, action, name string) bool {
+	return s.Auth == nil || s.Policy.Allow(auth.IdentityFromContext(ctx), action, name)
+}
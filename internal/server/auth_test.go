@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+)
+
+// newAuthServer returns a server authenticating by the "key subject" lines
+// of keys and, unless policy is empty, authorizing by its rules.
+func newAuthServer(t *testing.T, keys, policy string) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	k, err := auth.LoadAPIKeys(writeFile(t, dir, "keys", keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(nil)
+	s.Auth = k
+	if policy != "" {
+		if s.Policy, err = auth.LoadPolicy(writeFile(t, dir, "policy", policy)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// TestGreetAuthorizesNormalizedName checks that names are authorized as
+// they are greeted, so that spellings normalizing to the same name are
+// treated alike by /greet and /graphql.
+func TestGreetAuthorizesNormalizedName(t *testing.T) {
+	s := newAuthServer(t, "secret alice\n", "alice greet Ada\n")
+	tests := []struct {
+		name     string
+		allowed  bool
+		greeting string
+	}{
+		{"Ada", true, "Hello, Ada!"},
+		{"A\u200bda", true, "Hello, Ada!"},
+		{"  Ada ", true, "Hello, Ada!"},
+		{"Grace", false, ""},
+		{"Ada Lovelace", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run("rest/"+tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/greet?name="+url.QueryEscape(tt.name), nil)
+			r.Header.Set("X-API-Key", "secret")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if !tt.allowed {
+				if w.Code != http.StatusForbidden {
+					t.Errorf("status %d, want 403", w.Code)
+				}
+				return
+			}
+			var m struct{ Text string }
+			if err := json.NewDecoder(w.Body).Decode(&m); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status %d, %v", w.Code, err)
+			}
+			if m.Text != tt.greeting {
+				t.Errorf("greeted %q, want %q", m.Text, tt.greeting)
+			}
+		})
+		t.Run("graphql/"+tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]any{
+				"query":     "query($name: String!) { greet(name: $name) { text } }",
+				"variables": map[string]any{"name": tt.name},
+			})
+			r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("X-API-Key", "secret")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			var resp struct {
+				Data struct {
+					Greet *struct{ Text string }
+				}
+				Errors []struct{ Message string }
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.allowed {
+				if len(resp.Errors) == 0 || resp.Data.Greet != nil {
+					t.Errorf("got %+v, want an error", resp)
+				}
+				return
+			}
+			if resp.Data.Greet == nil || resp.Data.Greet.Text != tt.greeting {
+				t.Errorf("got %+v, want %q", resp, tt.greeting)
+			}
+		})
+	}
+}
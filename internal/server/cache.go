@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/cache"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+const (
+	defaultCacheTTL          = 5 * time.Minute
+	defaultGreetingCacheSize = 1000
+)
+
+type cachedGreeting struct {
+	Text string   `json:"text"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// greetingKey identifies a greeting in the server's LRU: the tenant, whose
+// templates and translations it may be rendered with, the greeter's style,
+// which picks its template, the locale and the name.
+type greetingKey struct {
+	tenant, style, locale, name string
+}
+
+// cachedGreet greets name with g through the server's caches: its LRU of
+// recent greetings, then Cache. Greetings from the timed greeter depend on
+// the time of day, and those of the variants greeter vary by design, so
+// they are never cached.
+func (s *Server) cachedGreet(ctx context.Context, style string, g greeting.Greeter, name string) (message.Message, error) {
+	switch g.(type) {
+	case *greeting.TimedGreeter, *greeting.VariantGreeter:
+		return g.Greet(ctx, name)
+	}
+	recent := s.recentGreetings()
+	if recent == nil {
+		return s.sharedGreet(ctx, style, g, name)
+	}
+	key := greetingKey{tenant.NameFromContext(ctx), style, greeting.LocalizerFor(ctx).Locale(), name}
+	if c, ok := recent.Get(key); ok {
+		s.recentRequests.Inc(string(cache.Hit))
+		return message.NewMessage(c.Text, slices.Clone(c.Tags)...), nil
+	}
+	s.recentRequests.Inc(string(cache.Miss))
+	m, err := s.sharedGreet(ctx, style, g, name)
+	if err != nil {
+		return m, err
+	}
+	recent.Add(key, cachedGreeting{m.Text, slices.Clone(m.Tags)})
+	return m, nil
+}
+
+// recentGreetings returns the server's LRU of recent greetings, or nil if
+// GreetingCacheSize disables it.
+func (s *Server) recentGreetings() *cache.LRU[greetingKey, cachedGreeting] {
+	s.recentOnce.Do(func() {
+		size := s.GreetingCacheSize
+		if size == 0 {
+			size = defaultGreetingCacheSize
+		}
+		if size > 0 {
+			s.recent = cache.NewLRU[greetingKey, cachedGreeting](size)
+		}
+	})
+	return s.recent
+}
+
+// InvalidateGreetings empties the server's LRU of recent greetings, so
+// that greetings are rendered afresh, as they must be once the templates
+// or translations they were rendered with are reloaded. Cache is keyed by
+// a hash of the translations and needs no invalidating.
+func (s *Server) InvalidateGreetings() {
+	if recent := s.recentGreetings(); recent != nil {
+		recent.Purge()
+	}
+}
+
+// sharedGreet greets name with g through Cache, if it is set.
+func (s *Server) sharedGreet(ctx context.Context, style string, g greeting.Greeter, name string) (message.Message, error) {
+	if s.Cache == nil {
+		return g.Greet(ctx, name)
+	}
+	s.loaderOnce.Do(func() {
+		ttl := s.CacheTTL
+		if ttl <= 0 {
+			ttl = defaultCacheTTL
+		}
+		s.loader = &cache.Loader{Cache: s.Cache, TTL: ttl}
+	})
+	l := greeting.LocalizerFor(ctx)
+	sum := sha256.Sum256([]byte(tenant.NameFromContext(ctx) + "\x00" + style + "\x00" + l.Locale() + "\x00" + l.TemplateHash() + "\x00" + name))
+	key := "greeting:" + hex.EncodeToString(sum[:])
+	var greeted *message.Message
+	data, result, err := s.loader.Load(ctx, key, func(ctx context.Context) ([]byte, error) {
+		m, err := g.Greet(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		greeted = &m
+		return json.Marshal(cachedGreeting{m.Text, m.Tags})
+	})
+	s.cacheRequests.Inc(string(result))
+	if err != nil {
+		return message.Message{}, err
+	}
+	if greeted != nil {
+		return *greeted, nil
+	}
+	var c cachedGreeting
+	if err := json.Unmarshal(data, &c); err != nil {
+		return g.Greet(ctx, name)
+	}
+	return message.NewMessage(c.Text, c.Tags...), nil
+}
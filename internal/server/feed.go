@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// subscriberBuffer is how many messages a slow subscriber may fall behind
+// before messages are dropped for it.
+const subscriberBuffer = 64
+
+// messageFilter selects messages by tenant, greeting language, minimum
+// severity and tag. The zero value matches every message of no tenant.
+type messageFilter struct {
+	tenant      string
+	lang        string
+	minSeverity message.Severity
+	tag         string
+}
+
+func (f messageFilter) matches(m message.Message) bool {
+	if tenant.Of(m) != f.tenant || m.Severity < f.minSeverity || f.tag != "" && !m.HasTag(f.tag) {
+		return false
+	}
+	if f.lang == "" {
+		return true
+	}
+	locale, _ := greeting.MessageLocale(m)
+	return locale == f.lang || strings.HasPrefix(locale, f.lang+"-")
+}
+
+// subscriber receives the messages matching its filter.
+type subscriber struct {
+	messageFilter
+	messages chan message.Message
+}
+
+// history returns up to limit of the newest messages in History that f,
+// for the tenant of ctx, matches, oldest first, or all of them if limit is
+// negative.
+func (s *Server) history(ctx context.Context, f messageFilter, limit int) []message.Message {
+	f.tenant = tenant.NameFromContext(ctx)
+	ms := s.History.Filter(f.matches)
+	if limit >= 0 && limit < len(ms) {
+		ms = ms[len(ms)-limit:]
+	}
+	return ms
+}
+
+func (s *Server) subscribe(sub *subscriber) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	s.subs[sub] = struct{}{}
+	s.subscribers.Inc()
+}
+
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	delete(s.subs, sub)
+	s.subscribers.Dec()
+}
+
+func (s *Server) publish(m message.Message) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	for sub := range s.subs {
+		if !sub.matches(m) {
+			continue
+		}
+		select {
+		case sub.messages <- m:
+		default:
+			slog.Warn("subscriber too slow, dropping message", "id", m.ID)
+		}
+	}
+}
+
+// Close ends the WebSocket and subscription streams. http.Server.Shutdown does not
+// track hijacked connections, so register it with RegisterOnShutdown.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
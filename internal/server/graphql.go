@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/graphql"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+var (
+	severityType = &graphql.Type{
+		Kind:       graphql.KindEnum,
+		Name:       "Severity",
+		EnumValues: []string{"INFO", "NOTICE", "WARNING", "ERROR"},
+	}
+	messageType = &graphql.Type{
+		Kind: graphql.KindObject,
+		Name: "Message",
+		Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull(graphql.ID)},
+			{Name: "createdAt", Description: "RFC 3339 timestamp.", Type: graphql.NonNull(graphql.String)},
+			{Name: "sender", Type: graphql.NonNull(graphql.String)},
+			{Name: "severity", Type: graphql.NonNull(severityType)},
+			{Name: "tags", Type: graphql.NonNull(graphql.ListOf(graphql.NonNull(graphql.String)))},
+			{Name: "text", Type: graphql.NonNull(graphql.String)},
+			{Name: "locale", Description: "Locale of a greeting.", Type: graphql.String},
+		},
+	}
+	messageFilterType = &graphql.Type{
+		Kind: graphql.KindInputObject,
+		Name: "MessageFilter",
+		InputFields: []*graphql.InputValue{
+			{Name: "lang", Description: "Only greetings in this language.", Type: graphql.String},
+			{Name: "severity", Description: "Only messages at least this severe.", Type: severityType},
+			{Name: "tag", Description: "Only messages with this tag.", Type: graphql.String},
+			{Name: "limit", Description: "Only the most recent messages.", Type: graphql.Int},
+		},
+	}
+	filterArg = []*graphql.InputValue{{Name: "filter", Type: messageFilterType}}
+
+	schema = &graphql.Schema{
+		Query: &graphql.Type{
+			Kind: graphql.KindObject,
+			Name: "Query",
+			Fields: []*graphql.Field{
+				{
+					Name:        "greet",
+					Description: "Greets name and records the greeting like GET /greet.",
+					Args: []*graphql.InputValue{
+						{Name: "name", Type: graphql.NonNull(graphql.String)},
+						{Name: "lang", Type: graphql.String},
+						{Name: "style", Type: graphql.String},
+					},
+					Type: graphql.NonNull(messageType),
+				},
+				{
+					Name:        "messages",
+					Description: "Recorded messages, oldest first.",
+					Args:        filterArg,
+					Type:        graphql.NonNull(graphql.ListOf(graphql.NonNull(messageType))),
+				},
+			},
+		},
+		Subscription: &graphql.Type{
+			Kind: graphql.KindObject,
+			Name: "Subscription",
+			Fields: []*graphql.Field{
+				{Name: "messageAdded", Args: filterArg, Type: graphql.NonNull(messageType)},
+			},
+		},
+		Types: []*graphql.Type{messageType, severityType, messageFilterType},
+	}
+)
+
+func (s *Server) queryRoot() *graphql.Object {
+	return &graphql.Object{Type: "Query", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "greet":
+			name := greetName(args["name"].(string))
+			if !s.allowed(ctx, auth.ActionGreet, name) {
+				return nil, errForbidden
+			}
+			style, _ := args["style"].(string)
+			lang, _ := args["lang"].(string)
+			ctx, style, g, err := s.greeter(ctx, style, lang)
+			if err != nil {
+				return nil, err
+			}
+			m, err := s.greet(ctx, style, g, name)
+			if err != nil {
+				return nil, err
+			}
+			return messageObject(m), nil
+		case "messages":
+			if !s.allowed(ctx, auth.ActionRead, "") {
+				return nil, errForbidden
+			}
+			f, limit, err := parseFilter(args["filter"])
+			if err != nil {
+				return nil, err
+			}
+			ms := s.history(ctx, f, limit)
+			list := make([]*graphql.Object, len(ms))
+			for i, m := range ms {
+				list[i] = messageObject(m)
+			}
+			return list, nil
+		}
+		return nil, nil
+	}}
+}
+
+// subscriptionRoot resolves a subscription for the event m, skipping it
+// if m does not match the filter.
+func subscriptionRoot(m message.Message) *graphql.Object {
+	return &graphql.Object{Type: "Subscription", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		f, _, err := parseFilter(args["filter"])
+		if err != nil {
+			return nil, err
+		}
+		f.tenant = tenant.NameFromContext(ctx)
+		if !f.matches(m) {
+			return nil, graphql.ErrSkip
+		}
+		return messageObject(m), nil
+	}}
+}
+
+func messageObject(m message.Message) *graphql.Object {
+	return &graphql.Object{Type: "Message", Resolve: func(ctx context.Context, field string, args map[string]any) (any, error) {
+		switch field {
+		case "id":
+			return m.ID, nil
+		case "createdAt":
+			return m.CreatedAt.Format(time.RFC3339Nano), nil
+		case "sender":
+			return m.Sender, nil
+		case "severity":
+			return strings.ToUpper(m.Severity.String()), nil
+		case "tags":
+			if m.Tags == nil {
+				return []string{}, nil
+			}
+			return m.Tags, nil
+		case "text":
+			return m.Text, nil
+		case "locale":
+			if locale, ok := greeting.MessageLocale(m); ok {
+				return locale, nil
+			}
+		}
+		return nil, nil
+	}}
+}
+
+// parseFilter converts a MessageFilter argument. limit is -1 if unset.
+func parseFilter(v any) (f messageFilter, limit int, err error) {
+	args, _ := v.(map[string]any)
+	f.lang, _ = args["lang"].(string)
+	f.lang = strings.ToLower(f.lang)
+	f.tag, _ = args["tag"].(string)
+	if severity, ok := args["severity"].(string); ok {
+		if err := f.minSeverity.UnmarshalText([]byte(strings.ToLower(severity))); err != nil {
+			return f, 0, err
+		}
+	}
+	limit = -1
+	if n, ok := args["limit"].(int); ok {
+		if n < 0 {
+			return f, 0, errors.New("limit must not be negative")
+		}
+		limit = n
+	}
+	return f, limit, nil
+}
+
+// handleGraphQL executes queries from GET query parameters or POST bodies.
+// Subscriptions need Accept: text/event-stream and are answered with a
+// server-sent event per matching message. With GraphQLPlayground set, a
+// browser GET without a query gets the GraphiQL page.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if req.Query == "" && s.GraphQLPlayground && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, playgroundHTML)
+			return
+		}
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQL(w, http.StatusBadRequest, graphql.ErrorResponse(fmt.Errorf("variables: %w", err)))
+				return
+			}
+		}
+	case http.MethodPost:
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err := dec.Decode(&req); err != nil {
+			writeGraphQL(w, http.StatusBadRequest, graphql.ErrorResponse(err))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQL(w, http.StatusMethodNotAllowed, graphql.ErrorResponse(errors.New("method not allowed")))
+		return
+	}
+	doc, err := graphql.Parse(req.Query)
+	if err != nil {
+		writeGraphQL(w, http.StatusBadRequest, graphql.ErrorResponse(err))
+		return
+	}
+	op, err := doc.Operation(req.OperationName)
+	if err != nil {
+		writeGraphQL(w, http.StatusBadRequest, graphql.ErrorResponse(err))
+		return
+	}
+	switch op.Type {
+	case "query":
+		writeGraphQL(w, http.StatusOK, schema.Execute(r.Context(), op, s.queryRoot(), req.Variables))
+	case "subscription":
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			writeGraphQL(w, http.StatusNotAcceptable, graphql.ErrorResponse(errors.New("subscriptions need Accept: text/event-stream")))
+			return
+		}
+		if !s.allowed(r.Context(), auth.ActionRead, "") {
+			writeGraphQL(w, http.StatusForbidden, graphql.ErrorResponse(errForbidden))
+			return
+		}
+		s.streamGraphQL(w, r, op, req.Variables)
+	default:
+		writeGraphQL(w, http.StatusBadRequest, graphql.ErrorResponse(fmt.Errorf("%s operations are not supported", op.Type)))
+	}
+}
+
+func (s *Server) streamGraphQL(w http.ResponseWriter, r *http.Request, op *graphql.Operation, variables map[string]any) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeGraphQL(w, http.StatusInternalServerError, graphql.ErrorResponse(errors.New("streaming is not supported")))
+		return
+	}
+	sub := &subscriber{messages: make(chan message.Message, subscriberBuffer)}
+	sub.tenant = tenant.NameFromContext(r.Context())
+	s.subscribe(sub)
+	defer s.unsubscribe(sub)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case m := <-sub.messages:
+			resp := schema.Execute(r.Context(), op, subscriptionRoot(m), variables)
+			if resp == nil {
+				continue
+			}
+			b, _ := json.Marshal(resp)
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", b)
+			flusher.Flush()
+			if len(resp.Errors) > 0 {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			io.WriteString(w, "event: complete\ndata:\n\n")
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+func writeGraphQL(w http.ResponseWriter, status int, resp *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+const playgroundHTML = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>greeter GraphQL</title>
+<link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+</head>
+<body style="margin:0">
+<div id="graphiql" style="height:100vh"></div>
+<script src="https://unpkg.com/react@18/umd/react.production.min.js" crossorigin></script>
+<script src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js" crossorigin></script>
+<script src="https://unpkg.com/graphiql@3/graphiql.min.js" crossorigin></script>
+<script>
+ReactDOM.createRoot(document.getElementById('graphiql')).render(
+  React.createElement(GraphiQL, {fetcher: GraphiQL.createFetcher({url: location.pathname})}));
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	greeterv1 "github.com/fanda-blazek/syntax-highlighting-test/api/greeter/v1"
+	messagev1 "github.com/fanda-blazek/syntax-highlighting-test/api/message/v1"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// maxBatchNames bounds the names of one GreetBatch or GreetStream call.
+const maxBatchNames = 1000
+
+// grpcService is the GreeterService, greeting as GET /greet does. The
+// calls are authenticated, limited and resolved to a tenant by protect
+// like any other request, and each name is authorized on its own.
+type grpcService struct {
+	s *Server
+}
+
+func (g grpcService) Greet(ctx context.Context, req *greeterv1.GreetRequest) (*greeterv1.GreetResponse, error) {
+	ctx, style, gr, err := g.s.greeter(ctx, req.Style, req.Lang)
+	if err != nil {
+		return nil, &greeterv1.Status{Code: greeterv1.InvalidArgument, Message: err.Error()}
+	}
+	m, err := g.greet(ctx, style, gr, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &greeterv1.GreetResponse{Message: messagev1.FromMessage(m)}, nil
+}
+
+// GreetBatch reports the names that are invalid or that the caller may not
+// greet in their items, and fails the call on any other error.
+func (g grpcService) GreetBatch(ctx context.Context, req *greeterv1.GreetBatchRequest) (*greeterv1.GreetBatchResponse, error) {
+	ctx, style, gr, err := g.batch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &greeterv1.GreetBatchResponse{Items: make([]*greeterv1.GreetBatchItem, len(req.Names))}
+	for i, name := range req.Names {
+		m, err := g.greet(ctx, style, gr, name)
+		var st *greeterv1.Status
+		switch {
+		case err == nil:
+			resp.Items[i] = &greeterv1.GreetBatchItem{Message: messagev1.FromMessage(m)}
+		case errors.As(err, &st) && (st.Code == greeterv1.InvalidArgument || st.Code == greeterv1.PermissionDenied):
+			resp.Items[i] = &greeterv1.GreetBatchItem{Error: st.Message}
+		default:
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// GreetStream ends the call at the first name that fails.
+func (g grpcService) GreetStream(ctx context.Context, req *greeterv1.GreetBatchRequest, send func(*greeterv1.GreetResponse) error) error {
+	ctx, style, gr, err := g.batch(ctx, req)
+	if err != nil {
+		return err
+	}
+	for _, name := range req.Names {
+		m, err := g.greet(ctx, style, gr, name)
+		if err != nil {
+			return err
+		}
+		if err := send(&greeterv1.GreetResponse{Message: messagev1.FromMessage(m)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batch checks req and returns the greeter its names are greeted with, as
+// Server.greeter does.
+func (g grpcService) batch(ctx context.Context, req *greeterv1.GreetBatchRequest) (context.Context, string, greeting.Greeter, error) {
+	if len(req.Names) > maxBatchNames {
+		return nil, "", nil, &greeterv1.Status{Code: greeterv1.InvalidArgument, Message: fmt.Sprintf("at most %d names may be greeted at once", maxBatchNames)}
+	}
+	ctx, style, gr, err := g.s.greeter(ctx, req.Style, req.Lang)
+	if err != nil {
+		return nil, "", nil, &greeterv1.Status{Code: greeterv1.InvalidArgument, Message: err.Error()}
+	}
+	return ctx, style, gr, nil
+}
+
+// greet greets name as handleGreet does, failing with the status that
+// matches the HTTP one handleGreet would answer with.
+func (g grpcService) greet(ctx context.Context, style string, gr greeting.Greeter, name string) (message.Message, error) {
+	name = greetName(name)
+	if !g.s.allowed(ctx, auth.ActionGreet, name) {
+		return message.Message{}, grpcStatus(http.StatusForbidden, errForbidden)
+	}
+	m, err := g.s.greet(ctx, style, gr, name)
+	if err != nil {
+		return m, grpcStatus(statusFor(err), err)
+	}
+	return m, nil
+}
+
+// grpcStatus returns the gRPC status of a call refused with the HTTP
+// status, for err.
+func grpcStatus(status int, err error) *greeterv1.Status {
+	code := greeterv1.Unknown
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = greeterv1.InvalidArgument
+	case http.StatusUnauthorized:
+		code = greeterv1.Unauthenticated
+	case http.StatusForbidden:
+		code = greeterv1.PermissionDenied
+	case http.StatusNotFound:
+		code = greeterv1.NotFound
+	case http.StatusTooManyRequests:
+		code = greeterv1.ResourceExhausted
+	case http.StatusInternalServerError:
+		code = greeterv1.Internal
+	case http.StatusServiceUnavailable:
+		code = greeterv1.Unavailable
+	}
+	return &greeterv1.Status{Code: code, Message: err.Error()}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	greeterv1 "github.com/fanda-blazek/syntax-highlighting-test/api/greeter/v1"
+)
+
+// newGRPCClient serves s over HTTP/2 with TLS and returns a client of it.
+func newGRPCClient(t *testing.T, s *Server) *greeterv1.GreeterServiceClient {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(s)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return &greeterv1.GreeterServiceClient{URL: ts.URL, HTTPClient: ts.Client()}
+}
+
+// writeFile writes data to the file name in dir, returning its path.
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	name = filepath.Join(dir, name)
+	if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestGRPCGreet(t *testing.T) {
+	c := newGRPCClient(t, New(nil))
+	resp, err := c.Greet(context.Background(), &greeterv1.GreetRequest{Name: "Ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message == nil || !strings.Contains(resp.Message.Text, "Ada") {
+		t.Errorf("Greet = %+v, want a greeting of Ada", resp.Message)
+	}
+}
+
+func TestGRPCGreetBatch(t *testing.T) {
+	c := newGRPCClient(t, New(nil))
+	resp, err := c.GreetBatch(context.Background(), &greeterv1.GreetBatchRequest{Names: []string{"Ada", "", "Grace"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(resp.Items))
+	}
+	for i, want := range []string{"Ada", "", "Grace"} {
+		item := resp.Items[i]
+		if want == "" {
+			if item.Message != nil || item.Error == "" {
+				t.Errorf("item %d = %+v, want an error for the empty name", i, item)
+			}
+			continue
+		}
+		if item.Message == nil || !strings.Contains(item.Message.Text, want) {
+			t.Errorf("item %d = %+v, want a greeting of %s", i, item, want)
+		}
+	}
+}
+
+func TestGRPCGreetStream(t *testing.T) {
+	c := newGRPCClient(t, New(nil))
+	stream, err := c.GreetStream(context.Background(), &greeterv1.GreetBatchRequest{Names: []string{"Ada", "Grace"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var texts []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, resp.Message.Text)
+	}
+	if len(texts) != 2 || !strings.Contains(texts[0], "Ada") || !strings.Contains(texts[1], "Grace") {
+		t.Errorf("streamed %q, want greetings of Ada then Grace", texts)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	c := newGRPCClient(t, newAuthServer(t, "secret alice\n", "alice greet Ada\n"))
+	tests := []struct {
+		name   string
+		header http.Header
+		req    *greeterv1.GreetRequest
+		code   greeterv1.Code
+	}{
+		{"no credentials", nil, &greeterv1.GreetRequest{Name: "Ada"}, greeterv1.Unauthenticated},
+		{"not allowed", http.Header{"X-Api-Key": {"secret"}}, &greeterv1.GreetRequest{Name: "Grace"}, greeterv1.PermissionDenied},
+		{"unknown style", http.Header{"X-Api-Key": {"secret"}}, &greeterv1.GreetRequest{Name: "Ada", Style: "nope"}, greeterv1.InvalidArgument},
+		{"allowed", http.Header{"X-Api-Key": {"secret"}}, &greeterv1.GreetRequest{Name: "Ada"}, greeterv1.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Header = tt.header
+			_, err := c.Greet(context.Background(), tt.req)
+			code := greeterv1.OK
+			var st *greeterv1.Status
+			if errors.As(err, &st) {
+				code = st.Code
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("Greet = %v, want code %v", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+)
+
+const checkTimeout = 2 * time.Second
+
+type check struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// AddCheck adds a readiness check reported by /readyz. The server is ready
+// while every check returns nil. The history store and the translation
+// catalog are always checked.
+func (s *Server) AddCheck(name string, fn func(context.Context) error) {
+	s.checksMu.Lock()
+	defer s.checksMu.Unlock()
+	s.checks = append(s.checks, check{name, fn})
+}
+
+func (s *Server) checkHistory(ctx context.Context) error {
+	if s.History == nil {
+		return errors.New("no history store")
+	}
+	return nil
+}
+
+func checkTranslations(ctx context.Context) error {
+	if len(greeting.Locales()) == 0 {
+		return errors.New("no translations loaded")
+	}
+	return nil
+}
+
+// handleHealthz reports that the process is up and serving.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// handleReadyz runs the readiness checks, answering 503 if any fails or
+// the server is shutting down. The body maps each check to "ok" or its
+// error.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+	s.checksMu.Lock()
+	checks := append([]check(nil), s.checks...)
+	s.checksMu.Unlock()
+	status := http.StatusOK
+	results := make(map[string]string, len(checks)+1)
+	select {
+	case <-s.done:
+		status = http.StatusServiceUnavailable
+		results["server"] = "shutting down"
+	default:
+	}
+	for _, c := range checks {
+		results[c.name] = "ok"
+		if err := c.fn(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			results[c.name] = err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleVersion reports the server version and the build information
+// embedded in the binary.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	v := map[string]string{
+		"version": s.Version,
+		"go":      runtime.Version(),
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v["module"] = info.Main.Path
+		if v["version"] == "" {
+			v["version"] = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				v[setting.Key] = setting.Value
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
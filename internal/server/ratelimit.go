@@ -0,0 +1,185 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+)
+
+// Limit is a token-bucket rate: Rate requests per second on average, in
+// bursts of up to Burst. The zero Limit allows everything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit written as N/s, N/m or N/h, optionally followed
+// by :B for a burst of B requests. The burst defaults to N. An empty string
+// or "off" is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "off" {
+		return Limit{}, nil
+	}
+	spec, burst, hasBurst := strings.Cut(s, ":")
+	count, unit, _ := strings.Cut(spec, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want N/s, N/m or N/h", s)
+	}
+	l := Limit{Burst: int(math.Ceil(n))}
+	switch unit {
+	case "", "s":
+		l.Rate = n
+	case "m":
+		l.Rate = n / 60
+	case "h":
+		l.Rate = n / 3600
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unknown unit %q", s, unit)
+	}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", s)
+		}
+	}
+	return l, nil
+}
+
+func (l Limit) String() string {
+	if l.Rate <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(l.Rate, 'g', -1, 64) + "/s:" + strconv.Itoa(l.Burst)
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	v, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(l Limit, now time.Time) *bucket {
+	return &bucket{tokens: float64(l.Burst), last: now}
+}
+
+// take removes a token from b, or returns how long until one is available.
+func (b *bucket) take(l Limit, now time.Time) time.Duration {
+	b.tokens = min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// idle reports whether b would be full again by now, so forgetting it
+// changes nothing.
+func (b *bucket) idle(l Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*l.Rate >= float64(l.Burst)
+}
+
+const sweepInterval = time.Minute
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	global    *bucket
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+// allow takes a token for r from its client's bucket and then the global
+// one. If either is empty it returns the wait and which limit applied.
+func (s *Server) allow(r *http.Request) (wait time.Duration, scope string) {
+	global, client := s.RateLimit, s.ClientRateLimit
+	if global.Rate <= 0 && client.Rate <= 0 {
+		return 0, ""
+	}
+	now := time.Now()
+	rl := &s.limiter
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	var cb *bucket
+	if client.Rate > 0 {
+		if now.Sub(rl.lastSweep) >= sweepInterval {
+			for key, b := range rl.clients {
+				if b.idle(client, now) {
+					delete(rl.clients, key)
+				}
+			}
+			rl.lastSweep = now
+		}
+		if rl.clients == nil {
+			rl.clients = make(map[string]*bucket)
+		}
+		key := clientKey(r)
+		if cb = rl.clients[key]; cb == nil {
+			cb = newBucket(client, now)
+			rl.clients[key] = cb
+		}
+		if wait := cb.take(client, now); wait > 0 {
+			return wait, "client"
+		}
+	}
+	if global.Rate > 0 {
+		if rl.global == nil {
+			rl.global = newBucket(global, now)
+		}
+		if wait := rl.global.take(global, now); wait > 0 {
+			if cb != nil {
+				cb.tokens++ // the request was not served
+			}
+			return wait, "global"
+		}
+	}
+	return 0, ""
+}
+
+// clientKey identifies the client of r by its authenticated identity, or
+// by its IP address without one. Credentials that have not been verified
+// are not used, for a client could send a new one with every request to
+// get a bucket of its own each time.
+func clientKey(r *http.Request) string {
+	if id := auth.IdentityFromContext(r.Context()); id != nil {
+		return "id:" + id.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limit wraps h so that requests over the server's rate limits get 429 Too
+// Many Requests with a Retry-After header.
+func (s *Server) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if wait, scope := s.allow(r); wait > 0 {
+			s.rateLimited.Inc(scope)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientRateLimitKey checks that clients are told apart by verified
+// identity or address only, so that a client cannot get a fresh bucket by
+// sending a new X-API-Key with every request.
+func TestClientRateLimitKey(t *testing.T) {
+	tests := []struct {
+		name string
+		auth bool
+		// reqs are the API key and address of each request, all of which
+		// but the last are allowed.
+		reqs [][2]string
+		last int
+	}{
+		{"same address, new keys", false, [][2]string{{"a", "192.0.2.1:1"}, {"b", "192.0.2.1:2"}}, http.StatusTooManyRequests},
+		{"other address", false, [][2]string{{"", "192.0.2.1:1"}, {"", "192.0.2.2:1"}}, http.StatusOK},
+		{"same identity, other address", true, [][2]string{{"secret", "192.0.2.1:1"}, {"secret", "192.0.2.2:1"}}, http.StatusTooManyRequests},
+		{"other identity, same address", true, [][2]string{{"secret", "192.0.2.1:1"}, {"other", "192.0.2.1:1"}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(nil)
+			if tt.auth {
+				s = newAuthServer(t, "secret alice\nother bob\n", "")
+			}
+			s.ClientRateLimit = Limit{Rate: 1.0 / 3600, Burst: 1}
+			for i, req := range tt.reqs {
+				r := httptest.NewRequest(http.MethodGet, "/greet?name=Ada", nil)
+				r.RemoteAddr = req[1]
+				if req[0] != "" {
+					r.Header.Set("X-API-Key", req[0])
+				}
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, r)
+				want := http.StatusOK
+				if i == len(tt.reqs)-1 {
+					want = tt.last
+				}
+				if w.Code != want {
+					t.Errorf("request %d: status %d, want %d", i, w.Code, want)
+				}
+			}
+		})
+	}
+}
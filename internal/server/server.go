@@ -0,0 +1,583 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	greeterv1 "github.com/fanda-blazek/syntax-highlighting-test/api/greeter/v1"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/audit"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/cache"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/history"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/metrics"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+const (
+	maxBodySize     = 1 << 20
+	defaultCapacity = 1000
+	storeTimeout    = 5 * time.Second
+)
+
+// Server exposes the greeting pipeline over HTTP:
+//
+//	GET  /greet?name=X[&lang=fr][&style=formal]
+//	GET  /messages[?limit=N]
+//	POST /messages
+//	GET  /ws[?lang=fr][&severity=warning]
+//	GET, POST /graphql
+//	POST /greeter.v1.GreeterService/{Greet,GreetBatch,GreetStream}
+//	GET  /metrics
+//	GET  /healthz, /readyz, /version
+//
+// Responses are JSON unless the client prefers text/plain. Requests over the
+// rate limits get 429 Too Many Requests with a Retry-After header. With Auth
+// set, API requests without valid credentials get 401 Unauthorized and those
+// the Policy denies 403 Forbidden. Every response carries an X-Request-ID
+// header, the client's if it sent a usable one. The GreeterService methods
+// are gRPC calls, which need HTTP/2, and are refused with gRPC statuses in
+// place of the HTTP ones.
+//
+// API requests are for the tenant their X-Tenant header or tenant
+// parameter names, as resolveTenant has it. Messages are recorded as the
+// tenant's, and a tenant's requests see its messages only.
+type Server struct {
+	// Style names the registered greeter used when a request has no style.
+	Style   string
+	History *history.Store
+	// Store, if set, keeps every recorded message beyond History.
+	Store store.MessageStore
+	// Keys, if set, signs every recorded message; see seal.Keyring.Sign.
+	Keys *seal.Keyring
+	// Audit, if set, logs the creation of every recorded message, by the
+	// caller and request that asked for it. Store should not be an
+	// audit.Store, which would log them again.
+	Audit *audit.Log
+	// Version is reported by /version, defaulting to the module version.
+	Version string
+	// GraphQLPlayground serves GraphiQL to browsers at /graphql.
+	GraphQLPlayground bool
+	// RateLimit caps requests from all clients together and
+	// ClientRateLimit those from each client, told apart by the identity
+	// Auth finds or else by IP address. /metrics and the health endpoints
+	// are not limited.
+	RateLimit, ClientRateLimit Limit
+	// Cache, if set, keeps rendered greetings for CacheTTL, keyed by style,
+	// name, locale and the translations used. Only the text and tags are
+	// cached; each request still gets a new message.
+	Cache    cache.Cache
+	CacheTTL time.Duration
+	// GreetingCacheSize bounds the in-process LRU of recent greetings in
+	// front of Cache, keyed by style, locale and name, which spares
+	// repeated greetings rendering their templates again. Zero means
+	// 1000 and a negative size disables it. InvalidateGreetings empties
+	// it.
+	GreetingCacheSize int
+	// Auth, if set, authenticates every request but those to /metrics and
+	// the health endpoints, and Policy decides what each identity may do.
+	// A nil Policy lets any authenticated caller do anything.
+	Auth   auth.Authenticator
+	Policy *auth.Policy
+	// Tenants, if set, are the tenants requests may be for, with their
+	// settings, and Tenant the one requests are for that name none. It may
+	// be empty for none; see the tenant package.
+	Tenants *tenant.Registry
+	Tenant  string
+	// Middleware, if not nil, is the chain the server's greetings are
+	// produced through, outermost first, in place of DefaultMiddleware.
+	// The caches are always innermost, so middleware sees cached
+	// greetings too.
+	Middleware []greeting.Middleware
+	// OnRecord, if set, is called with every message the server records.
+	// It must not block.
+	OnRecord func(message.Message)
+	mux      *http.ServeMux
+	limiter  rateLimiter
+	quotas   quotas
+
+	loaderOnce sync.Once
+	loader     *cache.Loader
+	recentOnce sync.Once
+	recent     *cache.LRU[greetingKey, cachedGreeting]
+
+	subsMu    sync.Mutex
+	subs      map[*subscriber]struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	checksMu sync.Mutex
+	checks   []check
+
+	metrics        *metrics.Registry
+	greetings      *metrics.CounterVec
+	renderDuration *metrics.Histogram
+	connections    *metrics.Gauge
+	subscribers    *metrics.Gauge
+	rateLimited    *metrics.CounterVec
+	cacheRequests  *metrics.CounterVec
+	recentRequests *metrics.CounterVec
+}
+
+// New returns a Server recording every message it produces in h, or in a
+// fresh store of the last 1000 messages if h is nil.
+func New(h *history.Store) *Server {
+	if h == nil {
+		h = history.New(defaultCapacity)
+	}
+	s := &Server{
+		Style:   "default",
+		History: h,
+		mux:     http.NewServeMux(),
+		subs:    make(map[*subscriber]struct{}),
+		done:    make(chan struct{}),
+		metrics: metrics.NewRegistry(),
+	}
+	s.greetings = s.metrics.Counter("greeter_greetings_total", "Greetings produced, by language.", "lang")
+	s.renderDuration = s.metrics.Histogram("greeter_render_duration_seconds", "Time taken to produce a greeting.", nil)
+	s.connections = s.metrics.Gauge("greeter_active_connections", "Open HTTP connections, see ConnState.")
+	s.subscribers = s.metrics.Gauge("greeter_subscribers", "Connected WebSocket and GraphQL subscription clients.")
+	s.rateLimited = s.metrics.Counter("greeter_rate_limited_total", "Requests refused with 429, by the limit that applied.", "scope")
+	s.cacheRequests = s.metrics.Counter("greeter_cache_requests_total", "Greeting cache lookups, by result.", "result")
+	s.recentRequests = s.metrics.Counter("greeter_greeting_lru_requests_total", "In-process greeting LRU lookups, by result: hit or miss.", "result")
+	s.mux.HandleFunc("GET /greet", s.protect("", s.handleGreet))
+	s.mux.HandleFunc("GET /messages", s.protect(auth.ActionRead, s.handleListMessages))
+	s.mux.HandleFunc("POST /messages", s.protect(auth.ActionPost, s.handlePostMessage))
+	s.mux.HandleFunc("GET /ws", s.protect(auth.ActionRead, s.handleWebSocket))
+	s.mux.HandleFunc("/graphql", s.protect("", s.handleGraphQL))
+	s.mux.HandleFunc("POST "+greeterv1.ServicePath, s.protect("", greeterv1.NewGreeterServiceHandler(grpcService{s}).ServeHTTP))
+	s.mux.Handle("GET /metrics", s.metrics)
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("GET /version", s.handleVersion)
+	s.AddCheck("history", s.checkHistory)
+	s.AddCheck("translations", checkTranslations)
+	return s
+}
+
+// ServeHTTP routes r, tracing it as a server span when tracing is on. A
+// W3C traceparent header on r makes the span part of the caller's trace.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := requestID(r)
+	w.Header().Set("X-Request-ID", id)
+	ctx, span := trace.StartKind(trace.Extract(audit.ContextWithRequestID(r.Context(), id), r.Header), r.Method, trace.SpanKindServer,
+		trace.String("http.request.method", r.Method), trace.String("url.path", r.URL.Path))
+	r = r.WithContext(ctx)
+	if span == nil {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	defer span.End()
+	if _, pattern := s.mux.Handler(r); pattern != "" {
+		if !strings.Contains(pattern, " ") {
+			pattern = r.Method + " " + pattern
+		}
+		span.SetName(pattern)
+		span.SetAttributes(trace.String("http.route", pattern))
+	}
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(sw, r)
+	span.SetAttributes(trace.Int("http.response.status_code", sw.status))
+	if sw.status >= 500 {
+		span.RecordError(errors.New(http.StatusText(sw.status)))
+	}
+}
+
+// maxRequestID bounds the length of the request IDs taken from clients.
+const maxRequestID = 128
+
+// requestID returns the X-Request-ID of r, if it has one of printable
+// ASCII no longer than maxRequestID, or a new random ID.
+func requestID(r *http.Request) string {
+	id := r.Header.Get("X-Request-ID")
+	usable := id != "" && len(id) <= maxRequestID
+	for i := 0; usable && i < len(id); i++ {
+		usable = id[i] > ' ' && id[i] <= '~'
+	}
+	if usable {
+		return id
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// statusWriter records the response status for the request span. It
+// passes through the Flusher and Hijacker interfaces used for streaming.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ConnState tracks open connections for the greeter_active_connections
+// gauge. Set it as the http.Server's ConnState hook.
+func (s *Server) ConnState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.connections.Inc()
+	case http.StateHijacked, http.StateClosed:
+		s.connections.Dec()
+	}
+}
+
+// greet greets name with g, the greeter registered as style, recording and
+// timing the greeting.
+func (s *Server) greet(ctx context.Context, style string, g greeting.Greeter, name string) (message.Message, error) {
+	mw := s.Middleware
+	if mw == nil {
+		mw = s.DefaultMiddleware()
+	}
+	cached := greeting.GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+		return s.cachedGreet(ctx, style, g, name)
+	})
+	m, err := greeting.Chain(cached, mw...).Greet(ctx, name)
+	if err != nil {
+		return m, err
+	}
+	return s.RecordContext(ctx, m), nil
+}
+
+// DefaultMiddleware returns the chain greetings are produced through
+// unless Middleware is set: the tenant's quota is taken, then the greeting
+// is timed and counted by /metrics, once validated.
+func (s *Server) DefaultMiddleware() []greeting.Middleware {
+	return []greeting.Middleware{greeting.Limiting(s.takeQuota), s.measure, greeting.Validating}
+}
+
+// measure times the greetings next produces and counts them by locale.
+func (s *Server) measure(next greeting.Greeter) greeting.Greeter {
+	return greeting.GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+		start := time.Now()
+		m, err := next.Greet(ctx, name)
+		if err != nil {
+			return m, err
+		}
+		s.renderDuration.Observe(time.Since(start).Seconds())
+		locale, _ := greeting.MessageLocale(m)
+		s.greetings.Inc(locale)
+		return m, nil
+	})
+}
+
+func (s *Server) handleGreet(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	name := greetName(q.Get("name"))
+	if !s.allowed(r.Context(), auth.ActionGreet, name) {
+		writeError(w, r, http.StatusForbidden, errForbidden)
+		return
+	}
+	ctx, style, g, err := s.greeter(r.Context(), q.Get("style"), q.Get("lang"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	m, err := s.greet(ctx, style, g, name)
+	if err != nil {
+		writeError(w, r, statusFor(err), err)
+		return
+	}
+	writeMessage(w, r, http.StatusOK, m)
+}
+
+// greeter returns the greeter for a request asking for style and lang,
+// either of which may be empty, with its style and ctx set up for it. The
+// tenant of ctx supplies the style, template, locale and translations the
+// request does not ask for.
+func (s *Server) greeter(ctx context.Context, style, lang string) (context.Context, string, greeting.Greeter, error) {
+	t := tenant.FromContext(ctx)
+	if t != nil {
+		if lang == "" {
+			lang = t.Locale
+		}
+		if t.Localizer != nil {
+			ctx = greeting.ContextWithLocalizer(ctx, t.Localizer)
+		}
+	}
+	if lang != "" {
+		ctx = greeting.ContextWithLocale(ctx, lang)
+	}
+	if style == "" && t != nil && t.Template != "" {
+		return ctx, "tenant-template", greeting.TemplateGreeter{Template: t.Template}, nil
+	}
+	if style == "" && t != nil {
+		style = t.Style
+	}
+	if style == "" {
+		style = s.Style
+	}
+	g, err := greeting.Lookup(style)
+	return ctx, style, g, err
+}
+
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	limit := s.History.Len()
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, errors.New("limit must be a non-negative integer"))
+			return
+		}
+		limit = n
+	}
+	writeMessages(w, r, http.StatusOK, s.history(r.Context(), messageFilter{}, limit))
+}
+
+func (s *Server) handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != "application/json" {
+			writeError(w, r, http.StatusUnsupportedMediaType, errors.New("body must be application/json"))
+			return
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	m, err := decodeMessage(body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := m.Validate(); err != nil {
+		writeError(w, r, statusFor(err), err)
+		return
+	}
+	if s.Store != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+		old, err := s.Store.Get(ctx, m.ID)
+		cancel()
+		if err == nil && tenant.Of(old) != tenant.NameFromContext(r.Context()) {
+			writeError(w, r, http.StatusConflict, errors.New("message ID is taken"))
+			return
+		}
+	}
+	if err := s.takeQuota(r.Context()); err != nil {
+		writeError(w, r, statusFor(err), err)
+		return
+	}
+	m = s.RecordContext(r.Context(), m)
+	writeMessage(w, r, http.StatusCreated, m)
+}
+
+// Record records m as the server does the messages it produces: in History
+// and Store, on the live feed and with OnRecord. It returns m as recorded,
+// signed if the server has Keys.
+func (s *Server) Record(m message.Message) message.Message {
+	return s.RecordContext(context.Background(), m)
+}
+
+// RecordContext records m as Record does, for the request or other actor
+// ctx belongs to; see audit.Log.Append. m is recorded as the message of
+// the tenant of ctx, or of none.
+func (s *Server) RecordContext(ctx context.Context, m message.Message) message.Message {
+	m = tenant.Set(m, tenant.NameFromContext(ctx))
+	if s.Keys != nil {
+		m = s.Keys.Sign(m)
+	}
+	s.History.Record(m)
+	ctx = context.WithoutCancel(ctx)
+	if s.Store != nil {
+		ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+		if err := s.Store.Save(ctx, m); err != nil {
+			slog.Error("saving message failed", "id", m.ID, "error", err)
+		}
+		cancel()
+	}
+	if s.Audit != nil {
+		if err := s.Audit.Append(ctx, audit.Entry{Action: audit.Create, MessageID: m.ID}); err != nil {
+			slog.Error("audit log failed", "id", m.ID, "action", audit.Create, "error", err)
+		}
+	}
+	s.publish(m)
+	if s.OnRecord != nil {
+		s.OnRecord(m)
+	}
+	slog.Info("message", "id", m.ID, "severity", m.Severity, "sender", m.Sender)
+	return m
+}
+
+// maxIDLength bounds the IDs clients may give the messages they post.
+const maxIDLength = 128
+
+var errInvalidID = errors.New("invalid message ID: want at most " + strconv.Itoa(maxIDLength) + " letters, digits, '-', '_' or '.'")
+
+// decodeMessage decodes a posted message, filling in the ID, creation time
+// and sender the client left out. An ID the client gave must be made of
+// letters, digits and -._, as it ends up in URLs and mail headers.
+func decodeMessage(body []byte) (message.Message, error) {
+	m, err := message.UnmarshalMessage(body, message.FormatJSON, true)
+	if err != nil {
+		return m, err
+	}
+	defaults := message.NewMessage(m.Text)
+	if m.ID == "" {
+		m.ID = defaults.ID
+	} else if !validID(m.ID) {
+		return m, errInvalidID
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = defaults.CreatedAt
+	}
+	if m.Sender == "" {
+		m.Sender = defaults.Sender
+	}
+	return m, nil
+}
+
+func validID(id string) bool {
+	if len(id) > maxIDLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func statusFor(err error) int {
+	var verr *message.ValidationError
+	if errors.As(err, &verr) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, errQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// wantsText reports whether the client prefers text/plain over JSON.
+func wantsText(r *http.Request) bool {
+	var textQ, jsonQ float64 = -1, -1
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mt {
+		case "text/plain", "text/*":
+			textQ = max(textQ, q)
+		case "application/json", "application/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return textQ > jsonQ
+}
+
+// writeMessages writes ms as JSON, or as lines of text if the client asked
+// for it. list selects a JSON array instead of a single object.
+func writeMessages(w http.ResponseWriter, r *http.Request, status int, ms []message.Message) {
+	_, span := trace.Start(r.Context(), "server.render", trace.Int("messages", len(ms)))
+	defer span.End()
+	w.Header().Set("Vary", "Accept")
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		for _, m := range ms {
+			io.WriteString(w, m.Text+"\n")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if ms == nil {
+		ms = []message.Message{}
+	}
+	json.NewEncoder(w).Encode(ms)
+}
+
+// writeMessage writes m as writeMessages does a list, but as the object
+// itself.
+func writeMessage(w http.ResponseWriter, r *http.Request, status int, m message.Message) {
+	_, span := trace.Start(r.Context(), "server.render", trace.Int("messages", 1))
+	defer span.End()
+	w.Header().Set("Vary", "Accept")
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		io.WriteString(w, m.Text+"\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(m)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if greeterv1.IsGRPC(r) {
+		greeterv1.WriteError(w, grpcStatus(status, err))
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+	if wantsText(r) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	body := map[string]any{"error": err.Error()}
+	// Validation failures also name the field and list each failure, so
+	// clients can tell them apart.
+	var verr *message.ValidationError
+	if errors.As(err, &verr) {
+		reasons := []error{verr.Err}
+		if joined, ok := verr.Err.(interface{ Unwrap() []error }); ok {
+			reasons = joined.Unwrap()
+		}
+		list := make([]string, len(reasons))
+		for i, r := range reasons {
+			list[i] = r.Error()
+		}
+		body["field"], body["reasons"] = verr.Field, list
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// recording returns middleware appending name to calls for each greeting
+// it sees.
+func recording(calls *[]string, name string) greeting.Middleware {
+	return func(next greeting.Greeter) greeting.Greeter {
+		return greeting.GreeterFunc(func(ctx context.Context, n string) (message.Message, error) {
+			*calls = append(*calls, name)
+			return next.Greet(ctx, n)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	s := New(nil)
+	s.Middleware = []greeting.Middleware{
+		recording(&calls, "outer"),
+		greeting.Transforming(strings.ToUpper),
+		recording(&calls, "inner"),
+	}
+	w := do(s, http.MethodGet, "/greet?name=Ada", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var m message.Message
+	if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Text != "HELLO, ADA!" {
+		t.Errorf("greeted %q, want HELLO, ADA!", m.Text)
+	}
+	if want := []string{"outer", "inner"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %q, want %q", calls, want)
+	}
+}
+
+// TestGreetRejectsEmptyNames checks that no style greets, or records, a
+// missing name.
+func TestGreetRejectsEmptyNames(t *testing.T) {
+	s := New(nil)
+	for _, style := range greeting.Greeters() {
+		for _, target := range []string{"/greet?style=" + style, "/greet?name=+&style=" + style} {
+			if w := do(s, http.MethodGet, target, "", ""); w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("GET %s: status %d, want 422: %s", target, w.Code, w.Body)
+			}
+		}
+	}
+	w := do(s, http.MethodGet, "/messages", "", "")
+	var ms []message.Message
+	if err := json.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 0 {
+		t.Errorf("recorded %d messages, want none", len(ms))
+	}
+}
+
+// TestDefaultMiddlewareTakesQuotaFirst checks that a greeting over its
+// tenant's quota goes no further than the quota.
+func TestDefaultMiddlewareTakesQuotaFirst(t *testing.T) {
+	var calls []string
+	s := newTenantServer(t)
+	s.Middleware = append(s.DefaultMiddleware(), recording(&calls, "greet"))
+	for i, status := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := do(s, http.MethodGet, "/greet?name=Ada", "bob-key", "globex"); w.Code != status {
+			t.Fatalf("greeting %d: status %d, want %d", i, w.Code, status)
+		}
+	}
+	if len(calls) != 2 {
+		t.Errorf("%d greetings got past the quota, want 2", len(calls))
+	}
+}
+
+func TestPostMessageID(t *testing.T) {
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{"", http.StatusCreated},
+		{"0123456789abcdef", http.StatusCreated},
+		{"msg-1_a.b", http.StatusCreated},
+		{"a\r\nBcc: x@y", http.StatusBadRequest},
+		{"a b", http.StatusBadRequest},
+		{"a/b", http.StatusBadRequest},
+		{"\u00e9", http.StatusBadRequest},
+		{strings.Repeat("a", maxIDLength+1), http.StatusBadRequest},
+	}
+	s := New(nil)
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{"text": "hi", "id": tt.id})
+		r := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("ID %q: status %d, want %d: %s", tt.id, w.Code, tt.status, w.Body)
+		}
+	}
+}
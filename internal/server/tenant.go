@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/auth"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+)
+
+var (
+	errNotMember     = errors.New("not a member of the tenant")
+	errQuotaExceeded = errors.New("tenant message quota exceeded")
+)
+
+// resolveTenant returns the tenant r is for: the one its X-Tenant header or
+// tenant query parameter names, or else the one its identity's tenant
+// claim names or the only one it is a member of, or else the server's
+// Tenant. It returns nil for none. With Tenants set, the tenant must be
+// one of them, and with Auth set, the identity must be a member of it or
+// claim it; without Tenants, a tenant has no members, so an identity may
+// only be for the tenant it claims.
+func (s *Server) resolveTenant(r *http.Request) (*tenant.Tenant, int, error) {
+	id := auth.IdentityFromContext(r.Context())
+	name := r.Header.Get("X-Tenant")
+	if name == "" {
+		name = r.URL.Query().Get("tenant")
+	}
+	var claimed string
+	if id != nil {
+		claimed, _ = id.Claims["tenant"].(string)
+	}
+	if name == "" {
+		name = claimed
+	}
+	if name == "" && id != nil && s.Tenants != nil {
+		if ts := s.Tenants.ForSubject(id.Subject); len(ts) == 1 {
+			name = ts[0].Name
+		}
+	}
+	if name == "" {
+		name = s.Tenant
+	}
+	if name == "" {
+		return nil, 0, nil
+	}
+	if err := tenant.Validate(name); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	t := &tenant.Tenant{Name: name}
+	if s.Tenants != nil {
+		var err error
+		if t, err = s.Tenants.Lookup(name); err != nil {
+			return nil, http.StatusNotFound, err
+		}
+	}
+	if s.Auth != nil && name != claimed && !t.Member(id.Subject) {
+		return nil, http.StatusForbidden, errNotMember
+	}
+	return t, 0, nil
+}
+
+// quotas counts the messages recorded for each tenant in the current
+// period of its quota.
+type quotas struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+// takeQuota counts a message for the tenant of ctx, or returns
+// errQuotaExceeded if its quota for the period is used up.
+func (s *Server) takeQuota(ctx context.Context) error {
+	t := tenant.FromContext(ctx)
+	if t == nil || t.Quota.Messages <= 0 {
+		return nil
+	}
+	now := time.Now()
+	q := &s.quotas
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.windows == nil {
+		q.windows = make(map[string]*quotaWindow)
+	}
+	w := q.windows[t.Name]
+	if w == nil || now.Sub(w.start) >= t.Quota.Per {
+		w = &quotaWindow{start: now.Truncate(t.Quota.Per)}
+		q.windows[t.Name] = w
+	}
+	if w.count >= t.Quota.Messages {
+		return errQuotaExceeded
+	}
+	w.count++
+	return nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+const testTenants = `
+acme template Ahoj, {name}!
+acme subjects alice
+globex lang fr
+globex quota 2/d
+globex subjects bob
+`
+
+// newTenantServer returns a server for the tenants acme, of alice, and
+// globex, of bob, which mallory is in neither of.
+func newTenantServer(t *testing.T) *Server {
+	t.Helper()
+	reg, err := tenant.Read(strings.NewReader(testTenants), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newAuthServer(t, "alice-key alice\nbob-key bob\nmallory-key mallory\n", "")
+	s.Tenants = reg
+	return s
+}
+
+// do makes a request as the caller with key, for the tenant named if not
+// empty.
+func do(s *Server, method, target, key, tenantName string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("X-API-Key", key)
+	if tenantName != "" {
+		r.Header.Set("X-Tenant", tenantName)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestTenantResolution(t *testing.T) {
+	s := newTenantServer(t)
+	tests := []struct {
+		name, key, tenant string
+		status            int
+		text              string
+	}{
+		{"member, by header", "alice-key", "acme", http.StatusOK, "Ahoj, Ada!"},
+		{"only tenant of the identity", "bob-key", "", http.StatusOK, "Bonjour, Ada !"},
+		{"not a member", "alice-key", "globex", http.StatusForbidden, ""},
+		{"unknown tenant", "alice-key", "initech", http.StatusNotFound, ""},
+		{"invalid name", "alice-key", "Acme!", http.StatusBadRequest, ""},
+		{"no tenant", "mallory-key", "", http.StatusOK, "Hello, Ada!"},
+		{"no tenant, not a member", "mallory-key", "acme", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(s, http.MethodGet, "/greet?name=Ada", tt.key, tt.tenant)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.text == "" {
+				return
+			}
+			var m message.Message
+			if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Text != tt.text {
+				t.Errorf("greeted %q, want %q", m.Text, tt.text)
+			}
+		})
+	}
+}
+
+// TestTenantIsolation checks that a tenant's requests see its messages
+// only, and that quotas are counted per tenant.
+func TestTenantIsolation(t *testing.T) {
+	s := newTenantServer(t)
+	greets := []struct {
+		key, tenant string
+		status      int
+	}{
+		{"alice-key", "acme", http.StatusOK},
+		{"alice-key", "acme", http.StatusOK},
+		{"alice-key", "acme", http.StatusOK},
+		{"bob-key", "globex", http.StatusOK},
+		{"bob-key", "globex", http.StatusOK},
+		// The third greeting of the day is over globex's quota, but acme's
+		// greetings did not count against it.
+		{"bob-key", "globex", http.StatusTooManyRequests},
+		{"mallory-key", "", http.StatusOK},
+	}
+	for i, g := range greets {
+		if w := do(s, http.MethodGet, "/greet?name=Ada", g.key, g.tenant); w.Code != g.status {
+			t.Fatalf("greeting %d: status %d, want %d", i, w.Code, g.status)
+		}
+	}
+	tests := []struct {
+		key, tenant string
+		want        int
+	}{
+		{"alice-key", "acme", 3},
+		{"bob-key", "globex", 2},
+		{"mallory-key", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			w := do(s, http.MethodGet, "/messages", tt.key, tt.tenant)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var ms []message.Message
+			if err := json.NewDecoder(w.Body).Decode(&ms); err != nil {
+				t.Fatal(err)
+			}
+			if len(ms) != tt.want {
+				t.Errorf("listed %d messages, want %d", len(ms), tt.want)
+			}
+			for _, m := range ms {
+				if got := tenant.Of(m); got != tt.tenant {
+					t.Errorf("listed message %s of tenant %q", m.ID, got)
+				}
+			}
+		})
+	}
+}
+
+// TestDefaultTenantWithoutRegistry checks that, with no registry, only the
+// identities that claim the server's tenant may act for it, as cmd/greeter
+// refuses to start so configured.
+func TestDefaultTenantWithoutRegistry(t *testing.T) {
+	s := newAuthServer(t, "alice-key alice\n", "")
+	s.Tenant = "acme"
+	if w := do(s, http.MethodGet, "/greet?name=Ada", "alice-key", ""); w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", w.Code)
+	}
+	s.Auth = nil
+	if w := do(s, http.MethodGet, "/greet?name=Ada", "", ""); w.Code != http.StatusOK {
+		t.Errorf("without auth: status %d, want 200", w.Code)
+	}
+}
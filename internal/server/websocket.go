@@ -0,0 +1,239 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// The WebSocket support is the subset of RFC 6455 the live feed needs:
+// unfragmented text frames out, control frames in both directions.
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+
+	maxFrameSize = 1 << 16
+	pingInterval = 30 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// handleWebSocket streams every message the server produces to the client
+// as JSON text frames. The lang and severity query parameters restrict the
+// feed to one language and to messages at least that severe.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub := &subscriber{messages: make(chan message.Message, subscriberBuffer)}
+	sub.tenant = tenant.NameFromContext(r.Context())
+	sub.lang = strings.ToLower(r.URL.Query().Get("lang"))
+	if v := r.URL.Query().Get("severity"); v != "" {
+		if err := sub.minSeverity.UnmarshalText([]byte(v)); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	conn, rw, err := upgrade(w, r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	defer conn.Close()
+	s.subscribe(sub)
+	defer s.unsubscribe(sub)
+	s.stream(&wsConn{conn: conn, rw: rw}, sub)
+}
+
+func (s *Server) stream(c *wsConn, sub *subscriber) {
+	pongs := make(chan struct{}, 1)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		c.readLoop(pongs)
+	}()
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	awaitingPong := false
+	for {
+		var err error
+		select {
+		case m := <-sub.messages:
+			var b []byte
+			if b, err = json.Marshal(m); err == nil {
+				err = c.write(opText, b)
+			}
+		case <-pongs:
+			awaitingPong = false
+		case <-ticker.C:
+			if awaitingPong {
+				slog.Info("websocket client stopped answering pings")
+				return
+			}
+			awaitingPong = true
+			err = c.write(opPing, nil)
+		case <-closed:
+			return
+		case <-s.done:
+			c.write(opClose, closePayload(1001, "server shutting down"))
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, nil, errors.New("expected a WebSocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, nil, errors.New("unsupported WebSocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection does not support WebSocket upgrades")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // serializes writes
+}
+
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	c.rw.Write(header)
+	c.rw.Write(payload)
+	return c.rw.Flush()
+}
+
+// readLoop answers pings and close frames until the connection fails or
+// the client closes it, reporting pongs on pongs. Data frames are ignored.
+func (c *wsConn) readLoop(pongs chan<- struct{}) {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.Debug("websocket read failed", "error", err)
+			}
+			return
+		}
+		switch opcode {
+		case opPing:
+			if c.write(opPong, payload) != nil {
+				return
+			}
+		case opPong:
+			select {
+			case pongs <- struct{}{}:
+			default:
+			}
+		case opClose:
+			c.write(opClose, payload)
+			return
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode = header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frame is not masked")
+	}
+	n := uint64(header[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the %d byte limit", n, maxFrameSize)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+func closePayload(code uint16, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, code), reason...)
+}
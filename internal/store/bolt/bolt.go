@@ -0,0 +1,635 @@
+package bolt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	messagev1 "github.com/fanda-blazek/syntax-highlighting-test/api/message/v1"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/compress"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Store is a store.MessageStore in a single file, with one bucket of
+// messages per sender. It needs no dependencies beyond the standard
+// library: rather than bbolt's B+tree the file is an append-only log of
+// puts and deletes, indexed in memory when the store is opened. Deleted and
+// replaced messages take up space until Compact rewrites the file.
+//
+// Schedules are kept in the same log, outside the buckets.
+//
+// A file must not be opened by more than one Store at a time.
+type Store struct {
+	mu   sync.RWMutex
+	name string
+	f    *os.File
+	// temp is whether the file is removed on Close, as for Memory.
+	temp bool
+	size int64
+	// dead counts the bytes of records that no longer hold a live message.
+	dead    int64
+	seq     uint64
+	version int
+
+	buckets map[string]map[string]entry // sender → ID → entry
+	senders map[string]string           // ID → sender
+	// schedules indexes the schedule records by ID.
+	schedules map[string]entry
+}
+
+type entry struct {
+	off, size int64
+	created   int64
+	seq       uint64 // orders messages created at the same time
+}
+
+const (
+	// The file starts with magic and the format version on a line. Each
+	// message is stored as api/message/v1 encodes it, so fields added to
+	// message.proto need no new version; changes to the record layout do.
+	// Format 2 added the schedule ops, format 3 gzipped messages and
+	// format 4 protobuf ones, in place of JSON; an older file is upgraded
+	// when a record it has no op for is first written to it.
+	magic         = "greeter-bolt-"
+	formatVersion = 4
+
+	opPut            = 1
+	opDelete         = 2
+	opPutSchedule    = 3
+	opDeleteSchedule = 4
+	// opPutGzip puts a message whose JSON is gzipped, for messages long
+	// enough that compress.Blob shrinks them.
+	opPutGzip = 5
+	// opPutProto and opPutProtoGzip are opPut and opPutGzip with the
+	// message in the protobuf encoding.
+	opPutProto     = 6
+	opPutProtoGzip = 7
+
+	// recordHeader is the CRC-32 and length that precede each record.
+	recordHeader = 8
+	maxRecord    = 16 << 20
+)
+
+var (
+	_ store.MessageStore  = (*Store)(nil)
+	_ store.ScheduleStore = (*Store)(nil)
+)
+
+// Memory is the name that opens a private store in a temporary file, which
+// is removed when the store is closed.
+const Memory = ":memory:"
+
+// Open opens the store in the file name, creating it if needed, or a
+// private one for Memory. A record left incomplete by a crash is cut off.
+func Open(name string) (*Store, error) {
+	var f *os.File
+	var err error
+	if name == Memory {
+		f, err = os.CreateTemp("", "greeter-bolt-*")
+	} else {
+		f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{name: f.Name(), f: f, temp: name == Memory}
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("bolt: %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// load reads the file into the index, writing the header to a new file.
+func (s *Store) load() error {
+	s.buckets = make(map[string]map[string]entry)
+	s.senders = make(map[string]string)
+	s.schedules = make(map[string]entry)
+	s.size, s.dead, s.seq, s.version = 0, 0, 0, formatVersion
+	info, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		n, err := s.f.WriteString(header())
+		if err != nil {
+			return err
+		}
+		s.size = int64(n)
+		return s.f.Sync()
+	}
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, info.Size()))
+	if p, _ := r.Peek(len(magic)); string(p) != magic {
+		return errors.New("not a greeter bolt store")
+	}
+	head, err := r.ReadString('\n')
+	version, verr := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(head, magic), "\n"))
+	if err != nil || !strings.HasPrefix(head, magic) || verr != nil {
+		return errors.New("not a greeter bolt store")
+	}
+	if version > formatVersion {
+		return fmt.Errorf("%w: format %d, want at most %d", store.ErrSchemaTooNew, version, formatVersion)
+	}
+	s.version = version
+	off := int64(len(head))
+	for {
+		op, bucket, key, value, n, err := readRecord(r)
+		if err != nil {
+			// Anything after the last whole record was being written
+			// when the process stopped.
+			if err := s.f.Truncate(off); err != nil {
+				return err
+			}
+			break
+		}
+		switch op {
+		case opPut, opPutGzip, opPutProto, opPutProtoGzip:
+			m, err := decodeMessage(op, value)
+			if err != nil {
+				return fmt.Errorf("record at %d: %w", off, err)
+			}
+			s.put(bucket, key, entry{off: off, size: n, created: m.CreatedAt.UnixNano()})
+		case opDelete:
+			s.remove(key)
+			s.dead += n
+		case opPutSchedule:
+			s.putSchedule(key, entry{off: off, size: n})
+		case opDeleteSchedule:
+			s.removeSchedule(key)
+			s.dead += n
+		}
+		off += n
+	}
+	s.size = off
+	return nil
+}
+
+func header() string {
+	return magic + strconv.Itoa(formatVersion) + "\n"
+}
+
+// upgrade rewrites the header of a file in an older format than version,
+// before a record needing version is written. Each format only adds ops,
+// so the records need no change. It is called with s.mu held.
+func (s *Store) upgrade(version int) error {
+	if s.version >= version {
+		return nil
+	}
+	// The header is as long in every format.
+	if _, err := s.f.WriteAt([]byte(header()), 0); err != nil {
+		return err
+	}
+	s.version = formatVersion
+	return nil
+}
+
+// decodeMessage decodes the value of a message record put with op.
+func decodeMessage(op byte, value []byte) (message.Message, error) {
+	var m message.Message
+	if op == opPutGzip || op == opPutProtoGzip {
+		var err error
+		if value, err = compress.Unblob(value); err != nil {
+			return m, err
+		}
+	}
+	if op == opPutProto || op == opPutProtoGzip {
+		return messagev1.UnmarshalMessage(value)
+	}
+	return m, json.Unmarshal(value, &m)
+}
+
+// A record is a CRC-32 of the rest, its length, then the op byte and the
+// bucket, key and value, each preceded by a uvarint length.
+func readRecord(r *bufio.Reader) (op byte, bucket, key string, value []byte, n int64, err error) {
+	var head [recordHeader]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, "", "", nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(head[4:])
+	if size == 0 || size > maxRecord {
+		return 0, "", "", nil, 0, errors.New("invalid record length")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, "", "", nil, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(head[:4]) {
+		return 0, "", "", nil, 0, errors.New("record checksum mismatch")
+	}
+	op, rest := body[0], body[1:]
+	var fields [3][]byte
+	for i := range fields {
+		l, k := binary.Uvarint(rest)
+		if k <= 0 || l > uint64(len(rest)-k) {
+			return 0, "", "", nil, 0, errors.New("invalid record field")
+		}
+		fields[i], rest = rest[k:k+int(l)], rest[k+int(l):]
+	}
+	return op, string(fields[0]), string(fields[1]), fields[2], recordHeader + int64(size), nil
+}
+
+func encodeRecord(op byte, bucket, key string, value []byte) []byte {
+	b := make([]byte, recordHeader, recordHeader+1+3*binary.MaxVarintLen64+len(bucket)+len(key)+len(value))
+	b = append(b, op)
+	for _, field := range [][]byte{[]byte(bucket), []byte(key), value} {
+		b = binary.AppendUvarint(b, uint64(len(field)))
+		b = append(b, field...)
+	}
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-recordHeader))
+	binary.BigEndian.PutUint32(b, crc32.ChecksumIEEE(b[recordHeader:]))
+	return b
+}
+
+// put indexes a message record, replacing any earlier one with its ID.
+func (s *Store) put(bucket, key string, e entry) {
+	s.remove(key)
+	s.seq++
+	e.seq = s.seq
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]entry)
+	}
+	s.buckets[bucket][key] = e
+	s.senders[key] = bucket
+}
+
+func (s *Store) remove(key string) {
+	bucket, ok := s.senders[key]
+	if !ok {
+		return
+	}
+	e := s.buckets[bucket][key]
+	delete(s.buckets[bucket], key)
+	if len(s.buckets[bucket]) == 0 {
+		delete(s.buckets, bucket)
+	}
+	delete(s.senders, key)
+	s.dead += e.size
+}
+
+// appendRecord writes a record at the end of the file and syncs it,
+// returning its offset.
+func (s *Store) appendRecord(b []byte) (int64, error) {
+	off := s.size
+	if _, err := s.f.WriteAt(b, off); err != nil {
+		s.f.Truncate(off)
+		return 0, err
+	}
+	if err := s.f.Sync(); err != nil {
+		return 0, err
+	}
+	s.size += int64(len(b))
+	return off, nil
+}
+
+func (s *Store) Save(ctx context.Context, m message.Message) error {
+	value := messagev1.MarshalMessage(m)
+	op := byte(opPutProto)
+	if zipped, ok := compress.Blob(value); ok {
+		op, value = opPutProtoGzip, zipped
+	}
+	b := encodeRecord(op, m.Sender, m.ID, value)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	if err := s.upgrade(4); err != nil {
+		return err
+	}
+	off, err := s.appendRecord(b)
+	if err != nil {
+		return err
+	}
+	s.put(m.Sender, m.ID, entry{off: off, size: int64(len(b)), created: m.CreatedAt.UnixNano()})
+	return nil
+}
+
+// read decodes the message stored at e. It is called with s.mu held.
+func (s *Store) read(e entry) (message.Message, error) {
+	r := bufio.NewReader(io.NewSectionReader(s.f, e.off, e.size))
+	op, _, _, value, _, err := readRecord(r)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("bolt: %s at %d: %w", s.name, e.off, err)
+	}
+	return decodeMessage(op, value)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (message.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.f == nil {
+		return message.Message{}, os.ErrClosed
+	}
+	bucket, ok := s.senders[id]
+	if !ok {
+		return message.Message{}, fmt.Errorf("%w: %s", store.ErrNotFound, id)
+	}
+	return s.read(s.buckets[bucket][id])
+}
+
+func (s *Store) List(ctx context.Context, limit int) ([]message.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.f == nil {
+		return nil, os.ErrClosed
+	}
+	var all []entry
+	for _, b := range s.buckets {
+		for _, e := range b {
+			all = append(all, e)
+		}
+	}
+	return s.readAll(ctx, all, limit)
+}
+
+// Find reads the messages of q's sender, or of every sender, to match
+// them against q.
+func (s *Store) Find(ctx context.Context, q store.Query) ([]message.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.f == nil {
+		return nil, os.ErrClosed
+	}
+	var es []entry
+	for sender, b := range s.buckets {
+		if q.Sender != "" && sender != q.Sender {
+			continue
+		}
+		for _, e := range b {
+			if (q.Since.IsZero() || e.created >= q.Since.UnixNano()) && (q.Until.IsZero() || e.created < q.Until.UnixNano()) {
+				es = append(es, e)
+			}
+		}
+	}
+	ms, err := s.readAll(ctx, es, -1)
+	if err != nil {
+		return nil, err
+	}
+	if q.Order == store.NewestFirst {
+		slices.Reverse(ms)
+	}
+	var out []message.Message
+	skip := q.Offset
+	for _, m := range ms {
+		if !q.Match(m) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		out = append(out, m)
+		if q.Limit > 0 && len(out) == q.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// readAll reads the newest limit of es, oldest first. It is called with
+// s.mu held.
+func (s *Store) readAll(ctx context.Context, es []entry, limit int) ([]message.Message, error) {
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].created != es[j].created {
+			return es[i].created < es[j].created
+		}
+		return es[i].seq < es[j].seq
+	})
+	if limit >= 0 && limit < len(es) {
+		es = es[len(es)-limit:]
+	}
+	ms := make([]message.Message, 0, len(es))
+	for _, e := range es {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		m, err := s.read(e)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// Senders returns the senders that have messages in the store, sorted.
+func (s *Store) Senders() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	senders := make([]string, 0, len(s.buckets))
+	for sender := range s.buckets {
+		senders = append(senders, sender)
+	}
+	slices.Sort(senders)
+	return senders
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	bucket, ok := s.senders[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", store.ErrNotFound, id)
+	}
+	b := encodeRecord(opDelete, bucket, id, nil)
+	if _, err := s.appendRecord(b); err != nil {
+		return err
+	}
+	s.remove(id)
+	s.dead += int64(len(b))
+	return nil
+}
+
+func (s *Store) putSchedule(id string, e entry) {
+	s.removeSchedule(id)
+	s.schedules[id] = e
+}
+
+func (s *Store) removeSchedule(id string) {
+	if e, ok := s.schedules[id]; ok {
+		delete(s.schedules, id)
+		s.dead += e.size
+	}
+}
+
+func (s *Store) SaveSchedule(ctx context.Context, sched store.Schedule) error {
+	value, err := json.Marshal(sched)
+	if err != nil {
+		return err
+	}
+	b := encodeRecord(opPutSchedule, "", sched.ID, value)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	if err := s.upgrade(2); err != nil {
+		return err
+	}
+	off, err := s.appendRecord(b)
+	if err != nil {
+		return err
+	}
+	s.putSchedule(sched.ID, entry{off: off, size: int64(len(b))})
+	return nil
+}
+
+func (s *Store) Schedules(ctx context.Context) ([]store.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.f == nil {
+		return nil, os.ErrClosed
+	}
+	scheds := make([]store.Schedule, 0, len(s.schedules))
+	for _, e := range s.schedules {
+		r := bufio.NewReader(io.NewSectionReader(s.f, e.off, e.size))
+		_, _, _, value, _, err := readRecord(r)
+		if err != nil {
+			return nil, fmt.Errorf("bolt: %s at %d: %w", s.name, e.off, err)
+		}
+		var sched store.Schedule
+		if err := json.Unmarshal(value, &sched); err != nil {
+			return nil, err
+		}
+		scheds = append(scheds, sched)
+	}
+	slices.SortFunc(scheds, func(a, b store.Schedule) int { return strings.Compare(a.ID, b.ID) })
+	return scheds, nil
+}
+
+func (s *Store) DeleteSchedule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	if _, ok := s.schedules[id]; !ok {
+		return fmt.Errorf("%w: schedule %s", store.ErrNotFound, id)
+	}
+	b := encodeRecord(opDeleteSchedule, "", id, nil)
+	if _, err := s.appendRecord(b); err != nil {
+		return err
+	}
+	s.removeSchedule(id)
+	s.dead += int64(len(b))
+	return nil
+}
+
+// Garbage returns the fraction of the file taken up by deleted and
+// replaced messages and schedules, which Compact would reclaim.
+func (s *Store) Garbage() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.size == 0 {
+		return 0
+	}
+	return float64(s.dead) / float64(s.size)
+}
+
+// Compact rewrites the file with only the live messages, bucket by bucket,
+// and schedules, replacing it atomically once the copy is on disk.
+func (s *Store) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.name), ".compact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := s.copyLive(ctx, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.name); err != nil {
+		return err
+	}
+	s.f.Close()
+	if s.f, err = os.OpenFile(s.name, os.O_RDWR, 0o600); err != nil {
+		s.f = nil
+		return err
+	}
+	return s.load()
+}
+
+func (s *Store) copyLive(ctx context.Context, w *os.File) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(header())
+	buckets := make([]string, 0, len(s.buckets))
+	for bucket := range s.buckets {
+		buckets = append(buckets, bucket)
+	}
+	slices.Sort(buckets)
+	for _, bucket := range buckets {
+		es := make([]entry, 0, len(s.buckets[bucket]))
+		for _, e := range s.buckets[bucket] {
+			es = append(es, e)
+		}
+		// Keep the order among messages created at the same time.
+		sort.Slice(es, func(i, j int) bool { return es[i].seq < es[j].seq })
+		for _, e := range es {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			b := make([]byte, e.size)
+			if _, err := s.f.ReadAt(b, e.off); err != nil {
+				return err
+			}
+			bw.Write(b)
+		}
+	}
+	ids := make([]string, 0, len(s.schedules))
+	for id := range s.schedules {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		e := s.schedules[id]
+		b := make([]byte, e.size)
+		if _, err := s.f.ReadAt(b, e.off); err != nil {
+			return err
+		}
+		bw.Write(b)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return w.Sync()
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	if s.temp {
+		if rerr := os.Remove(s.name); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package bolt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := message.NewMessage("Hello, Ada!")
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(ctx, m.ID); err != nil || got.Text != m.Text {
+		t.Fatalf("Get = %+v, %v, want %+v", got, err, m)
+	}
+	name := s.name
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("%s is left after Close: %v", name, err)
+	}
+
+	// Each Memory store is private.
+	other, err := Open(Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if ms, err := other.List(ctx, -1); err != nil || len(ms) != 0 {
+		t.Errorf("new Memory store lists %d messages, %v, want none", len(ms), err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), "messages.db")
+	s, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := message.NewMessage("Hello, Ada!")
+	if err := s.Save(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = Open(name); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, err := s.Get(ctx, m.ID); err != nil || got.Text != m.Text {
+		t.Errorf("Get after reopening = %+v, %v, want %+v", got, err, m)
+	}
+}
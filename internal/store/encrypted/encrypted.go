@@ -0,0 +1,148 @@
+// Package encrypted is a message store encrypting the text of the messages
+// kept in another, with the keys of a seal.Keyring.
+package encrypted
+
+import (
+	"context"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/seal"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Store keeps messages in an underlying store with their text sealed.
+// Their IDs, times, senders, severities and tags stay in the clear, for
+// the underlying store to search by; searching the text decrypts every
+// other match in memory. Messages stored before encryption was turned on
+// are read as they are, until Rotate seals them.
+type Store struct {
+	s    store.MessageStore
+	keys *seal.Keyring
+}
+
+var (
+	_ store.MessageStore = (*Store)(nil)
+	_ store.Wrapper      = (*Store)(nil)
+)
+
+// New returns a store keeping messages in s, encrypted with keys.
+func New(s store.MessageStore, keys *seal.Keyring) *Store {
+	return &Store{s: s, keys: keys}
+}
+
+// Unwrap returns the underlying store, for the interfaces it implements,
+// such as store.ScheduleStore. Schedules are not encrypted.
+func (s *Store) Unwrap() store.MessageStore {
+	return s.s
+}
+
+func (s *Store) Save(ctx context.Context, m message.Message) error {
+	sealed, err := s.keys.Seal(m)
+	if err != nil {
+		return err
+	}
+	return s.s.Save(ctx, sealed)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (message.Message, error) {
+	m, err := s.s.Get(ctx, id)
+	if err != nil {
+		return m, err
+	}
+	return s.keys.Open(m)
+}
+
+func (s *Store) List(ctx context.Context, limit int) ([]message.Message, error) {
+	ms, err := s.s.List(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(ms)
+}
+
+func (s *Store) Find(ctx context.Context, q store.Query) ([]message.Message, error) {
+	if q.Text == "" {
+		ms, err := s.s.Find(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		return s.open(ms)
+	}
+	inner := q
+	inner.Text, inner.Offset, inner.Limit = "", 0, 0
+	ms, err := s.s.Find(ctx, inner)
+	if err != nil {
+		return nil, err
+	}
+	if ms, err = s.open(ms); err != nil {
+		return nil, err
+	}
+	var matched []message.Message
+	for _, m := range ms {
+		if q.Match(m) {
+			matched = append(matched, m)
+		}
+	}
+	if q.Offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[q.Offset:]
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return matched, nil
+}
+
+func (s *Store) open(ms []message.Message) ([]message.Message, error) {
+	for i, m := range ms {
+		var err error
+		if ms[i], err = s.keys.Open(m); err != nil {
+			return nil, err
+		}
+	}
+	return ms, nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.s.Delete(ctx, id)
+}
+
+func (s *Store) Close() error {
+	return s.s.Close()
+}
+
+// Rotate seals every message not sealed with the primary key, those in
+// the clear included, with the primary key, and returns how many it
+// resealed. Once it is done, keys other than the primary one can be
+// retired.
+func (s *Store) Rotate(ctx context.Context) (int, error) {
+	ms, err := s.Unrotated(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range ms {
+		if m, err = s.keys.Open(m); err != nil {
+			return i, err
+		}
+		if err := s.Save(ctx, m); err != nil {
+			return i, err
+		}
+	}
+	return len(ms), nil
+}
+
+// Unrotated returns the messages Rotate would reseal, as stored, without
+// changing them.
+func (s *Store) Unrotated(ctx context.Context) ([]message.Message, error) {
+	ms, err := s.s.List(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	var unrotated []message.Message
+	for _, m := range ms {
+		if id, ok := seal.SealedWith(m); !ok || id != s.keys.Primary() {
+			unrotated = append(unrotated, m)
+		}
+	}
+	return unrotated, nil
+}
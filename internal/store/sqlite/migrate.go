@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+)
+
+// A migration is one version of the schema. Versions are numbered from 1
+// without gaps, and new columns need defaults so that rows written before
+// them still scan.
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create messages",
+		// Databases created before migrations were recorded already have
+		// the table.
+		up: `
+CREATE TABLE IF NOT EXISTS messages (
+	id         TEXT PRIMARY KEY,
+	created_at INTEGER NOT NULL,
+	sender     TEXT NOT NULL,
+	severity   TEXT NOT NULL,
+	tags       TEXT NOT NULL,
+	text       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_created_at ON messages (created_at);`,
+		down: `DROP TABLE messages`,
+	},
+	{
+		version: 2,
+		name:    "create schedules",
+		up: `
+CREATE TABLE schedules (
+	id         TEXT PRIMARY KEY,
+	cron       TEXT NOT NULL,
+	time_zone  TEXT NOT NULL DEFAULT '',
+	names_file TEXT NOT NULL,
+	style      TEXT NOT NULL DEFAULT '',
+	locale     TEXT NOT NULL DEFAULT '',
+	catch_up   TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	last_run   INTEGER NOT NULL DEFAULT 0
+)`,
+		down: `DROP TABLE schedules`,
+	},
+}
+
+const migrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`
+
+func (s *Store) LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, s.db)
+}
+
+func schemaVersion(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}) (int, error) {
+	var version int
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// A Step is one migration MigrateTo applies, or reverts if Down is set,
+// with the statements it runs.
+type Step struct {
+	Version int
+	Name    string
+	Down    bool
+	SQL     string
+}
+
+// steps returns the steps from schema version current to version, in the
+// order they are taken.
+func steps(current, version int) []Step {
+	var ss []Step
+	for _, m := range migrations {
+		if m.version > current && m.version <= version {
+			ss = append(ss, Step{Version: m.version, Name: m.name, SQL: m.up})
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if m := migrations[i]; m.version <= current && m.version > version {
+			ss = append(ss, Step{Version: m.version, Name: m.name, Down: true, SQL: m.down})
+		}
+	}
+	return ss
+}
+
+// MigrateTo applies the up or down migrations between the current version
+// and version in one transaction, so a failed step leaves the schema as it
+// was. Downgrading below the latest version leaves the store unusable
+// until it is upgraded again.
+func (s *Store) MigrateTo(ctx context.Context, version int) error {
+	if version < 0 || version > s.LatestVersion() {
+		return fmt.Errorf("sqlite: no schema version %d", version)
+	}
+	if _, err := s.db.ExecContext(ctx, migrationsTable); err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	current, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if current > s.LatestVersion() {
+		return fmt.Errorf("%w: version %d, want at most %d", store.ErrSchemaTooNew, current, s.LatestVersion())
+	}
+	for _, step := range steps(current, version) {
+		if _, err := tx.ExecContext(ctx, step.SQL); err != nil {
+			if step.Down {
+				return fmt.Errorf("sqlite: reverting migration %d (%s): %w", step.Version, step.Name, err)
+			}
+			return fmt.Errorf("sqlite: migration %d (%s): %w", step.Version, step.Name, err)
+		}
+		if step.Down {
+			_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, step.Version)
+		} else {
+			_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				step.Version, step.Name, time.Now().Unix())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Plan returns the schema version of the database in the file name and the
+// steps MigrateTo would take from it to version, the latest if version is
+// negative. Unlike Open, it upgrades nothing, and a missing file is taken
+// for an empty database rather than created.
+func Plan(ctx context.Context, name string, version int) (current int, ss []Step, err error) {
+	latest := migrations[len(migrations)-1].version
+	if version < 0 {
+		version = latest
+	}
+	if version > latest {
+		return 0, nil, fmt.Errorf("sqlite: no schema version %d", version)
+	}
+	if !slices.Contains(sql.Drivers(), DriverName) {
+		return 0, nil, fmt.Errorf("sqlite: no %q database/sql driver is linked into this program", DriverName)
+	}
+	if _, err := os.Stat(name); name != Memory && errors.Is(err, fs.ErrNotExist) {
+		return 0, steps(0, version), nil
+	}
+	db, err := sql.Open(DriverName, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer db.Close()
+	var tables int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables); err != nil {
+		return 0, nil, fmt.Errorf("sqlite: %s: %w", name, err)
+	}
+	if tables > 0 {
+		if current, err = schemaVersion(ctx, db); err != nil {
+			return 0, nil, fmt.Errorf("sqlite: %s: %w", name, err)
+		}
+	}
+	if current > latest {
+		return current, nil, fmt.Errorf("%w: version %d, want at most %d", store.ErrSchemaTooNew, current, latest)
+	}
+	return current, steps(current, version), nil
+}
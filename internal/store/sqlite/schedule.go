@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+)
+
+const scheduleColumns = `id, cron, time_zone, names_file, style, locale, catch_up, created_at, last_run`
+
+// Schedules are saved rarely, so their statements are not prepared.
+
+func (s *Store) SaveSchedule(ctx context.Context, sched store.Schedule) error {
+	var lastRun int64
+	if !sched.LastRun.IsZero() {
+		lastRun = sched.LastRun.UnixNano()
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO schedules (`+scheduleColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sched.ID, sched.Cron, sched.TimeZone, sched.To, sched.Style, sched.Locale, sched.CatchUp, sched.CreatedAt.UnixNano(), lastRun)
+	return err
+}
+
+func (s *Store) Schedules(ctx context.Context) ([]store.Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+scheduleColumns+` FROM schedules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var scheds []store.Schedule
+	for rows.Next() {
+		var sched store.Schedule
+		var created, lastRun int64
+		if err := rows.Scan(&sched.ID, &sched.Cron, &sched.TimeZone, &sched.To, &sched.Style, &sched.Locale, &sched.CatchUp, &created, &lastRun); err != nil {
+			return nil, err
+		}
+		sched.CreatedAt = time.Unix(0, created)
+		if lastRun != 0 {
+			sched.LastRun = time.Unix(0, lastRun)
+		}
+		scheds = append(scheds, sched)
+	}
+	return scheds, rows.Err()
+}
+
+func (s *Store) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: schedule %s", store.ErrNotFound, id)
+	}
+	return nil
+}
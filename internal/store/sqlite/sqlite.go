@@ -0,0 +1,229 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// DriverName is the database/sql driver the store opens databases with.
+// The standard library has no SQLite driver, so one must be linked in by
+// the program, such as modernc.org/sqlite, which registers as "sqlite".
+var DriverName = "sqlite"
+
+// Memory is the name that opens a private in-memory database, as used in
+// tests. It is gone once the store is closed.
+const Memory = ":memory:"
+
+const columns = `id, created_at, sender, severity, tags, text`
+
+// Store is a store.MessageStore in a SQLite database.
+type Store struct {
+	db *sql.DB
+
+	save, get, list, listAll, del *sql.Stmt
+}
+
+var (
+	_ store.MessageStore  = (*Store)(nil)
+	_ store.Migrator      = (*Store)(nil)
+	_ store.ScheduleStore = (*Store)(nil)
+)
+
+// Open opens the database in the file name, creating it if needed, or an
+// in-memory database for Memory, and upgrades its schema. File databases
+// use write-ahead logging so that reads do not wait for writes.
+func Open(name string) (*Store, error) {
+	if !slices.Contains(sql.Drivers(), DriverName) {
+		return nil, fmt.Errorf("sqlite: no %q database/sql driver is linked into this program", DriverName)
+	}
+	db, err := sql.Open(DriverName, name)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db}
+	if err := s.init(name); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: %s: %w", name, err)
+	}
+	return s, nil
+}
+
+func (s *Store) init(name string) error {
+	if name == Memory {
+		// Every connection would get its own empty database.
+		s.db.SetMaxOpenConns(1)
+	} else if _, err := s.db.Exec(`PRAGMA journal_mode = WAL; PRAGMA busy_timeout = 5000`); err != nil {
+		return err
+	}
+	if err := store.Migrate(context.Background(), s); err != nil {
+		return err
+	}
+	for _, p := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.save, `INSERT OR REPLACE INTO messages (` + columns + `) VALUES (?, ?, ?, ?, ?, ?)`},
+		{&s.get, `SELECT ` + columns + ` FROM messages WHERE id = ?`},
+		// The subquery's rows have no rowid of their own, so it selects
+		// the table's for the outer order.
+		{&s.list, `SELECT ` + columns + ` FROM (SELECT ` + columns + `, rowid AS seq FROM messages ORDER BY created_at DESC, rowid DESC LIMIT ?) ORDER BY created_at, seq`},
+		{&s.listAll, `SELECT ` + columns + ` FROM messages ORDER BY created_at, rowid`},
+		{&s.del, `DELETE FROM messages WHERE id = ?`},
+	} {
+		stmt, err := s.db.Prepare(p.query)
+		if err != nil {
+			return err
+		}
+		*p.stmt = stmt
+	}
+	return nil
+}
+
+func (s *Store) Save(ctx context.Context, m message.Message) error {
+	tags, err := json.Marshal(m.Tags)
+	if err != nil {
+		return err
+	}
+	_, err = s.save.ExecContext(ctx, m.ID, m.CreatedAt.UnixNano(), m.Sender, m.Severity.String(), string(tags), m.Text)
+	return err
+}
+
+func (s *Store) Get(ctx context.Context, id string) (message.Message, error) {
+	m, err := scan(s.get.QueryRowContext(ctx, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, fmt.Errorf("%w: %s", store.ErrNotFound, id)
+	}
+	return m, err
+}
+
+func (s *Store) List(ctx context.Context, limit int) ([]message.Message, error) {
+	var rows *sql.Rows
+	var err error
+	if limit < 0 {
+		rows, err = s.listAll.QueryContext(ctx)
+	} else {
+		rows, err = s.list.QueryContext(ctx, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanAll(rows)
+}
+
+func scanAll(rows *sql.Rows) ([]message.Message, error) {
+	defer rows.Close()
+	var ms []message.Message
+	for rows.Next() {
+		m, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return ms, rows.Err()
+}
+
+// Find builds the query from q, so unlike the other methods it does not
+// use a prepared statement.
+func (s *Store) Find(ctx context.Context, q store.Query) ([]message.Message, error) {
+	var where []string
+	var args []any
+	if q.Sender != "" {
+		where = append(where, "sender = ?")
+		args = append(args, q.Sender)
+	}
+	if q.MinSeverity > message.SeverityInfo {
+		var in []string
+		for sev := q.MinSeverity; sev <= message.SeverityError; sev++ {
+			in = append(in, "?")
+			args = append(args, sev.String())
+		}
+		where = append(where, "severity IN ("+strings.Join(in, ", ")+")")
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+	for _, tag := range q.Tags {
+		// Tags are kept as a JSON array, in which a tag is its JSON
+		// string; instr, unlike LIKE, matches case.
+		quoted, _ := json.Marshal(tag)
+		where = append(where, "instr(tags, ?) > 0")
+		args = append(args, string(quoted))
+	}
+	for _, term := range q.Terms() {
+		where = append(where, `text LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(term)+"%")
+	}
+	query := `SELECT ` + columns + ` FROM messages`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if q.Order == store.NewestFirst {
+		query += ` ORDER BY created_at DESC, rowid DESC`
+	} else {
+		query += ` ORDER BY created_at, rowid`
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, max(q.Offset, 0))
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAll(rows)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// scan reads a row of the columns above.
+func scan(row interface{ Scan(...any) error }) (message.Message, error) {
+	var m message.Message
+	var created int64
+	var severity, tags string
+	if err := row.Scan(&m.ID, &created, &m.Sender, &severity, &tags, &m.Text); err != nil {
+		return m, err
+	}
+	m.CreatedAt = time.Unix(0, created)
+	if err := m.Severity.UnmarshalText([]byte(severity)); err != nil {
+		return m, err
+	}
+	return m, json.Unmarshal([]byte(tags), &m.Tags)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	res, err := s.del.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", store.ErrNotFound, id)
+	}
+	return nil
+}
+
+// Close closes the statements and the database.
+func (s *Store) Close() error {
+	for _, stmt := range []*sql.Stmt{s.save, s.get, s.list, s.listAll, s.del} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// ErrNotFound is returned by Get and Delete for an unknown message ID,
+// and by DeleteSchedule for an unknown schedule ID.
+var ErrNotFound = errors.New("message not found")
+
+// MessageStore keeps messages beyond the life of the process.
+type MessageStore interface {
+	// Save stores m, replacing any message with the same ID.
+	Save(ctx context.Context, m message.Message) error
+	Get(ctx context.Context, id string) (message.Message, error)
+	// List returns up to limit of the newest messages, oldest first, or
+	// all of them if limit is negative.
+	List(ctx context.Context, limit int) ([]message.Message, error)
+	// Find returns the messages matching q, in q's order.
+	Find(ctx context.Context, q Query) ([]message.Message, error)
+	Delete(ctx context.Context, id string) error
+	Close() error
+}
+
+// Order is the order Find returns messages in.
+type Order int
+
+const (
+	OldestFirst Order = iota
+	NewestFirst
+)
+
+// Query selects messages for Find. Its zero value matches every message,
+// oldest first.
+type Query struct {
+	// Sender, if set, must match exactly.
+	Sender string
+	// MinSeverity excludes less severe messages.
+	MinSeverity message.Severity
+	// Messages must be created in [Since, Until); zero times are unbounded.
+	Since, Until time.Time
+	// Text holds search terms that must each occur in a message's text,
+	// ignoring case. SQLite folds the case of ASCII letters only.
+	Text string
+	// Tags must each be among a message's tags, exactly.
+	Tags  []string
+	Order Order
+	// Offset skips that many matches and Limit, if positive, caps the
+	// number returned, for paging through results.
+	Offset, Limit int
+}
+
+// Terms splits q.Text into the terms a message must contain.
+func (q Query) Terms() []string {
+	return strings.Fields(q.Text)
+}
+
+// Match reports whether m satisfies every condition of q, for stores that
+// filter messages themselves.
+func (q Query) Match(m message.Message) bool {
+	if q.Sender != "" && m.Sender != q.Sender || m.Severity < q.MinSeverity {
+		return false
+	}
+	if !q.Since.IsZero() && m.CreatedAt.Before(q.Since) || !q.Until.IsZero() && !m.CreatedAt.Before(q.Until) {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !m.HasTag(tag) {
+			return false
+		}
+	}
+	text := strings.ToLower(m.Text)
+	for _, term := range q.Terms() {
+		if !strings.Contains(text, strings.ToLower(term)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Schedule is a greeting sent on a cron schedule, as greeter schedule
+// keeps them.
+type Schedule struct {
+	ID string `json:"id"`
+	// Cron is the cron expression of the times it is sent at.
+	Cron string `json:"cron"`
+	// TimeZone is the location Cron is read in, or the local one if empty.
+	TimeZone string `json:"time_zone,omitempty"`
+	// To is the file of names greeted, read for each run.
+	To     string `json:"to"`
+	Style  string `json:"style,omitempty"`
+	Locale string `json:"locale,omitempty"`
+	// CatchUp is what is done about runs missed while no scheduler ran:
+	// skip, once or all.
+	CatchUp   string    `json:"catch_up,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastRun is the time of the last run that was sent, or skipped.
+	LastRun time.Time `json:"last_run"`
+}
+
+// ScheduleStore is implemented by stores that also keep schedules.
+type ScheduleStore interface {
+	// SaveSchedule stores s, replacing any schedule with the same ID.
+	SaveSchedule(ctx context.Context, s Schedule) error
+	// Schedules returns every schedule, by ID.
+	Schedules(ctx context.Context) ([]Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+}
+
+// ErrSchemaTooNew is returned when a store was written by a newer version
+// of the program, whose schema this one does not know.
+var ErrSchemaTooNew = errors.New("store schema is newer than this program supports")
+
+// Migrator is implemented by stores with a versioned schema. Opening such a
+// store upgrades it to the latest version.
+type Migrator interface {
+	// SchemaVersion returns the version the store's schema is at, 0 for an
+	// empty store.
+	SchemaVersion(ctx context.Context) (int, error)
+	// MigrateTo upgrades or downgrades the schema to version, recording
+	// each step applied.
+	MigrateTo(ctx context.Context, version int) error
+	// LatestVersion is the newest schema version the store knows.
+	LatestVersion() int
+}
+
+// Wrapper is implemented by stores that add to another store, such as by
+// encrypting its messages.
+type Wrapper interface {
+	Unwrap() MessageStore
+}
+
+// As returns s, or the first store s wraps, as a T, such as a
+// ScheduleStore, and reports whether there is one.
+func As[T any](s MessageStore) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		w, ok := s.(Wrapper)
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// Migrate upgrades s to its latest schema version if it has a versioned
+// schema, and does nothing otherwise.
+func Migrate(ctx context.Context, s MessageStore) error {
+	m, ok := As[Migrator](s)
+	if !ok {
+		return nil
+	}
+	return m.MigrateTo(ctx, m.LatestVersion())
+}
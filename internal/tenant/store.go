@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Store is the part of an underlying store that belongs to one tenant. The
+// messages saved to it are made the tenant's, and those of other tenants
+// or of none are not found in it.
+//
+// It does not unwrap to the underlying store, which would hand the whole
+// of it, such as its schedules, to whoever holds the tenant's part.
+type Store struct {
+	s    store.MessageStore
+	name string
+	tag  string
+}
+
+var _ store.MessageStore = (*Store)(nil)
+
+// NewStore returns the part of s that belongs to the tenant name.
+func NewStore(s store.MessageStore, name string) *Store {
+	return &Store{s: s, name: name, tag: Tag + name}
+}
+
+func (s *Store) Save(ctx context.Context, m message.Message) error {
+	// A message whose ID another tenant uses would replace that tenant's.
+	old, err := s.s.Get(ctx, m.ID)
+	switch {
+	case err == nil && Of(old) != s.name:
+		return fmt.Errorf("message %s belongs to another tenant", m.ID)
+	case err != nil && !errors.Is(err, store.ErrNotFound):
+		return err
+	}
+	return s.s.Save(ctx, Set(m, s.name))
+}
+
+func (s *Store) Get(ctx context.Context, id string) (message.Message, error) {
+	m, err := s.s.Get(ctx, id)
+	if err == nil && Of(m) != s.name {
+		return message.Message{}, fmt.Errorf("%w: %s", store.ErrNotFound, id)
+	}
+	return m, err
+}
+
+func (s *Store) List(ctx context.Context, limit int) ([]message.Message, error) {
+	if limit < 0 {
+		return s.s.Find(ctx, store.Query{Tags: []string{s.tag}})
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	ms, err := s.s.Find(ctx, store.Query{Tags: []string{s.tag}, Order: store.NewestFirst, Limit: limit})
+	slices.Reverse(ms)
+	return ms, err
+}
+
+func (s *Store) Find(ctx context.Context, q store.Query) ([]message.Message, error) {
+	q.Tags = append(slices.Clip(q.Tags), s.tag)
+	return s.s.Find(ctx, q)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.s.Delete(ctx, id)
+}
+
+func (s *Store) Close() error {
+	return s.s.Close()
+}
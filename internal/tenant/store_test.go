@@ -0,0 +1,93 @@
+package tenant_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/store/bolt"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/tenant"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// TestStoreIsolation checks that each tenant's part of a shared store holds
+// its own messages only.
+func TestStoreIsolation(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	acme, globex := tenant.NewStore(db, "acme"), tenant.NewStore(db, "globex")
+	msg := func(id, text string, tags ...string) message.Message {
+		m := message.NewMessage(text)
+		m.ID, m.Tags = id, tags
+		return m
+	}
+	for _, save := range []struct {
+		s *tenant.Store
+		m message.Message
+	}{
+		{acme, msg("a1", "for acme")},
+		{acme, msg("a2", "for acme too", "tenant:globex")},
+		{globex, msg("g1", "for globex")},
+	} {
+		if err := save.s.Save(ctx, save.m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Save(ctx, msg("n1", "for no tenant")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		s       *tenant.Store
+		visible []string
+		hidden  []string
+	}{
+		{"acme", acme, []string{"a1", "a2"}, []string{"g1", "n1"}},
+		{"globex", globex, []string{"g1"}, []string{"a1", "a2", "n1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, err := tt.s.List(ctx, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, m := range ms {
+				ids = append(ids, m.ID)
+				if got := tenant.Of(m); got != tt.name {
+					t.Errorf("message %s belongs to %q, want %q", m.ID, got, tt.name)
+				}
+			}
+			if len(ids) != len(tt.visible) {
+				t.Errorf("List = %v, want %v", ids, tt.visible)
+			}
+			found, err := tt.s.Find(ctx, store.Query{})
+			if err != nil || len(found) != len(tt.visible) {
+				t.Errorf("Find = %d messages, %v; want %d", len(found), err, len(tt.visible))
+			}
+			for _, id := range tt.visible {
+				if _, err := tt.s.Get(ctx, id); err != nil {
+					t.Errorf("Get(%s) = %v", id, err)
+				}
+			}
+			for _, id := range tt.hidden {
+				if _, err := tt.s.Get(ctx, id); !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("Get(%s) = %v, want ErrNotFound", id, err)
+				}
+				if err := tt.s.Delete(ctx, id); !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("Delete(%s) = %v, want ErrNotFound", id, err)
+				}
+				if err := tt.s.Save(ctx, msg(id, "taken over")); err == nil {
+					t.Errorf("Save(%s) replaced a message of another tenant", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Package tenant scopes messages and settings to tenants, the teams that
+// share one deployment of the greeter.
+//
+// A message belongs to the tenant named by its Tag tag, or to no tenant,
+// the space shared by deployments that have none, if it has none. Stores
+// keep it as they keep every tag, so that tenants need no schema of their
+// own, and Store keeps each tenant to its own messages.
+package tenant
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/greeting"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Tag prefixes the tag naming a message's tenant.
+const Tag = "tenant:"
+
+// maxName bounds the length of tenant names, as DNS labels are bounded.
+const maxName = 63
+
+// Validate reports whether name is a valid tenant name: lowercase ASCII
+// letters, digits and hyphens, starting with a letter or digit.
+func Validate(name string) error {
+	if name == "" || len(name) > maxName || name[0] == '-' {
+		return fmt.Errorf("invalid tenant name %q: want up to %d lowercase letters, digits and hyphens", name, maxName)
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return fmt.Errorf("invalid tenant name %q: want up to %d lowercase letters, digits and hyphens", name, maxName)
+		}
+	}
+	return nil
+}
+
+// Of returns the name of the tenant m belongs to, or "" for none.
+func Of(m message.Message) string {
+	for _, tag := range m.Tags {
+		if name, ok := strings.CutPrefix(tag, Tag); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// Set returns m as belonging to the tenant name, or to none if name is
+// empty, dropping any tenant tag it had. Tags are copied if they change.
+func Set(m message.Message, name string) message.Message {
+	if Of(m) == name && (name == "" || countTenants(m) == 1) {
+		return m
+	}
+	tags := make([]string, 0, len(m.Tags)+1)
+	for _, tag := range m.Tags {
+		if !strings.HasPrefix(tag, Tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if name != "" {
+		tags = append(tags, Tag+name)
+	}
+	m.Tags = tags
+	return m
+}
+
+func countTenants(m message.Message) int {
+	n := 0
+	for _, tag := range m.Tags {
+		if strings.HasPrefix(tag, Tag) {
+			n++
+		}
+	}
+	return n
+}
+
+// Tenant is a tenant and its settings. The zero values of the settings
+// leave those of the deployment in place.
+type Tenant struct {
+	Name string
+	// Style names the registered greeter the tenant's greetings use when
+	// none is asked for.
+	Style string
+	// Locale is the locale of the tenant's greetings when none is asked
+	// for.
+	Locale string
+	// Template, if set, is the greeting.GreetTemplate template of the
+	// tenant's greetings when no style is asked for.
+	Template string
+	// Localizer, if set, has the tenant's translations over the bundled
+	// ones; see greeting.ContextWithLocalizer.
+	Localizer *greeting.Localizer
+	// Quota caps the messages the server records for the tenant.
+	Quota Quota
+	// Subjects are the authenticated identities that may act for the
+	// tenant.
+	Subjects []string
+}
+
+// Member reports whether the identity subject may act for t.
+func (t *Tenant) Member(subject string) bool {
+	return slices.Contains(t.Subjects, subject)
+}
+
+// Quota is a number of messages per period. The zero Quota is unlimited.
+type Quota struct {
+	Messages int
+	Per      time.Duration
+}
+
+// ParseQuota parses a quota written as N/h or N/d, for N messages an hour
+// or a day. An empty string or "off" is the zero Quota.
+func ParseQuota(s string) (Quota, error) {
+	if s == "" || s == "off" {
+		return Quota{}, nil
+	}
+	count, unit, _ := strings.Cut(s, "/")
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Quota{}, fmt.Errorf("invalid quota %q: want N/h or N/d", s)
+	}
+	switch unit {
+	case "h":
+		return Quota{n, time.Hour}, nil
+	case "d":
+		return Quota{n, 24 * time.Hour}, nil
+	}
+	return Quota{}, fmt.Errorf("invalid quota %q: unknown unit %q", s, unit)
+}
+
+func (q Quota) String() string {
+	switch q.Per {
+	case 0:
+		return "off"
+	case time.Hour:
+		return strconv.Itoa(q.Messages) + "/h"
+	}
+	return strconv.Itoa(q.Messages) + "/d"
+}
+
+type contextKey struct{}
+
+// ContextWithTenant returns ctx for work done for t.
+func ContextWithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant ctx does work for, or nil for none.
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(contextKey{}).(*Tenant)
+	return t
+}
+
+// NameFromContext returns the name of the tenant ctx does work for, or ""
+// for none.
+func NameFromContext(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.Name
+	}
+	return ""
+}
+
+// ErrUnknown is returned by Registry.Lookup for a tenant it does not have.
+var ErrUnknown = errors.New("unknown tenant")
+
+// Registry holds the tenants of a deployment.
+type Registry struct {
+	tenants map[string]*Tenant
+}
+
+// Load reads a registry from a file of "tenant setting value" lines:
+//
+//	acme style formal
+//	acme lang fr
+//	acme template Ahoj, {name}!
+//	acme locales acme-locales
+//	acme quota 1000/d
+//	acme subjects alice bob
+//
+// The value is the rest of the line. locales names a directory of
+// <locale>.json translation files, relative to the file's directory.
+// Blank lines and lines starting with # are ignored.
+func Load(name string) (*Registry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := Read(f, filepath.Dir(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return r, nil
+}
+
+// Read reads a registry written as Load reads it, with locales directories
+// relative to dir.
+func Read(r io.Reader, dir string) (*Registry, error) {
+	reg := &Registry{tenants: make(map[string]*Tenant)}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest, _ := strings.Cut(line, " ")
+		setting, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		value = strings.TrimSpace(value)
+		if err := Validate(name); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("line %d: want a tenant, a setting and a value", n)
+		}
+		t := reg.tenants[name]
+		if t == nil {
+			t = &Tenant{Name: name}
+			reg.tenants[name] = t
+		}
+		if err := t.set(setting, value, dir); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (t *Tenant) set(setting, value, dir string) error {
+	switch setting {
+	case "style":
+		t.Style = value
+	case "lang":
+		t.Locale = value
+	case "template":
+		t.Template = value
+	case "locales":
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		l := greeting.NewLocalizer(greeting.DetectLocale())
+		if err := l.LoadFS(os.DirFS(value), "."); err != nil {
+			return err
+		}
+		t.Localizer = l
+	case "quota":
+		q, err := ParseQuota(value)
+		if err != nil {
+			return err
+		}
+		t.Quota = q
+	case "subjects":
+		t.Subjects = append(t.Subjects, strings.Fields(value)...)
+	default:
+		return fmt.Errorf("unknown setting %q: want style, lang, template, locales, quota or subjects", setting)
+	}
+	return nil
+}
+
+// Lookup returns the tenant name.
+func (r *Registry) Lookup(name string) (*Tenant, error) {
+	if t, ok := r.tenants[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknown, name)
+}
+
+// Names lists the tenants, in order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.tenants))
+	for name := range r.tenants {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ForSubject returns the tenants the identity subject may act for, in
+// order.
+func (r *Registry) ForSubject(subject string) []*Tenant {
+	var ts []*Tenant
+	for _, name := range r.Names() {
+		if t := r.tenants[name]; t.Member(subject) {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
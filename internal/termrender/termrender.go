@@ -0,0 +1,432 @@
+package termrender
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/linerange"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/theme"
+)
+
+// Depth is how many colors a terminal can show.
+type Depth int
+
+const (
+	Depth16 Depth = iota
+	Depth256
+	DepthTrueColor
+)
+
+// ParseDepth parses 16, 256 or truecolor, also written 24bit.
+func ParseDepth(s string) (Depth, error) {
+	switch s {
+	case "16":
+		return Depth16, nil
+	case "256":
+		return Depth256, nil
+	case "truecolor", "24bit":
+		return DepthTrueColor, nil
+	}
+	return Depth16, fmt.Errorf("invalid color depth %q: want 16, 256 or truecolor", s)
+}
+
+func (d Depth) String() string {
+	return [...]string{"16", "256", "truecolor"}[d]
+}
+
+// DetectDepth guesses the color depth of the terminal from the COLORTERM
+// and TERM environment variables, as read by getenv, falling back to the 16
+// colors every color terminal has.
+func DetectDepth(getenv func(string) string) Depth {
+	switch getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return DepthTrueColor
+	}
+	term := getenv("TERM")
+	switch {
+	case strings.HasSuffix(term, "-direct") || strings.Contains(term, "truecolor"):
+		return DepthTrueColor
+	case strings.Contains(term, "256color"):
+		return Depth256
+	}
+	return Depth16
+}
+
+// Options control the escape sequences produced.
+type Options struct {
+	Depth Depth
+	// Theme colors the tokens. It defaults to theme.Dark. Its background
+	// and foreground are left to the terminal.
+	Theme *theme.Theme
+	// LineNumbers numbers the lines from StartLine, or 1 if it is zero.
+	LineNumbers bool
+	StartLine   int
+	// HighlightLines gives the lines with these numbers, counted from
+	// StartLine, the theme's highlight background, to the right edge of
+	// the terminal.
+	HighlightLines linerange.Set
+	// Lines is the number of lines in the source, which line numbers are
+	// padded to the width of. RenderTokens counts them if it is zero.
+	Lines int
+}
+
+// Render tokenizes src and writes it for a terminal, as RenderTokens does.
+func Render(w io.Writer, src []byte, opts Options) error {
+	return RenderTokens(w, src, lexer.Tokenize(src), opts)
+}
+
+// RenderReader tokenizes the source read from r and writes it for a
+// terminal, as RenderTokens does, but a token at a time as it is read, so
+// that sources too large to hold in memory can be rendered. Line numbers
+// are only as wide as opts.Lines makes them.
+func RenderReader(w io.Writer, r io.Reader, opts Options) error {
+	tw, err := NewWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	if err := lexer.TokenizeReaderText(r, tw.Text, tw.Token); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// RenderTokens writes src with each of toks, which must be in order and lie
+// within src, colored by its kind. Styles are reset at the end of every
+// line so that pagers and partial output stay readable. Control characters
+// other than tab and newline are written in caret notation, such as ^[, so
+// that src cannot send the terminal escape sequences of its own.
+func RenderTokens(w io.Writer, src []byte, toks []lexer.Token, opts Options) error {
+	if opts.Lines == 0 {
+		opts.Lines = bytes.Count(src, []byte("\n"))
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			opts.Lines++
+		}
+	}
+	tw, err := NewWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	off := 0
+	for _, t := range toks {
+		if t.Pos.Offset < off || t.End() > len(src) {
+			return fmt.Errorf("termrender: token %s at offset %d is out of order or past the source", t.Kind, t.Pos.Offset)
+		}
+		if err := tw.Text(string(src[off:t.Pos.Offset])); err != nil {
+			return err
+		}
+		if err := tw.Token(t); err != nil {
+			return err
+		}
+		off = t.End()
+	}
+	if err := tw.Text(string(src[off:])); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// bufferSize is how much output a Writer holds before writing it on.
+const bufferSize = 32 << 10
+
+// A Writer writes source for a terminal, as RenderTokens does, a piece at a
+// time: the source between tokens with Text, and each token with Token.
+// Output is written on to the underlying writer in blocks as it builds up,
+// so that a slow writer holds back the source too. It writes text a line at
+// a time, starting each line with its number and highlight as the options
+// ask.
+type Writer struct {
+	w    *errWriter
+	b    *bufio.Writer
+	opts Options
+	sgr  map[lexer.Kind]string
+	// line is the number of the current line, and start whether nothing
+	// of it has been written.
+	line  int
+	start bool
+	// width is the width of line numbers. numberSGR starts their style and
+	// highlightSGR the highlight background.
+	width        int
+	numberSGR    string
+	highlightSGR string
+	// highlighted is whether the current line is highlighted, and crlf
+	// whether a CR is to be written after it is filled.
+	highlighted bool
+	crlf        bool
+}
+
+// NewWriter returns a Writer writing to w, or an error if a style of the
+// theme cannot be written at opts.Depth.
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	th := opts.Theme
+	if th == nil {
+		th = theme.Dark
+	}
+	sgr := make(map[lexer.Kind]string, len(th.Styles))
+	for k, st := range th.Styles {
+		seq, err := styleSGR(st, opts.Depth)
+		if err != nil {
+			return nil, fmt.Errorf("termrender: %s style: %w", k, err)
+		}
+		sgr[k] = seq
+	}
+	ew := &errWriter{w: w}
+	tw := &Writer{w: ew, b: bufio.NewWriterSize(ew, bufferSize), opts: opts, sgr: sgr, line: opts.StartLine, start: true}
+	if tw.line == 0 {
+		tw.line = 1
+	}
+	if opts.LineNumbers {
+		tw.width = len(strconv.Itoa(tw.line + max(opts.Lines, 1) - 1))
+		var err error
+		if tw.numberSGR, err = styleSGR(th.LineNumbers, opts.Depth); err != nil {
+			return nil, fmt.Errorf("termrender: line number style: %w", err)
+		}
+	}
+	if th.Highlight != "" && len(opts.HighlightLines) > 0 {
+		var err error
+		if tw.highlightSGR, err = styleSGR(theme.Style{Background: th.Highlight}, opts.Depth); err != nil {
+			return nil, fmt.Errorf("termrender: highlight: %w", err)
+		}
+	}
+	return tw, nil
+}
+
+// Text writes s, source that is not part of any token, and returns the
+// error, if any, from writing on to the underlying writer.
+func (tw *Writer) Text(s string) error {
+	tw.text(s, "")
+	return tw.w.err
+}
+
+// Token writes t, which must follow the source written so far, colored by
+// its kind, and returns the error, if any, from writing on to the
+// underlying writer.
+func (tw *Writer) Token(t lexer.Token) error {
+	tw.text(t.Text, tw.sgr[t.Kind])
+	return tw.w.err
+}
+
+// Close ends the last line and flushes the output.
+func (tw *Writer) Close() error {
+	if !tw.start {
+		tw.endLine()
+	}
+	return tw.b.Flush()
+}
+
+// errWriter keeps the first error from writing to w, which a bufio.Writer
+// would otherwise only return from a later write.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	ew.err = err
+	return n, err
+}
+
+// text writes s styled by the escape sequence seq, one line at a time.
+func (tw *Writer) text(s, seq string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			if tw.start {
+				tw.startLine()
+			}
+			tw.endLine()
+			tw.b.WriteByte('\n')
+			tw.line++
+			tw.start = true
+		}
+		// A CR ending the line is part of a CRLF line ending.
+		line, crlf := strings.CutSuffix(line, "\r")
+		if line != "" {
+			if tw.start {
+				tw.startLine()
+			}
+			if tw.crlf {
+				// The CR was not part of a line ending after all.
+				tw.b.WriteByte('\r')
+				tw.crlf = false
+			}
+			if tw.highlighted {
+				// Reset sequences end the background too, so start it
+				// again with each piece of text.
+				writeLine(tw.b, line, tw.highlightSGR+seq)
+			} else {
+				writeLine(tw.b, line, seq)
+			}
+		}
+		if crlf && tw.highlighted {
+			// Write the CR after filling the line, which would otherwise
+			// erase it all.
+			tw.crlf = true
+		} else if crlf {
+			tw.b.WriteByte('\r')
+		}
+	}
+}
+
+func (tw *Writer) startLine() {
+	tw.start = false
+	tw.highlighted = tw.highlightSGR != "" && tw.opts.HighlightLines.Contains(tw.line)
+	if tw.opts.LineNumbers {
+		writeLine(tw.b, fmt.Sprintf("%*d ", tw.width, tw.line), tw.numberSGR)
+	}
+}
+
+// endLine fills the rest of a highlighted line with its background, then
+// writes the CR of its line ending, if any.
+func (tw *Writer) endLine() {
+	if tw.highlighted {
+		// Erasing to the end of the line fills it with the background.
+		tw.b.WriteString(tw.highlightSGR + "\x1b[K\x1b[0m")
+	}
+	if tw.crlf {
+		tw.b.WriteByte('\r')
+		tw.crlf = false
+	}
+}
+
+func writeLine(b *bufio.Writer, line, seq string) {
+	b.WriteString(seq)
+	for _, r := range line {
+		switch {
+		case r == 0x7f:
+			b.WriteString("^?")
+		case r < 0x20 && r != '\t':
+			b.WriteByte('^')
+			b.WriteRune(r + '@')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if seq != "" {
+		b.WriteString("\x1b[0m")
+	}
+}
+
+// styleSGR returns the escape sequence that starts st at depth, or "" for
+// the zero Style.
+func styleSGR(st theme.Style, depth Depth) (string, error) {
+	var params []string
+	if st.Bold {
+		params = append(params, "1")
+	}
+	if st.Italic {
+		params = append(params, "3")
+	}
+	if st.Underline {
+		params = append(params, "4")
+	}
+	for i, color := range []string{st.Color, st.Background} {
+		if color == "" {
+			continue
+		}
+		r, g, b, err := theme.RGB(color)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, rgb{int(r), int(g), int(b)}.sgr(depth, i == 1))
+	}
+	if len(params) == 0 {
+		return "", nil
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m", nil
+}
+
+type rgb struct{ r, g, b int }
+
+// sgr returns the SGR parameters for a foreground, or background, of c,
+// approximated by the nearest color the depth has.
+func (c rgb) sgr(depth Depth, background bool) string {
+	switch {
+	case depth == DepthTrueColor && background:
+		return fmt.Sprintf("48;2;%d;%d;%d", c.r, c.g, c.b)
+	case depth == DepthTrueColor:
+		return fmt.Sprintf("38;2;%d;%d;%d", c.r, c.g, c.b)
+	case depth == Depth256 && background:
+		return "48;5;" + strconv.Itoa(c.index256())
+	case depth == Depth256:
+		return "38;5;" + strconv.Itoa(c.index256())
+	case background:
+		return strconv.Itoa(c.index16() + 10)
+	}
+	return strconv.Itoa(c.index16())
+}
+
+// index16 returns the SGR color code of the basic color with c's hue, as
+// the nearest by distance turns most pastel colors white. Colors with
+// little saturation map to black, white or the grays between.
+func (c rgb) index16() int {
+	hi, lo := max(c.r, c.g, c.b), min(c.r, c.g, c.b)
+	if hi-lo < 40 {
+		switch {
+		case hi < 64:
+			return 30
+		case hi < 160:
+			return 90
+		case hi < 224:
+			return 37
+		}
+		return 97
+	}
+	var hue int
+	switch hi {
+	case c.r:
+		hue = 60 * (c.g - c.b) / (hi - lo)
+	case c.g:
+		hue = 120 + 60*(c.b-c.r)/(hi-lo)
+	default:
+		hue = 240 + 60*(c.r-c.g)/(hi-lo)
+	}
+	// Red, yellow, green, cyan, blue and magenta, every 60 degrees.
+	code := [6]int{31, 33, 32, 36, 34, 35}[(hue+390)/60%6]
+	if hi > 200 {
+		code += 60
+	}
+	return code
+}
+
+// index256 returns the nearest of the 6×6×6 color cube and the 24 grays of
+// the 256-color palette.
+func (c rgb) index256() int {
+	levels := [6]int{0, 95, 135, 175, 215, 255}
+	nearest := func(v int) int {
+		best := 0
+		for i, l := range levels {
+			if abs(v-l) < abs(v-levels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := nearest(c.r), nearest(c.g), nearest(c.b)
+	cube := rgb{levels[r], levels[g], levels[b]}
+	gray := min(max((c.r+c.g+c.b)/3-8, 0)/10, 23)
+	grayLevel := 8 + gray*10
+	if c.dist(rgb{grayLevel, grayLevel, grayLevel}) < c.dist(cube) {
+		return 232 + gray
+	}
+	return 16 + 36*r + 6*g + b
+}
+
+func (c rgb) dist(o rgb) int {
+	dr, dg, db := c.r-o.r, c.g-o.g, c.b-o.b
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
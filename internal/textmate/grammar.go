@@ -0,0 +1,400 @@
+package textmate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Grammar is a TextMate language grammar, as read from a .tmLanguage.json
+// file.
+//
+// Patterns are Oniguruma regular expressions, which Go's regexp only
+// partly supports. Load translates \h and \H, and emulates a lookahead at
+// the end of a pattern, as in \bfunc(?=\s*\(). Rules whose patterns still
+// do not compile, or that use while, are left out and listed in Skipped,
+// so that the rest of the grammar still applies.
+type Grammar struct {
+	Name      string
+	ScopeName string
+	FileTypes []string
+	// Skipped describes each rule left out, with the reason.
+	Skipped []string
+
+	// root groups the top-level patterns and repository.
+	root *rule
+	mu   sync.Mutex
+}
+
+// rawRule is a rule as written in the grammar file.
+type rawRule struct {
+	Include       string              `json:"include"`
+	Name          string              `json:"name"`
+	ContentName   string              `json:"contentName"`
+	Match         string              `json:"match"`
+	Begin         string              `json:"begin"`
+	End           string              `json:"end"`
+	While         string              `json:"while"`
+	Captures      map[string]rawScope `json:"captures"`
+	BeginCaptures map[string]rawScope `json:"beginCaptures"`
+	EndCaptures   map[string]rawScope `json:"endCaptures"`
+	Patterns      []*rawRule          `json:"patterns"`
+	Repository    map[string]*rawRule `json:"repository"`
+}
+
+type rawScope struct {
+	Name string `json:"name"`
+}
+
+type rawGrammar struct {
+	Name       string              `json:"name"`
+	ScopeName  string              `json:"scopeName"`
+	FileTypes  []string            `json:"fileTypes"`
+	Patterns   []*rawRule          `json:"patterns"`
+	Repository map[string]*rawRule `json:"repository"`
+}
+
+// rule is a compiled rule. A rule with neither match nor begin only groups
+// its patterns; one with include refers to another rule.
+type rule struct {
+	include     string
+	name        string
+	contentName string
+	match       *pattern
+	begin       *pattern
+	// end is the source of the end pattern, compiled for each begin match
+	// as it may refer back to the begin captures, as in \1.
+	end           string
+	captures      map[int]string
+	beginCaptures map[int]string
+	endCaptures   map[int]string
+	patterns      []*rule
+	// repo holds the rule's own repository, which includes in its
+	// patterns look in before the grammar's.
+	repo map[string]*rule
+
+	// target is the rule an include refers to, set by Parse. candidates
+	// are the match and begin rules that patterns expands to through
+	// groups and includes, listed on first use.
+	target     *rule
+	candidates []*rule
+}
+
+// Load reads a grammar from a .tmLanguage.json file.
+func Load(path string) (*Grammar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Parse reads a grammar in the JSON form of the TextMate format.
+func Parse(data []byte) (*Grammar, error) {
+	var raw rawGrammar
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw.ScopeName == "" {
+		return nil, fmt.Errorf("grammar has no scopeName")
+	}
+	g := &Grammar{Name: raw.Name, ScopeName: raw.ScopeName, FileTypes: raw.FileTypes}
+	g.root = &rule{patterns: g.compileAll(raw.Patterns, "patterns")}
+	g.root.repo = g.compileRepo(raw.Repository, "repository")
+	g.resolve(g.root, nil, map[*rule]bool{})
+	return g, nil
+}
+
+// resolve points the includes under r at their targets, looking names up
+// in the repositories of r and the rules around it, innermost first.
+func (g *Grammar) resolve(r *rule, repos []map[string]*rule, seen map[*rule]bool) {
+	if seen[r] {
+		return
+	}
+	seen[r] = true
+	if r.repo != nil {
+		repos = append(repos, r.repo)
+	}
+	switch {
+	case r.include == "$self" || r.include == "$base":
+		r.target = g.root
+	case strings.HasPrefix(r.include, "#"):
+		for i := len(repos) - 1; i >= 0 && r.target == nil; i-- {
+			r.target = repos[i][r.include[1:]]
+		}
+		if r.target == nil {
+			g.Skipped = append(g.Skipped, "include "+r.include+": no such repository rule")
+		}
+	case r.include != "":
+		g.Skipped = append(g.Skipped, "include "+r.include+": other grammars are not supported")
+	}
+	for _, p := range r.patterns {
+		g.resolve(p, repos, seen)
+	}
+	for _, name := range sortedKeys(r.repo) {
+		g.resolve(r.repo[name], repos, seen)
+	}
+}
+
+// candidatesOf returns the match and begin rules r's patterns expand to,
+// computing them on first use.
+func (g *Grammar) candidatesOf(r *rule) []*rule {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r.candidates == nil {
+		r.candidates = expand(r.patterns, map[*rule]bool{}, nil)
+		if r.candidates == nil {
+			r.candidates = []*rule{}
+		}
+	}
+	return r.candidates
+}
+
+func expand(rules []*rule, seen map[*rule]bool, out []*rule) []*rule {
+	for _, r := range rules {
+		// Follow includes of includes, giving up on a cycle of them.
+		for n := 0; r.target != nil && n < 100; n++ {
+			r = r.target
+		}
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		if r.match != nil || r.begin != nil {
+			out = append(out, r)
+		} else {
+			out = expand(r.patterns, seen, out)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]*rule) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (g *Grammar) compileAll(raws []*rawRule, where string) []*rule {
+	var rules []*rule
+	for i, raw := range raws {
+		if r := g.compile(raw, fmt.Sprintf("%s[%d]", where, i)); r != nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func (g *Grammar) compileRepo(raws map[string]*rawRule, where string) map[string]*rule {
+	if len(raws) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(raws))
+	for name := range raws {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	repo := make(map[string]*rule, len(raws))
+	for _, name := range names {
+		if r := g.compile(raws[name], where+"."+name); r != nil {
+			repo[name] = r
+		}
+	}
+	return repo
+}
+
+// compile compiles raw, found at where in the grammar, or returns nil and
+// records why it was skipped.
+func (g *Grammar) compile(raw *rawRule, where string) *rule {
+	if raw == nil {
+		return nil
+	}
+	skip := func(format string, args ...any) *rule {
+		g.Skipped = append(g.Skipped, where+": "+fmt.Sprintf(format, args...))
+		return nil
+	}
+	r := &rule{
+		include:     raw.Include,
+		name:        raw.Name,
+		contentName: raw.ContentName,
+		end:         raw.End,
+	}
+	var err error
+	switch {
+	case raw.While != "":
+		return skip("while rules are not supported")
+	case raw.Match != "":
+		if r.match, err = compilePattern(raw.Match); err != nil {
+			return skip("%v", err)
+		}
+	case raw.Begin != "":
+		if raw.End == "" {
+			return skip("begin without end")
+		}
+		if r.begin, err = compilePattern(raw.Begin); err != nil {
+			return skip("%v", err)
+		}
+		// Check the end pattern now, with any back-references filled
+		// with empty text, rather than fail at every match.
+		if _, err := compilePattern(expandBackrefs(raw.End, nil)); err != nil {
+			return skip("end: %v", err)
+		}
+	}
+	r.captures = captureNames(raw.Captures)
+	r.beginCaptures = captureNames(raw.BeginCaptures)
+	r.endCaptures = captureNames(raw.EndCaptures)
+	if r.begin != nil {
+		// Captures applies to both ends of a begin/end rule unless they
+		// have their own.
+		if r.beginCaptures == nil {
+			r.beginCaptures = r.captures
+		}
+		if r.endCaptures == nil {
+			r.endCaptures = r.captures
+		}
+	}
+	r.patterns = g.compileAll(raw.Patterns, where+".patterns")
+	r.repo = g.compileRepo(raw.Repository, where+".repository")
+	return r
+}
+
+func captureNames(raw map[string]rawScope) map[int]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	names := make(map[int]string, len(raw))
+	for k, v := range raw {
+		if i, err := strconv.Atoi(k); err == nil && v.Name != "" {
+			names[i] = v.Name
+		}
+	}
+	return names
+}
+
+// pattern is a compiled rule pattern.
+type pattern struct {
+	re *regexp.Regexp
+	// lookahead, if set, must match, or if negative must not, right after
+	// re does.
+	lookahead *regexp.Regexp
+	negative  bool
+	// anchored patterns start with ^ and only match at the start of a
+	// line.
+	anchored bool
+}
+
+func compilePattern(src string) (*pattern, error) {
+	src = translate(src)
+	p := &pattern{anchored: strings.HasPrefix(src, "^")}
+	// Lines are matched with their newline, before which $ matches in
+	// Oniguruma.
+	src = "(?m)" + src
+	if body, look, negative, ok := cutLookahead(src); ok {
+		la, err := regexp.Compile(`^(?m:` + look + `)`)
+		if err != nil {
+			return nil, err
+		}
+		p.lookahead, p.negative = la, negative
+		src = body
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+	return p, nil
+}
+
+// translate rewrites Oniguruma syntax that has a Go equivalent.
+func translate(src string) string {
+	var b strings.Builder
+	for i := 0; i < len(src); i++ {
+		if src[i] != '\\' || i+1 == len(src) {
+			b.WriteByte(src[i])
+			continue
+		}
+		switch src[i+1] {
+		case 'h':
+			b.WriteString(`[0-9A-Fa-f]`)
+		case 'H':
+			b.WriteString(`[^0-9A-Fa-f]`)
+		case 'Z':
+			b.WriteString(`$`)
+		default:
+			b.WriteString(src[i : i+2])
+		}
+		i++
+	}
+	return b.String()
+}
+
+// cutLookahead splits a pattern ending in (?=...) or (?!...) at the top
+// level into the pattern before it and the lookahead's contents.
+func cutLookahead(src string) (body, look string, negative, ok bool) {
+	if !strings.HasSuffix(src, ")") {
+		return "", "", false, false
+	}
+	depth := 0
+	for i := len(src) - 1; i >= 0; i-- {
+		// Skip escaped parentheses.
+		escaped := false
+		for j := i - 1; j >= 0 && src[j] == '\\'; j-- {
+			escaped = !escaped
+		}
+		if escaped {
+			continue
+		}
+		switch src[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			rest := src[i:]
+			if !strings.HasPrefix(rest, "(?=") && !strings.HasPrefix(rest, "(?!") {
+				return "", "", false, false
+			}
+			return src[:i], rest[3 : len(rest)-1], rest[2] == '!', true
+		}
+	}
+	return "", "", false, false
+}
+
+// expandBackrefs replaces \1 to \9 in an end pattern with the matching
+// captures of the begin match, quoted, as TextMate does.
+func expandBackrefs(end string, captures []string) string {
+	if !strings.Contains(end, `\`) {
+		return end
+	}
+	var b strings.Builder
+	for i := 0; i < len(end); i++ {
+		if end[i] == '\\' && i+1 < len(end) {
+			if d := end[i+1]; '1' <= d && d <= '9' {
+				if n := int(d - '0'); n < len(captures) {
+					b.WriteString(regexp.QuoteMeta(captures[n]))
+				}
+				i++
+				continue
+			}
+			b.WriteString(end[i : i+2])
+			i++
+			continue
+		}
+		b.WriteByte(end[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+// Package grammars bundles TextMate grammars for the languages of the
+// highlighting corpus that have no lexer of their own, so that its goldens
+// can be checked without grammars from outside this module. They cover
+// comments, strings, numbers and keywords rather than all of each language.
+package grammars
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/textmate"
+)
+
+//go:embed *.tmLanguage.json
+var files embed.FS
+
+// ByFileType returns the bundled grammars by the file types they are for,
+// such as tsx, parsed on first use. The map is shared, so it must not be
+// changed.
+func ByFileType() (map[string]*textmate.Grammar, error) {
+	return parsed()
+}
+
+var parsed = sync.OnceValues(func() (map[string]*textmate.Grammar, error) {
+	names, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	byType := map[string]*textmate.Grammar{}
+	for _, e := range names {
+		data, err := files.ReadFile(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		g, err := textmate.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		for _, t := range g.FileTypes {
+			byType[strings.TrimPrefix(t, ".")] = g
+		}
+	}
+	return byType, nil
+})
@@ -0,0 +1,58 @@
+package grammars
+
+import (
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// TestByFileType checks that every bundled grammar compiles whole, as Go's
+// regexp cannot take every Oniguruma pattern, and tokenizes the basics of
+// its language.
+func TestByFileType(t *testing.T) {
+	byType, err := ByFileType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range byType {
+		if len(g.Skipped) > 0 {
+			t.Errorf("%s skips rules: %v", g.ScopeName, g.Skipped)
+		}
+	}
+	for _, tt := range []struct {
+		fileType, src string
+		want          lexer.Token
+	}{
+		{"css", "a { color: #fff; width: 80%; }", lexer.Token{Kind: lexer.Number, Text: "80%"}},
+		{"css", "/* note */", lexer.Token{Kind: lexer.Comment, Text: "/* note */"}},
+		{"html", "<p class=\"x\">&amp;</p>", lexer.Token{Kind: lexer.String, Text: `"x"`}},
+		{"html", "<p>&amp;</p>", lexer.Token{Kind: lexer.Char, Text: "&amp;"}},
+		{"js", "const re = /a+b/g;", lexer.Token{Kind: lexer.String, Text: "/a+b/g"}},
+		{"js", "x = a / b / c;", lexer.Token{Kind: lexer.Operator, Text: "/"}},
+		{"jsx", "`Hi ${name}!`", lexer.Token{Kind: lexer.Ident, Text: "name"}},
+		{"jsx", "<p>{/* note */}</p>", lexer.Token{Kind: lexer.Comment, Text: "/* note */"}},
+		{"json", `{"key": null}`, lexer.Token{Kind: lexer.Keyword, Text: "null"}},
+		{"php", "<p><?php echo $name; ?></p>", lexer.Token{Kind: lexer.Ident, Text: "$name"}},
+		{"py", "def f():\n    return f\"{x}\"\n", lexer.Token{Kind: lexer.Ident, Text: "x"}},
+		{"py", "# note\n", lexer.Token{Kind: lexer.Comment, Text: "# note"}},
+		{"rb", "puts \"#{name}\"", lexer.Token{Kind: lexer.Ident, Text: "name"}},
+		{"rs", "let c = 'x';", lexer.Token{Kind: lexer.Char, Text: "'x'"}},
+		{"rs", "const N: u32 = 100_000;", lexer.Token{Kind: lexer.Number, Text: "100_000"}},
+		{"tsx", "type Id = string | number;", lexer.Token{Kind: lexer.Keyword, Text: "string"}},
+	} {
+		g, ok := byType[tt.fileType]
+		if !ok {
+			t.Errorf("no grammar for %s", tt.fileType)
+			continue
+		}
+		found := false
+		for _, tok := range g.Tokenize([]byte(tt.src), nil) {
+			if tok.Kind == tt.want.Kind && tok.Text == tt.want.Text {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s grammar: the tokens of %q have no %s %q", tt.fileType, tt.src, tt.want.Kind, tt.want.Text)
+		}
+	}
+}
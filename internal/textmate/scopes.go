@@ -0,0 +1,92 @@
+package textmate
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// ScopeMap maps scope names to token kinds. A scope takes the kind of its
+// longest prefix in the map, counted in dot-separated parts, so
+// keyword.operator.arithmetic.go takes the kind of keyword.operator over
+// that of keyword.
+type ScopeMap map[string]lexer.Kind
+
+// DefaultScopes maps the scope names TextMate grammars conventionally use.
+var DefaultScopes = ScopeMap{
+	"comment":            lexer.Comment,
+	"string":             lexer.String,
+	"string.regexp":      lexer.String,
+	"constant.character": lexer.Char,
+	"constant.numeric":   lexer.Number,
+	"constant.language":  lexer.Keyword,
+	"keyword":            lexer.Keyword,
+	"keyword.operator":   lexer.Operator,
+	"storage":            lexer.Keyword,
+	"punctuation":        lexer.Operator,
+	// Quotes and comment markers belong to their string or comment.
+	"punctuation.definition.string":  lexer.EOF,
+	"punctuation.definition.comment": lexer.EOF,
+	"entity.name":                    lexer.Ident,
+	"variable":                       lexer.Ident,
+	"support.function":               lexer.Ident,
+	"support.type":                   lexer.Keyword,
+	"invalid":                        lexer.Illegal,
+}
+
+// Kind returns the kind of tokens in scope, and false if no prefix of it is
+// mapped or the longest is mapped to EOF, meaning no kind.
+func (m ScopeMap) Kind(scope string) (lexer.Kind, bool) {
+	for {
+		if k, ok := m[scope]; ok {
+			return k, k != lexer.EOF
+		}
+		i := strings.LastIndexByte(scope, '.')
+		if i < 0 {
+			return 0, false
+		}
+		scope = scope[:i]
+	}
+}
+
+// LoadScopes reads a file with one "scope kind" mapping per line, such as
+// "storage.type ident", on top of DefaultScopes. The kind none maps a
+// scope to no kind, so that its tokens take the kind of an enclosing scope
+// instead: "punctuation.definition.string none" makes quotes part of their
+// strings. Blank lines and lines starting with # are ignored.
+func LoadScopes(name string) (ScopeMap, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := maps.Clone(DefaultScopes)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a scope and a kind", name, n)
+		}
+		if fields[1] == "none" {
+			m[fields[0]] = lexer.EOF
+			continue
+		}
+		k, ok := lexer.ParseKind(fields[1])
+		if !ok || k == lexer.EOF {
+			return nil, fmt.Errorf("%s:%d: unknown token kind %q", name, n, fields[1])
+		}
+		m[fields[0]] = k
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
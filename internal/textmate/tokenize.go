@@ -0,0 +1,271 @@
+package textmate
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// maxStalls bounds how often rules may begin or end without consuming text
+// at one position before the tokenizer skips a byte, so that a grammar
+// whose zero-width rules loop cannot hang it.
+const maxStalls = 16
+
+// frame is a begin/end rule being matched.
+type frame struct {
+	rule *rule
+	end  *pattern
+}
+
+// span is a run of source of one kind, ok if the kind is known.
+type span struct {
+	start, end int
+	kind       lexer.Kind
+	ok         bool
+}
+
+type tokenizer struct {
+	g      *Grammar
+	scopes ScopeMap
+	stack  []frame
+	spans  []span
+}
+
+// Tokenize applies g to src a line at a time, as TextMate does, and returns
+// a token for each run of text whose scopes map to a kind in scopes, the
+// innermost mapped scope deciding. Text no mapped scope covers, typically
+// whitespace, is between tokens. A nil scopes uses DefaultScopes.
+func (g *Grammar) Tokenize(src []byte, scopes ScopeMap) []lexer.Token {
+	if scopes == nil {
+		scopes = DefaultScopes
+	}
+	t := &tokenizer{g: g, scopes: scopes}
+	for off := 0; off < len(src); {
+		end := len(src)
+		if i := bytes.IndexByte(src[off:], '\n'); i >= 0 {
+			end = off + i + 1
+		}
+		t.line(string(src[off:end]), off)
+		off = end
+	}
+	return t.tokens(src)
+}
+
+// line tokenizes one line, including its newline, found at offset base.
+func (t *tokenizer) line(line string, base int) {
+	pos, stalls := 0, 0
+	for pos < len(line) {
+		var top *frame
+		candidates := t.g.candidatesOf(t.g.root)
+		if n := len(t.stack); n > 0 {
+			top = &t.stack[n-1]
+			candidates = t.g.candidatesOf(top.rule)
+		}
+		// The end pattern wins ties, as it does in TextMate.
+		best, bestLoc := (*rule)(nil), []int(nil)
+		if top != nil {
+			bestLoc = top.end.find(line, pos)
+		}
+		for _, r := range candidates {
+			p := r.match
+			if p == nil {
+				p = r.begin
+			}
+			if loc := p.find(line, pos); loc != nil && (bestLoc == nil || loc[0] < bestLoc[0]) {
+				best, bestLoc = r, loc
+			}
+		}
+		if bestLoc == nil {
+			t.emit(base+pos, base+len(line), t.content())
+			return
+		}
+		t.emit(base+pos, base+bestLoc[0], t.content())
+		switch {
+		case best == nil:
+			t.captureSpans(line, base, bestLoc, t.outer(), top.rule.name, top.rule.endCaptures)
+			t.stack = t.stack[:len(t.stack)-1]
+		case best.match != nil:
+			t.captureSpans(line, base, bestLoc, t.content(), best.name, best.captures)
+		default:
+			t.captureSpans(line, base, bestLoc, t.content(), best.name, best.beginCaptures)
+			captures := make([]string, len(bestLoc)/2)
+			for i := range captures {
+				if bestLoc[2*i] >= 0 {
+					captures[i] = line[bestLoc[2*i]:bestLoc[2*i+1]]
+				}
+			}
+			end, err := compilePattern(expandBackrefs(best.end, captures))
+			if err != nil {
+				// A capture made the end pattern invalid; end the rule at
+				// the end of the line rather than never.
+				end, _ = compilePattern(`$`)
+			}
+			t.stack = append(t.stack, frame{rule: best, end: end})
+		}
+		switch {
+		case bestLoc[1] > pos:
+			pos, stalls = bestLoc[1], 0
+		case best != nil && best.match != nil:
+			// An empty match would be found again at once.
+			pos = t.skip(line, base, pos)
+		default:
+			if stalls++; stalls > maxStalls {
+				pos, stalls = t.skip(line, base, pos), 0
+			}
+		}
+	}
+}
+
+// skip emits the character at pos in line with the current scopes and
+// returns the position after it.
+func (t *tokenizer) skip(line string, base, pos int) int {
+	_, size := utf8.DecodeRuneInString(line[pos:])
+	t.emit(base+pos, base+pos+size, t.content())
+	return pos + size
+}
+
+// content returns the scopes that apply inside the innermost rule being
+// matched, outermost first.
+func (t *tokenizer) content() []string {
+	scopes := t.outer()
+	if n := len(t.stack); n > 0 {
+		r := t.stack[n-1].rule
+		scopes = appendScope(scopes, r.name)
+		scopes = appendScope(scopes, r.contentName)
+	}
+	return scopes
+}
+
+// outer returns the scopes that apply around the innermost rule being
+// matched.
+func (t *tokenizer) outer() []string {
+	var scopes []string
+	for i := 0; i < len(t.stack)-1; i++ {
+		r := t.stack[i].rule
+		scopes = appendScope(scopes, r.name)
+		scopes = appendScope(scopes, r.contentName)
+	}
+	return scopes
+}
+
+func appendScope(scopes []string, name string) []string {
+	if name == "" {
+		return scopes
+	}
+	// A name may list several scopes.
+	return append(scopes, strings.Fields(name)...)
+}
+
+// captureSpans emits the match at loc in line with the scopes around it,
+// the rule's name, and the names of its capture groups for the text they
+// capture. Later groups, which nest inside earlier ones, take precedence.
+func (t *tokenizer) captureSpans(line string, base int, loc []int, around []string, name string, captures map[int]string) {
+	start, end := loc[0], loc[1]
+	if start == end {
+		return
+	}
+	scopes := appendScope(append([]string(nil), around...), name)
+	outer, ok := t.kind(scopes)
+	kinds := make([]span, end-start)
+	for i := range kinds {
+		kinds[i] = span{kind: outer, ok: ok}
+	}
+	for group := 0; 2*group < len(loc); group++ {
+		cname, named := captures[group]
+		gs, ge := loc[2*group], loc[2*group+1]
+		if !named || gs < 0 {
+			continue
+		}
+		k, ok := t.kind(appendScope(append([]string(nil), scopes...), cname))
+		for i := gs; i < ge; i++ {
+			kinds[i-start] = span{kind: k, ok: ok}
+		}
+	}
+	for i := 0; i < len(kinds); {
+		j := i + 1
+		for j < len(kinds) && kinds[j] == kinds[i] {
+			j++
+		}
+		t.spans = append(t.spans, span{start: base + start + i, end: base + start + j, kind: kinds[i].kind, ok: kinds[i].ok})
+		i = j
+	}
+}
+
+func (t *tokenizer) emit(start, end int, scopes []string) {
+	if start == end {
+		return
+	}
+	k, ok := t.kind(scopes)
+	t.spans = append(t.spans, span{start: start, end: end, kind: k, ok: ok})
+}
+
+// kind returns the kind of the innermost of scopes that is mapped.
+func (t *tokenizer) kind(scopes []string) (lexer.Kind, bool) {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if k, ok := t.scopes.Kind(scopes[i]); ok {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// tokens joins adjacent spans of the same kind into tokens and drops the
+// spans of no kind.
+func (t *tokenizer) tokens(src []byte) []lexer.Token {
+	var toks []lexer.Token
+	line, lineStart, scanned := 1, 0, 0
+	for i := 0; i < len(t.spans); {
+		s := t.spans[i]
+		j := i + 1
+		for j < len(t.spans) && t.spans[j].start == t.spans[j-1].end && t.spans[j].kind == s.kind && t.spans[j].ok == s.ok {
+			j++
+		}
+		end := t.spans[j-1].end
+		i = j
+		if !s.ok {
+			continue
+		}
+		for ; scanned < s.start; scanned++ {
+			if src[scanned] == '\n' {
+				line++
+				lineStart = scanned + 1
+			}
+		}
+		toks = append(toks, lexer.Token{
+			Kind: s.kind,
+			Text: string(src[s.start:end]),
+			Pos:  lexer.Pos{Offset: s.start, Line: line, Column: s.start - lineStart + 1},
+		})
+	}
+	return toks
+}
+
+// find returns the submatch indexes of the first match of p in line at or
+// after pos, as regexp's FindStringSubmatchIndex does, or nil.
+func (p *pattern) find(line string, pos int) []int {
+	if p.anchored && pos > 0 {
+		return nil
+	}
+	for from := pos; from <= len(line); {
+		loc := p.re.FindStringSubmatchIndex(line[from:])
+		if loc == nil {
+			return nil
+		}
+		for i := range loc {
+			if loc[i] >= 0 {
+				loc[i] += from
+			}
+		}
+		if p.lookahead == nil || p.lookahead.MatchString(line[loc[1]:]) != p.negative {
+			return loc
+		}
+		// The lookahead failed here; look for a later match.
+		from = loc[0] + 1
+		if p.anchored {
+			return nil
+		}
+	}
+	return nil
+}
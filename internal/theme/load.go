@@ -0,0 +1,202 @@
+package theme
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Load reads a theme from a .json, .yaml or .yml file. Both hold the same
+// fields; in YAML:
+//
+//	name: mine
+//	base: dark
+//	background: "#1e1e1e"
+//	foreground: "#d4d4d4"
+//	line_numbers: "#858585"
+//	highlight: "#264f78"
+//	styles:
+//	  keyword: "#569cd6 bold"
+//	  comment: "#6a9955 italic"
+//
+// A theme with a base starts from that bundled theme's colors and styles.
+// Styles, and the style of line numbers, are written as ParseStyle reads
+// them, the styles keyed by token kind; highlight is the background of
+// highlighted lines. YAML
+// values starting with # must be quoted, or they are comments.
+func Load(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	case ".yaml", ".yml":
+		err = parseYAML(data, &f)
+	default:
+		return nil, fmt.Errorf("%s: unknown theme format %q: want .json, .yaml or .yml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	t, err := f.theme()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// file is a theme as written in a file.
+type file struct {
+	Name        string            `json:"name"`
+	Base        string            `json:"base"`
+	Background  string            `json:"background"`
+	Foreground  string            `json:"foreground"`
+	LineNumbers string            `json:"line_numbers"`
+	Highlight   string            `json:"highlight"`
+	Styles      map[string]string `json:"styles"`
+}
+
+func (f *file) theme() (*Theme, error) {
+	t := &Theme{Name: f.Name, Styles: make(map[lexer.Kind]Style)}
+	if f.Base != "" {
+		base, ok := Builtin(f.Base)
+		if !ok {
+			return nil, fmt.Errorf("unknown base theme %q: want one of %s", f.Base, strings.Join(Names(), ", "))
+		}
+		t.Background, t.Foreground = base.Background, base.Foreground
+		t.LineNumbers, t.Highlight = base.LineNumbers, base.Highlight
+		maps.Copy(t.Styles, base.Styles)
+	}
+	if f.Background != "" {
+		t.Background = f.Background
+	}
+	if f.Foreground != "" {
+		t.Foreground = f.Foreground
+	}
+	if f.LineNumbers != "" {
+		st, err := ParseStyle(f.LineNumbers)
+		if err != nil {
+			return nil, fmt.Errorf("line_numbers: %w", err)
+		}
+		t.LineNumbers = st
+	}
+	if f.Highlight != "" {
+		t.Highlight = f.Highlight
+	}
+	for name, s := range f.Styles {
+		k, ok := lexer.ParseKind(name)
+		if !ok || k == lexer.EOF {
+			return nil, fmt.Errorf("styles: unknown token kind %q", name)
+		}
+		st, err := ParseStyle(s)
+		if err != nil {
+			return nil, fmt.Errorf("styles: %s: %w", name, err)
+		}
+		t.Styles[k] = st
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseYAML reads the subset of YAML a theme needs: top-level scalars and
+// a styles mapping of scalars one level below.
+func parseYAML(data []byte, f *file) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inStyles := false
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		line := strings.TrimSpace(text)
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected key: value", n)
+		}
+		key = strings.TrimSpace(key)
+		value, err := yamlScalar(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		nested := text[0] == ' ' || text[0] == '\t'
+		switch {
+		case nested && inStyles:
+			if value == "" {
+				return fmt.Errorf("line %d: %s: missing style; quote styles that start with #", n, key)
+			}
+			if f.Styles == nil {
+				f.Styles = make(map[string]string)
+			}
+			f.Styles[key] = value
+			continue
+		case nested:
+			return fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		inStyles = false
+		var field *string
+		switch key {
+		case "styles":
+			if value != "" {
+				return fmt.Errorf("line %d: styles: expected a mapping on the lines below", n)
+			}
+			inStyles = true
+			continue
+		case "name":
+			field = &f.Name
+		case "base":
+			field = &f.Base
+		case "background":
+			field = &f.Background
+		case "foreground":
+			field = &f.Foreground
+		case "line_numbers":
+			field = &f.LineNumbers
+		case "highlight":
+			field = &f.Highlight
+		default:
+			return fmt.Errorf("line %d: unknown key %q", n, key)
+		}
+		*field = value
+	}
+	return scanner.Err()
+}
+
+func yamlScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		quoted, err := strconv.QuotedPrefix(raw)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Unquote(quoted)
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(strings.ReplaceAll(raw[1:], "''", "  "), "'")
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		return strings.ReplaceAll(raw[1:end+1], "''", "'"), nil
+	case strings.HasPrefix(raw, "#"):
+		return "", nil
+	}
+	value, _, _ := strings.Cut(raw, " #")
+	return strings.TrimSpace(value), nil
+}
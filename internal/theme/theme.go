@@ -0,0 +1,203 @@
+package theme
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/lexer"
+)
+
+// Style is how a kind of token is shown. Colors are written #rrggbb, and
+// empty for the renderer's default.
+type Style struct {
+	Color      string
+	Background string
+	Bold       bool
+	Italic     bool
+	Underline  bool
+}
+
+// ParseStyle parses a style written as space-separated words: a #rrggbb
+// color, bg:#rrggbb for the background, and bold, italic or underline.
+// "#d73a49 bold" is a bold red.
+func ParseStyle(s string) (Style, error) {
+	var st Style
+	for _, word := range strings.Fields(s) {
+		switch {
+		case word == "bold":
+			st.Bold = true
+		case word == "italic":
+			st.Italic = true
+		case word == "underline":
+			st.Underline = true
+		case strings.HasPrefix(word, "bg:"):
+			if err := checkColor(word[3:]); err != nil {
+				return Style{}, err
+			}
+			st.Background = word[3:]
+		case strings.HasPrefix(word, "#"):
+			if err := checkColor(word); err != nil {
+				return Style{}, err
+			}
+			st.Color = word
+		default:
+			return Style{}, fmt.Errorf("unknown style %q: want #rrggbb, bg:#rrggbb, bold, italic or underline", word)
+		}
+	}
+	return st, nil
+}
+
+// String formats st as ParseStyle reads it.
+func (st Style) String() string {
+	var words []string
+	if st.Color != "" {
+		words = append(words, st.Color)
+	}
+	if st.Background != "" {
+		words = append(words, "bg:"+st.Background)
+	}
+	if st.Bold {
+		words = append(words, "bold")
+	}
+	if st.Italic {
+		words = append(words, "italic")
+	}
+	if st.Underline {
+		words = append(words, "underline")
+	}
+	return strings.Join(words, " ")
+}
+
+// RGB returns the components of a #rrggbb color.
+func RGB(color string) (r, g, b uint8, err error) {
+	if err := checkColor(color); err != nil {
+		return 0, 0, 0, err
+	}
+	v, _ := strconv.ParseUint(color[1:], 16, 32)
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), nil
+}
+
+func checkColor(color string) error {
+	if len(color) != 7 || color[0] != '#' || strings.Trim(color[1:], "0123456789abcdefABCDEF") != "" {
+		return fmt.Errorf("invalid color %q: want #rrggbb", color)
+	}
+	return nil
+}
+
+// Theme maps kinds of token to styles. Kinds without a style are shown in
+// the foreground color.
+type Theme struct {
+	Name       string
+	Background string
+	Foreground string
+	Styles     map[lexer.Kind]Style
+	// LineNumbers is the style of line numbers, and Highlight the
+	// background of highlighted lines, where renderers show them.
+	LineNumbers Style
+	Highlight   string
+}
+
+// Style returns the style of tokens of kind k.
+func (t *Theme) Style(k lexer.Kind) Style {
+	return t.Styles[k]
+}
+
+// Validate checks every color of t.
+func (t *Theme) Validate() error {
+	for _, c := range []string{t.Background, t.Foreground, t.LineNumbers.Color, t.LineNumbers.Background, t.Highlight} {
+		if c != "" {
+			if err := checkColor(c); err != nil {
+				return fmt.Errorf("theme %s: %w", t.Name, err)
+			}
+		}
+	}
+	for k, st := range t.Styles {
+		for _, c := range []string{st.Color, st.Background} {
+			if c != "" {
+				if err := checkColor(c); err != nil {
+					return fmt.Errorf("theme %s: %s: %w", t.Name, k, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	// Light has dark text on white, in the colors of GitHub's light theme.
+	Light = &Theme{
+		Name:       "light",
+		Background: "#ffffff",
+		Foreground: "#24292e",
+		Styles: map[lexer.Kind]Style{
+			lexer.Illegal:  {Color: "#b31d28", Underline: true},
+			lexer.Keyword:  {Color: "#d73a49", Bold: true},
+			lexer.Number:   {Color: "#005cc5"},
+			lexer.String:   {Color: "#032f62"},
+			lexer.Char:     {Color: "#032f62"},
+			lexer.Comment:  {Color: "#6a737d", Italic: true},
+			lexer.Operator: {Color: "#d73a49"},
+		},
+		LineNumbers: Style{Color: "#959da5"},
+		Highlight:   "#fffbdd",
+	}
+	// Dark has light text on near black, in the colors of One Dark.
+	Dark = &Theme{
+		Name:       "dark",
+		Background: "#282c34",
+		Foreground: "#abb2bf",
+		Styles: map[lexer.Kind]Style{
+			lexer.Illegal:  {Color: "#e06c75", Underline: true},
+			lexer.Keyword:  {Color: "#c678dd", Bold: true},
+			lexer.Number:   {Color: "#56b6c2"},
+			lexer.String:   {Color: "#98c379"},
+			lexer.Char:     {Color: "#98c379"},
+			lexer.Comment:  {Color: "#7f848e", Italic: true},
+			lexer.Operator: {Color: "#d19a66"},
+		},
+		LineNumbers: Style{Color: "#636d83"},
+		Highlight:   "#3e4451",
+	}
+	// HighContrast uses saturated colors on black, all well above the WCAG
+	// AAA contrast ratio, and marks errors without relying on color.
+	HighContrast = &Theme{
+		Name:       "high-contrast",
+		Background: "#000000",
+		Foreground: "#ffffff",
+		Styles: map[lexer.Kind]Style{
+			lexer.Illegal:  {Color: "#ffffff", Background: "#c00000", Bold: true, Underline: true},
+			lexer.Keyword:  {Color: "#ffff00", Bold: true},
+			lexer.Number:   {Color: "#00ffff"},
+			lexer.String:   {Color: "#00ff00"},
+			lexer.Char:     {Color: "#00ff00"},
+			lexer.Comment:  {Color: "#c0c0c0", Italic: true},
+			lexer.Operator: {Color: "#ff80ff"},
+		},
+		LineNumbers: Style{Color: "#c0c0c0"},
+		Highlight:   "#000080",
+	}
+)
+
+var builtin = map[string]*Theme{
+	Light.Name:        Light,
+	Dark.Name:         Dark,
+	HighContrast.Name: HighContrast,
+}
+
+// Builtin returns the bundled theme with the given name.
+func Builtin(name string) (*Theme, bool) {
+	t, ok := builtin[name]
+	return t, ok
+}
+
+// Names returns the names of the bundled themes, sorted.
+func Names() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,288 @@
+//go:build !js
+
+// The exporters and HTTP propagation are left out of browser builds,
+// which trace in-process only and would otherwise carry all of net/http.
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewTracerFromEnv builds a tracer from the standard OpenTelemetry
+// environment variables. It returns nil, leaving tracing off, unless
+// OTEL_TRACES_EXPORTER is set to otlp or console, or an OTLP endpoint is
+// configured.
+//
+// Supported variables: OTEL_TRACES_EXPORTER, OTEL_SERVICE_NAME,
+// OTEL_RESOURCE_ATTRIBUTES, OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG,
+// OTEL_EXPORTER_OTLP_{,TRACES_}ENDPOINT, _HEADERS, _TIMEOUT and _PROTOCOL.
+// Only the http/json OTLP protocol is available.
+func NewTracerFromEnv(defaultService string, console io.Writer) (*Tracer, error) {
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "" && (os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "") {
+		exporter = "otlp"
+	}
+	s, err := samplerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	res := resourceFromEnv(defaultService)
+	var e Exporter
+	switch exporter {
+	case "", "none":
+		return nil, nil
+	case "console":
+		e = &consoleExporter{w: console}
+	case "otlp":
+		if e, err = otlpExporterFromEnv(res); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER: unsupported exporter %q", exporter)
+	}
+	t := NewTracer(e)
+	t.sampler = s
+	return t, nil
+}
+
+func samplerFromEnv() (sampler, error) {
+	name := os.Getenv("OTEL_TRACES_SAMPLER")
+	ratio := 1.0
+	if arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); arg != "" && strings.HasSuffix(name, "traceidratio") {
+		var err error
+		if ratio, err = strconv.ParseFloat(arg, 64); err != nil || ratio < 0 || ratio > 1 {
+			return sampler{}, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG: want a ratio between 0 and 1, got %q", arg)
+		}
+	}
+	switch name {
+	case "", "parentbased_always_on":
+		return sampler{ratio: 1, parentBased: true}, nil
+	case "always_on":
+		return sampler{ratio: 1}, nil
+	case "always_off":
+		return sampler{ratio: 0}, nil
+	case "parentbased_always_off":
+		return sampler{ratio: 0, parentBased: true}, nil
+	case "traceidratio":
+		return sampler{ratio: ratio}, nil
+	case "parentbased_traceidratio":
+		return sampler{ratio: ratio, parentBased: true}, nil
+	}
+	return sampler{}, fmt.Errorf("OTEL_TRACES_SAMPLER: unsupported sampler %q", name)
+}
+
+// resourceFromEnv returns the resource attributes describing this process.
+func resourceFromEnv(defaultService string) []Attr {
+	attrs := []Attr{String("service.name", defaultService)}
+	for _, pair := range splitList(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		key, value, _ := strings.Cut(pair, "=")
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		attrs = setAttr(attrs, strings.TrimSpace(key), value)
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		attrs = setAttr(attrs, "service.name", name)
+	}
+	return attrs
+}
+
+func setAttr(attrs []Attr, key, value string) []Attr {
+	for i := range attrs {
+		if attrs[i].Key == key {
+			attrs[i].Value = value
+			return attrs
+		}
+	}
+	return append(attrs, String(key, value))
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// consoleExporter writes one JSON object per span, for debugging.
+type consoleExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *consoleExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		attrs := make(map[string]any, len(s.Attrs))
+		for _, a := range s.Attrs {
+			attrs[a.Key] = a.Value
+		}
+		v := map[string]any{
+			"name":        s.Name,
+			"trace_id":    s.Context.TraceID.String(),
+			"span_id":     s.Context.SpanID.String(),
+			"start":       s.Start,
+			"duration_ms": float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+			"attributes":  attrs,
+		}
+		if s.Parent != (SpanID{}) {
+			v["parent_id"] = s.Parent.String()
+		}
+		if s.Err != nil {
+			v["error"] = s.Err.Error()
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// otlpExporter posts spans to an OTLP/HTTP collector using the JSON
+// encoding.
+type otlpExporter struct {
+	endpoint string
+	headers  http.Header
+	client   *http.Client
+	resource []Attr
+}
+
+func otlpExporterFromEnv(resource []Attr) (*otlpExporter, error) {
+	protocol := envOr("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL")
+	if protocol != "" && protocol != "http/json" {
+		return nil, fmt.Errorf("OTLP protocol %q is not supported; use http/json", protocol)
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			base = "http://localhost:4318"
+		}
+		endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+	}
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, fmt.Errorf("OTLP endpoint: %w", err)
+	}
+	e := &otlpExporter{
+		endpoint: endpoint,
+		headers:  make(http.Header),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		resource: resource,
+	}
+	if v := envOr("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("OTLP timeout: want milliseconds, got %q", v)
+		}
+		e.client.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	for _, h := range []string{os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")} {
+		for _, pair := range splitList(h) {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("OTLP headers: invalid entry %q", pair)
+			}
+			if v, err := url.QueryUnescape(value); err == nil {
+				value = v
+			}
+			e.headers.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	return e, nil
+}
+
+func envOr(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	out := make([]map[string]any, len(spans))
+	for i, s := range spans {
+		span := map[string]any{
+			"traceId":           s.Context.TraceID.String(),
+			"spanId":            s.Context.SpanID.String(),
+			"name":              s.Name,
+			"kind":              int(s.Kind),
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttrs(s.Attrs),
+		}
+		if s.Parent != (SpanID{}) {
+			span["parentSpanId"] = s.Parent.String()
+		}
+		if s.Err != nil {
+			span["status"] = map[string]any{"code": 2, "message": s.Err.Error()}
+		}
+		out[i] = span
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource":   map[string]any{"attributes": otlpAttrs(e.resource)},
+			"scopeSpans": []any{map[string]any{"scope": map[string]any{"name": "greeter"}, "spans": out}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range e.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP export: %s", resp.Status)
+	}
+	return nil
+}
+
+func otlpAttrs(attrs []Attr) []any {
+	out := make([]any, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]any
+		switch value := a.Value.(type) {
+		case string:
+			v = map[string]any{"stringValue": value}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": value}
+		case bool:
+			v = map[string]any{"boolValue": value}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(value)}
+		}
+		out = append(out, map[string]any{"key": a.Key, "value": v})
+	}
+	return out
+}
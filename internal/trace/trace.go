@@ -0,0 +1,280 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+)
+
+type Attr struct {
+	Key   string
+	Value any // string, int64, float64 or bool
+}
+
+func String(key, value string) Attr    { return Attr{key, value} }
+func Int(key string, value int) Attr   { return Attr{key, int64(value)} }
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+
+// Span records one timed operation. A nil *Span, returned when tracing is
+// off or the trace is not sampled, accepts every call and records nothing.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	Name    string
+	Kind    SpanKind
+	Context SpanContext
+	Parent  SpanID
+	Start   time.Time
+	End     time.Time
+	Attrs   []Attr
+	Err     error
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.enqueue(data)
+}
+
+type spanKey struct{}
+
+// ContextWithSpanContext returns a context carrying sc as the parent of the
+// spans started from it, typically one extracted from a request.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+var global atomic.Pointer[Tracer]
+
+// SetTracer installs t as the tracer used by Start. A nil t turns tracing
+// off.
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Start begins an internal span as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return StartKind(ctx, name, SpanKindInternal, attrs...)
+}
+
+func StartKind(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	parent, hasParent := SpanContextFromContext(ctx)
+	sc := SpanContext{TraceID: parent.TraceID}
+	if !hasParent {
+		rand.Read(sc.TraceID[:])
+	}
+	rand.Read(sc.SpanID[:])
+	sc.Sampled = t.sampler.sample(parent, hasParent, sc.TraceID)
+	ctx = ContextWithSpanContext(ctx, sc)
+	if !sc.Sampled {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, data: SpanData{
+		Name:    name,
+		Kind:    kind,
+		Context: sc,
+		Start:   time.Now(),
+		Attrs:   attrs,
+	}}
+	if hasParent {
+		s.data.Parent = parent.SpanID
+	}
+	return ctx, s
+}
+
+// sampler decides whether a span is recorded: by its parent if
+// parentBased is set and there is one, otherwise for ratio of traces.
+type sampler struct {
+	ratio       float64
+	parentBased bool
+}
+
+func (s sampler) sample(parent SpanContext, hasParent bool, id TraceID) bool {
+	if hasParent && s.parentBased {
+		return parent.Sampled
+	}
+	switch {
+	case s.ratio >= 1:
+		return true
+	case s.ratio <= 0:
+		return false
+	}
+	// The same rule as the OpenTelemetry TraceIdRatioBased sampler: compare
+	// the low 63 bits of the trace ID against the ratio.
+	return binary.BigEndian.Uint64(id[8:])>>1 < uint64(s.ratio*(1<<63))
+}
+
+const (
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+)
+
+// Exporter sends finished spans to a backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer batches finished spans and exports them in the background.
+type Tracer struct {
+	exporter Exporter
+	sampler  sampler
+
+	mu      sync.Mutex
+	pending []SpanData
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+func NewTracer(e Exporter) *Tracer {
+	t := &Tracer{
+		exporter: e,
+		sampler:  sampler{ratio: 1, parentBased: true},
+		flush:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+func (t *Tracer) enqueue(s SpanData) {
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	full := len(t.pending) >= batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) loop() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-t.done:
+			t.export()
+			return
+		}
+		t.export()
+	}
+}
+
+func (t *Tracer) export() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := t.exporter.Export(ctx, spans); err != nil {
+		slog.Warn("exporting spans failed", "spans", len(spans), "error", err)
+	}
+}
+
+// Shutdown exports the pending spans and stops the background exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stop.Do(func() { close(t.done) })
+	select {
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
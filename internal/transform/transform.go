@@ -0,0 +1,84 @@
+package transform
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Transform func(string) string
+
+type Chain []Transform
+
+func (c Chain) Apply(s string) string {
+	for _, t := range c {
+		s = t(s)
+	}
+	return s
+}
+
+var (
+	Uppercase Transform = strings.ToUpper
+	TitleCase Transform = title
+	Reverse   Transform = reverse
+	Leetspeak Transform = strings.NewReplacer(
+		"a", "4", "A", "4", "e", "3", "E", "3", "i", "1", "I", "1",
+		"o", "0", "O", "0", "s", "5", "S", "5", "t", "7", "T", "7",
+	).Replace
+)
+
+// Redact masks every whole-word, case-insensitive occurrence of words.
+func Redact(words ...string) Transform {
+	hidden := make(map[string]bool, len(words))
+	for _, w := range words {
+		hidden[strings.ToLower(w)] = true
+	}
+	return func(s string) string {
+		var b strings.Builder
+		for s != "" {
+			i := strings.IndexFunc(s, isWordSeparator)
+			if i < 0 {
+				i = len(s)
+			}
+			if i == 0 {
+				_, size := utf8.DecodeRuneInString(s)
+				b.WriteString(s[:size])
+				s = s[size:]
+				continue
+			}
+			word := s[:i]
+			if hidden[strings.ToLower(word)] {
+				word = strings.Repeat("*", utf8.RuneCountInString(word))
+			}
+			b.WriteString(word)
+			s = s[i:]
+		}
+		return b.String()
+	}
+}
+
+func title(s string) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range s {
+		if unicode.IsSpace(prev) {
+			b.WriteRune(unicode.ToTitle(r))
+		} else {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,384 @@
+// Package pkg checks the public API of the packages under it against
+// api.txt, in the manner of the Go distribution's api/ files: every
+// exported identifier, with its signature, on a line of its own.
+package pkg
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite api.txt with the current API")
+
+// publicPackages are the packages under the semantic versioning promise of
+// the README.
+var publicPackages = []string{"greeting", "message", "render"}
+
+// TestAPI fails if an identifier of api.txt is gone or its signature has
+// changed, which breaks importers, and if the API has identifiers api.txt
+// lacks, so that additions are recorded on purpose. After an intended
+// addition, run go test ./pkg -update.
+func TestAPI(t *testing.T) {
+	var features []string
+	for _, name := range publicPackages {
+		fs, _, err := packageFeatures(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		features = append(features, fs...)
+	}
+	slices.Sort(features)
+	features = slices.Compact(features)
+	if *update {
+		if err := os.WriteFile("api.txt", []byte(strings.Join(features, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := os.ReadFile("api.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for _, f := range want {
+		if _, found := slices.BinarySearch(features, f); !found {
+			t.Errorf("incompatible change: %s is gone or has changed", f)
+		}
+	}
+	for _, f := range features {
+		if _, found := slices.BinarySearch(want, f); !found {
+			t.Errorf("new API not in api.txt, record it with -update: %s", f)
+		}
+	}
+}
+
+// TestAPIHasNoInternalTypes fails for API that mentions a type of an
+// internal package, which importers cannot name and so cannot call.
+func TestAPIHasNoInternalTypes(t *testing.T) {
+	for _, name := range publicPackages {
+		_, leaks, err := packageFeatures(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range leaks {
+			t.Errorf("%s uses an internal package", f)
+		}
+	}
+}
+
+// packageFeatures returns the API of the package in dir, one line per
+// exported identifier, field and method, and those of the lines that
+// mention an internal package.
+func packageFeatures(dir string) (features, leaks []string, err error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := &apiWriter{fset: fset}
+	for name, p := range pkgs {
+		w.prefix = "pkg " + name + ", "
+		files := make([]string, 0, len(p.Files))
+		for file := range p.Files {
+			files = append(files, file)
+		}
+		slices.Sort(files)
+		for _, file := range files {
+			w.file(p.Files[file])
+		}
+	}
+	return w.features, w.leaks, nil
+}
+
+type apiWriter struct {
+	fset     *token.FileSet
+	prefix   string
+	features []string
+	leaks    []string
+	// internal holds the names the current file imports internal
+	// packages as, and leaked whether the feature being written used one.
+	internal map[string]bool
+	leaked   bool
+}
+
+func (w *apiWriter) add(s string) {
+	w.features = append(w.features, w.prefix+s)
+	if w.leaked {
+		w.leaks = append(w.leaks, w.prefix+s)
+		w.leaked = false
+	}
+}
+
+func (w *apiWriter) file(f *ast.File) {
+	w.internal = make(map[string]bool)
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if !strings.Contains(path, "/internal/") {
+			continue
+		}
+		if imp.Name != nil {
+			w.internal[imp.Name.Name] = true
+		} else {
+			w.internal[path[strings.LastIndex(path, "/")+1:]] = true
+		}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			w.funcDecl(d)
+		case *ast.GenDecl:
+			w.genDecl(d)
+		}
+	}
+}
+
+func (w *apiWriter) funcDecl(d *ast.FuncDecl) {
+	if !d.Name.IsExported() {
+		return
+	}
+	if d.Recv == nil {
+		w.add("func " + d.Name.Name + w.typeParams(d.Type.TypeParams) + w.signature(d.Type))
+		return
+	}
+	recv := d.Recv.List[0].Type
+	star := ""
+	if s, ok := recv.(*ast.StarExpr); ok {
+		recv, star = s.X, "*"
+	}
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		recv = r.X
+	case *ast.IndexListExpr:
+		recv = r.X
+	}
+	if id, ok := recv.(*ast.Ident); ok && id.IsExported() {
+		w.add("method (" + star + id.Name + ") " + d.Name.Name + w.signature(d.Type))
+	}
+}
+
+func (w *apiWriter) genDecl(d *ast.GenDecl) {
+	// Constants repeat the type of the last spec that had one, as iota
+	// groups do.
+	var lastType ast.Expr
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			w.typeSpec(s)
+		case *ast.ValueSpec:
+			kind := "var "
+			if d.Tok == token.CONST {
+				kind = "const "
+				if s.Type != nil || len(s.Values) > 0 {
+					lastType = s.Type
+				}
+			}
+			for i, name := range s.Names {
+				if !name.IsExported() {
+					continue
+				}
+				switch {
+				case s.Type != nil:
+					w.add(kind + name.Name + " " + w.expr(s.Type))
+				case d.Tok == token.VAR && len(s.Values) > i && valueType(s.Values[i]) != nil:
+					w.add(kind + name.Name + " " + w.expr(valueType(s.Values[i])))
+				case d.Tok == token.CONST && len(s.Values) > i && lastType == nil:
+					w.add(kind + name.Name + " = " + w.expr(s.Values[i]))
+				case d.Tok == token.CONST && lastType != nil:
+					w.add(kind + name.Name + " " + w.expr(lastType))
+				default:
+					w.add(kind + name.Name)
+				}
+			}
+		}
+	}
+}
+
+func (w *apiWriter) typeSpec(s *ast.TypeSpec) {
+	if !s.Name.IsExported() {
+		return
+	}
+	name := "type " + s.Name.Name + w.typeParams(s.TypeParams)
+	if s.Assign.IsValid() {
+		w.add(name + " = " + w.expr(s.Type))
+		return
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		w.add(name + " struct")
+		for _, f := range t.Fields.List {
+			if len(f.Names) == 0 {
+				if embeddedName(f.Type).IsExported() {
+					w.add(name + " struct, embedded " + w.expr(f.Type))
+				}
+				continue
+			}
+			for _, n := range f.Names {
+				if n.IsExported() {
+					w.add(name + " struct, " + n.Name + " " + w.expr(f.Type))
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		w.add(name + " interface")
+		for _, f := range t.Methods.List {
+			if len(f.Names) == 0 {
+				w.add(name + " interface, embedded " + w.expr(f.Type))
+				continue
+			}
+			for _, n := range f.Names {
+				if n.IsExported() {
+					w.add(name + " interface, " + n.Name + w.signature(f.Type.(*ast.FuncType)))
+				} else {
+					w.add(name + " interface, unexported methods")
+				}
+			}
+		}
+	default:
+		w.add(name + " " + w.expr(s.Type))
+	}
+}
+
+// valueType returns the type of the variable initialized with v, where it
+// shows without type checking: that of a composite literal, or error for an
+// errors.New, or nil.
+func valueType(v ast.Expr) ast.Expr {
+	switch e := v.(type) {
+	case *ast.CompositeLit:
+		return e.Type
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND && lit.Type != nil {
+			return &ast.StarExpr{X: lit.Type}
+		}
+	case *ast.CallExpr:
+		if sel, ok := e.Fun.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "errors" && sel.Sel.Name == "New" {
+				return ast.NewIdent("error")
+			}
+		}
+	}
+	return nil
+}
+
+// embeddedName returns the name of the type embedded as t.
+func embeddedName(t ast.Expr) *ast.Ident {
+	for {
+		switch e := t.(type) {
+		case *ast.StarExpr:
+			t = e.X
+		case *ast.SelectorExpr:
+			return e.Sel
+		case *ast.IndexExpr:
+			t = e.X
+		case *ast.IndexListExpr:
+			t = e.X
+		case *ast.Ident:
+			return e
+		default:
+			return ast.NewIdent("_")
+		}
+	}
+}
+
+// signature returns the parameter and result types of ft, without names,
+// which callers do not depend on.
+func (w *apiWriter) signature(ft *ast.FuncType) string {
+	s := "(" + w.types(ft.Params) + ")"
+	if ft.Results == nil || len(ft.Results.List) == 0 {
+		return s
+	}
+	results := w.types(ft.Results)
+	if len(ft.Results.List) == 1 && len(ft.Results.List[0].Names) <= 1 {
+		return s + " " + results
+	}
+	return s + " (" + results + ")"
+}
+
+func (w *apiWriter) typeParams(fl *ast.FieldList) string {
+	if fl == nil {
+		return ""
+	}
+	var params []string
+	for _, f := range fl.List {
+		for _, n := range f.Names {
+			params = append(params, n.Name+" "+w.expr(f.Type))
+		}
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// types returns the types of fl, each as often as it has names.
+func (w *apiWriter) types(fl *ast.FieldList) string {
+	if fl == nil {
+		return ""
+	}
+	var types []string
+	for _, f := range fl.List {
+		t := w.expr(f.Type)
+		for range max(len(f.Names), 1) {
+			types = append(types, t)
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+func (w *apiWriter) expr(e ast.Expr) string {
+	// Function types, as of callbacks, lose their parameter names too.
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncType:
+			n.Params, n.Results = unnamed(n.Params), unnamed(n.Results)
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok && w.internal[id.Name] {
+				w.leaked = true
+			}
+		}
+		return true
+	})
+	var b bytes.Buffer
+	printer.Fprint(&b, w.fset, e)
+	// Multi-line types, such as struct literals, are put on one line.
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// unnamed returns fl with a field of each name's type and no names.
+func unnamed(fl *ast.FieldList) *ast.FieldList {
+	if fl == nil {
+		return nil
+	}
+	out := &ast.FieldList{}
+	for _, f := range fl.List {
+		for range max(len(f.Names), 1) {
+			out.List = append(out.List, &ast.Field{Type: f.Type})
+		}
+	}
+	return out
+}
+
+// TestAPIListsEveryPackage guards the list of packages against one added
+// under pkg/ without its API being recorded.
+func TestAPIListsEveryPackage(t *testing.T) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if matches, _ := filepath.Glob(filepath.Join(e.Name(), "*.go")); len(matches) > 0 && !slices.Contains(publicPackages, e.Name()) {
+			t.Errorf("package %s is not in publicPackages", e.Name())
+		}
+	}
+}
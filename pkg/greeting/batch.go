@@ -0,0 +1,43 @@
+package greeting
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/pipeline"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+type BatchItemError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("item %d (%q): %v", e.Index, e.Name, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// GreetBatch greets every name, on as many goroutines as there are cores,
+// returning the messages that succeeded in input order and a
+// *BatchItemError for each name that failed.
+func GreetBatch(ctx context.Context, names []string, opts ...Option) ([]message.Message, []error) {
+	var msgs []message.Message
+	var errs []error
+	l := newGreetOptions(ctx, opts).localizer
+	results := pipeline.Run(ctx, names, 0, func(ctx context.Context, name string) (string, error) {
+		return GreetContext(ctx, name, opts...)
+	})
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, &BatchItemError{Index: i, Name: names[i], Err: r.Err})
+			continue
+		}
+		msgs = append(msgs, newGreeting(l, r.Value))
+	}
+	return msgs, errs
+}
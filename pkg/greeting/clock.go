@@ -0,0 +1,93 @@
+package greeting
+
+import (
+	"context"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+var SystemClock Clock = ClockFunc(time.Now)
+
+type Period struct {
+	StartHour int
+	Key       string
+}
+
+type Holiday struct {
+	Month time.Month
+	Day   int
+	Key   string
+}
+
+// Schedule picks a translation key for a point in time. Holidays win over
+// periods, and periods must be sorted by StartHour.
+type Schedule struct {
+	Periods  []Period
+	Holidays []Holiday
+}
+
+var DefaultSchedule = Schedule{
+	Periods: []Period{
+		{StartHour: 0, Key: "greeting.evening"},
+		{StartHour: 5, Key: "greeting.morning"},
+		{StartHour: 12, Key: "greeting.afternoon"},
+		{StartHour: 18, Key: "greeting.evening"},
+	},
+	Holidays: []Holiday{
+		{Month: time.January, Day: 1, Key: "greeting.new_year"},
+		{Month: time.December, Day: 25, Key: "greeting.christmas"},
+	},
+}
+
+func (s Schedule) Key(t time.Time) string {
+	for _, h := range s.Holidays {
+		if t.Month() == h.Month && t.Day() == h.Day {
+			return h.Key
+		}
+	}
+	key := "greeting"
+	for _, p := range s.Periods {
+		if t.Hour() >= p.StartHour {
+			key = p.Key
+		}
+	}
+	return key
+}
+
+type TimedGreeter struct {
+	Localizer *Localizer
+	Clock     Clock
+	Schedule  Schedule
+}
+
+func NewTimedGreeter(l *Localizer) *TimedGreeter {
+	return &TimedGreeter{Localizer: l, Clock: SystemClock, Schedule: DefaultSchedule}
+}
+
+func (g *TimedGreeter) Greet(ctx context.Context, name string) (message.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return message.Message{}, err
+	}
+	l := localizerFor(ctx, g.Localizer)
+	_, span := trace.Start(ctx, "greeting.localize", trace.String("locale", l.Locale()))
+	defer span.End()
+	return newGreeting(l, l.Format(g.Schedule.Key(g.Clock.Now()), name)), nil
+}
@@ -0,0 +1,145 @@
+// Package greeting greets people by name: Greeter and its implementations,
+// the Greet functions and their options, templates, and the translations
+// greetings are localized with.
+//
+// It is part of the module's public API, which follows semantic versioning;
+// see the API stability section of the README.
+package greeting
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// LocaleTag prefixes the tag greeters add to record the locale of a
+// greeting, as in "lang:fr".
+const LocaleTag = "lang:"
+
+// MessageLocale returns the locale recorded on m by a greeter.
+func MessageLocale(m message.Message) (string, bool) {
+	for _, tag := range m.Tags {
+		if locale, ok := strings.CutPrefix(tag, LocaleTag); ok {
+			return locale, true
+		}
+	}
+	return "", false
+}
+
+func newGreeting(l *Localizer, text string) message.Message {
+	return message.NewMessage(text, LocaleTag+l.Locale())
+}
+
+type Greeter interface {
+	Greet(ctx context.Context, name string) (message.Message, error)
+}
+
+type GreeterFunc func(ctx context.Context, name string) (message.Message, error)
+
+func (f GreeterFunc) Greet(ctx context.Context, name string) (message.Message, error) {
+	return f(ctx, name)
+}
+
+type DefaultGreeter struct {
+	Options []Option
+}
+
+func (g DefaultGreeter) Greet(ctx context.Context, name string) (message.Message, error) {
+	text, err := GreetContext(ctx, name, g.Options...)
+	if err != nil {
+		return message.Message{}, err
+	}
+	return newGreeting(newGreetOptions(ctx, g.Options).localizer, text), nil
+}
+
+type FormalGreeter struct {
+	Localizer *Localizer
+}
+
+func (g FormalGreeter) Greet(ctx context.Context, name string) (message.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return message.Message{}, err
+	}
+	l := localizerFor(ctx, g.Localizer)
+	_, span := trace.Start(ctx, "greeting.localize", trace.String("locale", l.Locale()))
+	defer span.End()
+	return newGreeting(l, l.Format("greeting.formal", name)), nil
+}
+
+// TemplateGreeter greets with a GreetTemplate template, such as a tenant's
+// own greeting.
+type TemplateGreeter struct {
+	Template string
+}
+
+func (g TemplateGreeter) Greet(ctx context.Context, name string) (message.Message, error) {
+	text, err := GreetTemplate(ctx, g.Template, name, nil)
+	if err != nil {
+		return message.Message{}, err
+	}
+	return newGreeting(LocalizerFor(ctx), text), nil
+}
+
+// Transformed wraps g so that every message it produces goes through t,
+// for example a transform.Chain's Apply method.
+func Transformed(g Greeter, t func(string) string) Greeter {
+	return GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+		m, err := g.Greet(ctx, name)
+		if err != nil {
+			return m, err
+		}
+		return m.Transform(t), nil
+	})
+}
+
+var (
+	greetersMu sync.RWMutex
+	greeters   = make(map[string]Greeter)
+)
+
+func init() {
+	Register("default", DefaultGreeter{})
+	Register("formal", FormalGreeter{})
+	Register("timed", NewTimedGreeter(nil))
+	Register("variants", NewVariantGreeter(nil, nil))
+}
+
+// Register makes a greeter available by name. It panics if the name is
+// already taken or g is nil.
+func Register(name string, g Greeter) {
+	greetersMu.Lock()
+	defer greetersMu.Unlock()
+	if g == nil {
+		panic("greeter: Register greeter is nil")
+	}
+	if _, dup := greeters[name]; dup {
+		panic("greeter: Register called twice for " + name)
+	}
+	greeters[name] = g
+}
+
+func Lookup(name string) (Greeter, error) {
+	greetersMu.RLock()
+	defer greetersMu.RUnlock()
+	g, ok := greeters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown greeter %q", name)
+	}
+	return g, nil
+}
+
+func Greeters() []string {
+	greetersMu.RLock()
+	defer greetersMu.RUnlock()
+	names := make([]string, 0, len(greeters))
+	for name := range greeters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,311 @@
+package greeting
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+)
+
+//go:embed locales/*.json
+var bundledLocales embed.FS
+
+const defaultLocale = "en"
+
+// bundledLocalizer is the default localizer until SetDefaultLocalizer
+// replaces it.
+var bundledLocalizer = NewLocalizer(DetectLocale())
+
+var defaultLocalizer atomic.Pointer[Localizer]
+
+// DefaultLocalizer returns the localizer greeters use when neither they nor
+// their context have one: the bundled translations, for the locale of the
+// environment, or those of SetDefaultLocalizer.
+func DefaultLocalizer() *Localizer {
+	if l := defaultLocalizer.Load(); l != nil {
+		return l
+	}
+	return bundledLocalizer
+}
+
+// SetDefaultLocalizer makes l the default localizer, or restores the
+// bundled one if l is nil. Greetings under way keep the localizer they
+// started with.
+func SetDefaultLocalizer(l *Localizer) {
+	defaultLocalizer.Store(l)
+}
+
+type Localizer struct {
+	locale  string
+	bundles map[string]map[string]string
+}
+
+func NewLocalizer(locale string) *Localizer {
+	l := &Localizer{
+		locale:  normalizeLocale(locale),
+		bundles: make(map[string]map[string]string),
+	}
+	if err := l.LoadFS(bundledLocales, "locales"); err != nil {
+		panic(fmt.Sprintf("i18n: bundled locales: %v", err))
+	}
+	return l
+}
+
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// Locales lists the locales l has bundles for.
+func (l *Localizer) Locales() []string {
+	locales := make([]string, 0, len(l.bundles))
+	for locale := range l.bundles {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Locales lists the locales of the bundled translations.
+func Locales() []string {
+	return DefaultLocalizer().Locales()
+}
+
+func (l *Localizer) WithLocale(locale string) *Localizer {
+	return &Localizer{locale: normalizeLocale(locale), bundles: l.bundles}
+}
+
+func (l *Localizer) LoadFS(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if err := l.load(strings.TrimSuffix(path.Base(p), ".json"), data); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// LoadBundle returns a localizer for the locale of the environment with the
+// bundled translations and, over them, those of the <locale>.json files in
+// dir of fsys, once Validate finds no fault with them.
+func LoadBundle(fsys fs.FS, dir string) (*Localizer, error) {
+	l := NewLocalizer(DetectLocale())
+	if err := l.LoadFS(fsys, dir); err != nil {
+		return nil, err
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Validate reports the translations of l that would garble greetings:
+// those that are empty, or that take a different number of arguments from
+// the bundled translation of the same key in the default locale.
+func (l *Localizer) Validate() error {
+	var errs []error
+	for _, locale := range l.Locales() {
+		bundle := l.bundles[locale]
+		keys := make([]string, 0, len(bundle))
+		for key := range bundle {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			text := bundle[key]
+			ref, ok := bundledLocalizer.bundles[defaultLocale][key]
+			switch {
+			case strings.TrimSpace(text) == "":
+				errs = append(errs, fmt.Errorf("%s: %s is empty", locale, key))
+			case ok && countVerbs(text) != countVerbs(ref):
+				errs = append(errs, fmt.Errorf("%s: %s takes %d arguments, want %d as in %q", locale, key, countVerbs(text), countVerbs(ref), ref))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// countVerbs counts the fmt verbs in format, not counting %%.
+func countVerbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func (l *Localizer) LoadFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := l.load(strings.TrimSuffix(filepath.Base(name), ".json"), data); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func (l *Localizer) load(locale string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+	locale = normalizeLocale(locale)
+	bundle, ok := l.bundles[locale]
+	if !ok {
+		bundle = make(map[string]string, len(messages))
+		l.bundles[locale] = bundle
+	}
+	for key, text := range messages {
+		bundle[key] = text
+	}
+	return nil
+}
+
+func (l *Localizer) Translate(key string) string {
+	for _, locale := range fallbackChain(l.locale) {
+		if text, ok := l.bundles[locale][key]; ok {
+			return text
+		}
+	}
+	return key
+}
+
+// translates reports whether l has a translation of key for its locale or
+// that locale's language, or, for a language it has no translations of,
+// in the default locale that Translate falls back to.
+func (l *Localizer) translates(key string) bool {
+	chain := fallbackChain(l.locale)
+	known := false
+	for _, locale := range chain[:2] {
+		bundle, ok := l.bundles[locale]
+		if _, has := bundle[key]; has {
+			return true
+		}
+		known = known || ok
+	}
+	if known {
+		return false
+	}
+	_, ok := l.bundles[chain[2]][key]
+	return ok
+}
+
+func (l *Localizer) Format(key string, args ...any) string {
+	return fmt.Sprintf(l.Translate(key), args...)
+}
+
+// TemplateHash fingerprints the translations l uses for its locale, so
+// that caches of rendered greetings can tell when they change.
+func (l *Localizer) TemplateHash() string {
+	h := sha256.New()
+	for _, locale := range fallbackChain(l.locale) {
+		if locale == "" {
+			continue
+		}
+		bundle := l.bundles[locale]
+		keys := make([]string, 0, len(bundle))
+		for key := range bundle {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		fmt.Fprintf(h, "%s\x00", locale)
+		for _, key := range keys {
+			fmt.Fprintf(h, "%s\x00%s\x00", key, bundle[key])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// LocalizerFor returns the default localizer, or that of
+// ContextWithLocalizer, switched to the locale carried by ctx, as the
+// bundled greeters use it.
+func LocalizerFor(ctx context.Context) *Localizer {
+	return localizerFor(ctx, nil)
+}
+
+func (l *Localizer) Greet(name string) string {
+	return l.Format("greeting", name)
+}
+
+type localeKey struct{}
+
+// ContextWithLocale returns a copy of ctx that makes greeters use locale
+// instead of the one they were configured with.
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, normalizeLocale(locale))
+}
+
+func LocaleFromContext(ctx context.Context) (string, bool) {
+	locale, ok := ctx.Value(localeKey{}).(string)
+	return locale, ok
+}
+
+type localizerKey struct{}
+
+// ContextWithLocalizer returns a copy of ctx that makes greeters without a
+// localizer of their own use l instead of the default one, as for a
+// tenant's translations. The locale of ContextWithLocale still applies.
+func ContextWithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// localizerFor returns l, or the localizer of ContextWithLocalizer or the
+// default one if l is nil, switched to the locale carried by ctx.
+func localizerFor(ctx context.Context, l *Localizer) *Localizer {
+	if l == nil {
+		l, _ = ctx.Value(localizerKey{}).(*Localizer)
+	}
+	if l == nil {
+		l = DefaultLocalizer()
+	}
+	if locale, ok := LocaleFromContext(ctx); ok {
+		return l.WithLocale(locale)
+	}
+	return l
+}
+
+func DetectLocale() string {
+	return locale.Detect()
+}
+
+// normalizeLocale turns POSIX-style values like "fr_CA.UTF-8@euro" into "fr-ca".
+func normalizeLocale(tag string) string {
+	return locale.Normalize(tag)
+}
+
+// fallbackChain returns the locales to look a translation up in, most
+// specific first: locale, its language, or "" if it has none, and the
+// default locale. It is an array so that Translate does not allocate.
+func fallbackChain(locale string) [3]string {
+	chain := [3]string{locale, "", defaultLocale}
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		chain[1] = locale[:i]
+	}
+	return chain
+}
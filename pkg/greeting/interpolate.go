@@ -0,0 +1,143 @@
+package greeting
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/normalize"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+)
+
+type MissingKeyError struct {
+	Key string
+}
+
+func (e *MissingKeyError) Error() string {
+	return fmt.Sprintf("no value for placeholder {%s}", e.Key)
+}
+
+// Interpolator fills {key} placeholders from a data map, falling back to
+// Defaults. "{{" and "}}" produce literal braces. A placeholder may also
+// call a function of locale.Locale.Call with values and literal words, as
+// in {date created}, {number 2 total} or {currency EUR total}; a key may
+// be written with a leading dot, {date .created}, to say it is not a word.
+type Interpolator struct {
+	Defaults map[string]any
+	// Locale is the locale functions format for, the environment's if
+	// empty.
+	Locale string
+}
+
+func Interpolate(tmpl string, data map[string]any) (string, error) {
+	return (&Interpolator{}).Interpolate(tmpl, data)
+}
+
+func (ip *Interpolator) Interpolate(tmpl string, data map[string]any) (string, error) {
+	var b strings.Builder
+	for tmpl != "" {
+		i := strings.IndexAny(tmpl, "{}")
+		if i < 0 {
+			b.WriteString(tmpl)
+			break
+		}
+		b.WriteString(tmpl[:i])
+		if strings.HasPrefix(tmpl[i:], "{{") || strings.HasPrefix(tmpl[i:], "}}") {
+			b.WriteByte(tmpl[i])
+			tmpl = tmpl[i+2:]
+			continue
+		}
+		if tmpl[i] == '}' {
+			return "", fmt.Errorf("unmatched } before %q", tmpl[i+1:])
+		}
+		end := strings.IndexByte(tmpl[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder %q", tmpl[i:])
+		}
+		key := tmpl[i+1 : i+end]
+		if fn, args, ok := strings.Cut(key, " "); ok {
+			text, err := ip.call(fn, strings.Fields(args), data)
+			if err != nil {
+				return "", fmt.Errorf("{%s}: %w", key, err)
+			}
+			b.WriteString(text)
+			tmpl = tmpl[i+end+1:]
+			continue
+		}
+		value, ok := ip.lookup(key, data)
+		if !ok {
+			return "", &MissingKeyError{Key: key}
+		}
+		b.WriteString(formatValue(value))
+		tmpl = tmpl[i+end+1:]
+	}
+	return b.String(), nil
+}
+
+func (ip *Interpolator) lookup(key string, data map[string]any) (any, bool) {
+	value, ok := data[key]
+	if !ok {
+		value, ok = ip.Defaults[key]
+	}
+	return value, ok
+}
+
+// call calls the function fn with args, each a key if there is a value
+// for it and a literal word otherwise.
+func (ip *Interpolator) call(fn string, args []string, data map[string]any) (string, error) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		key, dotted := strings.CutPrefix(arg, ".")
+		value, ok := ip.lookup(key, data)
+		switch {
+		case ok:
+			values[i] = value
+		case dotted:
+			return "", &MissingKeyError{Key: key}
+		default:
+			values[i] = arg
+		}
+	}
+	tag := ip.Locale
+	if tag == "" {
+		tag = DetectLocale()
+	}
+	return locale.For(tag).Call(fn, values...)
+}
+
+func formatValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.DateOnly)
+	}
+	return fmt.Sprint(v)
+}
+
+// GreetTemplate normalizes and validates name, then fills tmpl from data
+// with {name} bound to it and {now} to the time, formatting for the locale
+// carried by ctx.
+func GreetTemplate(ctx context.Context, tmpl, name string, data map[string]any) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	name, err := validateName(ctx, normalize.Default, name)
+	if err != nil {
+		return "", err
+	}
+	values := maps.Clone(data)
+	if values == nil {
+		values = make(map[string]any)
+	}
+	values["name"] = name
+	if _, ok := values["now"]; !ok {
+		values["now"] = time.Now()
+	}
+	_, span := trace.Start(ctx, "greeting.template")
+	defer span.End()
+	ip := &Interpolator{Locale: LocalizerFor(ctx).Locale()}
+	text, err := ip.Interpolate(tmpl, values)
+	span.RecordError(err)
+	return text, err
+}
@@ -0,0 +1,91 @@
+package greeting
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/normalize"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Middleware wraps a greeter with work done around every greeting it
+// produces, such as validating, logging or counting them.
+type Middleware func(next Greeter) Greeter
+
+// Chain returns g wrapped in mw, the first outermost: it sees each name
+// first and each message last, so that Chain(g, a, b) is a(b(g)).
+func Chain(g Greeter, mw ...Middleware) Greeter {
+	for i := len(mw) - 1; i >= 0; i-- {
+		g = mw[i](g)
+	}
+	return g
+}
+
+// DefaultChain returns the middleware greetings are produced through when
+// nothing else is configured: they are logged with slog's default logger,
+// then validated.
+func DefaultChain() []Middleware {
+	return []Middleware{Logging(nil), Validating}
+}
+
+// Validating fails greetings of names NameValidator rejects once
+// normalized, such as the empty name, without calling next, and those next
+// produces that are not valid messages. The errors are those of
+// NameValidator and message.Message.Validate, *message.ValidationError.
+func Validating(next Greeter) Greeter {
+	return GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+		if _, err := validateName(ctx, normalize.Default, name); err != nil {
+			return message.Message{}, err
+		}
+		m, err := next.Greet(ctx, name)
+		if err != nil {
+			return m, err
+		}
+		if err := m.Validate(); err != nil {
+			return message.Message{}, err
+		}
+		return m, nil
+	})
+}
+
+// Transforming returns middleware that passes every greeting through t, as
+// Transformed does.
+func Transforming(t func(string) string) Middleware {
+	return func(next Greeter) Greeter { return Transformed(next, t) }
+}
+
+// Logging returns middleware that logs each greeting, or its failure, to
+// logger, or to slog's default logger if logger is nil.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Greeter) Greeter {
+		return GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			start := time.Now()
+			m, err := next.Greet(ctx, name)
+			if err != nil {
+				l.InfoContext(ctx, "greeting failed", "name", name, "error", err)
+				return m, err
+			}
+			l.InfoContext(ctx, "greeting", "name", name, "id", m.ID, "duration", time.Since(start))
+			return m, nil
+		})
+	}
+}
+
+// Limiting returns middleware that calls allow before each greeting and
+// fails it with allow's error, if any, without calling the greeter, so
+// that greetings can be rate limited or held to a quota.
+func Limiting(allow func(ctx context.Context) error) Middleware {
+	return func(next Greeter) Greeter {
+		return GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+			if err := allow(ctx); err != nil {
+				return message.Message{}, err
+			}
+			return next.Greet(ctx, name)
+		})
+	}
+}
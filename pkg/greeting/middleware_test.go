@@ -0,0 +1,138 @@
+package greeting
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// recorder returns a greeter greeting with text, and middleware named
+// after each of names, all recording in order the calls they see and the
+// messages they return.
+func recorder(text string, names ...string) (*[]string, Greeter, []Middleware) {
+	var calls []string
+	g := GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+		calls = append(calls, "greet "+name)
+		return message.Message{Text: text}, nil
+	})
+	mw := make([]Middleware, len(names))
+	for i, n := range names {
+		mw[i] = func(next Greeter) Greeter {
+			return GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+				calls = append(calls, n+" before")
+				m, err := next.Greet(ctx, name)
+				calls = append(calls, n+" after")
+				return m, err
+			})
+		}
+	}
+	return &calls, g, mw
+}
+
+func TestChainOrder(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{"greet Ada"}},
+		{[]string{"a"}, []string{"a before", "greet Ada", "a after"}},
+		{[]string{"a", "b", "c"}, []string{"a before", "b before", "c before", "greet Ada", "c after", "b after", "a after"}},
+	}
+	for _, tt := range tests {
+		calls, g, mw := recorder("Hello, Ada!", tt.names...)
+		if _, err := Chain(g, mw...).Greet(context.Background(), "Ada"); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*calls, tt.want) {
+			t.Errorf("Chain with %q: calls %q, want %q", tt.names, *calls, tt.want)
+		}
+	}
+}
+
+// TestChainShortCircuits checks that middleware failing a greeting keeps
+// it from the middleware inside and the greeter, not from those outside.
+func TestChainShortCircuits(t *testing.T) {
+	errQuota := errors.New("over quota")
+	calls, g, mw := recorder("Hello, Ada!", "outer", "inner")
+	mw = []Middleware{mw[0], Limiting(func(context.Context) error { return errQuota }), mw[1]}
+	if _, err := Chain(g, mw...).Greet(context.Background(), "Ada"); err != errQuota {
+		t.Fatalf("error %v, want %v", err, errQuota)
+	}
+	if want := []string{"outer before", "outer after"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("calls %q, want %q", *calls, want)
+	}
+}
+
+func TestTransformingOrder(t *testing.T) {
+	// The innermost transform applies first, as it sees the message first.
+	g := Chain(GreeterFunc(func(ctx context.Context, name string) (message.Message, error) {
+		return message.Message{Text: "Hello, " + name + "!"}, nil
+	}), Transforming(strings.ToUpper), Transforming(func(s string) string { return s + " (hi)" }))
+	m, err := g.Greet(context.Background(), "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "HELLO, ADA! (HI)"; m.Text != want {
+		t.Errorf("greeted %q, want %q", m.Text, want)
+	}
+}
+
+func TestValidating(t *testing.T) {
+	tests := []struct {
+		text string
+		err  error
+	}{
+		{"Hello, Ada!", nil},
+		{"  ", message.ErrEmpty},
+		{"\xff", message.ErrInvalidUTF8},
+	}
+	for _, tt := range tests {
+		_, g, _ := recorder(tt.text)
+		m, err := Validating(g).Greet(context.Background(), "Ada")
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Validating of %q: error %v, want %v", tt.text, err, tt.err)
+		}
+		if err != nil && m.Text != "" {
+			t.Errorf("Validating of %q returned %q with its error", tt.text, m.Text)
+		}
+	}
+}
+
+// TestValidatingRejectsEmptyNames checks that no registered style greets
+// an empty name through the default chain.
+func TestValidatingRejectsEmptyNames(t *testing.T) {
+	for _, style := range Greeters() {
+		g, err := Lookup(style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"", "  ", "\u200b"} {
+			m, err := Chain(g, DefaultChain()...).Greet(context.Background(), name)
+			if !errors.Is(err, ErrEmptyName) {
+				t.Errorf("style %s greeted %q as %q, %v, want %v", style, name, m.Text, err, ErrEmptyName)
+			}
+		}
+	}
+}
+
+// TestDefaultChain checks that the default chain logs greetings outside
+// their validation, so that those failing it are logged as failures.
+func TestDefaultChain(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	for _, text := range []string{"Hello, Ada!", " "} {
+		_, g, _ := recorder(text)
+		Chain(g, DefaultChain()...).Greet(context.Background(), "Ada")
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `msg=greeting `) || !strings.Contains(lines[1], `msg="greeting failed"`) {
+		t.Errorf("logged\n%s\nwant a greeting, then a failed greeting", buf.String())
+	}
+}
@@ -0,0 +1,20 @@
+package greeting
+
+import "github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+
+const MaxNameLength = 128
+
+var (
+	ErrEmptyName   = &message.ValidationError{Field: "name", Err: message.ErrEmpty}
+	ErrNameTooLong = &message.ValidationError{Field: "name", Err: message.ErrTooLong}
+)
+
+var NameValidator = &message.Validator{
+	Rules: []message.Rule{
+		message.NotEmpty(),
+		message.ValidUTF8(),
+		message.MaxLength(MaxNameLength),
+		message.NoControlChars(),
+		message.NoMixedScripts(),
+	},
+}
@@ -0,0 +1,194 @@
+package greeting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/normalize"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/transform"
+)
+
+type greetOptions struct {
+	localizer   *Localizer
+	normalizer  *normalize.Normalizer
+	word        string
+	punctuation *string
+	uppercase   bool
+	oxfordComma *bool
+	transforms  transform.Chain
+}
+
+type Option func(*greetOptions)
+
+func WithLocale(locale string) Option {
+	return func(o *greetOptions) { o.localizer = o.localizer.WithLocale(locale) }
+}
+
+func WithLocalizer(l *Localizer) Option {
+	return func(o *greetOptions) { o.localizer = l }
+}
+
+func WithGreetingWord(word string) Option {
+	return func(o *greetOptions) { o.word = word }
+}
+
+func WithPunctuation(punctuation string) Option {
+	return func(o *greetOptions) { o.punctuation = &punctuation }
+}
+
+func WithUppercase() Option {
+	return func(o *greetOptions) { o.uppercase = true }
+}
+
+// WithTransforms applies transforms to the greeting in order, as
+// Transformed does to a greeter's.
+func WithTransforms(transforms ...func(string) string) Option {
+	return func(o *greetOptions) {
+		for _, t := range transforms {
+			o.transforms = append(o.transforms, t)
+		}
+	}
+}
+
+func WithOxfordComma(enabled bool) Option {
+	return func(o *greetOptions) { o.oxfordComma = &enabled }
+}
+
+func Greet(name string, opts ...Option) string {
+	o := newGreetOptions(context.Background(), opts)
+	return o.greet(o.normalizer.Normalize(name))
+}
+
+// AppendGreeting appends the greeting Greet(name) returns to dst and
+// returns the extended buffer. It allocates nothing when dst has room and
+// name is already normalized, for servers that format greetings by the
+// million.
+func AppendGreeting(dst []byte, name string) []byte {
+	format := DefaultLocalizer().Translate("greeting")
+	before, after, ok := splitFormat(format)
+	if !ok {
+		return fmt.Appendf(dst, format, normalize.Default.Normalize(name))
+	}
+	dst = append(dst, before...)
+	dst = normalize.Default.Append(dst, name)
+	return append(dst, after...)
+}
+
+func GreetContext(ctx context.Context, name string, opts ...Option) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	start := time.Now()
+	o := newGreetOptions(ctx, opts)
+	name, err := validateName(ctx, o.normalizer, name)
+	if err != nil {
+		return "", err
+	}
+	_, span := trace.Start(ctx, "greeting.localize", trace.String("locale", o.localizer.Locale()))
+	text := o.greet(name)
+	span.End()
+	slog.DebugContext(ctx, "greeted", "locale", o.localizer.Locale(), "duration", time.Since(start))
+	return text, nil
+}
+
+// validateName normalizes name with n and checks it against NameValidator.
+func validateName(ctx context.Context, n *normalize.Normalizer, name string) (string, error) {
+	_, span := trace.Start(ctx, "greeting.validate")
+	defer span.End()
+	name = n.Normalize(name)
+	if err := NameValidator.Validate("name", name); err != nil {
+		slog.DebugContext(ctx, "name rejected", "name", name, "error", err)
+		span.RecordError(err)
+		return "", err
+	}
+	return name, nil
+}
+
+func GreetAll(names []string, opts ...Option) string {
+	if len(names) == 0 {
+		return ""
+	}
+	o := newGreetOptions(context.Background(), opts)
+	normalized := make([]string, len(names))
+	for i, name := range names {
+		normalized[i] = o.normalizer.Normalize(name)
+	}
+	return o.greet(o.joinNames(normalized))
+}
+
+func newGreetOptions(ctx context.Context, opts []Option) *greetOptions {
+	o := &greetOptions{localizer: localizerFor(ctx, nil), normalizer: normalize.Default}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *greetOptions) greet(name string) string {
+	// The word and punctuation are text, not format: a % of theirs is
+	// escaped so that fmt writes it as is.
+	format := o.localizer.Translate("greeting")
+	if o.word != "" {
+		format = strings.ReplaceAll(o.word, "%", "%%") + ", %s!"
+	}
+	if o.punctuation != nil {
+		format = strings.TrimRightFunc(format, func(r rune) bool {
+			return unicode.IsPunct(r) || unicode.IsSpace(r)
+		}) + strings.ReplaceAll(*o.punctuation, "%", "%%")
+	}
+	greeting := formatGreeting(format, name)
+	if o.uppercase {
+		greeting = strings.ToUpper(greeting)
+	}
+	return o.transforms.Apply(greeting)
+}
+
+// formatGreeting returns fmt.Sprintf(format, name), without fmt for the
+// usual format with a single %s.
+func formatGreeting(format, name string) string {
+	before, after, ok := splitFormat(format)
+	if !ok {
+		return fmt.Sprintf(format, name)
+	}
+	var b strings.Builder
+	b.Grow(len(before) + len(name) + len(after))
+	b.WriteString(before)
+	b.WriteString(name)
+	b.WriteString(after)
+	return b.String()
+}
+
+// splitFormat returns the text of format before and after its %s, or false
+// if it has other verbs, or %%, that only fmt can format.
+func splitFormat(format string) (before, after string, ok bool) {
+	before, after, ok = strings.Cut(format, "%s")
+	if !ok || strings.Contains(before, "%") || strings.Contains(after, "%") {
+		return "", "", false
+	}
+	return before, after, true
+}
+
+func (o *greetOptions) joinNames(names []string) string {
+	and := o.localizer.Translate("list.and")
+	switch len(names) {
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " " + and + " " + names[1]
+	}
+	serial := o.localizer.Translate("list.serial_comma") == "true"
+	if o.oxfordComma != nil {
+		serial = *o.oxfordComma
+	}
+	last := len(names) - 1
+	separator := " "
+	if serial {
+		separator = ", "
+	}
+	return strings.Join(names[:last], ", ") + separator + and + " " + names[last]
+}
@@ -0,0 +1,75 @@
+package greeting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppendGreeting(t *testing.T) {
+	for _, name := range []string{"Ada", "Grace Hopper", "  Ada  ", "A\u200bda", "Zoe\u0308", "Zoë", "100%"} {
+		got := string(AppendGreeting([]byte("> "), name))
+		if want := "> " + Greet(name); got != want {
+			t.Errorf("AppendGreeting(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGreetOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"Ada", nil, "Hello, Ada!"},
+		{"Ada", []Option{WithGreetingWord("Hi")}, "Hi, Ada!"},
+		{"Ada", []Option{WithGreetingWord("100% hello")}, "100% hello, Ada!"},
+		{"Ada", []Option{WithGreetingWord("%s %d %v")}, "%s %d %v, Ada!"},
+		{"Ada", []Option{WithPunctuation("%")}, "Hello, Ada%"},
+		{"Ada", []Option{WithGreetingWord("50%"), WithPunctuation("%!")}, "50%, Ada%!"},
+		{"100%", []Option{WithGreetingWord("Hi")}, "Hi, 100%!"},
+		{"Ada", []Option{WithGreetingWord("Hi"), WithUppercase()}, "HI, ADA!"},
+		{"Ada", []Option{WithTransforms(strings.ToLower, func(s string) string { return s + "?" })}, "hello, ada!?"},
+	}
+	for _, tt := range tests {
+		if got := Greet(tt.name, tt.opts...); got != tt.want {
+			t.Errorf("Greet(%q, ...) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendGreetingAllocs(t *testing.T) {
+	dst := make([]byte, 0, 64)
+	for _, name := range []string{"Ada", "Grace Hopper"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			dst = AppendGreeting(dst[:0], name)
+		})
+		if allocs != 0 {
+			t.Errorf("AppendGreeting(%q) allocated %v times per call, want 0", name, allocs)
+		}
+	}
+}
+
+func BenchmarkAppendGreeting(b *testing.B) {
+	dst := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for b.Loop() {
+		dst = AppendGreeting(dst[:0], "Ada")
+	}
+}
+
+// BenchmarkAppendGreetingUnnormalized measures the path of names that need
+// normalizing, which allocates for the normalized name.
+func BenchmarkAppendGreetingUnnormalized(b *testing.B) {
+	dst := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for b.Loop() {
+		dst = AppendGreeting(dst[:0], "  Ada  ")
+	}
+}
+
+func BenchmarkGreet(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		Greet("Ada")
+	}
+}
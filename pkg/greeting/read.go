@@ -0,0 +1,30 @@
+package greeting
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// ReadNames reads one recipient per line. Lines are parsed as CSV and only
+// the first field is used, so both plain name lists and "name,email,..."
+// exports work. Blank lines and lines starting with # are skipped.
+func ReadNames(r io.Reader) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var names []string
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+		if name := strings.TrimSpace(record[0]); name != "" {
+			names = append(names, name)
+		}
+	}
+}
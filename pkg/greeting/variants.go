@@ -0,0 +1,133 @@
+package greeting
+
+import (
+	"context"
+	"math/bits"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/trace"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+// Variant is one phrasing of a greeting: a translation key, whose
+// translations take the name as the "greeting" key's do, and how often it
+// is picked relative to the others. A Weight below 1 counts as 1.
+type Variant struct {
+	Key    string
+	Weight int
+}
+
+// DefaultVariants mostly greet with the plain greeting, sometimes casually
+// and now and then with a welcome.
+var DefaultVariants = []Variant{
+	{Key: "greeting", Weight: 6},
+	{Key: "greeting.casual", Weight: 3},
+	{Key: "greeting.welcome", Weight: 1},
+}
+
+// FixedSource is a rand.Source that always returns the same number, so
+// that a VariantGreeter always picks the same variant. The number is read
+// as a fraction of 2^64 of the candidates' total weight: FixedSource(n)
+// picks the variant whose weights span n*total/2^64, so FixedSource(0)
+// picks the first, and FixedVariant returns the source of any other.
+type FixedSource uint64
+
+func (s FixedSource) Uint64() uint64 {
+	return uint64(s)
+}
+
+// FixedVariant returns the source with which a VariantGreeter picks the
+// variant whose weights span position i of total, the sum of the weights
+// of the variants picked from. With DefaultVariants, FixedVariant(0, 10)
+// picks "greeting", FixedVariant(6, 10) "greeting.casual" and
+// FixedVariant(9, 10) "greeting.welcome". It panics unless 0 <= i < total.
+func FixedVariant(i, total int) FixedSource {
+	if i < 0 || i >= total {
+		panic("greeting: FixedVariant position out of range")
+	}
+	// The smallest n with n*total/2^64 >= i, rounding i*2^64/total up.
+	n, rem := bits.Div64(uint64(i), 0, uint64(total))
+	if rem != 0 {
+		n++
+	}
+	return FixedSource(n)
+}
+
+// SeededSource returns a source that yields the same numbers for the same
+// seed, so that a VariantGreeter picks the same variants in the same order.
+func SeededSource(seed uint64) rand.Source {
+	return rand.NewPCG(seed, seed)
+}
+
+// VariantGreeter greets with one of Variants, or DefaultVariants if nil,
+// picked at random by weight so that greetings do not read the same every
+// time. Only the variants translated for the greeting's locale or its
+// language are picked from, so that a locale that lacks one is not greeted
+// in another language; the "greeting" key is used if it lacks them all.
+type VariantGreeter struct {
+	Localizer *Localizer
+	Variants  []Variant
+	// Source is the randomness variants are picked with, which the greeter
+	// guards, so it need not be safe for concurrent use. If nil, a source
+	// seeded from the time is used.
+	Source rand.Source
+
+	mu sync.Mutex
+}
+
+// NewVariantGreeter returns a greeter of DefaultVariants that picks them
+// with source.
+func NewVariantGreeter(l *Localizer, source rand.Source) *VariantGreeter {
+	return &VariantGreeter{Localizer: l, Source: source}
+}
+
+func (g *VariantGreeter) Greet(ctx context.Context, name string) (message.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return message.Message{}, err
+	}
+	l := localizerFor(ctx, g.Localizer)
+	_, span := trace.Start(ctx, "greeting.localize", trace.String("locale", l.Locale()))
+	defer span.End()
+	key := g.pick(l)
+	span.SetAttributes(trace.String("key", key))
+	return newGreeting(l, l.Format(key, name)), nil
+}
+
+// pick returns the key of a variant l translates, chosen by weight.
+func (g *VariantGreeter) pick(l *Localizer) string {
+	variants := g.Variants
+	if variants == nil {
+		variants = DefaultVariants
+	}
+	var candidates []Variant
+	total := 0
+	for _, v := range variants {
+		if !l.translates(v.Key) {
+			continue
+		}
+		v.Weight = max(v.Weight, 1)
+		candidates = append(candidates, v)
+		total += v.Weight
+	}
+	if len(candidates) == 0 {
+		return "greeting"
+	}
+	g.mu.Lock()
+	if g.Source == nil {
+		g.Source = SeededSource(uint64(time.Now().UnixNano()))
+	}
+	// The high half of the product scales the number to [0, total), with
+	// no retries that a FixedSource would never get out of.
+	hi, _ := bits.Mul64(g.Source.Uint64(), uint64(total))
+	g.mu.Unlock()
+	n := int(hi)
+	for _, v := range candidates {
+		if n < v.Weight {
+			return v.Key
+		}
+		n -= v.Weight
+	}
+	return candidates[len(candidates)-1].Key
+}
@@ -0,0 +1,62 @@
+package greeting
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestFixedVariant(t *testing.T) {
+	// DefaultVariants weigh greeting 6, greeting.casual 3 and
+	// greeting.welcome 1, for a total of 10.
+	want := []string{
+		"Hello, Ada!", "Hello, Ada!", "Hello, Ada!", "Hello, Ada!", "Hello, Ada!", "Hello, Ada!",
+		"Hi, Ada!", "Hi, Ada!", "Hi, Ada!",
+		"Welcome, Ada!",
+	}
+	for i, text := range want {
+		g := NewVariantGreeter(NewLocalizer("en"), FixedVariant(i, len(want)))
+		m, err := g.Greet(context.Background(), "Ada")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Text != text {
+			t.Errorf("FixedVariant(%d, 10) greeted %q, want %q", i, m.Text, text)
+		}
+	}
+}
+
+func TestFixedSource(t *testing.T) {
+	tests := []struct {
+		source FixedSource
+		want   string
+	}{
+		{0, "Hello, Ada!"},
+		{math.MaxUint64 / 10 * 6, "Hello, Ada!"},
+		{math.MaxUint64/10*6 + math.MaxUint64/10, "Hi, Ada!"},
+		{math.MaxUint64, "Welcome, Ada!"},
+	}
+	for _, tt := range tests {
+		g := NewVariantGreeter(NewLocalizer("en"), tt.source)
+		m, err := g.Greet(context.Background(), "Ada")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Text != tt.want {
+			t.Errorf("FixedSource(%d) greeted %q, want %q", uint64(tt.source), m.Text, tt.want)
+		}
+	}
+}
+
+func TestFixedVariantRange(t *testing.T) {
+	for _, tt := range [][2]int{{-1, 10}, {10, 10}, {0, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("FixedVariant(%d, %d) did not panic", tt[0], tt[1])
+				}
+			}()
+			FixedVariant(tt[0], tt[1])
+		}()
+	}
+}
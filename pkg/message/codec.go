@@ -0,0 +1,292 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+type messageJSON struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Sender    string    `json:"sender,omitempty"`
+	Severity  Severity  `json:"severity"`
+	Tags      []string  `json:"tags,omitempty"`
+	Text      string    `json:"text"`
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(severityNames) {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if string(text) == name {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(messageJSON(m))
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var v messageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Message(v)
+	return nil
+}
+
+func MarshalMessage(m Message, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(m, "", "  ")
+	case FormatYAML:
+		return encodeFlat(m, ": "), nil
+	case FormatTOML:
+		return encodeFlat(m, " = "), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// UnmarshalMessage decodes data in the given format. In strict mode unknown
+// fields are rejected instead of ignored.
+func UnmarshalMessage(data []byte, format Format, strict bool) (Message, error) {
+	var m Message
+	switch format {
+	case FormatJSON:
+		var v messageJSON
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(&v); err != nil {
+			return m, err
+		}
+		return Message(v), nil
+	case FormatYAML:
+		return m, decodeFlat(data, &m, ":", strict)
+	case FormatTOML:
+		return m, decodeFlat(data, &m, "=", strict)
+	}
+	return m, fmt.Errorf("unsupported format %q", format)
+}
+
+// encodeFlat and decodeFlat implement the YAML and TOML codecs. They only
+// cover the flat, one-key-per-line documents that Message needs: scalars,
+// inline lists and, for YAML, block lists.
+func encodeFlat(m Message, assign string) []byte {
+	var b bytes.Buffer
+	line := func(key, value string) {
+		b.WriteString(key + assign + value + "\n")
+	}
+	line("id", quote(m.ID))
+	line("created_at", m.CreatedAt.Format(time.RFC3339Nano))
+	if m.Sender != "" {
+		line("sender", quote(m.Sender))
+	}
+	line("severity", quote(m.Severity.String()))
+	if len(m.Tags) > 0 {
+		tags := make([]string, len(m.Tags))
+		for i, t := range m.Tags {
+			tags[i] = quote(t)
+		}
+		line("tags", "["+strings.Join(tags, ", ")+"]")
+	}
+	line("text", quote(m.Text))
+	return b.Bytes()
+}
+
+func decodeFlat(data []byte, m *Message, assign string, strict bool) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var listKey string
+	var list []string
+	flush := func() error {
+		if listKey == "" {
+			return nil
+		}
+		err := m.setField(listKey, flatValue{list: list, isList: true}, strict)
+		listKey, list = "", nil
+		return err
+	}
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line == "---" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok && listKey != "" && assign == ":" {
+			value, err := parseScalar(item)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			list = append(list, value)
+			continue
+		}
+		if err := flush(); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		key, raw, ok := strings.Cut(line, assign)
+		if !ok {
+			return fmt.Errorf("line %d: expected key%svalue", n, assign)
+		}
+		key, raw = strings.TrimSpace(key), strings.TrimSpace(raw)
+		var err error
+		switch {
+		case raw == "" && assign == ":":
+			listKey = key
+		case strings.HasPrefix(raw, "["):
+			var items []string
+			if items, err = parseList(raw); err == nil {
+				err = m.setField(key, flatValue{list: items, isList: true}, strict)
+			}
+		default:
+			var value string
+			if value, err = parseScalar(raw); err == nil {
+				err = m.setField(key, flatValue{scalar: value}, strict)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+type flatValue struct {
+	scalar string
+	list   []string
+	isList bool
+}
+
+func (m *Message) setField(key string, v flatValue, strict bool) error {
+	switch key {
+	case "id", "created_at", "sender", "severity", "text":
+		if v.isList {
+			return fmt.Errorf("%s: expected a scalar", key)
+		}
+	case "tags":
+		if !v.isList {
+			return fmt.Errorf("tags: expected a list")
+		}
+		m.Tags = v.list
+		return nil
+	default:
+		if strict {
+			return fmt.Errorf("unknown field %q", key)
+		}
+		return nil
+	}
+	var err error
+	switch key {
+	case "id":
+		m.ID = v.scalar
+	case "created_at":
+		m.CreatedAt, err = time.Parse(time.RFC3339Nano, v.scalar)
+	case "sender":
+		m.Sender = v.scalar
+	case "severity":
+		err = m.Severity.UnmarshalText([]byte(v.scalar))
+	case "text":
+		m.Text = v.scalar
+	}
+	return err
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func parseScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		var s string
+		end := closingQuote(raw)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		err := json.Unmarshal([]byte(raw[:end+1]), &s)
+		return s, err
+	case strings.HasPrefix(raw, "'"):
+		end := closingQuote(raw)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strings.ReplaceAll(raw[1:end], "''", "'"), nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+func parseList(raw string) ([]string, error) {
+	end := strings.LastIndexByte(raw, ']')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated list %s", raw)
+	}
+	body := strings.TrimSpace(raw[1:end])
+	var items []string
+	for body != "" {
+		item := body
+		if body[0] == '"' || body[0] == '\'' {
+			end := closingQuote(body)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", body)
+			}
+			item, body = body[:end+1], body[end+1:]
+		} else if i := strings.IndexByte(body, ','); i >= 0 {
+			item, body = body[:i], body[i:]
+		} else {
+			body = ""
+		}
+		value, err := parseScalar(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+		body = strings.TrimSpace(body)
+		body = strings.TrimSpace(strings.TrimPrefix(body, ","))
+	}
+	return items, nil
+}
+
+// closingQuote returns the index of the quote that closes s[0], honouring
+// backslash escapes in double-quoted strings and doubled quotes in
+// single-quoted ones.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+// Package message defines Message, the greeting or notice every other
+// package passes around, with its severities, tags, validation and the
+// encodings it is stored and sent in.
+//
+// Message is the module's public API together with packages greeting and
+// render, so its fields and encodings change only in a new major version.
+package message
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/emoji"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/locale"
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/transform"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityNotice
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = [...]string{"info", "notice", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+const defaultSender = "greeter"
+
+type Message struct {
+	ID        string
+	CreatedAt time.Time
+	Sender    string
+	Severity  Severity
+	Tags      []string
+	Text      string
+}
+
+func NewMessage(text string, tags ...string) Message {
+	return Message{
+		ID:        newMessageID(),
+		CreatedAt: time.Now(),
+		Sender:    defaultSender,
+		Severity:  SeverityInfo,
+		Tags:      tags,
+		Text:      text,
+	}
+}
+
+func newMessageID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (m Message) HasTag(tag string) bool {
+	return slices.Contains(m.Tags, tag)
+}
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"title": transform.TitleCase,
+	"emoji": emoji.Expand,
+	"trunc": trunc,
+}
+
+// Render executes m's text as a text/template with data, with the
+// functions of templateFuncs and those of locale.Locale.Funcs for the
+// environment's locale.
+func (m Message) Render(data any) (string, error) {
+	tmpl, err := template.New("message").Funcs(templateFuncs).Funcs(locale.For(locale.Detect()).Funcs()).Parse(emoji.Expand(m.Text))
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func trunc(n int, s string) string {
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// Transform returns a copy of m with t applied to its text, for example a
+// transform.Chain's Apply method.
+func (m Message) Transform(t func(string) string) Message {
+	m.Text = t(m.Text)
+	return m
+}
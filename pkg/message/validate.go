@@ -0,0 +1,166 @@
+package message
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/normalize"
+)
+
+var (
+	ErrEmpty       = errors.New("empty")
+	ErrTooLong     = errors.New("too long")
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+	ErrControlChar = errors.New("control character")
+	ErrBannedWord  = errors.New("banned word")
+	ErrConfusable  = errors.New("mixes confusable scripts")
+)
+
+// ValidationError reports the rules a field failed. Err holds the failures,
+// each wrapping one of the ErrEmpty, ErrTooLong, ... sentinels.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, strings.ReplaceAll(e.Err.Error(), "\n", "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches another ValidationError for the same field whose Err is among
+// this error's failures, so errors.Is(err, greeting.ErrEmptyName) works.
+func (e *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && t.Field == e.Field && errors.Is(e.Err, t.Err)
+}
+
+type Rule func(text string) error
+
+type Validator struct {
+	Rules []Rule
+}
+
+const DefaultMaxLength = 1024
+
+var DefaultValidator = &Validator{
+	Rules: []Rule{NotEmpty(), ValidUTF8(), MaxLength(DefaultMaxLength), NoControlChars()},
+}
+
+// Validate runs every rule against the named field and returns a
+// *ValidationError joining all failures, or nil.
+func (v *Validator) Validate(field, text string) error {
+	var errs []error
+	for _, rule := range v.Rules {
+		if err := rule(text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Field: field, Err: errors.Join(errs...)}
+}
+
+func (m Message) Validate() error {
+	return DefaultValidator.Validate("text", m.Text)
+}
+
+func NotEmpty() Rule {
+	return func(text string) error {
+		if strings.TrimSpace(text) == "" {
+			return ErrEmpty
+		}
+		return nil
+	}
+}
+
+func MaxLength(n int) Rule {
+	return func(text string) error {
+		if count := utf8.RuneCountInString(text); count > n {
+			return fmt.Errorf("%w: %d characters, limit is %d", ErrTooLong, count, n)
+		}
+		return nil
+	}
+}
+
+func ValidUTF8() Rule {
+	return func(text string) error {
+		if !utf8.ValidString(text) {
+			return ErrInvalidUTF8
+		}
+		return nil
+	}
+}
+
+func NoControlChars() Rule {
+	return func(text string) error {
+		for i, r := range text {
+			if unicode.IsControl(r) && r != '\n' && r != '\t' {
+				return fmt.Errorf("%w %U at byte %d", ErrControlChar, r, i)
+			}
+		}
+		return nil
+	}
+}
+
+// NoMixedScripts rejects text mixing look-alike scripts, such as a Cyrillic
+// "а" inside an otherwise Latin name.
+func NoMixedScripts() Rule {
+	return func(text string) error {
+		if scripts := normalize.Scripts(text); len(scripts) > 1 {
+			return fmt.Errorf("%w %s", ErrConfusable, strings.Join(scripts, " and "))
+		}
+		return nil
+	}
+}
+
+func BannedWords(words ...string) Rule {
+	banned := make(map[string]bool, len(words))
+	for _, w := range words {
+		banned[strings.ToLower(w)] = true
+	}
+	return func(text string) error {
+		for _, w := range strings.FieldsFunc(text, isWordSeparator) {
+			if banned[strings.ToLower(w)] {
+				return fmt.Errorf("%w %q", ErrBannedWord, w)
+			}
+		}
+		return nil
+	}
+}
+
+// LoadBannedWords reads one word per line; blank lines and lines starting
+// with # are ignored.
+func LoadBannedWords(name string) (Rule, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return BannedWords(words...), nil
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
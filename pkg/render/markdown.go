@@ -0,0 +1,123 @@
+package render
+
+import (
+	"html"
+	"strings"
+)
+
+type mdStyle int
+
+const (
+	mdPlain mdStyle = iota
+	mdBold
+	mdItalic
+	mdCode
+)
+
+type mdSpan struct {
+	style mdStyle
+	text  string
+}
+
+// MarkdownHTML renders text as HTML, escaping everything that is not markup.
+// Only paragraphs, **bold**, *italic* or _italic_, and `code` spans are
+// supported, without nesting.
+func MarkdownHTML(text string) string {
+	var b strings.Builder
+	for _, para := range mdParagraphs(text) {
+		b.WriteString("<p>")
+		for _, span := range mdInline(para) {
+			escaped := html.EscapeString(span.text)
+			switch span.style {
+			case mdBold:
+				b.WriteString("<strong>" + escaped + "</strong>")
+			case mdItalic:
+				b.WriteString("<em>" + escaped + "</em>")
+			case mdCode:
+				b.WriteString("<code>" + escaped + "</code>")
+			default:
+				b.WriteString(escaped)
+			}
+		}
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// MarkdownANSI renders text for a terminal. With color disabled the markup
+// is simply dropped.
+func MarkdownANSI(text string, color bool) string {
+	var b strings.Builder
+	for i, para := range mdParagraphs(text) {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		for _, span := range mdInline(para) {
+			if !color || span.style == mdPlain {
+				b.WriteString(span.text)
+				continue
+			}
+			switch span.style {
+			case mdBold:
+				b.WriteString(sgrBold)
+			case mdItalic:
+				b.WriteString("\x1b[3m")
+			case mdCode:
+				b.WriteString("\x1b[36m")
+			}
+			b.WriteString(span.text + sgrReset)
+		}
+	}
+	return b.String()
+}
+
+func mdParagraphs(text string) []string {
+	var paras []string
+	for _, block := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if block = strings.Join(strings.Fields(block), " "); block != "" {
+			paras = append(paras, block)
+		}
+	}
+	return paras
+}
+
+func mdInline(s string) []mdSpan {
+	var spans []mdSpan
+	var plain strings.Builder
+	emit := func(style mdStyle, text string) {
+		if plain.Len() > 0 {
+			spans = append(spans, mdSpan{mdPlain, plain.String()})
+			plain.Reset()
+		}
+		spans = append(spans, mdSpan{style, text})
+	}
+	for i := 0; i < len(s); {
+		delim, style := "", mdPlain
+		switch {
+		case s[i] == '`':
+			delim, style = "`", mdCode
+		case strings.HasPrefix(s[i:], "**"):
+			delim, style = "**", mdBold
+		case s[i] == '*' || s[i] == '_' && (i == 0 || !isWordByte(s[i-1])):
+			delim, style = s[i:i+1], mdItalic
+		}
+		if delim != "" {
+			if end := strings.Index(s[i+len(delim):], delim); end > 0 {
+				start := i + len(delim)
+				emit(style, s[start:start+end])
+				i = start + end + len(delim)
+				continue
+			}
+		}
+		plain.WriteByte(s[i])
+		i++
+	}
+	if plain.Len() > 0 {
+		spans = append(spans, mdSpan{mdPlain, plain.String()})
+	}
+	return spans
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+type Output string
+
+const (
+	OutputText  Output = "text"
+	OutputJSON  Output = "json"
+	OutputJSONL Output = "jsonl"
+	OutputYAML  Output = "yaml"
+)
+
+func ParseOutput(s string) (Output, error) {
+	switch o := Output(s); o {
+	case OutputText, OutputJSON, OutputJSONL, OutputYAML:
+		return o, nil
+	}
+	return "", fmt.Errorf("invalid output %q: want text, json, jsonl or yaml", s)
+}
+
+type Printer struct {
+	w        io.Writer
+	renderer *Renderer
+	output   Output
+	printed  int
+}
+
+func NewPrinter(w io.Writer, r *Renderer) *Printer {
+	return &Printer{w: w, renderer: r, output: OutputText}
+}
+
+func (p *Printer) SetOutput(o Output) {
+	p.output = o
+}
+
+func (p *Printer) Print(m message.Message, names ...string) error {
+	b, err := p.format(m, names)
+	if err != nil {
+		return err
+	}
+	p.printed++
+	_, err = p.w.Write(b)
+	return err
+}
+
+func (p *Printer) format(m message.Message, names []string) ([]byte, error) {
+	switch p.output {
+	case OutputJSON:
+		b, err := json.MarshalIndent(m, "", "  ")
+		return append(b, '\n'), err
+	case OutputJSONL:
+		b, err := json.Marshal(m)
+		return append(b, '\n'), err
+	case OutputYAML:
+		b, err := message.MarshalMessage(m, message.FormatYAML)
+		if p.printed > 0 {
+			b = append([]byte("---\n"), b...)
+		}
+		return b, err
+	}
+	return []byte(p.renderer.Render(m, names...) + "\n"), nil
+}
@@ -0,0 +1,126 @@
+// Package render writes messages for people to read: in color on terminals,
+// as Markdown or HTML, and through Printer as text, JSON, JSON lines or
+// YAML.
+//
+// It is a public package of the module, covered by the promises of the API
+// stability section of the README.
+package render
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fanda-blazek/syntax-highlighting-test/internal/emoji"
+	"github.com/fanda-blazek/syntax-highlighting-test/pkg/message"
+)
+
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+func ParseColorMode(s string) (ColorMode, error) {
+	switch s {
+	case "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	}
+	return ColorAuto, fmt.Errorf("invalid color mode %q: want auto, always or never", s)
+}
+
+var colorModeNames = [...]string{"auto", "always", "never"}
+
+// String returns the name ParseColorMode accepts for c, or unknown for a
+// value with none.
+func (c ColorMode) String() string {
+	if c < 0 || int(c) >= len(colorModeNames) {
+		return "unknown"
+	}
+	return colorModeNames[c]
+}
+
+// Enabled reports whether output written to f should be colored.
+func (c ColorMode) Enabled(f *os.File) bool {
+	switch c {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" || AccessibleEnv() {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// AccessibleEnv reports whether $GREETER_A11Y asks for accessible output,
+// with a value such as 1 or true.
+func AccessibleEnv() bool {
+	on, _ := strconv.ParseBool(os.Getenv("GREETER_A11Y"))
+	return on
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	sgrReset = "\x1b[0m"
+	sgrBold  = "\x1b[1m"
+)
+
+type Theme map[message.Severity]string
+
+var DefaultTheme = Theme{
+	message.SeverityInfo:    "\x1b[32m",
+	message.SeverityNotice:  "\x1b[36m",
+	message.SeverityWarning: "\x1b[33m",
+	message.SeverityError:   "\x1b[31m",
+}
+
+type Renderer struct {
+	Theme Theme
+	Color bool
+	// Accessible renders for screen readers: without color, with emoji
+	// described in words, and with the severity of messages above info
+	// said rather than colored, as in "Warning: disk nearly full".
+	Accessible bool
+}
+
+// NewRenderer returns a renderer coloring its output if color is set, and
+// accessible if AccessibleEnv says so.
+func NewRenderer(color bool) *Renderer {
+	return &Renderer{Theme: DefaultTheme, Color: color, Accessible: AccessibleEnv()}
+}
+
+// Render colors m by severity and bolds every occurrence of the given names.
+func (r *Renderer) Render(m message.Message, names ...string) string {
+	if r != nil && r.Accessible {
+		text := emoji.Describe(m.Text)
+		if m.Severity > message.SeverityInfo {
+			severity := m.Severity.String()
+			text = strings.ToUpper(severity[:1]) + severity[1:] + ": " + text
+		}
+		return text
+	}
+	if r == nil || !r.Color {
+		return m.Text
+	}
+	color := r.Theme[m.Severity]
+	text := m.Text
+	for _, name := range names {
+		if name != "" {
+			text = strings.ReplaceAll(text, name, sgrBold+name+sgrReset+color)
+		}
+	}
+	return color + text + sgrReset
+}
@@ -0,0 +1,28 @@
+package render
+
+import "testing"
+
+func TestColorModeString(t *testing.T) {
+	tests := []struct {
+		mode ColorMode
+		want string
+	}{
+		{ColorAuto, "auto"},
+		{ColorAlways, "always"},
+		{ColorNever, "never"},
+		{ColorNever + 1, "unknown"},
+		{-1, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.String(); got != tt.want {
+			t.Errorf("ColorMode(%d).String() = %q, want %q", int(tt.mode), got, tt.want)
+		}
+		if tt.mode >= ColorAuto && tt.mode <= ColorNever {
+			if m, err := ParseColorMode(tt.want); err != nil || m != tt.mode {
+				t.Errorf("ParseColorMode(%q) = %v, %v, want %v", tt.want, m, err, tt.mode)
+			}
+		} else if _, err := ParseColorMode(tt.want); err == nil {
+			t.Errorf("ParseColorMode(%q) succeeded for a value with no name", tt.want)
+		}
+	}
+}